@@ -0,0 +1,96 @@
+// Command api-keys manages the keys file named by API_KEYS_PATH. The file
+// stores a salted hash and a short prefix of each key, never the key itself.
+//
+//	api-keys create -file keys.yaml -name ci -environment dev   # prints the new key once
+//	api-keys hash -name legacy < key.txt                        # entry for an existing key
+//	api-keys migrate -file keys.yaml                            # hash plaintext entries
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/apikey"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/middleware"
+	"gopkg.in/yaml.v3"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: api-keys create|hash|migrate [flags]")
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "create":
+		err = create(os.Args[2:])
+	case "hash":
+		err = hash(os.Args[2:])
+	case "migrate":
+		err = migrate(os.Args[2:])
+	default:
+		err = fmt.Errorf("unknown command %q", os.Args[1])
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "api-keys: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func create(args []string) error {
+	flags := flag.NewFlagSet("create", flag.ExitOnError)
+	path := flags.String("file", "api-keys.yaml", "keys file to add the key to")
+	name := flags.String("name", "", "name of the key, e.g. the team or service using it")
+	environment := flags.String("environment", "", "environment of the key, e.g. dev or prod, which picks its routing profile")
+	flags.Parse(args)
+
+	key, entry, err := apikey.Generate(*name)
+	if err != nil {
+		return err
+	}
+	entry.Environment = *environment
+	if err := apikey.Append(*path, entry); err != nil {
+		return err
+	}
+	fmt.Printf("Added key %s to %s, key ID %s\n", entry.Prefix, *path, middleware.HashKey(key))
+	fmt.Printf("%s\n", key)
+	fmt.Fprintln(os.Stderr, "The key is not stored and cannot be shown again")
+	return nil
+}
+
+func hash(args []string) error {
+	flags := flag.NewFlagSet("hash", flag.ExitOnError)
+	name := flags.String("name", "", "name of the key")
+	environment := flags.String("environment", "", "environment of the key")
+	flags.Parse(args)
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return fmt.Errorf("reading key from stdin: %w", err)
+	}
+	entry, err := apikey.Hash(*name, strings.TrimSpace(line))
+	if err != nil {
+		return err
+	}
+	entry.Environment = *environment
+	encoder := yaml.NewEncoder(os.Stdout)
+	encoder.SetIndent(2)
+	return encoder.Encode([]apikey.Entry{entry})
+}
+
+func migrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	path := flags.String("file", "api-keys.yaml", "keys file to migrate")
+	flags.Parse(args)
+
+	migrated, err := apikey.Migrate(*path)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Hashed %d plaintext keys in %s\n", migrated, *path)
+	return nil
+}
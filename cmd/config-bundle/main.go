@@ -0,0 +1,153 @@
+// Command config-bundle creates signing keys and signed configuration bundles
+// without a running gateway, for air-gapped environments. Bundles are imported
+// with POST /admin/config/bundle on the target instance.
+//
+//	config-bundle keygen -name staging                         # staging.key, staging.pub
+//	config-bundle export -key staging.key -out b.tar.gz providers.csv configs
+//	config-bundle verify -pub staging.pub b.tar.gz              # exit 1 unless trusted
+package main
+
+import (
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/bundle"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: config-bundle keygen|export|verify [flags]")
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "keygen":
+		err = keygen(os.Args[2:])
+	case "export":
+		err = export(os.Args[2:])
+	case "verify":
+		err = verify(os.Args[2:])
+	default:
+		err = fmt.Errorf("unknown command %q", os.Args[1])
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config-bundle: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func keygen(args []string) error {
+	flags := flag.NewFlagSet("keygen", flag.ExitOnError)
+	name := flags.String("name", "bundle", "key file name, without extension")
+	flags.Parse(args)
+
+	public, err := bundle.GenerateKey(*name+".key", *name+".pub")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s.key and %s.pub, key ID %s\n", *name, *name, bundle.KeyID(public))
+	return nil
+}
+
+func export(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	keyPath := flags.String("key", "", "private signing key (required)")
+	out := flags.String("out", "config-bundle.tar.gz", "bundle file to write")
+	source := flags.String("source", "", "name of the environment the bundle comes from")
+	flags.Parse(args)
+	if *keyPath == "" || flags.NArg() == 0 {
+		return fmt.Errorf("export requires -key and at least one file or directory")
+	}
+
+	key, err := bundle.LoadPrivateKey(*keyPath)
+	if err != nil {
+		return err
+	}
+	files, err := expandPaths(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	manifest, err := bundle.Export(file, files, key, *source)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s with %d files, signed by %s\n", *out, len(manifest.Files), manifest.KeyID)
+	return file.Close()
+}
+
+func verify(args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	pub := flags.String("pub", "", "comma-separated trusted public keys (required)")
+	flags.Parse(args)
+	if *pub == "" || flags.NArg() != 1 {
+		return fmt.Errorf("verify requires -pub and one bundle file")
+	}
+
+	var trusted []ed25519.PublicKey
+	for _, path := range strings.Split(*pub, ",") {
+		key, err := bundle.LoadPublicKey(strings.TrimSpace(path))
+		if err != nil {
+			return err
+		}
+		trusted = append(trusted, key)
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	verified, err := bundle.Read(file, trusted)
+	if err != nil {
+		return err
+	}
+	manifest := verified.Manifest
+	fmt.Printf("Signed by %s, exported %s", manifest.KeyID, manifest.CreatedAt.Format("2006-01-02 15:04:05 MST"))
+	if manifest.Source != "" {
+		fmt.Printf(" from %s", manifest.Source)
+	}
+	fmt.Println()
+	for _, file := range manifest.Files {
+		fmt.Printf("  %s (%d bytes)\n", file.Path, file.Size)
+	}
+	return nil
+}
+
+// expandPaths replaces each directory with the regular, non-hidden files
+// directly inside it, as the gateway's configuration history tracks them
+func expandPaths(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.Type().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+	return files, nil
+}
@@ -2,23 +2,100 @@ package main
 
 import (
 	"context"
+	"crypto/ed25519"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
+	"unicode/utf8"
 
 	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/internal/enhanced"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/admin"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/analytics"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/apikey"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/artifact"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/assistant"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/bootstrap"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/bundle"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/cassette"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/classify"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/cluster"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/credentials"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/envelope"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/environment"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/events"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/flags"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/health"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/hooks"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/incident"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/injection"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/kube"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/leader"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/logging"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/middleware"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/objstore"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/oidc"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/onboard"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/postprocess"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/promptpolicy"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/probe"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/reasoning"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/reload"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/reporting"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/requestid"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/retention"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/safety"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/scrub"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/selection"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/sla"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/speculative"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tenant"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/validation"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/wasmplugin"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 )
 
 func main() {
-	// Initialize logger
-	logger := logrus.New()
-	logger.SetLevel(logrus.InfoLevel)
+	// Initialize logging: LOG_LEVEL sets the default, LOG_MODULES overrides single
+	// modules (e.g. "selection=debug,analytics=warn")
+	if os.Getenv("LOG_FORMAT") == "json" {
+		logging.Default.SetFormatter(&logrus.JSONFormatter{})
+	}
+	if err := logging.Default.Configure(os.Getenv("LOG_LEVEL"), os.Getenv("LOG_MODULES")); err != nil {
+		logrus.Fatalf("Invalid logging configuration: %v", err)
+	}
+	logger := logging.Module("server")
+	// With DATA_DIR set, relative paths are resolved there rather than in the
+	// directory the server was started in
+	if dataDir := os.Getenv("DATA_DIR"); dataDir != "" {
+		if err := os.MkdirAll(dataDir, 0o755); err != nil {
+			logger.Fatalf("Failed to create DATA_DIR: %v", err)
+		}
+		if err := os.Chdir(dataDir); err != nil {
+			logger.Fatalf("Invalid DATA_DIR: %v", err)
+		}
+	}
+	// Paths given as s3:// or gs:// URLs are read from and written back to
+	// object storage, so the gateway can run on ephemeral containers
+	objectMirror := newObjectMirror(logger)
+	if os.Getenv("BOOTSTRAP") != "false" {
+		bootstrapDataDir(logger)
+	}
+	scrubber := newScrubber(logger)
+	if scrubber != nil {
+		logging.Default.AddHook(scrub.NewLogHook(scrubber))
+	}
 
 	// Create some default providers for demonstration
 	providers := []*enhanced.Provider{
@@ -50,37 +127,479 @@ func main() {
 
 	// Initialize enhanced system
 	system := enhanced.NewEnhancedSystem(providers)
+	system.SetScrubber(scrubber)
+	// METRICS_RETENTION is the default for every kind of stored data
+	metricsRetention := durationFromEnv(logger, "METRICS_RETENTION", enhanced.DefaultMetricsRetention)
+	retentionPolicy := retention.Policy{
+		Metrics:        metricsRetention,
+		RequestHistory: durationFromEnv(logger, "HISTORY_RETENTION", metricsRetention),
+		Analytics:      durationFromEnv(logger, "ANALYTICS_RETENTION", metricsRetention),
+		AccessLog:      durationFromEnv(logger, "ACCESS_LOG_RETENTION", 0),
+		Audit:          durationFromEnv(logger, "AUDIT_RETENTION", metricsRetention),
+	}
+	if dbPath := os.Getenv("METRICS_DB_PATH"); dbPath != "" {
+		storage, err := enhanced.NewMetricsStorage(dbPath)
+		if err != nil {
+			logger.Fatalf("Failed to open metrics storage: %v", err)
+		}
+		storage.SetRetentionPolicy(retentionPolicy)
+		storage.SetSealer(newHistorySealer(logger))
+		system.SetMetricsStorage(storage)
+	} else if os.Getenv("HISTORY_ENCRYPTION") != "" {
+		logger.Warn("HISTORY_ENCRYPTION does not apply to the request history without METRICS_DB_PATH; the in-memory history is not persisted")
+	}
+	system.SetAsyncTimeout(durationFromEnv(logger, "ASYNC_REQUEST_TIMEOUT", enhanced.DefaultAsyncTimeout))
+	paretoPolicy, err := selection.ParseParetoPolicy(os.Getenv("PARETO_POLICY"))
+	if err != nil {
+		logger.Fatalf("Invalid PARETO_POLICY: %v", err)
+	}
+	system.SetParetoPolicy(paretoPolicy)
+	tieBreakStrategy, err := selection.ParseTieBreakStrategy(os.Getenv("TIE_BREAK_STRATEGY"))
+	if err != nil {
+		logger.Fatalf("Invalid TIE_BREAK_STRATEGY: %v", err)
+	}
+	system.SetTieBreaker(selection.NewTieBreaker(tieBreakStrategy, floatFromEnv(logger, "TIE_BREAK_EPSILON", selection.DefaultTieEpsilon)))
+	lowConfidenceFallback, err := selection.ParseLowConfidenceFallback(os.Getenv("LOW_CONFIDENCE_FALLBACK"))
+	if err != nil {
+		logger.Fatalf("Invalid LOW_CONFIDENCE_FALLBACK: %v", err)
+	}
+	minConfidence := floatFromEnv(logger, "MIN_SELECTION_CONFIDENCE", 0)
+	if minConfidence < 0 || minConfidence > 1 {
+		logger.Fatalf("Invalid MIN_SELECTION_CONFIDENCE %g: must be between 0 and 1", minConfidence)
+	}
+	system.SetConfidencePolicy(selection.ConfidencePolicy{MinConfidence: minConfidence, Fallback: lowConfidenceFallback})
+	classificationCacheTTL := durationFromEnv(logger, "CLASSIFICATION_CACHE_TTL", classify.DefaultTTL)
+	if classificationCacheTTL < 0 {
+		logger.Fatalf("Invalid CLASSIFICATION_CACHE_TTL %s: must not be negative", classificationCacheTTL)
+	}
+	system.SetClassificationCache(classificationCacheTTL, int(int64FromEnv(logger, "CLASSIFICATION_CACHE_SIZE", classify.DefaultMaxEntries)))
+	metricsHalfLife := durationFromEnv(logger, "METRICS_HALF_LIFE", selection.DefaultMetricsHalfLife)
+	if metricsHalfLife < 0 {
+		logger.Fatalf("Invalid METRICS_HALF_LIFE %s: must not be negative", metricsHalfLife)
+	}
+	system.SetMetricsHalfLife(metricsHalfLife)
+	warmupPolicy := selection.WarmupPolicy{
+		Requests:         int64FromEnv(logger, "COLD_START_REQUESTS", 0),
+		TrafficShare:     floatFromEnv(logger, "COLD_START_TRAFFIC_SHARE", selection.DefaultWarmupTrafficShare),
+		RequireBenchmark: os.Getenv("COLD_START_BENCHMARK") == "true",
+		PriorStrength:    floatFromEnv(logger, "COLD_START_PRIOR_STRENGTH", selection.DefaultPriorStrength),
+	}
+	if warmupPolicy.TrafficShare <= 0 || warmupPolicy.TrafficShare > 1 {
+		logger.Fatalf("Invalid COLD_START_TRAFFIC_SHARE %g: must be above 0 and at most 1", warmupPolicy.TrafficShare)
+	}
+	if warmupPolicy.PriorStrength < 0 {
+		logger.Fatalf("Invalid COLD_START_PRIOR_STRENGTH %g: must not be negative", warmupPolicy.PriorStrength)
+	}
+	if warmupPolicy.RequireBenchmark && os.Getenv("CAPABILITY_PROBE") != "true" {
+		logger.Fatal("COLD_START_BENCHMARK needs CAPABILITY_PROBE=true; the capability probe is the benchmark")
+	}
+	system.SetWarmupPolicy(warmupPolicy)
+	throughputPolicy := selection.ThroughputPolicy{
+		InteractiveReserve: floatFromEnv(logger, "THROUGHPUT_INTERACTIVE_RESERVE", selection.DefaultInteractiveReserve),
+		MaxPenalty:         floatFromEnv(logger, "MAX_THROUGHPUT_PENALTY", selection.DefaultMaxThroughputPenalty),
+	}
+	if throughputPolicy.InteractiveReserve < 0 || throughputPolicy.InteractiveReserve > 1 {
+		logger.Fatalf("Invalid THROUGHPUT_INTERACTIVE_RESERVE %g: must be between 0 and 1", throughputPolicy.InteractiveReserve)
+	}
+	if throughputPolicy.MaxPenalty < 0 {
+		logger.Fatalf("Invalid MAX_THROUGHPUT_PENALTY %g: must not be negative", throughputPolicy.MaxPenalty)
+	}
+	system.SetThroughputPolicy(throughputPolicy)
+	system.SetMinHealthyProviders(int(int64FromEnv(logger, "MIN_HEALTHY_PROVIDERS", enhanced.DefaultMinHealthyProviders)))
+	system.SetLoadPenalty(int(int64FromEnv(logger, "LOAD_CAPACITY", selection.DefaultLoadCapacity)), floatFromEnv(logger, "MAX_LOAD_PENALTY", selection.DefaultMaxLoadPenalty))
+	draftCheck := speculative.NewHeuristicCheck()
+	draftCheck.MinLength = int(int64FromEnv(logger, "SPECULATIVE_MIN_DRAFT_LENGTH", speculative.DefaultMinDraftLength))
+	system.SetSpeculative(os.Getenv("SPECULATIVE_ROUTING") == "true", draftCheck)
+	modelAliases := selection.DefaultModelAliases()
+	if aliasesPath := os.Getenv("MODEL_ALIASES_PATH"); aliasesPath != "" {
+		if modelAliases, err = selection.LoadModelAliases(aliasesPath); err != nil {
+			logger.Fatalf("Failed to load model aliases: %v", err)
+		}
+		logger.Infof("Loaded model aliases from %s", aliasesPath)
+	}
+	system.SetModelAliases(modelAliases)
+	if virtualModelsPath := os.Getenv("VIRTUAL_MODELS_PATH"); virtualModelsPath != "" {
+		virtualModels, err := selection.LoadVirtualModels(virtualModelsPath)
+		if err != nil {
+			logger.Fatalf("Failed to load virtual models: %v", err)
+		}
+		system.SetVirtualModels(virtualModels)
+		logger.Infof("Loaded virtual models from %s", virtualModelsPath)
+	}
+	if policiesPath := os.Getenv("ROUTING_POLICIES_PATH"); policiesPath != "" {
+		policies, err := selection.LoadRoutingPolicies(policiesPath)
+		if err != nil {
+			logger.Fatalf("Failed to load routing policies: %v", err)
+		}
+		system.SetRoutingPolicies(policies)
+		logger.Infof("Loaded %d routing policies from %s", len(policies.Policies()), policiesPath)
+	}
+	if hooksPath := os.Getenv("HOOKS_PATH"); hooksPath != "" {
+		configured, err := hooks.Load(hooksPath)
+		if err != nil {
+			logger.Fatalf("Failed to load hooks: %v", err)
+		}
+		system.SetHooks(configured)
+		logger.Infof("Loaded %d hooks from %s", len(configured.Specs()), hooksPath)
+	}
+	if scorersPath := os.Getenv("WASM_SCORERS_PATH"); scorersPath != "" {
+		scorers, err := wasmplugin.LoadScorers(context.Background(), scorersPath)
+		if err != nil {
+			logger.Fatalf("Failed to load WASM scorers: %v", err)
+		}
+		system.SetScorers(scorers)
+		logger.Infof("Loaded %d WASM scorers from %s", len(scorers), scorersPath)
+	}
+	if processorsPath := os.Getenv("RESPONSE_PROCESSORS_PATH"); processorsPath != "" {
+		pipelines, err := postprocess.LoadPipelines(processorsPath)
+		if err != nil {
+			logger.Fatalf("Failed to load response processors: %v", err)
+		}
+		system.SetResponseProcessors(pipelines)
+		logger.Infof("Loaded response processors from %s", processorsPath)
+	}
+	if promptPolicyPath := os.Getenv("SYSTEM_PROMPT_POLICY_PATH"); promptPolicyPath != "" {
+		promptPolicy, err := promptpolicy.LoadPolicy(promptPolicyPath)
+		if err != nil {
+			logger.Fatalf("Failed to load system prompt policy: %v", err)
+		}
+		system.SetSystemPromptPolicy(promptPolicy)
+		logger.Infof("Loaded %d system prompt rules from %s", len(promptPolicy.Rules()), promptPolicyPath)
+	}
+	if reasoningModelsPath := os.Getenv("REASONING_MODELS_PATH"); reasoningModelsPath != "" {
+		reasoningModels, err := reasoning.LoadTable(reasoningModelsPath)
+		if err != nil {
+			logger.Fatalf("Failed to load reasoning models: %v", err)
+		}
+		system.SetReasoningModels(reasoningModels)
+		logger.Infof("Loaded reasoning models from %s", reasoningModelsPath)
+	}
+	if detector := newPromptInjectionDetector(logger); detector != nil {
+		system.SetPromptInjectionDetector(detector)
+	}
+	if filter, escalation := newSafetyFilter(logger); filter != nil {
+		system.SetSafetyFilter(filter)
+		system.SetSafetyEscalation(escalation)
+	}
+	if library := newCassetteLibrary(logger); library != nil {
+		system.SetCassettes(library)
+	}
+	if incidentsPath := os.Getenv("INCIDENTS_PATH"); incidentsPath != "" {
+		incidents, err := incident.NewLog(incidentsPath)
+		if err != nil {
+			logger.Fatalf("Failed to load provider incidents: %v", err)
+		}
+		system.SetIncidentLog(incidents)
+	}
+	system.SetCredentials(newCredentialStore(logger), probe.NewProber(durationFromEnv(logger, "CAPABILITY_PROBE_TIMEOUT", 30*time.Second)))
+	if flagsPath := os.Getenv("FEATURE_FLAGS_PATH"); flagsPath != "" {
+		featureFlags, err := flags.NewStore(flagsPath)
+		if err != nil {
+			logger.Fatalf("Failed to load feature flags: %v", err)
+		}
+		system.SetFeatureFlags(featureFlags)
+	}
+	if yamlDir := os.Getenv("PROVIDER_YAML_DIR"); yamlDir != "" {
+		system.SetProviderYAMLDir(yamlDir)
+	}
+	providersCSV := os.Getenv("PROVIDERS_CSV")
+	if providersCSV == "" {
+		providersCSV = "providers.csv"
+	}
+	system.SetProvidersCSVPath(providersCSV)
+	configHistory := newConfigHistory(logger, providersCSV)
+
+	// The assistant model writes the gateway's own analysis, such as insight summaries
+	assistantConfig, err := assistant.ConfigFromEnv()
+	if err != nil {
+		logger.Fatalf("Invalid assistant configuration: %v", err)
+	}
+	endpoints := make([]assistant.Endpoint, 0, len(providers))
+	for _, provider := range providers {
+		endpoints = append(endpoints, assistant.Endpoint{Name: provider.Name, BaseURL: provider.BaseURL, Models: provider.Models})
+	}
+	assistantModel, err := assistant.New(assistantConfig, endpoints)
+	if err != nil {
+		logger.Fatalf("Invalid assistant configuration: %v", err)
+	}
+	logger.Infof("Assistant model: %s", assistant.Describe(assistantModel))
+	analyticsEngine := analytics.NewAnalyticsEngine(logging.Module("analytics"), assistantModel)
+	system.SetAnalytics(analyticsEngine)
+	system.SetRetentionPolicy(retentionPolicy)
+	tenants := newTenantRegistry(logger)
+	system.SetTenants(tenants)
+	reportScheduler := newReportScheduler(logger, analyticsEngine)
+	slaTracker := newSLATracker(logger, analyticsEngine)
 	logger.Info("Enhanced system initialized successfully")
 
+	// Request bodies are bounded and, optionally, unknown JSON fields are rejected
+	maxBodyBytes := int64FromEnv(logger, "MAX_REQUEST_BODY_BYTES", middleware.DefaultMaxBodyBytes)
+	requestLimits := middleware.NewRequestLimits(maxBodyBytes)
+	// Requests are bounded by the client's X-Timeout, at most REQUEST_TIMEOUT
+	requestTimeout := durationFromEnv(logger, "REQUEST_TIMEOUT", 30*time.Second)
+	deadlines := middleware.NewDeadlines(requestTimeout)
+	strictJSON := os.Getenv("STRICT_JSON") == "true"
+
 	// Create HTTP server
 	server := &HTTPServer{
-		system: system,
-		logger: logger,
+		system:       system,
+		logger:       logger,
+		strictJSON:   strictJSON,
+		history:      configHistory,
+		modelAliases: modelAliases,
+		started:      time.Now(),
+	}
+
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	// Events let external systems alert on, bill for and analyse what the gateway does
+	eventBus := newEventBus(logger)
+	system.SetEvents(eventBus)
+
+	// Reports are delivered, and SLA breaches alerted on, by one replica: the
+	// elected leader with LEADER_ELECTION=true, otherwise every replica
+	if slaTracker != nil {
+		slaTracker.SetEvents(eventBus)
+	}
+	startLeaderJobs(backgroundCtx, logger, func(ctx context.Context) {
+		reportScheduler.Start(ctx)
+		if slaTracker != nil {
+			slaTracker.Start(ctx, durationFromEnv(logger, "SLA_CHECK_INTERVAL", sla.DefaultCheckInterval))
+		}
+	})
+	startCapabilityProbes(backgroundCtx, logger, system)
+	system.StartRetentionPurger(backgroundCtx, time.Hour)
+	if objectMirror != nil {
+		objectMirror.StartSync(backgroundCtx, durationFromEnv(logger, "OBJECT_SYNC_INTERVAL", time.Minute), func(err error) {
+			logger.Warnf("Failed to sync object storage: %v", err)
+		})
+	}
+
+	// With LEARNED_STATE_PATH set, provider performance, token calibration and
+	// optimizer statistics are snapshotted there and restored on boot
+	learnedStatePath := os.Getenv("LEARNED_STATE_PATH")
+	if learnedStatePath != "" {
+		if err := system.RestoreLearnedState(learnedStatePath); err != nil {
+			logger.Warnf("Failed to restore learned state, starting fresh: %v", err)
+		}
+		system.StartLearnedStateSnapshots(backgroundCtx, learnedStatePath, durationFromEnv(logger, "LEARNED_STATE_INTERVAL", 5*time.Minute))
+	}
+
+	// With CLUSTER_REDIS_URL set, replicas share request counts, provider metrics,
+	// rate limits and idempotent responses through Redis
+	var sharedState *cluster.RedisState
+	if redisURL := os.Getenv("CLUSTER_REDIS_URL"); redisURL != "" {
+		nodeID := clusterNodeID()
+		state, err := cluster.NewRedisState(redisURL, os.Getenv("CLUSTER_KEY_PREFIX"), nodeID)
+		if err != nil {
+			logger.Fatalf("Failed to initialize cluster state: %v", err)
+		}
+		state.StartHeartbeat(backgroundCtx, 10*time.Second)
+		system.SetSharedState(state)
+		sharedState = state
+		logger.Infof("Cluster mode enabled as node %s", nodeID)
+	}
+
+	// Images and audio of responses are kept by the gateway, which serves them
+	// under signed URLs instead of provider links that expire
+	artifacts := newArtifacts(logger)
+	if artifacts != nil {
+		system.SetArtifacts(artifacts)
+		artifacts.StartJanitor(backgroundCtx, min(artifacts.TTL()/10, time.Hour), func(err error) {
+			logger.Warnf("Failed to clean up artifacts: %v", err)
+		})
+	}
+	server.artifacts = artifacts
+
+	// Idempotency keys let clients safely retry POSTs without duplicate upstream calls
+	idempotencyTTL := durationFromEnv(logger, "IDEMPOTENCY_TTL", 24*time.Hour)
+	var idempotencyStore middleware.IdempotencyStore
+	if sharedState != nil {
+		idempotencyStore = cluster.NewRedisIdempotencyStore(sharedState)
+	} else {
+		memoryStore := middleware.NewMemoryIdempotencyStore()
+		memoryStore.StartJanitor(backgroundCtx, time.Minute)
+		idempotencyStore = memoryStore
+	}
+	idempotency := middleware.NewIdempotency(idempotencyStore, idempotencyTTL, logger)
+	// Keys are reserved only as long as a request can run, then kept for the TTL once answered
+	idempotency.SetLockTTL(durationFromEnv(logger, "IDEMPOTENCY_LOCK_TTL", max(requestTimeout+5*time.Second, middleware.DefaultIdempotencyLockTTL)))
+
+	// Access logs are written as JSON lines, separately from application logs
+	accessLogWriter, closeAccessLog := openAccessLog(logger, retentionPolicy.AccessLog)
+	defer closeAccessLog()
+	redaction, err := middleware.ParsePromptRedaction(os.Getenv("ACCESS_LOG_REDACTION"))
+	if err != nil {
+		logger.Fatalf("Invalid ACCESS_LOG_REDACTION: %v", err)
+	}
+
+	serverConfig := loadServerConfig(logger)
+	cors, browserTokens := newBrowserAccess(logger, serverConfig)
+	server.browserTokens = browserTokens
+	server.cors = cors
+	sso, accessControl := newAdminAccess(logger, serverConfig.Admin)
+	keys := loadAPIKeys(logger)
+	environments := newEnvironments(logger, serverConfig, keys)
+	system.SetEnvironments(environments)
+	if serverConfig.Selection.Weights != nil {
+		system.SetSelectionWeights(selectionWeights(serverConfig.Selection.Weights))
 	}
 
 	// Setup routes
 	router := mux.NewRouter()
+	router.NotFoundHandler = http.HandlerFunc(openAINotFoundHandler)
+	common := []mux.MiddlewareFunc{middleware.RequestID, middleware.ClientKey}
+	if browserTokens != nil {
+		common = append(common, browserTokens.Middleware)
+	}
+	public := []string{"/health", "/healthz", "/readyz", "/openapi.json"}
+	if artifacts != nil {
+		// Artifact URLs carry their own signature
+		public = append(public, artifact.RoutePrefix)
+	}
+	if sso != nil {
+		common = append(common, sso.Middleware)
+		public = append(public, "/admin/sso/login", "/admin/sso/callback")
+	}
+	if keys != nil {
+		common = append(common, middleware.NewAPIKeyAuth(keys, public).Middleware)
+	}
+	common = append(common, tenants.Middleware)
+	if accessLogWriter != nil {
+		accessLog := middleware.NewAccessLog(accessLogWriter, redaction)
+		if scrubber != nil {
+			accessLog.SetScrubber(scrubber.Scrub)
+		}
+		common = append(common, accessLog.Middleware)
+	}
+	router.Use(common...)
 	router.HandleFunc("/health", server.healthHandler).Methods("GET")
-	router.HandleFunc("/api/v1/process", server.processHandler).Methods("POST")
+	router.HandleFunc("/healthz", server.healthHandler).Methods("GET")
+	router.HandleFunc("/readyz", server.readyHandler).Methods("GET")
+	router.Handle("/api/v1/process", server.drainGuard(requestLimits.Middleware(deadlines.Middleware(idempotency.Middleware(http.HandlerFunc(server.processHandler)))))).Methods("POST")
+	router.Handle("/api/v1/batch", server.drainGuard(requestLimits.Middleware(deadlines.Middleware(idempotency.Middleware(http.HandlerFunc(server.batchHandler)))))).Methods("POST")
+	// OpenAI-compatible routes let SDKs and frameworks use the gateway as their base URL
+	router.Handle("/v1/chat/completions", server.drainGuard(requestLimits.Middleware(deadlines.Middleware(idempotency.Middleware(http.HandlerFunc(server.chatCompletionsHandler)))))).Methods("POST")
+	router.HandleFunc("/v1/models", server.listModelsHandler).Methods("GET")
+	router.HandleFunc("/v1/models/{id:.+}", server.getModelHandler).Methods("GET")
+	router.HandleFunc("/api/v1/requests", server.listRequestsHandler).Methods("GET")
 	router.HandleFunc("/api/v1/requests/{id}", server.getRequestHandler).Methods("GET")
+	router.HandleFunc("/api/v1/requests/{id}", server.cancelRequestHandler).Methods("DELETE")
 	router.HandleFunc("/api/v1/providers", server.getProvidersHandler).Methods("GET")
 	router.HandleFunc("/api/v1/providers/{id}/yaml", server.generateProviderYAMLHandler).Methods("GET")
 	router.HandleFunc("/api/v1/providers/yaml/generate-all", server.generateAllYAMLsHandler).Methods("POST")
+	router.HandleFunc("/api/v1/providers/yaml/diff", server.diffYAMLsHandler).Methods("GET")
+	router.HandleFunc("/api/v1/providers/capabilities", server.getCapabilityProbesHandler).Methods("GET")
+	router.HandleFunc("/api/v1/providers/load", server.getProviderLoadHandler).Methods("GET")
+	router.HandleFunc("/api/v1/providers/warmup", server.getProviderWarmupHandler).Methods("GET")
+	router.HandleFunc("/api/v1/providers/throughput", server.getProviderThroughputHandler).Methods("GET")
+	router.HandleFunc("/api/v1/providers/{id}/probe", server.probeProviderHandler).Methods("POST")
 	router.HandleFunc("/api/v1/metrics", server.getMetricsHandler).Methods("GET")
+	router.HandleFunc("/api/v1/artifacts/{key}", server.getArtifactHandler).Methods("GET")
+	router.HandleFunc("/api/v1/speculative/stats", server.getSpeculativeStatsHandler).Methods("GET")
+	router.HandleFunc("/api/v1/safety/escalations", server.getSafetyEscalationsHandler).Methods("GET")
+	if browserTokens != nil {
+		router.HandleFunc("/api/v1/browser-tokens", server.issueBrowserTokenHandler).Methods("POST")
+	}
+
+	// Admin routes move to their own listener or stay on this one behind the
+	// IP allow-list, as the server config file says
+	adminRouter, adminServer, adminAllowList := newAdminRouter(logger, router, serverConfig.Admin, common)
+	adminRouter.Use(middleware.NewAuditTrail(system, logger).Middleware)
+	if accessControl != nil {
+		adminRouter.Use(accessControl.Middleware)
+	}
+	if sso != nil {
+		sso.SetAuditLog(system)
+		sso.RegisterRoutes(adminRouter)
+	}
+	logLevelHandlers := admin.NewLogLevelHandlers(logging.Default, logger)
+	logLevelHandlers.SetChangesAllowed(os.Getenv("ADMIN_LOG_LEVEL") == "true")
+	logLevelHandlers.RegisterRoutes(adminRouter)
+	admin.NewModelAliasHandlers(modelAliases).RegisterRoutes(adminRouter)
+	adminHandlers := admin.NewAdminHandlers(logging.Module("admin"), analyticsEngine)
+	adminHandlers.SetReadiness(system.CheckReadiness)
+	adminHandlers.RegisterRoutes(adminRouter)
+	admin.NewReportHandlers(reportScheduler).RegisterRoutes(adminRouter)
+	if slaTracker != nil {
+		admin.NewSLAHandlers(slaTracker).RegisterRoutes(adminRouter)
+	}
+	admin.NewConsistencyHandlers(system, configHistory).RegisterRoutes(adminRouter)
+	admin.NewConfigHistoryHandlers(configHistory).RegisterRoutes(adminRouter)
+	admin.NewOnboardingHandlers(newOnboardingWizard(logger, configHistory, providersCSV)).RegisterRoutes(adminRouter)
+	admin.NewHistoryEncryptionHandlers(system).RegisterRoutes(adminRouter)
+	admin.NewRetentionHandlers(system).RegisterRoutes(adminRouter)
+	admin.NewLearnedStateHandlers(system).RegisterRoutes(adminRouter)
+	admin.NewAuditHandlers(system).RegisterRoutes(adminRouter)
+	admin.NewSimulationHandlers(system).RegisterRoutes(adminRouter)
+	admin.NewIncidentHandlers(system).RegisterRoutes(adminRouter)
+	admin.NewCredentialHandlers(system).RegisterRoutes(adminRouter)
+	admin.NewFeatureFlagHandlers(system).RegisterRoutes(adminRouter)
+	if os.Getenv("CAPABILITY_PROBE") == "true" {
+		admin.NewCapabilityHandlers(system).RegisterRoutes(adminRouter)
+	}
+	newBundleHandlers(logger, configHistory).RegisterRoutes(adminRouter)
+	// SIGHUP and POST /admin/reload re-read the configuration that can change
+	// without a restart
+	reloader := newReloader(backgroundCtx, logger, system, &liveServerConfig{
+		system:        system,
+		cors:          cors,
+		allowList:     adminAllowList,
+		accessControl: accessControl,
+		running:       serverConfig,
+	})
+	admin.NewReloadHandlers(reloader).RegisterRoutes(adminRouter)
+	if os.Getenv("ADMIN_PPROF") == "true" {
+		admin.NewProfilingHandlers().RegisterRoutes(adminRouter)
+	}
+	tenantHandlers := admin.NewTenantHandlers(tenants, analyticsEngine, tenantAdminKeys())
+	tenantHandlers.SetEvents(eventBus)
+	tenantHandlers.RegisterRoutes(adminRouter)
+	if environments != nil {
+		admin.NewEnvironmentHandlers(environments, analyticsEngine).RegisterRoutes(adminRouter)
+	}
+
+	// The OpenAPI document is checked against the routers so new routes cannot go undocumented
+	spec := buildOpenAPISpec()
+	router.HandleFunc("/openapi.json", openAPIHandler(spec)).Methods("GET")
+	checkOpenAPICoverage(router, spec, logger)
+	if adminServer != nil {
+		// Probes of a separate admin port see the same state as the public ones
+		adminRouter.HandleFunc("/healthz", server.healthHandler).Methods("GET")
+		adminRouter.HandleFunc("/readyz", server.readyHandler).Methods("GET")
+		checkOpenAPICoverage(adminRouter, spec, logger)
+	}
+
+	// LISTEN_ADDR binds the public listener, otherwise every interface on PORT,
+	// 8080 by default
+	addr := os.Getenv("LISTEN_ADDR")
+	if addr == "" {
+		port := os.Getenv("PORT")
+		if port == "" {
+			port = "8080"
+		}
+		addr = ":" + port
+	}
+
+	// Leave time to write the timeout response of requests that use all of
+	// REQUEST_TIMEOUT; without one, responses are not cut off either
+	var writeTimeout time.Duration
+	if requestTimeout > 0 {
+		writeTimeout = requestTimeout + 5*time.Second
+	}
 
-	// Get port from environment variable, default to 8080
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// CORS wraps the router since preflight requests match no route
+	var handler http.Handler = router
+	if cors != nil {
+		handler = cors.Middleware(router)
 	}
-	addr := ":" + port
 
 	// Start server
 	srv := &http.Server{
 		Addr:         addr,
-		Handler:      router,
+		Handler:      handler,
 		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
+		WriteTimeout: writeTimeout,
 	}
 
 	go func() {
@@ -89,6 +608,25 @@ func main() {
 			logger.Fatalf("Server failed to start: %v", err)
 		}
 	}()
+	if adminServer != nil {
+		go func() {
+			var err error
+			if adminServer.TLSConfig != nil {
+				logger.Infof("Starting admin server on %s (TLS, client certificates %t)", adminServer.Addr, adminServer.TLSConfig.ClientCAs != nil)
+				err = adminServer.ListenAndServeTLS("", "")
+			} else {
+				logger.Infof("Starting admin server on %s", adminServer.Addr)
+				err = adminServer.ListenAndServe()
+			}
+			if err != nil && err != http.ErrServerClosed {
+				logger.Fatalf("Admin server failed to start: %v", err)
+			}
+		}()
+	}
+
+	// Startup validation runs while the server is up, so liveness passes and
+	// /readyz reports its progress
+	go runStartup(backgroundCtx, logger, system)
 
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
@@ -96,120 +634,1806 @@ func main() {
 	<-quit
 	logger.Info("Shutting down server...")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	// Readiness fails for SHUTDOWN_DELAY first, while requests are still
+	// served, so load balancers and endpoints stop routing here before new
+	// work is refused; a second signal skips the wait
+	system.BeginTermination()
+	if delay := durationFromEnv(logger, "SHUTDOWN_DELAY", 0); delay > 0 {
+		logger.Infof("Waiting %s for traffic to move to other replicas", delay)
+		select {
+		case <-time.After(delay):
+		case <-quit:
+		}
+	}
+
+	// New work is rejected with 503 while in-flight requests are allowed to finish
+	system.BeginDrain()
+
+	drainTimeout := durationFromEnv(logger, "SHUTDOWN_DRAIN_TIMEOUT", 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
-		logger.Fatalf("Server forced to shutdown: %v", err)
+		logger.Errorf("Server forced to shutdown: %v", err)
+	}
+	if adminServer != nil {
+		if err := adminServer.Shutdown(ctx); err != nil {
+			logger.Errorf("Admin server forced to shutdown: %v", err)
+		}
+	}
+
+	if err := system.Shutdown(ctx); err != nil {
+		logger.Errorf("Enhanced system shutdown incomplete: %v", err)
+	}
+	if learnedStatePath != "" {
+		if err := system.SaveLearnedState(learnedStatePath); err != nil {
+			logger.Errorf("Failed to save learned state: %v", err)
+		}
+	}
+	if objectMirror != nil {
+		if err := objectMirror.Push(ctx); err != nil {
+			logger.Errorf("Failed to push files to object storage: %v", err)
+		}
+	}
+	if err := eventBus.Close(ctx); err != nil {
+		logger.Errorf("Failed to close event publishers: %v", err)
+	}
+
+	if sharedState != nil {
+		if err := sharedState.Close(); err != nil {
+			logger.Errorf("Failed to leave cluster cleanly: %v", err)
+		}
 	}
 
 	logger.Info("Server exited")
 }
 
-// HTTPServer handles HTTP requests
-type HTTPServer struct {
-	system *enhanced.EnhancedSystem
-	logger *logrus.Logger
+// runStartup validates the configuration and providers as STARTUP_MODE says.
+// fail-fast exits on any failure; degraded starts without unreachable
+// providers, except those in STARTUP_CRITICAL_PROVIDERS, and rechecks them
+// every STARTUP_RECHECK_INTERVAL
+func runStartup(ctx context.Context, logger *logrus.Logger, system *enhanced.EnhancedSystem) {
+	mode, err := enhanced.ParseStartupMode(os.Getenv("STARTUP_MODE"))
+	if err != nil {
+		logger.Fatalf("Invalid STARTUP_MODE: %v", err)
+	}
+	var critical []string
+	for _, name := range strings.Split(os.Getenv("STARTUP_CRITICAL_PROVIDERS"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			critical = append(critical, name)
+		}
+	}
+	report, err := system.Startup(ctx, enhanced.StartupOptions{
+		Mode:              mode,
+		CriticalProviders: critical,
+		ProviderTimeout:   durationFromEnv(logger, "STARTUP_PROVIDER_TIMEOUT", enhanced.DefaultProviderCheckTimeout),
+		ProbeMaxAge:       durationFromEnv(logger, "CAPABILITY_PROBE_MAX_AGE", 24*time.Hour),
+	})
+	if err != nil {
+		logger.Fatalf("Startup failed in %s mode: %v", mode, err)
+	}
+	logger.Infof("Startup validation finished: %s", report.Status)
+	if report.Status == enhanced.StartupStatusDegraded {
+		system.StartProviderRecheck(ctx, durationFromEnv(logger, "STARTUP_RECHECK_INTERVAL", time.Minute))
+	}
 }
 
-func (h *HTTPServer) healthHandler(w http.ResponseWriter, r *http.Request) {
-	response := map[string]interface{}{
-		"status":    "healthy",
-		"timestamp": time.Now().Unix(),
-		"version":   "enhanced-2.0.0",
-		"features":  []string{"complexity-analysis", "provider-selection", "prompt-optimization"},
+// startCapabilityProbes enables capability probing when CAPABILITY_PROBE is
+// true. Providers are probed during startup validation and, checked every
+// CAPABILITY_PROBE_CHECK_INTERVAL, again once a feature has not been
+// verified within CAPABILITY_PROBE_MAX_AGE; results are kept in
+// CAPABILITY_PROBE_PATH. Each provider is sent its active key
+func startCapabilityProbes(ctx context.Context, logger *logrus.Logger, system *enhanced.EnhancedSystem) {
+	if os.Getenv("CAPABILITY_PROBE") != "true" {
+		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	store, err := probe.NewStore(os.Getenv("CAPABILITY_PROBE_PATH"))
+	if err != nil {
+		logger.Fatalf("Failed to load capability probes: %v", err)
+	}
+	prober := probe.NewProber(durationFromEnv(logger, "CAPABILITY_PROBE_TIMEOUT", 30*time.Second))
+	maxAge := durationFromEnv(logger, "CAPABILITY_PROBE_MAX_AGE", 24*time.Hour)
+	interval := min(durationFromEnv(logger, "CAPABILITY_PROBE_CHECK_INTERVAL", time.Hour), maxAge)
+	system.SetCapabilityProbe(prober, store, system.ProviderAPIKey)
+	system.StartCapabilityReverification(ctx, maxAge, interval)
+	logger.Infof("Capability probing enabled, re-verifying features older than %s every %s", maxAge, interval)
 }
 
-func (h *HTTPServer) processHandler(w http.ResponseWriter, r *http.Request) {
-	var input enhanced.RequestInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
-		return
+// providerAPIKey reads the API key of a provider from <NAME>_API_KEY, where
+// NAME is the provider name upper-cased with other characters replaced by _
+func providerAPIKey(provider string) string {
+	return os.Getenv(config.ProviderAPIKeyVariable(provider))
+}
+
+// newCredentialStore opens the provider keys staged and promoted through the
+// admin API, saved at PROVIDER_CREDENTIALS_PATH and sealed like the request
+// history when HISTORY_ENCRYPTION is set. Providers without a promoted key
+// use <NAME>_API_KEY
+func newCredentialStore(logger *logrus.Logger) *credentials.Store {
+	path := os.Getenv("PROVIDER_CREDENTIALS_PATH")
+	var sealer *envelope.Sealer
+	if path != "" {
+		sealer = newHistorySealer(logger)
+	}
+	store, err := credentials.NewStore(path, sealer, providerAPIKey)
+	if err != nil {
+		logger.Fatalf("Failed to load provider credentials: %v", err)
 	}
+	if path != "" && sealer == nil {
+		logger.Warnf("Provider keys promoted through the admin API are saved unencrypted in %s; set HISTORY_ENCRYPTION to seal them", path)
+	}
+	return store
+}
+
+// newSLATracker tracks the provider SLA targets of SLA_TARGETS_PATH, keeping
+// monthly scorecards in SLA_SCORECARDS_DIR. It returns nil without targets
+func newSLATracker(logger *logrus.Logger, engine *analytics.AnalyticsEngine) *sla.Tracker {
+	path := os.Getenv("SLA_TARGETS_PATH")
+	if path == "" {
+		return nil
+	}
+	targets, err := sla.LoadTargets(path)
+	if err != nil {
+		logger.Fatalf("Invalid SLA_TARGETS_PATH: %v", err)
+	}
+	tracker, err := sla.NewTracker(targets, engine, os.Getenv("SLA_SCORECARDS_DIR"))
+	if err != nil {
+		logger.Fatalf("Failed to open SLA scorecards: %v", err)
+	}
+	logger.Infof("Tracking SLA targets of %d providers from %s", len(targets.List()), path)
+	return tracker
+}
 
-	h.logger.Infof("Processing request: %s", input.Content)
+// newReportScheduler configures scheduled reports from REPORT_SCHEDULE, where
+// they are kept (REPORTS_DIR) and how they are delivered (REPORT_WEBHOOK_*,
+// REPORT_SMTP_* and REPORT_EMAIL_*)
+// newConfigHistory opens the revision history of the configuration files:
+// providersCSV, the provider YAML directory, the alias and policy files when
+// set, and the comma-separated CONFIG_HISTORY_PATHS. The current files are
+// recorded at startup so edits made while the gateway was down are kept
+func newConfigHistory(logger *logrus.Logger, providersCSV string) *config.ConfigHistory {
+	dir := os.Getenv("CONFIG_HISTORY_DIR")
+	if dir == "" {
+		dir = ".config-history"
+	}
+	yamlDir := os.Getenv("PROVIDER_YAML_DIR")
+	if yamlDir == "" {
+		yamlDir = "configs"
+	}
 
-	// Process request with enhanced system
-	result, err := h.system.ProcessRequest(r.Context(), input)
+	paths := []string{providersCSV, yamlDir}
+	for _, name := range []string{"MODEL_ALIASES_PATH", "VIRTUAL_MODELS_PATH", "ROUTING_POLICIES_PATH", "RESPONSE_PROCESSORS_PATH", "SYSTEM_PROMPT_POLICY_PATH", "PROMPT_INJECTION_POLICY_PATH", "SAFETY_POLICY_PATH", "HOOKS_PATH", "WASM_SCORERS_PATH", "SLA_TARGETS_PATH", "REASONING_MODELS_PATH"} {
+		if path := os.Getenv(name); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	for _, path := range strings.Split(os.Getenv("CONFIG_HISTORY_PATHS"), ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+
+	history, err := config.NewConfigHistory(dir, paths...)
 	if err != nil {
-		h.logger.Errorf("Failed to process request: %v", err)
-		http.Error(w, fmt.Sprintf("Processing failed: %v", err), http.StatusInternalServerError)
-		return
+		logger.Fatalf("Failed to open configuration history: %v", err)
 	}
+	revisions, err := history.Snapshot("system", "startup")
+	if err != nil {
+		logger.Warnf("Failed to record configuration at startup: %v", err)
+	}
+	if len(revisions) > 0 {
+		logger.Infof("Recorded %d changed configuration files in %s", len(revisions), dir)
+	}
+	return history
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+// newOnboardingWizard drafts new providers for providersCSV and the provider
+// YAML directory. Each request it sends a provider times out after
+// ONBOARD_TIMEOUT
+func newOnboardingWizard(logger *logrus.Logger, history *config.ConfigHistory, providersCSV string) *onboard.Wizard {
+	yamlDir := os.Getenv("PROVIDER_YAML_DIR")
+	if yamlDir == "" {
+		yamlDir = "configs"
+	}
+	return onboard.NewWizard(history, providersCSV, yamlDir, durationFromEnv(logger, "ONBOARD_TIMEOUT", 15*time.Second))
 }
 
-func (h *HTTPServer) getRequestHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	requestID := vars["id"]
+// newBundleHandlers enables signed config bundles: export with the private key
+// at BUNDLE_SIGNING_KEY, import of bundles signed by any public key listed in
+// the comma-separated BUNDLE_TRUSTED_KEYS
+// newTenantRegistry loads the tenants saved at TENANTS_PATH, tenants.json by default
+func newTenantRegistry(logger *logrus.Logger) *tenant.Registry {
+	path := os.Getenv("TENANTS_PATH")
+	if path == "" {
+		path = "tenants.json"
+	}
+	registry, err := tenant.NewRegistry(path)
+	if err != nil {
+		logger.Fatalf("Failed to load tenants: %v", err)
+	}
+	if tenants := registry.List(); len(tenants) > 0 {
+		logger.Infof("Loaded %d tenants from %s", len(tenants), path)
+	}
+	return registry
+}
+
+// tenantAdminKeys returns the key IDs in TENANT_ADMIN_KEYS allowed to manage tenants
+func tenantAdminKeys() []string {
+	var keys []string
+	for _, key := range strings.Split(os.Getenv("TENANT_ADMIN_KEYS"), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func newBundleHandlers(logger *logrus.Logger, history *config.ConfigHistory) *admin.BundleHandlers {
+	var signingKey ed25519.PrivateKey
+	if path := os.Getenv("BUNDLE_SIGNING_KEY"); path != "" {
+		key, err := bundle.LoadPrivateKey(path)
+		if err != nil {
+			logger.Fatalf("Invalid BUNDLE_SIGNING_KEY: %v", err)
+		}
+		signingKey = key
+		logger.Infof("Config bundle export enabled, signing key %s", bundle.KeyID(key.Public().(ed25519.PublicKey)))
+	}
+
+	var trusted []ed25519.PublicKey
+	for _, path := range strings.Split(os.Getenv("BUNDLE_TRUSTED_KEYS"), ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		key, err := bundle.LoadPublicKey(path)
+		if err != nil {
+			logger.Fatalf("Invalid BUNDLE_TRUSTED_KEYS: %v", err)
+		}
+		trusted = append(trusted, key)
+	}
+
+	return admin.NewBundleHandlers(history, signingKey, trusted, clusterNodeID())
+}
 
-	// Create a dummy RequestInput for demonstration
-	input := enhanced.RequestInput{
-		Content: fmt.Sprintf("Request ID: %s", requestID),
+// clusterNodeID names this replica: CLUSTER_NODE_ID, or the hostname
+func clusterNodeID() string {
+	if nodeID := os.Getenv("CLUSTER_NODE_ID"); nodeID != "" {
+		return nodeID
 	}
+	hostname, _ := os.Hostname()
+	return hostname
+}
 
-	request, err := h.system.GetRequest(r.Context(), input)
+// newEventBus creates the event bus and its publishers: NATS with
+// EVENTS_NATS_URL and Kafka with EVENTS_KAFKA_BROKERS. EVENTS_TYPES limits
+// the published types. Without publishers it returns nil, which drops events
+func newEventBus(logger *logrus.Logger) *events.Bus {
+	natsURL := os.Getenv("EVENTS_NATS_URL")
+	kafkaBrokers := os.Getenv("EVENTS_KAFKA_BROKERS")
+	if natsURL == "" && kafkaBrokers == "" {
+		return nil
+	}
+	types, err := events.ParseTypes(os.Getenv("EVENTS_TYPES"))
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Request processing failed: %v", err), http.StatusInternalServerError)
-		return
+		logger.Fatalf("Invalid EVENTS_TYPES: %v", err)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(request)
+	bus := events.NewBus(clusterNodeID(), int(int64FromEnv(logger, "EVENTS_BUFFER_SIZE", events.DefaultBufferSize)))
+	bus.SetPublishTimeout(durationFromEnv(logger, "EVENTS_PUBLISH_TIMEOUT", events.DefaultPublishTimeout))
+	if natsURL != "" {
+		publisher, err := events.NewNATSPublisher(natsURL, os.Getenv("EVENTS_NATS_SUBJECT"))
+		if err != nil {
+			logger.Fatalf("Failed to initialize NATS events: %v", err)
+		}
+		bus.AddPublisher(publisher, types...)
+	}
+	if kafkaBrokers != "" {
+		var brokers []string
+		for _, broker := range strings.Split(kafkaBrokers, ",") {
+			if broker = strings.TrimSpace(broker); broker != "" {
+				brokers = append(brokers, broker)
+			}
+		}
+		publisher, err := events.NewKafkaPublisher(brokers, os.Getenv("EVENTS_KAFKA_TOPIC"))
+		if err != nil {
+			logger.Fatalf("Failed to initialize Kafka events: %v", err)
+		}
+		bus.AddPublisher(publisher, types...)
+	}
+	logger.Infof("Publishing events to %s", strings.Join(bus.Publishers(), ", "))
+	return bus
 }
 
-func (h *HTTPServer) getProvidersHandler(w http.ResponseWriter, r *http.Request) {
-	providers := h.system.GetProviders()
+// bootstrapDataDir creates the directories the server writes to and, on a
+// first run, an editable starter providers CSV
+func bootstrapDataDir(logger *logrus.Logger) {
+	layout := bootstrap.Layout{ProvidersCSV: os.Getenv("PROVIDERS_CSV")}
+	if layout.ProvidersCSV == "" {
+		layout.ProvidersCSV = "providers.csv"
+	}
+	for name, defaultDir := range map[string]string{"PROVIDER_YAML_DIR": "configs", "CONFIG_HISTORY_DIR": ".config-history", "REPORTS_DIR": "", "SLA_SCORECARDS_DIR": ""} {
+		if dir := os.Getenv(name); dir != "" {
+			layout.Dirs = append(layout.Dirs, dir)
+		} else if defaultDir != "" {
+			layout.Dirs = append(layout.Dirs, defaultDir)
+		}
+	}
+	for _, name := range []string{"API_KEYS_PATH", "TENANTS_PATH", "LEARNED_STATE_PATH", "CAPABILITY_PROBE_PATH", "INCIDENTS_PATH", "PROVIDER_CREDENTIALS_PATH", "FEATURE_FLAGS_PATH", "METRICS_DB_PATH", "ACCESS_LOG_PATH"} {
+		if path := os.Getenv(name); path != "" && path != "stdout" && path != "off" {
+			layout.Files = append(layout.Files, path)
+		}
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(providers)
+	written, err := bootstrap.Prepare(layout)
+	if err != nil {
+		logger.Fatalf("Failed to prepare the data directory: %v", err)
+	}
+	for _, path := range written {
+		logger.Infof("First run: wrote starter %s, edit it to add providers", path)
+	}
 }
 
-func (h *HTTPServer) getMetricsHandler(w http.ResponseWriter, r *http.Request) {
-	// Return dummy metrics for now
-	metrics := map[string]interface{}{
-		"total_requests":     100,
-		"successful_requests": 95,
-		"failed_requests":    5,
-		"average_latency":    "150ms",
-		"providers_active":   len(h.system.GetProviders()),
+// Environment variables naming files and directories that may be object
+// storage URLs
+var (
+	objectFileVariables = []string{
+		"PROVIDERS_CSV", "SERVER_CONFIG_PATH", "API_KEYS_PATH", "TENANTS_PATH",
+		"MODEL_ALIASES_PATH", "VIRTUAL_MODELS_PATH", "ROUTING_POLICIES_PATH",
+		"HOOKS_PATH", "WASM_SCORERS_PATH", "RESPONSE_PROCESSORS_PATH",
+		"SYSTEM_PROMPT_POLICY_PATH", "SECRET_PATTERNS_PATH", "LEARNED_STATE_PATH",
+		"CAPABILITY_PROBE_PATH", "PROMPT_INJECTION_POLICY_PATH", "SAFETY_POLICY_PATH",
+		"SLA_TARGETS_PATH", "INCIDENTS_PATH", "PROVIDER_CREDENTIALS_PATH", "FEATURE_FLAGS_PATH",
+		"REASONING_MODELS_PATH",
 	}
+	objectDirVariables = []string{"PROVIDER_YAML_DIR", "CONFIG_HISTORY_DIR", "REPORTS_DIR", "SLA_SCORECARDS_DIR"}
+)
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(metrics)
+// newObjectMirror pulls the files and directories whose variables are s3://
+// or gs:// URLs into OBJECT_CACHE_DIR and points the variables at the local
+// copies, which the rest of the server reads and writes like any file. It
+// returns nil when no variable is a URL. Pulls that fail fall back to the
+// copies cached by earlier runs, if any
+func newObjectMirror(logger *logrus.Logger) *objstore.Mirror {
+	cacheDir := os.Getenv("OBJECT_CACHE_DIR")
+	if cacheDir == "" {
+		cacheDir = filepath.Join(os.TempDir(), "pal-moe-objects")
+	}
+	var mirror *objstore.Mirror
+	buckets := make(map[string]objstore.Bucket)
+	add := func(name string, dir bool) {
+		value := os.Getenv(name)
+		if !objstore.IsURL(value) {
+			return
+		}
+		location, err := objstore.ParseLocation(value)
+		if err != nil {
+			logger.Fatalf("Invalid %s: %v", name, err)
+		}
+		if mirror == nil {
+			if mirror, err = objstore.NewMirror(cacheDir); err != nil {
+				logger.Fatalf("Failed to initialize object cache: %v", err)
+			}
+		}
+		bucketURL := location.Scheme + "://" + location.Bucket
+		bucket, ok := buckets[bucketURL]
+		if !ok {
+			if bucket, err = newObjectBucket(location); err != nil {
+				logger.Fatalf("Failed to initialize object store for %s: %v", name, err)
+			}
+			buckets[bucketURL] = bucket
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		var local string
+		if dir {
+			local, err = mirror.AddDir(ctx, bucket, location)
+		} else {
+			local, err = mirror.AddFile(ctx, bucket, location)
+		}
+		if err != nil {
+			if _, statErr := os.Stat(local); statErr != nil {
+				logger.Fatalf("Failed to read %s: %v", name, err)
+			}
+			logger.Warnf("Using cached copy of %s: %v", name, err)
+		}
+		os.Setenv(name, local)
+		logger.Infof("Mirroring %s to %s", value, local)
+	}
+	for _, name := range objectFileVariables {
+		add(name, false)
+	}
+	for _, name := range objectDirVariables {
+		add(name, true)
+	}
+	return mirror
 }
 
-func (h *HTTPServer) generateProviderYAMLHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	providerID := vars["id"]
+// newObjectBucket creates the bucket of location: S3 with the AWS_*
+// credentials and S3_ENDPOINT, Google Cloud Storage with GCS_HMAC_* keys or the
+// workload's service account
+func newObjectBucket(location objstore.Location) (objstore.Bucket, error) {
+	if location.Scheme == "gs" {
+		return objstore.NewGCS(location.Bucket, os.Getenv("GCS_HMAC_ACCESS_KEY"), os.Getenv("GCS_HMAC_SECRET"))
+	}
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint := os.Getenv("S3_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	return objstore.NewS3(objstore.S3Config{
+		Endpoint: endpoint,
+		Bucket:   location.Bucket,
+		Credentials: objstore.Credentials{
+			AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+			Region:       region,
+		},
+	})
+}
 
-	yaml, err := h.system.GenerateProviderYAML(providerID)
+// newArtifacts creates the artifact manager ARTIFACT_STORE selects: "disk"
+// keeps artifacts in ARTIFACT_DIR, "s3" in the ARTIFACT_S3_BUCKET of an
+// S3-compatible store. Unset, it returns nil and responses keep provider links
+func newArtifacts(logger *logrus.Logger) *artifact.Manager {
+	var store artifact.Store
+	var err error
+	switch kind := os.Getenv("ARTIFACT_STORE"); kind {
+	case "":
+		return nil
+	case "disk":
+		dir := os.Getenv("ARTIFACT_DIR")
+		if dir == "" {
+			dir = "artifacts"
+		}
+		store, err = artifact.NewDiskStore(dir)
+	case "s3":
+		store, err = artifact.NewS3Store(artifact.S3Config{
+			Endpoint:  os.Getenv("ARTIFACT_S3_ENDPOINT"),
+			Region:    os.Getenv("ARTIFACT_S3_REGION"),
+			Bucket:    os.Getenv("ARTIFACT_S3_BUCKET"),
+			Prefix:    os.Getenv("ARTIFACT_S3_PREFIX"),
+			AccessKey: os.Getenv("ARTIFACT_S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("ARTIFACT_S3_SECRET_KEY"),
+		})
+	default:
+		logger.Fatalf("Invalid ARTIFACT_STORE %q: must be disk or s3", kind)
+	}
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to generate YAML: %v", err), http.StatusInternalServerError)
-		return
+		logger.Fatalf("Failed to initialize artifact store: %v", err)
 	}
 
-	w.Header().Set("Content-Type", "application/x-yaml")
-	w.Write([]byte(yaml))
+	// Replicas must share the key for URLs signed by one to open on another
+	secret := []byte(os.Getenv("ARTIFACT_SIGNING_KEY"))
+	if len(secret) == 0 {
+		logger.Fatal("ARTIFACT_SIGNING_KEY is required with ARTIFACT_STORE")
+	}
+	manager := artifact.NewManager(store, artifact.Config{
+		Secret:       secret,
+		TTL:          durationFromEnv(logger, "ARTIFACT_TTL", artifact.DefaultTTL),
+		BaseURL:      os.Getenv("ARTIFACT_BASE_URL"),
+		MaxSize:      int64FromEnv(logger, "ARTIFACT_MAX_BYTES", artifact.DefaultMaxSize),
+		AllowPrivate: os.Getenv("ARTIFACT_ALLOW_PRIVATE") == "true",
+	})
+	logger.Infof("Storing response artifacts in %s for %s", os.Getenv("ARTIFACT_STORE"), manager.TTL())
+	return manager
 }
 
-func (h *HTTPServer) generateAllYAMLsHandler(w http.ResponseWriter, r *http.Request) {
-	yaml, err := h.system.GenerateAllProviderYAMLs()
+func newReportScheduler(logger *logrus.Logger, engine *analytics.AnalyticsEngine) *reporting.Scheduler {
+	periods, err := reporting.ParsePeriods(os.Getenv("REPORT_SCHEDULE"))
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to generate YAMLs: %v", err), http.StatusInternalServerError)
-		return
+		logger.Fatalf("Invalid REPORT_SCHEDULE: %v", err)
+	}
+	store, err := reporting.NewStore(os.Getenv("REPORTS_DIR"))
+	if err != nil {
+		logger.Fatalf("Failed to open report store: %v", err)
 	}
+	scheduler := reporting.NewScheduler(engine, store, periods...)
 
-	response := map[string]interface{}{
+	if webhookURL := os.Getenv("REPORT_WEBHOOK_URL"); webhookURL != "" {
+		format, err := reporting.ParseFormat(os.Getenv("REPORT_WEBHOOK_FORMAT"))
+		if err != nil {
+			logger.Fatalf("Invalid REPORT_WEBHOOK_FORMAT: %v", err)
+		}
+		scheduler.AddDelivery(reporting.NewWebhookDelivery(webhookURL, format))
+	}
+	if smtpAddr := os.Getenv("REPORT_SMTP_ADDR"); smtpAddr != "" {
+		var recipients []string
+		for _, recipient := range strings.Split(os.Getenv("REPORT_EMAIL_TO"), ",") {
+			if recipient = strings.TrimSpace(recipient); recipient != "" {
+				recipients = append(recipients, recipient)
+			}
+		}
+		from := os.Getenv("REPORT_EMAIL_FROM")
+		if from == "" || len(recipients) == 0 {
+			logger.Fatalf("REPORT_SMTP_ADDR requires REPORT_EMAIL_FROM and REPORT_EMAIL_TO")
+		}
+		scheduler.AddDelivery(reporting.NewSMTPDelivery(smtpAddr,
+			os.Getenv("REPORT_SMTP_USERNAME"), os.Getenv("REPORT_SMTP_PASSWORD"), from, recipients))
+	}
+
+	if len(periods) > 0 {
+		logger.Infof("Scheduled reports: %v", periods)
+	}
+	return scheduler
+}
+
+// loadServerConfig reads the server config file named by SERVER_CONFIG_PATH,
+// or the YAML of SERVER_CONFIG, and applies the environment overrides of
+// config.ServerConfig.ApplyEnv; without any, every setting keeps its default
+func loadServerConfig(logger *logrus.Logger) *config.ServerConfig {
+	serverConfig, source, err := readServerConfig()
+	if err != nil {
+		logger.Fatalf("Failed to load server config: %v", err)
+	}
+	if source != "" {
+		logger.Infof("Loaded server config from %s", source)
+	}
+	return serverConfig
+}
+
+// readServerConfig reads and validates the server config as loadServerConfig
+// describes, returning where it came from
+func readServerConfig() (*config.ServerConfig, string, error) {
+	serverConfig := &config.ServerConfig{}
+	source := ""
+	var err error
+	if path := os.Getenv("SERVER_CONFIG_PATH"); path != "" {
+		source = path
+		serverConfig, err = config.LoadServerConfig(path)
+	} else if inline := os.Getenv("SERVER_CONFIG"); inline != "" {
+		source = "SERVER_CONFIG"
+		serverConfig, err = config.ParseServerConfig([]byte(inline), source)
+	}
+	if err != nil {
+		return nil, source, err
+	}
+
+	if err := serverConfig.ApplyEnv(os.LookupEnv); err != nil {
+		return nil, source, fmt.Errorf("invalid server config environment: %w", err)
+	}
+	if err := serverConfig.Validate(); err != nil {
+		return nil, source, fmt.Errorf("invalid server config: %w", err)
+	}
+	return serverConfig, source, nil
+}
+
+// selectionWeights converts configured weights, which Validate has checked
+func selectionWeights(cfg *config.WeightsConfig) selection.SelectionWeights {
+	if cfg == nil {
+		return selection.DefaultSelectionWeights
+	}
+	weights, _ := selection.SelectionWeights{Cost: cfg.Cost, Quality: cfg.Quality, Latency: cfg.Latency, Reliability: cfg.Reliability}.Normalized()
+	return weights
+}
+
+// liveServerConfig is what a reloaded server config can change while the
+// server runs. The rest, such as listen addresses, is reported as needing a
+// restart, and so are settings whose middleware was not set up at startup
+type liveServerConfig struct {
+	system        *enhanced.EnhancedSystem
+	cors          *middleware.CORS
+	allowList     *middleware.IPAllowList
+	accessControl *admin.AccessControl
+	// running holds the settings in effect
+	running *config.ServerConfig
+}
+
+// prepare reads the server config again and compares it with the running one
+func (l *liveServerConfig) prepare(ctx context.Context) (*reload.Change, error) {
+	next, _, err := readServerConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	change := &reload.Change{}
+	var apply []func()
+	restart := func(name string, before, after interface{}) {
+		if !reflect.DeepEqual(before, after) {
+			change.Changed = append(change.Changed, name)
+			change.Restart = append(change.Restart, name)
+		}
+	}
+
+	if !reflect.DeepEqual(l.running.CORS, next.CORS) {
+		change.Changed = append(change.Changed, "cors")
+		if l.cors == nil || len(next.CORS) == 0 {
+			change.Restart = append(change.Restart, "cors")
+		} else {
+			cors, err := middleware.NewCORS(next.CORS)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cors configuration: %w", err)
+			}
+			apply = append(apply, func() {
+				l.cors.Replace(cors)
+				l.running.CORS = next.CORS
+			})
+		}
+	}
+	if !reflect.DeepEqual(l.running.Admin.AllowedIPs, next.Admin.AllowedIPs) {
+		change.Changed = append(change.Changed, "admin.allowed_ips")
+		if l.allowList == nil || len(next.Admin.AllowedIPs) == 0 {
+			change.Restart = append(change.Restart, "admin.allowed_ips")
+		} else {
+			allowList, err := middleware.NewIPAllowList(next.Admin.AllowedIPs)
+			if err != nil {
+				return nil, fmt.Errorf("invalid admin.allowed_ips: %w", err)
+			}
+			apply = append(apply, func() {
+				l.allowList.Replace(allowList)
+				l.running.Admin.AllowedIPs = next.Admin.AllowedIPs
+			})
+		}
+	}
+	if !reflect.DeepEqual(l.running.Admin.Keys, next.Admin.Keys) {
+		change.Changed = append(change.Changed, "admin.keys")
+		if l.accessControl == nil {
+			change.Restart = append(change.Restart, "admin.keys")
+		} else {
+			accessControl, err := admin.NewAccessControl(next.Admin.Keys)
+			if err != nil {
+				return nil, fmt.Errorf("invalid admin.keys: %w", err)
+			}
+			apply = append(apply, func() {
+				l.accessControl.Replace(accessControl)
+				l.running.Admin.Keys = next.Admin.Keys
+			})
+		}
+	}
+	if !reflect.DeepEqual(l.running.Selection, next.Selection) {
+		change.Changed = append(change.Changed, "selection.weights")
+		apply = append(apply, func() {
+			l.system.SetSelectionWeights(selectionWeights(next.Selection.Weights))
+			l.running.Selection = next.Selection
+		})
+	}
+	restart("admin.listen", l.running.Admin.Listen, next.Admin.Listen)
+	restart("admin.tls", l.running.Admin.TLS, next.Admin.TLS)
+	restart("admin.oidc", l.running.Admin.OIDC, next.Admin.OIDC)
+	restart("admin.insecure", l.running.Admin.Insecure, next.Admin.Insecure)
+	restart("browser_tokens", l.running.BrowserTokens, next.BrowserTokens)
+	restart("environments", l.running.Environments, next.Environments)
+
+	if len(change.Changed) == 0 {
+		return nil, nil
+	}
+	change.Apply = func() {
+		for _, f := range apply {
+			f()
+		}
+	}
+	return change, nil
+}
+
+// newReloader reloads the server config, the routing policies and the
+// virtual models on SIGHUP and POST /admin/reload
+func newReloader(ctx context.Context, logger *logrus.Logger, system *enhanced.EnhancedSystem, live *liveServerConfig) *reload.Reloader {
+	reloader := reload.NewReloader()
+	reloader.Add("server_config", live.prepare)
+	if path := os.Getenv("ROUTING_POLICIES_PATH"); path != "" {
+		reloader.Add("routing_policies", reload.FileSection(reload.NewFile(path), selection.LoadRoutingPolicies, system.SetRoutingPolicies))
+	}
+	if path := os.Getenv("VIRTUAL_MODELS_PATH"); path != "" {
+		reloader.Add("virtual_models", reload.FileSection(reload.NewFile(path), selection.LoadVirtualModels, system.SetVirtualModels))
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				signal.Stop(hup)
+				return
+			case <-hup:
+				logger.Info("Reloading configuration on SIGHUP")
+				reloader.Reload(ctx, "signal")
+			}
+		}
+	}()
+	return reloader
+}
+
+// startLeaderJobs runs jobs that must not run on every replica. With
+// LEADER_ELECTION=true replicas elect one to run them through the Kubernetes
+// Lease LEADER_ELECTION_LEASE (pal-moe-leader) in LEADER_ELECTION_NAMESPACE
+// (the pod's namespace), each candidate named by its cluster node ID; jobs
+// are stopped when leadership is lost. Otherwise they run right away
+func startLeaderJobs(ctx context.Context, logger *logrus.Logger, jobs func(ctx context.Context)) {
+	if os.Getenv("LEADER_ELECTION") != "true" {
+		jobs(ctx)
+		return
+	}
+	api, namespace, err := kube.NewClient("", os.Getenv("LEADER_ELECTION_NAMESPACE"))
+	if err != nil {
+		logger.Fatalf("Failed to set up leader election: %v", err)
+	}
+	name := os.Getenv("LEADER_ELECTION_LEASE")
+	if name == "" {
+		name = "pal-moe-leader"
+	}
+	elector := leader.NewElector(api, leader.Config{
+		Namespace:     namespace,
+		Name:          name,
+		Identity:      clusterNodeID(),
+		LeaseDuration: durationFromEnv(logger, "LEADER_ELECTION_LEASE_DURATION", leader.DefaultLeaseDuration),
+	})
+	logger.Infof("Leader election enabled through lease %s/%s", namespace, name)
+	go elector.Run(ctx, jobs)
+}
+
+// loadAPIKeys reads the hashed API keys named by API_KEYS_PATH; without them
+// keys only identify callers and are not checked
+func loadAPIKeys(logger *logrus.Logger) *apikey.Store {
+	path := os.Getenv("API_KEYS_PATH")
+	if path == "" {
+		return nil
+	}
+	keys, err := apikey.LoadStore(path)
+	if err != nil {
+		logger.Fatalf("Failed to load API keys: %v", err)
+	}
+	logger.Infof("Loaded %d API keys from %s", keys.Len(), path)
+	if keys.Plaintext() > 0 {
+		logger.Warnf("%d API keys in %s are stored in plaintext; hash them with `api-keys migrate -file %s`", keys.Plaintext(), path, path)
+	}
+	return keys
+}
+
+// newEnvironments sets up the routing profiles of key environments from the
+// server config; nil when there are none. Keys of environments without a
+// profile are refused service, so each is reported here
+func newEnvironments(logger *logrus.Logger, cfg *config.ServerConfig, keys *apikey.Store) *environment.Profiles {
+	if len(cfg.Environments) == 0 {
+		if keys != nil && len(keys.Environments()) > 0 {
+			logger.Warnf("API keys name environments %v but the server config defines no environment profiles; they are routed like other keys", keys.Environments())
+		}
+		return nil
+	}
+	profiles, err := environment.NewProfiles(cfg.Environments)
+	if err != nil {
+		logger.Fatalf("Invalid environments: %v", err)
+	}
+	if keys != nil {
+		for _, name := range keys.Environments() {
+			if _, err := profiles.Get(name); err != nil {
+				logger.Warnf("API keys of environment %s have no profile; their requests will be refused", name)
+			}
+		}
+	}
+	logger.Infof("Environment routing profiles: %v", profiles.Names())
+	return profiles
+}
+
+// newBrowserAccess sets up CORS and browser tokens from the server config;
+// each is nil when not configured
+func newBrowserAccess(logger *logrus.Logger, cfg *config.ServerConfig) (*middleware.CORS, *middleware.BrowserTokens) {
+	if len(cfg.CORS) == 0 {
+		return nil, nil
+	}
+	cors, err := middleware.NewCORS(cfg.CORS)
+	if err != nil {
+		logger.Fatalf("Invalid cors configuration: %v", err)
+	}
+	if cfg.BrowserTokens.Secret == "" {
+		return cors, nil
+	}
+	browserTokens, err := middleware.NewBrowserTokens(cfg.BrowserTokens.Secret, cfg.BrowserTokens.TTL, cfg.BrowserTokens.Paths)
+	if err != nil {
+		logger.Fatalf("Invalid browser_tokens configuration: %v", err)
+	}
+	logger.Infof("Browser tokens enabled, valid for at most %s", browserTokens.MaxTTL())
+	return cors, browserTokens
+}
+
+// newAdminAccess sets up roles on the admin routes from the server config:
+// SSO sign-in for operators when admin.oidc is set, and roles for the API
+// keys in admin.keys. Without either the admin routes refuse every caller,
+// unless admin.insecure opens them and both are nil
+func newAdminAccess(logger *logrus.Logger, cfg config.AdminConfig) (*admin.SSO, *admin.AccessControl) {
+	if cfg.Insecure {
+		logger.Warn("Admin routes are open to every caller (admin.insecure); use this for local development only")
+		return nil, nil
+	}
+	accessControl, err := admin.NewAccessControl(cfg.Keys)
+	if err != nil {
+		logger.Fatalf("Invalid admin.keys: %v", err)
+	}
+	if cfg.OIDC == nil {
+		if len(cfg.Keys) == 0 {
+			logger.Warn("Admin routes refuse every request: set admin.keys or admin.oidc to reach them")
+		} else {
+			logger.Infof("Admin routes need one of %d admin API keys", len(cfg.Keys))
+		}
+		return nil, accessControl
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	provider, err := oidc.NewProvider(ctx, oidc.Config{
+		Issuer:       cfg.OIDC.Issuer,
+		ClientID:     cfg.OIDC.ClientID,
+		ClientSecret: cfg.OIDC.ClientSecret,
+		RedirectURL:  cfg.OIDC.RedirectURL,
+		Scopes:       cfg.OIDC.Scopes,
+		GroupsClaim:  cfg.OIDC.GroupsClaim,
+	})
+	if err != nil {
+		logger.Fatalf("Failed to set up admin SSO: %v", err)
+	}
+	sso, err := admin.NewSSO(provider, cfg.OIDC.SessionSecret, cfg.OIDC.SessionTTL, cfg.OIDC.Roles, cfg.OIDC.RedirectURL)
+	if err != nil {
+		logger.Fatalf("Invalid admin.oidc: %v", err)
+	}
+	logger.Infof("Admin SSO enabled through %s", cfg.OIDC.Issuer)
+	return sso, accessControl
+}
+
+// newAdminRouter returns the router the /admin routes are registered on. With
+// admin.listen set it is a router of its own, served by the returned server
+// over TLS when admin.tls is set; otherwise it is part of router, and nil is
+// returned for the server. admin.allowed_ips guards it in both cases, through
+// the returned allow-list
+func newAdminRouter(logger *logrus.Logger, router *mux.Router, cfg config.AdminConfig, common []mux.MiddlewareFunc) (*mux.Router, *http.Server, *middleware.IPAllowList) {
+	var allowList *middleware.IPAllowList
+	if len(cfg.AllowedIPs) > 0 {
+		var err error
+		if allowList, err = middleware.NewIPAllowList(cfg.AllowedIPs); err != nil {
+			logger.Fatalf("Invalid admin.allowed_ips: %v", err)
+		}
+	}
+
+	if cfg.Listen == "" {
+		if allowList == nil {
+			return router, nil, nil
+		}
+		adminRouter := router.MatcherFunc(func(r *http.Request, _ *mux.RouteMatch) bool {
+			return r.URL.Path == "/admin" || strings.HasPrefix(r.URL.Path, "/admin/")
+		}).Subrouter()
+		adminRouter.Use(allowList.Middleware)
+		return adminRouter, nil, allowList
+	}
+
+	adminRouter := mux.NewRouter()
+	adminRouter.Use(common...)
+	if allowList != nil {
+		adminRouter.Use(allowList.Middleware)
+	}
+	adminServer := &http.Server{
+		Addr:        cfg.Listen,
+		Handler:     adminRouter,
+		ReadTimeout: 30 * time.Second,
+	}
+	if cfg.TLS != nil {
+		tlsConfig, err := cfg.TLS.ServerTLS()
+		if err != nil {
+			logger.Fatalf("Invalid admin TLS configuration: %v", err)
+		}
+		adminServer.TLSConfig = tlsConfig
+	}
+	return adminRouter, adminServer, allowList
+}
+
+// openAccessLog opens the access log destination named by ACCESS_LOG_PATH: a file
+// path, "stdout" (the default) or "off". Files are rotated by size and reopened on SIGHUP;
+// rotated files older than maxAge, when set, are removed hourly
+func openAccessLog(logger *logrus.Logger, maxAge time.Duration) (io.Writer, func()) {
+	path := os.Getenv("ACCESS_LOG_PATH")
+	switch path {
+	case "off":
+		return nil, func() {}
+	case "", "stdout":
+		return os.Stdout, func() {}
+	}
+
+	maxBytes := int64FromEnv(logger, "ACCESS_LOG_MAX_BYTES", 100<<20)
+	maxBackups := int64FromEnv(logger, "ACCESS_LOG_MAX_BACKUPS", 5)
+	file, err := logging.NewRotatingFile(path, maxBytes, int(maxBackups))
+	if err != nil {
+		logger.Fatalf("Failed to open access log: %v", err)
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := file.Reopen(); err != nil {
+				logger.Errorf("Failed to reopen access log: %v", err)
+			}
+		}
+	}()
+
+	stopPurge := make(chan struct{})
+	if maxAge > 0 {
+		file.SetMaxAge(maxAge)
+		go func() {
+			ticker := time.NewTicker(time.Hour)
+			defer ticker.Stop()
+			for {
+				if n := file.Purge(); n > 0 {
+					logger.Infof("Removed %d access log files older than %s", n, maxAge)
+				}
+				select {
+				case <-stopPurge:
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+
+	return file, func() {
+		signal.Stop(hup)
+		close(stopPurge)
+		file.Close()
+	}
+}
+
+// newScrubber creates the secret scrubber with the default patterns and any
+// listed in SECRET_PATTERNS_PATH; nil when SCRUB_SECRETS is false
+func newScrubber(logger *logrus.Logger) *scrub.Scrubber {
+	if os.Getenv("SCRUB_SECRETS") == "false" {
+		logger.Warn("Secret scrubbing is disabled; prompts are logged and stored as received")
+		return nil
+	}
+	extra := secretPatterns(logger)
+	scrubber, err := scrub.New(extra)
+	if err != nil {
+		logger.Fatalf("Invalid secret pattern: %v", err)
+	}
+	if len(extra) > 0 {
+		logger.Infof("Loaded %d secret patterns from %s", len(extra), os.Getenv("SECRET_PATTERNS_PATH"))
+	}
+	return scrubber
+}
+
+// secretPatterns loads the secret patterns listed in SECRET_PATTERNS_PATH
+func secretPatterns(logger *logrus.Logger) []scrub.Pattern {
+	path := os.Getenv("SECRET_PATTERNS_PATH")
+	if path == "" {
+		return nil
+	}
+	patterns, err := scrub.LoadPatterns(path)
+	if err != nil {
+		logger.Fatalf("Failed to load secret patterns: %v", err)
+	}
+	return patterns
+}
+
+// newCassetteLibrary opens the provider call cassettes in
+// PROVIDER_CASSETTE_DIR in PROVIDER_CASSETTE_MODE; nil when no directory is
+// set. Cassettes are always scrubbed, with the same patterns as the logs
+func newCassetteLibrary(logger *logrus.Logger) *cassette.Library {
+	dir := os.Getenv("PROVIDER_CASSETTE_DIR")
+	if dir == "" {
+		return nil
+	}
+	mode, err := cassette.ParseMode(os.Getenv("PROVIDER_CASSETTE_MODE"))
+	if err != nil {
+		logger.Fatalf("Invalid PROVIDER_CASSETTE_MODE: %v", err)
+	}
+	scrubber, err := scrub.New(secretPatterns(logger))
+	if err != nil {
+		logger.Fatalf("Invalid secret pattern: %v", err)
+	}
+	library, err := cassette.NewLibrary(dir, mode, scrubber)
+	if err != nil {
+		logger.Fatalf("Failed to open provider cassettes: %v", err)
+	}
+	return library
+}
+
+// newPromptInjectionDetector builds prompt injection detection from the
+// policy at PROMPT_INJECTION_POLICY_PATH, whose default action
+// PROMPT_INJECTION overrides. It returns nil when neither is set
+func newPromptInjectionDetector(logger *logrus.Logger) *injection.Detector {
+	path, actionName := os.Getenv("PROMPT_INJECTION_POLICY_PATH"), os.Getenv("PROMPT_INJECTION")
+	if path == "" && actionName == "" {
+		return nil
+	}
+	policy := &injection.Policy{}
+	if path != "" {
+		var err error
+		if policy, err = injection.LoadPolicy(path); err != nil {
+			logger.Fatalf("Failed to load prompt injection policy: %v", err)
+		}
+	}
+	if actionName != "" {
+		action, err := injection.ParseAction(actionName)
+		if err != nil {
+			logger.Fatalf("Invalid PROMPT_INJECTION: %v", err)
+		}
+		policy.Action = action
+	}
+
+	var classifier injection.Classifier
+	if cfg := policy.Classifier; cfg != nil {
+		apiKey, err := config.ExpandEnv(cfg.APIKey)
+		if err != nil {
+			logger.Fatalf("Invalid prompt injection classifier API key: %v", err)
+		}
+		classifier = injection.NewModelClassifier(assistant.NewOpenAIClient("prompt-injection", cfg.BaseURL, cfg.Model, apiKey, cfg.Timeout))
+	}
+	detector, err := injection.NewDetector(*policy, classifier)
+	if err != nil {
+		logger.Fatalf("Invalid prompt injection policy: %v", err)
+	}
+	logger.Infof("Prompt injection detection enabled (default action %s, %d key policies, classifier %t)",
+		detector.ActionFor(""), len(policy.Keys), classifier != nil)
+	return detector
+}
+
+// newSafetyFilter builds response safety classification from the policy at
+// SAFETY_POLICY_PATH, or with the default thresholds when SAFETY_FILTER is
+// true, and the escalation of blocked responses the policy or
+// SAFETY_ESCALATION=true enables. It returns nil when neither is set
+func newSafetyFilter(logger *logrus.Logger) (*safety.Filter, *safety.EscalationPolicy) {
+	path := os.Getenv("SAFETY_POLICY_PATH")
+	if path == "" && os.Getenv("SAFETY_FILTER") != "true" {
+		return nil, nil
+	}
+	policy := &safety.Policy{}
+	if path != "" {
+		var err error
+		if policy, err = safety.LoadPolicy(path); err != nil {
+			logger.Fatalf("Failed to load safety policy: %v", err)
+		}
+	}
+
+	var classifier safety.Classifier
+	if cfg := policy.Classifier; cfg != nil {
+		apiKey, err := config.ExpandEnv(cfg.APIKey)
+		if err != nil {
+			logger.Fatalf("Invalid safety classifier API key: %v", err)
+		}
+		categories := append(append([]string(nil), safety.Categories...), policy.Categories...)
+		classifier = safety.NewModelClassifier(assistant.NewOpenAIClient("safety", cfg.BaseURL, cfg.Model, apiKey, cfg.Timeout), categories)
+	}
+	filter, err := safety.NewFilter(*policy, classifier)
+	if err != nil {
+		logger.Fatalf("Invalid safety policy: %v", err)
+	}
+	logger.Infof("Safety filter enabled (%d categories, %d tenant policies, classifier %t)",
+		len(filter.Categories()), len(policy.Tenants), classifier != nil)
+
+	escalation := policy.Escalation
+	if escalation == nil && os.Getenv("SAFETY_ESCALATION") == "true" {
+		escalation = &safety.EscalationPolicy{}
+	}
+	if escalation != nil {
+		if err := escalation.Validate(); err != nil {
+			logger.Fatalf("Invalid safety escalation policy: %v", err)
+		}
+		logger.Infof("Escalating blocked responses of %v providers to the official tier", escalation.Tiers)
+	}
+	return filter, escalation
+}
+
+// newHistorySealer builds the encryption of the stored request history from
+// HISTORY_ENCRYPTION: "local" wraps data keys with the keyring at
+// HISTORY_KEYRING_PATH, "vault" with Vault's transit engine. It returns nil
+// when history encryption is off
+func newHistorySealer(logger *logrus.Logger) *envelope.Sealer {
+	switch mode := os.Getenv("HISTORY_ENCRYPTION"); mode {
+	case "":
+		return nil
+	case "local":
+		keyringPath := os.Getenv("HISTORY_KEYRING_PATH")
+		if keyringPath == "" {
+			logger.Fatal("HISTORY_ENCRYPTION=local needs HISTORY_KEYRING_PATH")
+		}
+		keyring, err := envelope.LoadKeyring(keyringPath)
+		if err != nil {
+			logger.Fatalf("Failed to load history keyring: %v", err)
+		}
+		logger.Infof("Encrypting request history with the keyring at %s", keyringPath)
+		return envelope.NewSealer(keyring)
+	case "vault":
+		addr, token := os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN")
+		if addr == "" || token == "" {
+			logger.Fatal("HISTORY_ENCRYPTION=vault needs VAULT_ADDR and VAULT_TOKEN")
+		}
+		keyName := os.Getenv("VAULT_TRANSIT_KEY")
+		if keyName == "" {
+			keyName = "pal-moe-history"
+		}
+		logger.Infof("Encrypting request history with Vault transit key %s at %s", keyName, addr)
+		return envelope.NewSealer(envelope.NewVaultTransit(addr, token, os.Getenv("VAULT_TRANSIT_MOUNT"), keyName))
+	default:
+		logger.Fatalf("Invalid HISTORY_ENCRYPTION %q (want local or vault)", mode)
+		return nil
+	}
+}
+
+// int64FromEnv reads a positive integer from the named environment variable
+func int64FromEnv(logger *logrus.Logger, name string, defaultValue int64) int64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || parsed <= 0 {
+		logger.Fatalf("Invalid %s %q", name, value)
+	}
+	return parsed
+}
+
+// floatFromEnv reads a non-negative number from the named environment variable
+func floatFromEnv(logger *logrus.Logger, name string, defaultValue float64) float64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil || parsed < 0 {
+		logger.Fatalf("Invalid %s %q", name, value)
+	}
+	return parsed
+}
+
+// durationFromEnv reads a time.Duration from the named environment variable
+func durationFromEnv(logger *logrus.Logger, name string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		logger.Fatalf("Invalid %s %q: %v", name, value, err)
+	}
+	return parsed
+}
+
+// HTTPServer handles HTTP requests
+type HTTPServer struct {
+	system        *enhanced.EnhancedSystem
+	logger        *logrus.Logger
+	strictJSON    bool
+	history       *config.ConfigHistory
+	cors          *middleware.CORS
+	browserTokens *middleware.BrowserTokens
+	modelAliases  *selection.ModelAliases
+	artifacts     *artifact.Manager
+	// started dates the models listed by /v1/models
+	started time.Time
+}
+
+// drainGuard rejects new work with 503 once the system has started draining
+func (h *HTTPServer) drainGuard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.system.IsDraining() {
+			writeShuttingDown(w)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeShuttingDown tells the client to retry against another replica
+func writeShuttingDown(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", "5")
+	http.Error(w, "Server is shutting down, retry shortly", http.StatusServiceUnavailable)
+}
+
+// healthHandler answers liveness probes: the process is up and serving HTTP
+func (h *HTTPServer) healthHandler(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{
+		"status":    "healthy",
+		"timestamp": time.Now().Unix(),
+		"version":   apiVersion,
+		"features":  []string{"complexity-analysis", "provider-selection", "prompt-optimization"},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// readyHandler answers readiness probes with the outcome of each dependency
+// check, and 503 when the server should not receive traffic
+func (h *HTTPServer) readyHandler(w http.ResponseWriter, r *http.Request) {
+	health.Write(w, h.system.CheckReadiness(r.Context()))
+}
+
+func (h *HTTPServer) processHandler(w http.ResponseWriter, r *http.Request) {
+	var input enhanced.RequestInput
+	if err := validation.DecodeJSON(r.Body, &input, h.strictJSON); err != nil {
+		validation.WriteError(w, err)
+		return
+	}
+	if err := input.Validate(); err != nil {
+		validation.WriteError(w, err)
+		return
+	}
+
+	middleware.SetAccessLogPrompt(r.Context(), input.Content)
+	logger := requestid.Logger(r.Context(), h.logger)
+	// Prompts stay out of the application log; the access log applies ACCESS_LOG_REDACTION to them
+	logger.Infof("Processing request of %d characters", utf8.RuneCountInString(input.Content))
+
+	if asyncRequested(r) {
+		h.startAsync(w, r, []enhanced.RequestInput{input}, false)
+		return
+	}
+
+	// Process request with enhanced system
+	result, err := h.system.ProcessRequest(r.Context(), input)
+	if errors.Is(err, enhanced.ErrShuttingDown) {
+		writeShuttingDown(w)
+		return
+	}
+	if errors.Is(err, enhanced.ErrRateLimited) {
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	if errors.Is(err, enhanced.ErrProvidersUnavailable) {
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if errors.Is(err, tenant.ErrBudgetExceeded) || errors.Is(err, environment.ErrBudgetExceeded) {
+		http.Error(w, err.Error(), http.StatusPaymentRequired)
+		return
+	}
+	if errors.Is(err, environment.ErrUnknown) || errors.Is(err, environment.ErrNoProviders) || errors.Is(err, hooks.ErrRejected) || errors.Is(err, injection.ErrBlocked) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, hooks.ErrVetoed) || errors.Is(err, safety.ErrBlocked) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	var deadlineErr *enhanced.DeadlineError
+	if errors.As(err, &deadlineErr) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusGatewayTimeout)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": deadlineErr.Error(),
+			"stage": deadlineErr.Stage,
+		})
+		return
+	}
+	var ambiguousErr *selection.AmbiguousRoutingError
+	if errors.As(err, &ambiguousErr) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":          ambiguousErr.Error(),
+			"confidence":     ambiguousErr.Confidence,
+			"min_confidence": ambiguousErr.MinConfidence,
+			"fallback":       ambiguousErr.Fallback,
+			"candidates":     ambiguousErr.Candidates,
+		})
+		return
+	}
+	var capabilityErr *selection.NoCapableProviderError
+	if errors.As(err, &capabilityErr) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":                 capabilityErr.Error(),
+			"task_type":             capabilityErr.TaskType,
+			"required_capabilities": capabilityErr.RequiredCapabilities,
+			"nearest_misses":        capabilityErr.NearestMisses,
+		})
+		return
+	}
+	var constraintErr *selection.ConstraintError
+	if errors.As(err, &constraintErr) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":       constraintErr.Error(),
+			"constraints": constraintErr.Constraints,
+			"rejections":  constraintErr.Rejections,
+		})
+		return
+	}
+	if err != nil {
+		logger.Errorf("Failed to process request: %v", err)
+		http.Error(w, fmt.Sprintf("Processing failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+	middleware.SetAccessLogProvider(r.Context(), result.Provider.Name)
+
+	setRoutingHeaders(w, result)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// BatchRequest is the payload accepted by the batch endpoint
+type BatchRequest struct {
+	Requests []enhanced.RequestInput `json:"requests"`
+}
+
+// BatchResult is the outcome of a single request within a batch
+type BatchResult struct {
+	Index    int                       `json:"index"`
+	Response *enhanced.ProcessResponse `json:"response,omitempty"`
+	Error    string                    `json:"error,omitempty"`
+}
+
+func (h *HTTPServer) batchHandler(w http.ResponseWriter, r *http.Request) {
+	var batch BatchRequest
+	if err := validation.DecodeJSON(r.Body, &batch, h.strictJSON); err != nil {
+		validation.WriteError(w, err)
+		return
+	}
+
+	ve := &validation.ValidationError{}
+	if len(batch.Requests) == 0 {
+		ve.Add("requests", "must contain at least one request")
+	}
+	for i, input := range batch.Requests {
+		if err := input.Validate(); err != nil {
+			ve.Merge(fmt.Sprintf("requests[%d]", i), err)
+		}
+	}
+	if ve.HasErrors() {
+		validation.WriteError(w, ve)
+		return
+	}
+
+	logger := requestid.Logger(r.Context(), h.logger)
+	logger.Infof("Processing batch of %d requests", len(batch.Requests))
+
+	if asyncRequested(r) {
+		h.startAsync(w, r, batch.Requests, true)
+		return
+	}
+
+	// Batch items are bulk traffic for routing policies
+	ctx := selection.WithTrafficClass(r.Context(), selection.TrafficBatch)
+
+	results := make([]BatchResult, len(batch.Requests))
+	for i, input := range batch.Requests {
+		results[i].Index = i
+		result, err := h.system.ProcessRequest(batchItemContext(ctx, i), input)
+		if err != nil {
+			logger.Errorf("Failed to process batch item %d: %v", i, err)
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].Response = result
+		middleware.SetAccessLogProvider(r.Context(), result.Provider.Name)
+	}
+
+	response := map[string]interface{}{
+		"results":   results,
+		"timestamp": time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// batchItemContext gives a batch item its own request ID, the batch's with
+// the item's index appended, so each item can be retrieved from the history
+func batchItemContext(ctx context.Context, index int) context.Context {
+	return requestid.NewContext(ctx, fmt.Sprintf("%s-%d", requestid.FromContext(ctx), index))
+}
+
+// asyncRequested reports whether the client asked for an answer before
+// processing completes, with ?async=true or Prefer: respond-async
+func asyncRequested(r *http.Request) bool {
+	return r.URL.Query().Get("async") == "true" || strings.Contains(r.Header.Get("Prefer"), "respond-async")
+}
+
+// AsyncBatch is the body returned when a batch is processed asynchronously
+type AsyncBatch struct {
+	Requests []*enhanced.RequestRecord `json:"requests"`
+}
+
+// startAsync starts processing inputs in the background and answers 202
+// with the running requests, to be polled at /api/v1/requests/{id}. Batch
+// items get their own IDs, see batchItemContext
+func (h *HTTPServer) startAsync(w http.ResponseWriter, r *http.Request, inputs []enhanced.RequestInput, batch bool) {
+	ctx := r.Context()
+	if batch {
+		ctx = selection.WithTrafficClass(ctx, selection.TrafficBatch)
+	}
+
+	records := make([]*enhanced.RequestRecord, 0, len(inputs))
+	for i, input := range inputs {
+		itemCtx := ctx
+		if batch {
+			itemCtx = batchItemContext(ctx, i)
+		}
+		record, err := h.system.ProcessAsync(itemCtx, input)
+		if errors.Is(err, enhanced.ErrShuttingDown) {
+			writeShuttingDown(w)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		records = append(records, record)
+	}
+	requestid.Logger(r.Context(), h.logger).Infof("Started %d asynchronous requests", len(records))
+
+	w.Header().Set("Content-Type", "application/json")
+	if batch {
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(AsyncBatch{Requests: records})
+		return
+	}
+	w.Header().Set("Location", "/api/v1/requests/"+records[0].ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(records[0])
+}
+
+// cancelRequestHandler cancels a running asynchronous request
+func (h *HTTPServer) cancelRequestHandler(w http.ResponseWriter, r *http.Request) {
+	record, err := h.system.CancelRequest(r.Context(), mux.Vars(r)["id"])
+	switch {
+	case errors.Is(err, enhanced.ErrRequestNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, enhanced.ErrRequestFinished):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, fmt.Sprintf("Failed to cancel request: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(record)
+}
+
+func (h *HTTPServer) getRequestHandler(w http.ResponseWriter, r *http.Request) {
+	record, err := h.system.GetRequest(r.Context(), mux.Vars(r)["id"])
+	if errors.Is(err, enhanced.ErrRequestNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load request: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(record)
+}
+
+// getArtifactHandler serves a stored artifact to holders of its signed URL
+func (h *HTTPServer) getArtifactHandler(w http.ResponseWriter, r *http.Request) {
+	if h.artifacts == nil {
+		http.Error(w, "ARTIFACT_STORE is not set", http.StatusNotFound)
+		return
+	}
+	query := r.URL.Query()
+	body, info, err := h.artifacts.Open(r.Context(), mux.Vars(r)["key"], query.Get("expires"), query.Get("signature"), time.Now())
+	switch {
+	case errors.Is(err, artifact.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, artifact.ErrExpired):
+		http.Error(w, err.Error(), http.StatusGone)
+		return
+	case errors.Is(err, artifact.ErrInvalidSignature):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case err != nil:
+		http.Error(w, fmt.Sprintf("Failed to load artifact: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+
+	// Content never changes under a key, so clients may cache it as long as
+	// the URL is valid
+	w.Header().Set("Content-Type", info.ContentType)
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(int(h.artifacts.TTL().Seconds())))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if info.Size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	}
+	io.Copy(w, body)
+}
+
+// listRequestsHandler pages through the caller's processed requests, newest first
+func (h *HTTPServer) listRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := enhanced.RequestQuery{
+		KeyID:     params.Get("key_id"),
+		SessionID: params.Get("session_id"),
+		Status:    params.Get("status"),
+		Provider:  params.Get("provider"),
+		Cursor:    params.Get("cursor"),
+	}
+
+	ve := &validation.ValidationError{Status: http.StatusBadRequest}
+	switch query.Status {
+	case "", enhanced.RequestSucceeded, enhanced.RequestFailed, enhanced.RequestCancelled:
+	default:
+		ve.Addf("status", "must be %s, %s or %s", enhanced.RequestSucceeded, enhanced.RequestFailed, enhanced.RequestCancelled)
+	}
+	for _, bound := range []struct {
+		name  string
+		value *time.Time
+	}{{"since", &query.Since}, {"until", &query.Until}} {
+		if value := params.Get(bound.name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				ve.Add(bound.name, "must be an RFC 3339 time")
+			}
+			*bound.value = parsed
+		}
+	}
+	if value := params.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > enhanced.MaxRequestPageSize {
+			ve.Addf("limit", "must be between 1 and %d", enhanced.MaxRequestPageSize)
+		}
+		query.Limit = limit
+	}
+	if ve.HasErrors() {
+		validation.WriteError(w, ve)
+		return
+	}
+
+	page, err := h.system.ListRequests(r.Context(), query)
+	if errors.Is(err, enhanced.ErrInvalidCursor) {
+		ve.Add("cursor", "must be the next_cursor of a previous page")
+		validation.WriteError(w, ve)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to list requests: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+func (h *HTTPServer) getProvidersHandler(w http.ResponseWriter, r *http.Request) {
+	// Tenants only see their own provider inventory
+	providers := h.system.ProvidersForTenant(middleware.TenantFromContext(r.Context()))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(providers)
+}
+
+func (h *HTTPServer) getProviderLoadHandler(w http.ResponseWriter, r *http.Request) {
+	load := make(map[string]int)
+	inFlight := h.system.ProviderLoad()
+	for _, provider := range h.system.ProvidersForTenant(middleware.TenantFromContext(r.Context())) {
+		load[provider.Name] = inFlight[provider.Name]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(load)
+}
+
+func (h *HTTPServer) getProviderWarmupHandler(w http.ResponseWriter, r *http.Request) {
+	estimates := h.system.WarmupEstimates()
+	if estimates == nil {
+		http.Error(w, "Provider warm-up is not enabled; set COLD_START_REQUESTS", http.StatusNotFound)
+		return
+	}
+	visible := make(map[string]selection.HealthEstimate)
+	for _, provider := range h.system.ProvidersForTenant(middleware.TenantFromContext(r.Context())) {
+		visible[provider.Name] = estimates[provider.Name]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(visible)
+}
+
+func (h *HTTPServer) getProviderThroughputHandler(w http.ResponseWriter, r *http.Request) {
+	visible := make(map[string]bool)
+	for _, provider := range h.system.ProvidersForTenant(middleware.TenantFromContext(r.Context())) {
+		visible[provider.Name] = true
+	}
+	// Tenants see the accounts of their providers, and only those providers
+	headrooms := []selection.Headroom{}
+	for _, headroom := range h.system.Throughput() {
+		var providers []string
+		for _, name := range headroom.Providers {
+			if visible[name] {
+				providers = append(providers, name)
+			}
+		}
+		if len(providers) > 0 {
+			headroom.Providers = providers
+			headrooms = append(headrooms, headroom)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(headrooms)
+}
+
+func (h *HTTPServer) getSpeculativeStatsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.system.SpeculativeStats())
+}
+
+// getSafetyEscalationsHandler returns how often blocked responses were
+// escalated to an official provider and how often that rescued the request
+func (h *HTTPServer) getSafetyEscalationsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.system.SafetyEscalationStats())
+}
+
+// BrowserTokenRequest asks for a token a front-end app served from Origin can
+// use in place of the caller's API key
+type BrowserTokenRequest struct {
+	Origin     string `json:"origin"`
+	TTLSeconds int    `json:"ttl_seconds,omitempty"`
+}
+
+// BrowserToken is an issued browser token
+type BrowserToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// issueBrowserTokenHandler exchanges the caller's API key for a short-lived
+// token restricted to one allowed origin
+func (h *HTTPServer) issueBrowserTokenHandler(w http.ResponseWriter, r *http.Request) {
+	keyID := middleware.KeyIDFromContext(r.Context())
+	if keyID == "" || middleware.IsBrowserToken(r.Context()) {
+		http.Error(w, "Browser tokens are issued to API keys only", http.StatusUnauthorized)
+		return
+	}
+
+	var req BrowserTokenRequest
+	if err := validation.DecodeJSON(r.Body, &req, h.strictJSON); err != nil {
+		validation.WriteError(w, err)
+		return
+	}
+	ve := &validation.ValidationError{}
+	if !h.cors.Allows(req.Origin) {
+		ve.Add("origin", "must be an origin allowed by the cors configuration")
+	}
+	if req.TTLSeconds < 0 {
+		ve.Add("ttl_seconds", "must not be negative")
+	}
+	if ve.HasErrors() {
+		validation.WriteError(w, ve)
+		return
+	}
+
+	token, expiresAt, err := h.browserTokens.Issue(keyID, middleware.EnvironmentFromContext(r.Context()), req.Origin, time.Duration(req.TTLSeconds)*time.Second)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to issue browser token: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(BrowserToken{Token: token, ExpiresAt: expiresAt})
+}
+
+func (h *HTTPServer) getMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	// Return dummy metrics for now
+	metrics := map[string]interface{}{
+		"total_requests":     100,
+		"successful_requests": 95,
+		"failed_requests":    5,
+		"average_latency":    "150ms",
+		"providers_active":   len(h.system.GetProviders()),
+		"active_requests":    h.system.ActiveRequests(),
+	}
+	if clusterActive, err := h.system.ClusterActiveRequests(r.Context()); err == nil {
+		metrics["cluster_active_requests"] = clusterActive
+	}
+	if providerStats, err := h.system.ClusterProviderStats(r.Context()); err == nil && len(providerStats) > 0 {
+		metrics["cluster_providers"] = providerStats
+	}
+	if calibrations := h.system.TokenCalibrations(); len(calibrations) > 0 {
+		metrics["token_calibration"] = calibrations
+	}
+	if scrubbed := h.system.ScrubCounts(); len(scrubbed) > 0 {
+		metrics["secrets_scrubbed"] = scrubbed
+	}
+	if eventStats, ok := h.system.EventStats(); ok {
+		metrics["events"] = eventStats
+	}
+	if cacheStats, ok := h.system.ClassificationCacheStats(); ok {
+		metrics["classification_cache"] = cacheStats
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(metrics)
+}
+
+func (h *HTTPServer) generateProviderYAMLHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	providerID := vars["id"]
+
+	yaml, err := h.system.GenerateProviderYAML(providerID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to generate YAML: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-yaml")
+	w.Write([]byte(yaml))
+}
+
+func (h *HTTPServer) generateAllYAMLsHandler(w http.ResponseWriter, r *http.Request) {
+	yaml, err := h.system.GenerateAllProviderYAMLs()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to generate YAMLs: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
 		"yaml":      yaml,
 		"timestamp": time.Now().Unix(),
 	}
 
+	// With PROVIDER_YAML_DIR set the files are written too, skipping unchanged ones
+	changes, err := h.system.SyncProviderYAMLs()
+	switch {
+	case err == nil:
+		response["changes"] = changes
+		if _, err := h.history.Snapshot(admin.Author(r), "generate provider YAML files"); err != nil {
+			h.logger.Warnf("Failed to record generated YAML files: %v", err)
+		}
+	case !errors.Is(err, enhanced.ErrProviderYAMLDirUnset):
+		http.Error(w, fmt.Sprintf("Failed to write YAMLs: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *HTTPServer) getCapabilityProbesHandler(w http.ResponseWriter, r *http.Request) {
+	results, err := h.system.CapabilityProbes()
+	if errors.Is(err, enhanced.ErrProbingDisabled) {
+		http.Error(w, "Capability probing is not enabled; set CAPABILITY_PROBE=true", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+func (h *HTTPServer) probeProviderHandler(w http.ResponseWriter, r *http.Request) {
+	result, err := h.system.ProbeProvider(r.Context(), mux.Vars(r)["id"])
+	switch {
+	case errors.Is(err, enhanced.ErrProbingDisabled):
+		http.Error(w, "Capability probing is not enabled; set CAPABILITY_PROBE=true", http.StatusNotFound)
+		return
+	case errors.Is(err, enhanced.ErrUnknownProvider):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case result == nil:
+		http.Error(w, fmt.Sprintf("Failed to probe provider: %v", err), http.StatusBadRequest)
+		return
+	case err != nil:
+		// The probe ran but its result could not be saved
+		h.logger.Warnf("Failed to store probe result: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (h *HTTPServer) diffYAMLsHandler(w http.ResponseWriter, r *http.Request) {
+	changes, err := h.system.DiffProviderYAMLs()
+	if errors.Is(err, enhanced.ErrProviderYAMLDirUnset) {
+		http.Error(w, "Provider YAML directory is not configured; set PROVIDER_YAML_DIR", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to diff YAMLs: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"changes":   changes,
+		"timestamp": time.Now().Unix(),
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
\ No newline at end of file
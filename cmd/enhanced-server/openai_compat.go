@@ -0,0 +1,354 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/internal/enhanced"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/environment"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/hooks"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/injection"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/middleware"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/openai"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/requestid"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/safety"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/selection"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tenant"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/usage"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/validation"
+	"github.com/gorilla/mux"
+)
+
+// chatCompletionsHandler serves the OpenAI chat completions API, routing the
+// conversation like /api/v1/process
+func (h *HTTPServer) chatCompletionsHandler(w http.ResponseWriter, r *http.Request) {
+	// Frameworks send many fields the gateway ignores, so unknown fields are
+	// always accepted here
+	var request openai.ChatCompletionRequest
+	if err := validation.DecodeJSON(r.Body, &request, false); err != nil {
+		writeOpenAIValidationError(w, err)
+		return
+	}
+	input, err := completionInput(&request)
+	if err != nil {
+		writeOpenAIValidationError(w, err)
+		return
+	}
+
+	middleware.SetAccessLogPrompt(r.Context(), input.Content)
+	logger := requestid.Logger(r.Context(), h.logger)
+	logger.Infof("Processing chat completion for model %s", request.Model)
+
+	result, err := h.system.ProcessRequest(r.Context(), input)
+	if err != nil {
+		h.writeCompletionError(w, r, err)
+		return
+	}
+	middleware.SetAccessLogProvider(r.Context(), result.Provider.Name)
+	setRoutingHeaders(w, result)
+
+	choices := completionChoices(result, &request)
+	completionUsage := completionUsage(result, &request, choices)
+	id := "chatcmpl-" + requestid.FromContext(r.Context())
+	created := time.Now().Unix()
+	if request.Stream {
+		streamCompletion(w, &request, id, created, result.Model, choices, completionUsage)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(openai.ChatCompletion{
+		ID:      id,
+		Object:  openai.ObjectChatCompletion,
+		Created: created,
+		Model:   result.Model,
+		Choices: choices,
+		Usage:   completionUsage,
+	})
+}
+
+// completionInput validates a chat completion request and turns it into the
+// request the system routes
+func completionInput(request *openai.ChatCompletionRequest) (enhanced.RequestInput, error) {
+	ve := &validation.ValidationError{}
+	if len(request.Messages) == 0 {
+		ve.Add("messages", "must contain at least one message")
+	}
+	for i, message := range request.Messages {
+		switch message.Role {
+		case openai.RoleSystem, openai.RoleDeveloper, openai.RoleUser, openai.RoleAssistant, openai.RoleTool:
+		default:
+			ve.Addf(fmt.Sprintf("messages[%d].role", i), "unknown role %q", message.Role)
+		}
+	}
+	if request.StreamOptions != nil && !request.Stream {
+		ve.Add("stream_options", "is only allowed when stream is true")
+	}
+	if ve.HasErrors() {
+		return enhanced.RequestInput{}, ve
+	}
+
+	input := enhanced.RequestInput{
+		Content:          request.Prompt(),
+		Model:            request.Model,
+		MaxTokens:        request.CompletionLimit(),
+		Temperature:      request.Temperature,
+		TopP:             request.TopP,
+		FrequencyPenalty: request.FrequencyPenalty,
+		PresencePenalty:  request.PresencePenalty,
+		Stop:             request.Stop,
+		LogitBias:        request.LogitBias,
+		N:                request.N,
+		BestOf:           request.BestOf,
+		Seed:             request.Seed,
+		ReasoningEffort:  request.ReasoningEffort,
+	}
+	metadata := make(map[string]interface{})
+	if request.User != "" {
+		metadata["user"] = request.User
+	}
+	if len(request.Metadata) > 0 {
+		metadata["tags"] = request.Metadata
+	}
+	if len(metadata) > 0 {
+		input.Metadata = metadata
+	}
+	if err := input.Validate(); err != nil {
+		// Name the fields as the client sent them
+		var ve *validation.ValidationError
+		if errors.As(err, &ve) {
+			for i := range ve.Errors {
+				switch ve.Errors[i].Field {
+				case "content":
+					ve.Errors[i].Field = "messages"
+				case "metadata.tags":
+					ve.Errors[i].Field = "metadata"
+				}
+			}
+		}
+		return enhanced.RequestInput{}, err
+	}
+	return input, nil
+}
+
+// completionChoices returns the answers of a completion, one per choice the
+// request asked for unless the safety filter withheld some
+func completionChoices(result *enhanced.ProcessResponse, request *openai.ChatCompletionRequest) []openai.Choice {
+	answers := []string{result.Content}
+	if len(result.Choices) > 0 {
+		answers = answers[:0]
+		for _, choice := range result.Choices {
+			answers = append(answers, choice.Content)
+		}
+	}
+	choices := make([]openai.Choice, len(answers))
+	for i, answer := range answers {
+		content, finishReason := completionContent(answer, request)
+		choices[i] = openai.Choice{
+			Index:        i,
+			Message:      openai.Message{Role: openai.RoleAssistant, Content: openai.Content(content)},
+			FinishReason: finishReason,
+		}
+	}
+	return choices
+}
+
+// completionContent applies the request's stop sequences and token limit to
+// the answer and returns it with its finish reason
+func completionContent(content string, request *openai.ChatCompletionRequest) (string, string) {
+	content, _ = openai.CutAtStop(content, request.Stop)
+	if truncated, cut := openai.TruncateTokens(content, request.CompletionLimit()); cut {
+		return truncated, openai.FinishLength
+	}
+	return content, openai.FinishStop
+}
+
+// completionUsage counts the tokens of a completion as tiktoken would, the
+// prompt once per provider call and the answers returned, unless the
+// provider reported its own usage
+func completionUsage(result *enhanced.ProcessResponse, request *openai.ChatCompletionRequest, choices []openai.Choice) openai.Usage {
+	if reconciliation, ok := result.Metadata["token_usage"].(usage.Reconciliation); ok && !reconciliation.Actual.IsZero() {
+		reported := openai.NewUsage(reconciliation.Actual.PromptTokens, reconciliation.Actual.CompletionTokens)
+		if reconciliation.Actual.ReasoningTokens > 0 {
+			reported.CompletionTokensDetails = &openai.CompletionTokensDetails{ReasoningTokens: reconciliation.Actual.ReasoningTokens}
+		}
+		return reported
+	}
+	calls := int64(1)
+	if generated, ok := result.Metadata["choices"].(enhanced.ChoiceResult); ok {
+		calls = int64(generated.Calls)
+	}
+	var completionTokens int64
+	for _, choice := range choices {
+		completionTokens += openai.CountTokens(string(choice.Message.Content))
+	}
+	return openai.NewUsage(openai.CountMessageTokens(request.Messages)*calls, completionTokens)
+}
+
+// streamCompletion writes a completion as server-sent chunks: for each
+// choice in turn the role, the content and the finish reason, then the usage
+// when asked for, then [DONE]
+func streamCompletion(w http.ResponseWriter, request *openai.ChatCompletionRequest, id string, created int64, model string, choices []openai.Choice, completionUsage openai.Usage) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	controller := http.NewResponseController(w)
+
+	chunk := func(choices []openai.ChunkChoice, chunkUsage *openai.Usage) {
+		data, _ := json.Marshal(openai.ChatCompletionChunk{
+			ID:      id,
+			Object:  openai.ObjectChatCompletionChunk,
+			Created: created,
+			Model:   model,
+			Choices: choices,
+			Usage:   chunkUsage,
+		})
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		controller.Flush()
+	}
+	for _, choice := range choices {
+		chunk([]openai.ChunkChoice{{Index: choice.Index, Delta: openai.Delta{Role: openai.RoleAssistant}}}, nil)
+		if content := string(choice.Message.Content); content != "" {
+			chunk([]openai.ChunkChoice{{Index: choice.Index, Delta: openai.Delta{Content: content}}}, nil)
+		}
+		chunk([]openai.ChunkChoice{{Index: choice.Index, FinishReason: &choice.FinishReason}}, nil)
+	}
+	if request.StreamOptions != nil && request.StreamOptions.IncludeUsage {
+		chunk([]openai.ChunkChoice{}, &completionUsage)
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	controller.Flush()
+}
+
+// writeCompletionError answers a failed completion with the status
+// /api/v1/process uses, in the OpenAI error format
+func (h *HTTPServer) writeCompletionError(w http.ResponseWriter, r *http.Request, err error) {
+	var deadlineErr *enhanced.DeadlineError
+	var constraintErr *selection.ConstraintError
+	var capabilityErr *selection.NoCapableProviderError
+	var ambiguousErr *selection.AmbiguousRoutingError
+	switch {
+	case errors.Is(err, enhanced.ErrShuttingDown):
+		w.Header().Set("Retry-After", "5")
+		openai.WriteError(w, http.StatusServiceUnavailable, openai.ErrorServer, "Server is shutting down, retry shortly", "", "shutting_down")
+	case errors.Is(err, enhanced.ErrRateLimited):
+		w.Header().Set("Retry-After", "60")
+		openai.WriteError(w, http.StatusTooManyRequests, openai.ErrorRateLimit, err.Error(), "", "rate_limit_exceeded")
+	case errors.Is(err, enhanced.ErrProvidersUnavailable):
+		w.Header().Set("Retry-After", "60")
+		openai.WriteError(w, http.StatusServiceUnavailable, openai.ErrorServer, err.Error(), "", "providers_unavailable")
+	case errors.Is(err, tenant.ErrBudgetExceeded) || errors.Is(err, environment.ErrBudgetExceeded):
+		openai.WriteError(w, http.StatusPaymentRequired, openai.ErrorInsufficientQuota, err.Error(), "", "budget_exceeded")
+	case errors.Is(err, environment.ErrUnknown) || errors.Is(err, environment.ErrNoProviders) || errors.Is(err, hooks.ErrRejected):
+		openai.WriteError(w, http.StatusForbidden, openai.ErrorPermission, err.Error(), "", "")
+	case errors.Is(err, injection.ErrBlocked):
+		openai.WriteError(w, http.StatusForbidden, openai.ErrorPermission, err.Error(), "", "prompt_injection")
+	case errors.Is(err, safety.ErrBlocked):
+		openai.WriteError(w, http.StatusUnprocessableEntity, openai.ErrorInvalidRequest, err.Error(), "", "content_filter")
+	case errors.Is(err, hooks.ErrVetoed):
+		openai.WriteError(w, http.StatusUnprocessableEntity, openai.ErrorInvalidRequest, err.Error(), "model", "no_capable_provider")
+	case errors.As(err, &deadlineErr):
+		openai.WriteError(w, http.StatusGatewayTimeout, openai.ErrorTimeout, deadlineErr.Error(), "", deadlineErr.Stage)
+	case errors.As(err, &ambiguousErr) && ambiguousErr.Fallback == selection.FallbackPin:
+		openai.WriteError(w, http.StatusConflict, openai.ErrorInvalidRequest, ambiguousErr.Error(), "model", "model_pin_required")
+	case errors.As(err, &ambiguousErr):
+		openai.WriteError(w, http.StatusConflict, openai.ErrorInvalidRequest, ambiguousErr.Error(), "model", "ambiguous_routing")
+	case errors.As(err, &capabilityErr):
+		openai.WriteError(w, http.StatusUnprocessableEntity, openai.ErrorInvalidRequest, capabilityErr.Error(), "model", "no_capable_provider")
+	case errors.As(err, &constraintErr):
+		openai.WriteError(w, http.StatusUnprocessableEntity, openai.ErrorInvalidRequest, constraintErr.Error(), "model", "no_capable_provider")
+	default:
+		requestid.Logger(r.Context(), h.logger).Errorf("Failed to process chat completion: %v", err)
+		openai.WriteError(w, http.StatusInternalServerError, openai.ErrorServer, fmt.Sprintf("Processing failed: %v", err), "", "")
+	}
+}
+
+// writeOpenAIValidationError answers an invalid request in the OpenAI error
+// format, naming the first invalid field as the param
+func writeOpenAIValidationError(w http.ResponseWriter, err error) {
+	var ve *validation.ValidationError
+	if !errors.As(err, &ve) {
+		ve = &validation.ValidationError{Status: http.StatusBadRequest, Errors: []validation.FieldError{{Message: err.Error()}}}
+	}
+	status := ve.Status
+	if status == 0 {
+		status = http.StatusBadRequest
+	}
+	param := ""
+	if len(ve.Errors) > 0 {
+		param = ve.Errors[0].Field
+	}
+	openai.WriteError(w, status, openai.ErrorInvalidRequest, ve.Error(), param, "")
+}
+
+// openAINotFoundHandler answers requests that match no route. Paths under
+// /v1/ get the body OpenAI answers for an unknown URL, so SDKs raise their
+// not found error instead of failing to parse plain text
+func openAINotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, "/v1/") {
+		http.NotFound(w, r)
+		return
+	}
+	openai.WriteError(w, http.StatusNotFound, openai.ErrorInvalidRequest, fmt.Sprintf("Invalid URL (%s %s)", r.Method, r.URL.Path), "", "")
+}
+
+// listModelsHandler lists the models a client may name: the virtual models,
+// the model aliases and the models of the caller's providers
+func (h *HTTPServer) listModelsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(openai.ModelList{
+		Object: openai.ObjectList,
+		Data:   h.models(r),
+	})
+}
+
+// getModelHandler describes one model of the list
+func (h *HTTPServer) getModelHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	for _, model := range h.models(r) {
+		if model.ID == id {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(model)
+			return
+		}
+	}
+	openai.WriteError(w, http.StatusNotFound, openai.ErrorNotFound, fmt.Sprintf("The model %q does not exist", id), "model", "model_not_found")
+}
+
+// models lists the virtual models first, then the aliases and provider models
+// sorted by ID. A model served by several providers is owned by the first
+func (h *HTTPServer) models(r *http.Request) []openai.Model {
+	created := h.started.Unix()
+	var models []openai.Model
+	for _, virtual := range h.system.VirtualModels() {
+		models = append(models, openai.Model{ID: virtual.ID(), Object: openai.ObjectModel, Created: created, OwnedBy: openai.OwnedBy})
+	}
+
+	seen := make(map[string]bool)
+	var named []openai.Model
+	for _, provider := range h.system.ProvidersForTenant(middleware.TenantFromContext(r.Context())) {
+		for _, model := range provider.Models {
+			if !seen[model] {
+				seen[model] = true
+				named = append(named, openai.Model{ID: model, Object: openai.ObjectModel, Created: created, OwnedBy: provider.Name})
+			}
+		}
+	}
+	if h.modelAliases != nil {
+		for _, alias := range h.modelAliases.Aliases() {
+			if !seen[alias.Alias] {
+				seen[alias.Alias] = true
+				named = append(named, openai.Model{ID: alias.Alias, Object: openai.ObjectModel, Created: created, OwnedBy: openai.OwnedBy})
+			}
+		}
+	}
+	sort.Slice(named, func(i, j int) bool {
+		return named[i].ID < named[j].ID
+	})
+	return append(models, named...)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/internal/enhanced"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/contract"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/middleware"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// TestOpenAIContract replays the OpenAI SDK captures against the compatible
+// routes, so a change that breaks the SDKs at the wire level fails
+func TestOpenAIContract(t *testing.T) {
+	system := enhanced.NewEnhancedSystem([]*enhanced.Provider{{
+		Name:         "OpenAI",
+		BaseURL:      "https://api.openai.com/v1",
+		Models:       []string{"gpt-4", "gpt-4o-mini"},
+		Tier:         enhanced.OfficialTier,
+		MaxTokens:    8192,
+		CostPerToken: 0.00003,
+		Capabilities: []string{"reasoning", "creative", "factual"},
+		Metadata:     make(map[string]interface{}),
+		LastUpdated:  time.Now(),
+	}})
+	logger := logrus.New()
+	logger.SetLevel(logrus.WarnLevel)
+	server := &HTTPServer{system: system, logger: logger, started: time.Now()}
+
+	router := mux.NewRouter()
+	router.NotFoundHandler = http.HandlerFunc(openAINotFoundHandler)
+	router.Use(middleware.RequestID)
+	router.HandleFunc("/v1/chat/completions", server.chatCompletionsHandler).Methods("POST")
+	router.HandleFunc("/v1/models", server.listModelsHandler).Methods("GET")
+	router.HandleFunc("/v1/models/{id:.+}", server.getModelHandler).Methods("GET")
+	gateway := httptest.NewServer(router)
+	defer gateway.Close()
+
+	contract.Check(t, gateway.URL, "")
+}
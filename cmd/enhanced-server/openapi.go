@@ -0,0 +1,622 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/internal/enhanced"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/admin"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/analytics"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/audit"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/credentials"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/flags"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/health"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/incident"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/learned"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/middleware"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/onboard"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/openai"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/openapi"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/probe"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/reload"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/reporting"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/requestid"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/retention"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/safety"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/selection"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/simulation"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/sla"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/speculative"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tenant"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// apiVersion is reported in the OpenAPI document and the health endpoint
+const apiVersion = "enhanced-2.0.0"
+
+// buildOpenAPISpec describes every route served by the enhanced server. Request and
+// response models are derived from the Go types the handlers encode and decode
+func buildOpenAPISpec() *openapi.Document {
+	b := openapi.NewBuilder("Your PaL MoE Enhanced API", apiVersion,
+		"Cost-optimized multi-provider AI gateway with task reasoning and adaptive provider selection")
+
+	validationError := b.AddSchema("ValidationError", &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"error": {Type: "string", Enum: []string{"validation_failed"}},
+			"fields": {Type: "array", Items: &openapi.Schema{
+				Type: "object",
+				Properties: map[string]*openapi.Schema{
+					"field":   {Type: "string"},
+					"message": {Type: "string"},
+				},
+			}},
+		},
+		Required: []string{"error", "fields"},
+	})
+	healthStatus := b.AddSchema("HealthStatus", &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"status":    {Type: "string"},
+			"timestamp": {Type: "integer", Format: "int64"},
+			"version":   {Type: "string"},
+			"features":  {Type: "array", Items: &openapi.Schema{Type: "string"}},
+		},
+	})
+	batchResponse := b.AddSchema("BatchResponse", &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"results":   {Type: "array", Items: b.SchemaOf(BatchResult{})},
+			"timestamp": {Type: "integer", Format: "int64"},
+		},
+	})
+	yamlChanges := &openapi.Schema{Type: "array", Items: b.SchemaOf(config.YAMLChange{})}
+	yamlBundle := b.AddSchema("YAMLBundle", &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"yaml":      {Type: "string"},
+			"changes":   yamlChanges,
+			"timestamp": {Type: "integer", Format: "int64"},
+		},
+	})
+	yamlDiff := b.AddSchema("YAMLDiff", &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"changes":   yamlChanges,
+			"timestamp": {Type: "integer", Format: "int64"},
+		},
+	})
+	logLevels := &openapi.Schema{Type: "object", AdditionalProperties: &openapi.Schema{Type: "string"}}
+	anyObject := &openapi.Schema{Type: "object", AdditionalProperties: &openapi.Schema{}}
+
+	b.Operation(http.MethodGet, "/health", "getHealth", "Liveness check", "system").
+		JSON(http.StatusOK, "Service is healthy", healthStatus)
+
+	b.Operation(http.MethodGet, "/healthz", "getLiveness", "Liveness check; the process is up and serving HTTP", "system").
+		JSON(http.StatusOK, "Service is alive", healthStatus)
+
+	b.Operation(http.MethodGet, "/readyz", "getReadiness", "Readiness check of the metrics store, shared state and providers", "system").
+		JSON(http.StatusOK, "Every dependency check passed", health.Report{}).
+		JSON(http.StatusServiceUnavailable, "A dependency check failed; the failed checks carry details", health.Report{})
+
+	b.Operation(http.MethodPost, "/api/v1/process", "processRequest", "Process a single request", "requests").
+		Header(middleware.IdempotencyHeader, "Client-chosen key that makes retries safe").
+		Header(requestid.Header, "Correlation ID; generated when absent").
+		Header(middleware.TenantHeader, "Tenant the request belongs to, unless its API key is bound to one").
+		Header(middleware.TimeoutHeader, "How long the client will wait, e.g. 10s; bounds the whole pipeline up to REQUEST_TIMEOUT").
+		Header("Prefer", "respond-async answers 202 before processing completes, like ?async=true").
+		Query("async", "boolean", "Answer 202 with the running request and process it in the background").
+		JSONBody(enhanced.RequestInput{}).
+		JSON(http.StatusOK, "Processing result; the routing outcome is repeated in X-PalMoE-* headers", enhanced.ProcessResponse{}).
+		JSON(http.StatusAccepted, "Asynchronous request started; poll the Location header", enhanced.RequestRecord{}).
+		JSON(http.StatusBadRequest, "Invalid request", validationError).
+		Status(http.StatusConflict, "A request with the same Idempotency-Key is in progress, an asynchronous request with the same ID is running, or routing confidence is below MIN_SELECTION_CONFIDENCE; the body lists the candidates").
+		Status(http.StatusRequestEntityTooLarge, "").
+		Status(http.StatusUnsupportedMediaType, "").
+		Status(http.StatusPaymentRequired, "The tenant or the key's environment has spent its monthly budget").
+		Status(http.StatusForbidden, "The request may not use the tenant it names, the key's environment has no profile or allows no provider, a hook rejected the request, or the prompt injection policy blocked it").
+		Status(http.StatusUnprocessableEntity, "Idempotency-Key reused with a different body, no provider has the capabilities the task type requires (the body lists the nearest misses), no provider satisfies the request constraints or scorers, hooks vetoed every provider, or the safety filter blocked the response").
+		Status(http.StatusTooManyRequests, "Provider rate limit exhausted").
+		Status(http.StatusServiceUnavailable, "Server is shutting down").
+		Status(http.StatusGatewayTimeout, "The request deadline passed; the body names the stage it cut short")
+
+	b.Operation(http.MethodPost, "/api/v1/batch", "processBatch", "Process several requests in one call", "requests").
+		Header(middleware.IdempotencyHeader, "Client-chosen key that makes retries safe").
+		Header(requestid.Header, "Correlation ID; generated when absent").
+		Header(middleware.TimeoutHeader, "How long the client will wait for the whole batch, up to REQUEST_TIMEOUT").
+		Header("Prefer", "respond-async answers 202 before processing completes, like ?async=true").
+		Query("async", "boolean", "Answer 202 with the running items and process them in the background").
+		JSONBody(BatchRequest{}).
+		JSON(http.StatusOK, "Per-item results", batchResponse).
+		JSON(http.StatusAccepted, "Asynchronous items started, each pollable by its ID", AsyncBatch{}).
+		JSON(http.StatusBadRequest, "Invalid request", validationError).
+		Status(http.StatusConflict, "A request with the same Idempotency-Key is in progress, or an asynchronous request with the same ID is running").
+		Status(http.StatusRequestEntityTooLarge, "").
+		Status(http.StatusUnsupportedMediaType, "").
+		Status(http.StatusServiceUnavailable, "Server is shutting down")
+
+	b.Operation(http.MethodPost, "/api/v1/browser-tokens", "issueBrowserToken", "Exchange the caller's API key for a short-lived token a browser app can use from one allowed origin (needs browser_tokens in the server config)", "requests").
+		JSONBody(BrowserTokenRequest{}).
+		JSON(http.StatusOK, "Token to send as the bearer credential from the origin", BrowserToken{}).
+		JSON(http.StatusBadRequest, "Origin not allowed by the cors configuration", validationError).
+		Status(http.StatusUnauthorized, "No API key, or a browser token was used")
+
+	openAIError := b.SchemaOf(openai.ErrorResponse{})
+	b.Operation(http.MethodPost, "/v1/chat/completions", "createChatCompletion", "OpenAI-compatible chat completion; palmoe/* virtual models route by policy", "openai").
+		Header(middleware.IdempotencyHeader, "Client-chosen key that makes retries safe").
+		Header(requestid.Header, "Correlation ID; generated when absent, and the completion ID is chatcmpl-<ID>").
+		Header(middleware.TimeoutHeader, "How long the client will wait, up to REQUEST_TIMEOUT").
+		JSONBody(openai.ChatCompletionRequest{}).
+		JSON(http.StatusOK, "Chat completion, or a text/event-stream of chat.completion.chunk events ending in [DONE] when stream is true; the routing outcome is in X-PalMoE-* headers", openai.ChatCompletion{}).
+		JSON(http.StatusBadRequest, "Invalid request", openAIError).
+		JSON(http.StatusConflict, "A request with the same Idempotency-Key is in progress, or routing confidence is below MIN_SELECTION_CONFIDENCE (code ambiguous_routing or model_pin_required)", openAIError).
+		JSON(http.StatusPaymentRequired, "The tenant or the key's environment has spent its monthly budget", openAIError).
+		JSON(http.StatusForbidden, "The request may not use the tenant it names, the key's environment allows no provider, a hook rejected the request, or the prompt injection policy blocked it", openAIError).
+		JSON(http.StatusUnprocessableEntity, "No provider satisfies the request, hooks vetoed every provider, or the safety filter blocked the response (code content_filter)", openAIError).
+		JSON(http.StatusTooManyRequests, "Provider rate limit exhausted", openAIError).
+		JSON(http.StatusServiceUnavailable, "Server is shutting down", openAIError).
+		JSON(http.StatusGatewayTimeout, "The request deadline passed; the code names the stage it cut short", openAIError)
+
+	b.Operation(http.MethodGet, "/v1/models", "listModels", "List the virtual models, model aliases and provider models a client may request", "openai").
+		JSON(http.StatusOK, "Models", openai.ModelList{})
+
+	b.Operation(http.MethodGet, "/v1/models/{id}", "getModel", "Describe one model; IDs such as palmoe/auto may contain slashes", "openai").
+		JSON(http.StatusOK, "Model", openai.Model{}).
+		JSON(http.StatusNotFound, "No such model", openAIError)
+
+	b.Operation(http.MethodGet, "/api/v1/requests", "listRequests", "List the caller's processed requests, newest first", "requests").
+		Query("key_id", "string", "Only requests made with this key ID; callers with an API key always see their own").
+		Query("session_id", "string", "Only requests whose metadata.session_id is this session").
+		Query("status", "string", "succeeded, failed or cancelled").
+		Query("provider", "string", "Only requests answered by this provider").
+		Query("since", "string", "Only requests started at or after this RFC 3339 time").
+		Query("until", "string", "Only requests started before this RFC 3339 time").
+		Query("limit", "integer", "Page size, 50 by default and at most 500").
+		Query("cursor", "string", "next_cursor of the previous page").
+		JSON(http.StatusOK, "One page of requests", enhanced.RequestPage{}).
+		JSON(http.StatusBadRequest, "Invalid filter or cursor", validationError)
+
+	b.Operation(http.MethodGet, "/api/v1/requests/{id}", "getRequest", "Get a request with its status, input and result", "requests").
+		JSON(http.StatusOK, "Request and its result or error", enhanced.RequestRecord{}).
+		Status(http.StatusNotFound, "Unknown request, or a request of another key or tenant")
+
+	b.Operation(http.MethodDelete, "/api/v1/requests/{id}", "cancelRequest", "Cancel a running asynchronous request", "requests").
+		JSON(http.StatusAccepted, "Cancellation requested; the request is recorded as cancelled", enhanced.RequestRecord{}).
+		Status(http.StatusNotFound, "Unknown request, or a request of another key or tenant").
+		Status(http.StatusConflict, "The request has already finished")
+
+	b.Operation(http.MethodGet, "/api/v1/providers", "listProviders", "List configured providers", "providers").
+		JSON(http.StatusOK, "Configured providers", []*enhanced.Provider{})
+
+	b.Operation(http.MethodGet, "/api/v1/providers/{id}/yaml", "getProviderYAML", "Generate YAML for one provider", "providers").
+		Content(http.StatusOK, "Provider configuration", "application/x-yaml")
+
+	b.Operation(http.MethodPost, "/api/v1/providers/yaml/generate-all", "generateAllProviderYAML", "Generate YAML for all providers", "providers").
+		JSON(http.StatusOK, "Combined provider configuration and, with PROVIDER_YAML_DIR set, the files written", yamlBundle)
+
+	b.Operation(http.MethodGet, "/api/v1/providers/yaml/diff", "diffProviderYAML", "Preview changes to the provider YAML files", "providers").
+		JSON(http.StatusOK, "Per-file status and unified diff", yamlDiff).
+		Status(http.StatusNotFound, "PROVIDER_YAML_DIR is not set")
+
+	b.Operation(http.MethodGet, "/api/v1/providers/load", "getProviderLoad", "Requests in flight on each provider", "providers").
+		JSON(http.StatusOK, "In-flight request count by provider name", map[string]int{})
+
+	b.Operation(http.MethodGet, "/api/v1/providers/warmup", "getProviderWarmup", "Health estimates blending each provider's cold-start prior with its real requests", "providers").
+		JSON(http.StatusOK, "Health estimate by provider name", map[string]selection.HealthEstimate{}).
+		Status(http.StatusNotFound, "COLD_START_REQUESTS is not set")
+
+	b.Operation(http.MethodGet, "/api/v1/providers/throughput", "getProviderThroughput", "Tokens per minute each upstream account used in the last minute and has left", "providers").
+		JSON(http.StatusOK, "Accounts with a tokens per minute limit", []selection.Headroom{})
+
+	b.Operation(http.MethodGet, "/api/v1/providers/capabilities", "listCapabilityProbes", "Features verified by capability probes", "providers").
+		JSON(http.StatusOK, "Latest probe result of each probed provider", []*probe.Result{}).
+		Status(http.StatusNotFound, "CAPABILITY_PROBE is not enabled")
+
+	b.Operation(http.MethodPost, "/api/v1/providers/{id}/probe", "probeProvider", "Probe a provider's features now", "providers").
+		JSON(http.StatusOK, "Probe result, also used for routing", probe.Result{}).
+		Status(http.StatusBadRequest, "The provider has no models to probe").
+		Status(http.StatusNotFound, "Unknown provider, or CAPABILITY_PROBE is not enabled")
+
+	b.Operation(http.MethodGet, "/api/v1/metrics", "getMetrics", "System and cluster metrics", "system").
+		JSON(http.StatusOK, "Metrics snapshot", anyObject)
+
+	b.Operation(http.MethodGet, "/api/v1/artifacts/{key}", "getArtifact", "Download an image or audio a response linked to; the signed URL needs no API key", "system").
+		Query("expires", "integer", "Unix time the URL expires at").
+		Query("signature", "string", "Signature of the URL").
+		Content(http.StatusOK, "Artifact in its stored content type", "application/octet-stream").
+		Status(http.StatusForbidden, "The signature is not valid").
+		Status(http.StatusNotFound, "Unknown artifact, or ARTIFACT_STORE is not set").
+		Status(http.StatusGone, "The URL expired")
+
+	b.Operation(http.MethodGet, "/api/v1/speculative/stats", "getSpeculativeStats", "How often speculative drafts needed verification and what they saved", "system").
+		JSON(http.StatusOK, "Totals since the server started", speculative.Stats{})
+
+	b.Operation(http.MethodGet, "/api/v1/safety/escalations", "getSafetyEscalations", "How often responses blocked by the safety filter were retried on an official provider and rescued", "system").
+		JSON(http.StatusOK, "Totals since the server started", safety.EscalationStats{})
+
+	b.Operation(http.MethodGet, "/admin/debug/pprof/", "getProfileIndex", "Runtime profiles available; ADMIN_PPROF=true only", "admin").
+		Content(http.StatusOK, "Profile index", "text/html").
+		Status(http.StatusForbidden, "The caller's role is not admin")
+
+	b.Operation(http.MethodGet, "/admin/debug/pprof/cmdline", "getProfileCmdline", "Command line of the server process", "admin").
+		Content(http.StatusOK, "Arguments separated by NUL bytes", "text/plain").
+		Status(http.StatusForbidden, "The caller's role is not admin")
+
+	b.Operation(http.MethodGet, "/admin/debug/pprof/profile", "getCPUProfile", "CPU profile of the server", "admin").
+		Query("seconds", "integer", "How long to profile, 30 by default").
+		Content(http.StatusOK, "pprof profile", "application/octet-stream").
+		Status(http.StatusForbidden, "The caller's role is not admin")
+
+	b.Operation(http.MethodGet, "/admin/debug/pprof/symbol", "getProfileSymbols", "Whether symbol lookup is available", "admin").
+		Content(http.StatusOK, "Symbol count", "text/plain").
+		Status(http.StatusForbidden, "The caller's role is not admin")
+
+	b.Operation(http.MethodPost, "/admin/debug/pprof/symbol", "lookupProfileSymbols", "Function names of program counters", "admin").
+		Content(http.StatusOK, "Program counters with their function names", "text/plain").
+		Status(http.StatusForbidden, "The caller's role is not admin")
+
+	b.Operation(http.MethodGet, "/admin/debug/pprof/trace", "getExecutionTrace", "Execution trace of the server", "admin").
+		Query("seconds", "integer", "How long to trace, 1 by default").
+		Content(http.StatusOK, "Execution trace", "application/octet-stream").
+		Status(http.StatusForbidden, "The caller's role is not admin")
+
+	b.Operation(http.MethodGet, "/admin/debug/pprof/{profile}", "getProfile", "Named runtime profile, such as heap, goroutine, allocs, block or mutex", "admin").
+		Query("debug", "integer", "Answer in text instead of the pprof format when 1 or 2").
+		Query("gc", "integer", "Run a garbage collection before a heap profile when 1").
+		Content(http.StatusOK, "pprof profile", "application/octet-stream").
+		Status(http.StatusForbidden, "The caller's role is not admin").
+		Status(http.StatusNotFound, "Unknown profile")
+
+	b.Operation(http.MethodGet, "/admin/log-level", "getLogLevels", "Effective log level per module", "admin").
+		JSON(http.StatusOK, "Module levels", logLevels)
+
+	b.Operation(http.MethodPut, "/admin/log-level", "setLogLevel", "Change the log level of a module", "admin").
+		JSONBody(admin.LogLevelUpdate{}).
+		JSON(http.StatusOK, "Module levels after the change", logLevels).
+		Status(http.StatusBadRequest, "Unknown level or invalid body")
+
+	b.Operation(http.MethodGet, "/admin/model-aliases", "getModelAliases", "Model alias table and alias usage", "admin").
+		JSON(http.StatusOK, "Aliases and how often each was requested", admin.ModelAliasReport{})
+
+	b.Operation(http.MethodGet, "/admin/metrics/system", "getAnalyticsSystemMetrics", "Request totals, success rate, latency and cost over the retained history", "admin").
+		JSON(http.StatusOK, "Aggregated request metrics", anyObject)
+
+	b.Operation(http.MethodGet, "/admin/metrics/provider/{id}", "getAnalyticsProviderMetrics", "Aggregated request metrics of one provider", "admin").
+		JSON(http.StatusOK, "Aggregated request metrics", anyObject)
+
+	b.Operation(http.MethodGet, "/admin/analytics/cost", "getCostAnalysis", "Cost by provider, model and tag over the last hours (query: hours, default 24)", "admin").
+		Query("tag", "string", "Only requests tagged key:value; repeat to require several tags").
+		JSON(http.StatusOK, "Cost breakdown, cumulative trend and savings opportunities", analytics.CostAnalysis{}).
+		Status(http.StatusBadRequest, "Invalid tag filter")
+
+	b.Operation(http.MethodGet, "/admin/analytics/performance", "getProviderPerformance", "Per-provider success rate, latency and cost", "admin").
+		JSON(http.StatusOK, "Providers, busiest first", []analytics.ProviderPerformance{})
+
+	b.Operation(http.MethodGet, "/admin/analytics/safety", "getSafetyReport", "Safety classification of responses over the last hours (query: hours, default 24; tenant)", "admin").
+		JSON(http.StatusOK, "Responses by action and detections by category", analytics.SafetyReport{})
+
+	b.Operation(http.MethodGet, "/admin/analytics/tags/{key}", "getTagBreakdown", "Requests, tokens, cost and latency by value of one request tag over the last hours (query: hours, default 24)", "admin").
+		Query("tag", "string", "Only requests tagged key:value; repeat to require several tags").
+		JSON(http.StatusOK, "Tag values, costliest first; an empty value covers untagged requests", analytics.TagBreakdown{}).
+		Status(http.StatusBadRequest, "Invalid tag filter")
+
+	b.Operation(http.MethodGet, "/admin/insights", "getInsights", "Data-driven recommendations over the last hours (query: hours, default 24; summarize=true adds an assistant summary)", "admin").
+		JSON(http.StatusOK, "Cost curves, failure clusters and recommendations", analytics.Insights{})
+
+	b.Operation(http.MethodGet, "/admin/health", "getAdminHealth", "System health, uptime and dependency checks", "admin").
+		JSON(http.StatusOK, "Health status", anyObject).
+		JSON(http.StatusServiceUnavailable, "A dependency check failed", anyObject)
+
+	b.Operation(http.MethodGet, "/admin/reports", "listReports", "Stored traffic reports, newest first", "admin").
+		JSON(http.StatusOK, "Report schedule and stored reports", admin.ReportList{})
+
+	b.Operation(http.MethodPost, "/admin/reports", "generateReport", "Generate a report for the period ending now (query: period=daily|weekly, deliver=true)", "admin").
+		JSON(http.StatusCreated, "The generated report", reporting.Report{}).
+		Status(http.StatusBadRequest, "Unknown period")
+
+	b.Operation(http.MethodGet, "/admin/reports/{id}", "downloadReport", "Export a stored report (query: format=json|csv|html)", "admin").
+		JSON(http.StatusOK, "The report in the requested format", reporting.Report{}).
+		Status(http.StatusBadRequest, "Unknown format").
+		Status(http.StatusNotFound, "No report with this ID")
+
+	b.Operation(http.MethodGet, "/admin/sla", "getSLA", "Provider SLA targets and the months with a scorecard", "admin").
+		JSON(http.StatusOK, "Targets and scored months, newest first", admin.SLAStatus{})
+
+	b.Operation(http.MethodGet, "/admin/sla/scorecards/{month}", "getSLAScorecard", "Availability, p95 latency and error rate of each targeted provider over a UTC calendar month (YYYY-MM), to date for the current month", "admin").
+		JSON(http.StatusOK, "The month's scorecard with the targets each provider breached", sla.Scorecard{}).
+		Status(http.StatusBadRequest, "Not a YYYY-MM month")
+
+	b.Operation(http.MethodGet, "/admin/incidents", "listIncidents", "Provider outages, newest first: when each provider turned unhealthy and recovered, the requests it served meanwhile and the failovers around it", "admin").
+		Query("provider", "string", "Only incidents of this provider").
+		Query("status", "string", "open or resolved").
+		Query("since", "string", "Only incidents still open or resolved at or after this RFC 3339 time").
+		JSON(http.StatusOK, "Incidents without their timelines", admin.IncidentList{}).
+		Status(http.StatusBadRequest, "Invalid status or since")
+
+	b.Operation(http.MethodGet, "/admin/incidents/{id}", "getIncident", "One provider outage with its timeline of failed requests and failovers", "admin").
+		JSON(http.StatusOK, "The incident and its timeline", incident.Incident{}).
+		Status(http.StatusNotFound, "No incident with this ID")
+
+	b.Operation(http.MethodGet, "/admin/credentials", "listProviderCredentials", "Fingerprints of each provider's active, staged and last retired API keys", "admin").
+		JSON(http.StatusOK, "Key status of every provider", []credentials.Status{})
+
+	b.Operation(http.MethodGet, "/admin/credentials/{provider}", "getProviderCredential", "Fingerprints of a provider's active, staged and last retired API keys", "admin").
+		JSON(http.StatusOK, "Key status of the provider", credentials.Status{}).
+		Status(http.StatusNotFound, "Unknown provider")
+
+	b.Operation(http.MethodPut, "/admin/credentials/{provider}/staged", "stageProviderKey", "Stage a new API key for a provider; traffic keeps using the active key until it is promoted", "admin").
+		JSONBody(admin.StageKeyRequest{}).
+		JSON(http.StatusOK, "Key status with the staged key pending validation", credentials.Status{}).
+		Status(http.StatusBadRequest, "No api_key given").
+		Status(http.StatusNotFound, "Unknown provider")
+
+	b.Operation(http.MethodDelete, "/admin/credentials/{provider}/staged", "discardProviderKey", "Discard a provider's staged API key", "admin").
+		Status(http.StatusNoContent, "Discarded").
+		Status(http.StatusNotFound, "Unknown provider or no staged key")
+
+	b.Operation(http.MethodPost, "/admin/credentials/{provider}/validate", "validateProviderKey", "Probe a provider with its staged API key; only a key the provider answers can be promoted", "admin").
+		JSON(http.StatusOK, "The probe result and whether the staged key passed", admin.KeyValidation{}).
+		Status(http.StatusBadRequest, "The provider has no models to probe").
+		Status(http.StatusNotFound, "Unknown provider").
+		Status(http.StatusConflict, "No key is staged")
+
+	b.Operation(http.MethodPost, "/admin/credentials/{provider}/promote", "promoteProviderKey", "Switch a provider's traffic to its validated staged API key and retire the active one", "admin").
+		JSON(http.StatusOK, "Key status after the switch", credentials.Status{}).
+		Status(http.StatusNotFound, "Unknown provider").
+		Status(http.StatusConflict, "No key is staged, or it has not passed validation")
+
+	b.Operation(http.MethodGet, "/admin/flags", "listFeatureFlags", "Feature flags of experimental behaviors with their global, tenant and key overrides", "admin").
+		JSON(http.StatusOK, "Every flag", []flags.Status{})
+
+	b.Operation(http.MethodGet, "/admin/flags/evaluate", "evaluateFeatureFlags", "The feature flags a request would see and the scope that decided each", "admin").
+		Query("tenant", "string", "Tenant ID of the request").
+		Query("key_id", "string", "Key ID of the request's API key").
+		JSON(http.StatusOK, "Evaluation of every flag, by name", flags.Evaluations{})
+
+	b.Operation(http.MethodGet, "/admin/flags/{flag}", "getFeatureFlag", "One feature flag with its overrides", "admin").
+		JSON(http.StatusOK, "The flag", flags.Status{}).
+		Status(http.StatusNotFound, "Unknown flag")
+
+	b.Operation(http.MethodPut, "/admin/flags/{flag}", "setFeatureFlag", "Override a feature flag globally, for a tenant or for an API key", "admin").
+		JSONBody(admin.FlagOverride{}).
+		JSON(http.StatusOK, "The flag with the new override", flags.Status{}).
+		Status(http.StatusBadRequest, "Invalid scope or target, or no enabled given").
+		Status(http.StatusNotFound, "Unknown flag")
+
+	b.Operation(http.MethodDelete, "/admin/flags/{flag}", "clearFeatureFlag", "Remove an override of a feature flag so the next broader scope decides", "admin").
+		Query("scope", "string", "global, tenant or key").
+		Query("target", "string", "Tenant ID or key ID of a tenant or key override").
+		JSON(http.StatusOK, "The flag without the override", flags.Status{}).
+		Status(http.StatusBadRequest, "Invalid scope or target").
+		Status(http.StatusNotFound, "Unknown flag or override not set")
+
+	b.Operation(http.MethodGet, "/admin/consistency", "checkConsistency", "Cross-check the provider CSV, the provider YAML files and the loaded providers", "admin").
+		JSON(http.StatusOK, "Drift between the three sources", config.ConsistencyReport{})
+
+	b.Operation(http.MethodPost, "/admin/consistency/reconcile", "reconcileConsistency", "Archive orphaned YAML files and regenerate missing or stale ones", "admin").
+		JSON(http.StatusOK, "Actions taken and the drift that remains", config.ReconcileResult{}).
+		Status(http.StatusConflict, "PROVIDER_YAML_DIR is not set")
+
+	b.Operation(http.MethodPost, "/admin/providers/onboard", "proposeProvider", "Detect the API of a provider from its base URL, discover and probe its models, and propose its CSV row and YAML file", "admin").
+		JSONBody(onboard.Request{}).
+		JSON(http.StatusOK, "Proposal awaiting confirmation", onboard.Proposal{}).
+		Status(http.StatusBadRequest, "Invalid base URL, name or tier").
+		Status(http.StatusConflict, "A provider with this name is already configured").
+		Status(http.StatusUnprocessableEntity, "The provider rejected the API key").
+		Status(http.StatusBadGateway, "The provider could not be reached")
+
+	b.Operation(http.MethodPost, "/admin/providers/onboard/{id}/confirm", "confirmProvider", "Add a proposed provider to the provider CSV and YAML directory", "admin").
+		JSON(http.StatusCreated, "Proposal and the configuration revisions written", onboard.Commit{}).
+		Status(http.StatusNotFound, "No pending proposal with this ID").
+		Status(http.StatusConflict, "A provider with this name was configured meanwhile")
+
+	b.Operation(http.MethodDelete, "/admin/providers/onboard/{id}", "discardProvider", "Discard a proposed provider", "admin").
+		Status(http.StatusNoContent, "Discarded").
+		Status(http.StatusNotFound, "No pending proposal with this ID")
+
+	b.Operation(http.MethodGet, "/admin/retention", "getRetention", "Retention policy of stored request data and what the last purge removed", "admin").
+		JSON(http.StatusOK, "Policy and last purge", admin.RetentionStatus{})
+
+	b.Operation(http.MethodPost, "/admin/erasures", "eraseData", "Erase the stored prompts, responses and analytics records of one key_id, session_id or request_id", "admin").
+		JSONBody(retention.Selector{}).
+		JSON(http.StatusOK, "Erasure receipt with the records erased per store", retention.Receipt{}).
+		Status(http.StatusBadRequest, "Not exactly one of key_id, session_id or request_id")
+
+	b.Operation(http.MethodGet, "/admin/learned-state", "exportLearnedState", "Snapshot of the provider performance, token calibration and optimizer statistics learned at runtime", "admin").
+		JSON(http.StatusOK, "Learned state, importable into another environment", learned.State{})
+
+	b.Operation(http.MethodPut, "/admin/learned-state", "importLearnedState", "Restore learned state exported by this or another environment", "admin").
+		JSONBody(learned.State{}).
+		JSON(http.StatusOK, "What was restored and which unknown providers were skipped", learned.ImportReport{}).
+		Status(http.StatusBadRequest, "Malformed state or unsupported version")
+
+	b.Operation(http.MethodPost, "/admin/simulate", "simulateRouting", "Replay the request history through proposed weights, routing policies or providers and compare the projected cost, latency and tier mix with what happened. Nothing is applied", "admin").
+		JSONBody(simulation.Proposal{}).
+		JSON(http.StatusOK, "Actual and projected outcomes of the replayed requests", simulation.Report{}).
+		Status(http.StatusBadRequest, "Malformed proposal, invalid routing policy or providers CSV")
+
+	b.Operation(http.MethodPost, "/admin/reload", "reloadConfig", "Re-read the server config, routing policies and virtual models, applying them only when every section is valid", "admin").
+		JSON(http.StatusOK, "Which sections changed and which changes need a restart", reload.Report{}).
+		JSON(http.StatusUnprocessableEntity, "A section is invalid and nothing was applied", reload.Report{})
+
+	b.Operation(http.MethodGet, "/admin/reload", "getLastReload", "Report of the latest reload, by SIGHUP or through the API", "admin").
+		JSON(http.StatusOK, "Latest reload report", reload.Report{}).
+		Status(http.StatusNotFound, "No reload has run yet")
+
+	b.Operation(http.MethodGet, "/admin/capabilities", "listCapabilityVerifications", "When each probed provider's features were last verified, whether they are due to be probed again and what changed since they were verified before", "admin").
+		JSON(http.StatusOK, "Verification of each probed provider", []probe.Verification{})
+
+	b.Operation(http.MethodPost, "/admin/capabilities/{provider}/acknowledge", "acknowledgeCapabilityChanges", "Clear the capability changes flagged on a provider", "admin").
+		Status(http.StatusNoContent, "Acknowledged").
+		Status(http.StatusNotFound, "The provider has not been probed")
+
+	b.Operation(http.MethodGet, "/admin/audit", "listAuditEvents", "Admin actions that changed something, newest first, with who made them and whether they succeeded", "admin").
+		Query("actor", "string", "Only events of this key ID").
+		Query("action", "string", "Only this method and route, e.g. PUT /admin/tenants/{id}").
+		Query("resource", "string", "Only events on this path or below it").
+		Query("since", "string", "Only events at or after this RFC 3339 time").
+		Query("until", "string", "Only events before this RFC 3339 time").
+		Query("success", "boolean", "Only successful or only failed events").
+		Query("limit", "integer", "Page size, 100 by default and at most 1000").
+		Query("cursor", "string", "next_cursor of the previous page").
+		JSON(http.StatusOK, "One page of audit events", audit.Page{}).
+		Status(http.StatusBadRequest, "Invalid filter or cursor")
+
+	b.Operation(http.MethodGet, "/admin/audit/export", "exportAuditEvents", "Download every audit event matching the /admin/audit filters", "admin").
+		Query("format", "string", "csv (default) or json").
+		Content(http.StatusOK, "Audit events as CSV", "text/csv").
+		Status(http.StatusBadRequest, "Invalid filter or format")
+
+	b.Operation(http.MethodGet, "/admin/sso/login", "startAdminSSO", "Redirect an operator to the identity provider to sign in to the admin API (needs admin.oidc in the server config)", "admin").
+		Query("return_to", "string", "Gateway path to return to after signing in").
+		Status(http.StatusFound, "Redirect to the identity provider")
+
+	b.Operation(http.MethodGet, "/admin/sso/callback", "finishAdminSSO", "Where the identity provider returns the operator; starts the session and redirects to return_to", "admin").
+		Query("code", "string", "Authorization code").
+		Query("state", "string", "State of the login").
+		Status(http.StatusFound, "Signed in; redirect to return_to").
+		Status(http.StatusBadRequest, "Login expired or state mismatch").
+		Status(http.StatusUnauthorized, "Sign-in failed").
+		Status(http.StatusForbidden, "No role is granted to the operator's groups")
+
+	b.Operation(http.MethodPost, "/admin/sso/logout", "endAdminSSO", "End the operator's session", "admin").
+		Status(http.StatusNoContent, "Signed out")
+
+	b.Operation(http.MethodGet, "/admin/sso/session", "getAdminSSOSession", "The signed-in operator and their role", "admin").
+		JSON(http.StatusOK, "Session", admin.Session{}).
+		Status(http.StatusUnauthorized, "Not signed in")
+
+	b.Operation(http.MethodPost, "/admin/history/rewrap", "rewrapRequestHistory", "Rewrap the encrypted request history under the current keys after a key rotation", "admin").
+		JSON(http.StatusOK, "Number of stored requests rewrapped", admin.RewrapResult{}).
+		Status(http.StatusConflict, "Request history is not encrypted")
+
+	b.Operation(http.MethodGet, "/admin/config/revisions", "listConfigRevisions", "Recorded revisions of the configuration files, newest first (query: file)", "admin").
+		JSON(http.StatusOK, "Revisions", []config.Revision{})
+
+	b.Operation(http.MethodGet, "/admin/config/revisions/{id}", "getConfigRevision", "One revision with the content it recorded", "admin").
+		JSON(http.StatusOK, "Revision and content", admin.RevisionContent{}).
+		Status(http.StatusBadRequest, "Invalid revision ID").
+		Status(http.StatusNotFound, "No revision with this ID")
+
+	b.Operation(http.MethodGet, "/admin/config/revisions/{id}/diff", "diffConfigRevision", "Unified diff to a revision (query: against, default the previous revision of the file)", "admin").
+		Content(http.StatusOK, "Unified diff, empty when the contents are equal", "text/x-diff").
+		Status(http.StatusBadRequest, "Invalid revision ID").
+		Status(http.StatusNotFound, "No revision with this ID")
+
+	b.Operation(http.MethodPost, "/admin/config/revisions/{id}/rollback", "rollbackConfigRevision", "Restore the content of a revision, recorded as a new revision", "admin").
+		JSON(http.StatusCreated, "The revision recording the rollback", config.Revision{}).
+		Status(http.StatusBadRequest, "Invalid revision ID").
+		Status(http.StatusNotFound, "No revision with this ID").
+		Status(http.StatusConflict, "The file is no longer tracked")
+
+	b.Operation(http.MethodPost, "/admin/config/snapshot", "snapshotConfig", "Record edits made to the configuration files outside the gateway (optional body: {\"message\": ...})", "admin").
+		JSON(http.StatusOK, "Revisions recorded, empty when nothing changed", []config.Revision{})
+
+	b.Operation(http.MethodGet, "/admin/config/bundle", "exportConfigBundle", "Export the tracked configuration files as a signed tarball", "admin").
+		Content(http.StatusOK, "Gzipped tarball with a signed manifest", "application/gzip").
+		Status(http.StatusNotFound, "BUNDLE_SIGNING_KEY is not set")
+
+	b.Operation(http.MethodPost, "/admin/config/bundle", "importConfigBundle", "Verify and import a signed configuration bundle (query: dry_run=true)", "admin").
+		JSON(http.StatusOK, "Manifest and the effect on each file", admin.BundleImport{}).
+		Status(http.StatusForbidden, "The bundle is not signed by a trusted key").
+		Status(http.StatusNotFound, "BUNDLE_TRUSTED_KEYS is not set").
+		Status(http.StatusConflict, "The bundle holds files this instance does not track").
+		Status(http.StatusUnprocessableEntity, "The bundle is malformed or its files do not match the manifest")
+
+	b.Operation(http.MethodGet, "/admin/tenants", "listTenants", "List tenants", "admin").
+		JSON(http.StatusOK, "Tenants by ID", []tenant.Tenant{}).
+		Status(http.StatusForbidden, "The API key is not in TENANT_ADMIN_KEYS")
+
+	b.Operation(http.MethodPost, "/admin/tenants", "createTenant", "Create a tenant", "admin").
+		JSONBody(admin.TenantRequest{}).
+		JSON(http.StatusCreated, "Created tenant", tenant.Tenant{}).
+		Status(http.StatusBadRequest, "Invalid tenant ID or spec").
+		Status(http.StatusForbidden, "The API key is not in TENANT_ADMIN_KEYS").
+		Status(http.StatusConflict, "A tenant with this ID exists")
+
+	b.Operation(http.MethodGet, "/admin/tenants/{id}", "getTenant", "Get a tenant", "admin").
+		JSON(http.StatusOK, "Tenant", tenant.Tenant{}).
+		Status(http.StatusForbidden, "The API key is not in TENANT_ADMIN_KEYS").
+		Status(http.StatusNotFound, "Unknown tenant")
+
+	b.Operation(http.MethodPut, "/admin/tenants/{id}", "updateTenant", "Replace a tenant's providers, keys, budget and state", "admin").
+		JSONBody(tenant.Spec{}).
+		JSON(http.StatusOK, "Updated tenant", tenant.Tenant{}).
+		Status(http.StatusBadRequest, "Invalid spec").
+		Status(http.StatusForbidden, "The API key is not in TENANT_ADMIN_KEYS").
+		Status(http.StatusNotFound, "Unknown tenant")
+
+	b.Operation(http.MethodDelete, "/admin/tenants/{id}", "deleteTenant", "Delete a tenant and unbind its keys", "admin").
+		Status(http.StatusNoContent, "Deleted").
+		Status(http.StatusForbidden, "The API key is not in TENANT_ADMIN_KEYS").
+		Status(http.StatusNotFound, "Unknown tenant")
+
+	b.Operation(http.MethodGet, "/admin/tenants/{id}/usage", "getTenantUsage", "Get a tenant's spend and analytics this month", "admin").
+		JSON(http.StatusOK, "Spend against the monthly budget and request metrics", admin.TenantUsage{}).
+		Status(http.StatusForbidden, "The API key is not in TENANT_ADMIN_KEYS").
+		Status(http.StatusNotFound, "Unknown tenant")
+
+	b.Operation(http.MethodGet, "/admin/environments", "listEnvironments", "Routing profiles of the key environments (needs environments in the server config)", "admin").
+		JSON(http.StatusOK, "Profiles by environment name", []admin.EnvironmentProfile{})
+
+	b.Operation(http.MethodGet, "/admin/environments/{name}/usage", "getEnvironmentUsage", "Get a key environment's spend and analytics this month", "admin").
+		JSON(http.StatusOK, "Spend against the monthly budget and request metrics", admin.EnvironmentUsage{}).
+		Status(http.StatusNotFound, "Unknown environment")
+
+	b.Operation(http.MethodGet, "/openapi.json", "getOpenAPI", "This document", "system").
+		JSON(http.StatusOK, "OpenAPI 3 document", anyObject)
+
+	return b.Document()
+}
+
+// openAPIHandler serves a pre-rendered OpenAPI document
+func openAPIHandler(doc *openapi.Document) http.HandlerFunc {
+	body, err := json.MarshalIndent(doc, "", "  ")
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			http.Error(w, "Failed to render OpenAPI document", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}
+}
+
+// checkOpenAPICoverage warns about routes that are served but not documented
+func checkOpenAPICoverage(router *mux.Router, doc *openapi.Document, logger *logrus.Logger) {
+	var routes []openapi.Route
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		path = stripPathPatterns(path)
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			routes = append(routes, openapi.Route{Method: method, Path: path})
+		}
+		return nil
+	})
+
+	for _, missing := range doc.Undocumented(routes) {
+		logger.Warnf("Route %s is missing from the OpenAPI document", missing)
+	}
+}
+
+// stripPathPatterns removes the patterns of path variables, turning
+// /v1/models/{id:.+} into the documented /v1/models/{id}
+func stripPathPatterns(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if name, _, found := strings.Cut(segment, ":"); found && strings.HasPrefix(segment, "{") {
+			segments[i] = name + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
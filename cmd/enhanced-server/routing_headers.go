@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/internal/enhanced"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/middleware"
+)
+
+// setRoutingHeaders copies the routing outcome of result into X-PalMoE-*
+// response headers. It must run before the status is written
+func setRoutingHeaders(w http.ResponseWriter, result *enhanced.ProcessResponse) {
+	header := w.Header()
+	if result.Provider != nil {
+		header.Set(middleware.ProviderHeader, result.Provider.Name)
+		header.Set(middleware.TierHeader, string(result.Provider.Tier))
+	}
+	if result.Model != "" {
+		header.Set(middleware.ModelHeader, result.Model)
+	}
+	if score, ok := result.Metadata["selection_score"].(float64); ok {
+		header.Set(middleware.ScoreHeader, strconv.FormatFloat(score, 'f', 4, 64))
+	}
+	header.Set(middleware.CacheHeader, middleware.CacheMiss)
+	retries := 0
+	if speculative, ok := result.Metadata["speculative"].(enhanced.SpeculativeResult); ok && speculative.Verified {
+		retries++
+	}
+	header.Set(middleware.RetriesHeader, strconv.Itoa(retries))
+	header.Set(middleware.CostHeader, strconv.FormatFloat(result.Cost, 'f', -1, 64))
+}
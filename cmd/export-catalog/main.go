@@ -0,0 +1,109 @@
+// Command export-catalog renders the provider inventory, routing policies and
+// model aliases in the config format of another gateway, so a configuration
+// can move to LiteLLM or one-api and back with import-catalog. Routing
+// policies depend on conditions neither gateway has, so only the one named by
+// -apply is exported, as a fixed ranking. Whatever the target cannot express
+// is reported as a note.
+//
+//	export-catalog litellm > litellm_config.yaml
+//	export-catalog oneapi -csv providers.csv -policies routing.yaml -apply peak -out channels.json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/catalog"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/selection"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: export-catalog litellm|oneapi [flags]")
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "litellm":
+		err = run(os.Args[1], os.Args[2:], catalog.ExportLiteLLM)
+	case "oneapi":
+		err = run(os.Args[1], os.Args[2:], catalog.ExportOneAPI)
+	default:
+		err = fmt.Errorf("unknown format %q", os.Args[1])
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "export-catalog: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run loads the configuration the server would and writes it with export
+func run(name string, args []string, export func(io.Writer, catalog.Inventory) ([]string, error)) error {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	csvPath := flags.String("csv", envOr("PROVIDERS_CSV", "providers.csv"), "providers CSV to export")
+	policiesPath := flags.String("policies", os.Getenv("ROUTING_POLICIES_PATH"), "routing policies file")
+	apply := flags.String("apply", "", "routing policy to rank the providers by, as if it applied to every request")
+	aliasesPath := flags.String("aliases", os.Getenv("MODEL_ALIASES_PATH"), "model aliases file; the built-in aliases are always exported")
+	out := flags.String("out", "-", "file to write, or - for stdout")
+	flags.Parse(args)
+
+	inv := catalog.Inventory{Apply: *apply}
+	file, err := os.Open(*csvPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	parsed, err := config.ReadProviderCSVTemplate(file)
+	if err != nil {
+		return fmt.Errorf("%s: %w", *csvPath, err)
+	}
+	inv.Providers = parsed.Providers
+
+	if *policiesPath != "" {
+		policies, err := selection.LoadRoutingPolicies(*policiesPath)
+		if err != nil {
+			return err
+		}
+		inv.Policies = policies.Policies()
+	}
+	aliases := selection.DefaultModelAliases()
+	if *aliasesPath != "" {
+		if aliases, err = selection.LoadModelAliases(*aliasesPath); err != nil {
+			return err
+		}
+	}
+	inv.Aliases = aliases.Aliases()
+
+	w := io.Writer(os.Stdout)
+	if *out != "-" {
+		outFile, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer outFile.Close()
+		w = outFile
+	}
+	notes, err := export(w, inv)
+	for _, note := range notes {
+		fmt.Fprintf(os.Stderr, "note: %s\n", note)
+	}
+	if err != nil {
+		return err
+	}
+	if *out != "-" {
+		fmt.Printf("Wrote %s\n", *out)
+	}
+	return nil
+}
+
+// envOr returns the value of variable, or fallback when it is unset
+func envOr(variable, fallback string) string {
+	if value := os.Getenv(variable); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,133 @@
+// Command import-catalog seeds providers.csv from a public model catalog. Rows
+// are appended, so existing providers and ${VAR} references are kept as
+// written; rows whose name is already taken are skipped.
+//
+//	import-catalog openrouter                                # fetch the live OpenRouter list
+//	import-catalog openrouter -in models.json -per-model     # one row per model
+//	import-catalog litellm -in litellm_config.yaml -csv providers.csv
+//	import-catalog litellm -in litellm_config.yaml -out -    # print the rows instead
+//	import-catalog oneapi -in channels.json
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/catalog"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: import-catalog openrouter|litellm|oneapi [flags]")
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "openrouter":
+		err = run(os.Args[1], os.Args[2:], catalog.OpenRouterCatalogURL, "OpenRouter", catalog.ParseOpenRouter)
+	case "litellm":
+		err = run(os.Args[1], os.Args[2:], "litellm_config.yaml", "", catalog.ParseLiteLLM)
+	case "oneapi":
+		err = run(os.Args[1], os.Args[2:], "channels.json", "", catalog.ParseOneAPI)
+	default:
+		err = fmt.Errorf("unknown catalog %q", os.Args[1])
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import-catalog: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run imports the catalog read by parse; source prefixes the row names
+func run(name string, args []string, defaultIn, source string, parse func(io.Reader) ([]catalog.Model, []string, error)) error {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	in := flags.String("in", defaultIn, "catalog file, or an http(s) URL to fetch it from")
+	csvPath := flags.String("csv", "providers.csv", "providers CSV to append the rows to")
+	out := flags.String("out", "", "- prints the rows as a CSV instead of appending them")
+	perModel := flags.Bool("per-model", false, "write one row per model instead of one per vendor")
+	tierName := flags.String("tier", string(tier.Official), "tier of the rows of paid models; free models are community")
+	flags.Parse(args)
+
+	rowTier, err := tier.Parse(*tierName)
+	if err != nil {
+		return err
+	}
+	data, err := read(*in)
+	if err != nil {
+		return err
+	}
+	models, notes, err := parse(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for _, note := range notes {
+		fmt.Fprintf(os.Stderr, "note: %s\n", note)
+	}
+	rows := catalog.Rows(models, catalog.Options{Source: source, Tier: rowTier, PerModel: *perModel})
+
+	if *out == "-" {
+		return config.WriteProviderCSV(os.Stdout, rows)
+	}
+
+	content, err := os.ReadFile(*csvPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	original := content
+	added := 0
+	for _, row := range rows {
+		updated, err := config.AppendProviderCSV(content, row)
+		if errors.Is(err, config.ErrProviderExists) {
+			fmt.Fprintf(os.Stderr, "skipped %s: already in %s\n", row.Name, *csvPath)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", *csvPath, err)
+		}
+		content = updated
+		added++
+	}
+	if added == 0 {
+		fmt.Printf("No new providers for %s\n", *csvPath)
+		return nil
+	}
+
+	if original != nil {
+		if err := os.WriteFile(*csvPath+".bak", original, 0644); err != nil {
+			return fmt.Errorf("failed to write backup: %w", err)
+		}
+	}
+	if err := os.WriteFile(*csvPath, content, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Added %d providers to %s\n", added, *csvPath)
+	return nil
+}
+
+// read loads the catalog from a file or URL
+func read(in string) ([]byte, error) {
+	if !strings.HasPrefix(in, "http://") && !strings.HasPrefix(in, "https://") {
+		return os.ReadFile(in)
+	}
+
+	client := &http.Client{Timeout: time.Minute}
+	resp, err := client.Get(in)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %d", in, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 64<<20))
+}
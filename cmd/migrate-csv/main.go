@@ -0,0 +1,70 @@
+// Command migrate-csv rewrites a providers.csv written in an older schema version
+// in the current canonical schema.
+//
+//	migrate-csv -in providers.csv             # rewrite in place, keeping providers.csv.bak
+//	migrate-csv -in providers.csv -out -      # print the migrated file
+//	migrate-csv -in providers.csv -check      # exit 1 when the file needs migrating
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+)
+
+func main() {
+	in := flag.String("in", "providers.csv", "CSV file to migrate")
+	out := flag.String("out", "", "destination file, - for stdout (default: rewrite the input in place)")
+	check := flag.Bool("check", false, "only report whether the file uses the current schema")
+	flag.Parse()
+
+	if err := run(*in, *out, *check); err != nil {
+		fmt.Fprintf(os.Stderr, "migrate-csv: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(in, out string, check bool) error {
+	original, err := os.ReadFile(in)
+	if err != nil {
+		return err
+	}
+
+	var migrated bytes.Buffer
+	version, err := config.MigrateCSV(bytes.NewReader(original), &migrated)
+	if err != nil {
+		return fmt.Errorf("%s: %w", in, err)
+	}
+
+	if check {
+		if version < config.CSVSchemaVersion {
+			return fmt.Errorf("%s uses schema v%d, current is v%d", in, version, config.CSVSchemaVersion)
+		}
+		fmt.Printf("%s uses schema v%d\n", in, version)
+		return nil
+	}
+
+	switch out {
+	case "-":
+		_, err := os.Stdout.Write(migrated.Bytes())
+		return err
+	case "", in:
+		if version == config.CSVSchemaVersion && bytes.Equal(original, migrated.Bytes()) {
+			fmt.Printf("%s already uses schema v%d\n", in, version)
+			return nil
+		}
+		if err := os.WriteFile(in+".bak", original, 0644); err != nil {
+			return fmt.Errorf("failed to write backup: %w", err)
+		}
+		out = in
+	}
+
+	if err := os.WriteFile(out, migrated.Bytes(), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Migrated %s from schema v%d to v%d: %s\n", in, version, config.CSVSchemaVersion, out)
+	return nil
+}
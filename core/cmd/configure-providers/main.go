@@ -0,0 +1,84 @@
+// Command configure-providers generates a YAML configuration per provider
+// listed in a providers CSV, analyzing each provider with an LLM.
+//
+//	configure-providers -csv providers.csv -out configs/generated/providers
+//	configure-providers -offline             # no LLM calls, default analysis only
+//	configure-providers -assistant-url http://localhost:11434/v1 -assistant-model llama3.1
+//	configure-providers -cache ""            # analyze every provider again
+//
+// Analyses are cached by provider fingerprint, so providers whose name, tier,
+// endpoint, authentication and models are unchanged are not analyzed again.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/labring/aiproxy/core/pkg/providers"
+)
+
+func main() {
+	csvPath := flag.String("csv", "providers.csv", "providers CSV file")
+	outputDir := flag.String("out", filepath.Join("configs", "generated", "providers"), "directory the configurations are written to")
+	offline := flag.Bool("offline", false, "skip LLM analysis and use the default analysis for every provider")
+	cachePath := flag.String("cache", "", "analysis cache file, empty to disable (default: <out>/.analysis-cache.json)")
+	cacheTTL := flag.Duration("cache-ttl", 30*24*time.Hour, "how long cached analyses are reused, 0 for forever")
+	assistantURL := flag.String("assistant-url", "", "OpenAI-compatible API used for analysis instead of Pollinations")
+	assistantModel := flag.String("assistant-model", "", "model to use with -assistant-url")
+	assistantKeyEnv := flag.String("assistant-key-env", "", "environment variable holding the -assistant-url API key")
+	flag.Parse()
+
+	if !isFlagSet("cache") {
+		*cachePath = filepath.Join(*outputDir, providers.AnalysisCacheFile)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var analyzer providers.ProviderAnalyzer = providers.NewPollinationsAnalyzer(nil)
+	switch {
+	case *offline:
+		analyzer = nil
+	case *assistantURL != "":
+		if *assistantModel == "" {
+			fmt.Fprintln(os.Stderr, "configure-providers: -assistant-model is required with -assistant-url")
+			os.Exit(2)
+		}
+		client := providers.NewChatCompletionsClient(*assistantURL, *assistantModel, os.Getenv(*assistantKeyEnv))
+		analyzer = providers.NewLLMAnalyzer(*assistantURL+"/"+*assistantModel, client)
+	}
+
+	if err := run(ctx, *csvPath, *outputDir, *cachePath, *cacheTTL, analyzer); err != nil {
+		fmt.Fprintf(os.Stderr, "configure-providers: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, csvPath, outputDir, cachePath string, cacheTTL time.Duration, analyzer providers.ProviderAnalyzer) error {
+	configurator := providers.NewAutoConfigurator(csvPath, outputDir)
+	configurator.SetAnalyzer(analyzer)
+	if analyzer != nil && cachePath != "" {
+		cache, err := providers.LoadAnalysisCache(cachePath, cacheTTL)
+		if err != nil {
+			return err
+		}
+		configurator.SetAnalysisCache(cache)
+	}
+	return configurator.GenerateConfigurations(ctx)
+}
+
+// isFlagSet reports whether name was given on the command line
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
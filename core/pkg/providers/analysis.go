@@ -0,0 +1,261 @@
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labring/aiproxy/core/pkg/pollinations"
+)
+
+// ProviderAnalyzer infers configuration details for a provider, typically by
+// asking an LLM. Name identifies the analyzer in cache keys, so a different
+// analyzer never reuses another's results
+type ProviderAnalyzer interface {
+	Name() string
+	Analyze(ctx context.Context, provider *ProviderConfig, models []string) (*ProviderAnalysis, error)
+}
+
+// ModelExtractor is implemented by analyzers that can also pull model names
+// out of a models endpoint response the built-in parser does not understand
+type ModelExtractor interface {
+	ExtractModels(ctx context.Context, provider *ProviderConfig, data interface{}) ([]string, error)
+}
+
+// AnalysisCacheFile is the conventional cache file name within an output directory
+const AnalysisCacheFile = ".analysis-cache.json"
+
+var errInvalidAnalysis = errors.New("analysis response is not valid JSON")
+
+// TextGenerator is the assistant model an LLMAnalyzer prompts
+type TextGenerator interface {
+	GenerateText(ctx context.Context, prompt string) (string, error)
+}
+
+// LLMAnalyzer analyzes providers by prompting an assistant model
+type LLMAnalyzer struct {
+	name   string
+	client TextGenerator
+}
+
+// NewLLMAnalyzer creates an analyzer named name, which should identify the
+// model (e.g. "ollama/llama3.1") since it is part of the cache key
+func NewLLMAnalyzer(name string, client TextGenerator) *LLMAnalyzer {
+	return &LLMAnalyzer{name: name, client: client}
+}
+
+// NewPollinationsAnalyzer creates an analyzer using the Pollinations text API
+func NewPollinationsAnalyzer(client *pollinations.Client) *LLMAnalyzer {
+	if client == nil {
+		client = pollinations.NewPollinationsClient()
+	}
+	return NewLLMAnalyzer("pollinations", client)
+}
+
+func (p *LLMAnalyzer) Name() string {
+	return p.name
+}
+
+func (p *LLMAnalyzer) Analyze(ctx context.Context, provider *ProviderConfig, models []string) (*ProviderAnalysis, error) {
+	prompt := fmt.Sprintf(`
+Analyze this AI provider and generate configuration details:
+
+Provider Name: %s
+Tier: %s (official=paid/premium, community=free/low-cost, unofficial=reverse-engineered)
+Endpoint: %s
+Available Models: %v
+
+Please analyze and return JSON with:
+{
+    "capabilities": ["text_generation", "image_generation", "audio_generation", "embeddings"],
+    "request_format": "openai|anthropic|huggingface|custom",
+    "response_format": "openai|anthropic|huggingface|custom",
+    "health_check_endpoint": "/health or /models or /",
+    "estimated_costs": {"text_generation": 0.001, "image_generation": 0.02},
+    "quality_scores": {"model_name": 8},
+    "notes": ["Additional configuration notes"]
+}
+
+Consider:
+- Official tier providers typically use bearer token auth and have usage costs
+- Community tier providers are often free or low-cost with simpler auth
+- Unofficial tier providers may use custom auth (cookies, etc.) and are free but unstable
+- Infer capabilities from model names and provider patterns
+- Estimate costs based on tier (official=paid, community=low/free, unofficial=free)
+- Quality scores 1-10 based on model reputation and tier
+`, provider.Name, provider.Tier, provider.Endpoint, models)
+
+	response, err := p.client.GenerateText(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	var analysis ProviderAnalysis
+	if err := json.Unmarshal([]byte(extractJSON(response, '{', '}')), &analysis); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidAnalysis, err)
+	}
+	if len(analysis.Capabilities) == 0 {
+		return nil, fmt.Errorf("%w: no capabilities", errInvalidAnalysis)
+	}
+	return &analysis, nil
+}
+
+func (p *LLMAnalyzer) ExtractModels(ctx context.Context, provider *ProviderConfig, data interface{}) ([]string, error) {
+	prompt := fmt.Sprintf(`
+Extract model names from this API response:
+
+Provider: %s
+Response Data: %v
+
+Return a JSON array of model names found in the response.
+Look for common patterns like "id", "name", "model", etc.
+`, provider.Name, data)
+
+	response, err := p.client.GenerateText(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	var models []string
+	if err := json.Unmarshal([]byte(extractJSON(response, '[', ']')), &models); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidAnalysis, err)
+	}
+	return models, nil
+}
+
+// extractJSON trims an LLM reply, which often wraps JSON in prose or code
+// fences, to the outermost value delimited by first and last
+func extractJSON(response string, first, last byte) string {
+	start := strings.IndexByte(response, first)
+	end := strings.LastIndexByte(response, last)
+	if start < 0 || end < start {
+		return response
+	}
+	return response[start : end+1]
+}
+
+// ProviderFingerprint identifies everything an analysis depends on: the
+// provider's identity, endpoint, authentication, discovered models and the
+// analyzer. A change to any of them invalidates cached analyses
+func ProviderFingerprint(provider *ProviderConfig, models []string, analyzer string) string {
+	sorted := append([]string(nil), models...)
+	sort.Strings(sorted)
+
+	h := sha256.New()
+	for _, part := range []string{
+		analyzer,
+		provider.Name,
+		provider.Tier,
+		provider.Endpoint,
+		provider.Authentication.Type,
+		strings.Join(sorted, "\n"),
+	} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// cachedAnalysis is one stored analysis
+type cachedAnalysis struct {
+	Provider  string           `json:"provider"`
+	Analyzer  string           `json:"analyzer"`
+	Analysis  ProviderAnalysis `json:"analysis"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// AnalysisCache stores provider analyses in a JSON file keyed by
+// ProviderFingerprint, so unchanged providers are not analyzed again
+type AnalysisCache struct {
+	path    string
+	ttl     time.Duration
+	entries map[string]cachedAnalysis
+	dirty   bool
+	mu      sync.Mutex
+}
+
+// LoadAnalysisCache opens the cache at path, starting empty if the file does
+// not exist. Entries older than ttl are ignored; a zero ttl keeps them forever
+func LoadAnalysisCache(path string, ttl time.Duration) (*AnalysisCache, error) {
+	cache := &AnalysisCache{
+		path:    path,
+		ttl:     ttl,
+		entries: make(map[string]cachedAnalysis),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read analysis cache: %w", err)
+	}
+	if err := json.Unmarshal(data, &cache.entries); err != nil {
+		return nil, fmt.Errorf("failed to parse analysis cache %s: %w", path, err)
+	}
+	return cache, nil
+}
+
+func (c *AnalysisCache) Get(fingerprint string) (*ProviderAnalysis, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[fingerprint]
+	if !ok || (c.ttl > 0 && time.Since(entry.CreatedAt) > c.ttl) {
+		return nil, false
+	}
+	analysis := entry.Analysis
+	return &analysis, true
+}
+
+func (c *AnalysisCache) Put(fingerprint, provider, analyzer string, analysis *ProviderAnalysis) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[fingerprint] = cachedAnalysis{
+		Provider:  provider,
+		Analyzer:  analyzer,
+		Analysis:  *analysis,
+		CreatedAt: time.Now(),
+	}
+	c.dirty = true
+}
+
+// Save writes the cache back to disk if anything was added, dropping expired entries
+func (c *AnalysisCache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+	if c.ttl > 0 {
+		for fingerprint, entry := range c.entries {
+			if time.Since(entry.CreatedAt) > c.ttl {
+				delete(c.entries, fingerprint)
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(c.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write analysis cache: %w", err)
+	}
+	c.dirty = false
+	return nil
+}
@@ -0,0 +1,82 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ChatCompletionsClient is a TextGenerator for any OpenAI-compatible chat
+// completions API, including a local Ollama at http://localhost:11434/v1
+type ChatCompletionsClient struct {
+	baseURL    string
+	model      string
+	apiKey     string
+	httpClient *http.Client
+}
+
+func NewChatCompletionsClient(baseURL, model, apiKey string) *ChatCompletionsClient {
+	return &ChatCompletionsClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		model:   model,
+		apiKey:  apiKey,
+		httpClient: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+	}
+}
+
+func (c *ChatCompletionsClient) GenerateText(ctx context.Context, prompt string) (string, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"model":    c.model,
+		"messages": []map[string]string{{"role": "user", "content": prompt}},
+		"stream":   false,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return "", err
+	}
+
+	var completion struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(data, &completion); err != nil {
+		return "", fmt.Errorf("failed to decode chat completion: %w", err)
+	}
+	if len(completion.Choices) == 0 {
+		return "", fmt.Errorf("chat completion has no choices")
+	}
+	return completion.Choices[0].Message.Content, nil
+}
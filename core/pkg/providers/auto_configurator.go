@@ -10,15 +10,16 @@ import (
 	"strings"
 	"time"
 
-	"github.com/labring/aiproxy/core/pkg/pollinations"
+	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
 )
 
 type AutoConfigurator struct {
-	csvParser          *CSVParser
-	pollinationsClient *pollinations.Client
-	outputDir          string
-	httpClient         *http.Client
+	csvParser  *CSVParser
+	analyzer   ProviderAnalyzer
+	cache      *AnalysisCache
+	outputDir  string
+	httpClient *http.Client
 }
 
 type GeneratedConfig struct {
@@ -89,15 +90,26 @@ type ProviderAnalysis struct {
 
 func NewAutoConfigurator(csvPath, outputDir string) *AutoConfigurator {
 	return &AutoConfigurator{
-		csvParser:          NewCSVParser(csvPath),
-		pollinationsClient: pollinations.NewPollinationsClient(),
-		outputDir:          outputDir,
+		csvParser: NewCSVParser(csvPath),
+		analyzer:  NewPollinationsAnalyzer(nil),
+		outputDir: outputDir,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
 	}
 }
 
+// SetAnalyzer replaces the LLM analysis step. With a nil analyzer the
+// configurator runs offline: every provider gets the default analysis
+func (ac *AutoConfigurator) SetAnalyzer(analyzer ProviderAnalyzer) {
+	ac.analyzer = analyzer
+}
+
+// SetAnalysisCache reuses analyses of providers whose fingerprint is unchanged
+func (ac *AutoConfigurator) SetAnalysisCache(cache *AnalysisCache) {
+	ac.cache = cache
+}
+
 func (ac *AutoConfigurator) GenerateConfigurations(ctx context.Context) error {
 	providers, err := ac.csvParser.LoadProviders()
 	if err != nil {
@@ -105,11 +117,11 @@ func (ac *AutoConfigurator) GenerateConfigurations(ctx context.Context) error {
 	}
 
 	for name, provider := range providers {
-		fmt.Printf("Configuring provider: %s\n", name)
+		log.Infof("configuring provider %s", name)
 
 		config, err := ac.generateProviderConfig(ctx, provider)
 		if err != nil {
-			fmt.Printf("Warning: Failed to generate config for %s: %v\n", name, err)
+			log.Warnf("failed to generate config for %s: %v", name, err)
 			continue
 		}
 
@@ -120,11 +132,17 @@ func (ac *AutoConfigurator) GenerateConfigurations(ctx context.Context) error {
 		// Generate script template for unofficial APIs
 		if provider.Tier == "unofficial" && strings.HasPrefix(provider.Endpoint, "./scripts/") {
 			if err := ac.generateScriptTemplate(provider); err != nil {
-				fmt.Printf("Warning: Failed to generate script template for %s: %v\n", name, err)
+				log.Warnf("failed to generate script template for %s: %v", name, err)
 			}
 		}
 	}
 
+	if ac.cache != nil {
+		if err := ac.cache.Save(); err != nil {
+			log.Warnf("failed to save analysis cache: %v", err)
+		}
+	}
+
 	return nil
 }
 
@@ -135,11 +153,7 @@ func (ac *AutoConfigurator) generateProviderConfig(ctx context.Context, provider
 		return nil, fmt.Errorf("failed to discover models: %w", err)
 	}
 
-	// Use Pollinations to analyze the provider
-	analysis, err := ac.analyzeProviderWithPollinations(ctx, provider, models)
-	if err != nil {
-		return nil, fmt.Errorf("failed to analyze provider with Pollinations: %w", err)
-	}
+	analysis := ac.analyzeProvider(ctx, provider, models)
 
 	// Build configuration
 	config := &GeneratedConfig{
@@ -231,10 +245,10 @@ func (ac *AutoConfigurator) fetchModelsFromURL(ctx context.Context, url string,
 	}
 
 	// Parse different response formats
-	return ac.parseModelsResponse(data, provider)
+	return ac.parseModelsResponse(ctx, data, provider)
 }
 
-func (ac *AutoConfigurator) parseModelsResponse(data interface{}, provider *ProviderConfig) ([]string, error) {
+func (ac *AutoConfigurator) parseModelsResponse(ctx context.Context, data interface{}, provider *ProviderConfig) ([]string, error) {
 	var models []string
 
 	switch v := data.(type) {
@@ -266,9 +280,9 @@ func (ac *AutoConfigurator) parseModelsResponse(data interface{}, provider *Prov
 		}
 	}
 
-	// If we couldn't parse automatically, use Pollinations to help
+	// If we couldn't parse automatically, let the analyzer help
 	if len(models) == 0 {
-		return ac.analyzeModelsWithPollinations(data, provider)
+		return ac.extractModelsWithAnalyzer(ctx, data, provider), nil
 	}
 
 	return models, nil
@@ -289,47 +303,34 @@ func (ac *AutoConfigurator) extractModelName(item interface{}) string {
 	return ""
 }
 
-func (ac *AutoConfigurator) analyzeProviderWithPollinations(ctx context.Context, provider *ProviderConfig, models []string) (*ProviderAnalysis, error) {
-	prompt := fmt.Sprintf(`
-Analyze this AI provider and generate configuration details:
-
-Provider Name: %s
-Tier: %s (official=paid/premium, community=free/low-cost, unofficial=reverse-engineered)
-Endpoint: %s
-Available Models: %v
-
-Please analyze and return JSON with:
-{
-    "capabilities": ["text_generation", "image_generation", "audio_generation", "embeddings"],
-    "request_format": "openai|anthropic|huggingface|custom",
-    "response_format": "openai|anthropic|huggingface|custom", 
-    "health_check_endpoint": "/health or /models or /",
-    "estimated_costs": {"text_generation": 0.001, "image_generation": 0.02},
-    "quality_scores": {"model_name": 8},
-    "notes": ["Additional configuration notes"]
-}
+// analyzeProvider runs the analyzer, reusing a cached result for an unchanged
+// provider. Offline, or when the analyzer fails, the default analysis is used
+// and nothing is cached so the next run tries again
+func (ac *AutoConfigurator) analyzeProvider(ctx context.Context, provider *ProviderConfig, models []string) *ProviderAnalysis {
+	if ac.analyzer == nil {
+		analysis := ac.createDefaultAnalysis(provider, models)
+		return &analysis
+	}
 
-Consider:
-- Official tier providers typically use bearer token auth and have usage costs
-- Community tier providers are often free or low-cost with simpler auth
-- Unofficial tier providers may use custom auth (cookies, etc.) and are free but unstable
-- Infer capabilities from model names and provider patterns
-- Estimate costs based on tier (official=paid, community=low/free, unofficial=free)
-- Quality scores 1-10 based on model reputation and tier
-`, provider.Name, provider.Tier, provider.Endpoint, models)
+	fingerprint := ProviderFingerprint(provider, models, ac.analyzer.Name())
+	if ac.cache != nil {
+		if analysis, ok := ac.cache.Get(fingerprint); ok {
+			return analysis
+		}
+	}
 
-	response, err := ac.pollinationsClient.GenerateText(ctx, prompt)
+	analysis, err := ac.analyzer.Analyze(ctx, provider, models)
 	if err != nil {
-		return nil, err
+		log.Warnf("%s analysis failed for %s, using defaults: %v", ac.analyzer.Name(), provider.Name, err)
+		fallback := ac.createDefaultAnalysis(provider, models)
+		fallback.Notes = append(fallback.Notes, fmt.Sprintf("%s analysis unavailable: %v", ac.analyzer.Name(), err))
+		return &fallback
 	}
 
-	var analysis ProviderAnalysis
-	if err := json.Unmarshal([]byte(response), &analysis); err != nil {
-		// If JSON parsing fails, create a reasonable default
-		analysis = ac.createDefaultAnalysis(provider, models)
+	if ac.cache != nil {
+		ac.cache.Put(fingerprint, provider.Name, ac.analyzer.Name(), analysis)
 	}
-
-	return &analysis, nil
+	return analysis
 }
 
 func (ac *AutoConfigurator) createDefaultAnalysis(provider *ProviderConfig, models []string) ProviderAnalysis {
@@ -459,28 +460,19 @@ func (ac *AutoConfigurator) determineProviderType(provider *ProviderConfig) stri
 	return "unknown"
 }
 
-func (ac *AutoConfigurator) analyzeModelsWithPollinations(data interface{}, provider *ProviderConfig) ([]string, error) {
-	prompt := fmt.Sprintf(`
-Extract model names from this API response:
-
-Provider: %s
-Response Data: %v
-
-Return a JSON array of model names found in the response.
-Look for common patterns like "id", "name", "model", etc.
-`, provider.Name, data)
-
-	response, err := ac.pollinationsClient.GenerateText(context.Background(), prompt)
-	if err != nil {
-		return []string{}, nil // Return empty if analysis fails
+// extractModelsWithAnalyzer asks the analyzer for model names in a response
+// the built-in parser did not understand, returning none when it cannot
+func (ac *AutoConfigurator) extractModelsWithAnalyzer(ctx context.Context, data interface{}, provider *ProviderConfig) []string {
+	extractor, ok := ac.analyzer.(ModelExtractor)
+	if !ok {
+		return []string{}
 	}
 
-	var models []string
-	if err := json.Unmarshal([]byte(response), &models); err != nil {
-		return []string{}, nil // Return empty if parsing fails
+	models, err := extractor.ExtractModels(ctx, provider, data)
+	if err != nil {
+		return []string{} // Return empty if analysis fails
 	}
-
-	return models, nil
+	return models
 }
 
 func (ac *AutoConfigurator) generateScriptTemplate(provider *ProviderConfig) error {
@@ -0,0 +1,123 @@
+package providers
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
+)
+
+// Metadata keys holding the authentication settings that the canonical
+// provider model has no fields for
+const (
+	metadataAuthType     = "auth_type"
+	metadataAuthEnvVar   = "auth_env_var"
+	metadataAuthHeader   = "auth_header"
+	metadataAuthRequired = "auth_required"
+)
+
+// ToCanonical converts the provider into the gateway's canonical
+// config.ProviderConfig. The API key becomes a ${VAR} reference to the
+// authentication variable, optional when the credential is not required.
+// Health and LastUpdated are runtime state and are not carried over
+func (p *ProviderConfig) ToCanonical() config.ProviderConfig {
+	source := canonicalModelsSource(p.ModelsSource)
+	canonical := config.ProviderConfig{
+		Name:                 p.Name,
+		Tier:                 tier.Normalize(p.Tier),
+		Endpoint:             p.Endpoint,
+		ModelsSource:         source,
+		Models:               source.List(),
+		DeclaredCapabilities: slices.Clone(p.Capabilities),
+		Metadata: map[string]string{
+			metadataAuthType:     p.Authentication.Type,
+			metadataAuthRequired: strconv.FormatBool(p.Authentication.Required),
+		},
+	}
+	if p.Authentication.EnvVar != "" {
+		canonical.Metadata[metadataAuthEnvVar] = p.Authentication.EnvVar
+		if p.Authentication.Required {
+			canonical.APIKey = "${" + p.Authentication.EnvVar + "}"
+		} else {
+			canonical.APIKey = "${" + p.Authentication.EnvVar + ":-}"
+		}
+	}
+	if p.Authentication.Header != "" {
+		canonical.Metadata[metadataAuthHeader] = p.Authentication.Header
+	}
+	return canonical
+}
+
+// FromCanonical converts a canonical config.ProviderConfig into this
+// package's provider, with unknown health. Authentication comes from the
+// metadata written by ToCanonical; without it an API key referencing a
+// variable is sent as a bearer token
+func FromCanonical(canonical config.ProviderConfig) *ProviderConfig {
+	provider := &ProviderConfig{
+		Name:         canonical.Name,
+		Tier:         canonical.Tier.String(),
+		Endpoint:     canonical.Endpoint,
+		ModelsSource: modelsSourceFromCanonical(canonical.ModelsSource),
+		LastUpdated:  time.Now(),
+		Health:       HealthStatus{Status: "unknown"},
+		Capabilities: slices.Clone(canonical.DeclaredCapabilities),
+	}
+	if canonical.ModelsSource.Type == "" && len(canonical.Models) > 0 {
+		provider.ModelsSource = ModelsSource{Type: "list", Value: slices.Clone(canonical.Models)}
+	}
+
+	auth := AuthConfig{
+		Type:   canonical.Metadata[metadataAuthType],
+		EnvVar: canonical.Metadata[metadataAuthEnvVar],
+		Header: canonical.Metadata[metadataAuthHeader],
+	}
+	auth.Required, _ = strconv.ParseBool(canonical.Metadata[metadataAuthRequired])
+	if auth.Type == "" {
+		auth = authFromAPIKey(canonical.APIKey)
+	}
+	provider.Authentication = auth
+	return provider
+}
+
+// authFromAPIKey derives bearer token authentication from an API key of the
+// form ${VAR} or ${VAR:-default}
+func authFromAPIKey(apiKey string) AuthConfig {
+	if !strings.HasPrefix(apiKey, "${") || !strings.HasSuffix(apiKey, "}") {
+		return AuthConfig{Type: "none", Required: false}
+	}
+	name := strings.TrimSuffix(strings.TrimPrefix(apiKey, "${"), "}")
+	required := true
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		required = strings.HasPrefix(name[i:], ":?")
+		name = name[:i]
+	}
+	return AuthConfig{Type: "bearer_token", EnvVar: name, Header: "Authorization", Required: required}
+}
+
+// canonicalModelsSource maps this package's "url" sources onto the canonical
+// "endpoint" type
+func canonicalModelsSource(source ModelsSource) config.ModelsSource {
+	switch source.Type {
+	case "url":
+		return config.ModelsSource{Type: "endpoint", Value: source.Value}
+	case "list":
+		if models, ok := source.Value.([]string); ok {
+			return config.ModelsSource{Type: "list", Value: slices.Clone(models)}
+		}
+	}
+	return config.ModelsSource{Type: source.Type, Value: source.Value}
+}
+
+// modelsSourceFromCanonical is the inverse of canonicalModelsSource
+func modelsSourceFromCanonical(source config.ModelsSource) ModelsSource {
+	switch source.Type {
+	case "endpoint":
+		return ModelsSource{Type: "url", Value: source.Value}
+	case "list":
+		return ModelsSource{Type: "list", Value: source.List()}
+	}
+	return ModelsSource{Type: source.Type, Value: source.Value}
+}
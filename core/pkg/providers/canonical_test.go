@@ -0,0 +1,108 @@
+package providers_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
+	"github.com/labring/aiproxy/core/pkg/providers"
+)
+
+func TestCanonicalRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider providers.ProviderConfig
+	}{
+		{
+			name: "official provider with a models URL",
+			provider: providers.ProviderConfig{
+				Name:         "OpenAI",
+				Tier:         "official",
+				Endpoint:     "https://api.openai.com/v1",
+				ModelsSource: providers.ModelsSource{Type: "url", Value: "https://api.openai.com/v1/models"},
+				Capabilities: []string{"text", "code"},
+				Authentication: providers.AuthConfig{
+					Type: "bearer_token", EnvVar: "OPENAI_API_KEY", Header: "Authorization", Required: true,
+				},
+			},
+		},
+		{
+			name: "community provider with a model list",
+			provider: providers.ProviderConfig{
+				Name:         "HuggingFace",
+				Tier:         "community",
+				Endpoint:     "https://api-inference.huggingface.co",
+				ModelsSource: providers.ModelsSource{Type: "list", Value: []string{"llama-3", "mistral"}},
+				Authentication: providers.AuthConfig{
+					Type: "bearer_token", EnvVar: "HUGGINGFACE_API_KEY", Header: "Authorization",
+				},
+			},
+		},
+		{
+			name: "unofficial script without authentication",
+			provider: providers.ProviderConfig{
+				Name:           "Local",
+				Tier:           "unofficial",
+				Endpoint:       "./scripts/local.py",
+				ModelsSource:   providers.ModelsSource{Type: "list", Value: []string{}},
+				Authentication: providers.AuthConfig{Type: "none"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := providers.FromCanonical(tt.provider.ToCanonical())
+			if got.Health.Status != "unknown" || got.LastUpdated.IsZero() {
+				t.Errorf("runtime state = %+v at %v, want unknown health and a load time", got.Health, got.LastUpdated)
+			}
+			got.Health, got.LastUpdated = tt.provider.Health, tt.provider.LastUpdated
+			if !reflect.DeepEqual(*got, tt.provider) {
+				t.Errorf("round trip = %+v, want %+v", *got, tt.provider)
+			}
+		})
+	}
+}
+
+func TestToCanonical(t *testing.T) {
+	provider := providers.ProviderConfig{
+		Name:         "Replicate",
+		Tier:         "community",
+		Endpoint:     "https://api.replicate.com/v1",
+		ModelsSource: providers.ModelsSource{Type: "list", Value: []string{"flux"}},
+		Authentication: providers.AuthConfig{
+			Type: "bearer_token", EnvVar: "REPLICATE_API_TOKEN", Header: "Authorization", Required: true,
+		},
+	}
+
+	canonical := provider.ToCanonical()
+	if canonical.Tier != tier.Community || canonical.APIKey != "${REPLICATE_API_TOKEN}" {
+		t.Errorf("canonical tier %q and key %q, want community and ${REPLICATE_API_TOKEN}", canonical.Tier, canonical.APIKey)
+	}
+	if !reflect.DeepEqual(canonical.Models, []string{"flux"}) {
+		t.Errorf("canonical models = %v, want [flux]", canonical.Models)
+	}
+
+	provider.Authentication.Required = false
+	if key := provider.ToCanonical().APIKey; key != "${REPLICATE_API_TOKEN:-}" {
+		t.Errorf("optional credential key = %q, want ${REPLICATE_API_TOKEN:-}", key)
+	}
+}
+
+func TestFromCanonicalWithoutAuthMetadata(t *testing.T) {
+	got := providers.FromCanonical(config.ProviderConfig{
+		Name:         "Groq",
+		Tier:         tier.Community,
+		Endpoint:     "https://api.groq.com/openai/v1",
+		APIKey:       "${GROQ_API_KEY}",
+		ModelsSource: config.ModelsSource{Type: "endpoint", Value: "https://api.groq.com/openai/v1/models"},
+	})
+
+	want := providers.AuthConfig{Type: "bearer_token", EnvVar: "GROQ_API_KEY", Header: "Authorization", Required: true}
+	if got.Authentication != want {
+		t.Errorf("authentication = %+v, want %+v", got.Authentication, want)
+	}
+	if got.ModelsSource.Type != "url" {
+		t.Errorf("models source type = %q, want url", got.ModelsSource.Type)
+	}
+}
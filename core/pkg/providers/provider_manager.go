@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/labring/aiproxy/core/pkg/pollinations"
+	log "github.com/sirupsen/logrus"
 )
 
 type ProviderManager struct {
@@ -73,7 +74,13 @@ func (pm *ProviderManager) Initialize(ctx context.Context) error {
 	pm.providers = providers
 
 	// Generate configurations if they don't exist
-	configurator := NewAutoConfigurator(pm.csvPath, filepath.Join(pm.configDir, "generated", "providers"))
+	outputDir := filepath.Join(pm.configDir, "generated", "providers")
+	configurator := NewAutoConfigurator(pm.csvPath, outputDir)
+	if cache, err := LoadAnalysisCache(filepath.Join(outputDir, AnalysisCacheFile), 30*24*time.Hour); err == nil {
+		configurator.SetAnalysisCache(cache)
+	} else {
+		log.Warnf("analysis cache unavailable, analyzing every provider: %v", err)
+	}
 	if err := configurator.GenerateConfigurations(ctx); err != nil {
 		return fmt.Errorf("failed to generate configurations: %w", err)
 	}
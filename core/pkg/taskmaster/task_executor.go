@@ -5,17 +5,23 @@ import (
 	"fmt"
 	"sync"
 	"time"
+
+	"golang.org/x/sync/errgroup"
 )
 
 type TaskExecutor struct {
 	maxConcurrency int
 	timeout        time.Duration
+	// cancels stops the tasks currently running, by task ID
+	cancels map[string]context.CancelFunc
+	mu      sync.Mutex
 }
 
 func NewTaskExecutor() *TaskExecutor {
 	return &TaskExecutor{
 		maxConcurrency: 5,
 		timeout:        60 * time.Second,
+		cancels:        make(map[string]context.CancelFunc),
 	}
 }
 
@@ -42,94 +48,104 @@ func (te *TaskExecutor) ExecuteParallel(ctx context.Context, tasks []*Task) erro
 	return te.executeWithDependencies(ctx, taskMap, dependencyMap)
 }
 
+// executeWithDependencies runs the tasks on a pool of maxConcurrency workers.
+// A task is queued as soon as the last of its dependencies reports completion,
+// and the first failure cancels the tasks still running
 func (te *TaskExecutor) executeWithDependencies(ctx context.Context, taskMap map[string]*Task, dependencies map[string][]string) error {
-	completed := make(map[string]bool)
-	running := make(map[string]bool)
-	var wg sync.WaitGroup
-	errorChan := make(chan error, len(taskMap))
-	semaphore := make(chan struct{}, te.maxConcurrency)
-
-	for len(completed) < len(taskMap) {
-		// Find tasks that can be executed (dependencies completed)
-		var readyTasks []*Task
-		for taskID, task := range taskMap {
-			if completed[taskID] || running[taskID] {
-				continue
+	// Count each task's unmet dependencies and index who waits on whom
+	unmet := make(map[string]int, len(taskMap))
+	dependents := make(map[string][]string)
+	for taskID := range taskMap {
+		for _, depID := range dependencies[taskID] {
+			if _, exists := taskMap[depID]; !exists {
+				return fmt.Errorf("task %s depends on unknown task %s", taskID, depID)
 			}
+			unmet[taskID]++
+			dependents[depID] = append(dependents[depID], taskID)
+		}
+	}
 
-			canExecute := true
-			for _, depID := range dependencies[taskID] {
-				if !completed[depID] {
-					canExecute = false
-					break
+	group, groupCtx := errgroup.WithContext(ctx)
+	// Both channels hold every task, so sends never block
+	ready := make(chan *Task, len(taskMap))
+	done := make(chan string, len(taskMap))
+
+	// The pool keeps the size it started with; SetMaxConcurrency applies to
+	// later executions
+	te.mu.Lock()
+	workers := te.maxConcurrency
+	te.mu.Unlock()
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(taskMap) {
+		workers = len(taskMap)
+	}
+	for i := 0; i < workers; i++ {
+		group.Go(func() error {
+			for task := range ready {
+				// Tasks still queued when a sibling failed are left pending
+				if groupCtx.Err() != nil {
+					return nil
 				}
+				if err := te.executeTask(groupCtx, task); err != nil {
+					return fmt.Errorf("task %s failed: %w", task.ID, err)
+				}
+				done <- task.ID
 			}
+			return nil
+		})
+	}
 
-			if canExecute {
-				readyTasks = append(readyTasks, task)
+	// The dispatcher queues the tasks without dependencies, then the
+	// dependents of each task that completes, and closes the queue when no
+	// task is left to wait for
+	group.Go(func() error {
+		defer close(ready)
+		queued := 0
+		for taskID, task := range taskMap {
+			if unmet[taskID] == 0 {
+				ready <- task
+				queued++
 			}
 		}
-
-		if len(readyTasks) == 0 && len(running) == 0 {
-			return fmt.Errorf("circular dependency detected or all remaining tasks failed")
-		}
-
-		// Execute ready tasks
-		for _, task := range readyTasks {
-			wg.Add(1)
-			running[task.ID] = true
-
-			go func(t *Task) {
-				defer wg.Done()
-
-				// Acquire semaphore
-				semaphore <- struct{}{}
-				defer func() { <-semaphore }()
-
-				if err := te.executeTask(ctx, t); err != nil {
-					errorChan <- fmt.Errorf("task %s failed: %w", t.ID, err)
-					return
+		for completed := 0; completed < queued; completed++ {
+			select {
+			case taskID := <-done:
+				for _, dependentID := range dependents[taskID] {
+					if unmet[dependentID]--; unmet[dependentID] == 0 {
+						ready <- taskMap[dependentID]
+						queued++
+					}
 				}
-
-				completed[t.ID] = true
-				delete(running, t.ID)
-			}(task)
-		}
-
-		// Wait for at least one task to complete
-		if len(readyTasks) > 0 {
-			// Wait a bit to allow tasks to start
-			time.Sleep(100 * time.Millisecond)
-		}
-
-		// Check for errors
-		select {
-		case err := <-errorChan:
-			return err
-		default:
+			case <-groupCtx.Done():
+				return groupCtx.Err()
+			}
 		}
-	}
-
-	wg.Wait()
-
-	// Check for any remaining errors
-	close(errorChan)
-	for err := range errorChan {
-		if err != nil {
-			return err
+		if queued < len(taskMap) {
+			return fmt.Errorf("circular dependency detected among %d tasks", len(taskMap)-queued)
 		}
-	}
+		return nil
+	})
 
-	return nil
+	return group.Wait()
 }
 
 func (te *TaskExecutor) executeTask(ctx context.Context, task *Task) error {
 	task.Status = "running"
 	task.StartTime = time.Now()
 
-	// Create task-specific context with timeout
+	// Create task-specific context with timeout, which CancelTask can end early
+	te.mu.Lock()
 	taskCtx, cancel := context.WithTimeout(ctx, te.timeout)
-	defer cancel()
+	te.cancels[task.ID] = cancel
+	te.mu.Unlock()
+	defer func() {
+		te.mu.Lock()
+		delete(te.cancels, task.ID)
+		te.mu.Unlock()
+		cancel()
+	}()
 
 	// Execute based on task type
 	var result interface{}
@@ -261,10 +277,28 @@ func truncateString(s string, maxLen int) string {
 	return s[:maxLen] + "..."
 }
 
+// SetMaxConcurrency sets the pool size of the parallel executions started
+// from now on; it is safe to call while executions are running
 func (te *TaskExecutor) SetMaxConcurrency(max int) {
+	te.mu.Lock()
 	te.maxConcurrency = max
+	te.mu.Unlock()
 }
 
 func (te *TaskExecutor) SetTimeout(timeout time.Duration) {
+	te.mu.Lock()
 	te.timeout = timeout
+	te.mu.Unlock()
+}
+
+// CancelTask cancels the context of a running task, which then fails with
+// context.Canceled. It reports whether the task was running
+func (te *TaskExecutor) CancelTask(taskID string) bool {
+	te.mu.Lock()
+	cancel, running := te.cancels[taskID]
+	te.mu.Unlock()
+	if running {
+		cancel()
+	}
+	return running
 }
\ No newline at end of file
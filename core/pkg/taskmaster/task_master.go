@@ -229,6 +229,16 @@ func (tm *TaskMaster) calculateTotalCost(tasks []*Task) float64 {
 	return total
 }
 
+// SetMaxConcurrency sets how many tasks of a parallel execution run at once
+func (tm *TaskMaster) SetMaxConcurrency(max int) {
+	tm.executor.SetMaxConcurrency(max)
+}
+
+// CancelTask cancels a running task of an execution
+func (tm *TaskMaster) CancelTask(taskID string) bool {
+	return tm.executor.CancelTask(taskID)
+}
+
 func (tm *TaskMaster) GetExecution(executionID string) (*WorkflowExecution, error) {
 	// This would typically retrieve from a database
 	// For now, return a placeholder
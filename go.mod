@@ -1,8 +1,28 @@
 module github.com/ThatsRight-ItsTJ/Your-PaL-MoE
 
-go 1.21
+go 1.23
 
 require (
+	github.com/go-redis/redis/v8 v8.11.5
 	github.com/gorilla/mux v1.8.1
+	github.com/mattn/go-sqlite3 v1.14.52
+	github.com/nats-io/nats.go v1.37.0
 	github.com/rs/cors v1.11.1
-)
\ No newline at end of file
+	github.com/segmentio/kafka-go v0.4.47
+	github.com/sirupsen/logrus v1.10.2
+	github.com/tetratelabs/wazero v1.8.2
+	golang.org/x/sync v0.1.0
+	gopkg.in/yaml.v2 v2.4.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.1.2 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/klauspost/compress v1.17.2 // indirect
+	github.com/nats-io/nkeys v0.4.7 // indirect
+	github.com/nats-io/nuid v1.0.1 // indirect
+	github.com/pierrec/lz4/v4 v4.1.15 // indirect
+	golang.org/x/crypto v0.18.0 // indirect
+	golang.org/x/sys v0.16.0 // indirect
+)
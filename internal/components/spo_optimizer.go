@@ -3,6 +3,7 @@ package components
 import (
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -52,6 +53,9 @@ type SPOOptimizer struct {
 	maxCacheSize     int
 	cacheHitRate     float64
 	totalOptimizations int64
+	cacheHits          int64
+	// mutex guards the cache and counters, which every request updates
+	mutex sync.Mutex
 }
 
 // CachedOptimization represents a cached optimization result
@@ -88,11 +92,15 @@ func (spo *SPOOptimizer) OptimizePrompt(prompt string, complexity TaskComplexity
 		return "", fmt.Errorf("prompt cannot be empty")
 	}
 
+	spo.mutex.Lock()
+	defer spo.mutex.Unlock()
+
 	// Check cache first
 	cacheKey := fmt.Sprintf("%s_%s", prompt, complexity.Overall.String())
 	if cached, exists := spo.cache[cacheKey]; exists {
 		cached.HitCount++
 		spo.cache[cacheKey] = cached
+		spo.cacheHits++
 		return cached.OptimizedPrompt, nil
 	}
 
@@ -159,16 +167,12 @@ func (spo *SPOOptimizer) applyRule(prompt string, rule string, complexity TaskCo
 
 // GetOptimizationStats returns optimization statistics
 func (spo *SPOOptimizer) GetOptimizationStats() map[string]interface{} {
-	cacheHits := 0
-	for _, cached := range spo.cache {
-		if cached.HitCount > 1 {
-			cacheHits += cached.HitCount - 1
-		}
-	}
+	spo.mutex.Lock()
+	defer spo.mutex.Unlock()
 
 	hitRate := 0.0
 	if spo.totalOptimizations > 0 {
-		hitRate = float64(cacheHits) / float64(spo.totalOptimizations)
+		hitRate = float64(spo.cacheHits) / float64(spo.totalOptimizations)
 	}
 
 	return map[string]interface{}{
@@ -179,18 +183,38 @@ func (spo *SPOOptimizer) GetOptimizationStats() map[string]interface{} {
 	}
 }
 
+// Counters returns how many prompts were optimized and how many were
+// answered from the cache
+func (spo *SPOOptimizer) Counters() (totalOptimizations, cacheHits int64) {
+	spo.mutex.Lock()
+	defer spo.mutex.Unlock()
+	return spo.totalOptimizations, spo.cacheHits
+}
+
+// RestoreCounters replaces the counters, e.g. from a saved snapshot
+func (spo *SPOOptimizer) RestoreCounters(totalOptimizations, cacheHits int64) {
+	spo.mutex.Lock()
+	defer spo.mutex.Unlock()
+	spo.totalOptimizations = totalOptimizations
+	spo.cacheHits = cacheHits
+}
+
 // ClearCache clears the optimization cache
 func (spo *SPOOptimizer) ClearCache() {
+	spo.mutex.Lock()
+	defer spo.mutex.Unlock()
 	spo.cache = make(map[string]CachedOptimization)
 }
 
 // SetMaxCacheSize sets the maximum cache size
 func (spo *SPOOptimizer) SetMaxCacheSize(size int) {
+	spo.mutex.Lock()
+	defer spo.mutex.Unlock()
 	spo.maxCacheSize = size
 	
 	// Trim cache if necessary
 	if len(spo.cache) > size {
 		// Simple implementation: clear all cache when over limit
-		spo.ClearCache()
+		spo.cache = make(map[string]CachedOptimization)
 	}
 }
\ No newline at end of file
@@ -6,6 +6,31 @@ import (
 	"strings"
 )
 
+// Patterns the complexity detectors match against lower-cased content,
+// compiled once rather than on every request
+var (
+	reasoningPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`\b(because|therefore|however|although|since)\b`),
+		regexp.MustCompile(`\b(analyze|evaluate|compare|contrast|argue)\b`),
+		regexp.MustCompile(`\b(logic|reasoning|conclusion|premise|inference)\b`),
+	}
+	mathPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`\d+\s*[\+\-\*\/\^]\s*\d+`),
+		regexp.MustCompile(`\b(equation|formula|calculate|solve|derivative|integral)\b`),
+		regexp.MustCompile(`\b(algebra|geometry|calculus|statistics|probability)\b`),
+	}
+	creativePatterns = []*regexp.Regexp{
+		regexp.MustCompile(`\b(write|create|generate|compose|design)\b`),
+		regexp.MustCompile(`\b(story|poem|article|essay|creative)\b`),
+		regexp.MustCompile(`\b(imagine|brainstorm|invent|original)\b`),
+	}
+	factualPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`\b(fact|data|information|research|study)\b`),
+		regexp.MustCompile(`\b(when|where|who|what|how)\b`),
+		regexp.MustCompile(`\b(define|explain|describe|list)\b`),
+	}
+)
+
 // TaskReasoner analyzes task complexity and requirements
 type TaskReasoner struct {
 	config *TaskReasonerConfig
@@ -74,12 +99,6 @@ func (tr *TaskReasoner) AnalyzeComplexity(content string) (*TaskComplexity, erro
 func (tr *TaskReasoner) detectReasoningComplexity(content string) ComplexityLevel {
 	content = strings.ToLower(content)
 	
-	reasoningPatterns := []*regexp.Regexp{
-		regexp.MustCompile(`\b(because|therefore|however|although|since)\b`),
-		regexp.MustCompile(`\b(analyze|evaluate|compare|contrast|argue)\b`),
-		regexp.MustCompile(`\b(logic|reasoning|conclusion|premise|inference)\b`),
-	}
-	
 	score := 0
 	for _, pattern := range reasoningPatterns {
 		matches := pattern.FindAllString(content, -1)
@@ -102,12 +121,6 @@ func (tr *TaskReasoner) detectReasoningComplexity(content string) ComplexityLeve
 func (tr *TaskReasoner) detectMathematicalComplexity(content string) ComplexityLevel {
 	content = strings.ToLower(content)
 	
-	mathPatterns := []*regexp.Regexp{
-		regexp.MustCompile(`\d+\s*[\+\-\*\/\^]\s*\d+`),
-		regexp.MustCompile(`\b(equation|formula|calculate|solve|derivative|integral)\b`),
-		regexp.MustCompile(`\b(algebra|geometry|calculus|statistics|probability)\b`),
-	}
-	
 	score := 0
 	for _, pattern := range mathPatterns {
 		if pattern.MatchString(content) {
@@ -139,12 +152,6 @@ func (tr *TaskReasoner) detectMathematicalComplexity(content string) ComplexityL
 func (tr *TaskReasoner) detectCreativeComplexity(content string) ComplexityLevel {
 	content = strings.ToLower(content)
 	
-	creativePatterns := []*regexp.Regexp{
-		regexp.MustCompile(`\b(write|create|generate|compose|design)\b`),
-		regexp.MustCompile(`\b(story|poem|article|essay|creative)\b`),
-		regexp.MustCompile(`\b(imagine|brainstorm|invent|original)\b`),
-	}
-	
 	score := 0
 	for _, pattern := range creativePatterns {
 		if pattern.MatchString(content) {
@@ -176,12 +183,6 @@ func (tr *TaskReasoner) detectCreativeComplexity(content string) ComplexityLevel
 func (tr *TaskReasoner) detectFactualComplexity(content string) ComplexityLevel {
 	content = strings.ToLower(content)
 	
-	factualPatterns := []*regexp.Regexp{
-		regexp.MustCompile(`\b(fact|data|information|research|study)\b`),
-		regexp.MustCompile(`\b(when|where|who|what|how)\b`),
-		regexp.MustCompile(`\b(define|explain|describe|list)\b`),
-	}
-	
 	score := 0
 	for _, pattern := range factualPatterns {
 		matches := pattern.FindAllString(content, -1)
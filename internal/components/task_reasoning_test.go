@@ -0,0 +1,41 @@
+package components
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/perfbudget"
+)
+
+// Prompts the complexity benchmarks analyze, a short question and a long
+// templated request
+var (
+	shortPrompt = "What is the capital of France?"
+	longPrompt  = strings.Repeat("Analyze the quarterly data and explain why revenue fell, then write a short report comparing 3 regions and calculate 12 * 7 growth. ", 20)
+)
+
+func benchmarkAnalyzeComplexity(b *testing.B, prompt string) {
+	reasoner := NewTaskReasoner()
+	for i := 0; i < b.N; i++ {
+		if _, err := reasoner.AnalyzeComplexity(prompt); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAnalyzeComplexityShort(b *testing.B) {
+	benchmarkAnalyzeComplexity(b, shortPrompt)
+}
+
+func BenchmarkAnalyzeComplexityLong(b *testing.B) {
+	benchmarkAnalyzeComplexity(b, longPrompt)
+}
+
+// TestPerformanceBudget runs on every request before selection, so it must
+// stay cheap. Run with PERF_BUDGET=true
+func TestPerformanceBudget(t *testing.T) {
+	perfbudget.Check(t,
+		perfbudget.Budget{Name: "AnalyzeComplexityShort", Benchmark: BenchmarkAnalyzeComplexityShort, MaxNsPerOp: 100_000, MaxAllocsPerOp: 16},
+		perfbudget.Budget{Name: "AnalyzeComplexityLong", Benchmark: BenchmarkAnalyzeComplexityLong, MaxNsPerOp: 4_000_000, MaxAllocsPerOp: 120},
+	)
+}
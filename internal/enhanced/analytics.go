@@ -0,0 +1,120 @@
+package enhanced
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/internal/components"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/analytics"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/middleware"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/requestid"
+)
+
+// SetAnalytics sends the outcome of every processed request to engine and
+// registers the configured providers as its catalogue
+func (es *EnhancedSystem) SetAnalytics(engine *analytics.AnalyticsEngine) {
+	es.analytics = engine
+	if engine == nil {
+		return
+	}
+
+	catalogue := make([]analytics.ProviderInfo, 0, len(es.providers))
+	for _, provider := range es.providers {
+		catalogue = append(catalogue, analytics.ProviderInfo{
+			ProviderID:   provider.Name,
+			Tier:         string(provider.Tier),
+			CostPerToken: provider.CostPerToken,
+		})
+	}
+	engine.SetProviders(catalogue)
+
+	if es.metricsStorage != nil {
+		es.seedAnalytics(es.metricsStorage)
+	}
+}
+
+// How much stored request history is replayed into the analytics engine
+const (
+	analyticsSeedWindow = 30 * 24 * time.Hour
+	analyticsSeedLimit  = 100000
+)
+
+// seedAnalytics replays stored requests so analytics survive restarts
+func (es *EnhancedSystem) seedAnalytics(storage *MetricsStorage) {
+	records, err := storage.GetRequests(time.Now().Add(-analyticsSeedWindow), analyticsSeedLimit)
+	if err != nil {
+		logger.Warnf("Failed to load stored requests: %v", err)
+		return
+	}
+	for _, record := range records {
+		es.analytics.RecordRequest(record)
+	}
+	if len(records) > 0 {
+		logger.Infof("Restored analytics from %d stored requests", len(records))
+	}
+}
+
+// recordAnalytics reports a request that reached a provider to the provider
+// incidents, the analytics engine and the metrics storage; err is the reason
+// it failed, nil when a response was produced
+func (es *EnhancedSystem) recordAnalytics(ctx context.Context, assignment *ProviderAssignment, complexity components.TaskComplexity, startTime time.Time, response *ProcessResponse, err error) {
+	es.recordIncidents(ctx, assignment, response, err)
+	if es.analytics == nil && es.metricsStorage == nil {
+		return
+	}
+
+	metrics := analytics.RequestMetrics{
+		RequestID:   requestid.FromContext(ctx),
+		KeyID:       middleware.KeyIDFromContext(ctx),
+		Tenant:      middleware.TenantFromContext(ctx),
+		Environment: middleware.EnvironmentFromContext(ctx),
+		ProviderID:  assignment.Provider.Name,
+		Model:       assignment.Model,
+		Tier:        string(assignment.Provider.Tier),
+		Complexity:  complexity.Overall.String(),
+		Timestamp:   startTime,
+		Duration:    time.Since(startTime).Milliseconds(),
+		Success:     err == nil,
+		Tags:        analytics.TagsFromContext(ctx),
+	}
+	if response != nil {
+		metrics.TokensUsed = int(response.TokensUsed)
+		metrics.ReasoningTokens = int(response.ReasoningTokens)
+		metrics.Cost = response.Cost
+		if response.safety != nil {
+			metrics.SafetyAction = string(response.safety.Action)
+			metrics.SafetyScores = response.safety.Scores
+		}
+	}
+	if err != nil {
+		metrics.ErrorMessage = es.scrubber.Scrub(err.Error())
+	}
+	if es.analytics != nil {
+		es.analytics.RecordRequest(metrics)
+	}
+	if es.metricsStorage != nil {
+		es.persistRequest(metrics, errors.Is(err, ErrRateLimited))
+	}
+}
+
+// persistRequest stores a request for analytics, and as a provider metrics
+// sample for health and cost-based selection
+func (es *EnhancedSystem) persistRequest(metrics analytics.RequestMetrics, rateLimited bool) {
+	var failures int64
+	if !metrics.Success {
+		failures = 1
+	}
+	err := errors.Join(
+		es.metricsStorage.RecordRequest(metrics),
+		es.metricsStorage.RecordProviderMetrics(
+			metrics.ProviderID, metrics.Model,
+			1, failures, int64(metrics.TokensUsed),
+			float64(metrics.Duration), metrics.Cost,
+			rateLimited,
+		),
+	)
+	if err != nil {
+		logger.WithField(requestid.Field, metrics.RequestID).Warnf("Failed to store request metrics: %v", err)
+	}
+}
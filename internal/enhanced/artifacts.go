@@ -0,0 +1,32 @@
+package enhanced
+
+import (
+	"context"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/artifact"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/requestid"
+)
+
+// SetArtifacts keeps the images and audio that responses embed or link to in
+// manager's store and rewrites responses to link to the gateway instead. A nil
+// manager leaves responses as providers wrote them
+func (es *EnhancedSystem) SetArtifacts(manager *artifact.Manager) {
+	es.artifacts = manager
+}
+
+// rewriteArtifacts stores the artifacts of a response ahead of response
+// processing, listing them in the metadata. Artifacts that cannot be stored
+// keep their provider links
+func (es *EnhancedSystem) rewriteArtifacts(ctx context.Context, response *ProcessResponse) {
+	if es.artifacts == nil {
+		return
+	}
+	content, artifacts, err := es.artifacts.Rewrite(ctx, response.Content)
+	if err != nil {
+		logger.WithField(requestid.Field, requestid.FromContext(ctx)).Warnf("Failed to store response artifacts: %v", err)
+	}
+	response.Content = content
+	if len(artifacts) > 0 {
+		response.Metadata["artifacts"] = artifacts
+	}
+}
@@ -0,0 +1,128 @@
+package enhanced
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/middleware"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/requestid"
+)
+
+// DefaultAsyncTimeout bounds how long an asynchronous request may run
+const DefaultAsyncTimeout = time.Hour
+
+var (
+	// ErrRequestRunning is returned when starting a request whose ID is still running
+	ErrRequestRunning = errors.New("a request with this ID is still running")
+	// ErrRequestFinished is returned when cancelling a request that has completed
+	ErrRequestFinished = errors.New("request has already finished")
+)
+
+// asyncRequest is an asynchronous request that has not finished yet
+type asyncRequest struct {
+	record RequestRecord
+	cancel context.CancelFunc
+}
+
+// SetAsyncTimeout sets how long asynchronous requests may run before they
+// are cancelled, DefaultAsyncTimeout when zero
+func (es *EnhancedSystem) SetAsyncTimeout(timeout time.Duration) {
+	es.asyncMutex.Lock()
+	defer es.asyncMutex.Unlock()
+	es.asyncTimeout = timeout
+}
+
+// ProcessAsync starts processing a request in the background and returns
+// it in the running state. The request keeps the ID, key and tenant of ctx
+// but not its cancellation, so it outlives the HTTP call; its outcome is
+// kept in the request history like any other request
+func (es *EnhancedSystem) ProcessAsync(ctx context.Context, input RequestInput) (*RequestRecord, error) {
+	if es.IsDraining() {
+		return nil, ErrShuttingDown
+	}
+	id := requestid.FromContext(ctx)
+	if id == "" {
+		id = requestid.New()
+		ctx = requestid.NewContext(ctx, id)
+	}
+
+	es.asyncMutex.Lock()
+	if _, ok := es.asyncRequests[id]; ok {
+		es.asyncMutex.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrRequestRunning, id)
+	}
+	if es.asyncRequests == nil {
+		es.asyncRequests = make(map[string]*asyncRequest)
+	}
+	timeout := es.asyncTimeout
+	if timeout <= 0 {
+		timeout = DefaultAsyncTimeout
+	}
+	jobCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	job := &asyncRequest{
+		record: RequestRecord{
+			ID:        id,
+			KeyID:     middleware.KeyIDFromContext(ctx),
+			Tenant:    middleware.TenantFromContext(ctx),
+			SessionID: sessionID(input),
+			Status:    RequestRunning,
+			CreatedAt: time.Now(),
+			Input:     input,
+		},
+		cancel: cancel,
+	}
+	es.asyncRequests[id] = job
+	es.asyncMutex.Unlock()
+
+	go func() {
+		defer cancel()
+		if _, err := es.ProcessRequest(jobCtx, input); errors.Is(err, ErrShuttingDown) {
+			// Draining began before the request did; keep it retrievable
+			es.recordRequestHistory(jobCtx, input, job.record.CreatedAt, nil, err)
+		}
+
+		es.asyncMutex.Lock()
+		delete(es.asyncRequests, id)
+		es.asyncMutex.Unlock()
+	}()
+
+	record := job.record
+	return &record, nil
+}
+
+// CancelRequest cancels a running asynchronous request. The request stops at
+// its next cancellation point and is recorded as cancelled
+func (es *EnhancedSystem) CancelRequest(ctx context.Context, id string) (*RequestRecord, error) {
+	es.asyncMutex.Lock()
+	job, ok := es.asyncRequests[id]
+	es.asyncMutex.Unlock()
+	if !ok {
+		if _, err := es.GetRequest(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %s", ErrRequestFinished, id)
+	}
+	if !scopeRequestQuery(ctx, RequestQuery{}).matches(&job.record) {
+		return nil, fmt.Errorf("%w: %s", ErrRequestNotFound, id)
+	}
+
+	job.cancel()
+	logger.WithField(requestid.Field, id).Info("Asynchronous request cancelled")
+	record := job.record
+	return &record, nil
+}
+
+// runningRequest returns the asynchronous request with id while it runs
+func (es *EnhancedSystem) runningRequest(id string) (*RequestRecord, bool) {
+	es.asyncMutex.Lock()
+	defer es.asyncMutex.Unlock()
+
+	job, ok := es.asyncRequests[id]
+	if !ok {
+		return nil, false
+	}
+	record := job.record
+	return &record, true
+}
@@ -0,0 +1,19 @@
+package enhanced
+
+import (
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/audit"
+)
+
+// defaultAuditLogSize is how many audit events are kept without metrics storage
+const defaultAuditLogSize = 10000
+
+// RecordAuditEvent adds an event to the audit log, persisted with the
+// metrics storage when one is set
+func (es *EnhancedSystem) RecordAuditEvent(event audit.Event) error {
+	return es.auditLog.RecordAuditEvent(event)
+}
+
+// QueryAuditEvents returns a page of the audit log
+func (es *EnhancedSystem) QueryAuditEvents(query audit.Query) (*audit.Page, error) {
+	return es.auditLog.QueryAuditEvents(query)
+}
@@ -0,0 +1,172 @@
+package enhanced
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/probe"
+)
+
+// ErrProbingDisabled is returned by probe operations when no prober is configured
+var ErrProbingDisabled = errors.New("capability probing is not enabled")
+
+// ErrUnknownProvider is returned for operations on a provider that is not configured
+var ErrUnknownProvider = errors.New("unknown provider")
+
+// defaultProbeMaxAge is the age after which probed features are verified
+// again when StartCapabilityReverification has not set one
+const defaultProbeMaxAge = 24 * time.Hour
+
+// capabilityProbe is the prober, result store and key lookup set by
+// SetCapabilityProbe, and the age StartCapabilityReverification keeps
+// features verified within
+type capabilityProbe struct {
+	prober *probe.Prober
+	store  *probe.Store
+	apiKey func(provider string) string
+	maxAge time.Duration
+}
+
+// SetCapabilityProbe enables capability probing. Results are kept in store,
+// which routing consults ahead of declared capabilities; apiKey returns the
+// key sent to a provider, empty for none
+func (es *EnhancedSystem) SetCapabilityProbe(prober *probe.Prober, store *probe.Store, apiKey func(provider string) string) {
+	es.capabilityProbe = &capabilityProbe{prober: prober, store: store, apiKey: apiKey}
+	if selector := es.builtinSelector(); selector != nil {
+		selector.SetCapabilityProbes(store)
+	}
+}
+
+// CapabilityProbes returns the latest probe result of every probed provider
+func (es *EnhancedSystem) CapabilityProbes() ([]*probe.Result, error) {
+	if es.capabilityProbe == nil {
+		return nil, ErrProbingDisabled
+	}
+	return es.capabilityProbe.store.List(), nil
+}
+
+// ProbeProvider probes the named provider now, using its first model, and
+// stores the result
+func (es *EnhancedSystem) ProbeProvider(ctx context.Context, providerName string) (*probe.Result, error) {
+	if es.capabilityProbe == nil {
+		return nil, ErrProbingDisabled
+	}
+	for _, provider := range es.providers {
+		if provider.Name == providerName {
+			return es.probe(ctx, provider)
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, providerName)
+}
+
+// CapabilityVerifications returns how current each probed provider's
+// features are and the changes flagged since they were last verified
+func (es *EnhancedSystem) CapabilityVerifications() ([]probe.Verification, error) {
+	if es.capabilityProbe == nil {
+		return nil, ErrProbingDisabled
+	}
+	return es.capabilityProbe.store.Verifications(es.capabilityProbeMaxAge()), nil
+}
+
+// AcknowledgeCapabilityChanges clears the changes flagged on a provider once
+// an operator has reviewed them, reporting whether the provider was probed
+func (es *EnhancedSystem) AcknowledgeCapabilityChanges(providerName string) (bool, error) {
+	if es.capabilityProbe == nil {
+		return false, ErrProbingDisabled
+	}
+	return es.capabilityProbe.store.Acknowledge(providerName)
+}
+
+// StartCapabilityReverification probes, every interval until ctx is done,
+// the providers with features not verified within maxAge
+func (es *EnhancedSystem) StartCapabilityReverification(ctx context.Context, maxAge, interval time.Duration) {
+	if es.capabilityProbe == nil {
+		return
+	}
+	es.capabilityProbe.maxAge = maxAge
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				es.ProbeStaleProviders(ctx, maxAge)
+			}
+		}
+	}()
+}
+
+// ProbeStaleProviders probes each provider without a successful probe newer
+// than maxAge or with a feature not verified since, the least recently
+// verified first and one at a time so providers are not flooded
+func (es *EnhancedSystem) ProbeStaleProviders(ctx context.Context, maxAge time.Duration) {
+	if es.capabilityProbe == nil {
+		return
+	}
+	var stale []*Provider
+	for _, provider := range es.providers {
+		if es.capabilityProbe.store.Stale(provider.Name, maxAge) {
+			stale = append(stale, provider)
+		}
+	}
+	sort.SliceStable(stale, func(i, j int) bool {
+		return es.lastVerified(stale[i].Name).Before(es.lastVerified(stale[j].Name))
+	})
+	for _, provider := range stale {
+		if ctx.Err() != nil {
+			return
+		}
+		if _, err := es.probe(ctx, provider); err != nil {
+			logger.Warnf("Failed to probe %s: %v", provider.Name, err)
+		}
+	}
+}
+
+// lastVerified returns when a provider's least recently verified feature
+// was verified, zero when it never was
+func (es *EnhancedSystem) lastVerified(providerName string) time.Time {
+	result, ok := es.capabilityProbe.store.Get(providerName)
+	if !ok {
+		return time.Time{}
+	}
+	if oldest := result.OldestVerification(); !oldest.IsZero() {
+		return oldest
+	}
+	return result.ProbedAt
+}
+
+// capabilityProbeMaxAge returns the age probed features are kept verified within
+func (es *EnhancedSystem) capabilityProbeMaxAge() time.Duration {
+	if es.capabilityProbe.maxAge > 0 {
+		return es.capabilityProbe.maxAge
+	}
+	return defaultProbeMaxAge
+}
+
+// probe sends the probes to provider and stores the result
+func (es *EnhancedSystem) probe(ctx context.Context, provider *Provider) (*probe.Result, error) {
+	if len(provider.Models) == 0 {
+		return nil, fmt.Errorf("provider %s has no models to probe", provider.Name)
+	}
+
+	target := probe.Target{
+		Provider: provider.Name,
+		BaseURL:  provider.BaseURL,
+		Model:    provider.Models[0],
+	}
+	if es.capabilityProbe.apiKey != nil {
+		target.APIKey = es.capabilityProbe.apiKey(provider.Name)
+	}
+
+	result := es.capabilityProbe.prober.Probe(ctx, target)
+	if err := es.capabilityProbe.store.Put(result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
@@ -0,0 +1,71 @@
+package enhanced
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/cassette"
+)
+
+// SetCassettes records the executor's provider calls in library, or replays
+// them from it, depending on its mode. Call it after the executor is set
+func (es *EnhancedSystem) SetCassettes(library *cassette.Library) {
+	if library == nil {
+		return
+	}
+	if recording, ok := es.executor.(cassetteExecutor); ok {
+		es.executor = recording.next
+	}
+	es.executor = cassetteExecutor{next: es.executor, library: library}
+	logger.Infof("Provider calls use cassettes in %s mode", library.Mode())
+}
+
+// cassetteExecutor answers provider calls from a cassette library and
+// records the calls it passes on to next
+type cassetteExecutor struct {
+	next    Executor
+	library *cassette.Library
+}
+
+// Execute replays the call's recorded interaction, or executes and records
+// it when the mode allows
+func (e cassetteExecutor) Execute(ctx context.Context, call ProviderCall) (*ProcessResponse, error) {
+	provider := call.Assignment.Provider.Name
+	request := cassette.Request{
+		Model:        call.Assignment.Model,
+		SystemPrompt: call.SystemPrompt,
+		Prompt:       call.Prompt,
+	}
+
+	if e.library.Mode() != cassette.ModeRecord {
+		recorded, err := e.library.Replay(provider, request)
+		if err == nil {
+			if err := recorded.Err(); err != nil {
+				return nil, err
+			}
+			return &ProcessResponse{
+				Content:    recorded.Content,
+				Provider:   call.Assignment.Provider,
+				Model:      call.Assignment.Model,
+				TokensUsed: recorded.TokensUsed,
+				Cost:       recorded.Cost,
+				Metadata:   make(map[string]interface{}),
+			}, nil
+		}
+		if e.library.Mode() == cassette.ModeReplay || !errors.Is(err, cassette.ErrNotRecorded) {
+			return nil, err
+		}
+	}
+
+	response, err := e.next.Execute(ctx, call)
+	var recorded cassette.Response
+	if err != nil {
+		recorded.Error = err.Error()
+	} else {
+		recorded = cassette.Response{Content: response.Content, TokensUsed: response.TokensUsed, Cost: response.Cost}
+	}
+	if recordErr := e.library.Record(provider, request, recorded); recordErr != nil {
+		logger.Warnf("Failed to record %s call in cassette: %v", provider, recordErr)
+	}
+	return response, err
+}
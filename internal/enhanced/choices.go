@@ -0,0 +1,180 @@
+package enhanced
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/sampling"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/usage"
+)
+
+// Choice is one completion of a request for several
+type Choice struct {
+	Index   int    `json:"index"`
+	Content string `json:"content"`
+}
+
+// ChoiceResult describes how the completions of a request for several were
+// generated, in the response metadata
+type ChoiceResult struct {
+	// Requested is how many completions the request returns, and Generated
+	// how many candidates were generated for them
+	Requested int `json:"requested"`
+	Generated int `json:"generated"`
+	// Calls is how many provider calls generated them, and Failed how many
+	// of those calls failed
+	Calls  int `json:"calls"`
+	Failed int `json:"failed,omitempty"`
+	// Withheld counts completions after the first the safety filter blocked
+	Withheld int `json:"withheld,omitempty"`
+}
+
+// callChoices returns how many completions one call to the assigned provider
+// generates for input: every candidate when its API takes a completion
+// count, and otherwise one
+func callChoices(input RequestInput, assignment *ProviderAssignment) int {
+	if sampling.MultipleChoices(sampling.DetectAPI(assignment.Provider.BaseURL)) {
+		return input.Candidates()
+	}
+	return 1
+}
+
+// generate answers input from the assigned provider with every candidate it
+// asks for, in one call when the provider's API takes a completion count
+// and in parallel calls otherwise, and keeps the completions it returns.
+// Requests for a single completion are a plain callProvider
+func (es *EnhancedSystem) generate(ctx context.Context, input RequestInput, assignment *ProviderAssignment, guard, prompt string, tokens int64) (*ProcessResponse, error) {
+	candidates := input.Candidates()
+	if candidates == 1 {
+		return es.callProvider(ctx, input, assignment, guard, prompt, tokens)
+	}
+
+	result := ChoiceResult{Requested: input.Choices(), Calls: 1}
+	var (
+		response *ProcessResponse
+		err      error
+	)
+	if callChoices(input, assignment) > 1 {
+		if response, err = es.callProvider(ctx, input, assignment, guard, prompt, tokens); err != nil {
+			return nil, err
+		}
+		// Executors that ignore the count answer with a single completion
+		if len(response.Choices) == 0 {
+			response.Choices = []Choice{{Content: response.Content}}
+		}
+	} else if response, err = es.fanOut(ctx, input, assignment, guard, prompt, tokens, &result); err != nil {
+		return nil, err
+	}
+	result.Generated = len(response.Choices)
+
+	es.pickChoices(input, prompt, response)
+	response.Metadata["choices"] = result
+	return response, nil
+}
+
+// fanOut calls the assigned provider once per candidate in parallel and
+// gathers the completions of the calls that succeed into the first one's
+// response, which pays for all of them. It fails when fewer calls succeed
+// than the request returns completions
+func (es *EnhancedSystem) fanOut(ctx context.Context, input RequestInput, assignment *ProviderAssignment, guard, prompt string, tokens int64, result *ChoiceResult) (*ProcessResponse, error) {
+	candidates := input.Candidates()
+	responses := make([]*ProcessResponse, candidates)
+	errs := make([]error, candidates)
+	var wg sync.WaitGroup
+	for i := range candidates {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			responses[i], errs[i] = es.callProvider(ctx, input, assignment, guard, prompt, tokens)
+		}(i)
+	}
+	wg.Wait()
+	result.Calls = candidates
+
+	var (
+		combined        *ProcessResponse
+		reconciliations []usage.Reconciliation
+		firstErr        error
+	)
+	for i, response := range responses {
+		if errs[i] != nil {
+			result.Failed++
+			if firstErr == nil {
+				firstErr = errs[i]
+			}
+			continue
+		}
+		if combined == nil {
+			combined = response
+		} else {
+			combined.TokensUsed += response.TokensUsed
+			combined.Cost += response.Cost
+			combined.ReasoningTokens += response.ReasoningTokens
+		}
+		combined.Choices = append(combined.Choices, Choice{Content: response.Content})
+		if reconciliation, ok := response.Metadata["token_usage"].(usage.Reconciliation); ok {
+			reconciliations = append(reconciliations, reconciliation)
+		}
+	}
+	if candidates-result.Failed < input.Choices() {
+		return nil, fmt.Errorf("%d of %d completions failed: %w", result.Failed, candidates, firstErr)
+	}
+
+	// Usage reported for some calls only would understate the others
+	if len(reconciliations) == len(combined.Choices) {
+		combined.Metadata["token_usage"] = usage.Combine(reconciliations)
+	} else {
+		delete(combined.Metadata, "token_usage")
+	}
+	return combined, nil
+}
+
+// pickChoices keeps the completions the request returns. Candidates best_of
+// generated beyond them are ranked by the quality check speculative drafts
+// must pass: those that pass come first, in the order generated. Content
+// becomes the first completion kept
+func (es *EnhancedSystem) pickChoices(input RequestInput, prompt string, response *ProcessResponse) {
+	if keep := input.Choices(); len(response.Choices) > keep {
+		var passed, failed []Choice
+		for _, choice := range response.Choices {
+			if es.speculativeCheck.Check(prompt, choice.Content).Passed {
+				passed = append(passed, choice)
+			} else {
+				failed = append(failed, choice)
+			}
+		}
+		response.Choices = append(passed, failed...)[:keep]
+	}
+	for i := range response.Choices {
+		response.Choices[i].Index = i
+	}
+	response.Content = response.Choices[0].Content
+}
+
+// finishChoices passes the completions after the first, which the response's
+// Content already went through, through artifact rewriting, response
+// processing and the safety filter. Completions the filter blocks are
+// withheld rather than failing the request
+func (es *EnhancedSystem) finishChoices(ctx context.Context, input RequestInput, response *ProcessResponse) {
+	if len(response.Choices) == 0 {
+		return
+	}
+	choices := []Choice{{Content: response.Content}}
+	withheld := 0
+	for _, choice := range response.Choices[1:] {
+		extra := &ProcessResponse{Content: choice.Content, Metadata: make(map[string]interface{})}
+		es.rewriteArtifacts(ctx, extra)
+		es.postProcess(ctx, input, extra)
+		if err := es.classifyResponse(ctx, extra); err != nil {
+			withheld++
+			continue
+		}
+		choices = append(choices, Choice{Index: len(choices), Content: extra.Content})
+	}
+	response.Choices = choices
+	if result, ok := response.Metadata["choices"].(ChoiceResult); ok && withheld > 0 {
+		result.Withheld = withheld
+		response.Metadata["choices"] = result
+	}
+}
@@ -0,0 +1,68 @@
+package enhanced
+
+import (
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/classify"
+)
+
+// cachedAnalyzer reuses the analysis of prompts with the same fingerprint
+// instead of analyzing them again
+type cachedAnalyzer struct {
+	analyzer ComplexityAnalyzer
+	cache    *classify.Cache[TaskComplexity]
+}
+
+// AnalyzeComplexity implements ComplexityAnalyzer. Failed analyses are not
+// cached, and callers get their own copy of a cached one
+func (ca *cachedAnalyzer) AnalyzeComplexity(content string) (*TaskComplexity, error) {
+	key := classify.Fingerprint(content)
+	if cached, ok := ca.cache.Get(key, time.Now()); ok {
+		return copyComplexity(cached), nil
+	}
+
+	complexity, err := ca.analyzer.AnalyzeComplexity(content)
+	if err != nil {
+		return nil, err
+	}
+	ca.cache.Put(key, *copyComplexity(*complexity), time.Now())
+	return complexity, nil
+}
+
+// copyComplexity copies an analysis so the copy's capabilities and metadata
+// can be changed on their own
+func copyComplexity(complexity TaskComplexity) *TaskComplexity {
+	complexity.RequiredCapabilities = append([]string(nil), complexity.RequiredCapabilities...)
+	metadata := make(map[string]interface{}, len(complexity.Metadata))
+	for key, value := range complexity.Metadata {
+		metadata[key] = value
+	}
+	complexity.Metadata = metadata
+	return &complexity
+}
+
+// SetClassificationCache caches the analyses of the complexity analyzer for
+// ttl, keeping at most maxEntries. A zero ttl analyzes every request again.
+// The built-in analyzer classifies prompts the same when they differ only in
+// case, spacing or numbers, which is what the fingerprint ignores
+func (es *EnhancedSystem) SetClassificationCache(ttl time.Duration, maxEntries int) {
+	analyzer := es.reasoner
+	if cached, ok := analyzer.(*cachedAnalyzer); ok {
+		analyzer = cached.analyzer
+	}
+	if ttl <= 0 {
+		es.reasoner = analyzer
+		return
+	}
+	es.reasoner = &cachedAnalyzer{analyzer: analyzer, cache: classify.NewCache[TaskComplexity](ttl, maxEntries)}
+}
+
+// ClassificationCacheStats returns the size and hit rate of the
+// classification cache, false when analyses are not cached
+func (es *EnhancedSystem) ClassificationCacheStats() (classify.Stats, bool) {
+	cached, ok := es.reasoner.(*cachedAnalyzer)
+	if !ok {
+		return classify.Stats{}, false
+	}
+	return cached.cache.Stats(), true
+}
@@ -0,0 +1,179 @@
+package enhanced
+
+import (
+	"context"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/probe"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/selection"
+)
+
+// coldStart is the warm-up policy, the prior of each provider and the
+// traffic warming providers served
+type coldStart struct {
+	policy  selection.WarmupPolicy
+	priors  map[string]selection.Prior
+	tracker *selection.WarmupTracker
+}
+
+// SetWarmupPolicy sets how providers without enough real traffic are routed.
+// Priors are derived once from each provider's tier and the model database,
+// and scoring uses health blended from them from now on
+func (es *EnhancedSystem) SetWarmupPolicy(policy selection.WarmupPolicy) {
+	if !policy.Enabled() {
+		es.coldStart = nil
+		return
+	}
+
+	database := selection.NewModelDatabase()
+	priors := make(map[string]selection.Prior, len(es.providers))
+	for _, provider := range es.providers {
+		models := make([]selection.ModelCapabilities, len(provider.Models))
+		for i, model := range provider.Models {
+			models[i] = database.LookupModelCapabilities(model, provider.Name)
+		}
+		priors[provider.Name] = policy.Prior(provider.Tier, models)
+	}
+	es.coldStart = &coldStart{policy: policy, priors: priors, tracker: selection.NewWarmupTracker()}
+	if selector := es.builtinSelector(); selector != nil {
+		selector.SetHealthEstimator(es.estimateHealth)
+	}
+}
+
+// WarmupEstimates returns the health estimate of every provider, or nil when
+// providers do not warm up
+func (es *EnhancedSystem) WarmupEstimates() map[string]selection.HealthEstimate {
+	if es.coldStart == nil {
+		return nil
+	}
+	estimates := make(map[string]selection.HealthEstimate, len(es.providers))
+	for _, provider := range es.providers {
+		estimates[provider.Name] = es.healthEstimate(provider)
+	}
+	return estimates
+}
+
+// healthEstimate blends the provider's prior, with the latency it showed in
+// the benchmark when it passed one, and the requests it served
+func (es *EnhancedSystem) healthEstimate(provider *Provider) selection.HealthEstimate {
+	prior, ok := es.coldStart.priors[provider.Name]
+	if !ok {
+		prior = es.coldStart.policy.Prior(provider.Tier, nil)
+	}
+	if passed, latencyMs := es.benchmark(provider.Name); passed && latencyMs > 0 {
+		prior.LatencyMs = latencyMs
+	}
+
+	metrics := es.healthMonitor.GetMetrics(provider.Name)
+	if metrics == nil {
+		return es.coldStart.policy.Estimate(prior, 0, selection.WindowedMetrics{})
+	}
+	observed := selection.WindowedMetrics{
+		Requests:         float64(metrics.TotalRequests),
+		SuccessRate:      metrics.SuccessRate,
+		AverageLatencyMs: metrics.AverageLatency,
+	}
+	if metrics.Windowed != nil {
+		observed = *metrics.Windowed
+	}
+	return es.coldStart.policy.Estimate(prior, metrics.TotalRequests, observed)
+}
+
+// estimateHealth is the selector's health estimator. TotalRequests stays the
+// real count, so latency constraints only apply to observed latency
+func (es *EnhancedSystem) estimateHealth(provider *Provider) *ProviderHealthMetrics {
+	estimate := es.healthEstimate(provider)
+	return &ProviderHealthMetrics{
+		SuccessRate:    estimate.SuccessRate,
+		ErrorRate:      1 - estimate.SuccessRate,
+		AverageLatency: estimate.LatencyMs,
+		TotalRequests:  estimate.Requests,
+	}
+}
+
+// benchmark reports whether the provider passed the synthetic benchmark,
+// its capability probe, and its mean latency in the checks it passed
+func (es *EnhancedSystem) benchmark(name string) (bool, float64) {
+	if es.capabilityProbe == nil {
+		return false, 0
+	}
+	result, ok := es.capabilityProbe.store.Get(name)
+	if !ok || result.Error != "" {
+		return false, 0
+	}
+
+	var total, passed int64
+	for _, check := range result.Checks {
+		if check.Status == probe.Supported {
+			total += check.LatencyMs
+			passed++
+		}
+	}
+	if passed == 0 {
+		return true, 0
+	}
+	return true, float64(total) / float64(passed)
+}
+
+// warming reports whether the provider has yet to serve enough requests
+func (es *EnhancedSystem) warming(name string) bool {
+	metrics := es.healthMonitor.GetMetrics(name)
+	return metrics == nil || !es.coldStart.policy.Warm(metrics.TotalRequests)
+}
+
+// applyWarmup keeps warming providers that have not passed a required
+// benchmark out of selection, and while warm providers are allowed as well,
+// those over their traffic share. A request with a seed admits each warming
+// provider with the probability of its traffic share, drawn from the seed.
+// It reports whether it left any out
+func (es *EnhancedSystem) applyWarmup(ctx context.Context, constraints selection.RequestConstraints) (selection.RequestConstraints, bool) {
+	if es.coldStart == nil {
+		return constraints, false
+	}
+	seed, seeded := selection.SeedFromContext(ctx)
+
+	var warm, benchmarked, admitted []string
+	considered := 0
+	for _, provider := range es.providers {
+		if len(constraints.AllowedProviders) > 0 && !containsFold(constraints.AllowedProviders, provider.Name) {
+			continue
+		}
+		considered++
+		if !es.warming(provider.Name) {
+			warm = append(warm, provider.Name)
+			continue
+		}
+		if passed, _ := es.benchmark(provider.Name); es.coldStart.policy.RequireBenchmark && !passed {
+			continue
+		}
+		benchmarked = append(benchmarked, provider.Name)
+		share := es.coldStart.policy.TrafficShare
+		if seeded && selection.SeededDraw(seed, "warmup", provider.Name) < share || !seeded && es.coldStart.tracker.Admit(provider.Name, share) {
+			admitted = append(admitted, provider.Name)
+		}
+	}
+
+	// Without warm providers every provider warms up, as after a fresh
+	// deployment, and the traffic share does not apply
+	allowed := append(warm, admitted...)
+	if len(warm) == 0 {
+		allowed = benchmarked
+	}
+	if len(allowed) == 0 || len(allowed) == considered {
+		return constraints, false
+	}
+	constraints.AllowedProviders = allowed
+	return constraints, true
+}
+
+// recordWarmup counts a request routed to provider against the traffic share
+// and notes in the assignment when the provider is warming up
+func (es *EnhancedSystem) recordWarmup(assignment *ProviderAssignment) {
+	if es.coldStart == nil {
+		return
+	}
+	warming := es.warming(assignment.Provider.Name)
+	es.coldStart.tracker.Record(assignment.Provider.Name, warming)
+	if warming {
+		assignment.Metadata["warmup"] = es.healthEstimate(assignment.Provider)
+	}
+}
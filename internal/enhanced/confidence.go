@@ -0,0 +1,87 @@
+package enhanced
+
+import (
+	"context"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/internal/components"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/hooks"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/requestid"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/selection"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
+)
+
+// SetConfidencePolicy sets the minimum confidence a selection needs and what
+// happens to requests below it
+func (es *EnhancedSystem) SetConfidencePolicy(policy selection.ConfidencePolicy) {
+	es.confidencePolicy = policy
+}
+
+// applyConfidencePolicy checks the confidence of assignment against the
+// policy. Requests that pin a model are routed as asked. Below the minimum,
+// escalation selects again among the highest tier the request accepts, and
+// the other fallbacks refuse the request with the candidates
+func (es *EnhancedSystem) applyConfidencePolicy(ctx context.Context, request *hooks.Request, assignment *ProviderAssignment, complexity components.TaskComplexity, requiredCapabilities []string, constraints selection.RequestConstraints) (*ProviderAssignment, selection.RequestConstraints, error) {
+	policy := es.confidencePolicy
+	if !policy.Enabled() || constraints.Model != "" {
+		return assignment, constraints, nil
+	}
+	assignment.Metadata["confidence"] = assignment.Confidence
+	if policy.Accepts(assignment.Confidence) {
+		return assignment, constraints, nil
+	}
+
+	log := logger.WithField(requestid.Field, requestid.FromContext(ctx))
+	if policy.Fallback != selection.FallbackEscalate {
+		return nil, constraints, &selection.AmbiguousRoutingError{
+			Confidence:    assignment.Confidence,
+			MinConfidence: policy.MinConfidence,
+			Fallback:      policy.Fallback,
+			Candidates:    routingCandidates(assignment),
+		}
+	}
+
+	// Nothing the request accepts ranks higher, so the selection stands
+	highest := selection.HighestTier(constraints.TierPreference)
+	if assignment.Provider.Tier.AtLeast(highest) {
+		log.Warnf("Selected %s with confidence %.2f, below %.2f, and no higher tier to escalate to", assignment.Provider.Name, assignment.Confidence, policy.MinConfidence)
+		assignment.Metadata["low_confidence"] = true
+		return assignment, constraints, nil
+	}
+
+	escalated := constraints
+	escalated.TierPreference = []tier.Tier{highest}
+	escalatedAssignment, escalatedConstraints, err := es.selectProvider(ctx, request, complexity, requiredCapabilities, escalated)
+	if err != nil {
+		log.Warnf("Failed to escalate %s with confidence %.2f to the %s tier: %v", assignment.Provider.Name, assignment.Confidence, highest, err)
+		assignment.Metadata["low_confidence"] = true
+		return assignment, constraints, nil
+	}
+	escalatedAssignment.Metadata["confidence"] = escalatedAssignment.Confidence
+	escalatedAssignment.Metadata["confidence_escalation"] = selection.Escalation{
+		From:          assignment.Provider.Name,
+		Confidence:    assignment.Confidence,
+		MinConfidence: policy.MinConfidence,
+		Tier:          highest,
+	}
+	log.Infof("Escalated %s with confidence %.2f to %s", assignment.Provider.Name, assignment.Confidence, escalatedAssignment.Provider.Name)
+
+	// Only the escalated selection is limited to the tier; drafts of
+	// speculative requests may still come from any tier
+	escalatedConstraints.TierPreference = constraints.TierPreference
+	return escalatedAssignment, escalatedConstraints, nil
+}
+
+// routingCandidates lists the selected provider and its alternatives with
+// the models a client may pin
+func routingCandidates(assignment *ProviderAssignment) []selection.RoutingCandidate {
+	providers := append([]*Provider{assignment.Provider}, assignment.Alternatives...)
+	candidates := make([]selection.RoutingCandidate, len(providers))
+	for i, provider := range providers {
+		candidates[i] = selection.RoutingCandidate{
+			ProviderID: provider.Name,
+			Tier:       provider.Tier,
+			Models:     provider.Models,
+		}
+	}
+	return candidates
+}
@@ -0,0 +1,127 @@
+package enhanced
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/credentials"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/probe"
+)
+
+// ErrCredentialsDisabled is returned by key rotation when no credential
+// store is set
+var ErrCredentialsDisabled = errors.New("provider key rotation is not enabled")
+
+// providerCredentials is the store and prober set by SetCredentials
+type providerCredentials struct {
+	store  *credentials.Store
+	prober *probe.Prober
+}
+
+// SetCredentials enables blue/green rotation of provider keys kept in store;
+// staged keys are validated by probing the provider with prober
+func (es *EnhancedSystem) SetCredentials(store *credentials.Store, prober *probe.Prober) {
+	es.credentials = &providerCredentials{store: store, prober: prober}
+}
+
+// ProviderAPIKey returns the key calls to a provider are sent with, empty
+// when key rotation is not enabled. Executors read it per call, so a
+// promoted key takes effect on the next request
+func (es *EnhancedSystem) ProviderAPIKey(providerName string) string {
+	if es.credentials == nil {
+		return ""
+	}
+	return es.credentials.store.Key(providerName)
+}
+
+// ProviderCredentials returns the key status of every provider
+func (es *EnhancedSystem) ProviderCredentials() ([]*credentials.Status, error) {
+	if es.credentials == nil {
+		return nil, ErrCredentialsDisabled
+	}
+	statuses := make([]*credentials.Status, 0, len(es.providers))
+	for _, provider := range es.providers {
+		statuses = append(statuses, es.credentials.store.Status(provider.Name))
+	}
+	return statuses, nil
+}
+
+// ProviderCredential returns the key status of one provider
+func (es *EnhancedSystem) ProviderCredential(providerName string) (*credentials.Status, error) {
+	provider, err := es.credentialProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+	return es.credentials.store.Status(provider.Name), nil
+}
+
+// StageProviderKey stages a new key for a provider next to its active one
+func (es *EnhancedSystem) StageProviderKey(providerName, key string) (*credentials.Status, error) {
+	provider, err := es.credentialProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+	return es.credentials.store.Stage(provider.Name, key)
+}
+
+// ValidateProviderKey probes a provider with its staged key, using its first
+// model, and records whether the provider answered. The probe result is not
+// kept with the capability probes, which describe the active key
+func (es *EnhancedSystem) ValidateProviderKey(ctx context.Context, providerName string) (*credentials.Status, *probe.Result, error) {
+	provider, err := es.credentialProvider(providerName)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := es.credentials.store.Staged(provider.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(provider.Models) == 0 {
+		return nil, nil, fmt.Errorf("provider %s has no models to probe", provider.Name)
+	}
+
+	result := es.credentials.prober.Probe(ctx, probe.Target{
+		Provider: provider.Name,
+		BaseURL:  provider.BaseURL,
+		Model:    provider.Models[0],
+		APIKey:   key,
+	})
+	var probeErr error
+	if result.Error != "" {
+		probeErr = errors.New(result.Error)
+	}
+	status, err := es.credentials.store.RecordValidation(provider.Name, key, probeErr)
+	return status, result, err
+}
+
+// PromoteProviderKey switches a provider to its validated staged key and
+// retires the key it replaces
+func (es *EnhancedSystem) PromoteProviderKey(providerName string) (*credentials.Status, error) {
+	provider, err := es.credentialProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+	return es.credentials.store.Promote(provider.Name)
+}
+
+// DiscardProviderKey drops the staged key of a provider
+func (es *EnhancedSystem) DiscardProviderKey(providerName string) error {
+	provider, err := es.credentialProvider(providerName)
+	if err != nil {
+		return err
+	}
+	return es.credentials.store.Discard(provider.Name)
+}
+
+// credentialProvider returns the configured provider named providerName
+func (es *EnhancedSystem) credentialProvider(providerName string) (*Provider, error) {
+	if es.credentials == nil {
+		return nil, ErrCredentialsDisabled
+	}
+	provider := es.findProvider(providerName)
+	if provider == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, providerName)
+	}
+	return provider, nil
+}
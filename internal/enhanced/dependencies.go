@@ -0,0 +1,121 @@
+package enhanced
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/reasoning"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/sampling"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/selection"
+)
+
+// ComplexityAnalyzer estimates how demanding a request's content is
+type ComplexityAnalyzer interface {
+	AnalyzeComplexity(content string) (*TaskComplexity, error)
+}
+
+// PromptOptimizer rewrites a prompt for the complexity of its task
+type PromptOptimizer interface {
+	OptimizePrompt(prompt string, complexity TaskComplexity) (string, error)
+}
+
+// ProviderSelector chooses the provider and model that answer a request and
+// tracks the requests in flight on each provider
+type ProviderSelector interface {
+	SelectProviderWithConstraints(ctx context.Context, complexity TaskComplexity, requiredCapabilities []string, constraints selection.RequestConstraints) (*ProviderAssignment, error)
+	SelectDraftProvider(complexity TaskComplexity, requiredCapabilities []string, constraints selection.RequestConstraints) (*ProviderAssignment, error)
+	AcquireProvider(providerName string) func()
+	InFlight() map[string]int
+	GetProviderStats() map[string]interface{}
+}
+
+var _ ProviderSelector = (*EnhancedProviderSelector)(nil)
+
+// Executor sends a prompt to the provider and model it was assigned to
+type Executor interface {
+	Execute(ctx context.Context, call ProviderCall) (*ProcessResponse, error)
+}
+
+// ProviderCall is one prompt sent to a provider. Tokens is the estimate for
+// the prompt, the system prompt and the reasoning together, of every
+// completion the call generates
+type ProviderCall struct {
+	Input        RequestInput
+	Assignment   *ProviderAssignment
+	SystemPrompt string
+	Prompt       string
+	Tokens       int64
+	// Reasoning is the effort to send a reasoning model with, nil when the
+	// request asks for none or the model does not reason
+	Reasoning *reasoning.Setting
+	// Sampling is the request's sampling parameters as the provider's API
+	// names them
+	Sampling sampling.Translation
+	// Choices is how many completions the call generates, which Sampling
+	// carries as the API names it. Executors return those above 1 in
+	// ProcessResponse.Choices
+	Choices int
+}
+
+// Option replaces a component of an EnhancedSystem when it is created
+type Option func(*EnhancedSystem)
+
+// WithComplexityAnalyzer replaces the task reasoner that analyzes requests
+func WithComplexityAnalyzer(analyzer ComplexityAnalyzer) Option {
+	return func(es *EnhancedSystem) {
+		es.reasoner = analyzer
+	}
+}
+
+// WithPromptOptimizer replaces the SPO prompt optimizer
+func WithPromptOptimizer(optimizer PromptOptimizer) Option {
+	return func(es *EnhancedSystem) {
+		es.optimizer = optimizer
+	}
+}
+
+// WithProviderSelector replaces the built-in provider selector. Pareto
+// policies, tie breakers, load penalties, scorers and capability probes
+// configure the built-in selector only
+func WithProviderSelector(selector ProviderSelector) Option {
+	return func(es *EnhancedSystem) {
+		es.selector = selector
+	}
+}
+
+// WithExecutor replaces the executor that calls providers
+func WithExecutor(executor Executor) Option {
+	return func(es *EnhancedSystem) {
+		es.executor = executor
+	}
+}
+
+// builtinSelector returns the built-in selector, or nil when another was
+// given with WithProviderSelector
+func (es *EnhancedSystem) builtinSelector() *EnhancedProviderSelector {
+	selector, _ := es.selector.(*EnhancedProviderSelector)
+	return selector
+}
+
+// placeholderExecutor answers without calling the provider
+type placeholderExecutor struct{}
+
+// Execute echoes the prompt as the named provider and model would be asked
+// it, once per completion
+func (placeholderExecutor) Execute(ctx context.Context, call ProviderCall) (*ProcessResponse, error) {
+	content := fmt.Sprintf("Processed by %s using model %s: %s", call.Assignment.Provider.Name, call.Assignment.Model, call.Prompt)
+	response := &ProcessResponse{
+		Content:    content,
+		Provider:   call.Assignment.Provider,
+		Model:      call.Assignment.Model,
+		TokensUsed: call.Tokens,
+		Cost:       float64(call.Tokens) * call.Assignment.Provider.CostPerToken,
+		Metadata:   make(map[string]interface{}),
+	}
+	if call.Choices > 1 {
+		for i := range call.Choices {
+			response.Choices = append(response.Choices, Choice{Index: i, Content: content})
+		}
+	}
+	return response, nil
+}
@@ -1,22 +1,44 @@
 package enhanced
 
 import (
+	"cmp"
 	"context"
-	"encoding/csv"
 	"fmt"
 	"io"
-	"log"
-	"strconv"
+	"math"
+	"slices"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/probe"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/reasoning"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/selection"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
 )
 
 // EnhancedProviderSelector provides advanced provider selection with capability filtering
 type EnhancedProviderSelector struct {
 	providers         []*Provider
 	capabilityFilters map[string][]string
-	healthCalculator  *HealthScoreCalculator
-	costOptimizer     *CostBasedSelector
+	modelDatabase     *selection.ModelDatabase
+	paretoPolicy      selection.ParetoPolicy
+	capabilityProbes  *probe.Store
+	reasoningModels   *reasoning.Table
+	tieBreaker        *selection.TieBreaker
+	load              *selection.LoadTracker
+	loadCapacity      int
+	maxLoadPenalty    float64
+	scorers           []selection.Scorer
+	healthEstimator   func(provider *Provider) *ProviderHealthMetrics
+	throughputPenalty func(ctx context.Context, provider *Provider) (penalty float64, used, ceiling int64)
+	// weights replace the default selection weights for requests without
+	// their own; nil keeps the defaults
+	weights *selection.SelectionWeights
+	// mutex guards capabilityFilters and weights, which admins may replace
+	// while requests are selected
+	mutex sync.RWMutex
 }
 
 // NewEnhancedProviderSelector creates a new enhanced provider selector
@@ -29,82 +51,36 @@ func NewEnhancedProviderSelector(providers []*Provider) *EnhancedProviderSelecto
 			"creative":     {"gpt-4", "claude-3", "dall-e"},
 			"factual":      {"gpt-3.5", "claude-instant", "gemini"},
 		},
-		healthCalculator: NewHealthScoreCalculator(),
-		costOptimizer:    NewCostBasedSelector(nil, nil),
+		modelDatabase:    selection.NewModelDatabase(),
+		reasoningModels:  reasoning.DefaultTable(),
+		tieBreaker:       selection.NewTieBreaker(selection.TieBreakRoundRobin, selection.DefaultTieEpsilon),
+		load:             selection.NewLoadTracker(),
+		loadCapacity:     selection.DefaultLoadCapacity,
+		maxLoadPenalty:   selection.DefaultMaxLoadPenalty,
 	}
 }
 
 // LoadProvidersFromCSV loads providers from a CSV file
 func (eps *EnhancedProviderSelector) LoadProvidersFromCSV(reader io.Reader) ([]*Provider, error) {
-	csvReader := csv.NewReader(reader)
-	records, err := csvReader.ReadAll()
+	parsed, err := config.ReadProviderCSV(reader)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read CSV: %w", err)
+		return nil, err
+	}
+	if parsed.Version < config.CSVSchemaVersion {
+		logger.Warnf("Provider CSV uses schema v%d; run migrate-csv to upgrade it to v%d", parsed.Version, config.CSVSchemaVersion)
 	}
 
 	var providers []*Provider
-	for i, record := range records {
-		if i == 0 { // Skip header
-			continue
-		}
-
-		if len(record) < 6 {
-			log.Printf("Skipping invalid record at line %d: insufficient fields", i+1)
-			continue
-		}
-
-		// Parse max tokens
-		maxTokens, err := strconv.ParseInt(strings.TrimSpace(record[3]), 10, 64)
-		if err != nil {
-			log.Printf("Invalid max_tokens at line %d: %v", i+1, err)
-			maxTokens = 4096 // Default value
-		}
-
-		// Parse cost per token
-		costPerToken, err := strconv.ParseFloat(strings.TrimSpace(record[5]), 64)
+	for _, row := range parsed.Providers {
+		providerTier, err := tier.Parse(row.Tier.String())
 		if err != nil {
-			log.Printf("Invalid cost_per_token at line %d: %v", i+1, err)
-			costPerToken = 0.00003 // Default value
-		}
-
-		// Parse tier
-		tierStr := strings.ToLower(strings.TrimSpace(record[1]))
-		var tier ProviderTier
-		switch tierStr {
-		case "official":
-			tier = OfficialTier
-		case "community":
-			tier = CommunityTier
-		case "unofficial":
-			tier = UnofficialTier
-		default:
-			tier = CommunityTier // Default
-		}
-
-		// Parse models (comma-separated)
-		modelsStr := strings.TrimSpace(record[4])
-		var models []string
-		if modelsStr != "" {
-			models = strings.Split(modelsStr, ",")
-			for j := range models {
-				models[j] = strings.TrimSpace(models[j])
-			}
+			logger.Warnf("Provider %s: %v, using %s", row.Name, err, CommunityTier)
+			providerTier = CommunityTier
 		}
 
-		provider := &Provider{
-			Name:         strings.TrimSpace(record[0]),
-			BaseURL:      strings.TrimSpace(record[2]),
-			Models:       models,
-			Tier:         tier,
-			MaxTokens:    maxTokens,
-			CostPerToken: costPerToken,
-			Capabilities: []string{"reasoning", "creative", "factual"}, // Default capabilities
-			RateLimits:   map[string]int64{"requests_per_minute": 60},
-			Metadata:     make(map[string]interface{}),
-			LastUpdated:  time.Now(),
-		}
-
-		providers = append(providers, provider)
+		cfg := row.ProviderConfig()
+		cfg.Tier = providerTier
+		providers = append(providers, NewProvider(cfg))
 	}
 
 	return providers, nil
@@ -112,48 +88,498 @@ func (eps *EnhancedProviderSelector) LoadProvidersFromCSV(reader io.Reader) ([]*
 
 // SelectProviderWithCapabilities selects a provider based on task complexity and required capabilities
 func (eps *EnhancedProviderSelector) SelectProviderWithCapabilities(ctx context.Context, complexity TaskComplexity, requiredCapabilities []string) (*ProviderAssignment, error) {
+	return eps.SelectProviderWithConstraints(ctx, complexity, requiredCapabilities, selection.RequestConstraints{})
+}
+
+// scoringScratch holds the slices one selection scores providers in, reused
+// across requests so selection allocates little beyond its assignment
+type scoringScratch struct {
+	compatible []*Provider
+	scores     []ProviderScore
+	others     []float64
+	ties       []selection.TieCandidate
+}
+
+// scoringScratchPool recycles scoring scratch between selections
+var scoringScratchPool = sync.Pool{
+	New: func() interface{} { return new(scoringScratch) },
+}
+
+// release clears the scratch, so it keeps no providers alive, and returns it
+// to the pool
+func (s *scoringScratch) release() {
+	clear(s.compatible[:cap(s.compatible)])
+	clear(s.scores[:cap(s.scores)])
+	clear(s.ties[:cap(s.ties)])
+	s.compatible, s.scores, s.others, s.ties = s.compatible[:0], s.scores[:0], s.others[:0], s.ties[:0]
+	scoringScratchPool.Put(s)
+}
+
+// SelectProviderWithConstraints selects a provider like SelectProviderWithCapabilities,
+// skipping providers that violate the request constraints. When none is left
+// the error is a *selection.ConstraintError listing every rejection. Scores
+// carry reasoning only when ctx asks for it with selection.WithExplain
+func (eps *EnhancedProviderSelector) SelectProviderWithConstraints(ctx context.Context, complexity TaskComplexity, requiredCapabilities []string, constraints selection.RequestConstraints) (*ProviderAssignment, error) {
 	if len(eps.providers) == 0 {
 		return nil, fmt.Errorf("no providers available")
 	}
+	explain := selection.ExplainFromContext(ctx)
+	seed, seeded := selection.SeedFromContext(ctx)
+	scratch := scoringScratchPool.Get().(*scoringScratch)
+	defer scratch.release()
 
 	// Filter providers by capabilities
-	compatibleProviders := eps.filterProvidersByCapabilities(requiredCapabilities)
+	scratch.compatible = eps.filterProvidersByCapabilities(scratch.compatible, requiredCapabilities)
+	compatibleProviders := scratch.compatible
 	if len(compatibleProviders) == 0 {
 		// Fallback to all providers if no exact matches
 		compatibleProviders = eps.providers
 	}
 
 	// Score providers
-	var scores []ProviderScore
+	weights := constraints.Weights
+	if weights == nil {
+		weights = eps.defaultWeights()
+	}
+	scores := scratch.scores
+	var rejections []selection.Rejection
 	for _, provider := range compatibleProviders {
-		score := eps.scoreProviderForComplexity(provider, complexity)
+		if rejected := constraints.Check(eps.constraintCandidate(provider, complexity)); len(rejected) > 0 {
+			rejections = append(rejections, rejected...)
+			continue
+		}
+		score := eps.scoreProviderForComplexity(provider, complexity, weights, explain)
+		eps.applyLoadPenalty(&score, explain)
+		eps.applyThroughputPenalty(ctx, &score, explain)
 		scores = append(scores, score)
 	}
+	scratch.scores = scores
+	if len(scores) > 0 {
+		var err error
+		if scores, rejections, err = eps.applyScorers(ctx, scores, rejections, complexity, requiredCapabilities, constraints, explain); err != nil {
+			return nil, err
+		}
+	}
+	if len(scores) == 0 {
+		return nil, &selection.ConstraintError{Constraints: constraints, Rejections: rejections}
+	}
 
-	// Sort by score (highest first)
-	scores = sortProvidersByScore(scores)
+	// Sort by tier and provider preference, then score (highest first)
+	slices.SortStableFunc(scores, func(a, b ProviderScore) int {
+		if rank := cmp.Compare(constraints.Rank(a.Provider.Tier, a.Provider.Name), constraints.Rank(b.Provider.Tier, b.Provider.Name)); rank != 0 {
+			return rank
+		}
+		return cmp.Compare(b.Score, a.Score)
+	})
 
-	// Select best provider
+	// Select best provider, from the Pareto front when a policy is in effect
 	bestScore := scores[0]
-	
+	policy := eps.paretoPolicy
+	if constraints.ParetoPolicy != selection.ParetoOff {
+		policy = constraints.ParetoPolicy
+	}
+	decision := eps.decide(scores, complexity, constraints, policy)
+	if decision != nil {
+		for _, score := range scores {
+			if score.Provider.Name == decision.Chosen {
+				bestScore = score
+				break
+			}
+		}
+	}
+	var tieBreak *selection.TieBreak
+	if decision == nil {
+		var tieSeed *int64
+		if seeded {
+			tieSeed = &seed
+		}
+		bestScore, tieBreak = eps.breakTie(scores, constraints, tieSeed, scratch)
+	}
+
+	// A requested model is used as is; otherwise the provider's models are ranked
+	var model string
+	var modelScores []selection.ModelScore
+	if constraints.Model != "" {
+		model, _ = selection.MatchModel(bestScore.Provider.Models, constraints.Model)
+	} else {
+		model, modelScores = eps.selectBestModel(bestScore.Provider, complexity, requiredCapabilities, explain)
+	}
+
+	// Confidence weighs how well the provider fits against how clearly it
+	// beat the others
+	otherScores := scratch.others
+	var alternatives []*Provider
+	if len(scores) > 1 {
+		alternatives = make([]*Provider, 0, min(len(scores)-1, maxAlternatives))
+	}
+	for _, score := range scores {
+		if score.Provider == bestScore.Provider {
+			continue
+		}
+		otherScores = append(otherScores, score.Score)
+		if len(alternatives) < maxAlternatives {
+			alternatives = append(alternatives, score.Provider)
+		}
+	}
+	scratch.others = otherScores
+
 	assignment := &ProviderAssignment{
 		Provider:        bestScore.Provider,
-		Model:          eps.selectBestModel(bestScore.Provider, complexity),
-		Confidence:     bestScore.Confidence,
+		Model:          model,
+		Confidence:     selection.CalibrateConfidence(bestScore.Score, otherScores, maxProviderScore),
 		EstimatedCost:  float64(complexity.TokenEstimate) * bestScore.Provider.CostPerToken,
 		EstimatedTokens: complexity.TokenEstimate,
 		Reasoning:      bestScore.Reasoning,
-		Alternatives:   []*Provider{}, // Could populate with other high-scoring providers
+		Alternatives:   alternatives,
 		Metadata:       make(map[string]interface{}),
 	}
+	assignment.Metadata["selection_score"] = bestScore.Score
+	if !constraints.IsZero() {
+		assignment.Metadata["constraints"] = constraints
+		if len(rejections) > 0 {
+			assignment.Metadata["rejected_providers"] = rejections
+		}
+	}
+	if len(modelScores) > 0 {
+		assignment.Metadata["model_scores"] = modelScores
+	}
+	if decision != nil {
+		assignment.Metadata["decision"] = decision
+		if explain {
+			assignment.Reasoning += ". Pareto decision: " + decision.Tradeoff
+		}
+	}
+	if tieBreak != nil {
+		assignment.Metadata["tie_break"] = tieBreak
+	}
+	if seeded {
+		assignment.Metadata["seed"] = seed
+	}
 
 	return assignment, nil
 }
 
-// filterProvidersByCapabilities filters providers based on required capabilities
-func (eps *EnhancedProviderSelector) filterProvidersByCapabilities(requiredCapabilities []string) []*Provider {
-	var compatibleProviders []*Provider
+// SelectDraftProvider selects the cheapest provider that has the required
+// capabilities and meets the constraints, to draft an answer that a better
+// provider verifies only if needed
+func (eps *EnhancedProviderSelector) SelectDraftProvider(complexity TaskComplexity, requiredCapabilities []string, constraints selection.RequestConstraints) (*ProviderAssignment, error) {
+	compatibleProviders := eps.filterProvidersByCapabilities(nil, requiredCapabilities)
+	if len(compatibleProviders) == 0 {
+		compatibleProviders = eps.providers
+	}
+
+	var cheapest *Provider
+	for _, provider := range compatibleProviders {
+		if len(constraints.Check(eps.constraintCandidate(provider, complexity))) > 0 {
+			continue
+		}
+		if cheapest == nil || provider.CostPerToken < cheapest.CostPerToken {
+			cheapest = provider
+		}
+	}
+	if cheapest == nil {
+		return nil, fmt.Errorf("no provider can draft the request")
+	}
+
+	model, _ := eps.selectBestModel(cheapest, complexity, requiredCapabilities, false)
+	return &ProviderAssignment{
+		Provider:        cheapest,
+		Model:           model,
+		EstimatedCost:   float64(complexity.TokenEstimate) * cheapest.CostPerToken,
+		EstimatedTokens: complexity.TokenEstimate,
+		Reasoning:       "Cheapest capable provider, drafting for verification",
+		Metadata:        make(map[string]interface{}),
+	}, nil
+}
+
+// SetHealthEstimator makes scoring use the health estimator returns for a
+// provider instead of its own HealthMetrics, e.g. blended with a prior
+func (eps *EnhancedProviderSelector) SetHealthEstimator(estimator func(provider *Provider) *ProviderHealthMetrics) {
+	eps.healthEstimator = estimator
+}
+
+// healthMetrics returns the health scoring uses for provider, nil if unknown
+func (eps *EnhancedProviderSelector) healthMetrics(provider *Provider) *ProviderHealthMetrics {
+	if eps.healthEstimator != nil {
+		return eps.healthEstimator(provider)
+	}
+	return provider.HealthMetrics
+}
+
+// SetParetoPolicy sets the default Pareto policy; requests may override it
+func (eps *EnhancedProviderSelector) SetParetoPolicy(policy selection.ParetoPolicy) {
+	eps.paretoPolicy = policy
+}
+
+// SetCapabilityProbes makes capability checks use the features verified by
+// probes in store ahead of declared capabilities and model names
+func (eps *EnhancedProviderSelector) SetCapabilityProbes(store *probe.Store) {
+	eps.capabilityProbes = store
+}
+
+// SetReasoningModels sets the table that tells which models spend reasoning
+// tokens
+func (eps *EnhancedProviderSelector) SetReasoningModels(table *reasoning.Table) {
+	eps.reasoningModels = table
+}
+
+// SetTieBreaker sets how providers scoring within epsilon of the best share
+// traffic
+func (eps *EnhancedProviderSelector) SetTieBreaker(tieBreaker *selection.TieBreaker) {
+	eps.tieBreaker = tieBreaker
+}
+
+// SetLoadPenalty sets how in-flight requests lower a provider's score: a
+// provider with capacity requests in flight, or its max_concurrent rate limit
+// when set, loses maxPenalty. A zero maxPenalty disables load-aware scoring
+func (eps *EnhancedProviderSelector) SetLoadPenalty(capacity int, maxPenalty float64) {
+	eps.loadCapacity = capacity
+	eps.maxLoadPenalty = maxPenalty
+}
+
+// SetThroughputPenalty sets what a provider's score loses for the tokens per
+// minute its upstream account used, with the usage and ceiling for the
+// reasoning
+func (eps *EnhancedProviderSelector) SetThroughputPenalty(penalty func(ctx context.Context, provider *Provider) (penalty float64, used, ceiling int64)) {
+	eps.throughputPenalty = penalty
+}
+
+// SetScorers sets the scorers that adjust provider scores, in order, after
+// the built-in scoring
+func (eps *EnhancedProviderSelector) SetScorers(scorers []selection.Scorer) {
+	eps.scorers = scorers
+}
+
+// AcquireProvider counts a request in flight on the named provider until
+// release is called
+func (eps *EnhancedProviderSelector) AcquireProvider(name string) (release func()) {
+	return eps.load.Acquire(name)
+}
+
+// InFlight returns the requests in flight on each provider that has any
+func (eps *EnhancedProviderSelector) InFlight() map[string]int {
+	return eps.load.Snapshot()
+}
+
+// applyLoadPenalty lowers score by the load penalty of its provider's
+// requests in flight
+func (eps *EnhancedProviderSelector) applyLoadPenalty(score *ProviderScore, explain bool) {
+	inFlight := eps.load.InFlight(score.Provider.Name)
+	capacity := eps.loadCapacity
+	if limit := score.Provider.RateLimits["max_concurrent"]; limit > 0 {
+		capacity = int(limit)
+	}
+	penalty := selection.LoadPenalty(inFlight, capacity, eps.maxLoadPenalty)
+	if penalty == 0 {
+		return
+	}
+	score.Score -= penalty
+	if explain {
+		score.Reasoning += fmt.Sprintf(", Load %d/%d in flight (-%.2f)", inFlight, capacity, penalty)
+	}
+}
+
+// applyThroughputPenalty lowers score by the throughput penalty of its
+// provider's account
+func (eps *EnhancedProviderSelector) applyThroughputPenalty(ctx context.Context, score *ProviderScore, explain bool) {
+	if eps.throughputPenalty == nil {
+		return
+	}
+	penalty, used, ceiling := eps.throughputPenalty(ctx, score.Provider)
+	if penalty == 0 {
+		return
+	}
+	score.Score -= penalty
+	if explain {
+		score.Reasoning += fmt.Sprintf(", Throughput %d/%d TPM (-%.2f)", used, ceiling, penalty)
+	}
+}
+
+// applyScorers runs the configured scorers over scores. Their adjustments are
+// added to the scores, and providers they exclude are moved to rejections
+func (eps *EnhancedProviderSelector) applyScorers(ctx context.Context, scores []ProviderScore, rejections []selection.Rejection, complexity TaskComplexity, requiredCapabilities []string, constraints selection.RequestConstraints, explain bool) ([]ProviderScore, []selection.Rejection, error) {
+	for _, scorer := range eps.scorers {
+		request := selection.ScoreRequest{
+			Complexity:   complexity,
+			Capabilities: requiredCapabilities,
+			Model:        constraints.Model,
+			Candidates:   make([]selection.ScoreCandidate, len(scores)),
+		}
+		for i, score := range scores {
+			request.Candidates[i] = selection.ScoreCandidate{
+				Provider:     score.Provider.Name,
+				Tier:         score.Provider.Tier,
+				Models:       score.Provider.Models,
+				Capabilities: score.Provider.Capabilities,
+				Score:        score.Score,
+				CostPerToken: score.Provider.CostPerToken,
+				InFlight:     eps.load.InFlight(score.Provider.Name),
+			}
+		}
+		adjustment, err := scorer.Score(ctx, request)
+		if err != nil {
+			return nil, nil, fmt.Errorf("scorer %s failed: %w", scorer.Name(), err)
+		}
+		if adjustment.IsZero() {
+			continue
+		}
+
+		kept := scores[:0]
+		for _, score := range scores {
+			if reason, ok := adjustment.Exclude[score.Provider.Name]; ok {
+				rejections = append(rejections, selection.Rejection{
+					ProviderID: score.Provider.Name,
+					Constraint: "scorer:" + scorer.Name(),
+					Reason:     reason,
+				})
+				continue
+			}
+			if delta := adjustment.Adjustments[score.Provider.Name]; delta != 0 {
+				score.Score += delta
+				if explain {
+					score.Reasoning += fmt.Sprintf(", Scorer %s %+.2f", scorer.Name(), delta)
+					if adjustment.Reasoning != "" {
+						score.Reasoning += " (" + adjustment.Reasoning + ")"
+					}
+				}
+			}
+			kept = append(kept, score)
+		}
+		if scores = kept; len(scores) == 0 {
+			break
+		}
+	}
+	return scores, rejections, nil
+}
+
+// breakTie chooses among the providers of the most preferred rank that score
+// within epsilon of the best, weighting each by its requests per minute, and
+// draws the choice from seed when one is given. scores must be sorted; the
+// tie is nil when the best has no equal
+func (eps *EnhancedProviderSelector) breakTie(scores []ProviderScore, constraints selection.RequestConstraints, seed *int64, scratch *scoringScratch) (ProviderScore, *selection.TieBreak) {
+	best := scores[0]
+	preferred := constraints.Rank(best.Provider.Tier, best.Provider.Name)
+	candidates := scratch.ties
+	defer func() { scratch.ties = candidates }()
+	for _, score := range scores {
+		if constraints.Rank(score.Provider.Tier, score.Provider.Name) != preferred || !eps.tieBreaker.Tied(best.Score, score.Score) {
+			break
+		}
+		candidates = append(candidates, selection.TieCandidate{
+			ProviderID: score.Provider.Name,
+			Weight:     float64(score.Provider.RateLimits["requests_per_minute"]),
+			Load:       eps.load.InFlight(score.Provider.Name),
+		})
+	}
+	if len(candidates) < 2 {
+		return best, nil
+	}
+
+	var chosen int
+	if seed != nil {
+		chosen = eps.tieBreaker.ChooseSeeded(candidates, *seed)
+	} else {
+		chosen = eps.tieBreaker.Choose(candidates)
+	}
+	tieBreak := &selection.TieBreak{
+		Strategy:   eps.tieBreaker.Strategy(),
+		Candidates: make([]string, len(candidates)),
+		Chosen:     candidates[chosen].ProviderID,
+		Seed:       seed,
+	}
+	for i, candidate := range candidates {
+		tieBreak.Candidates[i] = candidate.ProviderID
+	}
+	return scores[chosen], tieBreak
+}
+
+// decide places the most preferred rank's scored providers on the cost,
+// quality and latency objectives and chooses among them by policy
+func (eps *EnhancedProviderSelector) decide(scores []ProviderScore, complexity TaskComplexity, constraints selection.RequestConstraints, policy selection.ParetoPolicy) *selection.Decision {
+	if policy == selection.ParetoOff {
+		return nil
+	}
+
+	preferred := constraints.Rank(scores[0].Provider.Tier, scores[0].Provider.Name)
+	var points []selection.ParetoPoint
+	for _, score := range scores {
+		if constraints.Rank(score.Provider.Tier, score.Provider.Name) != preferred {
+			continue
+		}
+		candidate := eps.constraintCandidate(score.Provider, complexity)
+		latencyScore := 0.7 // No latency observed yet
+		if candidate.Latency > 0 {
+			latencyScore = 1 - math.Min(candidate.Latency.Seconds()/5, 0.9)
+		}
+		points = append(points, selection.ParetoPoint{
+			ProviderID:    score.Provider.Name,
+			Tier:          score.Provider.Tier,
+			EstimatedCost: candidate.EstimatedCost,
+			Quality:       candidate.Quality,
+			LatencyScore:  latencyScore,
+			TotalScore:    score.Score,
+		})
+	}
+	return selection.Decide(points, policy)
+}
+
+// tierWeights stands in for the quality of a provider in each tier
+var tierWeights = map[ProviderTier]float64{
+	OfficialTier:   1.0,
+	CommunityTier:  0.8,
+	UnofficialTier: 0.6,
+}
+
+// constraintCandidate describes a provider for request constraint checks. The
+// tier weight stands in for quality until per-model quality data exists
+func (eps *EnhancedProviderSelector) constraintCandidate(provider *Provider, complexity TaskComplexity) selection.Candidate {
+	candidate := selection.Candidate{
+		ProviderID:    provider.Name,
+		Tier:          provider.Tier,
+		EstimatedCost: float64(complexity.TokenEstimate) * provider.CostPerToken,
+		Quality:       tierWeights[provider.Tier],
+		Models:        provider.Models,
+		Capabilities:  providerFacts{selector: eps, provider: provider},
+	}
+	if metrics := eps.healthMetrics(provider); metrics != nil && metrics.TotalRequests > 0 {
+		candidate.Latency = time.Duration(metrics.AverageLatency * float64(time.Millisecond))
+	}
+	return candidate
+}
+
+// providerFacts evaluates capability expressions against a provider: names
+// are its tier or capabilities as providerHasCapability finds them, and
+// levels are the best of its models in the model database
+type providerFacts struct {
+	selector *EnhancedProviderSelector
+	provider *Provider
+}
+
+// HasCapability implements selection.CapabilityFacts
+func (f providerFacts) HasCapability(name string) bool {
+	return string(f.provider.Tier) == name || f.selector.providerHasCapability(f.provider, name)
+}
+
+// CapabilityLevel implements selection.CapabilityFacts
+func (f providerFacts) CapabilityLevel(name string) (float64, bool) {
+	best, known := 0.0, false
+	for _, model := range f.provider.Models {
+		capabilities := f.selector.modelDatabase.LookupModelCapabilities(model, f.provider.Name)
+		level, ok := selection.ProviderCapabilities{
+			Reasoning:   capabilities.Reasoning,
+			Knowledge:   capabilities.Knowledge,
+			Computation: capabilities.Computation,
+		}.Level(name)
+		if ok && (!known || level > best) {
+			best, known = level, true
+		}
+	}
+	return best, known
+}
 
+// filterProvidersByCapabilities appends the providers that have the required
+// capabilities to compatibleProviders
+func (eps *EnhancedProviderSelector) filterProvidersByCapabilities(compatibleProviders []*Provider, requiredCapabilities []string) []*Provider {
 	for _, provider := range eps.providers {
 		isCompatible := true
 		for _, requiredCap := range requiredCapabilities {
@@ -172,6 +598,13 @@ func (eps *EnhancedProviderSelector) filterProvidersByCapabilities(requiredCapab
 
 // providerHasCapability checks if a provider has a specific capability
 func (eps *EnhancedProviderSelector) providerHasCapability(provider *Provider, capability string) bool {
+	// A conclusive probe outranks what is declared or inferred
+	if eps.capabilityProbes != nil {
+		if supported, known := eps.capabilityProbes.Supports(provider.Name, capability); known {
+			return supported
+		}
+	}
+
 	// Check provider's declared capabilities
 	for _, cap := range provider.Capabilities {
 		if strings.EqualFold(cap, capability) {
@@ -179,8 +612,21 @@ func (eps *EnhancedProviderSelector) providerHasCapability(provider *Provider, c
 		}
 	}
 
+	// Models that spend reasoning tokens are known by name
+	if capability == reasoning.Capability {
+		for _, model := range provider.Models {
+			if eps.reasoningModels.Supports(model) {
+				return true
+			}
+		}
+		return false
+	}
+
 	// Check capability filters
-	if compatibleModels, exists := eps.capabilityFilters[capability]; exists {
+	eps.mutex.RLock()
+	compatibleModels, exists := eps.capabilityFilters[capability]
+	eps.mutex.RUnlock()
+	if exists {
 		for _, model := range provider.Models {
 			for _, compatibleModel := range compatibleModels {
 				if strings.Contains(strings.ToLower(model), strings.ToLower(compatibleModel)) {
@@ -193,28 +639,59 @@ func (eps *EnhancedProviderSelector) providerHasCapability(provider *Provider, c
 	return false
 }
 
-// scoreProviderForComplexity scores a provider based on task complexity
-func (eps *EnhancedProviderSelector) scoreProviderForComplexity(provider *Provider, complexity TaskComplexity) ProviderScore {
+// maxProviderScore is the score of a provider that earns every part of
+// scoreProviderForComplexity at the default weights
+const maxProviderScore = 1.2
+
+// maxAlternatives bounds the runners-up an assignment lists
+const maxAlternatives = 4
+
+// scoreProviderForComplexity scores a provider based on task complexity.
+// Request weights scale the tier, cost and health parts of the score relative
+// to the default selection weights; nil keeps them as they are. The score
+// explains itself only when explain is set
+func (eps *EnhancedProviderSelector) scoreProviderForComplexity(provider *Provider, complexity TaskComplexity, weights *selection.SelectionWeights, explain bool) ProviderScore {
 	score := 0.0
-	reasoning := "Provider scoring: "
+	var reasoning string
+	if explain {
+		reasoning = "Provider scoring: "
+	}
+	qualityFactor, costFactor, healthFactor := 1.0, 1.0, 1.0
+	if weights != nil {
+		defaults := selection.DefaultSelectionWeights
+		qualityFactor = weights.Quality / defaults.Quality
+		costFactor = weights.Cost / defaults.Cost
+		healthFactor = (weights.Latency + weights.Reliability) / (defaults.Latency + defaults.Reliability)
+		if explain {
+			reasoning = "Provider scoring with weights " + weights.String() + ": "
+		}
+	}
 
 	// Base score from tier
+	var tierScore float64
+	var tierName string
 	switch provider.Tier {
 	case OfficialTier:
-		score += 0.4
-		reasoning += "Official tier (+0.4), "
+		tierScore, tierName = 0.4, "Official"
 	case CommunityTier:
-		score += 0.2
-		reasoning += "Community tier (+0.2), "
+		tierScore, tierName = 0.2, "Community"
 	case UnofficialTier:
-		score += 0.1
-		reasoning += "Unofficial tier (+0.1), "
+		tierScore, tierName = 0.1, "Unofficial"
+	}
+	if tierName != "" {
+		tierScore *= qualityFactor
+		score += tierScore
+		if explain {
+			reasoning += fmt.Sprintf("%s tier (+%g), ", tierName, math.Round(tierScore*100)/100)
+		}
 	}
 
 	// Complexity-based scoring
 	complexityScore := float64(complexity.Overall) / float64(VeryHigh)
 	score += complexityScore * 0.3
-	reasoning += fmt.Sprintf("Complexity match (+%.2f), ", complexityScore*0.3)
+	if explain {
+		reasoning += fmt.Sprintf("Complexity match (+%.2f), ", complexityScore*0.3)
+	}
 
 	// Cost efficiency (lower cost = higher score)
 	if provider.CostPerToken > 0 {
@@ -222,144 +699,193 @@ func (eps *EnhancedProviderSelector) scoreProviderForComplexity(provider *Provid
 		if costScore > 0.2 {
 			costScore = 0.2 // Cap cost benefit
 		}
+		costScore *= costFactor
 		score += costScore
-		reasoning += fmt.Sprintf("Cost efficiency (+%.2f), ", costScore)
+		if explain {
+			reasoning += fmt.Sprintf("Cost efficiency (+%.2f), ", costScore)
+		}
 	}
 
 	// Token capacity
 	if provider.MaxTokens >= complexity.TokenEstimate {
 		score += 0.1
-		reasoning += "Sufficient tokens (+0.1), "
+		if explain {
+			reasoning += "Sufficient tokens (+0.1), "
+		}
 	}
 
 	// Health metrics (if available)
-	if provider.HealthMetrics != nil {
-		healthScore := eps.calculateHealthScore(provider)
-		score += healthScore * 0.2
-		reasoning += fmt.Sprintf("Health score (+%.2f), ", healthScore*0.2)
+	if metrics := eps.healthMetrics(provider); metrics != nil {
+		healthScore := calculateHealthScore(metrics) * 0.2 * healthFactor
+		score += healthScore
+		if explain {
+			reasoning += fmt.Sprintf("Health score (+%.2f), ", healthScore)
+		}
 	}
 
 	return ProviderScore{
 		Provider:   provider,
 		Score:      score,
-		Confidence: score, // Use score as confidence for now
+		Confidence: math.Min(score/maxProviderScore, 1),
 		Reasoning:  strings.TrimSuffix(reasoning, ", "),
 	}
 }
 
-// calculateHealthScore calculates a health score for a provider
-func (eps *EnhancedProviderSelector) calculateHealthScore(provider *Provider) float64 {
-	if provider.HealthMetrics == nil {
+// calculateHealthScore calculates a health score from a provider's metrics
+func calculateHealthScore(metrics *ProviderHealthMetrics) float64 {
+	if metrics == nil {
 		return 0.5 // Neutral score if no health data
 	}
 
 	score := 0.0
 	
 	// Success rate
-	if provider.HealthMetrics.SuccessRate > 0.9 {
+	if metrics.SuccessRate > 0.9 {
 		score += 0.4
-	} else if provider.HealthMetrics.SuccessRate > 0.8 {
+	} else if metrics.SuccessRate > 0.8 {
 		score += 0.3
-	} else if provider.HealthMetrics.SuccessRate > 0.7 {
+	} else if metrics.SuccessRate > 0.7 {
 		score += 0.2
 	}
 
 	// Average latency (lower is better)
-	if provider.HealthMetrics.AverageLatency < 1000 { // Less than 1 second
+	if metrics.AverageLatency < 1000 { // Less than 1 second
 		score += 0.3
-	} else if provider.HealthMetrics.AverageLatency < 3000 { // Less than 3 seconds
+	} else if metrics.AverageLatency < 3000 { // Less than 3 seconds
 		score += 0.2
-	} else if provider.HealthMetrics.AverageLatency < 5000 { // Less than 5 seconds
+	} else if metrics.AverageLatency < 5000 { // Less than 5 seconds
 		score += 0.1
 	}
 
 	// Error rate (lower is better)
-	if provider.HealthMetrics.ErrorRate < 0.05 { // Less than 5%
+	if metrics.ErrorRate < 0.05 { // Less than 5%
 		score += 0.3
-	} else if provider.HealthMetrics.ErrorRate < 0.1 { // Less than 10%
+	} else if metrics.ErrorRate < 0.1 { // Less than 10%
 		score += 0.2
-	} else if provider.HealthMetrics.ErrorRate < 0.2 { // Less than 20%
+	} else if metrics.ErrorRate < 0.2 { // Less than 20%
 		score += 0.1
 	}
 
 	return score
 }
 
-// selectBestModel selects the best model from a provider for the given complexity
-func (eps *EnhancedProviderSelector) selectBestModel(provider *Provider, complexity TaskComplexity) string {
+// selectBestModel ranks the provider's models by capability fit, price and
+// context window for the task and returns the best with the full ranking.
+// Tasks that require reasoning tokens rank only the models that spend them
+func (eps *EnhancedProviderSelector) selectBestModel(provider *Provider, complexity TaskComplexity, requiredCapabilities []string, explain bool) (string, []selection.ModelScore) {
 	if len(provider.Models) == 0 {
-		return "default"
+		return "default", nil
 	}
 
-	// Simple model selection based on complexity
-	switch complexity.Overall {
-	case VeryHigh:
-		// Prefer most capable models
-		for _, model := range provider.Models {
-			if strings.Contains(strings.ToLower(model), "gpt-4") ||
-				strings.Contains(strings.ToLower(model), "claude-3") ||
-				strings.Contains(strings.ToLower(model), "opus") {
-				return model
+	models := provider.Models
+	if requiresCapability(requiredCapabilities, reasoning.Capability) {
+		var reasoningModels []string
+		for _, model := range models {
+			if eps.reasoningModels.Supports(model) {
+				reasoningModels = append(reasoningModels, model)
 			}
 		}
-	case High:
-		// Prefer balanced models
-		for _, model := range provider.Models {
-			if strings.Contains(strings.ToLower(model), "gpt-4") ||
-				strings.Contains(strings.ToLower(model), "claude") ||
-				strings.Contains(strings.ToLower(model), "sonnet") {
-				return model
-			}
+		if len(reasoningModels) > 0 {
+			models = reasoningModels
 		}
-	case Medium:
-		// Prefer efficient models
-		for _, model := range provider.Models {
-			if strings.Contains(strings.ToLower(model), "gpt-3.5") ||
-				strings.Contains(strings.ToLower(model), "claude-instant") ||
-				strings.Contains(strings.ToLower(model), "haiku") {
-				return model
-			}
+	}
+
+	scores := selection.ScoreModels(eps.modelDatabase, provider.Name, models, selection.ModelRequest{
+		TaskType: taskTypeForCapabilities(requiredCapabilities),
+		Level:    selection.ModelLevel(float64(complexity.Overall) / float64(VeryHigh)),
+		Tokens:   complexity.TokenEstimate,
+		Explain:  explain,
+	})
+	return scores[0].Model, scores
+}
+
+// requiresCapability reports whether capability is among requiredCapabilities
+func requiresCapability(requiredCapabilities []string, capability string) bool {
+	for _, required := range requiredCapabilities {
+		if strings.EqualFold(required, capability) {
+			return true
+		}
+	}
+	return false
+}
+
+// taskTypeForCapabilities picks the task type a model must handle from the
+// capabilities the task requires
+func taskTypeForCapabilities(requiredCapabilities []string) selection.TaskType {
+	for _, capability := range requiredCapabilities {
+		switch strings.ToLower(capability) {
+		case "image", "vision":
+			return selection.TaskTypeImage
+		case "code", "programming":
+			return selection.TaskTypeCode
+		case "audio", "speech":
+			return selection.TaskTypeAudio
+		case "video":
+			return selection.TaskTypeVideo
 		}
 	}
+	return selection.TaskTypeText
+}
+
+// SetWeights sets the selection weights of requests that do not set their own
+func (eps *EnhancedProviderSelector) SetWeights(weights selection.SelectionWeights) {
+	eps.mutex.Lock()
+	defer eps.mutex.Unlock()
+	eps.weights = &weights
+}
 
-	// Default to first available model
-	return provider.Models[0]
+// defaultWeights returns the weights of requests without their own, nil for
+// the defaults
+func (eps *EnhancedProviderSelector) defaultWeights() *selection.SelectionWeights {
+	eps.mutex.RLock()
+	defer eps.mutex.RUnlock()
+	return eps.weights
 }
 
-// GetCapabilityFilters returns the current capability filters
+// GetCapabilityFilters returns a copy of the current capability filters
 func (eps *EnhancedProviderSelector) GetCapabilityFilters() map[string][]string {
-	return eps.capabilityFilters
+	eps.mutex.RLock()
+	defer eps.mutex.RUnlock()
+	return copyCapabilityFilters(eps.capabilityFilters)
 }
 
 // SetCapabilityFilters sets new capability filters
 func (eps *EnhancedProviderSelector) SetCapabilityFilters(filters map[string][]string) {
+	filters = copyCapabilityFilters(filters)
+	eps.mutex.Lock()
+	defer eps.mutex.Unlock()
 	eps.capabilityFilters = filters
 }
 
+// copyCapabilityFilters copies filters so callers cannot change the
+// selector's table behind its lock
+func copyCapabilityFilters(filters map[string][]string) map[string][]string {
+	copied := make(map[string][]string, len(filters))
+	for capability, models := range filters {
+		copied[capability] = append([]string(nil), models...)
+	}
+	return copied
+}
+
 // GetProviderStats returns statistics about providers
 func (eps *EnhancedProviderSelector) GetProviderStats() map[string]interface{} {
+	tierStats := make(map[string]int)
+	for _, name := range tier.Names() {
+		tierStats[name] = 0
+	}
 	stats := map[string]interface{}{
-		"total_providers": len(eps.providers),
-		"providers_by_tier": map[string]int{
-			"official":   0,
-			"community":  0,
-			"unofficial": 0,
-		},
-		"total_models": 0,
-		"capabilities": eps.capabilityFilters,
+		"total_providers":   len(eps.providers),
+		"providers_by_tier": tierStats,
+		"total_models":      0,
+		"capabilities":      eps.GetCapabilityFilters(),
+		"in_flight":         eps.InFlight(),
 	}
 
 	for _, provider := range eps.providers {
 		stats["total_models"] = stats["total_models"].(int) + len(provider.Models)
-		
-		tierStats := stats["providers_by_tier"].(map[string]int)
-		switch provider.Tier {
-		case OfficialTier:
-			tierStats["official"]++
-		case CommunityTier:
-			tierStats["community"]++
-		case UnofficialTier:
-			tierStats["unofficial"]++
+
+		if provider.Tier.Valid() {
+			tierStats[provider.Tier.String()]++
 		}
 	}
 
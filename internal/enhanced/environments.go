@@ -0,0 +1,95 @@
+package enhanced
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/environment"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/middleware"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/selection"
+)
+
+// SetEnvironments routes the requests of each key environment by its profile
+// in profiles. Call it after SetAnalytics so this month's spend is restored
+// from analytics
+func (es *EnhancedSystem) SetEnvironments(profiles *environment.Profiles) {
+	es.environments = profiles
+	if profiles == nil || es.analytics == nil {
+		return
+	}
+
+	now := time.Now().UTC()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for _, record := range es.analytics.Records(monthStart, time.Time{}) {
+		if record.Environment != "" && record.Success {
+			profiles.RecordSpend(record.Environment, record.Timestamp, record.Cost)
+		}
+	}
+}
+
+// applyEnvironment limits constraints to the tiers, providers and cost of
+// the profile of the request's key environment. It fails with
+// environment.ErrUnknown for environments without a profile, so a mistyped
+// environment never gets unrestricted routing, with
+// environment.ErrNoProviders when the profile leaves no provider, and with
+// environment.ErrBudgetExceeded once the environment's budget is spent
+func (es *EnhancedSystem) applyEnvironment(ctx context.Context, constraints selection.RequestConstraints) (selection.RequestConstraints, error) {
+	name := middleware.EnvironmentFromContext(ctx)
+	if es.environments == nil || name == "" {
+		return constraints, nil
+	}
+	profile, err := es.environments.Get(name)
+	if err != nil {
+		return constraints, err
+	}
+	if err := es.environments.CheckBudget(name); err != nil {
+		return constraints, err
+	}
+
+	if profile.CostLimit > 0 && (constraints.CostLimit == 0 || constraints.CostLimit > profile.CostLimit) {
+		constraints.CostLimit = profile.CostLimit
+	}
+	if len(profile.Tiers) == 0 && len(profile.Providers) == 0 {
+		return constraints, nil
+	}
+
+	allowed := []string{}
+	for _, provider := range es.providers {
+		if !profile.Allows(provider.Name, provider.Tier) {
+			continue
+		}
+		if len(constraints.AllowedProviders) > 0 && !containsFold(constraints.AllowedProviders, provider.Name) {
+			continue
+		}
+		allowed = append(allowed, provider.Name)
+	}
+	if len(allowed) == 0 {
+		return constraints, fmt.Errorf("%w: %s", environment.ErrNoProviders, name)
+	}
+	constraints.AllowedProviders = allowed
+	return constraints, nil
+}
+
+// recordEnvironmentSpend adds the cost of a request to its key environment's
+// monthly spend, publishing an event when it uses up the budget
+func (es *EnhancedSystem) recordEnvironmentSpend(ctx context.Context, startTime time.Time, cost float64) {
+	if name := middleware.EnvironmentFromContext(ctx); es.environments != nil && name != "" {
+		if es.environments.RecordSpend(name, startTime, cost) {
+			if usage, err := es.environments.Usage(name); err == nil {
+				es.publishBudgetExceeded(ctx, "environment", name, usage.Month, usage.Spend, usage.MonthlyBudget)
+			}
+		}
+	}
+}
+
+// containsFold reports whether names includes name, ignoring case
+func containsFold(names []string, name string) bool {
+	for _, candidate := range names {
+		if strings.EqualFold(candidate, name) {
+			return true
+		}
+	}
+	return false
+}
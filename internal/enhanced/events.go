@@ -0,0 +1,97 @@
+package enhanced
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/events"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/middleware"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/requestid"
+)
+
+// SetEvents makes the system publish request, provider health, budget and
+// key events to bus
+func (es *EnhancedSystem) SetEvents(bus *events.Bus) {
+	es.events = bus
+}
+
+// EventStats returns the counters of the event bus, false when events are
+// not published
+func (es *EnhancedSystem) EventStats() (events.Stats, bool) {
+	return es.events.Stats(), es.events != nil
+}
+
+// publishRequestCompleted publishes the outcome of a request
+func (es *EnhancedSystem) publishRequestCompleted(ctx context.Context, input RequestInput, startTime time.Time, response *ProcessResponse, err error) {
+	data := events.RequestCompletedData{
+		RequestID:   requestid.FromContext(ctx),
+		KeyID:       middleware.KeyIDFromContext(ctx),
+		Tenant:      middleware.TenantFromContext(ctx),
+		Environment: middleware.EnvironmentFromContext(ctx),
+		Mode:        input.Mode,
+		Tags:        input.Tags(),
+		Status:      RequestSucceeded,
+		DurationMs:  time.Since(startTime).Milliseconds(),
+	}
+	if response != nil {
+		data.Provider = response.Provider.Name
+		data.Tier = response.Provider.Tier
+		data.Model = response.Model
+		data.TokensUsed = response.TokensUsed
+		data.Cost = response.Cost
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		data.Status = RequestCancelled
+		data.Error = err.Error()
+	case err != nil:
+		data.Status = RequestFailed
+		data.Error = err.Error()
+	}
+	es.events.Publish(ctx, events.RequestCompleted, data)
+}
+
+// updateProviderHealth records the outcome of a provider call and publishes
+// the provider becoming unhealthy or recovering
+func (es *EnhancedSystem) updateProviderHealth(ctx context.Context, providerName string, success bool, latency time.Duration) {
+	previous := es.healthMonitor.GetMetrics(providerName)
+	wasHealthy := es.healthMonitor.IsHealthy(providerName)
+	es.healthMonitor.UpdateMetrics(providerName, success, latency)
+	if es.healthMonitor.IsHealthy(providerName) == wasHealthy {
+		return
+	}
+
+	current := es.healthMonitor.GetMetrics(providerName)
+	data := events.ProviderHealthData{
+		Provider:       providerName,
+		Status:         current.Status,
+		ErrorRate:      current.ErrorRate,
+		TotalRequests:  current.TotalRequests,
+		AverageLatency: current.AverageLatency,
+	}
+	if previous != nil {
+		data.PreviousStatus = previous.Status
+	}
+	eventType := events.ProviderRecovered
+	if wasHealthy {
+		eventType = events.ProviderUnhealthy
+		es.incidents.Open(providerName, time.Now(), current.ErrorRate)
+	} else {
+		es.incidents.Resolve(providerName, time.Now())
+	}
+	es.events.Publish(ctx, eventType, data)
+}
+
+// publishBudgetExceeded publishes that a request spent the last of a tenant's
+// or environment's monthly budget
+func (es *EnhancedSystem) publishBudgetExceeded(ctx context.Context, scope, name, month string, spend, budget float64) {
+	logger.WithField(requestid.Field, requestid.FromContext(ctx)).Warnf("Monthly budget of %s %s spent: $%.2f of $%.2f", scope, name, spend, budget)
+	es.events.Publish(ctx, events.BudgetExceeded, events.BudgetExceededData{
+		Scope:         scope,
+		Name:          name,
+		Month:         month,
+		Spend:         spend,
+		MonthlyBudget: budget,
+	})
+}
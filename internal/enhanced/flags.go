@@ -0,0 +1,58 @@
+package enhanced
+
+import (
+	"context"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/flags"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/middleware"
+)
+
+// SetFeatureFlags sets the store of feature flag overrides; flags without
+// one follow the system's configuration
+func (es *EnhancedSystem) SetFeatureFlags(store *flags.Store) {
+	es.featureFlags = store
+}
+
+// FeatureFlags returns every feature flag with its overrides
+func (es *EnhancedSystem) FeatureFlags() []*flags.Status {
+	return es.featureFlags.List(es.featureFlagDefaults())
+}
+
+// FeatureFlag returns one feature flag with its overrides
+func (es *EnhancedSystem) FeatureFlag(name string) (*flags.Status, error) {
+	return es.featureFlags.Get(name, es.featureFlagDefaults())
+}
+
+// SetFeatureFlag overrides a feature flag globally, for a tenant or for an
+// API key. Requests see the change from the next one on
+func (es *EnhancedSystem) SetFeatureFlag(name, scope, target string, enabled bool) (*flags.Status, error) {
+	return es.featureFlags.Set(name, scope, target, enabled, es.featureFlagDefaults())
+}
+
+// ClearFeatureFlag removes an override of a feature flag
+func (es *EnhancedSystem) ClearFeatureFlag(name, scope, target string) (*flags.Status, error) {
+	return es.featureFlags.Clear(name, scope, target, es.featureFlagDefaults())
+}
+
+// EvaluateFeatureFlags returns the feature flags a request of tenant with the
+// API key keyID would see
+func (es *EnhancedSystem) EvaluateFeatureFlags(tenant, keyID string) flags.Evaluations {
+	return es.featureFlags.Evaluate(tenant, keyID, es.featureFlagDefaults())
+}
+
+// requestFeatureFlags returns the feature flags of the request's tenant and
+// API key
+func (es *EnhancedSystem) requestFeatureFlags(ctx context.Context) flags.Evaluations {
+	return es.EvaluateFeatureFlags(middleware.TenantFromContext(ctx), middleware.KeyIDFromContext(ctx))
+}
+
+// featureFlagDefaults are the states of the flags without overrides, as the
+// system is configured. Safety escalation still needs an escalation policy
+// when a flag turns it on
+func (es *EnhancedSystem) featureFlagDefaults() map[string]bool {
+	return map[string]bool{
+		flags.SpeculativeRouting: es.speculativeDefault,
+		flags.SafetyEscalation:   es.safetyEscalation != nil,
+		flags.PromptOptimization: true,
+	}
+}
@@ -0,0 +1,116 @@
+package enhanced
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/internal/components"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/hooks"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/middleware"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/requestid"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/selection"
+)
+
+// SetHooks installs the hooks run at each stage of a request
+func (es *EnhancedSystem) SetHooks(configured *hooks.Hooks) {
+	es.hooks = configured
+}
+
+// runRequestHooks runs the OnRequest hooks and returns input with their
+// changes, and the request the later stages' hooks see
+func (es *EnhancedSystem) runRequestHooks(ctx context.Context, input RequestInput) (RequestInput, *hooks.Request, error) {
+	request := &hooks.Request{
+		ID:       requestid.FromContext(ctx),
+		KeyID:    middleware.KeyIDFromContext(ctx),
+		Tenant:   middleware.TenantFromContext(ctx),
+		Mode:     input.Mode,
+		Model:    input.Model,
+		Content:  input.Content,
+		Metadata: input.Metadata,
+	}
+	if err := es.hooks.Request(ctx, request); err != nil {
+		return input, request, err
+	}
+	input.Content = request.Content
+	input.Model = request.Model
+	input.Mode = request.Mode
+	return input, request, nil
+}
+
+// selectProvider selects a provider and lets the OnSelect hooks veto it. A
+// vetoed provider is excluded and selection runs again, until a provider is
+// accepted or none is left. It also returns constraints without the vetoed
+// providers, for later selections of the request
+func (es *EnhancedSystem) selectProvider(ctx context.Context, request *hooks.Request, complexity components.TaskComplexity, requiredCapabilities []string, constraints selection.RequestConstraints) (*ProviderAssignment, selection.RequestConstraints, error) {
+	var vetoes []string
+	for {
+		assignment, err := es.selector.SelectProviderWithConstraints(ctx, complexity, requiredCapabilities, constraints)
+		if err != nil {
+			if len(vetoes) > 0 {
+				return nil, constraints, fmt.Errorf("%w: %s; then %v", hooks.ErrVetoed, strings.Join(vetoes, "; "), err)
+			}
+			return nil, constraints, err
+		}
+
+		err = es.hooks.Select(ctx, request, hookSelection(assignment, false))
+		if err == nil {
+			if len(vetoes) > 0 {
+				assignment.Metadata["hook_vetoes"] = vetoes
+			}
+			return assignment, constraints, nil
+		}
+		if !errors.Is(err, hooks.ErrVetoed) {
+			return nil, constraints, err
+		}
+		veto := assignment.Provider.Name + " by " + strings.TrimPrefix(err.Error(), hooks.ErrVetoed.Error()+": ")
+		logger.WithField(requestid.Field, request.ID).Infof("Provider %s vetoed", veto)
+		vetoes = append(vetoes, veto)
+
+		// AllowedProviders only restricts when set, so start from everyone
+		remaining := []string{}
+		for _, provider := range es.providers {
+			if strings.EqualFold(provider.Name, assignment.Provider.Name) {
+				continue
+			}
+			if len(constraints.AllowedProviders) > 0 && !containsFold(constraints.AllowedProviders, provider.Name) {
+				continue
+			}
+			remaining = append(remaining, provider.Name)
+		}
+		if len(remaining) == 0 {
+			return nil, constraints, fmt.Errorf("%w: %s", hooks.ErrVetoed, strings.Join(vetoes, "; "))
+		}
+		constraints.AllowedProviders = remaining
+	}
+}
+
+// runResponseHooks runs the OnResponse hooks, applies their changes to
+// response and reports the request's annotations in its metadata
+func (es *EnhancedSystem) runResponseHooks(ctx context.Context, request *hooks.Request, response *ProcessResponse) error {
+	hookResponse := &hooks.Response{
+		Provider:   response.Provider.Name,
+		Model:      response.Model,
+		Content:    response.Content,
+		TokensUsed: response.TokensUsed,
+		Cost:       response.Cost,
+	}
+	err := es.hooks.Response(ctx, request, hookResponse)
+	response.Content = hookResponse.Content
+	if len(request.Annotations) > 0 {
+		response.Metadata["hook_annotations"] = request.Annotations
+	}
+	return err
+}
+
+// hookSelection describes an assignment to OnSelect hooks
+func hookSelection(assignment *ProviderAssignment, draft bool) hooks.Selection {
+	return hooks.Selection{
+		Provider:      assignment.Provider.Name,
+		Tier:          assignment.Provider.Tier,
+		Model:         assignment.Model,
+		EstimatedCost: assignment.EstimatedCost,
+		Draft:         draft,
+	}
+}
@@ -0,0 +1,47 @@
+package enhanced
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/incident"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/requestid"
+)
+
+// SetIncidentLog records provider outages in log instead of in memory
+func (es *EnhancedSystem) SetIncidentLog(log *incident.Log) {
+	if log != nil {
+		es.incidents = log
+	}
+}
+
+// Incidents returns the provider incidents matching query, newest first
+func (es *EnhancedSystem) Incidents(query incident.Query) []incident.Incident {
+	return es.incidents.List(query)
+}
+
+// Incident returns one provider incident with its timeline
+func (es *EnhancedSystem) Incident(id int64) (*incident.Incident, error) {
+	return es.incidents.Get(id)
+}
+
+// recordIncidents counts a request toward the open incidents it touched: the
+// incident of the provider that served it, and those of the runners-up it
+// was routed around. A failover costs what the request did above what the
+// skipped provider would have charged for the same tokens
+func (es *EnhancedSystem) recordIncidents(ctx context.Context, assignment *ProviderAssignment, response *ProcessResponse, err error) {
+	now := time.Now()
+	requestID := requestid.FromContext(ctx)
+	es.incidents.RecordRequest(assignment.Provider.Name, requestID, now, err)
+	if err != nil || response == nil {
+		return
+	}
+	for _, alternative := range assignment.Alternatives {
+		if strings.EqualFold(alternative.Name, assignment.Provider.Name) {
+			continue
+		}
+		extraCost := response.Cost - float64(response.TokensUsed)*alternative.CostPerToken
+		es.incidents.RecordFailover(alternative.Name, assignment.Provider.Name, requestID, now, extraCost)
+	}
+}
@@ -0,0 +1,109 @@
+package enhanced
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/internal/components"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/learned"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/usage"
+)
+
+// ExportLearnedState snapshots what the system has learned at runtime:
+// provider performance, token calibration, speculative routing totals and,
+// with the built-in optimizer, its counters
+func (es *EnhancedSystem) ExportLearnedState() *learned.State {
+	state := &learned.State{
+		Version:           learned.Version,
+		CreatedAt:         time.Now(),
+		Providers:         []learned.ProviderPerformance{},
+		TokenCalibrations: es.tokenCalibrator.Calibrations(),
+		Speculative:       es.speculativeStats.Stats(),
+	}
+	for name, metrics := range es.healthMonitor.GetAllMetrics() {
+		state.Providers = append(state.Providers, learned.ProviderPerformance{
+			Provider:         name,
+			TotalRequests:    metrics.TotalRequests,
+			FailedRequests:   metrics.FailedRequests,
+			AverageLatencyMs: metrics.AverageLatency,
+			LastUpdated:      metrics.LastUpdated,
+		})
+	}
+	sort.Slice(state.Providers, func(i, j int) bool {
+		return state.Providers[i].Provider < state.Providers[j].Provider
+	})
+	if optimizer, ok := es.optimizer.(*components.SPOOptimizer); ok {
+		total, hits := optimizer.Counters()
+		state.Optimizer = &learned.OptimizerStats{TotalOptimizations: total, CacheHits: hits}
+	}
+	return state
+}
+
+// ImportLearnedState restores learned state, replacing what was learned for
+// the providers it covers. Providers that are not configured are skipped
+func (es *EnhancedSystem) ImportLearnedState(state *learned.State) *learned.ImportReport {
+	report := &learned.ImportReport{}
+	for _, performance := range state.Providers {
+		if es.findProvider(performance.Provider) == nil {
+			report.Skipped = append(report.Skipped, performance.Provider)
+			continue
+		}
+		es.healthMonitor.Restore(performance.Provider, performance.TotalRequests, performance.FailedRequests, performance.AverageLatencyMs, performance.LastUpdated)
+		report.Providers++
+	}
+
+	calibrations := make([]usage.Calibration, 0, len(state.TokenCalibrations))
+	for _, calibration := range state.TokenCalibrations {
+		// The global ratio has no provider and always applies
+		if calibration.Provider != "" && es.findProvider(calibration.Provider) == nil {
+			continue
+		}
+		calibrations = append(calibrations, calibration)
+	}
+	es.tokenCalibrator.Restore(calibrations)
+	report.Calibrations = len(calibrations)
+
+	es.speculativeStats.Restore(state.Speculative)
+	if optimizer, ok := es.optimizer.(*components.SPOOptimizer); ok && state.Optimizer != nil {
+		optimizer.RestoreCounters(state.Optimizer.TotalOptimizations, state.Optimizer.CacheHits)
+	}
+	return report
+}
+
+// RestoreLearnedState imports the state saved at path, if there is one
+func (es *EnhancedSystem) RestoreLearnedState(path string) error {
+	state, err := learned.Load(path)
+	if err != nil || state == nil {
+		return err
+	}
+	report := es.ImportLearnedState(state)
+	logger.Infof("Restored learned state of %d providers and %d token calibrations from %s, saved %s",
+		report.Providers, report.Calibrations, path, state.CreatedAt.Format(time.RFC3339))
+	return nil
+}
+
+// SaveLearnedState writes a snapshot of the learned state to path
+func (es *EnhancedSystem) SaveLearnedState(path string) error {
+	return es.ExportLearnedState().Save(path)
+}
+
+// StartLearnedStateSnapshots saves the learned state to path every interval
+// until ctx is cancelled
+func (es *EnhancedSystem) StartLearnedStateSnapshots(ctx context.Context, path string, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := es.SaveLearnedState(path); err != nil {
+					logger.Warnf("Failed to save learned state: %v", err)
+				}
+			}
+		}
+	}()
+}
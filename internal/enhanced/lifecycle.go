@@ -0,0 +1,175 @@
+package enhanced
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/envelope"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/events"
+)
+
+// ErrShuttingDown is returned for requests that arrive after draining has started
+var ErrShuttingDown = errors.New("enhanced system is shutting down")
+
+// SetMetricsStorage attaches persistent storage that receives the outcome of
+// every request and token usage as it is reconciled, and keeps the request
+// history. Token calibration, provider health and analytics are restored
+// from what it already holds
+func (es *EnhancedSystem) SetMetricsStorage(storage *MetricsStorage) {
+	es.metricsStorage = storage
+	if storage != nil {
+		es.requestHistory = storage
+		es.auditLog = storage
+		es.seedTokenCalibrator(storage)
+		es.seedHealthMonitor(storage)
+		if es.analytics != nil {
+			es.seedAnalytics(storage)
+		}
+	}
+}
+
+// RewrapRequestHistory rewraps the encrypted request history under the
+// current key encryption keys after a key rotation
+func (es *EnhancedSystem) RewrapRequestHistory() (int, error) {
+	if es.metricsStorage == nil {
+		return 0, envelope.ErrNotConfigured
+	}
+	rewrapped, err := es.metricsStorage.RewrapRequestHistory()
+	if err == nil {
+		es.events.Publish(context.Background(), events.KeyRotated, events.KeyRotatedData{Scope: "history", Rewrapped: rewrapped})
+	}
+	return rewrapped, err
+}
+
+// healthSeedWindow is how much stored history restores provider health
+const healthSeedWindow = time.Hour
+
+// seedHealthMonitor restores provider health from recently stored requests
+func (es *EnhancedSystem) seedHealthMonitor(storage *MetricsStorage) {
+	health, err := storage.GetProviderHealth(healthSeedWindow)
+	if err != nil {
+		logger.Warnf("Failed to load stored provider health: %v", err)
+		return
+	}
+	for providerName, stored := range health {
+		es.healthMonitor.Restore(providerName, stored.Requests, stored.Failures, stored.AvgLatencyMs, time.Now())
+	}
+}
+
+// beginRequest registers an in-flight request unless the system is draining
+func (es *EnhancedSystem) beginRequest() bool {
+	es.lifecycleMutex.Lock()
+	if es.draining.Load() {
+		es.lifecycleMutex.Unlock()
+		return false
+	}
+	es.inFlight.Add(1)
+	es.activeRequests.Add(1)
+	es.lifecycleMutex.Unlock()
+
+	es.publishActiveDelta(1)
+	return true
+}
+
+// endRequest marks an in-flight request as finished
+func (es *EnhancedSystem) endRequest() {
+	es.activeRequests.Add(-1)
+	es.publishActiveDelta(-1)
+	es.inFlight.Done()
+}
+
+// ActiveRequests returns the number of requests currently being processed
+func (es *EnhancedSystem) ActiveRequests() int64 {
+	return es.activeRequests.Load()
+}
+
+// IsDraining reports whether the system has stopped accepting new requests
+func (es *EnhancedSystem) IsDraining() bool {
+	return es.draining.Load()
+}
+
+// IsTerminating reports whether the system is about to drain
+func (es *EnhancedSystem) IsTerminating() bool {
+	return es.terminating.Load()
+}
+
+// BeginTermination fails readiness ahead of BeginDrain, so load balancers
+// stop routing to this replica while it still serves what reaches it
+func (es *EnhancedSystem) BeginTermination() {
+	es.terminating.Store(true)
+}
+
+// BeginDrain stops accepting new requests while letting in-flight ones finish
+func (es *EnhancedSystem) BeginDrain() {
+	es.lifecycleMutex.Lock()
+	defer es.lifecycleMutex.Unlock()
+
+	es.draining.Store(true)
+}
+
+// Shutdown drains in-flight requests until ctx expires, then flushes metrics
+// to the attached storage. It returns ctx.Err() if requests were still running
+// when the drain deadline passed; metrics are flushed either way.
+func (es *EnhancedSystem) Shutdown(ctx context.Context) error {
+	logger.Info("Shutting down enhanced system")
+	es.BeginDrain()
+
+	drained := make(chan struct{})
+	go func() {
+		es.inFlight.Wait()
+		close(drained)
+	}()
+
+	var drainErr error
+	select {
+	case <-drained:
+		logger.Info("All in-flight requests completed")
+	case <-ctx.Done():
+		drainErr = fmt.Errorf("drain timed out with %d requests still active: %w", es.ActiveRequests(), ctx.Err())
+		logger.Warn(drainErr)
+	}
+
+	// Counters of incidents still open are only saved on the way out
+	es.incidents.Flush()
+	if err := es.flushMetrics(); err != nil {
+		return errors.Join(drainErr, fmt.Errorf("failed to flush metrics: %w", err))
+	}
+	return drainErr
+}
+
+// flushMetrics commits the metrics still queued and closes the metrics store
+func (es *EnhancedSystem) flushMetrics() error {
+	if es.metricsStorage == nil {
+		return nil
+	}
+	return es.metricsStorage.Close()
+}
+
+// DeadlineError is returned when a request's deadline passes before it
+// completes. Stage names the pipeline step that was cut short
+type DeadlineError struct {
+	Stage string `json:"stage"`
+	Err   error  `json:"-"`
+}
+
+// Error implements the error interface
+func (e *DeadlineError) Error() string {
+	return fmt.Sprintf("request deadline exceeded during %s", e.Stage)
+}
+
+// Unwrap returns the context error, so errors.Is matches context.DeadlineExceeded
+func (e *DeadlineError) Unwrap() error {
+	return e.Err
+}
+
+// checkContext returns a *DeadlineError for stage once ctx's deadline has
+// passed, or ctx.Err() when it was cancelled otherwise
+func checkContext(ctx context.Context, stage string) error {
+	err := ctx.Err()
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &DeadlineError{Stage: stage, Err: err}
+	}
+	return err
+}
@@ -1,41 +1,42 @@
 package enhanced
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
-	
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/analytics"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/audit"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/envelope"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/retention"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/usage"
 	_ "github.com/mattn/go-sqlite3"
 )
 
-// MetricsStorage handles persistent storage of cost-aware provider metrics
-type MetricsStorage struct {
-	db     *sql.DB
-	dbPath string
-}
+// Write batching and retention defaults of the metrics storage
+const (
+	metricsWriteBuffer      = 4096
+	metricsBatchSize        = 256
+	metricsFlushInterval    = time.Second
+	metricsPruneInterval    = time.Hour
+	DefaultMetricsRetention = 30 * 24 * time.Hour
+)
 
-// NewMetricsStorage creates a new cost-focused metrics storage instance
-func NewMetricsStorage(dbPath string) (*MetricsStorage, error) {
-	db, err := sql.Open("sqlite3", dbPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
-	}
-	
-	storage := &MetricsStorage{
-		db:     db,
-		dbPath: dbPath,
-	}
-	
-	if err := storage.initializeTables(); err != nil {
-		return nil, fmt.Errorf("failed to initialize tables: %w", err)
-	}
-	
-	return storage, nil
-}
+// ErrMetricsStorageClosed is returned for writes after Close
+var ErrMetricsStorageClosed = errors.New("metrics storage is closed")
 
-// initializeTables creates the necessary tables with cost tracking
-func (m *MetricsStorage) initializeTables() error {
-	schema := `
+// metricsMigrations are applied in order, each once; append new versions and
+// never edit released ones. Version 1 is the original schema, which used
+// IF NOT EXISTS so databases created before versioning adopt it unchanged
+var metricsMigrations = []string{
+	`
 	CREATE TABLE IF NOT EXISTS provider_metrics (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		provider_name TEXT NOT NULL,
@@ -52,7 +53,7 @@ func (m *MetricsStorage) initializeTables() error {
 		quality_score REAL NOT NULL DEFAULT 0,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
-	
+
 	CREATE TABLE IF NOT EXISTS rate_limit_status (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		provider_name TEXT NOT NULL,
@@ -65,7 +66,7 @@ func (m *MetricsStorage) initializeTables() error {
 		last_updated DATETIME DEFAULT CURRENT_TIMESTAMP,
 		UNIQUE(provider_name, model)
 	);
-	
+
 	CREATE TABLE IF NOT EXISTS cost_optimization_log (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		timestamp DATETIME NOT NULL,
@@ -79,19 +80,394 @@ func (m *MetricsStorage) initializeTables() error {
 		task_complexity TEXT NOT NULL,
 		selection_reason TEXT NOT NULL
 	);
-	
-	CREATE INDEX IF NOT EXISTS idx_provider_metrics_lookup 
+
+	CREATE TABLE IF NOT EXISTS token_usage (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		timestamp DATETIME NOT NULL,
+		provider_name TEXT NOT NULL,
+		model TEXT NOT NULL,
+		estimated_tokens INTEGER NOT NULL,
+		prompt_tokens INTEGER NOT NULL,
+		completion_tokens INTEGER NOT NULL,
+		actual_tokens INTEGER NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_provider_metrics_lookup
 	ON provider_metrics(provider_name, model, timestamp);
-	
-	CREATE INDEX IF NOT EXISTS idx_rate_limit_lookup 
+
+	CREATE INDEX IF NOT EXISTS idx_rate_limit_lookup
 	ON rate_limit_status(provider_name, model);
+
+	CREATE INDEX IF NOT EXISTS idx_token_usage_timestamp
+	ON token_usage(timestamp);
+	`,
 	`
-	
-	_, err := m.db.Exec(schema)
-	return err
+	CREATE TABLE request_metrics (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		request_id TEXT NOT NULL,
+		key_id TEXT NOT NULL DEFAULT '',
+		provider_name TEXT NOT NULL,
+		model TEXT NOT NULL,
+		tier TEXT NOT NULL DEFAULT '',
+		complexity TEXT NOT NULL DEFAULT '',
+		timestamp DATETIME NOT NULL,
+		duration_ms INTEGER NOT NULL DEFAULT 0,
+		tokens_used INTEGER NOT NULL DEFAULT 0,
+		cost REAL NOT NULL DEFAULT 0,
+		success BOOLEAN NOT NULL,
+		error_message TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE INDEX idx_request_metrics_timestamp ON request_metrics(timestamp);
+	CREATE INDEX idx_provider_metrics_timestamp ON provider_metrics(timestamp);
+	CREATE INDEX idx_cost_optimization_timestamp ON cost_optimization_log(timestamp);
+	`,
+	`
+	ALTER TABLE request_metrics ADD COLUMN tenant TEXT NOT NULL DEFAULT '';
+	`,
+	`
+	CREATE TABLE request_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		request_id TEXT NOT NULL,
+		key_id TEXT NOT NULL DEFAULT '',
+		tenant TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL,
+		provider_name TEXT NOT NULL DEFAULT '',
+		model TEXT NOT NULL DEFAULT '',
+		timestamp DATETIME NOT NULL,
+		duration_ms INTEGER NOT NULL DEFAULT 0,
+		cost REAL NOT NULL DEFAULT 0,
+		input TEXT NOT NULL,
+		response TEXT NOT NULL DEFAULT '',
+		error_message TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE INDEX idx_request_history_request_id ON request_history(request_id);
+	CREATE INDEX idx_request_history_key ON request_history(key_id, id);
+	CREATE INDEX idx_request_history_timestamp ON request_history(timestamp);
+	`,
+	`
+	ALTER TABLE request_history ADD COLUMN session_id TEXT NOT NULL DEFAULT '';
+	CREATE INDEX idx_request_history_session ON request_history(session_id);
+	CREATE INDEX idx_request_metrics_request_id ON request_metrics(request_id);
+	CREATE INDEX idx_request_metrics_key ON request_metrics(key_id);
+	`,
+	`
+	CREATE TABLE audit_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		timestamp DATETIME NOT NULL,
+		actor TEXT NOT NULL,
+		action TEXT NOT NULL,
+		resource TEXT NOT NULL,
+		success INTEGER NOT NULL,
+		status INTEGER NOT NULL,
+		request_id TEXT NOT NULL DEFAULT '',
+		remote_addr TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE INDEX idx_audit_events_timestamp ON audit_events(timestamp);
+	CREATE INDEX idx_audit_events_actor ON audit_events(actor, id);
+	`,
+	`
+	ALTER TABLE request_metrics ADD COLUMN environment TEXT NOT NULL DEFAULT '';
+	`,
+	`
+	ALTER TABLE audit_events ADD COLUMN detail TEXT NOT NULL DEFAULT '';
+	`,
+	`
+	ALTER TABLE request_metrics ADD COLUMN safety_action TEXT NOT NULL DEFAULT '';
+	ALTER TABLE request_metrics ADD COLUMN safety_scores TEXT NOT NULL DEFAULT '';
+	`,
+	`
+	ALTER TABLE request_metrics ADD COLUMN tags TEXT NOT NULL DEFAULT '';
+	`,
+	`
+	ALTER TABLE request_metrics ADD COLUMN reasoning_tokens INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE token_usage ADD COLUMN reasoning_tokens INTEGER NOT NULL DEFAULT 0;
+	`,
+}
+
+// prunedTables lists each table with the column retention is measured on;
+// retentionFor picks the part of the policy that applies
+var prunedTables = []struct{ table, column string }{
+	{"provider_metrics", "timestamp"},
+	{"rate_limit_status", "last_updated"},
+	{"cost_optimization_log", "timestamp"},
+	{"token_usage", "timestamp"},
+	{"request_metrics", "timestamp"},
+	{"request_history", "timestamp"},
+	{"audit_events", "timestamp"},
+}
+
+// metricsWrite is a queued statement
+type metricsWrite struct {
+	query string
+	args  []interface{}
+}
+
+// MetricsStorage persists provider metrics, rate limit status, token usage,
+// request history and the audit log in SQLite. Writes are queued and committed in batches
+// by a background writer; reads first flush the queue, so they always see
+// earlier writes
+type MetricsStorage struct {
+	db        *sql.DB
+	dbPath    string
+	policy    atomic.Pointer[retention.Policy]
+	lastPurge atomic.Pointer[retention.PurgeReport]
+	writes    chan metricsWrite
+	flushes   chan chan error
+	closing   chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// sealer encrypts request history bodies; nil stores them in plaintext
+	sealer *envelope.Sealer
+}
+
+// NewMetricsStorage opens or creates the database at dbPath, migrates it to
+// the current schema and starts the background writer
+func NewMetricsStorage(dbPath string) (*MetricsStorage, error) {
+	// WAL lets readers run alongside the writer; the busy timeout covers the
+	// brief moments when they still contend. Secure delete overwrites erased
+	// prompts and responses instead of leaving them in free pages
+	dsn := dbPath
+	if !strings.HasPrefix(dbPath, ":memory:") {
+		separator := "?"
+		if strings.Contains(dbPath, "?") {
+			separator = "&"
+		}
+		dsn += separator + "_journal_mode=WAL&_busy_timeout=5000&_secure_delete=on"
+	}
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	storage := &MetricsStorage{
+		db:      db,
+		dbPath:  dbPath,
+		writes:  make(chan metricsWrite, metricsWriteBuffer),
+		flushes: make(chan chan error),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	storage.SetRetention(DefaultMetricsRetention)
+
+	if err := storage.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate %s: %w", dbPath, err)
+	}
+
+	go storage.run()
+	return storage, nil
+}
+
+// migrate applies the migrations newer than the database's schema version
+func (m *MetricsStorage) migrate() error {
+	if _, err := m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			applied_at DATETIME NOT NULL
+		)
+	`); err != nil {
+		return err
+	}
+
+	var current int
+	if err := m.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return err
+	}
+	if current > len(metricsMigrations) {
+		return fmt.Errorf("schema version %d is newer than this build supports (%d)", current, len(metricsMigrations))
+	}
+
+	for i := current; i < len(metricsMigrations); i++ {
+		version := i + 1
+		tx, err := m.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(metricsMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", version, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`, version, time.Now()); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("migration %d: %w", version, err)
+		}
+		logger.Infof("Applied metrics storage migration %d", version)
+	}
+	return nil
 }
 
-// RecordProviderMetrics stores cost-aware metrics entry
+// SetRetention sets how long rows are kept; zero or less keeps them forever
+func (m *MetricsStorage) SetRetention(period time.Duration) {
+	m.SetRetentionPolicy(retention.Uniform(period))
+}
+
+// SetRetentionPolicy sets how long each kind of row is kept
+func (m *MetricsStorage) SetRetentionPolicy(policy retention.Policy) {
+	m.policy.Store(&policy)
+}
+
+// RetentionPolicy returns the retention in effect
+func (m *MetricsStorage) RetentionPolicy() retention.Policy {
+	return *m.policy.Load()
+}
+
+// LastPurge returns the outcome of the latest purge of expired rows, nil
+// before the first
+func (m *MetricsStorage) LastPurge() *retention.PurgeReport {
+	return m.lastPurge.Load()
+}
+
+// retentionFor returns how long rows of table are kept under policy
+func retentionFor(policy retention.Policy, table string) time.Duration {
+	switch table {
+	case "request_history":
+		return policy.RequestHistory
+	case "request_metrics":
+		return policy.Analytics
+	case "audit_events":
+		return policy.Audit
+	default:
+		return policy.Metrics
+	}
+}
+
+// run is the background writer. It commits queued writes once a batch fills
+// up or the flush interval passes, and prunes expired rows every prune interval
+func (m *MetricsStorage) run() {
+	defer close(m.done)
+
+	flushTicker := time.NewTicker(metricsFlushInterval)
+	defer flushTicker.Stop()
+	pruneTicker := time.NewTicker(metricsPruneInterval)
+	defer pruneTicker.Stop()
+
+	var pending []metricsWrite
+	commit := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		err := m.writeBatch(pending)
+		if err != nil {
+			logger.Warnf("Failed to write %d metrics: %v", len(pending), err)
+		}
+		pending = pending[:0]
+		return err
+	}
+	// drain moves everything already queued into pending
+	drain := func() {
+		for {
+			select {
+			case write := <-m.writes:
+				pending = append(pending, write)
+			default:
+				return
+			}
+		}
+	}
+
+	m.prune()
+	for {
+		select {
+		case write := <-m.writes:
+			pending = append(pending, write)
+			if len(pending) >= metricsBatchSize {
+				commit()
+			}
+		case <-flushTicker.C:
+			commit()
+		case reply := <-m.flushes:
+			drain()
+			reply <- commit()
+		case <-pruneTicker.C:
+			commit()
+			m.prune()
+		case <-m.closing:
+			drain()
+			commit()
+			return
+		}
+	}
+}
+
+// writeBatch executes writes in a single transaction
+func (m *MetricsStorage) writeBatch(writes []metricsWrite) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, write := range writes {
+		if _, err := tx.Exec(write.query, write.args...); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// prune deletes rows older than their retention
+func (m *MetricsStorage) prune() {
+	policy := m.RetentionPolicy()
+	report := &retention.PurgeReport{StartedAt: time.Now(), Purged: make(map[string]int64)}
+	for _, pruned := range prunedTables {
+		keep := retentionFor(policy, pruned.table)
+		if keep <= 0 {
+			continue
+		}
+		cutoff := report.StartedAt.Add(-keep)
+		result, err := m.db.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s < ?", pruned.table, pruned.column), cutoff)
+		if err != nil {
+			logger.Warnf("Failed to prune %s: %v", pruned.table, err)
+			continue
+		}
+		if n, err := result.RowsAffected(); err == nil && n > 0 {
+			report.Purged[pruned.table] = n
+			logger.Infof("Pruned %d %s rows older than %s", n, pruned.table, keep)
+		}
+	}
+	m.lastPurge.Store(report)
+}
+
+// enqueue hands a write to the background writer, waiting if its queue is full
+func (m *MetricsStorage) enqueue(query string, args ...interface{}) error {
+	select {
+	case <-m.closing:
+		return ErrMetricsStorageClosed
+	default:
+	}
+
+	select {
+	case m.writes <- metricsWrite{query: query, args: args}:
+		return nil
+	case <-m.closing:
+		return ErrMetricsStorageClosed
+	}
+}
+
+// Flush commits all queued writes, returning the error of the last batch
+func (m *MetricsStorage) Flush() error {
+	reply := make(chan error, 1)
+	select {
+	case m.flushes <- reply:
+		return <-reply
+	case <-m.done:
+		return nil
+	}
+}
+
+// SetSealer encrypts the input and response of request history records
+// written from now on. Records already stored in plaintext stay readable
+func (m *MetricsStorage) SetSealer(sealer *envelope.Sealer) {
+	m.sealer = sealer
+}
+
+// RecordProviderMetrics queues a cost-aware metrics entry
 func (m *MetricsStorage) RecordProviderMetrics(
 	providerName, model string,
 	requestCount, failureCount, tokensUsed int64,
@@ -102,44 +478,73 @@ func (m *MetricsStorage) RecordProviderMetrics(
 	if requestCount > 0 {
 		successRate = float64(requestCount-failureCount) / float64(requestCount)
 	}
-	
+
 	costPerToken := 0.0
 	if tokensUsed > 0 {
 		costPerToken = cost / float64(tokensUsed)
 	}
-	
+
 	query := `
-		INSERT INTO provider_metrics 
-		(provider_name, model, timestamp, request_count, failure_count, 
+		INSERT INTO provider_metrics
+		(provider_name, model, timestamp, request_count, failure_count,
 		 latency_ms, tokens_used, cost, cost_per_token, rate_limited, success_rate)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
-	
-	_, err := m.db.Exec(query, 
+
+	return m.enqueue(query,
 		providerName, model, time.Now(),
 		requestCount, failureCount, latency, tokensUsed, cost, costPerToken, rateLimited, successRate)
-	
-	return err
 }
 
-// UpdateRateLimitStatus stores current rate limit information
+// UpdateRateLimitStatus queues the current rate limit information
 func (m *MetricsStorage) UpdateRateLimitStatus(
 	providerName, model string,
 	requestsPerMin, requestsRemaining, tokensPerMin, tokensRemaining int64,
 	resetTime time.Time,
 ) error {
 	query := `
-		INSERT OR REPLACE INTO rate_limit_status 
+		INSERT OR REPLACE INTO rate_limit_status
 		(provider_name, model, requests_per_minute, requests_remaining,
 		 tokens_per_minute, tokens_remaining, reset_time, last_updated)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 	`
-	
-	_, err := m.db.Exec(query,
+
+	return m.enqueue(query,
 		providerName, model, requestsPerMin, requestsRemaining,
 		tokensPerMin, tokensRemaining, resetTime, time.Now())
-	
-	return err
+}
+
+// GetRateLimitStatus returns the stored rate limits whose reset time has not
+// passed, so a restart does not forget a provider is exhausted
+func (m *MetricsStorage) GetRateLimitStatus() ([]StoredRateLimit, error) {
+	if err := m.Flush(); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT provider_name, model, requests_per_minute, requests_remaining,
+		       tokens_per_minute, tokens_remaining, reset_time
+		FROM rate_limit_status
+		WHERE reset_time > ?
+	`
+
+	rows, err := m.db.Query(query, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var limits []StoredRateLimit
+	for rows.Next() {
+		var limit StoredRateLimit
+		if err := rows.Scan(&limit.ProviderName, &limit.Model,
+			&limit.RequestsPerMinute, &limit.RequestsRemaining,
+			&limit.TokensPerMinute, &limit.TokensRemaining, &limit.ResetTime); err != nil {
+			return nil, err
+		}
+		limits = append(limits, limit)
+	}
+	return limits, rows.Err()
 }
 
 // GetCostAnalysis provides cost efficiency analysis for provider selection
@@ -147,23 +552,26 @@ func (m *MetricsStorage) GetCostAnalysis(
 	providerName, model string,
 	window time.Duration,
 ) (*CostAnalysis, error) {
+	if err := m.Flush(); err != nil {
+		return nil, err
+	}
 	cutoffTime := time.Now().Add(-window)
-	
+
 	query := `
-		SELECT 
-			AVG(cost_per_token) as avg_cost_per_token,
-			SUM(cost) as total_cost,
-			SUM(tokens_used) as total_tokens,
-			AVG(success_rate) as avg_success_rate,
+		SELECT
+			COALESCE(AVG(cost_per_token), 0) as avg_cost_per_token,
+			COALESCE(SUM(cost), 0) as total_cost,
+			COALESCE(SUM(tokens_used), 0) as total_tokens,
+			COALESCE(AVG(success_rate), 0) as avg_success_rate,
 			COUNT(CASE WHEN rate_limited = 1 THEN 1 END) as rate_limit_hits,
 			COUNT(*) as total_records
 		FROM provider_metrics
 		WHERE provider_name = ? AND model = ? AND timestamp >= ?
 	`
-	
+
 	var analysis CostAnalysis
 	row := m.db.QueryRow(query, providerName, model, cutoffTime)
-	
+
 	err := row.Scan(
 		&analysis.AvgCostPerToken,
 		&analysis.TotalCost,
@@ -172,18 +580,53 @@ func (m *MetricsStorage) GetCostAnalysis(
 		&analysis.RateLimitHits,
 		&analysis.TotalRecords,
 	)
-	
+
 	if err != nil {
 		return nil, err
 	}
-	
+
 	analysis.ProviderName = providerName
 	analysis.Model = model
-	
+
 	return &analysis, nil
 }
 
-// RecordCostOptimization logs cost optimization decisions
+// GetProviderHealth aggregates the provider metrics recorded within window
+// by provider
+func (m *MetricsStorage) GetProviderHealth(window time.Duration) (map[string]StoredProviderHealth, error) {
+	if err := m.Flush(); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT provider_name,
+		       SUM(request_count),
+		       SUM(failure_count),
+		       COALESCE(SUM(latency_ms * request_count) / NULLIF(SUM(request_count), 0), 0)
+		FROM provider_metrics
+		WHERE timestamp >= ?
+		GROUP BY provider_name
+	`
+
+	rows, err := m.db.Query(query, time.Now().Add(-window))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	health := make(map[string]StoredProviderHealth)
+	for rows.Next() {
+		var providerName string
+		var stored StoredProviderHealth
+		if err := rows.Scan(&providerName, &stored.Requests, &stored.Failures, &stored.AvgLatencyMs); err != nil {
+			return nil, err
+		}
+		health[providerName] = stored
+	}
+	return health, rows.Err()
+}
+
+// RecordCostOptimization queues a cost optimization decision
 func (m *MetricsStorage) RecordCostOptimization(
 	originalProvider, selectedProvider, model string,
 	estimatedCostOriginal, actualCostSelected float64,
@@ -192,7 +635,7 @@ func (m *MetricsStorage) RecordCostOptimization(
 	reason string,
 ) error {
 	costSavings := estimatedCostOriginal - actualCostSelected
-	
+
 	query := `
 		INSERT INTO cost_optimization_log
 		(timestamp, original_provider, selected_provider, model,
@@ -200,34 +643,35 @@ func (m *MetricsStorage) RecordCostOptimization(
 		 tokens_used, task_complexity, selection_reason)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
-	
-	_, err := m.db.Exec(query,
+
+	return m.enqueue(query,
 		time.Now(), originalProvider, selectedProvider, model,
 		estimatedCostOriginal, actualCostSelected, costSavings,
 		tokensUsed, taskComplexity, reason)
-	
-	return err
 }
 
 // GetCostSavingsReport provides cost optimization analytics
 func (m *MetricsStorage) GetCostSavingsReport(days int) (*CostSavingsReport, error) {
+	if err := m.Flush(); err != nil {
+		return nil, err
+	}
 	cutoffDate := time.Now().AddDate(0, 0, -days)
-	
+
 	query := `
-		SELECT 
+		SELECT
 			COUNT(*) as total_optimizations,
-			SUM(cost_savings) as total_savings,
-			AVG(cost_savings) as avg_savings_per_request,
-			SUM(estimated_cost_original) as total_original_cost,
-			SUM(actual_cost_selected) as total_actual_cost,
-			(SUM(cost_savings) / SUM(estimated_cost_original)) * 100 as savings_percentage
+			COALESCE(SUM(cost_savings), 0) as total_savings,
+			COALESCE(AVG(cost_savings), 0) as avg_savings_per_request,
+			COALESCE(SUM(estimated_cost_original), 0) as total_original_cost,
+			COALESCE(SUM(actual_cost_selected), 0) as total_actual_cost,
+			COALESCE((SUM(cost_savings) / NULLIF(SUM(estimated_cost_original), 0)) * 100, 0) as savings_percentage
 		FROM cost_optimization_log
 		WHERE timestamp >= ?
 	`
-	
+
 	var report CostSavingsReport
 	row := m.db.QueryRow(query, cutoffDate)
-	
+
 	err := row.Scan(
 		&report.TotalOptimizations,
 		&report.TotalSavings,
@@ -236,36 +680,609 @@ func (m *MetricsStorage) GetCostSavingsReport(days int) (*CostSavingsReport, err
 		&report.TotalActualCost,
 		&report.SavingsPercentage,
 	)
-	
+
 	return &report, err
 }
 
-// Close closes the database connection
-func (m *MetricsStorage) Close() error {
-	if m.db != nil {
-		return m.db.Close()
+// RecordTokenUsage queues a request's estimated tokens next to the usage the
+// provider reported
+func (m *MetricsStorage) RecordTokenUsage(reconciliation usage.Reconciliation) error {
+	query := `
+		INSERT INTO token_usage
+		(timestamp, provider_name, model, estimated_tokens,
+		 prompt_tokens, completion_tokens, actual_tokens, reasoning_tokens)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	return m.enqueue(query,
+		time.Now(), reconciliation.Provider, reconciliation.Model, reconciliation.Estimated,
+		reconciliation.Actual.PromptTokens, reconciliation.Actual.CompletionTokens, reconciliation.Actual.TotalTokens,
+		reconciliation.Actual.ReasoningTokens)
+}
+
+// GetTokenUsage returns up to limit reconciliations recorded within window,
+// oldest first, so they can be replayed into a calibrator
+func (m *MetricsStorage) GetTokenUsage(window time.Duration, limit int) ([]usage.Reconciliation, error) {
+	if err := m.Flush(); err != nil {
+		return nil, err
 	}
-	return nil
+
+	query := `
+		SELECT provider_name, model, estimated_tokens,
+		       prompt_tokens, completion_tokens, actual_tokens, reasoning_tokens
+		FROM (
+			SELECT * FROM token_usage
+			WHERE timestamp >= ?
+			ORDER BY timestamp DESC
+			LIMIT ?
+		)
+		ORDER BY timestamp ASC
+	`
+
+	rows, err := m.db.Query(query, time.Now().Add(-window), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reconciliations []usage.Reconciliation
+	for rows.Next() {
+		var r usage.Reconciliation
+		if err := rows.Scan(&r.Provider, &r.Model, &r.Estimated,
+			&r.Actual.PromptTokens, &r.Actual.CompletionTokens, &r.Actual.TotalTokens, &r.Actual.ReasoningTokens); err != nil {
+			return nil, err
+		}
+		r.Delta = r.Actual.TotalTokens - r.Estimated
+		reconciliations = append(reconciliations, r)
+	}
+	return reconciliations, rows.Err()
+}
+
+// RecordRequest queues the analytics record of a processed request
+func (m *MetricsStorage) RecordRequest(metrics analytics.RequestMetrics) error {
+	query := `
+		INSERT INTO request_metrics
+		(request_id, key_id, tenant, environment, provider_name, model, tier, complexity, timestamp,
+		 duration_ms, tokens_used, reasoning_tokens, cost, success, error_message, safety_action, safety_scores, tags)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	var safetyScores string
+	if len(metrics.SafetyScores) > 0 {
+		data, err := json.Marshal(metrics.SafetyScores)
+		if err != nil {
+			return err
+		}
+		safetyScores = string(data)
+	}
+	var tags string
+	if len(metrics.Tags) > 0 {
+		data, err := json.Marshal(metrics.Tags)
+		if err != nil {
+			return err
+		}
+		tags = string(data)
+	}
+	return m.enqueue(query,
+		metrics.RequestID, metrics.KeyID, metrics.Tenant, metrics.Environment, metrics.ProviderID, metrics.Model, metrics.Tier,
+		metrics.Complexity, metrics.Timestamp, metrics.Duration, metrics.TokensUsed, metrics.ReasoningTokens,
+		metrics.Cost, metrics.Success, metrics.ErrorMessage, metrics.SafetyAction, safetyScores, tags)
+}
+
+// GetRequests returns up to limit requests recorded at or after since, oldest
+// first, so they can be replayed into the analytics engine
+func (m *MetricsStorage) GetRequests(since time.Time, limit int) ([]analytics.RequestMetrics, error) {
+	if err := m.Flush(); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT request_id, key_id, tenant, environment, provider_name, model, tier, complexity, timestamp,
+		       duration_ms, tokens_used, reasoning_tokens, cost, success, error_message, safety_action, safety_scores, tags
+		FROM (
+			SELECT * FROM request_metrics
+			WHERE timestamp >= ?
+			ORDER BY timestamp DESC
+			LIMIT ?
+		)
+		ORDER BY timestamp ASC
+	`
+
+	rows, err := m.db.Query(query, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []analytics.RequestMetrics
+	for rows.Next() {
+		var r analytics.RequestMetrics
+		var safetyScores, tags string
+		if err := rows.Scan(&r.RequestID, &r.KeyID, &r.Tenant, &r.Environment, &r.ProviderID, &r.Model, &r.Tier,
+			&r.Complexity, &r.Timestamp, &r.Duration, &r.TokensUsed, &r.ReasoningTokens,
+			&r.Cost, &r.Success, &r.ErrorMessage, &r.SafetyAction, &safetyScores, &tags); err != nil {
+			return nil, err
+		}
+		if safetyScores != "" {
+			if err := json.Unmarshal([]byte(safetyScores), &r.SafetyScores); err != nil {
+				return nil, err
+			}
+		}
+		if tags != "" {
+			if err := json.Unmarshal([]byte(tags), &r.Tags); err != nil {
+				return nil, err
+			}
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// requestHistoryColumns are the request_history columns scanned by scanRequestRecord
+const requestHistoryColumns = `id, request_id, key_id, tenant, session_id, status, provider_name, model,
+	timestamp, duration_ms, cost, input, response, error_message`
+
+// RecordRequestHistory queues a processed request with its input and result
+func (m *MetricsStorage) RecordRequestHistory(record RequestRecord) error {
+	input, err := json.Marshal(record.Input)
+	if err != nil {
+		return err
+	}
+	var response []byte
+	if record.Response != nil {
+		if response, err = json.Marshal(record.Response); err != nil {
+			return err
+		}
+	}
+
+	storedInput, storedResponse := string(input), string(response)
+	if m.sealer != nil {
+		// The record ID is authenticated so bodies cannot be swapped between rows
+		bodies := [][]byte{input}
+		if response != nil {
+			bodies = append(bodies, response)
+		}
+		sealed, err := m.sealer.Seal(record.Tenant, []byte(record.ID), bodies...)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt request %s: %w", record.ID, err)
+		}
+		storedInput = sealed[0]
+		if response != nil {
+			storedResponse = sealed[1]
+		}
+	}
+
+	query := `
+		INSERT INTO request_history
+		(request_id, key_id, tenant, session_id, status, provider_name, model, timestamp,
+		 duration_ms, cost, input, response, error_message)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	return m.enqueue(query,
+		record.ID, record.KeyID, record.Tenant, record.SessionID, record.Status, record.Provider, record.Model,
+		record.CreatedAt, record.DurationMs, record.Cost, storedInput, storedResponse, record.Error)
+}
+
+// GetRequestRecord returns the latest request stored with id within the key
+// and tenant of scope
+func (m *MetricsStorage) GetRequestRecord(id string, scope RequestQuery) (*RequestRecord, error) {
+	if err := m.Flush(); err != nil {
+		return nil, err
+	}
+
+	statement := `SELECT ` + requestHistoryColumns + ` FROM request_history WHERE request_id = ?`
+	args := []interface{}{id}
+	if scope.KeyID != "" {
+		statement += " AND key_id = ?"
+		args = append(args, scope.KeyID)
+	}
+	if scope.Tenant != "" {
+		statement += " AND tenant = ?"
+		args = append(args, scope.Tenant)
+	}
+	row := m.db.QueryRow(statement+" ORDER BY id DESC LIMIT 1", args...)
+	record, err := m.scanRequestRecord(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrRequestNotFound, id)
+	}
+	return record, err
+}
+
+// ListRequestRecords returns a page of the stored requests matching query
+func (m *MetricsStorage) ListRequestRecords(query RequestQuery) (*RequestPage, error) {
+	limit, before, err := query.pageBounds()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.Flush(); err != nil {
+		return nil, err
+	}
+
+	var conditions []string
+	var args []interface{}
+	for _, filter := range []struct {
+		column string
+		value  string
+	}{
+		{"key_id", query.KeyID},
+		{"tenant", query.Tenant},
+		{"session_id", query.SessionID},
+		{"status", query.Status},
+		{"provider_name", query.Provider},
+	} {
+		if filter.value != "" {
+			conditions = append(conditions, filter.column+" = ?")
+			args = append(args, filter.value)
+		}
+	}
+	if !query.Since.IsZero() {
+		conditions = append(conditions, "timestamp >= ?")
+		args = append(args, query.Since)
+	}
+	if !query.Until.IsZero() {
+		conditions = append(conditions, "timestamp < ?")
+		args = append(args, query.Until)
+	}
+	if before != 0 {
+		conditions = append(conditions, "id < ?")
+		args = append(args, before)
+	}
+	statement := `SELECT ` + requestHistoryColumns + ` FROM request_history`
+	if len(conditions) > 0 {
+		statement += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	// One extra row tells whether another page follows
+	statement += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit+1)
+
+	rows, err := m.db.Query(statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &RequestPage{Requests: []RequestRecord{}}
+	for rows.Next() {
+		record, err := m.scanRequestRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		if len(page.Requests) == limit {
+			page.NextCursor = strconv.FormatInt(page.Requests[limit-1].seq, 10)
+			break
+		}
+		page.Requests = append(page.Requests, *record)
+	}
+	return page, rows.Err()
+}
+
+// scanRequestRecord reads a row of requestHistoryColumns
+func (m *MetricsStorage) scanRequestRecord(row interface{ Scan(...interface{}) error }) (*RequestRecord, error) {
+	var record RequestRecord
+	var input, response string
+	if err := row.Scan(&record.seq, &record.ID, &record.KeyID, &record.Tenant, &record.SessionID, &record.Status,
+		&record.Provider, &record.Model, &record.CreatedAt, &record.DurationMs, &record.Cost,
+		&input, &response, &record.Error); err != nil {
+		return nil, err
+	}
+	inputData, err := m.openBody(record.ID, input)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(inputData, &record.Input); err != nil {
+		return nil, fmt.Errorf("request %s: %w", record.ID, err)
+	}
+	if response != "" {
+		responseData, err := m.openBody(record.ID, response)
+		if err != nil {
+			return nil, err
+		}
+		record.Response = &ProcessResponse{}
+		if err := json.Unmarshal(responseData, record.Response); err != nil {
+			return nil, fmt.Errorf("request %s: %w", record.ID, err)
+		}
+	}
+	return &record, nil
+}
+
+// openBody decrypts a stored request history body; plaintext bodies written
+// before encryption was enabled are returned as they are
+func (m *MetricsStorage) openBody(id, body string) ([]byte, error) {
+	if !envelope.IsSealed(body) {
+		return []byte(body), nil
+	}
+	if m.sealer == nil {
+		return nil, fmt.Errorf("request %s is encrypted: %w", id, envelope.ErrNotConfigured)
+	}
+	data, err := m.sealer.Open(body, []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("request %s: %w", id, err)
+	}
+	return data, nil
+}
+
+// rewrapBatchSize is how many encrypted rows RewrapRequestHistory reads at once
+const rewrapBatchSize = 500
+
+// RewrapRequestHistory rewraps the data keys of encrypted request history
+// under each tenant's current key encryption key, so retired keys can be
+// removed after a rotation. Bodies are not decrypted. It returns how many
+// rows changed
+func (m *MetricsStorage) RewrapRequestHistory() (int, error) {
+	if m.sealer == nil {
+		return 0, envelope.ErrNotConfigured
+	}
+	if err := m.Flush(); err != nil {
+		return 0, err
+	}
+
+	type storedBodies struct {
+		id              int64
+		tenant          string
+		input, response string
+	}
+	rewrapped := 0
+	var after int64
+	for {
+		rows, err := m.db.Query(`SELECT id, tenant, input, response FROM request_history
+			WHERE id > ? AND (input LIKE 'enc:%' OR response LIKE 'enc:%')
+			ORDER BY id LIMIT ?`, after, rewrapBatchSize)
+		if err != nil {
+			return rewrapped, err
+		}
+		var batch []storedBodies
+		for rows.Next() {
+			var row storedBodies
+			if err := rows.Scan(&row.id, &row.tenant, &row.input, &row.response); err != nil {
+				rows.Close()
+				return rewrapped, err
+			}
+			batch = append(batch, row)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return rewrapped, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		for _, row := range batch {
+			after = row.id
+			input, inputChanged, err := m.rewrapBody(row.tenant, row.input)
+			if err != nil {
+				return rewrapped, fmt.Errorf("request history row %d: %w", row.id, err)
+			}
+			response, responseChanged, err := m.rewrapBody(row.tenant, row.response)
+			if err != nil {
+				return rewrapped, fmt.Errorf("request history row %d: %w", row.id, err)
+			}
+			if !inputChanged && !responseChanged {
+				continue
+			}
+			if err := m.enqueue(`UPDATE request_history SET input = ?, response = ? WHERE id = ?`,
+				input, response, row.id); err != nil {
+				return rewrapped, err
+			}
+			rewrapped++
+		}
+	}
+	return rewrapped, m.Flush()
+}
+
+// rewrapBody rewraps a sealed body and leaves plaintext ones alone
+func (m *MetricsStorage) rewrapBody(tenant, body string) (string, bool, error) {
+	if !envelope.IsSealed(body) {
+		return body, false, nil
+	}
+	return m.sealer.Rewrap(tenant, body)
+}
+
+// EraseRequestRecords deletes the stored prompts, responses and analytics records
+// matching selector and returns how many rows each table lost. Erased pages
+// are overwritten and the write-ahead log is truncated, so nothing is left
+// on disk
+func (m *MetricsStorage) EraseRequestRecords(selector retention.Selector) (map[string]int, error) {
+	if err := selector.Validate(); err != nil {
+		return nil, err
+	}
+	if err := m.Flush(); err != nil {
+		return nil, err
+	}
+
+	// Analytics records carry no session, so sessions are resolved through
+	// the request history before it is erased
+	var metricsWhere, historyWhere, value string
+	switch {
+	case selector.KeyID != "":
+		metricsWhere, historyWhere, value = "key_id = ?", "key_id = ?", selector.KeyID
+	case selector.SessionID != "":
+		metricsWhere = "request_id IN (SELECT request_id FROM request_history WHERE session_id = ?)"
+		historyWhere, value = "session_id = ?", selector.SessionID
+	default:
+		metricsWhere, historyWhere, value = "request_id = ?", "request_id = ?", selector.RequestID
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	erased := make(map[string]int)
+	for _, statement := range []struct{ table, where string }{
+		{"request_metrics", metricsWhere},
+		{"request_history", historyWhere},
+	} {
+		result, err := tx.Exec("DELETE FROM "+statement.table+" WHERE "+statement.where, value)
+		if err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to erase from %s: %w", statement.table, err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		erased[statement.table] = int(n)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(m.dbPath, ":memory:") {
+		if _, err := m.db.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+			logger.Warnf("Failed to truncate the write-ahead log after an erasure: %v", err)
+		}
+	}
+	return erased, nil
+}
+
+// RecordAuditEvent queues an audit event for writing
+func (m *MetricsStorage) RecordAuditEvent(event audit.Event) error {
+	query := `
+		INSERT INTO audit_events
+		(timestamp, actor, action, resource, success, status, request_id, remote_addr, detail)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	return m.enqueue(query, event.Time, event.Actor, event.Action, event.Resource,
+		event.Success, event.Status, event.RequestID, event.RemoteAddr, event.Detail)
+}
+
+// QueryAuditEvents returns a page of the stored audit events matching query
+func (m *MetricsStorage) QueryAuditEvents(query audit.Query) (*audit.Page, error) {
+	limit, before, err := query.PageBounds()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.Flush(); err != nil {
+		return nil, err
+	}
+
+	var conditions []string
+	var args []interface{}
+	for _, filter := range []struct {
+		column string
+		value  string
+	}{
+		{"actor", query.Actor},
+		{"action", query.Action},
+	} {
+		if filter.value != "" {
+			conditions = append(conditions, filter.column+" = ?")
+			args = append(args, filter.value)
+		}
+	}
+	if query.Resource != "" {
+		// substr rather than LIKE, so % and _ in paths match literally
+		prefix := strings.TrimSuffix(query.Resource, "/") + "/"
+		conditions = append(conditions, "(resource = ? OR substr(resource, 1, ?) = ?)")
+		args = append(args, query.Resource, len(prefix), prefix)
+	}
+	if !query.Since.IsZero() {
+		conditions = append(conditions, "timestamp >= ?")
+		args = append(args, query.Since)
+	}
+	if !query.Until.IsZero() {
+		conditions = append(conditions, "timestamp < ?")
+		args = append(args, query.Until)
+	}
+	if query.Success != nil {
+		conditions = append(conditions, "success = ?")
+		args = append(args, *query.Success)
+	}
+	if before != 0 {
+		conditions = append(conditions, "id < ?")
+		args = append(args, before)
+	}
+	statement := `SELECT id, timestamp, actor, action, resource, success, status, request_id, remote_addr, detail
+		FROM audit_events`
+	if len(conditions) > 0 {
+		statement += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	// One extra row tells whether another page follows
+	statement += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit+1)
+
+	rows, err := m.db.Query(statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &audit.Page{Events: []audit.Event{}}
+	for rows.Next() {
+		var event audit.Event
+		if err := rows.Scan(&event.ID, &event.Time, &event.Actor, &event.Action, &event.Resource,
+			&event.Success, &event.Status, &event.RequestID, &event.RemoteAddr, &event.Detail); err != nil {
+			return nil, err
+		}
+		if len(page.Events) == limit {
+			page.NextCursor = strconv.FormatInt(page.Events[limit-1].ID, 10)
+			break
+		}
+		page.Events = append(page.Events, event)
+	}
+	return page, rows.Err()
+}
+
+// Ping checks that the database answers and the background writer is running
+func (m *MetricsStorage) Ping(ctx context.Context) error {
+	select {
+	case <-m.done:
+		return errors.New("metrics storage is closed")
+	default:
+	}
+	return m.db.PingContext(ctx)
+}
+
+// Close commits queued writes, stops the background writer and closes the
+// database connection
+func (m *MetricsStorage) Close() error {
+	var err error
+	m.closeOnce.Do(func() {
+		close(m.closing)
+		<-m.done
+		err = m.db.Close()
+	})
+	return err
 }
 
 // CostAnalysis represents cost efficiency metrics
 type CostAnalysis struct {
-	ProviderName     string  `json:"provider_name"`
-	Model            string  `json:"model"`
-	AvgCostPerToken  float64 `json:"avg_cost_per_token"`
-	TotalCost        float64 `json:"total_cost"`
-	TotalTokens      int64   `json:"total_tokens"`
-	AvgSuccessRate   float64 `json:"avg_success_rate"`
-	RateLimitHits    int64   `json:"rate_limit_hits"`
-	TotalRecords     int64   `json:"total_records"`
+	ProviderName    string  `json:"provider_name"`
+	Model           string  `json:"model"`
+	AvgCostPerToken float64 `json:"avg_cost_per_token"`
+	TotalCost       float64 `json:"total_cost"`
+	TotalTokens     int64   `json:"total_tokens"`
+	AvgSuccessRate  float64 `json:"avg_success_rate"`
+	RateLimitHits   int64   `json:"rate_limit_hits"`
+	TotalRecords    int64   `json:"total_records"`
 }
 
 // CostSavingsReport represents cost optimization performance
 type CostSavingsReport struct {
-	TotalOptimizations    int64   `json:"total_optimizations"`
+	TotalOptimizations   int64   `json:"total_optimizations"`
 	TotalSavings         float64 `json:"total_savings"`
 	AvgSavingsPerRequest float64 `json:"avg_savings_per_request"`
 	TotalOriginalCost    float64 `json:"total_original_cost"`
 	TotalActualCost      float64 `json:"total_actual_cost"`
 	SavingsPercentage    float64 `json:"savings_percentage"`
-}
\ No newline at end of file
+}
+
+// StoredRateLimit is a persisted rate limit status
+type StoredRateLimit struct {
+	ProviderName      string
+	Model             string
+	RequestsPerMinute int64
+	RequestsRemaining int64
+	TokensPerMinute   int64
+	TokensRemaining   int64
+	ResetTime         time.Time
+}
+
+// StoredProviderHealth aggregates the persisted metrics of a provider
+type StoredProviderHealth struct {
+	Requests     int64
+	Failures     int64
+	AvgLatencyMs float64
+}
@@ -0,0 +1,63 @@
+package enhanced
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/audit"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/injection"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/middleware"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/requestid"
+)
+
+// SetPromptInjectionDetector sets the detector that flags prompts trying to
+// override the system instructions or exfiltrate hidden context; nil turns
+// detection off
+func (es *EnhancedSystem) SetPromptInjectionDetector(detector *injection.Detector) {
+	es.promptInjection = detector
+}
+
+// checkPromptInjection records a flagged prompt in the audit log and
+// returns the finding, or an error wrapping injection.ErrBlocked when the
+// key's policy blocks it
+func (es *EnhancedSystem) checkPromptInjection(ctx context.Context, input RequestInput) (*injection.Finding, error) {
+	if es.promptInjection == nil {
+		return nil, nil
+	}
+	keyID := middleware.KeyIDFromContext(ctx)
+	finding := es.promptInjection.Check(ctx, keyID, input.Content)
+	if finding == nil {
+		return nil, nil
+	}
+
+	id := requestid.FromContext(ctx)
+	blocked := finding.Action == injection.ActionBlock
+	actor := keyID
+	if actor == "" {
+		actor = "anonymous"
+	}
+	status := http.StatusOK
+	if blocked {
+		status = http.StatusForbidden
+	}
+	logger.WithField(requestid.Field, id).Warnf("Prompt injection suspected (%s): %s", finding.Action, finding.Summary())
+	if err := es.RecordAuditEvent(audit.Event{
+		Time:      time.Now(),
+		Actor:     actor,
+		Action:    "prompt_injection." + string(finding.Action),
+		Resource:  "/api/v1/requests/" + id,
+		Success:   !blocked,
+		Status:    status,
+		RequestID: id,
+		Detail:    finding.Summary(),
+	}); err != nil {
+		logger.WithField(requestid.Field, id).Warnf("Failed to audit prompt injection: %v", err)
+	}
+
+	if blocked {
+		return finding, fmt.Errorf("%w: %s", injection.ErrBlocked, finding.Summary())
+	}
+	return finding, nil
+}
@@ -0,0 +1,113 @@
+package enhanced
+
+import (
+	"strings"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/selection"
+)
+
+// Defaults for providers whose configuration leaves them unset
+const (
+	defaultMaxTokens         = 4096
+	defaultCostPerToken      = 0.00003
+	defaultRequestsPerMinute = 60
+)
+
+// defaultCapabilities are assumed for providers that declare none
+var defaultCapabilities = []string{"reasoning", "creative", "factual"}
+
+// NewProvider converts a canonical provider configuration into a provider.
+// Endpoint and script model sources leave Models empty until model discovery
+// resolves them
+func NewProvider(cfg config.ProviderConfig) *Provider {
+	capabilities := cfg.DeclaredCapabilities
+	if len(capabilities) == 0 {
+		capabilities = append([]string(nil), defaultCapabilities...)
+	}
+
+	rateLimits := map[string]int64{"requests_per_minute": defaultRequestsPerMinute}
+	for name, limit := range cfg.Limits {
+		rateLimits[name] = int64(limit)
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = cfg.URL
+	}
+	// An account's usage tier implies its tokens per minute unless tpm is set
+	if _, ok := rateLimits["tokens_per_minute"]; !ok {
+		if tpm, ok := selection.UsageTierTokensPerMinute(endpoint, cfg.Limits["usage_tier"]); ok {
+			rateLimits["tokens_per_minute"] = tpm
+		}
+	}
+
+	maxTokens := int64(defaultMaxTokens)
+	if limit, ok := cfg.Limits["max_tokens"]; ok {
+		maxTokens = int64(limit)
+	}
+
+	costPerToken := cfg.CostTracking.CostPerToken
+	if costPerToken == 0 {
+		costPerToken = defaultCostPerToken
+	}
+
+	models := cfg.Models
+	if len(models) == 0 {
+		models = cfg.ModelsSource.List()
+	}
+
+	metadata := make(map[string]interface{})
+	if cfg.Region != "" {
+		metadata["region"] = cfg.Region
+	}
+	if cfg.Priority != 0 {
+		metadata["priority"] = cfg.Priority
+	}
+	if cfg.Description != "" {
+		metadata["description"] = cfg.Description
+	}
+	if account := selection.ThroughputAccount(endpoint, cfg.APIKey); account != "" {
+		metadata["account"] = account
+	}
+
+	return &Provider{
+		Name:         cfg.Name,
+		BaseURL:      endpoint,
+		Models:       models,
+		Tier:         cfg.Tier,
+		MaxTokens:    maxTokens,
+		CostPerToken: costPerToken,
+		Capabilities: capabilities,
+		RateLimits:   rateLimits,
+		Metadata:     metadata,
+		LastUpdated:  time.Now(),
+	}
+}
+
+// Config converts the provider back into its canonical configuration
+func (p *Provider) Config() config.ProviderConfig {
+	limits := make(map[string]int, len(p.RateLimits)+1)
+	for name, limit := range p.RateLimits {
+		limits[name] = int(limit)
+	}
+	limits["max_tokens"] = int(p.MaxTokens)
+
+	cfg := config.ProviderConfig{
+		ID:                   strings.ToLower(strings.ReplaceAll(p.Name, " ", "_")),
+		Name:                 p.Name,
+		Tier:                 p.Tier,
+		Endpoint:             p.BaseURL,
+		Enabled:              true,
+		ModelsSource:         config.ModelsSource{Type: "list", Value: p.Models},
+		Models:               p.Models,
+		CostTracking:         config.CostTracking{CostPerToken: p.CostPerToken},
+		DeclaredCapabilities: p.Capabilities,
+		Limits:               limits,
+		Metadata:             map[string]string{"source": "enhanced"},
+	}
+	cfg.Region, _ = p.Metadata["region"].(string)
+	cfg.Priority, _ = p.Metadata["priority"].(int)
+	cfg.Description, _ = p.Metadata["description"].(string)
+	return cfg
+}
@@ -3,9 +3,13 @@ package enhanced
 import (
 	"sync"
 	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/selection"
 )
 
-// ProviderHealthMetrics represents health metrics for a provider
+// ProviderHealthMetrics represents health metrics for a provider. The rates
+// and counters cover its lifetime; Windowed covers its recent requests and,
+// when set, decides the status
 type ProviderHealthMetrics struct {
 	SuccessRate      float64   `json:"success_rate"`
 	AverageLatency   float64   `json:"average_latency"`
@@ -15,6 +19,7 @@ type ProviderHealthMetrics struct {
 	FailedRequests   int64     `json:"failed_requests"`
 	LastUpdated      time.Time `json:"last_updated"`
 	Status           string    `json:"status"`
+	Windowed         *selection.WindowedMetrics `json:"windowed,omitempty"`
 }
 
 // UsageRecord represents a usage record for rate limiting
@@ -27,21 +32,49 @@ type UsageRecord struct {
 // RateLimitStatus represents the rate limit status for a provider
 type RateLimitStatus struct {
 	RequestsPerMinute int64     `json:"requests_per_minute"`
+	RequestsRemaining int64     `json:"requests_remaining"`
+	TokensPerMinute   int64     `json:"tokens_per_minute"`
+	TokensRemaining   int64     `json:"tokens_remaining"`
 	CurrentUsage      int64     `json:"current_usage"`
 	ResetTime         time.Time `json:"reset_time"`
+	LastRateLimitHit  time.Time `json:"last_rate_limit_hit"`
 	IsLimited         bool      `json:"is_limited"`
 }
 
 // ProviderHealthMonitor monitors provider health and performance
 type ProviderHealthMonitor struct {
 	providers map[string]*ProviderHealthMetrics
+	windows   map[string]*selection.WindowedStats
+	halfLife  time.Duration
 	mutex     sync.RWMutex
 }
 
-// NewProviderHealthMonitor creates a new provider health monitor
+// NewProviderHealthMonitor creates a new provider health monitor whose
+// windowed metrics decay with selection.DefaultMetricsHalfLife
 func NewProviderHealthMonitor() *ProviderHealthMonitor {
 	return &ProviderHealthMonitor{
 		providers: make(map[string]*ProviderHealthMetrics),
+		windows:   make(map[string]*selection.WindowedStats),
+		halfLife:  selection.DefaultMetricsHalfLife,
+	}
+}
+
+// SetHalfLife sets how long it takes a request's weight in the windowed
+// metrics to halve. Windows restart from the lifetime metrics; zero keeps
+// no window and derives the status from the lifetime error rate
+func (phm *ProviderHealthMonitor) SetHalfLife(halfLife time.Duration) {
+	phm.mutex.Lock()
+	defer phm.mutex.Unlock()
+
+	phm.halfLife = halfLife
+	phm.windows = make(map[string]*selection.WindowedStats)
+	if halfLife <= 0 {
+		return
+	}
+	for name, metrics := range phm.providers {
+		window := selection.NewWindowedStats(halfLife)
+		window.Seed(metrics.LastUpdated, metrics.TotalRequests, metrics.FailedRequests, metrics.AverageLatency)
+		phm.windows[name] = window
 	}
 }
 
@@ -81,11 +114,58 @@ func (phm *ProviderHealthMonitor) UpdateMetrics(providerName string, success boo
 	}
 
 	metrics.LastUpdated = time.Now()
+	metrics.updateStatus()
+
+	if phm.halfLife > 0 {
+		window, exists := phm.windows[providerName]
+		if !exists {
+			window = selection.NewWindowedStats(phm.halfLife)
+			phm.windows[providerName] = window
+		}
+		window.Record(metrics.LastUpdated, latency, success)
+	}
+}
+
+// Restore sets a provider's counters from persisted totals last updated at,
+// e.g. after a restart. The window counts them as requests made at that time
+func (phm *ProviderHealthMonitor) Restore(providerName string, total, failed int64, averageLatency float64, at time.Time) {
+	if total <= 0 {
+		return
+	}
 
-	// Update status based on metrics
-	if metrics.ErrorRate > 0.5 {
+	phm.mutex.Lock()
+	defer phm.mutex.Unlock()
+
+	metrics := &ProviderHealthMetrics{
+		TotalRequests:      total,
+		SuccessfulRequests: total - failed,
+		FailedRequests:     failed,
+		SuccessRate:        float64(total-failed) / float64(total),
+		ErrorRate:          float64(failed) / float64(total),
+		AverageLatency:     averageLatency,
+		LastUpdated:        time.Now(),
+	}
+	metrics.updateStatus()
+	phm.providers[providerName] = metrics
+
+	if phm.halfLife > 0 {
+		window := selection.NewWindowedStats(phm.halfLife)
+		window.Seed(at, total, failed, averageLatency)
+		phm.windows[providerName] = window
+	}
+}
+
+// updateStatus derives the status from the error rate, the windowed one
+// when there is a window. It moves toward no errors as the requests it was
+// measured on age, so old failures stop marking a provider unhealthy
+func (metrics *ProviderHealthMetrics) updateStatus() {
+	errorRate := metrics.ErrorRate
+	if metrics.Windowed != nil {
+		errorRate = selection.Blend(1-metrics.Windowed.SuccessRate, 0, metrics.Windowed.Requests)
+	}
+	if errorRate > 0.5 {
 		metrics.Status = "degraded"
-	} else if metrics.ErrorRate > 0.2 {
+	} else if errorRate > 0.2 {
 		metrics.Status = "warning"
 	} else {
 		metrics.Status = "healthy"
@@ -98,39 +178,42 @@ func (phm *ProviderHealthMonitor) GetMetrics(providerName string) *ProviderHealt
 	defer phm.mutex.RUnlock()
 
 	if metrics, exists := phm.providers[providerName]; exists {
-		// Return a copy to avoid race conditions
-		return &ProviderHealthMetrics{
-			SuccessRate:        metrics.SuccessRate,
-			AverageLatency:     metrics.AverageLatency,
-			ErrorRate:          metrics.ErrorRate,
-			TotalRequests:      metrics.TotalRequests,
-			SuccessfulRequests: metrics.SuccessfulRequests,
-			FailedRequests:     metrics.FailedRequests,
-			LastUpdated:        metrics.LastUpdated,
-			Status:             metrics.Status,
-		}
+		return phm.snapshot(providerName, metrics, time.Now())
 	}
 
 	return nil
 }
 
+// snapshot copies metrics, to avoid race conditions, with the window and
+// the status as of now. The caller holds the lock
+func (phm *ProviderHealthMonitor) snapshot(providerName string, metrics *ProviderHealthMetrics, now time.Time) *ProviderHealthMetrics {
+	snapshot := &ProviderHealthMetrics{
+		SuccessRate:        metrics.SuccessRate,
+		AverageLatency:     metrics.AverageLatency,
+		ErrorRate:          metrics.ErrorRate,
+		TotalRequests:      metrics.TotalRequests,
+		SuccessfulRequests: metrics.SuccessfulRequests,
+		FailedRequests:     metrics.FailedRequests,
+		LastUpdated:        metrics.LastUpdated,
+		Status:             metrics.Status,
+	}
+	if window, exists := phm.windows[providerName]; exists {
+		windowed := window.At(now)
+		snapshot.Windowed = &windowed
+		snapshot.updateStatus()
+	}
+	return snapshot
+}
+
 // GetAllMetrics returns health metrics for all providers
 func (phm *ProviderHealthMonitor) GetAllMetrics() map[string]*ProviderHealthMetrics {
 	phm.mutex.RLock()
 	defer phm.mutex.RUnlock()
 
+	now := time.Now()
 	result := make(map[string]*ProviderHealthMetrics)
 	for name, metrics := range phm.providers {
-		result[name] = &ProviderHealthMetrics{
-			SuccessRate:        metrics.SuccessRate,
-			AverageLatency:     metrics.AverageLatency,
-			ErrorRate:          metrics.ErrorRate,
-			TotalRequests:      metrics.TotalRequests,
-			SuccessfulRequests: metrics.SuccessfulRequests,
-			FailedRequests:     metrics.FailedRequests,
-			LastUpdated:        metrics.LastUpdated,
-			Status:             metrics.Status,
-		}
+		result[name] = phm.snapshot(name, metrics, now)
 	}
 
 	return result
@@ -152,4 +235,5 @@ func (phm *ProviderHealthMonitor) ResetMetrics(providerName string) {
 	defer phm.mutex.Unlock()
 
 	delete(phm.providers, providerName)
+	delete(phm.windows, providerName)
 }
\ No newline at end of file
@@ -0,0 +1,115 @@
+package enhanced
+
+import (
+	"fmt"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+)
+
+// ErrProviderYAMLDirUnset is returned when provider YAML files are synced
+// without an output directory
+var ErrProviderYAMLDirUnset = config.ErrYAMLDirUnset
+
+// SetProviderYAMLDir sets the directory provider YAML files are synced into
+func (es *EnhancedSystem) SetProviderYAMLDir(dir string) {
+	es.yamlSync = config.NewYAMLSync(dir)
+}
+
+// DiffProviderYAMLs reports what SyncProviderYAMLs would change, with a
+// unified diff per file, without writing anything
+func (es *EnhancedSystem) DiffProviderYAMLs() ([]config.YAMLChange, error) {
+	if es.yamlSync == nil {
+		return nil, ErrProviderYAMLDirUnset
+	}
+	return es.yamlSync.Plan(es.providerYAMLFiles())
+}
+
+// SyncProviderYAMLs writes one YAML file per provider. Providers whose
+// generated YAML has not changed since the last sync are skipped, and fields
+// edited by hand are kept unless the generated value changed too
+func (es *EnhancedSystem) SyncProviderYAMLs() ([]config.YAMLChange, error) {
+	if es.yamlSync == nil {
+		return nil, ErrProviderYAMLDirUnset
+	}
+	return es.yamlSync.Apply(es.providerYAMLFiles())
+}
+
+// providerYAMLFiles generates the YAML of every provider keyed by file name
+func (es *EnhancedSystem) providerYAMLFiles() map[string][]byte {
+	files := make(map[string][]byte, len(es.providers))
+	for _, provider := range es.providers {
+		yaml, err := es.GenerateProviderYAML(provider.Name)
+		if err != nil {
+			continue
+		}
+		files[config.ProviderYAMLFileName(provider.Name)] = []byte(yaml)
+	}
+	return files
+}
+
+// defaultProviderYAMLDir is checked for consistency when PROVIDER_YAML_DIR is unset
+const defaultProviderYAMLDir = "configs"
+
+// SetProvidersCSVPath sets the provider CSV that consistency checks compare against
+func (es *EnhancedSystem) SetProvidersCSVPath(path string) {
+	es.providersCSVPath = path
+}
+
+// providerYAMLDir is the directory consistency checks look at
+func (es *EnhancedSystem) providerYAMLDir() string {
+	if es.yamlSync != nil {
+		return es.yamlSync.Dir()
+	}
+	return defaultProviderYAMLDir
+}
+
+// CheckProviderConsistency cross-checks the provider CSV, the provider YAML
+// files and the loaded providers
+func (es *EnhancedSystem) CheckProviderConsistency() (*config.ConsistencyReport, error) {
+	runtime := make([]config.RuntimeProvider, 0, len(es.providers))
+	for _, provider := range es.providers {
+		runtime = append(runtime, config.RuntimeProvider{
+			Name:     provider.Name,
+			Tier:     string(provider.Tier),
+			Endpoint: provider.BaseURL,
+		})
+	}
+	return config.CheckConsistency(es.providersCSVPath, es.providerYAMLDir(), runtime)
+}
+
+// ReconcileProviderConsistency fixes the drift that can be fixed without a
+// restart: orphaned YAML files are archived, files are renamed to their
+// canonical names, and missing or stale files are regenerated from the loaded
+// providers. It needs a provider YAML directory
+func (es *EnhancedSystem) ReconcileProviderConsistency() (*config.ReconcileResult, error) {
+	if es.yamlSync == nil {
+		return nil, ErrProviderYAMLDirUnset
+	}
+
+	report, err := es.CheckProviderConsistency()
+	if err != nil {
+		return nil, err
+	}
+	actions, err := config.ReconcileYAMLDir(report)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := es.SyncProviderYAMLs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to sync provider YAML files: %w", err)
+	}
+	for _, change := range changes {
+		if change.Status != config.YAMLUnchanged {
+			actions = append(actions, fmt.Sprintf("%s %s", change.Status, change.File))
+		}
+	}
+
+	report, err = es.CheckProviderConsistency()
+	if err != nil {
+		return nil, err
+	}
+	if actions == nil {
+		actions = []string{}
+	}
+	return &config.ReconcileResult{Actions: actions, Changes: changes, Report: report}, nil
+}
@@ -32,12 +32,45 @@ type ModelRateLimit struct {
 	ConsecutiveHits     int
 }
 
-// NewRateLimitManager creates a new rate limit manager
+// NewRateLimitManager creates a new rate limit manager, restoring the limits
+// in storage that have not reset yet
 func NewRateLimitManager(storage *MetricsStorage) *RateLimitManager {
-	return &RateLimitManager{
+	manager := &RateLimitManager{
 		providerLimits: make(map[string]*ProviderRateLimits),
 		metricsStorage: storage,
 	}
+	if storage != nil {
+		manager.restore(storage)
+	}
+	return manager
+}
+
+// restore loads the persisted rate limit status
+func (r *RateLimitManager) restore(storage *MetricsStorage) {
+	limits, err := storage.GetRateLimitStatus()
+	if err != nil {
+		logger.Warnf("Failed to load stored rate limits: %v", err)
+		return
+	}
+	for _, limit := range limits {
+		providerLimits, exists := r.providerLimits[limit.ProviderName]
+		if !exists {
+			providerLimits = &ProviderRateLimits{
+				ProviderName: limit.ProviderName,
+				Models:       make(map[string]*ModelRateLimit),
+			}
+			r.providerLimits[limit.ProviderName] = providerLimits
+		}
+		providerLimits.Models[limit.Model] = &ModelRateLimit{
+			Model:             limit.Model,
+			RequestsPerMinute: limit.RequestsPerMinute,
+			RequestsRemaining: limit.RequestsRemaining,
+			TokensPerMinute:   limit.TokensPerMinute,
+			TokensRemaining:   limit.TokensRemaining,
+			ResetTime:         limit.ResetTime,
+		}
+		providerLimits.LastUpdated = time.Now()
+	}
 }
 
 // UpdateRateLimitStatus updates rate limit information from API response headers
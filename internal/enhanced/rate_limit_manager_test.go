@@ -0,0 +1,68 @@
+package enhanced
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestRateLimitManagerRestore checks that limits which have not reset
+// survive a restart and those which have are forgotten
+func TestRateLimitManagerRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.db")
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	storage, err := NewMetricsStorage(path)
+	if err != nil {
+		t.Fatalf("NewMetricsStorage: %v", err)
+	}
+	manager := NewRateLimitManager(storage)
+	if err := manager.UpdateRateLimitStatus("openai", "gpt-4o", map[string]string{
+		"x-ratelimit-limit-requests":     "500",
+		"x-ratelimit-remaining-requests": "0",
+		"x-ratelimit-limit-tokens":       "30000",
+		"x-ratelimit-remaining-tokens":   "1200",
+		"x-ratelimit-reset":              strconv.FormatInt(reset.Unix(), 10),
+	}); err != nil {
+		t.Fatalf("UpdateRateLimitStatus: %v", err)
+	}
+	if err := manager.UpdateRateLimitStatus("openai", "gpt-4o-mini", map[string]string{
+		"x-ratelimit-limit-requests": "500",
+		"x-ratelimit-reset":          strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10),
+	}); err != nil {
+		t.Fatalf("UpdateRateLimitStatus: %v", err)
+	}
+	if err := storage.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	storage, err = NewMetricsStorage(path)
+	if err != nil {
+		t.Fatalf("reopening storage: %v", err)
+	}
+	defer storage.Close()
+	restored := NewRateLimitManager(storage)
+
+	status := restored.GetRateLimitStatus("openai", "gpt-4o")
+	if status == nil {
+		t.Fatal("limit of gpt-4o was not restored")
+	}
+	want := RateLimitStatus{
+		RequestsPerMinute: 500,
+		RequestsRemaining: 0,
+		TokensPerMinute:   30000,
+		TokensRemaining:   1200,
+		ResetTime:         reset,
+	}
+	if status.RequestsPerMinute != want.RequestsPerMinute || status.RequestsRemaining != want.RequestsRemaining ||
+		status.TokensPerMinute != want.TokensPerMinute || status.TokensRemaining != want.TokensRemaining {
+		t.Errorf("restored status = %+v, want %+v", *status, want)
+	}
+	if !status.ResetTime.Equal(want.ResetTime) {
+		t.Errorf("restored reset time = %v, want %v", status.ResetTime, want.ResetTime)
+	}
+	if status := restored.GetRateLimitStatus("openai", "gpt-4o-mini"); status != nil {
+		t.Errorf("limit that already reset was restored: %+v", *status)
+	}
+}
@@ -0,0 +1,75 @@
+package enhanced
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/health"
+)
+
+// DefaultMinHealthyProviders is the number of healthy providers readiness
+// requires unless SetMinHealthyProviders changes it
+const DefaultMinHealthyProviders = 1
+
+// readinessTimeout bounds each dependency check of a readiness probe
+const readinessTimeout = 2 * time.Second
+
+// SetMinHealthyProviders sets how many providers must be healthy for the
+// system to be ready
+func (es *EnhancedSystem) SetMinHealthyProviders(count int) {
+	es.minHealthyProviders = count
+}
+
+// CheckReadiness checks that the system can serve requests: startup
+// validation has passed, it is not draining, the metrics store and shared state answer when configured,
+// providers are loaded and enough of them are healthy
+func (es *EnhancedSystem) CheckReadiness(ctx context.Context) *health.Report {
+	report := health.NewReport()
+	es.readinessStartup(report)
+
+	if es.IsDraining() {
+		report.Fail("lifecycle", "draining for shutdown")
+	} else if es.IsTerminating() {
+		report.Fail("lifecycle", "terminating, draining soon")
+	} else {
+		report.Pass("lifecycle", "accepting requests")
+	}
+
+	if es.metricsStorage != nil {
+		checkCtx, cancel := context.WithTimeout(ctx, readinessTimeout)
+		err := es.metricsStorage.Ping(checkCtx)
+		cancel()
+		if err != nil {
+			report.Fail("metrics_store", err.Error())
+		} else {
+			report.Pass("metrics_store", "reachable")
+		}
+	}
+
+	if es.sharedState != nil {
+		checkCtx, cancel := context.WithTimeout(ctx, readinessTimeout)
+		err := es.sharedState.Ping(checkCtx)
+		cancel()
+		if err != nil {
+			report.Fail("shared_state", err.Error())
+		} else {
+			report.Pass("shared_state", "reachable")
+		}
+	}
+
+	if len(es.providers) == 0 {
+		report.Fail("providers", "no providers loaded")
+	} else {
+		report.Pass("providers", fmt.Sprintf("%d loaded", len(es.providers)))
+	}
+
+	healthy := len(es.GetHealthyProviders())
+	detail := fmt.Sprintf("%d of %d healthy, %d required", healthy, len(es.providers), es.minHealthyProviders)
+	if healthy < es.minHealthyProviders {
+		report.Fail("healthy_providers", detail)
+	} else {
+		report.Pass("healthy_providers", detail)
+	}
+	return report
+}
@@ -0,0 +1,29 @@
+package enhanced
+
+import (
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/reasoning"
+)
+
+// SetReasoningModels sets the table of reasoning models, which decides the
+// providers and models requests with a reasoning effort may use and how the
+// effort is sent to each
+func (es *EnhancedSystem) SetReasoningModels(table *reasoning.Table) {
+	es.reasoningModels = table
+	if selector := es.builtinSelector(); selector != nil {
+		selector.SetReasoningModels(table)
+	}
+}
+
+// ReasoningModels returns the table of reasoning models
+func (es *EnhancedSystem) ReasoningModels() *reasoning.Table {
+	return es.reasoningModels
+}
+
+// reasoningTokens returns the reasoning tokens model is expected to spend on
+// the request, 0 when it asks for no effort or the model does not reason
+func (es *EnhancedSystem) reasoningTokens(input RequestInput, model string) int64 {
+	if setting := es.reasoningModels.Setting(model, input.Effort()); setting != nil {
+		return setting.BudgetTokens
+	}
+	return 0
+}
@@ -0,0 +1,325 @@
+package enhanced
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/middleware"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/requestid"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/retention"
+)
+
+// ErrRequestNotFound is returned for request IDs the history does not hold
+var ErrRequestNotFound = errors.New("request not found")
+
+// ErrInvalidCursor is returned for page cursors the history did not issue
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Request statuses; running requests are not in the history yet
+const (
+	RequestRunning   = "running"
+	RequestSucceeded = "succeeded"
+	RequestFailed    = "failed"
+	RequestCancelled = "cancelled"
+)
+
+// Page sizes for request history listings
+const (
+	DefaultRequestPageSize = 50
+	MaxRequestPageSize     = 500
+)
+
+// defaultRequestHistorySize is how many requests are kept without metrics storage
+const defaultRequestHistorySize = 1000
+
+// RequestRecord is a processed request with its result, as kept in the history
+type RequestRecord struct {
+	ID         string           `json:"id"`
+	KeyID      string           `json:"key_id,omitempty"`
+	Tenant     string           `json:"tenant,omitempty"`
+	SessionID  string           `json:"session_id,omitempty"`
+	Status     string           `json:"status"`
+	Provider   string           `json:"provider,omitempty"`
+	Model      string           `json:"model,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+	DurationMs int64            `json:"duration_ms"`
+	Cost       float64          `json:"cost"`
+	Input      RequestInput     `json:"input"`
+	Response   *ProcessResponse `json:"response,omitempty"`
+	Error      string           `json:"error,omitempty"`
+
+	// seq orders records and is the pagination cursor
+	seq int64
+}
+
+// RequestQuery filters and pages the request history. Empty fields match
+// every request
+type RequestQuery struct {
+	KeyID     string
+	Tenant    string
+	SessionID string
+	Status    string
+	Provider  string
+	Since     time.Time
+	Until     time.Time
+	// Limit is the page size, DefaultRequestPageSize when zero
+	Limit int
+	// Cursor is the NextCursor of the previous page
+	Cursor string
+}
+
+// RequestPage is one page of the request history, newest first
+type RequestPage struct {
+	Requests []RequestRecord `json:"requests"`
+	// NextCursor fetches the following page; empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// RequestHistory stores processed requests for later retrieval
+type RequestHistory interface {
+	RecordRequestHistory(record RequestRecord) error
+	// GetRequestRecord returns the latest request recorded with id that
+	// passes the key and tenant filters of scope, so a caller reusing another
+	// caller's request ID still finds its own request
+	GetRequestRecord(id string, scope RequestQuery) (*RequestRecord, error)
+	ListRequestRecords(query RequestQuery) (*RequestPage, error)
+	// EraseRequestRecords deletes the records matching selector and returns
+	// how many were deleted per store
+	EraseRequestRecords(selector retention.Selector) (map[string]int, error)
+}
+
+// matches reports whether record passes the filters of q
+func (q RequestQuery) matches(record *RequestRecord) bool {
+	switch {
+	case q.KeyID != "" && record.KeyID != q.KeyID,
+		q.Tenant != "" && record.Tenant != q.Tenant,
+		q.SessionID != "" && record.SessionID != q.SessionID,
+		q.Status != "" && record.Status != q.Status,
+		q.Provider != "" && record.Provider != q.Provider,
+		!q.Since.IsZero() && record.CreatedAt.Before(q.Since),
+		!q.Until.IsZero() && !record.CreatedAt.Before(q.Until):
+		return false
+	}
+	return true
+}
+
+// matchesOwner reports whether record belongs to the key and tenant of q
+func (q RequestQuery) matchesOwner(record *RequestRecord) bool {
+	return (q.KeyID == "" || record.KeyID == q.KeyID) && (q.Tenant == "" || record.Tenant == q.Tenant)
+}
+
+// pageBounds returns the page size and the sequence number to list before,
+// zero for the first page
+func (q RequestQuery) pageBounds() (int, int64, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultRequestPageSize
+	}
+	if limit > MaxRequestPageSize {
+		limit = MaxRequestPageSize
+	}
+	if q.Cursor == "" {
+		return limit, 0, nil
+	}
+	before, err := strconv.ParseInt(q.Cursor, 10, 64)
+	if err != nil || before <= 0 {
+		return 0, 0, fmt.Errorf("%w %q", ErrInvalidCursor, q.Cursor)
+	}
+	return limit, before, nil
+}
+
+// MemoryRequestHistory keeps the most recent requests in memory
+type MemoryRequestHistory struct {
+	records   []RequestRecord
+	size      int
+	retention time.Duration
+	nextSeq   int64
+	mutex     sync.RWMutex
+}
+
+// NewMemoryRequestHistory creates a history holding up to size requests
+func NewMemoryRequestHistory(size int) *MemoryRequestHistory {
+	return &MemoryRequestHistory{size: size}
+}
+
+// RecordRequestHistory adds a request, dropping the oldest when full
+func (h *MemoryRequestHistory) RecordRequestHistory(record RequestRecord) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.nextSeq++
+	record.seq = h.nextSeq
+	h.records = append(h.records, record)
+	if len(h.records) > h.size {
+		h.records = h.records[len(h.records)-h.size:]
+	}
+	return nil
+}
+
+// SetRetention sets how long requests are kept; zero or less keeps them
+// until the history is full
+func (h *MemoryRequestHistory) SetRetention(retention time.Duration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.retention = retention
+}
+
+// Prune drops requests past retention and returns how many were dropped
+func (h *MemoryRequestHistory) Prune() int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.retention <= 0 {
+		return 0
+	}
+	cutoff := time.Now().Add(-h.retention)
+	drop := 0
+	for drop < len(h.records) && h.records[drop].CreatedAt.Before(cutoff) {
+		drop++
+	}
+	h.records = append([]RequestRecord(nil), h.records[drop:]...)
+	return drop
+}
+
+// EraseRequestRecords deletes the requests matching selector
+func (h *MemoryRequestHistory) EraseRequestRecords(selector retention.Selector) (map[string]int, error) {
+	if err := selector.Validate(); err != nil {
+		return nil, err
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	kept := make([]RequestRecord, 0, len(h.records))
+	for _, record := range h.records {
+		if !selectsRecord(selector, &record) {
+			kept = append(kept, record)
+		}
+	}
+	erased := len(h.records) - len(kept)
+	h.records = kept
+	return map[string]int{"request_history": erased}, nil
+}
+
+// selectsRecord reports whether record falls under selector
+func selectsRecord(selector retention.Selector, record *RequestRecord) bool {
+	switch {
+	case selector.KeyID != "":
+		return record.KeyID == selector.KeyID
+	case selector.SessionID != "":
+		return record.SessionID == selector.SessionID
+	default:
+		return record.ID == selector.RequestID
+	}
+}
+
+// GetRequestRecord returns the latest request recorded with id within scope
+func (h *MemoryRequestHistory) GetRequestRecord(id string, scope RequestQuery) (*RequestRecord, error) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	for i := len(h.records) - 1; i >= 0; i-- {
+		if h.records[i].ID == id && scope.matchesOwner(&h.records[i]) {
+			record := h.records[i]
+			return &record, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrRequestNotFound, id)
+}
+
+// ListRequestRecords returns a page of the requests matching query
+func (h *MemoryRequestHistory) ListRequestRecords(query RequestQuery) (*RequestPage, error) {
+	limit, before, err := query.pageBounds()
+	if err != nil {
+		return nil, err
+	}
+
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	page := &RequestPage{Requests: []RequestRecord{}}
+	for i := len(h.records) - 1; i >= 0; i-- {
+		record := &h.records[i]
+		if before != 0 && record.seq >= before || !query.matches(record) {
+			continue
+		}
+		if len(page.Requests) == limit {
+			page.NextCursor = strconv.FormatInt(page.Requests[limit-1].seq, 10)
+			break
+		}
+		page.Requests = append(page.Requests, *record)
+	}
+	return page, nil
+}
+
+// GetRequest returns a request by ID, running or processed. Callers with an
+// API key or tenant only find their own requests
+func (es *EnhancedSystem) GetRequest(ctx context.Context, id string) (*RequestRecord, error) {
+	scope := scopeRequestQuery(ctx, RequestQuery{})
+	if record, running := es.runningRequest(id); running && scope.matchesOwner(record) {
+		return record, nil
+	}
+	return es.requestHistory.GetRequestRecord(id, scope)
+}
+
+// ListRequests returns a page of processed requests, newest first. Callers
+// with an API key or tenant only see their own requests, whatever query says
+func (es *EnhancedSystem) ListRequests(ctx context.Context, query RequestQuery) (*RequestPage, error) {
+	return es.requestHistory.ListRequestRecords(scopeRequestQuery(ctx, query))
+}
+
+// scopeRequestQuery restricts query to the caller's key and tenant
+func scopeRequestQuery(ctx context.Context, query RequestQuery) RequestQuery {
+	if keyID := middleware.KeyIDFromContext(ctx); keyID != "" {
+		query.KeyID = keyID
+	}
+	if tenant := middleware.TenantFromContext(ctx); tenant != "" {
+		query.Tenant = tenant
+	}
+	return query
+}
+
+// sessionID returns the session a request belongs to, empty when it names none
+func sessionID(input RequestInput) string {
+	session, _ := input.Metadata[retention.SessionMetadataKey].(string)
+	return session
+}
+
+// recordRequestHistory keeps the outcome of a request for GetRequest
+func (es *EnhancedSystem) recordRequestHistory(ctx context.Context, input RequestInput, startTime time.Time, response *ProcessResponse, err error) {
+	record := RequestRecord{
+		ID:         requestid.FromContext(ctx),
+		KeyID:      middleware.KeyIDFromContext(ctx),
+		Tenant:     middleware.TenantFromContext(ctx),
+		SessionID:  sessionID(input),
+		Status:     RequestSucceeded,
+		CreatedAt:  startTime,
+		DurationMs: time.Since(startTime).Milliseconds(),
+		Input:      input,
+		Response:   response,
+	}
+	if record.ID == "" {
+		record.ID = requestid.New()
+	}
+	if response != nil {
+		record.Provider = response.Provider.Name
+		record.Model = response.Model
+		record.Cost = response.Cost
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		record.Status = RequestCancelled
+		record.Error = err.Error()
+	case err != nil:
+		record.Status = RequestFailed
+		record.Error = err.Error()
+	}
+	es.scrubRecord(&record)
+	if err := es.requestHistory.RecordRequestHistory(record); err != nil {
+		logger.WithField(requestid.Field, record.ID).Warnf("Failed to store request history: %v", err)
+	}
+}
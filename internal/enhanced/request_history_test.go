@@ -0,0 +1,65 @@
+package enhanced
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestGetRequestRecordScope checks that a request ID reused by another key
+// or tenant neither hides the caller's own request nor leaks the other one
+func TestGetRequestRecordScope(t *testing.T) {
+	storage, err := NewMetricsStorage(filepath.Join(t.TempDir(), "metrics.db"))
+	if err != nil {
+		t.Fatalf("NewMetricsStorage: %v", err)
+	}
+	t.Cleanup(func() { storage.Close() })
+
+	stores := map[string]RequestHistory{
+		"memory": NewMemoryRequestHistory(10),
+		"sqlite": storage,
+	}
+	for name, history := range stores {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now()
+			for _, record := range []RequestRecord{
+				{ID: "req-1", KeyID: "key_a", Tenant: "acme", Status: RequestSucceeded, Model: "first", CreatedAt: now},
+				// The same client-chosen ID, reused later by other callers
+				{ID: "req-1", KeyID: "key_b", Tenant: "acme", Status: RequestSucceeded, Model: "second", CreatedAt: now.Add(time.Second)},
+				{ID: "req-1", KeyID: "key_c", Tenant: "globex", Status: RequestSucceeded, Model: "third", CreatedAt: now.Add(2 * time.Second)},
+			} {
+				if err := history.RecordRequestHistory(record); err != nil {
+					t.Fatalf("RecordRequestHistory: %v", err)
+				}
+			}
+
+			tests := []struct {
+				scope RequestQuery
+				want  string
+			}{
+				{RequestQuery{}, "third"},
+				{RequestQuery{KeyID: "key_a"}, "first"},
+				{RequestQuery{KeyID: "key_b", Tenant: "acme"}, "second"},
+				{RequestQuery{Tenant: "acme"}, "second"},
+				{RequestQuery{Tenant: "globex"}, "third"},
+				{RequestQuery{KeyID: "key_a", Tenant: "globex"}, ""},
+				{RequestQuery{KeyID: "key_d"}, ""},
+			}
+			for _, tt := range tests {
+				record, err := history.GetRequestRecord("req-1", tt.scope)
+				if tt.want == "" {
+					if !errors.Is(err, ErrRequestNotFound) {
+						t.Errorf("scope %+v = %v, %v; want ErrRequestNotFound", tt.scope, record, err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Errorf("scope %+v: %v", tt.scope, err)
+				} else if record.Model != tt.want {
+					t.Errorf("scope %+v found the %s request, want the %s", tt.scope, record.Model, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package enhanced
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/analytics"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/probe"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/reasoning"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/sampling"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/selection"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/validation"
+)
+
+// Limits applied when validating RequestInput
+const (
+	MaxContentLength           = 200000
+	MaxRequestTokens           = 200000
+	MaxPreferredProviderLength = 128
+	MaxModelLength             = 256
+	MaxMetadataEntries         = 64
+	MaxModeLength              = 128
+	MaxChoices                 = 8
+)
+
+// Validate checks a RequestInput and returns a *validation.ValidationError
+// listing every invalid field, or nil when the input is acceptable
+func (ri RequestInput) Validate() error {
+	ve := &validation.ValidationError{}
+
+	if strings.TrimSpace(ri.Content) == "" {
+		ve.Add("content", "is required")
+	} else if !utf8.ValidString(ri.Content) {
+		ve.Add("content", "must be valid UTF-8")
+	} else if len(ri.Content) > MaxContentLength {
+		ve.Addf("content", "must be at most %d bytes", MaxContentLength)
+	}
+
+	if ri.MaxTokens < 0 {
+		ve.Add("max_tokens", "must not be negative")
+	} else if ri.MaxTokens > MaxRequestTokens {
+		ve.Addf("max_tokens", "must be at most %d", MaxRequestTokens)
+	}
+
+	if err := ri.Sampling().Validate(); err != nil {
+		var invalid *validation.ValidationError
+		if errors.As(err, &invalid) {
+			ve.Errors = append(ve.Errors, invalid.Errors...)
+		}
+	}
+
+	if ri.N < 0 || ri.N > MaxChoices {
+		ve.Addf("n", "must be between 1 and %d", MaxChoices)
+	}
+	if ri.BestOf < 0 || ri.BestOf > MaxChoices {
+		ve.Addf("best_of", "must be between 1 and %d", MaxChoices)
+	} else if ri.BestOf > 0 && ri.BestOf < ri.N {
+		ve.Add("best_of", "must be at least n")
+	}
+
+	if len(ri.PreferredProvider) > MaxPreferredProviderLength {
+		ve.Addf("preferred_provider", "must be at most %d characters", MaxPreferredProviderLength)
+	}
+
+	if len(ri.Model) > MaxModelLength {
+		ve.Addf("model", "must be at most %d characters", MaxModelLength)
+	}
+
+	if len(ri.Metadata) > MaxMetadataEntries {
+		ve.Addf("metadata", "must have at most %d entries", MaxMetadataEntries)
+	}
+	if _, err := analytics.ParseTags(ri.Metadata["tags"]); err != nil {
+		ve.Add("metadata.tags", err.Error())
+	}
+
+	if ri.CostLimit < 0 {
+		ve.Add("cost_limit", "must not be negative")
+	}
+
+	if ri.QualityMin < 0 || ri.QualityMin > 1 {
+		ve.Add("quality_min", "must be between 0 and 1")
+	}
+
+	seenTiers := make(map[tier.Tier]bool)
+	for _, t := range ri.TierPreference {
+		if !t.Valid() {
+			ve.Addf("tier_preference", "unknown tier %q: must be one of %s", t, strings.Join(tier.Names(), ", "))
+		} else if seenTiers[t] {
+			ve.Addf("tier_preference", "tier %s is listed more than once", t)
+		}
+		seenTiers[t] = true
+	}
+
+	if ri.MaxLatencyMs < 0 {
+		ve.Add("max_latency_ms", "must not be negative")
+	}
+
+	if _, err := selection.ParseParetoPolicy(ri.ParetoPolicy); err != nil {
+		ve.Add("pareto_policy", err.Error())
+	}
+
+	for _, name := range ri.RequiredFeatures {
+		if _, ok := probe.ParseFeature(name); !ok {
+			ve.Addf("required_features", "unknown feature %q: must be one of %s", name, strings.Join(featureNames(), ", "))
+		}
+	}
+
+	if _, err := selection.ParseCapabilityExpr(ri.Capabilities); err != nil {
+		ve.Add("capabilities", err.Error())
+	}
+
+	if len(ri.Mode) > MaxModeLength {
+		ve.Addf("mode", "must be at most %d characters", MaxModeLength)
+	}
+
+	switch ri.Strategy {
+	case "", StrategyDirect, StrategySpeculative:
+	default:
+		ve.Addf("strategy", "unknown strategy %q: must be %s or %s", ri.Strategy, StrategyDirect, StrategySpeculative)
+	}
+
+	if _, err := reasoning.ParseEffort(ri.ReasoningEffort); err != nil {
+		ve.Add("reasoning_effort", err.Error())
+	}
+
+	return ve.ErrOrNil()
+}
+
+// Constraints returns the selection constraints carried by the request
+func (ri RequestInput) Constraints() selection.RequestConstraints {
+	policy, _ := selection.ParseParetoPolicy(ri.ParetoPolicy)
+	capabilities, _ := selection.ParseCapabilityExpr(ri.Capabilities)
+	return selection.RequestConstraints{
+		CostLimit:      ri.CostLimit,
+		QualityMin:     ri.QualityMin,
+		TierPreference: ri.TierPreference,
+		MaxLatency:     time.Duration(ri.MaxLatencyMs) * time.Millisecond,
+		ParetoPolicy:   policy,
+		Model:          strings.TrimSpace(ri.Model),
+		Capabilities:   capabilities,
+	}
+}
+
+// Tags returns the analytics tags sent in the request's metadata under
+// "tags", nil when there are none
+func (ri RequestInput) Tags() analytics.Tags {
+	tags, _ := analytics.ParseTags(ri.Metadata["tags"])
+	return tags
+}
+
+// Sampling returns the request's sampling parameters
+func (ri RequestInput) Sampling() sampling.Parameters {
+	return sampling.Parameters{
+		Temperature:      ri.Temperature,
+		TopP:             ri.TopP,
+		FrequencyPenalty: ri.FrequencyPenalty,
+		PresencePenalty:  ri.PresencePenalty,
+		Stop:             ri.Stop,
+		LogitBias:        ri.LogitBias,
+	}
+}
+
+// Choices returns how many completions the request asks for
+func (ri RequestInput) Choices() int {
+	return max(ri.N, 1)
+}
+
+// Candidates returns how many completions are generated for the request,
+// which best_of raises above the number returned
+func (ri RequestInput) Candidates() int {
+	return max(ri.BestOf, ri.Choices())
+}
+
+// Effort returns the reasoning effort the request asks for, empty when none
+func (ri RequestInput) Effort() reasoning.Effort {
+	effort, _ := reasoning.ParseEffort(ri.ReasoningEffort)
+	return effort
+}
+
+// featureNames lists the features a request may require
+func featureNames() []string {
+	names := make([]string, len(probe.Features))
+	for i, feature := range probe.Features {
+		names[i] = string(feature)
+	}
+	return names
+}
@@ -0,0 +1,25 @@
+package enhanced
+
+import (
+	"context"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/middleware"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/postprocess"
+)
+
+// SetResponseProcessors sets the processor chains responses pass through
+// before they are returned
+func (es *EnhancedSystem) SetResponseProcessors(pipelines *postprocess.Pipelines) {
+	es.responseProcessors = pipelines
+}
+
+// postProcess runs response content through the chain for the caller's key
+// or the request's mode, recording the processors applied in the metadata
+func (es *EnhancedSystem) postProcess(ctx context.Context, input RequestInput, response *ProcessResponse) {
+	chain := es.responseProcessors.For(middleware.KeyIDFromContext(ctx), input.Mode)
+	if len(chain) == 0 {
+		return
+	}
+	response.Content = chain.Process(response.Content)
+	response.Metadata["post_processors"] = chain.Names()
+}
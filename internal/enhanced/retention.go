@@ -0,0 +1,191 @@
+package enhanced
+
+import (
+	"context"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/analytics"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/audit"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/requestid"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/retention"
+)
+
+// SetRetentionPolicy sets how long stored request data is kept. It applies
+// to the metrics storage, the in-memory request history and the analytics
+// engine attached so far, so call it after SetMetricsStorage and SetAnalytics
+func (es *EnhancedSystem) SetRetentionPolicy(policy retention.Policy) {
+	es.retentionMutex.Lock()
+	es.retentionPolicy = policy
+	es.retentionMutex.Unlock()
+
+	if es.metricsStorage != nil {
+		es.metricsStorage.SetRetentionPolicy(policy)
+	}
+	if history, ok := es.requestHistory.(*MemoryRequestHistory); ok {
+		history.SetRetention(policy.RequestHistory)
+	}
+	if log, ok := es.auditLog.(*audit.MemoryLog); ok {
+		log.SetRetention(policy.Audit)
+	}
+	if es.analytics != nil {
+		es.analytics.SetRetention(policy.Analytics)
+	}
+}
+
+// RetentionPolicy returns the retention in effect
+func (es *EnhancedSystem) RetentionPolicy() retention.Policy {
+	es.retentionMutex.Lock()
+	defer es.retentionMutex.Unlock()
+	return es.retentionPolicy
+}
+
+// LastPurge returns what the latest purges of the metrics storage and the
+// in-memory stores removed, nil before the first
+func (es *EnhancedSystem) LastPurge() *retention.PurgeReport {
+	es.retentionMutex.Lock()
+	memory := es.lastPurge
+	es.retentionMutex.Unlock()
+
+	var stored *retention.PurgeReport
+	if es.metricsStorage != nil {
+		stored = es.metricsStorage.LastPurge()
+	}
+	if memory == nil || stored == nil {
+		if memory == nil {
+			return stored
+		}
+		return memory
+	}
+
+	report := &retention.PurgeReport{StartedAt: stored.StartedAt, Purged: make(map[string]int64)}
+	if memory.StartedAt.After(report.StartedAt) {
+		report.StartedAt = memory.StartedAt
+	}
+	for _, purged := range []*retention.PurgeReport{stored, memory} {
+		for store, n := range purged.Purged {
+			report.Purged[store] += n
+		}
+	}
+	return report
+}
+
+// StartRetentionPurger drops expired records from the in-memory request
+// history, audit log and analytics engine every interval until ctx is
+// cancelled. The metrics storage purges itself
+func (es *EnhancedSystem) StartRetentionPurger(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				es.purgeMemory()
+			}
+		}
+	}()
+}
+
+// purgeMemory drops expired requests from the in-memory stores
+func (es *EnhancedSystem) purgeMemory() {
+	report := &retention.PurgeReport{StartedAt: time.Now(), Purged: make(map[string]int64)}
+	if history, ok := es.requestHistory.(*MemoryRequestHistory); ok {
+		if n := history.Prune(); n > 0 {
+			report.Purged["request_history"] = int64(n)
+		}
+	}
+	if es.analytics != nil {
+		if n := es.analytics.Prune(); n > 0 {
+			report.Purged["analytics"] = int64(n)
+		}
+	}
+	if log, ok := es.auditLog.(*audit.MemoryLog); ok {
+		if n := log.Prune(); n > 0 {
+			report.Purged["audit_events"] = int64(n)
+		}
+	}
+	for store, n := range report.Purged {
+		logger.Infof("Purged %d expired %s records", n, store)
+	}
+
+	es.retentionMutex.Lock()
+	es.lastPurge = report
+	es.retentionMutex.Unlock()
+}
+
+// EraseData deletes the stored prompts, responses and analytics records of a
+// key, session or request, then checks every store again to confirm nothing
+// is left. requestedBy is recorded in the receipt and the log
+func (es *EnhancedSystem) EraseData(selector retention.Selector, requestedBy string) (*retention.Receipt, error) {
+	if err := selector.Validate(); err != nil {
+		return nil, err
+	}
+
+	erased, err := es.eraseOnce(selector)
+	if err != nil {
+		return nil, err
+	}
+	// A second pass also catches requests that finished during the first
+	remaining, err := es.eraseOnce(selector)
+	if err != nil {
+		return nil, err
+	}
+	verified := true
+	for store, n := range remaining {
+		erased[store] += n
+		if n > 0 {
+			verified = false
+		}
+	}
+
+	receipt := &retention.Receipt{
+		ID:          requestid.New(),
+		Selector:    selector,
+		RequestedBy: requestedBy,
+		CompletedAt: time.Now(),
+		Erased:      erased,
+		Verified:    verified,
+	}
+	logger.WithField("erasure_id", receipt.ID).Infof("Erased stored data of %s for %s: %v (verified: %t)",
+		selector, requestedBy, erased, verified)
+	return receipt, nil
+}
+
+// eraseOnce deletes the data matching selector from every store
+func (es *EnhancedSystem) eraseOnce(selector retention.Selector) (map[string]int, error) {
+	// The analytics engine knows no sessions, so resolve them to request IDs
+	// while the history still holds them
+	requestIDs := map[string]bool{selector.RequestID: selector.RequestID != ""}
+	if selector.SessionID != "" && es.analytics != nil {
+		query := RequestQuery{SessionID: selector.SessionID, Limit: MaxRequestPageSize}
+		for {
+			page, err := es.requestHistory.ListRequestRecords(query)
+			if err != nil {
+				return nil, err
+			}
+			for _, record := range page.Requests {
+				requestIDs[record.ID] = true
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			query.Cursor = page.NextCursor
+		}
+	}
+
+	erased, err := es.requestHistory.EraseRequestRecords(selector)
+	if err != nil {
+		return nil, err
+	}
+	if es.analytics != nil {
+		erased["analytics"] = es.analytics.EraseRequests(func(record analytics.RequestMetrics) bool {
+			if selector.KeyID != "" {
+				return record.KeyID == selector.KeyID
+			}
+			return requestIDs[record.RequestID]
+		})
+	}
+	return erased, nil
+}
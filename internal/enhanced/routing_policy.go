@@ -0,0 +1,35 @@
+package enhanced
+
+import (
+	"context"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/requestid"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/selection"
+)
+
+// SetRoutingPolicies installs time-of-day and load-aware routing policies
+func (es *EnhancedSystem) SetRoutingPolicies(policies *selection.RoutingPolicies) {
+	es.routingPolicies.Store(policies)
+}
+
+// matchRoutingPolicy returns the routing policy in effect for a request. Queue
+// depth is the cluster-wide in-flight count, which includes the request itself
+func (es *EnhancedSystem) matchRoutingPolicy(ctx context.Context) *selection.RoutingPolicy {
+	policies := es.routingPolicies.Load()
+	if policies == nil {
+		return nil
+	}
+
+	depth, err := es.ClusterActiveRequests(ctx)
+	if err != nil {
+		logger.WithField(requestid.Field, requestid.FromContext(ctx)).Warnf("Failed to read cluster queue depth, using local: %v", err)
+		depth = es.ActiveRequests()
+	}
+
+	return policies.Match(selection.RoutingConditions{
+		Time:       time.Now(),
+		Traffic:    selection.TrafficClassFromContext(ctx),
+		QueueDepth: depth,
+	})
+}
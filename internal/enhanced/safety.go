@@ -0,0 +1,145 @@
+package enhanced
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/internal/components"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/hooks"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/middleware"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/requestid"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/safety"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/selection"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
+)
+
+// SetSafetyFilter sets the filter that classifies responses for unsafe
+// content before they are returned; nil turns classification off
+func (es *EnhancedSystem) SetSafetyFilter(filter *safety.Filter) {
+	es.safetyFilter = filter
+}
+
+// classifyResponse applies the safety filter for the request's tenant,
+// redacting response content and annotating its metadata as the policy
+// says. The assessment is kept with the response for analytics; a blocked
+// response returns an error wrapping safety.ErrBlocked
+func (es *EnhancedSystem) classifyResponse(ctx context.Context, response *ProcessResponse) error {
+	if es.safetyFilter == nil {
+		return nil
+	}
+	content, assessment, err := es.safetyFilter.Apply(ctx, middleware.TenantFromContext(ctx), response.Content)
+	response.safety = assessment
+	if assessment.Action != safety.ActionPass {
+		logger.WithField(requestid.Field, requestid.FromContext(ctx)).Warnf("Safety filter %s response: %s", assessment.Action, assessment.Summary())
+		response.Metadata["safety"] = assessment
+	}
+	if err != nil {
+		return err
+	}
+	response.Content = content
+	return nil
+}
+
+// SetSafetyEscalation sets the policy that retries responses the safety
+// filter blocked on an official provider; nil fails them instead
+func (es *EnhancedSystem) SetSafetyEscalation(policy *safety.EscalationPolicy) {
+	es.safetyEscalation = policy
+}
+
+// SafetyEscalationStats returns how often blocked responses were escalated
+// and how often that rescued the request
+func (es *EnhancedSystem) SafetyEscalationStats() safety.EscalationStats {
+	return es.safetyEscalations.Stats()
+}
+
+// escalateBlocked retries a request whose response from blocked the safety
+// filter withheld on an official provider, with the escalation policy's
+// system prompt before the usual one. It returns blockErr when the policy
+// does not escalate the provider's tier, the request pins a model or does
+// not accept the official tier, or no official provider answers
+func (es *EnhancedSystem) escalateBlocked(ctx context.Context, input RequestInput, hookRequest *hooks.Request, blocked *ProviderAssignment, complexity components.TaskComplexity, requiredCapabilities []string, constraints selection.RequestConstraints, prompt string, blockedResponse *ProcessResponse, blockErr error) (*ProcessResponse, error) {
+	policy := es.safetyEscalation
+	if !policy.Escalates(blocked.Provider.Tier) || constraints.Model != "" ||
+		len(constraints.TierPreference) > 0 && !slices.Contains(constraints.TierPreference, tier.Official) {
+		return nil, blockErr
+	}
+	if err := checkContext(ctx, "escalation"); err != nil {
+		return nil, err
+	}
+
+	log := logger.WithField(requestid.Field, requestid.FromContext(ctx))
+	escalation := safety.Escalation{From: blocked.Provider.Name, FromTier: blocked.Provider.Tier}
+	fail := func(err error) (*ProcessResponse, error) {
+		escalation.Outcome = safety.EscalationFailed
+		es.safetyEscalations.Record(escalation)
+		log.Warnf("Failed to escalate the blocked response of %s: %v", blocked.Provider.Name, err)
+		return nil, blockErr
+	}
+
+	escalated := constraints
+	escalated.TierPreference = []tier.Tier{tier.Official}
+	selectionComplexity := complexity
+	selectionComplexity.TokenEstimate = es.tokenCalibrator.Correct("", "", complexity.TokenEstimate)
+	assignment, _, err := es.selectProvider(ctx, hookRequest, selectionComplexity, requiredCapabilities, escalated)
+	if err != nil {
+		return fail(err)
+	}
+	escalation.To = assignment.Provider.Name
+	if !es.allowProviderRequest(ctx, assignment.Provider) {
+		return fail(fmt.Errorf("%w for %s", ErrRateLimited, assignment.Provider.Name))
+	}
+
+	startTime := time.Now()
+	estimatedTokens := es.tokenCalibrator.Correct(assignment.Provider.Name, assignment.Model, complexity.TokenEstimate)
+	settle := es.consumeThroughput(assignment.Provider, estimatedTokens*int64(input.Candidates()))
+	release := es.selector.AcquireProvider(assignment.Provider.Name)
+	response, err := es.generate(ctx, input, assignment, policy.SystemPrompt, prompt, estimatedTokens)
+	release()
+	if err != nil {
+		settle(0)
+		es.updateProviderHealth(ctx, assignment.Provider.Name, false, time.Since(startTime))
+		es.publishProviderResult(assignment.Provider.Name, false, time.Since(startTime))
+		es.recordAnalytics(ctx, assignment, complexity, startTime, nil, err)
+		return fail(err)
+	}
+	settle(response.TokensUsed)
+	response.Complexity = complexity
+
+	// Request details carry over from the blocked response
+	for key, value := range blockedResponse.Metadata {
+		if _, ok := response.Metadata[key]; !ok && key != "safety" {
+			response.Metadata[key] = value
+		}
+	}
+	es.rewriteArtifacts(ctx, response)
+	es.postProcess(ctx, input, response)
+	safetyErr := es.classifyResponse(ctx, response)
+	if safetyErr == nil {
+		es.finishChoices(ctx, input, response)
+	}
+
+	es.updateProviderHealth(ctx, assignment.Provider.Name, true, time.Since(startTime))
+	es.publishProviderResult(assignment.Provider.Name, true, time.Since(startTime))
+	es.recordAnalytics(ctx, assignment, complexity, startTime, response, nil)
+	es.recordTenantSpend(ctx, startTime, response.Cost)
+	es.recordEnvironmentSpend(ctx, startTime, response.Cost)
+
+	if safetyErr != nil {
+		escalation.Outcome = safety.EscalationBlocked
+		es.safetyEscalations.Record(escalation)
+		log.Warnf("Escalated the blocked response of %s to %s, which was blocked too", blocked.Provider.Name, assignment.Provider.Name)
+		return nil, safetyErr
+	}
+	escalation.Outcome = safety.EscalationRescued
+	es.safetyEscalations.Record(escalation)
+	log.Infof("Escalated the blocked response of %s to %s", blocked.Provider.Name, assignment.Provider.Name)
+
+	// Both calls are paid for
+	response.TokensUsed += blockedResponse.TokensUsed
+	response.Cost += blockedResponse.Cost
+	response.ProcessingTime = blockedResponse.ProcessingTime + time.Since(startTime)
+	response.Metadata["safety_escalation"] = escalation
+	return response, nil
+}
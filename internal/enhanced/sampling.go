@@ -0,0 +1,19 @@
+package enhanced
+
+import (
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/reasoning"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/sampling"
+)
+
+// translateSampling maps the request's sampling parameters to the API of the
+// assigned provider, with the number of completions one call generates.
+// Models that always reason, and budget models sent a thinking budget, take
+// fewer of them
+func (es *EnhancedSystem) translateSampling(input RequestInput, assignment *ProviderAssignment, setting *reasoning.Setting, choices int) sampling.Translation {
+	model, isReasoning := es.reasoningModels.Lookup(assignment.Model)
+	return sampling.Translate(input.Sampling(), sampling.Target{
+		API:       sampling.DetectAPI(assignment.Provider.BaseURL),
+		Reasoning: setting != nil || (isReasoning && model.Parameter != reasoning.ParameterBudget),
+		Choices:   choices,
+	})
+}
@@ -0,0 +1,36 @@
+package enhanced
+
+import (
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/scrub"
+)
+
+// SetScrubber sets the scrubber that masks secrets in prompts, responses and
+// errors before they are persisted or attached to metrics
+func (es *EnhancedSystem) SetScrubber(scrubber *scrub.Scrubber) {
+	es.scrubber = scrubber
+}
+
+// ScrubCounts returns how many secrets each pattern has masked
+func (es *EnhancedSystem) ScrubCounts() map[string]int64 {
+	return es.scrubber.Counts()
+}
+
+// scrubRecord masks secrets in a request about to be stored. The response
+// is copied so the caller's is left intact
+func (es *EnhancedSystem) scrubRecord(record *RequestRecord) {
+	record.Input.Content = es.scrubber.Scrub(record.Input.Content)
+	record.Error = es.scrubber.Scrub(record.Error)
+	if record.Response == nil {
+		return
+	}
+	response := *record.Response
+	response.Content = es.scrubber.Scrub(response.Content)
+	response.Metadata = make(map[string]interface{}, len(record.Response.Metadata))
+	for key, value := range record.Response.Metadata {
+		if text, ok := value.(string); ok {
+			value = es.scrubber.Scrub(text)
+		}
+		response.Metadata[key] = value
+	}
+	record.Response = &response
+}
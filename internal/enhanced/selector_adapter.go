@@ -0,0 +1,123 @@
+package enhanced
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/analysis"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/selection"
+)
+
+// SelectorAdapter serves selection.Selector from a ProviderSelector and the
+// system's health monitor, so callers written against pkg/selection can route
+// over the providers this system loads. Providers are identified by name
+type SelectorAdapter struct {
+	selector      ProviderSelector
+	healthMonitor *ProviderHealthMonitor
+	weights       *selection.SelectionWeights
+	mutex         sync.RWMutex
+}
+
+var _ selection.Selector = (*SelectorAdapter)(nil)
+
+// NewSelectorAdapter creates an adapter over selector that records outcomes
+// in healthMonitor
+func NewSelectorAdapter(selector ProviderSelector, healthMonitor *ProviderHealthMonitor) *SelectorAdapter {
+	return &SelectorAdapter{
+		selector:      selector,
+		healthMonitor: healthMonitor,
+	}
+}
+
+// Selector returns the system's provider selection as a selection.Selector
+func (es *EnhancedSystem) Selector() selection.Selector {
+	return NewSelectorAdapter(es.selector, es.healthMonitor)
+}
+
+// SelectProvider selects a provider for the complexity under the request
+// constraints, using the adapter's weights unless the request sets its own
+func (a *SelectorAdapter) SelectProvider(complexity analysis.TaskComplexity, constraints map[string]interface{}) (selection.ProviderScore, error) {
+	requestConstraints, err := selection.ParseConstraints(constraints)
+	if err != nil {
+		return selection.ProviderScore{}, err
+	}
+	a.mutex.RLock()
+	if requestConstraints.Weights == nil {
+		requestConstraints.Weights = a.weights
+	}
+	a.mutex.RUnlock()
+
+	assignment, err := a.selector.SelectProviderWithConstraints(selection.WithExplain(context.Background()), ComplexityFromAnalysis(complexity), nil, requestConstraints)
+	if err != nil {
+		return selection.ProviderScore{}, err
+	}
+
+	score := selection.ProviderScore{
+		ProviderID:    assignment.Provider.Name,
+		Reasoning:     assignment.Reasoning,
+		EstimatedCost: assignment.EstimatedCost,
+		Model:         assignment.Model,
+	}
+	score.TotalScore, _ = assignment.Metadata["selection_score"].(float64)
+	score.Rejected, _ = assignment.Metadata["rejected_providers"].([]selection.Rejection)
+	score.Decision, _ = assignment.Metadata["decision"].(*selection.Decision)
+	score.ModelScores, _ = assignment.Metadata["model_scores"].([]selection.ModelScore)
+	score.QualityScore = tierWeights[assignment.Provider.Tier]
+	var providerMetrics *selection.ProviderMetrics
+	if metrics := a.healthMonitor.GetMetrics(assignment.Provider.Name); metrics != nil {
+		providerMetrics = toProviderMetrics(metrics)
+	}
+	score.LatencyScore = selection.LatencyScore(providerMetrics)
+	score.ReliabilityScore = selection.ReliabilityScore(providerMetrics)
+	return score, nil
+}
+
+// UpdateProviderMetrics records the outcome of a request in the health
+// monitor. Quality is not tracked there
+func (a *SelectorAdapter) UpdateProviderMetrics(providerID string, latency time.Duration, success bool, quality float64) {
+	a.healthMonitor.UpdateMetrics(providerID, success, latency)
+}
+
+// GetProviderMetrics returns the health monitor's metrics by provider name
+func (a *SelectorAdapter) GetProviderMetrics() map[string]*selection.ProviderMetrics {
+	all := a.healthMonitor.GetAllMetrics()
+	result := make(map[string]*selection.ProviderMetrics, len(all))
+	for name, metrics := range all {
+		result[name] = toProviderMetrics(metrics)
+	}
+	return result
+}
+
+// SetWeights sets the weights used for requests that do not set their own
+func (a *SelectorAdapter) SetWeights(weights selection.SelectionWeights) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.weights = &weights
+}
+
+// ComplexityFromAnalysis maps an analyzed complexity, whose dimensions range
+// from 0 to 3, onto complexity levels
+func ComplexityFromAnalysis(complexity analysis.TaskComplexity) TaskComplexity {
+	level := func(value float64) ComplexityLevel {
+		return ComplexityLevel(math.Max(0, math.Min(math.Round(value), float64(VeryHigh))))
+	}
+	return TaskComplexity{
+		Overall:      level(complexity.Overall),
+		Reasoning:    level(complexity.Reasoning),
+		Mathematical: level(complexity.Computation),
+		Factual:      level(complexity.Knowledge),
+	}
+}
+
+func toProviderMetrics(metrics *ProviderHealthMetrics) *selection.ProviderMetrics {
+	return &selection.ProviderMetrics{
+		AverageLatency:     time.Duration(metrics.AverageLatency * float64(time.Millisecond)),
+		SuccessRate:        metrics.SuccessRate,
+		TotalRequests:      int(metrics.TotalRequests),
+		SuccessfulRequests: int(metrics.SuccessfulRequests),
+		LastUpdated:        metrics.LastUpdated,
+		Windowed:           metrics.Windowed,
+	}
+}
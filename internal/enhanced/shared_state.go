@@ -0,0 +1,98 @@
+package enhanced
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/cluster"
+)
+
+// ErrRateLimited is returned when the cluster-wide rate limit of the selected provider is exhausted
+var ErrRateLimited = errors.New("provider rate limit exceeded")
+
+// sharedStateTimeout bounds calls to the shared store made on the request path
+const sharedStateTimeout = 500 * time.Millisecond
+
+// SetSharedState makes the system publish request and provider state to a store
+// shared with other replicas
+func (es *EnhancedSystem) SetSharedState(state cluster.State) {
+	es.sharedState = state
+}
+
+// ClusterActiveRequests returns in-flight requests across all replicas, or for
+// this process alone when no shared state is configured
+func (es *EnhancedSystem) ClusterActiveRequests(ctx context.Context) (int64, error) {
+	if es.sharedState == nil {
+		return es.ActiveRequests(), nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, sharedStateTimeout)
+	defer cancel()
+	return es.sharedState.ActiveRequests(ctx)
+}
+
+// ClusterProviderStats returns provider counters aggregated across replicas
+func (es *EnhancedSystem) ClusterProviderStats(ctx context.Context) (map[string]cluster.ProviderStats, error) {
+	stats := make(map[string]cluster.ProviderStats)
+	if es.sharedState == nil {
+		return stats, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sharedStateTimeout)
+	defer cancel()
+	for _, provider := range es.providers {
+		providerStats, err := es.sharedState.ProviderStats(ctx, provider.Name)
+		if err != nil {
+			return nil, err
+		}
+		stats[provider.Name] = providerStats
+	}
+	return stats, nil
+}
+
+// publishActiveDelta mirrors a change of the local in-flight count to shared state
+func (es *EnhancedSystem) publishActiveDelta(delta int64) {
+	if es.sharedState == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sharedStateTimeout)
+	defer cancel()
+	if err := es.sharedState.AddActiveRequests(ctx, delta); err != nil {
+		logger.Warnf("Failed to publish active request count: %v", err)
+	}
+}
+
+// publishProviderResult records a provider call outcome in shared state
+func (es *EnhancedSystem) publishProviderResult(provider string, success bool, latency time.Duration) {
+	if es.sharedState == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sharedStateTimeout)
+	defer cancel()
+	if err := es.sharedState.RecordProviderResult(ctx, provider, success, latency); err != nil {
+		logger.Warnf("Failed to publish provider result for %s: %v", provider, err)
+	}
+}
+
+// allowProviderRequest applies the provider's requests_per_minute limit across
+// all replicas. Store failures are logged and the request is allowed
+func (es *EnhancedSystem) allowProviderRequest(ctx context.Context, provider *Provider) bool {
+	if es.sharedState == nil {
+		return true
+	}
+	limit, exists := provider.RateLimits["requests_per_minute"]
+	if !exists || limit <= 0 {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sharedStateTimeout)
+	defer cancel()
+	allowed, err := es.sharedState.AllowRequest(ctx, "provider:"+provider.Name, limit, time.Minute)
+	if err != nil {
+		logger.Warnf("Shared rate limit check failed for %s: %v", provider.Name, err)
+		return true
+	}
+	return allowed
+}
@@ -0,0 +1,229 @@
+package enhanced
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/selection"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/simulation"
+)
+
+// simulatedProvider is what the history says about how a provider served
+type simulatedProvider struct {
+	requests, failed int64
+	latencyMs        float64
+}
+
+// Simulate replays the most recent requests in the history through proposal
+// and reports the cost, latency and tier mix it projects next to what
+// happened. Requests are routed by their stored classification on a
+// separate selector, so live routing is untouched. Providers are scored on
+// the health the history shows for them, and a request moved to another
+// provider is projected at that provider's price per token and average
+// latency, or its tier's expected latency when it served none
+func (es *EnhancedSystem) Simulate(ctx context.Context, proposal simulation.Proposal) (*simulation.Report, error) {
+	policies, err := proposal.Policies()
+	if err != nil {
+		return nil, err
+	}
+	if proposal.RoutingPolicies == nil {
+		policies = es.routingPolicies.Load()
+	}
+	providers, err := es.simulationProviders(proposal)
+	if err != nil {
+		return nil, err
+	}
+	records, err := es.simulationHistory(ctx, proposal)
+	if err != nil {
+		return nil, err
+	}
+
+	history := make(map[string]*simulatedProvider)
+	for _, record := range records {
+		if record.Provider == "" || record.Status == RequestCancelled {
+			continue
+		}
+		stats := history[strings.ToLower(record.Provider)]
+		if stats == nil {
+			stats = &simulatedProvider{}
+			history[strings.ToLower(record.Provider)] = stats
+		}
+		stats.requests++
+		if record.Status == RequestFailed {
+			stats.failed++
+		} else {
+			stats.latencyMs += float64(record.DurationMs)
+		}
+	}
+
+	selector := NewEnhancedProviderSelector(providers)
+	if live := es.builtinSelector(); live != nil {
+		if weights := live.defaultWeights(); weights != nil {
+			selector.SetWeights(*weights)
+		}
+		selector.SetParetoPolicy(live.paretoPolicy)
+	}
+	if proposal.Weights != nil {
+		selector.SetWeights(*proposal.Weights)
+	}
+	selector.SetHealthEstimator(func(provider *Provider) *ProviderHealthMetrics {
+		stats := history[strings.ToLower(provider.Name)]
+		if stats == nil {
+			return nil
+		}
+		return stats.health()
+	})
+
+	report := &simulation.Report{}
+	// The history is newest first; replay it in the order it was served so
+	// round-robin tie breaks spread as they would have
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		if record.Status != RequestSucceeded || record.Response == nil {
+			report.Skipped++
+			continue
+		}
+		assignment, err := es.simulateSelection(ctx, selector, policies, record)
+		if err != nil {
+			report.Unroutable++
+			continue
+		}
+
+		tokens := record.Response.TokensUsed
+		if tokens == 0 {
+			tokens = record.Response.Complexity.TokenEstimate
+		}
+		latencyMs := float64(record.DurationMs)
+		if !strings.EqualFold(assignment.Provider.Name, record.Provider) {
+			report.Changed++
+			if stats := history[strings.ToLower(assignment.Provider.Name)]; stats != nil && stats.requests > stats.failed {
+				latencyMs = stats.latencyMs / float64(stats.requests-stats.failed)
+			} else {
+				latencyMs = selection.WarmupPolicy{}.Prior(assignment.Provider.Tier, nil).LatencyMs
+			}
+		}
+
+		report.Requests++
+		if report.Since.IsZero() {
+			report.Since = record.CreatedAt
+		}
+		report.Until = record.CreatedAt
+		report.Actual.Add(record.Provider, string(es.recordedTier(record)), record.Cost, float64(record.DurationMs))
+		report.Projected.Add(assignment.Provider.Name, string(assignment.Provider.Tier), float64(tokens)*assignment.Provider.CostPerToken, latencyMs)
+	}
+	report.Finish()
+
+	logger.Infof("Simulated %d requests: %d would change provider, %d unroutable, cost change %+.1f%%",
+		report.Requests, report.Changed, report.Unroutable, report.CostChange*100)
+	return report, nil
+}
+
+// simulateSelection routes a stored request the way ProcessRequest would,
+// under the routing policy in effect when it was served. Tenant and
+// environment restrictions, queue depth and warm-up are not replayed
+func (es *EnhancedSystem) simulateSelection(ctx context.Context, selector *EnhancedProviderSelector, policies *selection.RoutingPolicies, record RequestRecord) (*ProviderAssignment, error) {
+	input := record.Input
+	constraints := input.Constraints()
+	virtualModel, isVirtual := es.virtualModels.Load().Lookup(constraints.Model)
+	if isVirtual {
+		constraints = virtualModel.Apply(constraints)
+	}
+	constraints.Model = es.modelAliases.Resolve(constraints.Model).Model
+	routingPolicy := policies.Match(selection.RoutingConditions{
+		Time:    record.CreatedAt,
+		Traffic: selection.TrafficInteractive,
+	})
+	if routingPolicy != nil {
+		constraints = routingPolicy.Apply(constraints)
+	}
+
+	complexity := record.Response.Complexity
+	requiredCapabilities := append([]string(nil), complexity.RequiredCapabilities...)
+	for _, feature := range input.RequiredFeatures {
+		requiredCapabilities = append(requiredCapabilities, strings.ToLower(feature))
+	}
+	if isVirtual {
+		requiredCapabilities = append(requiredCapabilities, virtualModel.RequiredCapabilities...)
+	}
+	if input.Seed != nil {
+		ctx = selection.WithSeed(ctx, *input.Seed)
+	}
+	return selector.SelectProviderWithConstraints(ctx, complexity, requiredCapabilities, constraints)
+}
+
+// simulationProviders returns the providers a proposal routes to. Proposed
+// providers whose models are discovered at runtime take the models of the
+// running provider of the same name
+func (es *EnhancedSystem) simulationProviders(proposal simulation.Proposal) ([]*Provider, error) {
+	configs, err := proposal.Providers()
+	if err != nil || configs == nil {
+		return es.providers, err
+	}
+	providers := make([]*Provider, 0, len(configs))
+	for _, cfg := range configs {
+		provider := NewProvider(cfg)
+		if len(provider.Models) == 0 {
+			if running := es.findProvider(provider.Name); running != nil {
+				provider.Models = append([]string(nil), running.Models...)
+			}
+		}
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
+
+// simulationHistory reads the most recent proposal.Limit requests of the
+// proposal's window, newest first, whoever made them
+func (es *EnhancedSystem) simulationHistory(ctx context.Context, proposal simulation.Proposal) ([]RequestRecord, error) {
+	limit := proposal.Limit
+	if limit <= 0 {
+		limit = simulation.DefaultLimit
+	}
+	query := RequestQuery{Since: proposal.Since, Until: proposal.Until}
+	var records []RequestRecord
+	for len(records) < limit {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		query.Limit = limit - len(records)
+		if query.Limit > MaxRequestPageSize {
+			query.Limit = MaxRequestPageSize
+		}
+		page, err := es.requestHistory.ListRequestRecords(query)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, page.Requests...)
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	return records, nil
+}
+
+// recordedTier returns the tier of the provider that served record
+func (es *EnhancedSystem) recordedTier(record RequestRecord) ProviderTier {
+	if record.Response.Provider != nil && record.Response.Provider.Tier != "" {
+		return record.Response.Provider.Tier
+	}
+	if provider := es.findProvider(record.Provider); provider != nil {
+		return provider.Tier
+	}
+	return ""
+}
+
+// health is the provider health the history shows
+func (s *simulatedProvider) health() *ProviderHealthMetrics {
+	metrics := &ProviderHealthMetrics{
+		TotalRequests:      s.requests,
+		SuccessfulRequests: s.requests - s.failed,
+		FailedRequests:     s.failed,
+		SuccessRate:        float64(s.requests-s.failed) / float64(s.requests),
+		ErrorRate:          float64(s.failed) / float64(s.requests),
+	}
+	if metrics.SuccessfulRequests > 0 {
+		metrics.AverageLatency = s.latencyMs / float64(metrics.SuccessfulRequests)
+	}
+	return metrics
+}
@@ -0,0 +1,149 @@
+package enhanced
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/internal/components"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/flags"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/hooks"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/requestid"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/selection"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/speculative"
+)
+
+// Request strategies
+const (
+	// StrategyDirect sends the request straight to the selected provider
+	StrategyDirect = "direct"
+	// StrategySpeculative drafts with the cheapest capable provider and only
+	// asks the selected provider to verify and repair drafts that fail the
+	// quality check
+	StrategySpeculative = "speculative"
+)
+
+// SpeculativeResult describes a speculative request in the response metadata
+type SpeculativeResult struct {
+	DraftProvider string `json:"draft_provider"`
+	DraftModel    string `json:"draft_model"`
+	// Verified is true when the draft failed the quality check and the
+	// selected provider repaired it
+	Verified   bool    `json:"verified"`
+	Reason     string  `json:"reason,omitempty"`
+	DraftCost  float64 `json:"draft_cost"`
+	VerifyCost float64 `json:"verify_cost"`
+	// Saved is the cost avoided compared with answering directly; negative
+	// when verification made the request dearer
+	Saved float64 `json:"saved"`
+}
+
+// SetSpeculative sets whether requests that name no strategy are
+// speculative without a speculative_routing flag override, and the check
+// drafts must pass; a nil check keeps the current one
+func (es *EnhancedSystem) SetSpeculative(enabled bool, check speculative.Check) {
+	es.speculativeDefault = enabled
+	if check != nil {
+		es.speculativeCheck = check
+	}
+}
+
+// SpeculativeStats returns how often drafts needed verification and what
+// speculative requests saved
+func (es *EnhancedSystem) SpeculativeStats() speculative.Stats {
+	return es.speculativeStats.Stats()
+}
+
+// isSpeculative reports whether input should be drafted first. Requests
+// that name no strategy follow the speculative_routing flag, and requests
+// for several completions are answered directly
+func (es *EnhancedSystem) isSpeculative(input RequestInput, featureFlags flags.Evaluations) bool {
+	if input.Candidates() > 1 {
+		return false
+	}
+	switch input.Strategy {
+	case StrategySpeculative:
+		return true
+	case StrategyDirect:
+		return false
+	}
+	return featureFlags.Enabled(flags.SpeculativeRouting)
+}
+
+// processSpeculative answers with a draft from the cheapest capable provider
+// when it passes the quality check, and otherwise with verifier's repair of
+// the draft. It also returns the assignment of the provider whose answer is
+// returned. Both are nil when no cheaper provider is available to draft, or
+// a hook vetoes the draft provider, and the caller answers directly
+func (es *EnhancedSystem) processSpeculative(ctx context.Context, input RequestInput, hookRequest *hooks.Request, verifier *ProviderAssignment, complexity components.TaskComplexity, requiredCapabilities []string, constraints selection.RequestConstraints, prompt string, verifierTokens int64) (*ProcessResponse, *ProviderAssignment, error) {
+	log := logger.WithField(requestid.Field, requestid.FromContext(ctx))
+	drafter, err := es.selector.SelectDraftProvider(complexity, requiredCapabilities, constraints)
+	if err != nil || drafter.Provider.Name == verifier.Provider.Name || drafter.Provider.CostPerToken >= verifier.Provider.CostPerToken {
+		return nil, nil, nil
+	}
+	if err := es.hooks.Select(ctx, hookRequest, hookSelection(drafter, true)); err != nil {
+		log.Debugf("Draft provider %s vetoed, answering directly: %v", drafter.Provider.Name, err)
+		return nil, nil, nil
+	}
+	if !es.allowProviderRequest(ctx, drafter.Provider) {
+		log.Debugf("Draft provider %s is rate limited, answering directly", drafter.Provider.Name)
+		return nil, nil, nil
+	}
+	// Selection details stay with whichever provider answers
+	drafter.Metadata = verifier.Metadata
+
+	draftStart := time.Now()
+	release := es.selector.AcquireProvider(drafter.Provider.Name)
+	draft, err := es.callProvider(ctx, input, drafter, "", prompt, es.tokenCalibrator.Correct(drafter.Provider.Name, drafter.Model, complexity.TokenEstimate))
+	release()
+	if err != nil {
+		es.updateProviderHealth(ctx, drafter.Provider.Name, false, time.Since(draftStart))
+		es.publishProviderResult(drafter.Provider.Name, false, time.Since(draftStart))
+		log.Debugf("Draft provider %s failed, answering directly: %v", drafter.Provider.Name, err)
+		return nil, nil, nil
+	}
+
+	baselineCost := float64(verifierTokens) * verifier.Provider.CostPerToken
+	result := SpeculativeResult{
+		DraftProvider: drafter.Provider.Name,
+		DraftModel:    drafter.Model,
+		DraftCost:     draft.Cost,
+	}
+	response, answeredBy := draft, drafter
+	if verdict := es.speculativeCheck.Check(prompt, draft.Content); !verdict.Passed {
+		// The draft call is done; the caller records the verifier's
+		es.updateProviderHealth(ctx, drafter.Provider.Name, true, time.Since(draftStart))
+		es.publishProviderResult(drafter.Provider.Name, true, time.Since(draftStart))
+		if err := checkContext(ctx, "verification"); err != nil {
+			return nil, nil, err
+		}
+		// The verifier reads the draft as well as the prompt
+		verifyPrompt := fmt.Sprintf("Verify and repair this draft answer.\n\nTask:\n%s\n\nDraft:\n%s", prompt, draft.Content)
+		verifyStart := time.Now()
+		release := es.selector.AcquireProvider(verifier.Provider.Name)
+		response, err = es.callProvider(ctx, input, verifier, "", verifyPrompt, verifierTokens+draft.TokensUsed)
+		release()
+		if err != nil {
+			es.updateProviderHealth(ctx, verifier.Provider.Name, false, time.Since(verifyStart))
+			es.publishProviderResult(verifier.Provider.Name, false, time.Since(verifyStart))
+			return nil, nil, fmt.Errorf("verifier %s failed: %w", verifier.Provider.Name, err)
+		}
+		answeredBy = verifier
+		result.Verified = true
+		result.Reason = verdict.Reason
+		result.VerifyCost = response.Cost
+		response.TokensUsed += draft.TokensUsed
+		response.Cost += draft.Cost
+		log.Debugf("Draft from %s failed the quality check (%s), verified by %s", drafter.Provider.Name, verdict.Reason, verifier.Provider.Name)
+	}
+	result.Saved = baselineCost - result.DraftCost - result.VerifyCost
+
+	es.speculativeStats.Record(speculative.Outcome{
+		Verified:     result.Verified,
+		DraftCost:    result.DraftCost,
+		VerifyCost:   result.VerifyCost,
+		BaselineCost: baselineCost,
+	})
+	response.Metadata["speculative"] = result
+	return response, answeredBy, nil
+}
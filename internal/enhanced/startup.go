@@ -0,0 +1,437 @@
+package enhanced
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/health"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/selection"
+)
+
+// StartupMode decides what startup validation does with unreachable providers
+type StartupMode string
+
+const (
+	// StartupFailFast fails startup when any check fails
+	StartupFailFast StartupMode = "fail-fast"
+	// StartupDegraded starts with the providers that answered and keeps
+	// rechecking the others; only configuration errors and critical
+	// providers fail startup
+	StartupDegraded StartupMode = "degraded"
+)
+
+// Statuses of startup validation
+const (
+	StartupStatusRunning  = "running"
+	StartupStatusReady    = "ready"
+	StartupStatusDegraded = "degraded"
+	StartupStatusFailed   = "failed"
+)
+
+// DefaultProviderCheckTimeout bounds each provider reachability check
+const DefaultProviderCheckTimeout = 5 * time.Second
+
+// ErrStartupFailed is returned by Startup when validation fails
+var ErrStartupFailed = errors.New("startup validation failed")
+
+// ErrProvidersUnavailable is returned for requests that only unavailable
+// providers could serve
+var ErrProvidersUnavailable = errors.New("no available provider")
+
+// ParseStartupMode parses a STARTUP_MODE value; empty means degraded
+func ParseStartupMode(value string) (StartupMode, error) {
+	switch mode := StartupMode(strings.ToLower(strings.TrimSpace(value))); mode {
+	case "":
+		return StartupDegraded, nil
+	case StartupFailFast, StartupDegraded:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown startup mode %q, want %s or %s", value, StartupFailFast, StartupDegraded)
+}
+
+// StartupOptions configures startup validation
+type StartupOptions struct {
+	Mode StartupMode
+	// CriticalProviders must be reachable in either mode
+	CriticalProviders []string
+	// ProviderTimeout bounds each reachability check; zero uses
+	// DefaultProviderCheckTimeout
+	ProviderTimeout time.Duration
+	// ProbeMaxAge is the age of cached capability probes that are probed
+	// again; zero only probes providers without a successful probe
+	ProbeMaxAge time.Duration
+}
+
+// StartupReport is the outcome of startup validation. UnavailableProviders
+// maps the providers routing skips to why they were unreachable
+type StartupReport struct {
+	Mode                 StartupMode       `json:"mode"`
+	Status               string            `json:"status"`
+	Checks               []health.Check    `json:"checks"`
+	UnavailableProviders map[string]string `json:"unavailable_providers,omitempty"`
+	StartedAt            time.Time         `json:"started_at"`
+	FinishedAt           time.Time         `json:"finished_at,omitempty"`
+}
+
+// startupState tracks startup validation and the providers it took out of
+// routing
+type startupState struct {
+	mutex       sync.RWMutex
+	report      *StartupReport
+	unavailable map[string]string
+	client      *http.Client
+}
+
+// Startup validates the configuration, checks that every provider answers
+// and warms the capability probe cache. Configuration errors and unreachable
+// critical providers fail in either mode; in degraded mode other unreachable
+// providers are left out of routing until RecheckProviders finds them again
+func (es *EnhancedSystem) Startup(ctx context.Context, options StartupOptions) (*StartupReport, error) {
+	if options.Mode == "" {
+		options.Mode = StartupDegraded
+	}
+	if options.ProviderTimeout <= 0 {
+		options.ProviderTimeout = DefaultProviderCheckTimeout
+	}
+	report := &StartupReport{
+		Mode:      options.Mode,
+		Status:    StartupStatusRunning,
+		Checks:    []health.Check{},
+		StartedAt: time.Now(),
+	}
+	es.startup.mutex.Lock()
+	es.startup.report = report
+	es.startup.client = &http.Client{Timeout: options.ProviderTimeout}
+	es.startup.mutex.Unlock()
+
+	// Checks are published with the outcome, so readiness never reads them
+	// half written
+	checks := []health.Check{}
+	var failures []string
+	check := func(name string, err error, detail string) {
+		if err != nil {
+			checks = append(checks, health.Check{Name: name, Status: health.StatusFailed, Detail: err.Error()})
+			failures = append(failures, fmt.Sprintf("%s: %v", name, err))
+			return
+		}
+		checks = append(checks, health.Check{Name: name, Status: health.StatusOK, Detail: detail})
+	}
+
+	check("providers_csv", es.validateProvidersCSV(), es.providersCSVPath)
+	check("provider_config", es.validateProviders(), fmt.Sprintf("%d providers", len(es.providers)))
+
+	unavailable := es.checkProviders(ctx, es.providers)
+	for _, provider := range es.providers {
+		reason, down := unavailable[provider.Name]
+		if !down {
+			check("provider:"+provider.Name, nil, "reachable")
+			continue
+		}
+		if options.Mode == StartupFailFast || containsFold(options.CriticalProviders, provider.Name) {
+			check("provider:"+provider.Name, errors.New(reason), "")
+			continue
+		}
+		checks = append(checks, health.Check{Name: "provider:" + provider.Name, Status: health.StatusWarn, Detail: reason})
+	}
+	for _, name := range options.CriticalProviders {
+		if es.findProvider(name) == nil {
+			check("provider:"+name, errors.New("critical provider is not configured"), "")
+		}
+	}
+
+	if len(failures) == 0 && es.capabilityProbe != nil {
+		checks = append(checks, es.warmCapabilityProbes(ctx, unavailable, options.ProbeMaxAge))
+	}
+
+	es.startup.mutex.Lock()
+	defer es.startup.mutex.Unlock()
+	report.Checks = checks
+	report.FinishedAt = time.Now()
+	switch {
+	case len(failures) > 0:
+		report.Status = StartupStatusFailed
+	case len(unavailable) > 0:
+		report.Status = StartupStatusDegraded
+		es.startup.unavailable = unavailable
+		report.UnavailableProviders = make(map[string]string, len(unavailable))
+		for name, reason := range unavailable {
+			report.UnavailableProviders[name] = reason
+		}
+	default:
+		report.Status = StartupStatusReady
+	}
+	if len(failures) > 0 {
+		return report, fmt.Errorf("%w: %s", ErrStartupFailed, strings.Join(failures, "; "))
+	}
+	if len(unavailable) > 0 {
+		logger.Warnf("Started in degraded mode without %d unreachable providers: %s", len(unavailable), strings.Join(sortedKeys(unavailable), ", "))
+	}
+	return report, nil
+}
+
+// StartupStatus returns the report of startup validation, or nil when it
+// has not run
+func (es *EnhancedSystem) StartupStatus() *StartupReport {
+	es.startup.mutex.RLock()
+	defer es.startup.mutex.RUnlock()
+	if es.startup.report == nil {
+		return nil
+	}
+	report := *es.startup.report
+	report.Checks = append([]health.Check(nil), report.Checks...)
+	if len(es.startup.unavailable) > 0 {
+		report.UnavailableProviders = make(map[string]string, len(es.startup.unavailable))
+		for name, reason := range es.startup.unavailable {
+			report.UnavailableProviders[name] = reason
+		}
+	} else {
+		report.UnavailableProviders = nil
+	}
+	return &report
+}
+
+// RecheckProviders checks the providers startup left out of routing again
+// and puts those that answer back
+func (es *EnhancedSystem) RecheckProviders(ctx context.Context) {
+	es.startup.mutex.RLock()
+	var down []*Provider
+	for name := range es.startup.unavailable {
+		if provider := es.findProvider(name); provider != nil {
+			down = append(down, provider)
+		}
+	}
+	es.startup.mutex.RUnlock()
+	if len(down) == 0 {
+		return
+	}
+
+	stillDown := es.checkProviders(ctx, down)
+	es.startup.mutex.Lock()
+	defer es.startup.mutex.Unlock()
+	for _, provider := range down {
+		if reason, ok := stillDown[provider.Name]; ok {
+			es.startup.unavailable[provider.Name] = reason
+			continue
+		}
+		delete(es.startup.unavailable, provider.Name)
+		logger.Infof("Provider %s is reachable again", provider.Name)
+	}
+	if len(es.startup.unavailable) == 0 && es.startup.report != nil {
+		es.startup.report.Status = StartupStatusReady
+		logger.Info("All providers are reachable; leaving degraded mode")
+	}
+}
+
+// StartProviderRecheck runs RecheckProviders every interval until ctx is
+// cancelled
+func (es *EnhancedSystem) StartProviderRecheck(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				es.RecheckProviders(ctx)
+			}
+		}
+	}()
+}
+
+// providerAvailable reports whether startup left the provider in routing
+func (es *EnhancedSystem) providerAvailable(name string) bool {
+	es.startup.mutex.RLock()
+	defer es.startup.mutex.RUnlock()
+	_, down := es.startup.unavailable[name]
+	return !down
+}
+
+// applyStartup keeps the providers startup found unreachable out of
+// selection
+func (es *EnhancedSystem) applyStartup(constraints selection.RequestConstraints) (selection.RequestConstraints, error) {
+	es.startup.mutex.RLock()
+	defer es.startup.mutex.RUnlock()
+	if len(es.startup.unavailable) == 0 {
+		return constraints, nil
+	}
+
+	allowed := []string{}
+	for _, provider := range es.providers {
+		if _, down := es.startup.unavailable[provider.Name]; down {
+			continue
+		}
+		if len(constraints.AllowedProviders) > 0 && !containsFold(constraints.AllowedProviders, provider.Name) {
+			continue
+		}
+		allowed = append(allowed, provider.Name)
+	}
+	if len(allowed) == 0 {
+		return constraints, fmt.Errorf("%w: every allowed provider was unreachable at startup", ErrProvidersUnavailable)
+	}
+	constraints.AllowedProviders = allowed
+	return constraints, nil
+}
+
+// readinessStartup adds the state of startup validation to a readiness report
+func (es *EnhancedSystem) readinessStartup(report *health.Report) {
+	status := es.StartupStatus()
+	switch {
+	case status == nil:
+		return
+	case status.Status == StartupStatusRunning:
+		report.Fail("startup", "validation is running")
+	case status.Status == StartupStatusFailed:
+		report.Fail("startup", "validation failed")
+	case len(status.UnavailableProviders) > 0:
+		report.Warn("startup", fmt.Sprintf("degraded, unreachable: %s", strings.Join(sortedKeys(status.UnavailableProviders), ", ")))
+	default:
+		report.Pass("startup", "ready")
+	}
+}
+
+// validateProvidersCSV parses the provider CSV when there is one
+func (es *EnhancedSystem) validateProvidersCSV() error {
+	if es.providersCSVPath == "" {
+		return nil
+	}
+	if _, err := os.Stat(es.providersCSVPath); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	_, err := config.ReadProviderCSVFile(es.providersCSVPath)
+	return err
+}
+
+// validateProviders checks that providers have unique names and HTTP endpoints
+func (es *EnhancedSystem) validateProviders() error {
+	if len(es.providers) == 0 {
+		return errors.New("no providers configured")
+	}
+	var problems []string
+	seen := make(map[string]bool, len(es.providers))
+	for _, provider := range es.providers {
+		if provider.Name == "" {
+			problems = append(problems, "a provider has no name")
+			continue
+		}
+		key := strings.ToLower(provider.Name)
+		if seen[key] {
+			problems = append(problems, fmt.Sprintf("%s is configured twice", provider.Name))
+		}
+		seen[key] = true
+		if provider.BaseURL == "" {
+			continue
+		}
+		if u, err := url.Parse(provider.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("%s has an invalid base URL %q", provider.Name, provider.BaseURL))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// checkProviders checks every provider at once and returns why each
+// unreachable one failed. Any answer below 500 counts as reachable, since
+// checks are sent without credentials
+func (es *EnhancedSystem) checkProviders(ctx context.Context, providers []*Provider) map[string]string {
+	es.startup.mutex.RLock()
+	client := es.startup.client
+	es.startup.mutex.RUnlock()
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	unavailable := make(map[string]string)
+	for _, provider := range providers {
+		if provider.BaseURL == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(provider *Provider) {
+			defer wg.Done()
+			if err := checkProvider(ctx, client, provider); err != nil {
+				mutex.Lock()
+				unavailable[provider.Name] = err.Error()
+				mutex.Unlock()
+			}
+		}(provider)
+	}
+	wg.Wait()
+	return unavailable
+}
+
+// checkProvider lists the models of provider to see that it answers
+func checkProvider(ctx context.Context, client *http.Client, provider *Provider) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(provider.BaseURL, "/")+"/models", nil)
+	if err != nil {
+		return err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("answered %s", response.Status)
+	}
+	return nil
+}
+
+// warmCapabilityProbes probes the reachable providers without a cached
+// capability probe younger than maxAge. Probe failures are reported but do
+// not fail startup
+func (es *EnhancedSystem) warmCapabilityProbes(ctx context.Context, unavailable map[string]string, maxAge time.Duration) health.Check {
+	if maxAge <= 0 {
+		maxAge = time.Duration(math.MaxInt64)
+	}
+	warmed, failed := 0, []string{}
+	for _, provider := range es.providers {
+		if _, down := unavailable[provider.Name]; down || ctx.Err() != nil {
+			continue
+		}
+		if !es.capabilityProbe.store.Stale(provider.Name, maxAge) {
+			continue
+		}
+		if _, err := es.probe(ctx, provider); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", provider.Name, err))
+			continue
+		}
+		warmed++
+	}
+	if len(failed) > 0 {
+		return health.Check{Name: "capability_probes", Status: health.StatusWarn, Detail: strings.Join(failed, "; ")}
+	}
+	return health.Check{Name: "capability_probes", Status: health.StatusOK, Detail: fmt.Sprintf("%d providers probed", warmed)}
+}
+
+// findProvider returns the provider with the name, ignoring case
+func (es *EnhancedSystem) findProvider(name string) *Provider {
+	for _, provider := range es.providers {
+		if strings.EqualFold(provider.Name, name) {
+			return provider
+		}
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
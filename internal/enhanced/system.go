@@ -3,64 +3,290 @@ package enhanced
 import (
 	"context"
 	"fmt"
-	"log"
+	"math"
 	"strings"
 	"time"
 
 	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/internal/components"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/analytics"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/audit"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/flags"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/hooks"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/incident"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/injection"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/logging"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/openai"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/reasoning"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/requestid"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/safety"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/scrub"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/selection"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/speculative"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/usage"
 )
 
-// NewEnhancedSystem creates a new enhanced system with default configuration
-func NewEnhancedSystem(providers []*Provider) *EnhancedSystem {
-	return &EnhancedSystem{
-		selector:      NewEnhancedProviderSelector(providers),
-		reasoner:      components.NewTaskReasoner(),
-		optimizer:     components.NewSPOOptimizer(),
-		healthMonitor: NewProviderHealthMonitor(),
-		providers:     providers,
-		metrics:       NewSystemMetrics(),
+// logger is the enhanced module logger, configurable via LOG_MODULES=enhanced=<level>
+var logger = logging.Module("enhanced")
+
+// NewEnhancedSystem creates a new enhanced system with default configuration.
+// Options replace its analyzer, optimizer, selector or executor
+func NewEnhancedSystem(providers []*Provider, options ...Option) *EnhancedSystem {
+	es := &EnhancedSystem{
+		selector:        NewEnhancedProviderSelector(providers),
+		reasoner:        components.NewTaskReasoner(),
+		optimizer:       components.NewSPOOptimizer(),
+		executor:        placeholderExecutor{},
+		healthMonitor:   NewProviderHealthMonitor(),
+		providers:       providers,
+		metrics:         NewSystemMetrics(),
+		modelAliases:    selection.DefaultModelAliases(),
+		tokenCalibrator: usage.NewCalibrator(),
+		requestHistory:  NewMemoryRequestHistory(defaultRequestHistorySize),
+		auditLog:        audit.NewMemoryLog(defaultAuditLogSize),
+		incidents:       incident.NewMemoryLog(),
+		featureFlags:    flags.NewMemoryStore(),
+		reasoningModels: reasoning.DefaultTable(),
+		speculativeCheck: speculative.NewHeuristicCheck(),
+		speculativeStats: speculative.NewRecorder(),
+		scrubber:         scrub.NewDefault(),
+
+		safetyEscalations: safety.NewEscalationRecorder(),
+
+		minHealthyProviders: DefaultMinHealthyProviders,
+	}
+	es.virtualModels.Store(selection.DefaultVirtualModels())
+	for _, option := range options {
+		option(es)
 	}
+	return es
 }
 
-// ProcessRequest processes a request using the enhanced system
+// ProcessRequest processes a request using the enhanced system and keeps
+// its outcome in the request history
 func (es *EnhancedSystem) ProcessRequest(ctx context.Context, input RequestInput) (*ProcessResponse, error) {
+	if !es.beginRequest() {
+		return nil, ErrShuttingDown
+	}
+	defer es.endRequest()
+
 	startTime := time.Now()
+	input, hookRequest, err := es.runRequestHooks(ctx, input)
+	var finding *injection.Finding
+	if err == nil {
+		finding, err = es.checkPromptInjection(ctx, input)
+	}
+	var response *ProcessResponse
+	if err == nil {
+		response, err = es.processRequest(ctx, input, hookRequest, startTime)
+	}
+	if response != nil && finding != nil && finding.Action == injection.ActionWarn {
+		if response.Metadata == nil {
+			response.Metadata = make(map[string]interface{})
+		}
+		response.Metadata["prompt_injection"] = finding
+	}
+	if err != nil {
+		es.hooks.Error(ctx, hookRequest, err)
+	}
+	es.recordRequestHistory(ctx, input, startTime, response, err)
+	es.publishRequestCompleted(ctx, input, startTime, response, err)
+	return response, err
+}
+
+// processRequest analyzes, routes and answers a request
+func (es *EnhancedSystem) processRequest(ctx context.Context, input RequestInput, hookRequest *hooks.Request, startTime time.Time) (*ProcessResponse, error) {
+	if input.Explain {
+		ctx = selection.WithExplain(ctx)
+	}
+	if input.Seed != nil {
+		ctx = selection.WithSeed(ctx, *input.Seed)
+	}
+	ctx = analytics.WithTags(ctx, input.Tags())
+	featureFlags := es.requestFeatureFlags(ctx)
 
 	// Analyze task complexity
 	complexity, err := es.reasoner.AnalyzeComplexity(input.Content)
 	if err != nil {
 		return nil, fmt.Errorf("failed to analyze complexity: %w", err)
 	}
+	if err := checkContext(ctx, "analysis"); err != nil {
+		return nil, err
+	}
 
 	// Optimize prompt
-	optimizedPrompt, err := es.optimizer.OptimizePrompt(input.Content, *complexity)
-	if err != nil {
-		log.Printf("Failed to optimize prompt: %v", err)
-		// Continue with original prompt
-		optimizedPrompt = input.Content
+	optimizedPrompt := input.Content
+	if featureFlags.Enabled(flags.PromptOptimization) {
+		if optimizedPrompt, err = es.optimizer.OptimizePrompt(input.Content, *complexity); err != nil {
+			logger.WithField(requestid.Field, requestid.FromContext(ctx)).Warnf("Failed to optimize prompt: %v", err)
+			// Continue with original prompt
+			optimizedPrompt = input.Content
+		}
+	}
+	if err := checkContext(ctx, "optimization"); err != nil {
+		return nil, err
 	}
 
-	// Select provider
-	assignment, err := es.selector.SelectProviderWithCapabilities(ctx, *complexity, complexity.RequiredCapabilities)
+	// Select provider, adjusted by the routing policy for the time and load
+	constraints := input.Constraints()
+	virtualModel, isVirtual := es.virtualModels.Load().Lookup(constraints.Model)
+	if isVirtual {
+		constraints = virtualModel.Apply(constraints)
+	}
+	modelResolution := es.modelAliases.Resolve(constraints.Model)
+	constraints.Model = modelResolution.Model
+	routingPolicy := es.matchRoutingPolicy(ctx)
+	if routingPolicy != nil {
+		constraints = routingPolicy.Apply(constraints)
+	}
+	// The tenant's inventory and budget apply after the policy, which cannot
+	// widen them, and the key environment's profile narrows them further
+	if constraints, err = es.applyTenant(ctx, constraints); err != nil {
+		return nil, err
+	}
+	if constraints, err = es.applyEnvironment(ctx, constraints); err != nil {
+		return nil, err
+	}
+	// Providers startup found unreachable stay out until they answer again
+	if constraints, err = es.applyStartup(constraints); err != nil {
+		return nil, err
+	}
+	// Select on the estimate corrected by usage seen so far; the response keeps
+	// the reasoner's own estimate in Complexity for later reconciliation
+	selectionComplexity := *complexity
+	selectionComplexity.TokenEstimate = es.tokenCalibrator.Correct("", "", complexity.TokenEstimate)
+	// Requests with a reasoning effort need a model that reasons, and what it
+	// is expected to think counts towards the estimate
+	effort := input.Effort()
+	if effort != "" {
+		selectionComplexity.TokenEstimate += reasoning.DefaultBudgets[effort]
+	}
+	// Features the request relies on narrow the providers like inferred capabilities
+	requiredCapabilities := append([]string(nil), complexity.RequiredCapabilities...)
+	for _, feature := range input.RequiredFeatures {
+		requiredCapabilities = append(requiredCapabilities, strings.ToLower(feature))
+	}
+	if isVirtual {
+		requiredCapabilities = append(requiredCapabilities, virtualModel.RequiredCapabilities...)
+	}
+	if effort != "" {
+		requiredCapabilities = append(requiredCapabilities, reasoning.Capability)
+	}
+	// A request for several completions may spend its cost limit on all of
+	// them, which selection estimates one of
+	candidates := input.Candidates()
+	constraints.CostLimit /= float64(candidates)
+	// Accounts without tokens per minute left for the request stay out, and
+	// batch traffic leaves the interactive reserve alone
+	if constraints, err = es.applyThroughput(ctx, constraints, selectionComplexity.TokenEstimate); err != nil {
+		return nil, err
+	}
+	// Hooks may veto the choice, and vetoed providers stay out of the draft
+	// selection of speculative requests as well. Warming providers over their
+	// traffic share stay out unless nothing else can serve the request
+	warmupConstraints, warmupLimited := es.applyWarmup(ctx, constraints)
+	assignment, warmupConstraints, err := es.selectProvider(ctx, hookRequest, selectionComplexity, requiredCapabilities, warmupConstraints)
+	if err != nil && warmupLimited && checkContext(ctx, "selection") == nil {
+		assignment, warmupConstraints, err = es.selectProvider(ctx, hookRequest, selectionComplexity, requiredCapabilities, constraints)
+	}
+	constraints = warmupConstraints
+	if err := checkContext(ctx, "selection"); err != nil {
+		return nil, err
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to select provider: %w", err)
 	}
+	// Selections below the minimum confidence escalate or are refused
+	if assignment, constraints, err = es.applyConfidencePolicy(ctx, hookRequest, assignment, selectionComplexity, requiredCapabilities, constraints); err != nil {
+		return nil, err
+	}
+	es.recordWarmup(assignment)
+	if !es.allowProviderRequest(ctx, assignment.Provider) {
+		err := fmt.Errorf("%w for %s", ErrRateLimited, assignment.Provider.Name)
+		es.recordAnalytics(ctx, assignment, *complexity, startTime, nil, err)
+		return nil, err
+	}
 
 	// Update metrics
 	es.metrics.IncrementTotalRequests()
 	es.metrics.RecordComplexity(complexity.Overall)
 	es.metrics.RecordProviderUsage(assignment.Provider.Name)
 
-	// Process with selected provider (placeholder)
-	response := &ProcessResponse{
-		Content:        fmt.Sprintf("Processed by %s using model %s: %s", assignment.Provider.Name, assignment.Model, optimizedPrompt),
-		Provider:       assignment.Provider,
-		Model:          assignment.Model,
-		Complexity:     *complexity,
-		ProcessingTime: time.Since(startTime),
-		TokensUsed:     complexity.TokenEstimate,
-		Cost:           assignment.EstimatedCost,
-		Metadata:       make(map[string]interface{}),
+	// Refine the estimate for the chosen provider and model
+	estimatedTokens := es.tokenCalibrator.Correct(assignment.Provider.Name, assignment.Model, complexity.TokenEstimate)
+
+	// Cancelled requests, such as asynchronous ones deleted by the client, and
+	// requests out of time stop before the provider call. The call itself gets
+	// ctx, so the client's deadline bounds it as well
+	if err := checkContext(ctx, "provider"); err != nil {
+		return nil, err
+	}
+	// The request counts against its account's tokens per minute, by its
+	// estimate and the reasoning expected of the model for every candidate
+	// until the provider reports what it used
+	settle := es.consumeThroughput(assignment.Provider, (estimatedTokens+es.reasoningTokens(input, assignment.Model))*int64(candidates))
+
+	// Process with the selected provider, letting a cheaper one draft first
+	// when the request is speculative and pins no model. The provider that
+	// answers is the one recorded below
+	var response *ProcessResponse
+	if es.isSpeculative(input, featureFlags) && constraints.Model == "" {
+		var answeredBy *ProviderAssignment
+		if response, answeredBy, err = es.processSpeculative(ctx, input, hookRequest, assignment, selectionComplexity, requiredCapabilities, constraints, optimizedPrompt, estimatedTokens); err != nil {
+			settle(0)
+			return nil, err
+		}
+		if answeredBy != nil {
+			if answeredBy.Provider != assignment.Provider {
+				settle(0)
+				settle = es.consumeThroughput(answeredBy.Provider, estimatedTokens+es.reasoningTokens(input, answeredBy.Model))
+			}
+			assignment = answeredBy
+		}
+	}
+	if response == nil {
+		release := es.selector.AcquireProvider(assignment.Provider.Name)
+		response, err = es.generate(ctx, input, assignment, "", optimizedPrompt, estimatedTokens)
+		release()
+		if err != nil {
+			settle(0)
+			es.updateProviderHealth(ctx, assignment.Provider.Name, false, time.Since(startTime))
+			es.publishProviderResult(assignment.Provider.Name, false, time.Since(startTime))
+			es.recordAnalytics(ctx, assignment, *complexity, startTime, nil, err)
+			return nil, fmt.Errorf("provider %s failed: %w", assignment.Provider.Name, err)
+		}
+	}
+	settle(response.TokensUsed)
+	response.Complexity = *complexity
+	response.ProcessingTime = time.Since(startTime)
+
+	// Selection details such as constraint rejections and the Pareto decision
+	for key, value := range assignment.Metadata {
+		response.Metadata[key] = value
+	}
+	if input.Explain {
+		response.Metadata["selection_reasoning"] = assignment.Reasoning
+	}
+
+	if id := requestid.FromContext(ctx); id != "" {
+		response.Metadata["request_id"] = id
+	}
+
+	if routingPolicy != nil {
+		response.Metadata["routing_policy"] = routingPolicy.Name
+	}
+
+	response.Metadata["feature_flags"] = featureFlags
+
+	if isVirtual {
+		response.Metadata["virtual_model"] = virtualModel.ID()
+	}
+
+	if modelResolution.Aliased {
+		response.Metadata["model_resolution"] = modelResolution
+		if modelResolution.Warning != "" {
+			response.Metadata["deprecation_warning"] = modelResolution.Warning
+			logger.WithField(requestid.Field, requestid.FromContext(ctx)).Warn(modelResolution.Warning)
+		}
 	}
 
 	if optimizedPrompt != input.Content {
@@ -68,15 +294,150 @@ func (es *EnhancedSystem) ProcessRequest(ctx context.Context, input RequestInput
 		response.Metadata["original_prompt"] = input.Content
 	}
 
+	es.rewriteArtifacts(ctx, response)
+	es.postProcess(ctx, input, response)
+	safetyErr := es.classifyResponse(ctx, response)
+	if safetyErr == nil {
+		es.finishChoices(ctx, input, response)
+	}
+
 	// Update provider health metrics
-	es.healthMonitor.UpdateMetrics(assignment.Provider.Name, true, time.Since(startTime))
+	es.updateProviderHealth(ctx, assignment.Provider.Name, true, time.Since(startTime))
+	es.publishProviderResult(assignment.Provider.Name, true, time.Since(startTime))
+	es.recordAnalytics(ctx, assignment, *complexity, startTime, response, nil)
+	es.recordTenantSpend(ctx, startTime, response.Cost)
+	es.recordEnvironmentSpend(ctx, startTime, response.Cost)
+
+	// The answer is paid for by now, but the safety filter and hooks may
+	// still withhold it. A blocked answer of a lower tier may be retried on
+	// an official provider instead, unless the request's flags turn that off
+	if safetyErr != nil {
+		if !featureFlags.Enabled(flags.SafetyEscalation) {
+			return nil, safetyErr
+		}
+		if response, err = es.escalateBlocked(ctx, input, hookRequest, assignment, *complexity, requiredCapabilities, constraints, optimizedPrompt, response, safetyErr); err != nil {
+			return nil, err
+		}
+	}
+	if err := es.runResponseHooks(ctx, hookRequest, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// callProvider sends prompt to the assigned provider and model through the
+// executor, with the system prompt the policy mandates for it after guard,
+// if any, and the request's reasoning effort, sampling parameters and
+// completion count as the model takes them. The answers are cut at the
+// request's stop sequences
+func (es *EnhancedSystem) callProvider(ctx context.Context, input RequestInput, assignment *ProviderAssignment, guard, prompt string, tokens int64) (*ProcessResponse, error) {
+	metadata := make(map[string]interface{})
+	systemPrompt := es.systemPrompt(ctx, input, assignment.Provider.Name, metadata)
+	if guard != "" {
+		if systemPrompt != "" {
+			guard += "\n\n"
+		}
+		systemPrompt = guard + systemPrompt
+	}
+	// About four bytes per token, as in selection estimates
+	tokens += int64(math.Ceil(float64(len(systemPrompt)) / 4))
+	setting := es.reasoningModels.Setting(assignment.Model, input.Effort())
+	if setting != nil {
+		metadata["reasoning"] = setting
+		tokens += setting.BudgetTokens
+	}
+	choices := callChoices(input, assignment)
+	tokens *= int64(choices)
+	translation := es.translateSampling(input, assignment, setting, choices)
+	if !translation.IsZero() {
+		metadata["sampling"] = translation
+	}
 
+	response, err := es.executor.Execute(ctx, ProviderCall{
+		Input:        input,
+		Assignment:   assignment,
+		SystemPrompt: systemPrompt,
+		Prompt:       prompt,
+		Tokens:       tokens,
+		Reasoning:    setting,
+		Sampling:     translation,
+		Choices:      choices,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if content, cut := openai.CutAtStop(response.Content, input.Stop); cut {
+		response.Content = content
+	}
+	for i := range response.Choices {
+		response.Choices[i].Content, _ = openai.CutAtStop(response.Choices[i].Content, input.Stop)
+	}
+	if response.Metadata == nil {
+		response.Metadata = make(map[string]interface{})
+	}
+	for key, value := range metadata {
+		response.Metadata[key] = value
+	}
 	return response, nil
 }
 
-// GetRequest processes a single request (alias for ProcessRequest)
-func (es *EnhancedSystem) GetRequest(ctx context.Context, input RequestInput) (*ProcessResponse, error) {
-	return es.ProcessRequest(ctx, input)
+// SetParetoPolicy sets the default Pareto policy used to trade off cost,
+// quality and latency when selecting providers
+func (es *EnhancedSystem) SetParetoPolicy(policy selection.ParetoPolicy) {
+	if selector := es.builtinSelector(); selector != nil {
+		selector.SetParetoPolicy(policy)
+	}
+}
+
+// SetTieBreaker sets how traffic spreads across providers with equal scores
+func (es *EnhancedSystem) SetTieBreaker(tieBreaker *selection.TieBreaker) {
+	if selector := es.builtinSelector(); selector != nil {
+		selector.SetTieBreaker(tieBreaker)
+	}
+}
+
+// SetLoadPenalty sets how strongly requests in flight on a provider lower its
+// selection score
+func (es *EnhancedSystem) SetLoadPenalty(capacity int, maxPenalty float64) {
+	if selector := es.builtinSelector(); selector != nil {
+		selector.SetLoadPenalty(capacity, maxPenalty)
+	}
+}
+
+// SetSelectionWeights sets the weights of provider scores for requests that
+// do not set their own
+func (es *EnhancedSystem) SetSelectionWeights(weights selection.SelectionWeights) {
+	if selector := es.builtinSelector(); selector != nil {
+		selector.SetWeights(weights)
+	}
+}
+
+// SetScorers sets the scorers that adjust provider scores after the built-in
+// scoring, such as WASM scoring modules
+func (es *EnhancedSystem) SetScorers(scorers []selection.Scorer) {
+	if selector := es.builtinSelector(); selector != nil {
+		selector.SetScorers(scorers)
+	}
+}
+
+// ProviderLoad returns the requests in flight on each provider that has any
+func (es *EnhancedSystem) ProviderLoad() map[string]int {
+	return es.selector.InFlight()
+}
+
+// SetModelAliases replaces the table used to resolve requested model names
+func (es *EnhancedSystem) SetModelAliases(aliases *selection.ModelAliases) {
+	es.modelAliases = aliases
+}
+
+// SetVirtualModels replaces the table of virtual models clients may request
+func (es *EnhancedSystem) SetVirtualModels(models *selection.VirtualModels) {
+	es.virtualModels.Store(models)
+}
+
+// VirtualModels returns the virtual models clients may request
+func (es *EnhancedSystem) VirtualModels() []selection.VirtualModel {
+	return es.virtualModels.Load().Models()
 }
 
 // GetProviders returns all available providers
@@ -153,9 +514,15 @@ func formatCapabilitiesYAML(capabilities []string) string {
 	return strings.TrimSuffix(result.String(), "\n")
 }
 
-// GetSystemMetrics returns system metrics
+// GetSystemMetrics returns a snapshot of the system metrics
 func (es *EnhancedSystem) GetSystemMetrics() *SystemMetrics {
-	return es.metrics
+	return es.metrics.Snapshot()
+}
+
+// SetMetricsHalfLife sets how long it takes a request's weight in the
+// windowed provider metrics to halve; zero keeps lifetime metrics only
+func (es *EnhancedSystem) SetMetricsHalfLife(halfLife time.Duration) {
+	es.healthMonitor.SetHalfLife(halfLife)
 }
 
 // GetProviderMetrics returns metrics for all providers
@@ -170,14 +537,15 @@ func (es *EnhancedSystem) GetProviderMetricsByName(providerName string) *Provide
 
 // UpdateProviderHealth updates health metrics for a provider
 func (es *EnhancedSystem) UpdateProviderHealth(providerName string, success bool, latency time.Duration) {
-	es.healthMonitor.UpdateMetrics(providerName, success, latency)
+	es.updateProviderHealth(context.Background(), providerName, success, latency)
 }
 
-// GetHealthyProviders returns a list of healthy providers
+// GetHealthyProviders returns the providers that are healthy and that
+// startup did not leave out of routing
 func (es *EnhancedSystem) GetHealthyProviders() []*Provider {
 	var healthyProviders []*Provider
 	for _, provider := range es.providers {
-		if es.healthMonitor.IsHealthy(provider.Name) {
+		if es.healthMonitor.IsHealthy(provider.Name) && es.providerAvailable(provider.Name) {
 			healthyProviders = append(healthyProviders, provider)
 		}
 	}
@@ -213,9 +581,10 @@ func (es *EnhancedSystem) AnalyzeComplexityOnly(ctx context.Context, input Reque
 	return es.reasoner.AnalyzeComplexity(input.Content)
 }
 
-// SelectProviderOnly selects a provider without full processing
+// SelectProviderOnly selects a provider without full processing, explaining
+// the choice
 func (es *EnhancedSystem) SelectProviderOnly(ctx context.Context, complexity components.TaskComplexity, requiredCapabilities []string) (*ProviderAssignment, error) {
-	return es.selector.SelectProviderWithCapabilities(ctx, complexity, requiredCapabilities)
+	return es.selector.SelectProviderWithConstraints(selection.WithExplain(ctx), complexity, requiredCapabilities, selection.RequestConstraints{})
 }
 
 // GetProviderStats returns statistics about providers
@@ -227,10 +596,3 @@ func (es *EnhancedSystem) GetProviderStats() map[string]interface{} {
 func (es *EnhancedSystem) ResetProviderMetrics(providerName string) {
 	es.healthMonitor.ResetMetrics(providerName)
 }
-
-// Shutdown gracefully shuts down the enhanced system
-func (es *EnhancedSystem) Shutdown(ctx context.Context) error {
-	log.Println("Shutting down enhanced system...")
-	// Add any cleanup logic here
-	return nil
-}
\ No newline at end of file
@@ -0,0 +1,29 @@
+package enhanced
+
+import (
+	"context"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/middleware"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/promptpolicy"
+)
+
+// SetSystemPromptPolicy sets the rules that attach mandatory system prompt
+// prefixes and suffixes to requests
+func (es *EnhancedSystem) SetSystemPromptPolicy(policy *promptpolicy.Policy) {
+	es.systemPromptPolicy = policy
+}
+
+// systemPrompt returns the system prompt the policy mandates for a request
+// dispatched to provider, recording the rules applied in metadata
+func (es *EnhancedSystem) systemPrompt(ctx context.Context, input RequestInput, provider string, metadata map[string]interface{}) string {
+	resolution := es.systemPromptPolicy.Resolve(promptpolicy.Target{
+		KeyID:    middleware.KeyIDFromContext(ctx),
+		Mode:     input.Mode,
+		Provider: provider,
+	})
+	if resolution.IsZero() {
+		return ""
+	}
+	metadata["system_prompt_policy"] = resolution
+	return resolution.Apply("")
+}
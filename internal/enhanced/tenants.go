@@ -0,0 +1,82 @@
+package enhanced
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/middleware"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/selection"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tenant"
+)
+
+// SetTenants isolates the tenants in registry: a tenant's requests only use
+// its providers and are refused once its monthly budget is spent. Call it
+// after SetAnalytics so this month's spend is restored from analytics
+func (es *EnhancedSystem) SetTenants(registry *tenant.Registry) {
+	es.tenants = registry
+	if registry == nil || es.analytics == nil {
+		return
+	}
+
+	now := time.Now().UTC()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for _, record := range es.analytics.Records(monthStart, time.Time{}) {
+		if record.Tenant != "" && record.Success {
+			registry.RecordSpend(record.Tenant, record.Timestamp, record.Cost)
+		}
+	}
+}
+
+// ProvidersForTenant returns the providers available to a tenant, every
+// provider for "" or a tenant without an inventory
+func (es *EnhancedSystem) ProvidersForTenant(id string) []*Provider {
+	if es.tenants == nil || id == "" {
+		return es.providers
+	}
+	current, err := es.tenants.Get(id)
+	if err != nil || len(current.Providers) == 0 {
+		return es.providers
+	}
+
+	providers := []*Provider{}
+	for _, provider := range es.providers {
+		for _, name := range current.Providers {
+			if strings.EqualFold(name, provider.Name) {
+				providers = append(providers, provider)
+				break
+			}
+		}
+	}
+	return providers
+}
+
+// applyTenant limits constraints to the providers of the request's tenant,
+// failing with tenant.ErrBudgetExceeded once its budget is spent
+func (es *EnhancedSystem) applyTenant(ctx context.Context, constraints selection.RequestConstraints) (selection.RequestConstraints, error) {
+	id := middleware.TenantFromContext(ctx)
+	if es.tenants == nil || id == "" {
+		return constraints, nil
+	}
+	current, err := es.tenants.Get(id)
+	if err != nil {
+		return constraints, err
+	}
+	if err := es.tenants.CheckBudget(id); err != nil {
+		return constraints, err
+	}
+	constraints.AllowedProviders = current.Providers
+	return constraints, nil
+}
+
+// recordTenantSpend adds the cost of a request to its tenant's monthly
+// spend, publishing an event when it uses up the budget
+func (es *EnhancedSystem) recordTenantSpend(ctx context.Context, startTime time.Time, cost float64) {
+	if id := middleware.TenantFromContext(ctx); es.tenants != nil && id != "" {
+		if es.tenants.RecordSpend(id, startTime, cost) {
+			if usage, err := es.tenants.Usage(id); err == nil {
+				es.publishBudgetExceeded(ctx, "tenant", id, usage.Month, usage.Spend, usage.MonthlyBudget)
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package enhanced
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/selection"
+)
+
+// throughput is the policy sharing upstream accounts' tokens per minute and
+// what each account consumed
+type throughput struct {
+	policy  selection.ThroughputPolicy
+	tracker *selection.ThroughputTracker
+}
+
+// SetThroughputPolicy sets how the tokens per minute of upstream accounts
+// are shared between interactive and batch traffic. Providers with a
+// tokens_per_minute limit, set directly or through their usage tier, are
+// only selected while their account has room for the request, and score
+// lower as it fills up
+func (es *EnhancedSystem) SetThroughputPolicy(policy selection.ThroughputPolicy) {
+	es.throughput = &throughput{policy: policy, tracker: selection.NewThroughputTracker()}
+	if selector := es.builtinSelector(); selector != nil {
+		selector.SetThroughputPenalty(es.throughputPenalty)
+	}
+}
+
+// providerAccount returns the upstream account a provider draws on, the
+// provider itself when it shares none
+func providerAccount(provider *Provider) string {
+	if account, ok := provider.Metadata["account"].(string); ok && account != "" {
+		return account
+	}
+	return provider.Name
+}
+
+// accountLimit returns the tokens per minute of an account, the lowest any
+// of its providers declares, or 0 when none does
+func (es *EnhancedSystem) accountLimit(account string) int64 {
+	var limit int64
+	for _, provider := range es.providers {
+		if providerAccount(provider) != account {
+			continue
+		}
+		if tpm := provider.RateLimits["tokens_per_minute"]; tpm > 0 && (limit == 0 || tpm < limit) {
+			limit = tpm
+		}
+	}
+	return limit
+}
+
+// Throughput returns the consumption of every account with a tokens per
+// minute limit, or nil when throughput is not modeled
+func (es *EnhancedSystem) Throughput() []selection.Headroom {
+	if es.throughput == nil {
+		return nil
+	}
+	providers := make(map[string][]string)
+	for _, provider := range es.providers {
+		account := providerAccount(provider)
+		providers[account] = append(providers[account], provider.Name)
+	}
+
+	now := time.Now()
+	headrooms := make([]selection.Headroom, 0, len(providers))
+	for account, names := range providers {
+		limit := es.accountLimit(account)
+		if limit <= 0 {
+			continue
+		}
+		used := es.throughput.tracker.Used(account, now)
+		headrooms = append(headrooms, es.throughput.policy.NewHeadroom(account, names, limit, used))
+	}
+	sort.Slice(headrooms, func(i, j int) bool { return headrooms[i].Account < headrooms[j].Account })
+	return headrooms
+}
+
+// applyThroughput keeps providers whose account lacks room for a request of
+// tokens out of selection. Batch requests leave the interactive reserve
+// alone. When no provider has room the request is rate limited
+func (es *EnhancedSystem) applyThroughput(ctx context.Context, constraints selection.RequestConstraints, tokens int64) (selection.RequestConstraints, error) {
+	if es.throughput == nil {
+		return constraints, nil
+	}
+
+	class := selection.TrafficClassFromContext(ctx)
+	now := time.Now()
+	var allowed, full []string
+	for _, provider := range es.providers {
+		if len(constraints.AllowedProviders) > 0 && !containsFold(constraints.AllowedProviders, provider.Name) {
+			continue
+		}
+		account := providerAccount(provider)
+		limit := es.accountLimit(account)
+		if es.throughput.policy.Allows(class, limit, es.throughput.tracker.Used(account, now), tokens) {
+			allowed = append(allowed, provider.Name)
+		} else {
+			full = append(full, provider.Name)
+		}
+	}
+
+	if len(full) == 0 {
+		return constraints, nil
+	}
+	if len(allowed) == 0 {
+		return constraints, fmt.Errorf("%w: no %s tokens per minute left for %d tokens on %v", ErrRateLimited, class, tokens, full)
+	}
+	constraints.AllowedProviders = allowed
+	return constraints, nil
+}
+
+// throughputPenalty is the selector's throughput penalty: the score provider
+// loses for how much of the tokens per minute its account granted the
+// request's traffic class is used
+func (es *EnhancedSystem) throughputPenalty(ctx context.Context, provider *Provider) (penalty float64, used, ceiling int64) {
+	account := providerAccount(provider)
+	limit := es.accountLimit(account)
+	if limit <= 0 {
+		return 0, 0, 0
+	}
+	ceiling = es.throughput.policy.Ceiling(selection.TrafficClassFromContext(ctx), limit)
+	used = es.throughput.tracker.Used(account, time.Now())
+	return es.throughput.policy.Penalty(used, ceiling), used, ceiling
+}
+
+// consumeThroughput counts the estimated tokens of a request on its way to
+// provider against its account. The returned settle records what the
+// request actually consumed
+func (es *EnhancedSystem) consumeThroughput(provider *Provider, tokens int64) (settle func(actual int64)) {
+	if es.throughput == nil {
+		return func(int64) {}
+	}
+	return es.throughput.tracker.Consume(providerAccount(provider), time.Now(), tokens)
+}
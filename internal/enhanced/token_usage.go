@@ -0,0 +1,69 @@
+package enhanced
+
+import (
+	"context"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/requestid"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/usage"
+)
+
+// How much stored token usage seeds the calibrator when storage is attached
+const (
+	tokenUsageSeedWindow = 7 * 24 * time.Hour
+	tokenUsageSeedLimit  = 5000
+)
+
+// ReconcileUsage corrects a response with the usage block of the provider's
+// raw response body. The actual tokens replace the estimate in TokensUsed and
+// Cost, and the difference is fed back into future estimates. It returns false
+// when the body reports no usage, leaving the response untouched
+func (es *EnhancedSystem) ReconcileUsage(ctx context.Context, response *ProcessResponse, body []byte) (usage.Reconciliation, bool) {
+	actual, ok := usage.Parse(body)
+	if !ok || response == nil || response.Provider == nil {
+		return usage.Reconciliation{}, false
+	}
+	return es.reconcile(ctx, response, actual), true
+}
+
+// reconcile corrects a response with the usage its provider reported
+func (es *EnhancedSystem) reconcile(ctx context.Context, response *ProcessResponse, actual usage.Usage) usage.Reconciliation {
+	// Learn from the reasoner's raw estimate, not the already corrected one
+	reconciliation := es.tokenCalibrator.Record(response.Provider.Name, response.Model, response.Complexity.TokenEstimate, actual)
+
+	// Reasoning tokens are billed as output, so the total already holds them
+	response.TokensUsed = actual.TotalTokens
+	response.ReasoningTokens = actual.ReasoningTokens
+	response.Cost = float64(actual.TotalTokens) * response.Provider.CostPerToken
+	if response.Metadata == nil {
+		response.Metadata = make(map[string]interface{})
+	}
+	response.Metadata["token_usage"] = reconciliation
+
+	if es.metricsStorage != nil {
+		if err := es.metricsStorage.RecordTokenUsage(reconciliation); err != nil {
+			logger.WithField(requestid.Field, requestid.FromContext(ctx)).Warnf("Failed to store token usage: %v", err)
+		}
+	}
+	return reconciliation
+}
+
+// TokenCalibrations returns the learned ratios of actual to estimated tokens
+func (es *EnhancedSystem) TokenCalibrations() []usage.Calibration {
+	return es.tokenCalibrator.Calibrations()
+}
+
+// seedTokenCalibrator replays recently stored usage so corrections survive restarts
+func (es *EnhancedSystem) seedTokenCalibrator(storage *MetricsStorage) {
+	reconciliations, err := storage.GetTokenUsage(tokenUsageSeedWindow, tokenUsageSeedLimit)
+	if err != nil {
+		logger.Warnf("Failed to load stored token usage: %v", err)
+		return
+	}
+	for _, r := range reconciliations {
+		es.tokenCalibrator.Record(r.Provider, r.Model, r.Estimated, r.Actual)
+	}
+	if len(reconciliations) > 0 {
+		logger.Infof("Calibrated token estimates from %d stored requests", len(reconciliations))
+	}
+}
@@ -1,30 +1,79 @@
 package enhanced
 
 import (
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/internal/components"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/analytics"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/artifact"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/audit"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/cluster"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/environment"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/events"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/flags"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/hooks"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/incident"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/injection"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/postprocess"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/promptpolicy"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/reasoning"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/retention"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/safety"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/scrub"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/selection"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/speculative"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tenant"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/usage"
+)
+
+// TaskComplexity and its levels are defined with the task reasoner
+type (
+	TaskComplexity  = components.TaskComplexity
+	ComplexityLevel = components.ComplexityLevel
+)
+
+const (
+	Low      = components.Low
+	Medium   = components.Medium
+	High     = components.High
+	VeryHigh = components.VeryHigh
 )
 
 // ProviderTier represents the tier/quality level of a provider
-type ProviderTier string
+type ProviderTier = tier.Tier
+
+const (
+	OfficialTier   = tier.Official
+	CommunityTier  = tier.Community
+	UnofficialTier = tier.Unofficial
+)
 
+// Deprecated: the free/basic/premium/enterprise tiers are mapped onto the
+// official/community/unofficial tiers; use those instead
 const (
-	TierFree     ProviderTier = "free"
-	TierBasic    ProviderTier = "basic"
-	TierPremium  ProviderTier = "premium"
-	TierEnterprise ProviderTier = "enterprise"
+	TierFree       = tier.Unofficial
+	TierBasic      = tier.Community
+	TierPremium    = tier.Official
+	TierEnterprise = tier.Official
 )
 
-// Provider represents an AI provider configuration
+// Provider is a provider as the enhanced system routes to it. NewProvider and
+// Config convert it from and to the canonical config.ProviderConfig
 type Provider struct {
 	Name         string       `json:"name"`
 	BaseURL      string       `json:"base_url"`
 	Models       []string     `json:"models"`
 	Tier         ProviderTier `json:"tier"`
-	MaxTokens    int          `json:"max_tokens"`
+	MaxTokens    int64        `json:"max_tokens"`
 	CostPerToken float64      `json:"cost_per_token"`
 	Capabilities []string     `json:"capabilities"`
+	RateLimits   map[string]int64       `json:"rate_limits,omitempty"`
+	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+	LastUpdated  time.Time              `json:"last_updated"`
 	HealthMetrics *ProviderHealthMetrics `json:"health_metrics,omitempty"`
 }
 
@@ -32,9 +81,63 @@ type Provider struct {
 type RequestInput struct {
 	Content           string            `json:"content"`
 	PreferredProvider string            `json:"preferred_provider,omitempty"`
+	Model             string            `json:"model,omitempty"`
 	MaxTokens         int               `json:"max_tokens,omitempty"`
-	Temperature       float64           `json:"temperature,omitempty"`
+	Temperature       *float64          `json:"temperature,omitempty"`
 	Metadata          map[string]interface{} `json:"metadata,omitempty"`
+
+	// Sampling parameters beyond temperature, as OpenAI names them. Each is
+	// translated to the chosen provider's equivalent, and those it lacks are
+	// listed in the response metadata
+	TopP             *float64           `json:"top_p,omitempty"`
+	FrequencyPenalty *float64           `json:"frequency_penalty,omitempty"`
+	PresencePenalty  *float64           `json:"presence_penalty,omitempty"`
+	Stop             []string           `json:"stop,omitempty"`
+	LogitBias        map[string]float64 `json:"logit_bias,omitempty"`
+
+	// N is how many completions to return, and BestOf how many to generate
+	// and return the best N of. Providers whose API takes a completion count
+	// generate them in one call; the others get a call per completion
+	N      int `json:"n,omitempty"`
+	BestOf int `json:"best_of,omitempty"`
+
+	// Selection constraints; providers that cannot meet them are never chosen
+	CostLimit      float64     `json:"cost_limit,omitempty"`
+	QualityMin     float64     `json:"quality_min,omitempty"`
+	TierPreference []tier.Tier `json:"tier_preference,omitempty"`
+	MaxLatencyMs   int64       `json:"max_latency_ms,omitempty"`
+
+	// ParetoPolicy chooses from the cost/quality/latency Pareto front instead
+	// of by weighted score; "advisory" only reports the front
+	ParetoPolicy string `json:"pareto_policy,omitempty"`
+
+	// RequiredFeatures lists provider features the request relies on, e.g.
+	// "streaming" or "vision"; verified by capability probes when available
+	RequiredFeatures []string `json:"required_features,omitempty"`
+
+	// Capabilities is a capability expression every provider must satisfy,
+	// e.g. "code AND (reasoning>=7 OR official)"
+	Capabilities string `json:"capabilities,omitempty"`
+
+	// ReasoningEffort is low, medium or high. It routes the request to a
+	// model that spends reasoning tokens and sets how many it may spend
+	ReasoningEffort string `json:"reasoning_effort,omitempty"`
+
+	// Strategy is "direct" or "speculative"; empty uses the server default
+	Strategy string `json:"strategy,omitempty"`
+
+	// Mode names the agent mode of the request, which picks the response
+	// processors when the API key has none of its own
+	Mode string `json:"mode,omitempty"`
+
+	// Explain returns the reasoning behind the provider and model chosen,
+	// which selection otherwise skips building
+	Explain bool `json:"explain,omitempty"`
+
+	// Seed makes routing reproducible for debugging: tie breaks and the
+	// admission of warming providers are drawn from it, so the same request
+	// with the same seed routes identically while provider health holds
+	Seed *int64 `json:"seed,omitempty"`
 }
 
 // ProcessResponse represents the response from processing a request
@@ -47,6 +150,18 @@ type ProcessResponse struct {
 	TokensUsed     int64                      `json:"tokens_used"`
 	Cost           float64                    `json:"cost"`
 	Metadata       map[string]interface{}     `json:"metadata"`
+
+	// ReasoningTokens is the part of TokensUsed, and of Cost, a reasoning
+	// model spent thinking, as its provider reported
+	ReasoningTokens int64 `json:"reasoning_tokens,omitempty"`
+
+	// Choices are the completions of a request for several, Content being
+	// the first. TokensUsed and Cost cover every candidate generated,
+	// including those best_of discarded
+	Choices []Choice `json:"choices,omitempty"`
+
+	// safety is the response's safety classification, for analytics
+	safety *safety.Assessment
 }
 
 // ProviderAssignment represents the result of provider selection
@@ -92,6 +207,8 @@ type SystemMetrics struct {
 	TotalTokens       int64                              `json:"total_tokens"`
 	StartTime         time.Time                          `json:"start_time"`
 	LastUpdated       time.Time                          `json:"last_updated"`
+	// mutex guards the fields above, which every request updates
+	mutex sync.RWMutex
 }
 
 // NewSystemMetrics creates a new system metrics instance
@@ -106,36 +223,48 @@ func NewSystemMetrics() *SystemMetrics {
 
 // IncrementTotalRequests increments the total request counter
 func (sm *SystemMetrics) IncrementTotalRequests() {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
 	sm.TotalRequests++
 	sm.LastUpdated = time.Now()
 }
 
 // IncrementSuccessfulRequests increments the successful request counter
 func (sm *SystemMetrics) IncrementSuccessfulRequests() {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
 	sm.SuccessfulRequests++
 	sm.LastUpdated = time.Now()
 }
 
 // IncrementFailedRequests increments the failed request counter
 func (sm *SystemMetrics) IncrementFailedRequests() {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
 	sm.FailedRequests++
 	sm.LastUpdated = time.Now()
 }
 
 // RecordComplexity records complexity distribution
 func (sm *SystemMetrics) RecordComplexity(complexity components.ComplexityLevel) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
 	sm.ComplexityDistribution[complexity]++
 	sm.LastUpdated = time.Now()
 }
 
 // RecordProviderUsage records provider usage
 func (sm *SystemMetrics) RecordProviderUsage(providerName string) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
 	sm.ProviderUsage[providerName]++
 	sm.LastUpdated = time.Now()
 }
 
 // UpdateLatency updates average latency
 func (sm *SystemMetrics) UpdateLatency(latency time.Duration) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
 	// Simple moving average - could be improved with more sophisticated calculation
 	if sm.TotalRequests == 1 {
 		sm.AverageLatency = latency
@@ -147,294 +276,125 @@ func (sm *SystemMetrics) UpdateLatency(latency time.Duration) {
 
 // AddCost adds to total cost
 func (sm *SystemMetrics) AddCost(cost float64) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
 	sm.TotalCost += cost
 	sm.LastUpdated = time.Now()
 }
 
 // AddTokens adds to total tokens
 func (sm *SystemMetrics) AddTokens(tokens int64) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
 	sm.TotalTokens += tokens
 	sm.LastUpdated = time.Now()
 }
 
-// ProviderHealthMetrics represents health metrics for a provider
-type ProviderHealthMetrics struct {
-	ProviderName      string        `json:"provider_name"`
-	TotalRequests     int64         `json:"total_requests"`
-	SuccessfulRequests int64        `json:"successful_requests"`
-	FailedRequests    int64         `json:"failed_requests"`
-	AverageLatency    time.Duration `json:"average_latency"`
-	LastRequestTime   time.Time     `json:"last_request_time"`
-	HealthScore       float64       `json:"health_score"`
-	IsHealthy         bool          `json:"is_healthy"`
-	ErrorRate         float64       `json:"error_rate"`
-	LastError         string        `json:"last_error,omitempty"`
-	LastErrorTime     time.Time     `json:"last_error_time,omitempty"`
-}
-
-// ProviderHealthMonitor monitors provider health
-type ProviderHealthMonitor struct {
-	metrics map[string]*ProviderHealthMetrics
-}
-
-// NewProviderHealthMonitor creates a new provider health monitor
-func NewProviderHealthMonitor() *ProviderHealthMonitor {
-	return &ProviderHealthMonitor{
-		metrics: make(map[string]*ProviderHealthMetrics),
-	}
-}
-
-// UpdateMetrics updates health metrics for a provider
-func (phm *ProviderHealthMonitor) UpdateMetrics(providerName string, success bool, latency time.Duration) {
-	if phm.metrics[providerName] == nil {
-		phm.metrics[providerName] = &ProviderHealthMetrics{
-			ProviderName: providerName,
-		}
-	}
-
-	metrics := phm.metrics[providerName]
-	metrics.TotalRequests++
-	metrics.LastRequestTime = time.Now()
-
-	if success {
-		metrics.SuccessfulRequests++
-	} else {
-		metrics.FailedRequests++
-	}
-
-	// Update average latency
-	if metrics.TotalRequests == 1 {
-		metrics.AverageLatency = latency
-	} else {
-		metrics.AverageLatency = time.Duration((int64(metrics.AverageLatency)*int64(metrics.TotalRequests-1) + int64(latency)) / int64(metrics.TotalRequests))
-	}
-
-	// Calculate error rate
-	metrics.ErrorRate = float64(metrics.FailedRequests) / float64(metrics.TotalRequests)
-
-	// Calculate health score (simple implementation)
-	metrics.HealthScore = 1.0 - metrics.ErrorRate
-	if metrics.AverageLatency > 5*time.Second {
-		metrics.HealthScore *= 0.8 // Penalize high latency
-	}
-
-	// Determine if healthy
-	metrics.IsHealthy = metrics.HealthScore > 0.7 && metrics.ErrorRate < 0.3
-}
-
-// GetMetrics returns health metrics for a provider
-func (phm *ProviderHealthMonitor) GetMetrics(providerName string) *ProviderHealthMetrics {
-	return phm.metrics[providerName]
-}
-
-// GetAllMetrics returns all provider health metrics
-func (phm *ProviderHealthMonitor) GetAllMetrics() map[string]*ProviderHealthMetrics {
-	return phm.metrics
-}
-
-// IsHealthy checks if a provider is healthy
-func (phm *ProviderHealthMonitor) IsHealthy(providerName string) bool {
-	if metrics := phm.metrics[providerName]; metrics != nil {
-		return metrics.IsHealthy
-	}
-	return true // Assume healthy if no metrics available
-}
-
-// ResetMetrics resets metrics for a provider
-func (phm *ProviderHealthMonitor) ResetMetrics(providerName string) {
-	delete(phm.metrics, providerName)
-}
-
-// EnhancedProviderSelector represents an enhanced provider selector
-type EnhancedProviderSelector struct {
-	providers     []*Provider
-	healthMonitor *ProviderHealthMonitor
-}
-
-// NewEnhancedProviderSelector creates a new enhanced provider selector
-func NewEnhancedProviderSelector(providers []*Provider) *EnhancedProviderSelector {
-	return &EnhancedProviderSelector{
-		providers:     providers,
-		healthMonitor: NewProviderHealthMonitor(),
+// Snapshot returns a copy of the metrics that later requests leave unchanged,
+// safe to encode while the system keeps serving
+func (sm *SystemMetrics) Snapshot() *SystemMetrics {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+
+	snapshot := &SystemMetrics{
+		TotalRequests:          sm.TotalRequests,
+		SuccessfulRequests:     sm.SuccessfulRequests,
+		FailedRequests:         sm.FailedRequests,
+		AverageLatency:         sm.AverageLatency,
+		ComplexityDistribution: make(map[components.ComplexityLevel]int64, len(sm.ComplexityDistribution)),
+		ProviderUsage:          make(map[string]int64, len(sm.ProviderUsage)),
+		TotalCost:              sm.TotalCost,
+		TotalTokens:            sm.TotalTokens,
+		StartTime:              sm.StartTime,
+		LastUpdated:            sm.LastUpdated,
 	}
-}
-
-// SelectProviderWithCapabilities selects a provider based on complexity and required capabilities
-func (eps *EnhancedProviderSelector) SelectProviderWithCapabilities(ctx context.Context, complexity components.TaskComplexity, requiredCapabilities []string) (*ProviderAssignment, error) {
-	if len(eps.providers) == 0 {
-		return nil, fmt.Errorf("no providers available")
+	for level, count := range sm.ComplexityDistribution {
+		snapshot.ComplexityDistribution[level] = count
 	}
-
-	// Score providers based on capabilities and health
-	var scores []ProviderScore
-	for _, provider := range eps.providers {
-		score := eps.scoreProvider(provider, complexity, requiredCapabilities)
-		scores = append(scores, score)
+	for provider, count := range sm.ProviderUsage {
+		snapshot.ProviderUsage[provider] = count
 	}
-
-	// Sort by score (highest first)
-	for i := 0; i < len(scores)-1; i++ {
-		for j := i + 1; j < len(scores); j++ {
-			if scores[j].Score > scores[i].Score {
-				scores[i], scores[j] = scores[j], scores[i]
-			}
-		}
-	}
-
-	if len(scores) == 0 {
-		return nil, fmt.Errorf("no suitable providers found")
-	}
-
-	bestScore := scores[0]
-	
-	// Select first available model
-	model := "default"
-	if len(bestScore.Provider.Models) > 0 {
-		model = bestScore.Provider.Models[0]
-	}
-
-	return &ProviderAssignment{
-		Provider:        bestScore.Provider,
-		Model:           model,
-		Confidence:      bestScore.Confidence,
-		EstimatedCost:   float64(complexity.TokenEstimate) * bestScore.Provider.CostPerToken,
-		EstimatedTokens: complexity.TokenEstimate,
-		Reasoning:       bestScore.Reasoning,
-		Alternatives:    []*Provider{}, // Could populate with other high-scoring providers
-		Metadata:        make(map[string]interface{}),
-	}, nil
-}
-
-// scoreProvider scores a provider based on various factors
-func (eps *EnhancedProviderSelector) scoreProvider(provider *Provider, complexity components.TaskComplexity, requiredCapabilities []string) ProviderScore {
-	score := 1.0
-	reasoning := []string{}
-
-	// Check capability match
-	capabilityScore := eps.calculateCapabilityScore(provider.Capabilities, requiredCapabilities)
-	score *= capabilityScore
-	if capabilityScore > 0.8 {
-		reasoning = append(reasoning, "excellent capability match")
-	} else if capabilityScore > 0.5 {
-		reasoning = append(reasoning, "good capability match")
-	} else {
-		reasoning = append(reasoning, "limited capability match")
-	}
-
-	// Factor in health metrics
-	if metrics := eps.healthMonitor.GetMetrics(provider.Name); metrics != nil {
-		score *= metrics.HealthScore
-		if metrics.IsHealthy {
-			reasoning = append(reasoning, "healthy provider")
-		} else {
-			reasoning = append(reasoning, "provider health concerns")
-		}
-	}
-
-	// Factor in cost efficiency
-	costScore := 1.0 / (1.0 + provider.CostPerToken*100) // Prefer lower cost
-	score *= costScore
-
-	// Factor in complexity appropriateness
-	complexityScore := eps.calculateComplexityScore(provider, complexity)
-	score *= complexityScore
-
-	confidence := score
-	if confidence > 1.0 {
-		confidence = 1.0
-	}
-
-	return ProviderScore{
-		Provider:   provider,
-		Score:      score,
-		Confidence: confidence,
-		Reasoning:  strings.Join(reasoning, ", "),
-		Metadata:   make(map[string]interface{}),
-	}
-}
-
-// calculateCapabilityScore calculates how well provider capabilities match requirements
-func (eps *EnhancedProviderSelector) calculateCapabilityScore(providerCaps, requiredCaps []string) float64 {
-	if len(requiredCaps) == 0 {
-		return 1.0 // No specific requirements
-	}
-
-	matches := 0
-	for _, required := range requiredCaps {
-		for _, provided := range providerCaps {
-			if provided == required {
-				matches++
-				break
-			}
-		}
-	}
-
-	return float64(matches) / float64(len(requiredCaps))
-}
-
-// calculateComplexityScore calculates how well provider handles complexity
-func (eps *EnhancedProviderSelector) calculateComplexityScore(provider *Provider, complexity components.TaskComplexity) float64 {
-	// Simple implementation - could be more sophisticated
-	switch provider.Tier {
-	case TierEnterprise:
-		return 1.0 // Can handle any complexity
-	case TierPremium:
-		if complexity.Overall <= components.High {
-			return 1.0
-		}
-		return 0.8
-	case TierBasic:
-		if complexity.Overall <= components.Medium {
-			return 1.0
-		}
-		return 0.6
-	case TierFree:
-		if complexity.Overall <= components.Low {
-			return 1.0
-		}
-		return 0.4
-	default:
-		return 0.5
-	}
-}
-
-// GetProviderStats returns statistics about providers
-func (eps *EnhancedProviderSelector) GetProviderStats() map[string]interface{} {
-	stats := make(map[string]interface{})
-	
-	stats["total_providers"] = len(eps.providers)
-	
-	tierCounts := make(map[ProviderTier]int)
-	for _, provider := range eps.providers {
-		tierCounts[provider.Tier]++
-	}
-	stats["tier_distribution"] = tierCounts
-	
-	healthyCount := 0
-	for _, provider := range eps.providers {
-		if eps.healthMonitor.IsHealthy(provider.Name) {
-			healthyCount++
-		}
-	}
-	stats["healthy_providers"] = healthyCount
-	
-	return stats
+	return snapshot
 }
 
 // EnhancedSystem represents the main enhanced system
 type EnhancedSystem struct {
-	selector      *EnhancedProviderSelector
-	reasoner      *components.TaskReasoner
-	optimizer     *components.SPOOptimizer
-	healthMonitor *ProviderHealthMonitor
-	providers     []*Provider
-	metrics       *SystemMetrics
+	selector        ProviderSelector
+	reasoner        ComplexityAnalyzer
+	optimizer       PromptOptimizer
+	executor        Executor
+	healthMonitor   *ProviderHealthMonitor
+	providers       []*Provider
+	metrics         *SystemMetrics
+	metricsStorage  *MetricsStorage
+	sharedState     cluster.State
+	// Routing policies and virtual models are swapped by configuration
+	// reloads while requests read them
+	routingPolicies atomic.Pointer[selection.RoutingPolicies]
+	modelAliases    *selection.ModelAliases
+	virtualModels   atomic.Pointer[selection.VirtualModels]
+	tokenCalibrator *usage.Calibrator
+	yamlSync        *config.YAMLSync
+	providersCSVPath string
+	analytics       *analytics.AnalyticsEngine
+	capabilityProbe *capabilityProbe
+	credentials     *providerCredentials
+	tenants         *tenant.Registry
+	environments    *environment.Profiles
+	requestHistory  RequestHistory
+	auditLog        audit.Log
+	incidents       *incident.Log
+	featureFlags    *flags.Store
+	reasoningModels *reasoning.Table
+
+	// Draft-then-verify routing
+	speculativeDefault bool
+	speculativeCheck   speculative.Check
+	speculativeStats   *speculative.Recorder
+
+	responseProcessors *postprocess.Pipelines
+	hooks              *hooks.Hooks
+	events             *events.Bus
+	systemPromptPolicy *promptpolicy.Policy
+	scrubber           *scrub.Scrubber
+	promptInjection    *injection.Detector
+	safetyFilter       *safety.Filter
+	safetyEscalation   *safety.EscalationPolicy
+	safetyEscalations  *safety.EscalationRecorder
+
+	// Data retention; lastPurge covers the in-memory stores
+	retentionMutex  sync.Mutex
+	retentionPolicy retention.Policy
+	lastPurge       *retention.PurgeReport
+
+	// Asynchronous requests that are still running, by request ID
+	asyncMutex    sync.Mutex
+	asyncRequests map[string]*asyncRequest
+	asyncTimeout  time.Duration
+
+	// Request lifecycle tracking used for graceful shutdown
+	lifecycleMutex sync.Mutex
+	inFlight       sync.WaitGroup
+	activeRequests atomic.Int64
+	draining       atomic.Bool
+	terminating    atomic.Bool
+
+	// Readiness requires at least this many healthy providers
+	minHealthyProviders int
+
+	// Startup validation and the providers it left out of routing
+	startup startupState
+
+	// Minimum selection confidence and the fallback below it
+	confidencePolicy selection.ConfidencePolicy
+
+	// Warm-up of providers without enough real traffic, nil when disabled
+	coldStart *coldStart
+
+	// Tokens per minute of upstream accounts, nil when not modeled
+	throughput *throughput
+
+	// Store of the images and audio responses carry, nil when responses
+	// keep provider links
+	artifacts *artifact.Manager
 }
-
-// RateLimitStatus represents rate limiting status
-type RateLimitStatus struct {
-	IsLimited         bool      `json:"is_limited"`
-	RequestsPerMinute int       `json:"requests_per_minute"`
-	TokensPerMinute   int       `json:"tokens_per_minute"`
-	ResetTime         time.Time `json:"reset_time"`
-}
\ No newline at end of file
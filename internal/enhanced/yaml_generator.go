@@ -1,45 +1,47 @@
 package enhanced
 
 import (
-	"bytes"
 	"context"
 	"fmt"
-	"net/http"
-	"net/url"
 	"os"
 	"strings"
-	"time"
 
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/assistant"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/pollinations"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
 	"github.com/sirupsen/logrus"
 )
 
 // YAMLGenerator handles CSV-to-YAML conversion using AI
 type YAMLGenerator struct {
-	logger      *logrus.Logger
-	pollinationsURL string
-	httpClient  *http.Client
+	logger    *logrus.Logger
+	assistant assistant.Model
 }
 
-// NewYAMLGenerator creates a new YAML generator
+// NewYAMLGenerator creates a new YAML generator backed by Pollinations
 func NewYAMLGenerator(logger *logrus.Logger) *YAMLGenerator {
 	return &YAMLGenerator{
-		logger:          logger,
-		pollinationsURL: "https://text.pollinations.ai",
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		logger:    logger,
+		assistant: pollinations.NewClient(),
 	}
 }
 
+// SetAssistant replaces the model that writes the YAML
+func (y *YAMLGenerator) SetAssistant(model assistant.Model) {
+	y.assistant = model
+}
+
 // GenerateYAMLFromProvider converts a Provider to YAML using AI
 func (y *YAMLGenerator) GenerateYAMLFromProvider(ctx context.Context, provider *Provider) (string, error) {
-	y.logger.Infof("Generating YAML for provider: %s", provider.ID)
+	cfg := provider.Config()
+	y.logger.Infof("Generating YAML for provider: %s", cfg.ID)
 	
-	prompt := y.buildPrompt(provider)
+	prompt := y.buildPrompt(cfg)
 	
-	response, err := y.callPollinationsAPI(ctx, prompt)
+	response, err := y.assistant.GenerateText(ctx, prompt)
 	if err != nil {
-		return "", fmt.Errorf("failed to call Pollinations API: %w", err)
+		return "", fmt.Errorf("failed to call %s: %w", assistant.Describe(y.assistant), err)
 	}
 	
 	// Extract YAML from response
@@ -48,34 +50,33 @@ func (y *YAMLGenerator) GenerateYAMLFromProvider(ctx context.Context, provider *
 		return "", fmt.Errorf("no valid YAML generated from AI response")
 	}
 	
-	y.logger.Infof("Successfully generated YAML for provider %s", provider.ID)
+	y.logger.Infof("Successfully generated YAML for provider %s", cfg.ID)
 	return yaml, nil
 }
 
 // buildPrompt creates the AI prompt for YAML generation
-func (y *YAMLGenerator) buildPrompt(provider *Provider) string {
+func (y *YAMLGenerator) buildPrompt(provider config.ProviderConfig) string {
 	var sb strings.Builder
 	
 	sb.WriteString("Generate a YAML configuration file for an AI provider with the following details:\n\n")
 	sb.WriteString(fmt.Sprintf("Provider ID: %s\n", provider.ID))
 	sb.WriteString(fmt.Sprintf("Provider Name: %s\n", provider.Name))
 	sb.WriteString(fmt.Sprintf("Tier: %s\n", provider.Tier))
-	sb.WriteString(fmt.Sprintf("Base URL: %s\n", provider.BaseURL))
+	sb.WriteString(fmt.Sprintf("Base URL: %s\n", provider.Endpoint))
 	
 	// Handle models field
-	if provider.Models != "" {
-		sb.WriteString(fmt.Sprintf("Models: %s\n", provider.Models))
+	if models := provider.ModelsSource.String(); models != "" {
+		sb.WriteString(fmt.Sprintf("Models: %s\n", models))
 	}
 	
 	// Add pricing information if available
-	if provider.Pricing != nil {
-		sb.WriteString(fmt.Sprintf("Input Token Cost: %.6f\n", provider.Pricing.InputTokenCost))
-		sb.WriteString(fmt.Sprintf("Output Token Cost: %.6f\n", provider.Pricing.OutputTokenCost))
+	if provider.CostTracking.CostPerToken > 0 {
+		sb.WriteString(fmt.Sprintf("Token Cost: %.6f\n", provider.CostTracking.CostPerToken))
 	}
 	
 	// Add other information
-	if provider.Other != "" {
-		sb.WriteString(fmt.Sprintf("Additional Info: %s\n", provider.Other))
+	if provider.Description != "" {
+		sb.WriteString(fmt.Sprintf("Additional Info: %s\n", provider.Description))
 	}
 	
 	sb.WriteString("\nGenerate a complete YAML configuration file that includes:\n")
@@ -86,41 +87,13 @@ func (y *YAMLGenerator) buildPrompt(provider *Provider) string {
 	sb.WriteString("5. Cost tracking settings\n")
 	sb.WriteString("6. Retry and timeout configurations\n")
 	sb.WriteString("7. Any provider-specific settings based on the additional info\n\n")
+	sb.WriteString(fmt.Sprintf("Set the tier field to %s; valid tiers are %s.\n", provider.Tier, strings.Join(tier.Names(), ", ")))
 	sb.WriteString("Format the output as valid YAML. Include comments explaining each section.\n")
 	sb.WriteString("Only return the YAML content, no additional text or explanation.")
 	
 	return sb.String()
 }
 
-// callPollinationsAPI calls the Pollinations text generation API (simple GET)
-func (y *YAMLGenerator) callPollinationsAPI(ctx context.Context, prompt string) (string, error) {
-	// Use simple text API with proper URL encoding
-	encodedPrompt := url.QueryEscape(prompt)
-	apiURL := fmt.Sprintf("%s/%s", y.pollinationsURL, encodedPrompt)
-	
-	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
-	}
-	
-	resp, err := y.httpClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to make request: %w", err)
-	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API returned status %d", resp.StatusCode)
-	}
-	
-	var buf bytes.Buffer
-	if _, err := buf.ReadFrom(resp.Body); err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
-	}
-	
-	return buf.String(), nil
-}
-
 // extractYAML extracts YAML content from the AI response
 func (y *YAMLGenerator) extractYAML(response string) string {
 	// Remove any markdown code blocks
@@ -151,10 +124,10 @@ func (y *YAMLGenerator) GenerateYAMLBatch(ctx context.Context, providers []*Prov
 	for _, provider := range providers {
 		yaml, err := y.GenerateYAMLFromProvider(ctx, provider)
 		if err != nil {
-			y.logger.Errorf("Failed to generate YAML for provider %s: %v", provider.ID, err)
+			y.logger.Errorf("Failed to generate YAML for provider %s: %v", provider.Name, err)
 			continue
 		}
-		results[provider.ID] = yaml
+		results[provider.Config().ID] = yaml
 	}
 	
 	y.logger.Infof("Generated YAML for %d/%d providers", len(results), len(providers))
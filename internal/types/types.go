@@ -22,19 +22,3 @@ type RequestResult struct {
 	Metadata     map[string]interface{} `json:"metadata"`
 	Timestamp    time.Time              `json:"timestamp"`
 }
-
-// Provider represents an AI provider configuration
-type Provider struct {
-	Name           string            `json:"name"`
-	Tier           string            `json:"tier"`
-	Endpoint       string            `json:"endpoint"`
-	ModelsSource   string            `json:"models_source"`
-	Authentication AuthConfig        `json:"authentication"`
-	Metadata       map[string]string `json:"metadata"`
-}
-
-// AuthConfig represents authentication configuration
-type AuthConfig struct {
-	Type   string `json:"type"`
-	APIKey string `json:"api_key,omitempty"`
-}
\ No newline at end of file
@@ -0,0 +1,120 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/audit"
+	"github.com/gorilla/mux"
+)
+
+// AuditHandlers serves the audit log of admin actions
+type AuditHandlers struct {
+	log audit.Log
+}
+
+// NewAuditHandlers creates handlers reading log
+func NewAuditHandlers(log audit.Log) *AuditHandlers {
+	return &AuditHandlers{log: log}
+}
+
+// ListEvents returns a page of audit events, newest first, filtered by
+// ?actor=, ?action=, ?resource=, ?since=, ?until= and ?success=
+func (ah *AuditHandlers) ListEvents(w http.ResponseWriter, r *http.Request) {
+	query, err := parseAuditQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := ah.log.QueryAuditEvents(query)
+	if errors.Is(err, audit.ErrInvalidCursor) {
+		http.Error(w, "cursor must be the next_cursor of a previous page", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to query audit log: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// ExportEvents downloads every audit event matching the ListEvents filters
+// as ?format=csv (the default) or json
+func (ah *AuditHandlers) ExportEvents(w http.ResponseWriter, r *http.Request) {
+	query, err := parseAuditQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	contentType := "text/csv"
+	switch format {
+	case "", "csv":
+		format = "csv"
+	case "json":
+		contentType = "application/json"
+	default:
+		http.Error(w, "format must be csv or json", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q",
+		fmt.Sprintf("audit-%s.%s", time.Now().UTC().Format("20060102-150405"), format)))
+	if err := audit.Export(w, ah.log, query, format); err != nil {
+		// Headers are gone once rows are written; a truncated file is all
+		// the client can be given
+		logger.Warnf("Audit log export failed: %v", err)
+	}
+}
+
+// parseAuditQuery reads the audit log filters from the query string
+func parseAuditQuery(r *http.Request) (audit.Query, error) {
+	params := r.URL.Query()
+	query := audit.Query{
+		Actor:    params.Get("actor"),
+		Action:   params.Get("action"),
+		Resource: params.Get("resource"),
+		Cursor:   params.Get("cursor"),
+	}
+	for _, bound := range []struct {
+		name  string
+		value *time.Time
+	}{{"since", &query.Since}, {"until", &query.Until}} {
+		if value := params.Get(bound.name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return query, fmt.Errorf("%s must be an RFC 3339 time", bound.name)
+			}
+			*bound.value = parsed
+		}
+	}
+	if value := params.Get("success"); value != "" {
+		success, err := strconv.ParseBool(value)
+		if err != nil {
+			return query, fmt.Errorf("success must be true or false")
+		}
+		query.Success = &success
+	}
+	if value := params.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > audit.MaxPageSize {
+			return query, fmt.Errorf("limit must be between 1 and %d", audit.MaxPageSize)
+		}
+		query.Limit = limit
+	}
+	return query, nil
+}
+
+// RegisterRoutes adds the audit log routes to router
+func (ah *AuditHandlers) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/audit", ah.ListEvents).Methods("GET")
+	router.HandleFunc("/admin/audit/export", ah.ExportEvents).Methods("GET")
+}
@@ -0,0 +1,66 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/probe"
+	"github.com/gorilla/mux"
+)
+
+// CapabilityVerifier reports how current the probed capabilities of the
+// providers are and clears the changes flagged on them
+type CapabilityVerifier interface {
+	CapabilityVerifications() ([]probe.Verification, error)
+	AcknowledgeCapabilityChanges(provider string) (bool, error)
+}
+
+// CapabilityHandlers serve capability verification ages and changes
+type CapabilityHandlers struct {
+	verifier CapabilityVerifier
+}
+
+// NewCapabilityHandlers creates handlers for verifier
+func NewCapabilityHandlers(verifier CapabilityVerifier) *CapabilityHandlers {
+	return &CapabilityHandlers{verifier: verifier}
+}
+
+// List returns when each provider's features were last verified, whether
+// they are due to be probed again and what changed since the verification
+// before
+func (ch *CapabilityHandlers) List(w http.ResponseWriter, r *http.Request) {
+	verifications, err := ch.verifier.CapabilityVerifications()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(verifications)
+}
+
+// Acknowledge clears the changes flagged on a provider
+func (ch *CapabilityHandlers) Acknowledge(w http.ResponseWriter, r *http.Request) {
+	provider := mux.Vars(r)["provider"]
+	probed, err := ch.verifier.AcknowledgeCapabilityChanges(provider)
+	if err != nil && !probed {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if !probed {
+		http.Error(w, fmt.Sprintf("Provider %s has not been probed", provider), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to save probe results: %v", err), http.StatusInternalServerError)
+		return
+	}
+	logger.Infof("Capability changes of %s acknowledged by %s", provider, Author(r))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RegisterRoutes adds the capability verification routes to router
+func (ch *CapabilityHandlers) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/capabilities", ch.List).Methods("GET")
+	router.HandleFunc("/admin/capabilities/{provider}/acknowledge", ch.Acknowledge).Methods("POST")
+}
@@ -0,0 +1,150 @@
+package admin
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/bundle"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+	"github.com/gorilla/mux"
+)
+
+// BundleHandlers exports the tracked configuration files as a signed bundle
+// and imports bundles signed by a trusted key
+type BundleHandlers struct {
+	history    *config.ConfigHistory
+	signingKey ed25519.PrivateKey
+	trusted    []ed25519.PublicKey
+	source     string
+}
+
+// NewBundleHandlers creates handlers for the files tracked by history. Export
+// is disabled without signingKey and import without trusted keys; source names
+// this instance in exported manifests
+func NewBundleHandlers(history *config.ConfigHistory, signingKey ed25519.PrivateKey, trusted []ed25519.PublicKey, source string) *BundleHandlers {
+	return &BundleHandlers{history: history, signingKey: signingKey, trusted: trusted, source: source}
+}
+
+// BundleFileResult is the effect of importing one bundled file
+type BundleFileResult struct {
+	Path string `json:"path"`
+	// Status is created, updated or unchanged
+	Status string `json:"status"`
+	// Revision records the new content, absent for dry runs and unchanged files
+	Revision *config.Revision `json:"revision,omitempty"`
+}
+
+// BundleImport is the body returned by POST /admin/config/bundle
+type BundleImport struct {
+	Manifest bundle.Manifest    `json:"manifest"`
+	DryRun   bool               `json:"dry_run"`
+	Files    []BundleFileResult `json:"files"`
+}
+
+// ExportBundle returns the tracked configuration files as a signed tarball
+func (bh *BundleHandlers) ExportBundle(w http.ResponseWriter, r *http.Request) {
+	if bh.signingKey == nil {
+		http.Error(w, "Bundle export is not enabled; set BUNDLE_SIGNING_KEY", http.StatusNotFound)
+		return
+	}
+	files, err := bh.history.Files()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to list configuration files: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	manifest, err := bundle.Export(&buf, files, bh.signingKey, bh.source)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to export bundle: %v", err), http.StatusInternalServerError)
+		return
+	}
+	logger.Infof("Exported config bundle with %d files for %s", len(manifest.Files), Author(r))
+
+	name := fmt.Sprintf("config-bundle-%s.tar.gz", manifest.CreatedAt.Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	w.Write(buf.Bytes())
+}
+
+// ImportBundle verifies a bundle and writes its files, each recorded as a
+// revision. Nothing is written unless every file is tracked here; files
+// missing from the bundle are left alone. ?dry_run=true only reports what
+// would change
+func (bh *BundleHandlers) ImportBundle(w http.ResponseWriter, r *http.Request) {
+	if len(bh.trusted) == 0 {
+		http.Error(w, "Bundle import is not enabled; set BUNDLE_TRUSTED_KEYS", http.StatusNotFound)
+		return
+	}
+
+	imported, err := bundle.Read(r.Body, bh.trusted)
+	switch {
+	case errors.Is(err, bundle.ErrUntrustedSignature):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	var untracked []string
+	for _, file := range imported.Manifest.Files {
+		if !bh.history.Tracks(file.Path) {
+			untracked = append(untracked, file.Path)
+		}
+	}
+	if len(untracked) > 0 {
+		http.Error(w, fmt.Sprintf("Bundle holds files this instance does not track: %s", strings.Join(untracked, ", ")), http.StatusConflict)
+		return
+	}
+
+	result := BundleImport{
+		Manifest: imported.Manifest,
+		DryRun:   r.URL.Query().Get("dry_run") == "true",
+		Files:    make([]BundleFileResult, 0, len(imported.Manifest.Files)),
+	}
+	message := fmt.Sprintf("import bundle signed by %s", imported.Manifest.KeyID)
+	if imported.Manifest.Source != "" {
+		message += " from " + imported.Manifest.Source
+	}
+	for _, file := range imported.Manifest.Files {
+		content := imported.Contents[file.Path]
+		outcome := BundleFileResult{Path: file.Path, Status: "updated"}
+		current, err := os.ReadFile(file.Path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			outcome.Status = "created"
+		case err == nil && bytes.Equal(current, content):
+			outcome.Status = "unchanged"
+		}
+
+		if !result.DryRun && outcome.Status != "unchanged" {
+			revision, err := bh.history.Write(file.Path, content, Author(r), message)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Failed to import %s: %v", file.Path, err), http.StatusInternalServerError)
+				return
+			}
+			outcome.Revision = revision
+		}
+		result.Files = append(result.Files, outcome)
+	}
+	if !result.DryRun {
+		logger.Infof("Imported config bundle %s exported %s for %s", imported.Manifest.KeyID, imported.Manifest.CreatedAt.Format(time.RFC3339), Author(r))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// RegisterRoutes adds the bundle routes to router
+func (bh *BundleHandlers) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/config/bundle", bh.ExportBundle).Methods("GET")
+	router.HandleFunc("/admin/config/bundle", bh.ImportBundle).Methods("POST")
+}
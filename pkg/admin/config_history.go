@@ -0,0 +1,165 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/middleware"
+	"github.com/gorilla/mux"
+)
+
+// ConfigHistoryHandlers lists, compares and rolls back configuration revisions
+type ConfigHistoryHandlers struct {
+	history *config.ConfigHistory
+}
+
+// NewConfigHistoryHandlers creates handlers for history
+func NewConfigHistoryHandlers(history *config.ConfigHistory) *ConfigHistoryHandlers {
+	return &ConfigHistoryHandlers{history: history}
+}
+
+// RevisionContent is the body returned by GET /admin/config/revisions/{id}
+type RevisionContent struct {
+	Revision *config.Revision `json:"revision"`
+	Content  string           `json:"content"`
+}
+
+// Author attributes a configuration change to the caller's key ID, so
+// revisions never hold the key itself
+func Author(r *http.Request) string {
+	if id := middleware.KeyID(r); id != "" {
+		return id
+	}
+	return "anonymous"
+}
+
+// ListRevisions returns the revisions of ?file=, or of every file, newest first
+func (hh *ConfigHistoryHandlers) ListRevisions(w http.ResponseWriter, r *http.Request) {
+	revisions := hh.history.List(r.URL.Query().Get("file"))
+	if revisions == nil {
+		revisions = []config.Revision{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revisions)
+}
+
+// GetRevision returns a revision with the content it recorded
+func (hh *ConfigHistoryHandlers) GetRevision(w http.ResponseWriter, r *http.Request) {
+	id, ok := revisionID(w, r)
+	if !ok {
+		return
+	}
+	revision, content, err := hh.history.Get(id)
+	if err != nil {
+		writeHistoryError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RevisionContent{Revision: revision, Content: string(content)})
+}
+
+// DiffRevision returns a unified diff to the revision from ?against=, by
+// default the previous revision of the same file
+func (hh *ConfigHistoryHandlers) DiffRevision(w http.ResponseWriter, r *http.Request) {
+	id, ok := revisionID(w, r)
+	if !ok {
+		return
+	}
+	against := 0
+	if value := r.URL.Query().Get("against"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "against must be a revision ID", http.StatusBadRequest)
+			return
+		}
+		against = parsed
+	}
+
+	diff, err := hh.history.Diff(id, against)
+	if err != nil {
+		writeHistoryError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/x-diff; charset=utf-8")
+	fmt.Fprint(w, diff)
+}
+
+// Rollback restores a revision's content and returns the revision recording it
+func (hh *ConfigHistoryHandlers) Rollback(w http.ResponseWriter, r *http.Request) {
+	id, ok := revisionID(w, r)
+	if !ok {
+		return
+	}
+	revision, err := hh.history.Rollback(id, Author(r))
+	if err != nil {
+		writeHistoryError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(revision)
+}
+
+// Snapshot records edits made to the tracked files outside the gateway. The
+// optional body {"message": "..."} describes them
+func (hh *ConfigHistoryHandlers) Snapshot(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Message string `json:"message"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	revisions, err := hh.history.Snapshot(Author(r), body.Message)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to snapshot configuration: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if revisions == nil {
+		revisions = []config.Revision{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revisions)
+}
+
+// RegisterRoutes adds the configuration history routes to router
+func (hh *ConfigHistoryHandlers) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/config/revisions", hh.ListRevisions).Methods("GET")
+	router.HandleFunc("/admin/config/revisions/{id}", hh.GetRevision).Methods("GET")
+	router.HandleFunc("/admin/config/revisions/{id}/diff", hh.DiffRevision).Methods("GET")
+	router.HandleFunc("/admin/config/revisions/{id}/rollback", hh.Rollback).Methods("POST")
+	router.HandleFunc("/admin/config/snapshot", hh.Snapshot).Methods("POST")
+}
+
+// revisionID parses the {id} route variable, answering 400 when it is invalid
+func revisionID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Revision ID must be a number", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// writeHistoryError maps history errors to status codes
+func writeHistoryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, config.ErrRevisionNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, config.ErrUntrackedFile):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,73 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/logging"
+	"github.com/gorilla/mux"
+)
+
+var logger = logging.Module("admin")
+
+// ProviderConsistency checks and reconciles the provider CSV, the provider
+// YAML files and the loaded providers
+type ProviderConsistency interface {
+	CheckProviderConsistency() (*config.ConsistencyReport, error)
+	ReconcileProviderConsistency() (*config.ReconcileResult, error)
+}
+
+// ConsistencyHandlers serves the provider consistency check
+type ConsistencyHandlers struct {
+	checker ProviderConsistency
+	history *config.ConfigHistory
+}
+
+// NewConsistencyHandlers creates handlers for checker. Files changed by a
+// reconcile are recorded in history unless it is nil
+func NewConsistencyHandlers(checker ProviderConsistency, history *config.ConfigHistory) *ConsistencyHandlers {
+	return &ConsistencyHandlers{checker: checker, history: history}
+}
+
+// CheckConsistency reports the drift between the CSV, YAML files and runtime
+func (ch *ConsistencyHandlers) CheckConsistency(w http.ResponseWriter, r *http.Request) {
+	report, err := ch.checker.CheckProviderConsistency()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to check consistency: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// Reconcile fixes the reconcilable drift and returns what changed together
+// with the drift that remains
+func (ch *ConsistencyHandlers) Reconcile(w http.ResponseWriter, r *http.Request) {
+	result, err := ch.checker.ReconcileProviderConsistency()
+	if errors.Is(err, config.ErrYAMLDirUnset) {
+		http.Error(w, "Reconciling writes provider YAML files; set PROVIDER_YAML_DIR", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to reconcile: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if ch.history != nil {
+		if _, err := ch.history.Snapshot(Author(r), "reconcile provider consistency"); err != nil {
+			logger.Warnf("Failed to record reconciled configuration: %v", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// RegisterRoutes adds the consistency routes to router
+func (ch *ConsistencyHandlers) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/consistency", ch.CheckConsistency).Methods("GET")
+	router.HandleFunc("/admin/consistency/reconcile", ch.Reconcile).Methods("POST")
+}
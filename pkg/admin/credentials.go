@@ -0,0 +1,179 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/credentials"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/probe"
+	"github.com/gorilla/mux"
+)
+
+// maxStagedKeyBytes bounds the body of a staged key
+const maxStagedKeyBytes = 64 << 10
+
+// CredentialRotator is the part of the enhanced system that rotates
+// provider API keys blue/green
+type CredentialRotator interface {
+	ProviderCredentials() ([]*credentials.Status, error)
+	ProviderCredential(provider string) (*credentials.Status, error)
+	StageProviderKey(provider, key string) (*credentials.Status, error)
+	ValidateProviderKey(ctx context.Context, provider string) (*credentials.Status, *probe.Result, error)
+	PromoteProviderKey(provider string) (*credentials.Status, error)
+	DiscardProviderKey(provider string) error
+}
+
+// CredentialHandlers serve the staging, validation and promotion of
+// provider API keys
+type CredentialHandlers struct {
+	rotator CredentialRotator
+}
+
+// NewCredentialHandlers creates handlers rotating keys through rotator
+func NewCredentialHandlers(rotator CredentialRotator) *CredentialHandlers {
+	return &CredentialHandlers{rotator: rotator}
+}
+
+// StageKeyRequest is the body of PUT /admin/credentials/{provider}/staged
+type StageKeyRequest struct {
+	APIKey string `json:"api_key"`
+}
+
+// KeyValidation is the body returned by POST /admin/credentials/{provider}/validate
+type KeyValidation struct {
+	Credential *credentials.Status `json:"credential"`
+	// Probe is what the provider answered the staged key with
+	Probe *probe.Result `json:"probe"`
+}
+
+// List returns the key status of every provider
+func (ch *CredentialHandlers) List(w http.ResponseWriter, r *http.Request) {
+	statuses, err := ch.rotator.ProviderCredentials()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statuses)
+}
+
+// Get returns the key status of one provider
+func (ch *CredentialHandlers) Get(w http.ResponseWriter, r *http.Request) {
+	status, ok := ch.status(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// Stage stages a new key for a provider; traffic keeps the active key
+func (ch *CredentialHandlers) Stage(w http.ResponseWriter, r *http.Request) {
+	if _, ok := ch.status(w, r); !ok {
+		return
+	}
+	var request StageKeyRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxStagedKeyBytes)).Decode(&request); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	provider := mux.Vars(r)["provider"]
+	status, err := ch.rotator.StageProviderKey(provider, request.APIKey)
+	if status == nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to save provider credentials: %v", err), http.StatusInternalServerError)
+		return
+	}
+	logger.Infof("Key %s staged for %s by %s", status.Staged.Fingerprint, provider, Author(r))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// Validate probes a provider with its staged key. A key the provider does
+// not answer is marked failed and cannot be promoted
+func (ch *CredentialHandlers) Validate(w http.ResponseWriter, r *http.Request) {
+	if _, ok := ch.status(w, r); !ok {
+		return
+	}
+	status, result, err := ch.rotator.ValidateProviderKey(r.Context(), mux.Vars(r)["provider"])
+	switch {
+	case errors.Is(err, credentials.ErrNothingStaged):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case result == nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, fmt.Sprintf("Failed to save provider credentials: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(KeyValidation{Credential: status, Probe: result})
+}
+
+// Promote switches a provider to its validated staged key and retires the
+// active one
+func (ch *CredentialHandlers) Promote(w http.ResponseWriter, r *http.Request) {
+	if _, ok := ch.status(w, r); !ok {
+		return
+	}
+	provider := mux.Vars(r)["provider"]
+	status, err := ch.rotator.PromoteProviderKey(provider)
+	switch {
+	case errors.Is(err, credentials.ErrNothingStaged), errors.Is(err, credentials.ErrNotValidated):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, fmt.Sprintf("Failed to save provider credentials: %v", err), http.StatusInternalServerError)
+		return
+	}
+	logger.Infof("Key %s promoted for %s by %s, retiring %s", status.ActiveFingerprint, provider, Author(r), status.RetiredFingerprint)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// Discard drops the staged key of a provider
+func (ch *CredentialHandlers) Discard(w http.ResponseWriter, r *http.Request) {
+	if _, ok := ch.status(w, r); !ok {
+		return
+	}
+	err := ch.rotator.DiscardProviderKey(mux.Vars(r)["provider"])
+	switch {
+	case errors.Is(err, credentials.ErrNothingStaged):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, fmt.Sprintf("Failed to save provider credentials: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// status returns the key status of the route's provider, answering 404 when
+// it is not configured or rotation is disabled
+func (ch *CredentialHandlers) status(w http.ResponseWriter, r *http.Request) (*credentials.Status, bool) {
+	status, err := ch.rotator.ProviderCredential(mux.Vars(r)["provider"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return nil, false
+	}
+	return status, true
+}
+
+// RegisterRoutes adds the credential rotation routes to router
+func (ch *CredentialHandlers) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/credentials", ch.List).Methods("GET")
+	router.HandleFunc("/admin/credentials/{provider}", ch.Get).Methods("GET")
+	router.HandleFunc("/admin/credentials/{provider}/staged", ch.Stage).Methods("PUT")
+	router.HandleFunc("/admin/credentials/{provider}/staged", ch.Discard).Methods("DELETE")
+	router.HandleFunc("/admin/credentials/{provider}/validate", ch.Validate).Methods("POST")
+	router.HandleFunc("/admin/credentials/{provider}/promote", ch.Promote).Methods("POST")
+}
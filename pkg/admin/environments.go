@@ -0,0 +1,76 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/analytics"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/environment"
+	"github.com/gorilla/mux"
+)
+
+// EnvironmentHandlers reports the routing profiles of key environments and
+// their spend
+type EnvironmentHandlers struct {
+	profiles *environment.Profiles
+	engine   *analytics.AnalyticsEngine
+}
+
+// NewEnvironmentHandlers creates handlers for profiles
+func NewEnvironmentHandlers(profiles *environment.Profiles, engine *analytics.AnalyticsEngine) *EnvironmentHandlers {
+	return &EnvironmentHandlers{profiles: profiles, engine: engine}
+}
+
+// EnvironmentProfile is one entry of GET /admin/environments
+type EnvironmentProfile struct {
+	Name string `json:"name"`
+	environment.Profile
+}
+
+// EnvironmentUsage is the body returned by GET /admin/environments/{name}/usage
+type EnvironmentUsage struct {
+	*environment.Usage
+	Metrics map[string]interface{} `json:"metrics,omitempty"`
+}
+
+// ListEnvironments returns the profile of every environment
+func (eh *EnvironmentHandlers) ListEnvironments(w http.ResponseWriter, r *http.Request) {
+	profiles := []EnvironmentProfile{}
+	for _, name := range eh.profiles.Names() {
+		profile, _ := eh.profiles.Get(name)
+		profiles = append(profiles, EnvironmentProfile{Name: name, Profile: profile})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profiles)
+}
+
+// GetEnvironmentUsage returns an environment's spend against its budget this
+// month and the analytics of its requests over the same period
+func (eh *EnvironmentHandlers) GetEnvironmentUsage(w http.ResponseWriter, r *http.Request) {
+	usage, err := eh.profiles.Usage(mux.Vars(r)["name"])
+	if errors.Is(err, environment.ErrUnknown) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	result := EnvironmentUsage{Usage: usage}
+	if eh.engine != nil {
+		monthStart, _ := time.Parse("2006-01", usage.Month)
+		result.Metrics = eh.engine.GetEnvironmentMetrics(usage.Environment, monthStart)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// RegisterRoutes adds the environment routes to router
+func (eh *EnvironmentHandlers) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/environments", eh.ListEnvironments).Methods("GET")
+	router.HandleFunc("/admin/environments/{name}/usage", eh.GetEnvironmentUsage).Methods("GET")
+}
@@ -0,0 +1,127 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/flags"
+	"github.com/gorilla/mux"
+)
+
+// FeatureFlagStore is the part of the enhanced system that evaluates and
+// overrides feature flags
+type FeatureFlagStore interface {
+	FeatureFlags() []*flags.Status
+	FeatureFlag(name string) (*flags.Status, error)
+	SetFeatureFlag(name, scope, target string, enabled bool) (*flags.Status, error)
+	ClearFeatureFlag(name, scope, target string) (*flags.Status, error)
+	EvaluateFeatureFlags(tenant, keyID string) flags.Evaluations
+}
+
+// FeatureFlagHandlers serve the feature flags of experimental behaviors
+type FeatureFlagHandlers struct {
+	store FeatureFlagStore
+}
+
+// NewFeatureFlagHandlers creates handlers for the flags of store
+func NewFeatureFlagHandlers(store FeatureFlagStore) *FeatureFlagHandlers {
+	return &FeatureFlagHandlers{store: store}
+}
+
+// FlagOverride is the body of PUT /admin/flags/{flag}
+type FlagOverride struct {
+	// Scope is global, tenant or key
+	Scope string `json:"scope"`
+	// Target is the tenant ID or key ID of a tenant or key override
+	Target  string `json:"target,omitempty"`
+	Enabled *bool  `json:"enabled"`
+}
+
+// List returns every flag with its overrides
+func (fh *FeatureFlagHandlers) List(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fh.store.FeatureFlags())
+}
+
+// Get returns one flag with its overrides
+func (fh *FeatureFlagHandlers) Get(w http.ResponseWriter, r *http.Request) {
+	status, err := fh.store.FeatureFlag(mux.Vars(r)["flag"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// Evaluate returns the flags a request of ?tenant= with the API key ?key_id=
+// would see, and the scope that decided each
+func (fh *FeatureFlagHandlers) Evaluate(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fh.store.EvaluateFeatureFlags(params.Get("tenant"), params.Get("key_id")))
+}
+
+// Set overrides a flag globally, for a tenant or for an API key
+func (fh *FeatureFlagHandlers) Set(w http.ResponseWriter, r *http.Request) {
+	var override FlagOverride
+	if err := json.NewDecoder(r.Body).Decode(&override); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if override.Enabled == nil {
+		http.Error(w, "enabled is required", http.StatusBadRequest)
+		return
+	}
+
+	name := mux.Vars(r)["flag"]
+	status, err := fh.store.SetFeatureFlag(name, override.Scope, override.Target, *override.Enabled)
+	if !fh.answered(w, status, err) {
+		return
+	}
+	logger.Infof("Feature flag %s set to %t for %s %s by %s", name, *override.Enabled, override.Scope, override.Target, Author(r))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// Clear removes the override of a flag named by ?scope= and ?target=
+func (fh *FeatureFlagHandlers) Clear(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["flag"]
+	scope, target := r.URL.Query().Get("scope"), r.URL.Query().Get("target")
+	status, err := fh.store.ClearFeatureFlag(name, scope, target)
+	if !fh.answered(w, status, err) {
+		return
+	}
+	logger.Infof("Feature flag %s override for %s %s cleared by %s", name, scope, target, Author(r))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// answered writes the error of a change to a flag, and reports whether the
+// change went through. An unknown flag or override answers 404, an invalid
+// one 400 and a failed save 500
+func (fh *FeatureFlagHandlers) answered(w http.ResponseWriter, status *flags.Status, err error) bool {
+	switch {
+	case errors.Is(err, flags.ErrUnknownFlag), errors.Is(err, flags.ErrNoOverride):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return false
+	case status == nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	case err != nil:
+		http.Error(w, fmt.Sprintf("Failed to save feature flags: %v", err), http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// RegisterRoutes adds the feature flag routes to router
+func (fh *FeatureFlagHandlers) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/flags", fh.List).Methods("GET")
+	router.HandleFunc("/admin/flags/evaluate", fh.Evaluate).Methods("GET")
+	router.HandleFunc("/admin/flags/{flag}", fh.Get).Methods("GET")
+	router.HandleFunc("/admin/flags/{flag}", fh.Set).Methods("PUT")
+	router.HandleFunc("/admin/flags/{flag}", fh.Clear).Methods("DELETE")
+}
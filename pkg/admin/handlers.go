@@ -1,13 +1,14 @@
 package admin
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/analytics"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/health"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 )
@@ -16,6 +17,8 @@ import (
 type AdminHandlers struct {
 	logger          *logrus.Logger
 	analyticsEngine *analytics.AnalyticsEngine
+	readiness       func(ctx context.Context) *health.Report
+	startTime       time.Time
 }
 
 // NewAdminHandlers creates a new AdminHandlers instance
@@ -23,9 +26,15 @@ func NewAdminHandlers(logger *logrus.Logger, analyticsEngine *analytics.Analytic
 	return &AdminHandlers{
 		logger:          logger,
 		analyticsEngine: analyticsEngine,
+		startTime:       time.Now(),
 	}
 }
 
+// SetReadiness sets the dependency checks the health endpoint reports
+func (ah *AdminHandlers) SetReadiness(readiness func(ctx context.Context) *health.Report) {
+	ah.readiness = readiness
+}
+
 // GetSystemMetrics returns system performance metrics
 func (ah *AdminHandlers) GetSystemMetrics(w http.ResponseWriter, r *http.Request) {
 	metrics := ah.analyticsEngine.GetSystemMetrics()
@@ -58,22 +67,25 @@ func (ah *AdminHandlers) GetProviderMetrics(w http.ResponseWriter, r *http.Reque
 	}
 }
 
-// GetCostAnalysis returns cost analysis data
+// sinceHours reads the "hours" query parameter as a window ending now, 24 hours by default
+func sinceHours(r *http.Request) time.Time {
+	hours := 24
+	if h, err := strconv.Atoi(r.URL.Query().Get("hours")); err == nil && h > 0 {
+		hours = h
+	}
+	return time.Now().Add(-time.Duration(hours) * time.Hour)
+}
+
+// GetCostAnalysis returns cost analysis data, of the requests carrying every
+// "tag" key:value filter
 func (ah *AdminHandlers) GetCostAnalysis(w http.ResponseWriter, r *http.Request) {
-	// Parse query parameters for time range
-	query := r.URL.Query()
-	hoursStr := query.Get("hours")
-	hours := 24 // default to 24 hours
-	
-	if hoursStr != "" {
-		if h, err := strconv.Atoi(hoursStr); err == nil && h > 0 {
-			hours = h
-		}
+	filter, err := analytics.ParseTagFilter(r.URL.Query()["tag"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
+	costAnalysis := ah.analyticsEngine.GetCostAnalysis(sinceHours(r), filter)
 
-	since := time.Now().Add(-time.Duration(hours) * time.Hour)
-	costAnalysis := ah.analyticsEngine.GetCostAnalysis(since)
-	
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(costAnalysis); err != nil {
 		ah.logger.Errorf("Failed to encode cost analysis: %v", err)
@@ -82,6 +94,38 @@ func (ah *AdminHandlers) GetCostAnalysis(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// GetTagBreakdown returns the traffic and cost of each value of the tag named
+// by the route over the last "hours" hours, of the requests carrying every
+// "tag" key:value filter
+func (ah *AdminHandlers) GetTagBreakdown(w http.ResponseWriter, r *http.Request) {
+	filter, err := analytics.ParseTagFilter(r.URL.Query()["tag"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	breakdown := ah.analyticsEngine.GetTagBreakdown(mux.Vars(r)["key"], sinceHours(r), filter)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(breakdown); err != nil {
+		ah.logger.Errorf("Failed to encode tag breakdown: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetSafetyReport returns the safety classification of responses over the
+// last "hours" hours, of the tenant named by "tenant" or of all tenants
+func (ah *AdminHandlers) GetSafetyReport(w http.ResponseWriter, r *http.Request) {
+	report := ah.analyticsEngine.GetSafetyReport(sinceHours(r), r.URL.Query().Get("tenant"))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		ah.logger.Errorf("Failed to encode safety report: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
 // GetProviderPerformance returns provider performance analysis
 func (ah *AdminHandlers) GetProviderPerformance(w http.ResponseWriter, r *http.Request) {
 	performance := ah.analyticsEngine.GetProviderPerformance()
@@ -94,15 +138,13 @@ func (ah *AdminHandlers) GetProviderPerformance(w http.ResponseWriter, r *http.R
 	}
 }
 
-// GetOptimizationInsights returns optimization recommendations
+// GetOptimizationInsights returns cost curves, failure clusters and
+// recommendations for the last "hours" hours. With summarize=true the
+// assistant model adds a prose summary
 func (ah *AdminHandlers) GetOptimizationInsights(w http.ResponseWriter, r *http.Request) {
-	insights, err := ah.analyticsEngine.GenerateInsights()
-	if err != nil {
-		ah.logger.Errorf("Failed to generate insights: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to generate insights: %v", err), http.StatusInternalServerError)
-		return
-	}
-	
+	summarize := r.URL.Query().Get("summarize") == "true"
+	insights := ah.analyticsEngine.GenerateInsights(r.Context(), sinceHours(r), summarize)
+
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(insights); err != nil {
 		ah.logger.Errorf("Failed to encode insights: %v", err)
@@ -111,17 +153,26 @@ func (ah *AdminHandlers) GetOptimizationInsights(w http.ResponseWriter, r *http.
 	}
 }
 
-// GetHealthStatus returns overall system health
+// GetHealthStatus returns overall system health with the outcome of each
+// dependency check, answering 503 when a check failed
 func (ah *AdminHandlers) GetHealthStatus(w http.ResponseWriter, r *http.Request) {
-	health := map[string]interface{}{
-		"status":    "healthy",
-		"timestamp": time.Now().Unix(),
+	report := health.NewReport()
+	if ah.readiness != nil {
+		report = ah.readiness(r.Context())
+	}
+	status := map[string]interface{}{
+		"status":    report.Status,
+		"timestamp": report.Timestamp,
 		"version":   "1.0.0",
-		"uptime":    time.Since(time.Now().Add(-24 * time.Hour)).String(), // placeholder
+		"uptime":    time.Since(ah.startTime).String(),
+		"checks":    report.Checks,
 	}
-	
+
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(health); err != nil {
+	if !report.Ready() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(status); err != nil {
 		ah.logger.Errorf("Failed to encode health status: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -136,6 +187,8 @@ func (ah *AdminHandlers) RegisterRoutes(router *mux.Router) {
 	adminRouter.HandleFunc("/metrics/provider/{id}", ah.GetProviderMetrics).Methods("GET")
 	adminRouter.HandleFunc("/analytics/cost", ah.GetCostAnalysis).Methods("GET")
 	adminRouter.HandleFunc("/analytics/performance", ah.GetProviderPerformance).Methods("GET")
+	adminRouter.HandleFunc("/analytics/safety", ah.GetSafetyReport).Methods("GET")
+	adminRouter.HandleFunc("/analytics/tags/{key}", ah.GetTagBreakdown).Methods("GET")
 	adminRouter.HandleFunc("/insights", ah.GetOptimizationInsights).Methods("GET")
 	adminRouter.HandleFunc("/health", ah.GetHealthStatus).Methods("GET")
 }
\ No newline at end of file
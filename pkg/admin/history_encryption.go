@@ -0,0 +1,53 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/envelope"
+	"github.com/gorilla/mux"
+)
+
+// HistoryRewrapper rewraps encrypted request history after a key rotation
+type HistoryRewrapper interface {
+	RewrapRequestHistory() (int, error)
+}
+
+// RewrapResult reports how many stored requests were rewrapped
+type RewrapResult struct {
+	Rewrapped int `json:"rewrapped"`
+}
+
+// HistoryEncryptionHandlers serves history key rotation
+type HistoryEncryptionHandlers struct {
+	rewrapper HistoryRewrapper
+}
+
+// NewHistoryEncryptionHandlers creates handlers for rewrapper
+func NewHistoryEncryptionHandlers(rewrapper HistoryRewrapper) *HistoryEncryptionHandlers {
+	return &HistoryEncryptionHandlers{rewrapper: rewrapper}
+}
+
+// Rewrap moves the stored request history onto the current keys
+func (hh *HistoryEncryptionHandlers) Rewrap(w http.ResponseWriter, r *http.Request) {
+	rewrapped, err := hh.rewrapper.RewrapRequestHistory()
+	if errors.Is(err, envelope.ErrNotConfigured) {
+		http.Error(w, "Request history is not encrypted; set METRICS_DB_PATH and HISTORY_ENCRYPTION", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to rewrap request history after %d requests: %v", rewrapped, err), http.StatusInternalServerError)
+		return
+	}
+	logger.Infof("Rewrapped %d stored requests", rewrapped)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RewrapResult{Rewrapped: rewrapped})
+}
+
+// RegisterRoutes adds the history encryption routes to router
+func (hh *HistoryEncryptionHandlers) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/history/rewrap", hh.Rewrap).Methods("POST")
+}
@@ -0,0 +1,88 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/incident"
+	"github.com/gorilla/mux"
+)
+
+// IncidentSource is the part of the enhanced system that keeps provider
+// incidents
+type IncidentSource interface {
+	Incidents(query incident.Query) []incident.Incident
+	Incident(id int64) (*incident.Incident, error)
+}
+
+// IncidentHandlers serve the provider outages recorded as incidents
+type IncidentHandlers struct {
+	source IncidentSource
+}
+
+// NewIncidentHandlers creates handlers reading the incidents of source
+func NewIncidentHandlers(source IncidentSource) *IncidentHandlers {
+	return &IncidentHandlers{source: source}
+}
+
+// IncidentList is the body returned by GET /admin/incidents
+type IncidentList struct {
+	Incidents []incident.Incident `json:"incidents"`
+}
+
+// ListIncidents returns the incidents, newest first and without their
+// timelines, filtered by ?provider=, ?status= and ?since=
+func (ih *IncidentHandlers) ListIncidents(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := incident.Query{
+		Provider: params.Get("provider"),
+		Status:   params.Get("status"),
+	}
+	switch query.Status {
+	case "", incident.StatusOpen, incident.StatusResolved:
+	default:
+		http.Error(w, "status must be open or resolved", http.StatusBadRequest)
+		return
+	}
+	if value := params.Get("since"); value != "" {
+		since, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			http.Error(w, "since must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		query.Since = since
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(IncidentList{Incidents: ih.source.Incidents(query)})
+}
+
+// GetIncident returns one incident with its timeline
+func (ih *IncidentHandlers) GetIncident(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "incident id must be a number", http.StatusBadRequest)
+		return
+	}
+	found, err := ih.source.Incident(id)
+	if errors.Is(err, incident.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(found)
+}
+
+// RegisterRoutes adds the incident routes to router
+func (ih *IncidentHandlers) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/incidents", ih.ListIncidents).Methods("GET")
+	router.HandleFunc("/admin/incidents/{id}", ih.GetIncident).Methods("GET")
+}
@@ -0,0 +1,55 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/learned"
+	"github.com/gorilla/mux"
+)
+
+// LearnedStateStore exports and imports what the gateway learned at runtime
+type LearnedStateStore interface {
+	ExportLearnedState() *learned.State
+	ImportLearnedState(state *learned.State) *learned.ImportReport
+}
+
+// LearnedStateHandlers serves learned state export and import
+type LearnedStateHandlers struct {
+	store LearnedStateStore
+}
+
+// NewLearnedStateHandlers creates handlers for store
+func NewLearnedStateHandlers(store LearnedStateStore) *LearnedStateHandlers {
+	return &LearnedStateHandlers{store: store}
+}
+
+// Export downloads a snapshot of the learned state
+func (lh *LearnedStateHandlers) Export(w http.ResponseWriter, r *http.Request) {
+	state := lh.store.ExportLearnedState()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="learned-state-%s.json"`, state.CreatedAt.UTC().Format("20060102-150405")))
+	json.NewEncoder(w).Encode(state)
+}
+
+// Import restores a snapshot exported by this or another environment
+func (lh *LearnedStateHandlers) Import(w http.ResponseWriter, r *http.Request) {
+	state, err := learned.Decode(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	report := lh.store.ImportLearnedState(state)
+	logger.Infof("Imported learned state saved %s: %d providers, %d token calibrations", state.CreatedAt.Format(time.RFC3339), report.Providers, report.Calibrations)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// RegisterRoutes adds the learned state routes to router
+func (lh *LearnedStateHandlers) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/learned-state", lh.Export).Methods("GET")
+	router.HandleFunc("/admin/learned-state", lh.Import).Methods("PUT")
+}
@@ -0,0 +1,83 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/logging"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// LogLevelHandlers exposes runtime adjustment of per-module log levels
+type LogLevelHandlers struct {
+	registry *logging.Registry
+	logger   *logrus.Logger
+	// changesAllowed enables PUT; debug logs can hold request payloads, so
+	// levels are read-only unless the server turns changes on
+	changesAllowed bool
+}
+
+// NewLogLevelHandlers creates handlers operating on registry
+func NewLogLevelHandlers(registry *logging.Registry, logger *logrus.Logger) *LogLevelHandlers {
+	return &LogLevelHandlers{
+		registry: registry,
+		logger:   logger,
+	}
+}
+
+// SetChangesAllowed enables changing log levels through PUT /admin/log-level
+func (lh *LogLevelHandlers) SetChangesAllowed(allowed bool) {
+	lh.changesAllowed = allowed
+}
+
+// LogLevelUpdate is the body accepted by PUT /admin/log-level. An empty Level
+// resets the module to the default level
+type LogLevelUpdate struct {
+	Module string `json:"module"`
+	Level  string `json:"level"`
+}
+
+// GetLogLevels returns the effective level of every module
+func (lh *LogLevelHandlers) GetLogLevels(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lh.registry.Levels())
+}
+
+// SetLogLevel changes the level of one module, or the default level
+func (lh *LogLevelHandlers) SetLogLevel(w http.ResponseWriter, r *http.Request) {
+	if !lh.changesAllowed {
+		http.Error(w, "Changing log levels is disabled on this server", http.StatusForbidden)
+		return
+	}
+
+	var update LogLevelUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case update.Level == "" && (update.Module == "" || update.Module == logging.DefaultModule):
+		http.Error(w, "level is required for the default module", http.StatusBadRequest)
+		return
+	case update.Level == "":
+		lh.registry.ResetLevel(update.Module)
+	default:
+		level, err := logrus.ParseLevel(update.Level)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		lh.registry.SetLevel(update.Module, level)
+	}
+
+	lh.logger.Infof("Log level of %q changed to %q", update.Module, update.Level)
+	lh.GetLogLevels(w, r)
+}
+
+// RegisterRoutes registers the log level routes under /admin
+func (lh *LogLevelHandlers) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/log-level", lh.GetLogLevels).Methods("GET")
+	router.HandleFunc("/admin/log-level", lh.SetLogLevel).Methods("PUT")
+}
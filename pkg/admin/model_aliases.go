@@ -0,0 +1,42 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/selection"
+	"github.com/gorilla/mux"
+)
+
+// ModelAliasHandlers reports the model alias table and how often clients
+// still request aliased or deprecated models
+type ModelAliasHandlers struct {
+	aliases *selection.ModelAliases
+}
+
+// NewModelAliasHandlers creates handlers reporting on aliases
+func NewModelAliasHandlers(aliases *selection.ModelAliases) *ModelAliasHandlers {
+	return &ModelAliasHandlers{aliases: aliases}
+}
+
+// ModelAliasReport is the body returned by GET /admin/model-aliases
+type ModelAliasReport struct {
+	Aliases []selection.ModelAlias `json:"aliases"`
+	Usage   []selection.AliasUsage `json:"usage"`
+}
+
+// GetModelAliases returns the alias table and per-alias usage, most used first
+func (mh *ModelAliasHandlers) GetModelAliases(w http.ResponseWriter, r *http.Request) {
+	report := ModelAliasReport{
+		Aliases: mh.aliases.Aliases(),
+		Usage:   mh.aliases.Usage(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// RegisterRoutes registers the model alias routes under /admin
+func (mh *ModelAliasHandlers) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/model-aliases", mh.GetModelAliases).Methods("GET")
+}
@@ -0,0 +1,88 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/onboard"
+	"github.com/gorilla/mux"
+)
+
+// OnboardingHandlers serve the provider onboarding wizard: a proposal is
+// drafted from a base URL, reviewed, then confirmed or discarded
+type OnboardingHandlers struct {
+	wizard *onboard.Wizard
+}
+
+// NewOnboardingHandlers creates handlers for wizard
+func NewOnboardingHandlers(wizard *onboard.Wizard) *OnboardingHandlers {
+	return &OnboardingHandlers{wizard: wizard}
+}
+
+// ProposeProvider detects, discovers and probes a provider and returns the
+// configuration it would be added with
+func (oh *OnboardingHandlers) ProposeProvider(w http.ResponseWriter, r *http.Request) {
+	var body onboard.Request
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	proposal, err := oh.wizard.Propose(r.Context(), body)
+	if err != nil {
+		writeOnboardingError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(proposal)
+}
+
+// ConfirmProvider writes a proposal to the provider CSV and YAML directory
+func (oh *OnboardingHandlers) ConfirmProvider(w http.ResponseWriter, r *http.Request) {
+	commit, err := oh.wizard.Confirm(mux.Vars(r)["id"], Author(r))
+	if err != nil {
+		writeOnboardingError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(commit)
+}
+
+// DiscardProvider drops a proposal without writing it
+func (oh *OnboardingHandlers) DiscardProvider(w http.ResponseWriter, r *http.Request) {
+	if err := oh.wizard.Discard(mux.Vars(r)["id"]); err != nil {
+		writeOnboardingError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RegisterRoutes adds the onboarding routes to router
+func (oh *OnboardingHandlers) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/providers/onboard", oh.ProposeProvider).Methods("POST")
+	router.HandleFunc("/admin/providers/onboard/{id}/confirm", oh.ConfirmProvider).Methods("POST")
+	router.HandleFunc("/admin/providers/onboard/{id}", oh.DiscardProvider).Methods("DELETE")
+}
+
+// writeOnboardingError maps wizard errors to status codes
+func writeOnboardingError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, onboard.ErrInvalidRequest):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, onboard.ErrUnknownProposal):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, config.ErrProviderExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, onboard.ErrRejected):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, onboard.ErrUnreachable):
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,48 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/gorilla/mux"
+)
+
+// pprofPath is where the runtime profiles are served
+const pprofPath = "/admin/debug/pprof"
+
+// ProfilingHandlers serve the runtime profiles of net/http/pprof under
+// /admin/debug/pprof. Profiles reveal memory contents and a CPU profile or
+// trace slows the server while it runs, so with roles in use only the admin
+// role may fetch them, even though they are read with GET
+type ProfilingHandlers struct{}
+
+// NewProfilingHandlers creates the profiling handlers
+func NewProfilingHandlers() *ProfilingHandlers {
+	return &ProfilingHandlers{}
+}
+
+// Profile serves the named profile, such as heap, goroutine or allocs
+func (ph *ProfilingHandlers) Profile(w http.ResponseWriter, r *http.Request) {
+	pprof.Handler(mux.Vars(r)["profile"]).ServeHTTP(w, r)
+}
+
+// requireAdmin answers 403 to callers whose role is not admin
+func (ph *ProfilingHandlers) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if role := RoleFromContext(r.Context()); role != "" && role != RoleAdmin {
+			http.Error(w, "Profiling requires the admin role", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// RegisterRoutes registers the profiling routes
+func (ph *ProfilingHandlers) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc(pprofPath+"/", ph.requireAdmin(pprof.Index)).Methods("GET")
+	router.HandleFunc(pprofPath+"/cmdline", ph.requireAdmin(pprof.Cmdline)).Methods("GET")
+	router.HandleFunc(pprofPath+"/profile", ph.requireAdmin(pprof.Profile)).Methods("GET")
+	router.HandleFunc(pprofPath+"/symbol", ph.requireAdmin(pprof.Symbol)).Methods("GET", "POST")
+	router.HandleFunc(pprofPath+"/trace", ph.requireAdmin(pprof.Trace)).Methods("GET")
+	router.HandleFunc(pprofPath+"/{profile}", ph.requireAdmin(ph.Profile)).Methods("GET")
+}
@@ -0,0 +1,149 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/middleware"
+)
+
+// Role is what a caller may do through the admin API
+type Role string
+
+// Admin roles, from least to most privileged
+const (
+	// RoleViewer may read everything but change nothing
+	RoleViewer Role = "viewer"
+	// RoleAdmin may do everything
+	RoleAdmin Role = "admin"
+)
+
+// ParseRole checks that name is a known role
+func ParseRole(name string) (Role, error) {
+	switch role := Role(name); role {
+	case RoleViewer, RoleAdmin:
+		return role, nil
+	}
+	return "", fmt.Errorf("unknown role %q, must be %s or %s", name, RoleViewer, RoleAdmin)
+}
+
+// rank orders roles by privilege; unknown roles rank lowest
+func (r Role) rank() int {
+	switch r {
+	case RoleViewer:
+		return 1
+	case RoleAdmin:
+		return 2
+	}
+	return 0
+}
+
+// Allows reports whether the role may make requests with method
+func (r Role) Allows(method string) bool {
+	switch r {
+	case RoleAdmin:
+		return true
+	case RoleViewer:
+		return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+	}
+	return false
+}
+
+// roleContextKey is the context key for the caller's role
+type roleContextKey struct{}
+
+// WithRole returns a copy of ctx carrying the caller's role
+func WithRole(ctx context.Context, role Role) context.Context {
+	return context.WithValue(ctx, roleContextKey{}, role)
+}
+
+// RoleFromContext returns the caller's role, or "" if it has none
+func RoleFromContext(ctx context.Context) Role {
+	if ctx == nil {
+		return ""
+	}
+	role, _ := ctx.Value(roleContextKey{}).(Role)
+	return role
+}
+
+// AccessControl enforces roles on the admin routes. People get their role
+// from an SSO session; machines get the role granted to their API key
+type AccessControl struct {
+	keyIDs   []string
+	keyRoles []Role
+	mutex    sync.RWMutex
+}
+
+// NewAccessControl grants roles to API keys, mapped from key ID to role name
+func NewAccessControl(keyRoles map[string]string) (*AccessControl, error) {
+	ac := &AccessControl{}
+	for keyID, name := range keyRoles {
+		role, err := ParseRole(name)
+		if err != nil {
+			return nil, fmt.Errorf("admin key %s: %w", keyID, err)
+		}
+		ac.keyIDs = append(ac.keyIDs, keyID)
+		ac.keyRoles = append(ac.keyRoles, role)
+	}
+	return ac, nil
+}
+
+// Replace takes over the key roles of other
+func (ac *AccessControl) Replace(other *AccessControl) {
+	ac.mutex.Lock()
+	defer ac.mutex.Unlock()
+	ac.keyIDs, ac.keyRoles = other.keyIDs, other.keyRoles
+}
+
+// keyRole returns the role of keyID, comparing every key ID in constant time
+func (ac *AccessControl) keyRole(keyID string) Role {
+	if keyID == "" {
+		return ""
+	}
+	ac.mutex.RLock()
+	defer ac.mutex.RUnlock()
+	var role Role
+	for i, candidate := range ac.keyIDs {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(keyID)) == 1 {
+			role = ac.keyRoles[i]
+		}
+	}
+	return role
+}
+
+// Middleware answers 401 to admin requests from callers without a role and
+// 403 to those whose role does not allow the request. The SSO routes are
+// left to handle sign-in themselves. It goes on the router serving the
+// admin routes, after the SSO middleware
+func (ac *AccessControl) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isAdminPath(r.URL.Path) || strings.HasPrefix(r.URL.Path, ssoPathPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		role := RoleFromContext(r.Context())
+		if role == "" {
+			role = ac.keyRole(middleware.KeyID(r))
+		}
+		if role == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="pal-moe-admin"`)
+			http.Error(w, "Admin access requires an admin API key or an SSO session", http.StatusUnauthorized)
+			return
+		}
+		if !role.Allows(r.Method) {
+			http.Error(w, fmt.Sprintf("Role %s cannot make changes", role), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithRole(r.Context(), role)))
+	})
+}
+
+// isAdminPath reports whether path is one of the /admin routes
+func isAdminPath(path string) bool {
+	return path == "/admin" || strings.HasPrefix(path, "/admin/")
+}
@@ -0,0 +1,48 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/reload"
+	"github.com/gorilla/mux"
+)
+
+// ReloadHandlers reload the configuration without a restart
+type ReloadHandlers struct {
+	reloader *reload.Reloader
+}
+
+// NewReloadHandlers creates handlers for reloader
+func NewReloadHandlers(reloader *reload.Reloader) *ReloadHandlers {
+	return &ReloadHandlers{reloader: reloader}
+}
+
+// Reload re-reads every section and applies the changes when all are valid.
+// It answers 422 with the report when a section is invalid and nothing was
+// applied
+func (rh *ReloadHandlers) Reload(w http.ResponseWriter, r *http.Request) {
+	report := rh.reloader.Reload(r.Context(), "api")
+	w.Header().Set("Content-Type", "application/json")
+	if !report.Applied {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// Last returns the report of the latest reload, by signal or through the API
+func (rh *ReloadHandlers) Last(w http.ResponseWriter, r *http.Request) {
+	report := rh.reloader.Last()
+	if report == nil {
+		http.Error(w, "No reload has run yet", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// RegisterRoutes adds the reload routes to router
+func (rh *ReloadHandlers) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/reload", rh.Reload).Methods("POST")
+	router.HandleFunc("/admin/reload", rh.Last).Methods("GET")
+}
@@ -0,0 +1,91 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/reporting"
+	"github.com/gorilla/mux"
+)
+
+// ReportHandlers lists, generates and exports traffic reports
+type ReportHandlers struct {
+	scheduler *reporting.Scheduler
+}
+
+// NewReportHandlers creates handlers for the reports kept by scheduler
+func NewReportHandlers(scheduler *reporting.Scheduler) *ReportHandlers {
+	return &ReportHandlers{scheduler: scheduler}
+}
+
+// ReportList is the body returned by GET /admin/reports
+type ReportList struct {
+	Schedule []reporting.Period        `json:"schedule"`
+	Reports  []reporting.ReportSummary `json:"reports"`
+}
+
+// ListReports returns the stored reports, newest first
+func (rh *ReportHandlers) ListReports(w http.ResponseWriter, r *http.Request) {
+	list := ReportList{
+		Schedule: rh.scheduler.Periods(),
+		Reports:  rh.scheduler.Store().List(),
+	}
+	if list.Schedule == nil {
+		list.Schedule = []reporting.Period{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// GenerateReport builds a report for the period ending now, daily unless
+// ?period=weekly. ?deliver=true also sends it through the configured deliveries
+func (rh *ReportHandlers) GenerateReport(w http.ResponseWriter, r *http.Request) {
+	period := reporting.Daily
+	if value := r.URL.Query().Get("period"); value != "" {
+		parsed, err := reporting.ParsePeriod(value)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		period = parsed
+	}
+
+	to := time.Now()
+	report, err := rh.scheduler.Generate(r.Context(), period, to.Add(-period.Duration()), to, r.URL.Query().Get("deliver") == "true")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to generate report: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(report)
+}
+
+// DownloadReport exports a stored report as ?format=json (default), csv or html
+func (rh *ReportHandlers) DownloadReport(w http.ResponseWriter, r *http.Request) {
+	format, err := reporting.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	report, ok := rh.scheduler.Store().Get(mux.Vars(r)["id"])
+	if !ok {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", format.FileName(report)))
+	reporting.Render(w, report, format)
+}
+
+// RegisterRoutes registers the report routes under /admin
+func (rh *ReportHandlers) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/reports", rh.ListReports).Methods("GET")
+	router.HandleFunc("/admin/reports", rh.GenerateReport).Methods("POST")
+	router.HandleFunc("/admin/reports/{id}", rh.DownloadReport).Methods("GET")
+}
@@ -0,0 +1,72 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/retention"
+	"github.com/gorilla/mux"
+)
+
+// DataRetention expires and erases stored request data
+type DataRetention interface {
+	RetentionPolicy() retention.Policy
+	LastPurge() *retention.PurgeReport
+	EraseData(selector retention.Selector, requestedBy string) (*retention.Receipt, error)
+}
+
+// RetentionStatus is the retention in effect and what its last purge removed
+type RetentionStatus struct {
+	Policy    retention.Policy       `json:"policy"`
+	LastPurge *retention.PurgeReport `json:"last_purge,omitempty"`
+}
+
+// RetentionHandlers serves the retention policy and data erasure
+type RetentionHandlers struct {
+	store DataRetention
+}
+
+// NewRetentionHandlers creates handlers for store
+func NewRetentionHandlers(store DataRetention) *RetentionHandlers {
+	return &RetentionHandlers{store: store}
+}
+
+// GetRetention returns the retention policy and the outcome of the last purge
+func (rh *RetentionHandlers) GetRetention(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RetentionStatus{
+		Policy:    rh.store.RetentionPolicy(),
+		LastPurge: rh.store.LastPurge(),
+	})
+}
+
+// Erase deletes the stored data of one key, session or request and returns
+// the erasure receipt
+func (rh *RetentionHandlers) Erase(w http.ResponseWriter, r *http.Request) {
+	var selector retention.Selector
+	if err := json.NewDecoder(r.Body).Decode(&selector); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	receipt, err := rh.store.EraseData(selector, Author(r))
+	if errors.Is(err, retention.ErrInvalidSelector) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to erase data: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(receipt)
+}
+
+// RegisterRoutes adds the retention routes to router
+func (rh *RetentionHandlers) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/retention", rh.GetRetention).Methods("GET")
+	router.HandleFunc("/admin/erasures", rh.Erase).Methods("POST")
+}
@@ -0,0 +1,55 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/simulation"
+	"github.com/gorilla/mux"
+)
+
+// Simulator replays request history through a proposed configuration
+type Simulator interface {
+	Simulate(ctx context.Context, proposal simulation.Proposal) (*simulation.Report, error)
+}
+
+// SimulationHandlers serves routing simulations
+type SimulationHandlers struct {
+	simulator Simulator
+}
+
+// NewSimulationHandlers creates handlers for simulator
+func NewSimulationHandlers(simulator Simulator) *SimulationHandlers {
+	return &SimulationHandlers{simulator: simulator}
+}
+
+// Simulate projects the cost, latency and tier mix of the proposal posted
+// against the traffic actually served. Nothing is applied
+func (sh *SimulationHandlers) Simulate(w http.ResponseWriter, r *http.Request) {
+	proposal, err := simulation.Decode(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := sh.simulator.Simulate(r.Context(), *proposal)
+	if errors.Is(err, simulation.ErrInvalidProposal) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to simulate: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// RegisterRoutes adds the simulation route to router
+func (sh *SimulationHandlers) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/simulate", sh.Simulate).Methods("POST")
+}
@@ -0,0 +1,55 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/sla"
+	"github.com/gorilla/mux"
+)
+
+// SLAHandlers serve provider SLA targets and monthly scorecards
+type SLAHandlers struct {
+	tracker *sla.Tracker
+}
+
+// NewSLAHandlers creates handlers for the scorecards kept by tracker
+func NewSLAHandlers(tracker *sla.Tracker) *SLAHandlers {
+	return &SLAHandlers{tracker: tracker}
+}
+
+// SLAStatus is the body returned by GET /admin/sla
+type SLAStatus struct {
+	Targets []sla.Target `json:"targets"`
+	// Months have a scorecard, newest first
+	Months []string `json:"months"`
+}
+
+// GetSLA returns the targets and the months with a scorecard
+func (sh *SLAHandlers) GetSLA(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SLAStatus{
+		Targets: sh.tracker.Targets(),
+		Months:  sh.tracker.Months(),
+	})
+}
+
+// GetScorecard returns the scorecard of a month, e.g. 2024-05. The current
+// month is scored to date
+func (sh *SLAHandlers) GetScorecard(w http.ResponseWriter, r *http.Request) {
+	month, err := sla.ParseMonth(mux.Vars(r)["month"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sh.tracker.Scorecard(month, time.Now()))
+}
+
+// RegisterRoutes adds the SLA routes to router
+func (sh *SLAHandlers) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/admin/sla", sh.GetSLA).Methods("GET")
+	router.HandleFunc("/admin/sla/scorecards/{month}", sh.GetScorecard).Methods("GET")
+}
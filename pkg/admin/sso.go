@@ -0,0 +1,362 @@
+package admin
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/audit"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/middleware"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/oidc"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/requestid"
+	"github.com/gorilla/mux"
+)
+
+// ssoPathPrefix starts the sign-in routes, which need no role
+const ssoPathPrefix = "/admin/sso/"
+
+// SSO defaults
+const (
+	DefaultSessionTTL = 8 * time.Hour
+	minSessionSecret  = 32
+	loginTTL          = 10 * time.Minute
+	sessionCookie     = "pal_admin_session"
+	loginCookie       = "pal_admin_login"
+	defaultReturnTo   = "/admin/sso/session"
+)
+
+// Session is an operator signed in through SSO
+type Session struct {
+	Subject   string `json:"sub"`
+	Email     string `json:"email,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Role      Role   `json:"role"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Actor is who admin actions of the session are attributed to
+func (s *Session) Actor() string {
+	if s.Email != "" {
+		return "sso:" + s.Email
+	}
+	return "sso:" + s.Subject
+}
+
+// loginState ties the provider's callback to the browser that started the
+// login
+type loginState struct {
+	State     string `json:"state"`
+	Nonce     string `json:"nonce"`
+	Verifier  string `json:"verifier"`
+	ReturnTo  string `json:"return_to"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// SSO signs operators in with an OpenID Connect provider and keeps them
+// signed in with a session cookie. IdP groups, or email addresses, map to
+// roles
+type SSO struct {
+	provider *oidc.Provider
+	secret   []byte
+	ttl      time.Duration
+	roles    map[Role][]string
+	origin   string
+	secure   bool
+	auditLog audit.Log
+}
+
+// NewSSO creates sign-in through provider. Sessions are signed with secret,
+// which replicas must share, and last ttl, DefaultSessionTTL when zero.
+// roles maps role names to the groups and email addresses granted them;
+// redirectURL is the provider's redirect URL, whose origin mutating
+// requests of sessions must come from
+func NewSSO(provider *oidc.Provider, secret string, ttl time.Duration, roles map[string][]string, redirectURL string) (*SSO, error) {
+	if len(secret) < minSessionSecret {
+		return nil, fmt.Errorf("session secret must be at least %d characters", minSessionSecret)
+	}
+	if ttl <= 0 {
+		ttl = DefaultSessionTTL
+	}
+	redirect, err := url.Parse(redirectURL)
+	if err != nil || redirect.Scheme == "" || redirect.Host == "" {
+		return nil, fmt.Errorf("redirect URL %q must be absolute", redirectURL)
+	}
+	if redirect.Path != ssoPathPrefix+"callback" {
+		return nil, fmt.Errorf("redirect URL %q must end in %scallback", redirectURL, ssoPathPrefix)
+	}
+
+	sso := &SSO{
+		provider: provider,
+		secret:   []byte(secret),
+		ttl:      ttl,
+		roles:    make(map[Role][]string),
+		origin:   redirect.Scheme + "://" + redirect.Host,
+		secure:   redirect.Scheme == "https",
+	}
+	for name, members := range roles {
+		role, err := ParseRole(name)
+		if err != nil {
+			return nil, err
+		}
+		sso.roles[role] = members
+	}
+	if len(sso.roles) == 0 {
+		return nil, fmt.Errorf("no roles are mapped; nobody could sign in")
+	}
+	return sso, nil
+}
+
+// SetAuditLog records sign-ins, successful or not, in log
+func (s *SSO) SetAuditLog(log audit.Log) {
+	s.auditLog = log
+}
+
+// roleFor returns the most privileged role granted to identity's groups or
+// email address, or "" if none is
+func (s *SSO) roleFor(identity *oidc.Identity) Role {
+	var granted Role
+	for role, members := range s.roles {
+		if role.rank() <= granted.rank() {
+			continue
+		}
+		for _, member := range members {
+			if slices.Contains(identity.Groups, member) || identity.Email != "" && strings.EqualFold(identity.Email, member) {
+				granted = role
+				break
+			}
+		}
+	}
+	return granted
+}
+
+// Middleware signs in admin requests carrying a valid session cookie: they
+// act as the session's operator with its role, and need no API key. Requests
+// with an API key keep acting as the key. Mutating requests must come from
+// the gateway's own origin, so other sites cannot ride on the cookie. It
+// runs after ClientKey and before the API key middleware
+func (s *SSO) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isAdminPath(r.URL.Path) || middleware.Credential(r) != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		session := s.session(r)
+		if session == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !RoleViewer.Allows(r.Method) {
+			if origin := r.Header.Get("Origin"); origin != "" && origin != s.origin {
+				http.Error(w, "Cross-origin admin requests are not allowed", http.StatusForbidden)
+				return
+			}
+		}
+
+		ctx := middleware.WithKeyID(r.Context(), session.Actor())
+		ctx = middleware.WithAuthenticated(ctx)
+		ctx = WithRole(ctx, session.Role)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// session returns the valid session of r's cookie, or nil
+func (s *SSO) session(r *http.Request) *Session {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return nil
+	}
+	var session Session
+	if err := s.open(cookie.Value, &session); err != nil || time.Now().Unix() >= session.ExpiresAt {
+		return nil
+	}
+	if _, err := ParseRole(string(session.Role)); err != nil {
+		return nil
+	}
+	return &session
+}
+
+// Login redirects to the provider's login page; after signing in the
+// operator is sent to ?return_to=, a path on this gateway
+func (s *SSO) Login(w http.ResponseWriter, r *http.Request) {
+	returnTo := r.URL.Query().Get("return_to")
+	// Only local paths, or the login would be an open redirect
+	if !strings.HasPrefix(returnTo, "/") || strings.HasPrefix(returnTo, "//") || strings.Contains(returnTo, `\`) {
+		returnTo = defaultReturnTo
+	}
+	state, nonce, verifier, err := oidc.NewLoginSecrets()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to start login: %v", err), http.StatusInternalServerError)
+		return
+	}
+	login := loginState{
+		State:     state,
+		Nonce:     nonce,
+		Verifier:  verifier,
+		ReturnTo:  returnTo,
+		ExpiresAt: time.Now().Add(loginTTL).Unix(),
+	}
+	value, err := s.seal(login)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to start login: %v", err), http.StatusInternalServerError)
+		return
+	}
+	s.setCookie(w, loginCookie, value, ssoPathPrefix, loginTTL)
+	http.Redirect(w, r, s.provider.AuthURL(state, nonce, verifier), http.StatusFound)
+}
+
+// Callback finishes a login: it checks the provider's answer, maps the
+// operator's groups to a role and starts the session
+func (s *SSO) Callback(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	var login loginState
+	cookie, err := r.Cookie(loginCookie)
+	if err != nil || s.open(cookie.Value, &login) != nil || time.Now().Unix() >= login.ExpiresAt {
+		http.Error(w, "Login expired or was started elsewhere; sign in again", http.StatusBadRequest)
+		return
+	}
+	s.setCookie(w, loginCookie, "", ssoPathPrefix, -1)
+	if !hmac.Equal([]byte(params.Get("state")), []byte(login.State)) {
+		http.Error(w, "Login state mismatch; sign in again", http.StatusBadRequest)
+		return
+	}
+	if reason := params.Get("error"); reason != "" {
+		s.recordLogin(r, "anonymous", http.StatusUnauthorized)
+		http.Error(w, fmt.Sprintf("Sign-in failed: %s %s", reason, params.Get("error_description")), http.StatusUnauthorized)
+		return
+	}
+
+	identity, err := s.provider.Exchange(r.Context(), params.Get("code"), login.Verifier, login.Nonce)
+	if err != nil {
+		logger.Warnf("SSO sign-in failed: %v", err)
+		s.recordLogin(r, "anonymous", http.StatusUnauthorized)
+		status := http.StatusBadGateway
+		if errors.Is(err, oidc.ErrInvalidToken) {
+			status = http.StatusUnauthorized
+		}
+		http.Error(w, "Sign-in failed", status)
+		return
+	}
+	session := Session{
+		Subject:   identity.Subject,
+		Email:     identity.Email,
+		Name:      identity.Name,
+		Role:      s.roleFor(identity),
+		ExpiresAt: time.Now().Add(s.ttl).Unix(),
+	}
+	if session.Role == "" {
+		s.recordLogin(r, session.Actor(), http.StatusForbidden)
+		http.Error(w, "None of your groups grants access to the admin API", http.StatusForbidden)
+		return
+	}
+
+	value, err := s.seal(session)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to start session: %v", err), http.StatusInternalServerError)
+		return
+	}
+	s.setCookie(w, sessionCookie, value, "/admin", s.ttl)
+	s.recordLogin(r, session.Actor(), http.StatusFound)
+	logger.Infof("%s signed in to the admin API as %s", session.Actor(), session.Role)
+	http.Redirect(w, r, login.ReturnTo, http.StatusFound)
+}
+
+// Logout ends the session
+func (s *SSO) Logout(w http.ResponseWriter, r *http.Request) {
+	s.setCookie(w, sessionCookie, "", "/admin", -1)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetSession returns the signed-in operator and their role
+func (s *SSO) GetSession(w http.ResponseWriter, r *http.Request) {
+	session := s.session(r)
+	if session == nil {
+		http.Error(w, "Not signed in", http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(session)
+}
+
+// recordLogin adds a sign-in attempt to the audit log
+func (s *SSO) recordLogin(r *http.Request, actor string, status int) {
+	if s.auditLog == nil {
+		return
+	}
+	event := audit.Event{
+		Time:       time.Now(),
+		Actor:      actor,
+		Action:     r.Method + " " + ssoPathPrefix + "callback",
+		Resource:   r.URL.Path,
+		Success:    status < http.StatusBadRequest,
+		Status:     status,
+		RequestID:  requestid.FromContext(r.Context()),
+		RemoteAddr: r.RemoteAddr,
+	}
+	if err := s.auditLog.RecordAuditEvent(event); err != nil {
+		logger.Warnf("Failed to record sign-in of %s: %v", actor, err)
+	}
+}
+
+// setCookie sets a cookie scripts cannot read; a negative maxAge deletes it
+func (s *SSO) setCookie(w http.ResponseWriter, name, value, path string, maxAge time.Duration) {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		MaxAge:   int(maxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   s.secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if maxAge < 0 {
+		cookie.MaxAge = -1
+	}
+	http.SetCookie(w, cookie)
+}
+
+// seal encodes v as signed base64url JSON
+func (s *SSO) seal(v interface{}) (string, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + s.sign(encoded), nil
+}
+
+// open checks the signature of a sealed value and decodes it into v
+func (s *SSO) open(value string, v interface{}) error {
+	encoded, signature, ok := strings.Cut(value, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.sign(encoded))) {
+		return fmt.Errorf("invalid signature")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(payload, v)
+}
+
+// sign returns the MAC of encoded
+func (s *SSO) sign(encoded string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// RegisterRoutes adds the sign-in routes to router
+func (s *SSO) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc(ssoPathPrefix+"login", s.Login).Methods("GET")
+	router.HandleFunc(ssoPathPrefix+"callback", s.Callback).Methods("GET")
+	router.HandleFunc(ssoPathPrefix+"logout", s.Logout).Methods("POST")
+	router.HandleFunc(ssoPathPrefix+"session", s.GetSession).Methods("GET")
+}
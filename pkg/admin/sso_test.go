@@ -0,0 +1,260 @@
+package admin
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/oidc"
+	"github.com/gorilla/mux"
+)
+
+const (
+	testSessionSecret = "0123456789abcdef0123456789abcdef"
+	testRedirectURL   = "https://gateway.example.com/admin/sso/callback"
+	testClientID      = "pal-moe"
+)
+
+// testIdP is an identity provider whose token endpoint answers with an ID
+// token carrying claims, signed with key
+type testIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	claims map[string]interface{}
+}
+
+// newTestIdP starts an identity provider with one RSA signing key
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &testIdP{key: key}
+	router := http.NewServeMux()
+	router.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	router.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "key-1",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	router.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "key-1"})
+		payload, _ := json.Marshal(idp.claims)
+		signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+		digest := sha256.Sum256([]byte(signed))
+		signature, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		json.NewEncoder(w).Encode(map[string]string{"id_token": signed + "." + base64.RawURLEncoding.EncodeToString(signature)})
+	})
+	idp.server = httptest.NewServer(router)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// newTestSSO returns sign-in through idp granting admin to the platform
+// group, and a router serving the SSO routes and one admin route behind
+// the SSO and role checks
+func newTestSSO(t *testing.T, idp *testIdP) (*SSO, http.Handler) {
+	t.Helper()
+	provider, err := oidc.NewProvider(context.Background(), oidc.Config{
+		Issuer:      idp.server.URL,
+		ClientID:    testClientID,
+		RedirectURL: testRedirectURL,
+	})
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	sso, err := NewSSO(provider, testSessionSecret, time.Hour, map[string][]string{"admin": {"platform"}}, testRedirectURL)
+	if err != nil {
+		t.Fatalf("NewSSO: %v", err)
+	}
+	accessControl, err := NewAccessControl(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := mux.NewRouter()
+	router.Use(sso.Middleware, accessControl.Middleware)
+	sso.RegisterRoutes(router)
+	router.HandleFunc("/admin/stats", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(RoleFromContext(r.Context())))
+	})
+	return sso, router
+}
+
+// serve sends a request for target with cookies to handler
+func serve(handler http.Handler, method, target string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	for _, cookie := range cookies {
+		r.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+// cookie returns the cookie named name set by w
+func cookie(w *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+// signIn runs a login through idp, which answers with claims changed by
+// change, and returns the callback's response
+func signIn(t *testing.T, idp *testIdP, router http.Handler, change func(claims map[string]interface{})) *httptest.ResponseRecorder {
+	t.Helper()
+	login := serve(router, http.MethodGet, "/admin/sso/login?return_to=/admin/stats")
+	if login.Code != http.StatusFound {
+		t.Fatalf("login = %d, want %d", login.Code, http.StatusFound)
+	}
+	authURL, err := url.Parse(login.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := authURL.Query()
+
+	now := time.Now()
+	idp.claims = map[string]interface{}{
+		"iss":    idp.server.URL,
+		"aud":    testClientID,
+		"sub":    "user-1",
+		"email":  "ops@example.com",
+		"groups": []string{"platform"},
+		"nonce":  params.Get("nonce"),
+		"iat":    now.Unix(),
+		"exp":    now.Add(time.Hour).Unix(),
+	}
+	if change != nil {
+		change(idp.claims)
+	}
+	return serve(router, http.MethodGet, "/admin/sso/callback?code=code&state="+url.QueryEscape(params.Get("state")), cookie(login, loginCookie))
+}
+
+func TestSSOSignIn(t *testing.T) {
+	idp := newTestIdP(t)
+	_, router := newTestSSO(t, idp)
+
+	callback := signIn(t, idp, router, nil)
+	if callback.Code != http.StatusFound || callback.Header().Get("Location") != "/admin/stats" {
+		t.Fatalf("callback = %d to %q, want a redirect to /admin/stats", callback.Code, callback.Header().Get("Location"))
+	}
+	session := cookie(callback, sessionCookie)
+	if session == nil || !session.HttpOnly {
+		t.Fatal("callback set no HttpOnly session cookie")
+	}
+
+	w := serve(router, http.MethodGet, "/admin/stats", session)
+	if w.Code != http.StatusOK || w.Body.String() != string(RoleAdmin) {
+		t.Errorf("admin request with the session = %d %q, want 200 %q", w.Code, w.Body.String(), RoleAdmin)
+	}
+	if w := serve(router, http.MethodGet, "/admin/stats"); w.Code != http.StatusUnauthorized {
+		t.Errorf("admin request without a session = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestSSORejectsInvalidIDTokens(t *testing.T) {
+	idp := newTestIdP(t)
+	_, router := newTestSSO(t, idp)
+
+	tests := []struct {
+		name   string
+		change func(claims map[string]interface{})
+		want   int
+	}{
+		{"wrong issuer", func(claims map[string]interface{}) { claims["iss"] = "https://attacker.example.com" }, http.StatusUnauthorized},
+		{"wrong audience", func(claims map[string]interface{}) { claims["aud"] = "another-client" }, http.StatusUnauthorized},
+		{"expired", func(claims map[string]interface{}) { claims["exp"] = time.Now().Add(-time.Hour).Unix() }, http.StatusUnauthorized},
+		{"no role", func(claims map[string]interface{}) { claims["groups"] = []string{"sales"} }, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			callback := signIn(t, idp, router, tt.change)
+			if callback.Code != tt.want {
+				t.Errorf("callback = %d, want %d", callback.Code, tt.want)
+			}
+			if session := cookie(callback, sessionCookie); session != nil && session.Value != "" {
+				t.Error("callback started a session")
+			}
+		})
+	}
+}
+
+func TestSSOCallbackRejectsWrongState(t *testing.T) {
+	idp := newTestIdP(t)
+	_, router := newTestSSO(t, idp)
+
+	login := serve(router, http.MethodGet, "/admin/sso/login")
+	w := serve(router, http.MethodGet, "/admin/sso/callback?code=code&state=forged", cookie(login, loginCookie))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("callback with a forged state = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := serve(router, http.MethodGet, "/admin/sso/callback?code=code&state=forged"); w.Code != http.StatusBadRequest {
+		t.Errorf("callback without a login cookie = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestSSORejectsForgedSessions(t *testing.T) {
+	idp := newTestIdP(t)
+	sso, router := newTestSSO(t, idp)
+
+	viewer, err := sso.seal(Session{Subject: "user-1", Role: RoleViewer, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := sso.seal(Session{Subject: "user-1", Role: RoleAdmin, ExpiresAt: time.Now().Add(-time.Minute).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The viewer's payload raised to admin, keeping the viewer's signature
+	payload, _ := json.Marshal(Session{Subject: "user-1", Role: RoleAdmin, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	_, signature, _ := strings.Cut(viewer, ".")
+	escalated := base64.RawURLEncoding.EncodeToString(payload) + "." + signature
+	other, err := NewSSO(sso.provider, strings.Repeat("x", minSessionSecret), time.Hour, map[string][]string{"admin": {"platform"}}, testRedirectURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreign, err := other.seal(Session{Subject: "user-1", Role: RoleAdmin, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w := serve(router, http.MethodPost, "/admin/stats", &http.Cookie{Name: sessionCookie, Value: viewer}); w.Code != http.StatusForbidden {
+		t.Errorf("viewer session changing state = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	for name, value := range map[string]string{
+		"escalated role": escalated,
+		"other secret":   foreign,
+		"expired":        expired,
+		"unsigned":       base64.RawURLEncoding.EncodeToString(payload),
+		"garbage":        "not-a-session",
+	} {
+		if w := serve(router, http.MethodGet, "/admin/stats", &http.Cookie{Name: sessionCookie, Value: value}); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s session = %d, want %d", name, w.Code, http.StatusUnauthorized)
+		}
+	}
+}
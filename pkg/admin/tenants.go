@@ -0,0 +1,193 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/analytics"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/events"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/middleware"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tenant"
+	"github.com/gorilla/mux"
+)
+
+// TenantHandlers manages the tenant lifecycle for super-admins
+type TenantHandlers struct {
+	registry  *tenant.Registry
+	engine    *analytics.AnalyticsEngine
+	adminKeys []string
+	events    *events.Bus
+}
+
+// NewTenantHandlers creates handlers for registry. When adminKeys is set only
+// callers whose key ID is listed may use them
+func NewTenantHandlers(registry *tenant.Registry, engine *analytics.AnalyticsEngine, adminKeys []string) *TenantHandlers {
+	return &TenantHandlers{registry: registry, engine: engine, adminKeys: adminKeys}
+}
+
+// SetEvents makes changes to tenants' key bindings publish key.rotated events
+// to bus
+func (th *TenantHandlers) SetEvents(bus *events.Bus) {
+	th.events = bus
+}
+
+// TenantRequest is the body accepted by POST /admin/tenants
+type TenantRequest struct {
+	ID string `json:"id"`
+	tenant.Spec
+}
+
+// TenantUsage is the body returned by GET /admin/tenants/{id}/usage
+type TenantUsage struct {
+	*tenant.Usage
+	Metrics map[string]interface{} `json:"metrics,omitempty"`
+}
+
+// ListTenants returns every tenant
+func (th *TenantHandlers) ListTenants(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(th.registry.List())
+}
+
+// CreateTenant registers a tenant
+func (th *TenantHandlers) CreateTenant(w http.ResponseWriter, r *http.Request) {
+	var body TenantRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	created, err := th.registry.Create(body.ID, body.Spec)
+	if err != nil {
+		writeTenantError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// GetTenant returns one tenant
+func (th *TenantHandlers) GetTenant(w http.ResponseWriter, r *http.Request) {
+	found, err := th.registry.Get(mux.Vars(r)["id"])
+	if err != nil {
+		writeTenantError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(found)
+}
+
+// UpdateTenant replaces a tenant's spec
+func (th *TenantHandlers) UpdateTenant(w http.ResponseWriter, r *http.Request) {
+	var spec tenant.Spec
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	id := mux.Vars(r)["id"]
+	previous, err := th.registry.Get(id)
+	if err != nil {
+		writeTenantError(w, err)
+		return
+	}
+	updated, err := th.registry.Update(id, spec)
+	if err != nil {
+		writeTenantError(w, err)
+		return
+	}
+	added, removed := keyChanges(previous.KeyIDs, updated.KeyIDs)
+	if len(added) > 0 || len(removed) > 0 {
+		th.events.Publish(r.Context(), events.KeyRotated, events.KeyRotatedData{Scope: "tenant", Name: id, Added: added, Removed: removed})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeleteTenant removes a tenant. Its analytics records are kept
+func (th *TenantHandlers) DeleteTenant(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if err := th.registry.Delete(id); err != nil {
+		writeTenantError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetTenantUsage returns a tenant's spend against its budget this month and
+// the analytics of its requests over the same period
+func (th *TenantHandlers) GetTenantUsage(w http.ResponseWriter, r *http.Request) {
+	usage, err := th.registry.Usage(mux.Vars(r)["id"])
+	if err != nil {
+		writeTenantError(w, err)
+		return
+	}
+	result := TenantUsage{Usage: usage}
+	if th.engine != nil {
+		monthStart, _ := time.Parse("2006-01", usage.Month)
+		result.Metrics = th.engine.GetTenantMetrics(usage.Tenant, monthStart)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// RegisterRoutes adds the tenant routes to router
+func (th *TenantHandlers) RegisterRoutes(router *mux.Router) {
+	routes := router.PathPrefix("/admin/tenants").Subrouter()
+	routes.Use(th.requireAdmin)
+	routes.HandleFunc("", th.ListTenants).Methods("GET")
+	routes.HandleFunc("", th.CreateTenant).Methods("POST")
+	routes.HandleFunc("/{id}", th.GetTenant).Methods("GET")
+	routes.HandleFunc("/{id}", th.UpdateTenant).Methods("PUT")
+	routes.HandleFunc("/{id}", th.DeleteTenant).Methods("DELETE")
+	routes.HandleFunc("/{id}/usage", th.GetTenantUsage).Methods("GET")
+}
+
+// requireAdmin rejects callers whose key ID is not one of the admin keys,
+// unless they hold the admin role
+func (th *TenantHandlers) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(th.adminKeys) > 0 && RoleFromContext(r.Context()) != RoleAdmin && !middleware.KeyIDIn(th.adminKeys, middleware.KeyID(r)) {
+			http.Error(w, "Tenant administration requires a super-admin API key", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeTenantError maps registry errors to status codes
+func writeTenantError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, tenant.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, tenant.ErrExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, tenant.ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// keyChanges returns the key IDs in after but not before, and in before but
+// not after
+func keyChanges(before, after []string) (added, removed []string) {
+	for _, keyID := range after {
+		if !slices.Contains(before, keyID) {
+			added = append(added, keyID)
+		}
+	}
+	for _, keyID := range before {
+		if !slices.Contains(after, keyID) {
+			removed = append(removed, keyID)
+		}
+	}
+	return added, removed
+}
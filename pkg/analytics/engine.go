@@ -3,20 +3,36 @@ package analytics
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
-	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/pollinations"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/assistant"
 	"github.com/sirupsen/logrus"
 )
 
-// AnalyticsEngine provides analytics and insights functionality
+// Bounds of the request history kept in memory
+const (
+	defaultRetention = 30 * 24 * time.Hour
+	maxRecords       = 100000
+)
+
+// AnalyticsEngine aggregates recorded requests into metrics and insights
 type AnalyticsEngine struct {
-	logger             *logrus.Logger
-	metricsStore       *MetricsStore
-	insightsGenerator  *InsightsGenerator
-	pollinationsClient *pollinations.Client
-	mutex              sync.RWMutex
+	logger            *logrus.Logger
+	metricsStore      *MetricsStore
+	insightsGenerator *InsightsGenerator
+	records           []RequestMetrics
+	providers         []ProviderInfo
+	retention         time.Duration
+	mutex             sync.RWMutex
+}
+
+// ProviderInfo is the catalogue entry of a configured provider
+type ProviderInfo struct {
+	ProviderID   string  `json:"provider_id"`
+	Tier         string  `json:"tier"`
+	CostPerToken float64 `json:"cost_per_token"`
 }
 
 // MetricsStore handles storage and retrieval of metrics
@@ -35,15 +51,30 @@ type CachedMetrics struct {
 
 // RequestMetrics represents metrics for individual requests
 type RequestMetrics struct {
-	RequestID    string    `json:"request_id"`
-	ProviderID   string    `json:"provider_id"`
-	Model        string    `json:"model"`
+	RequestID   string `json:"request_id"`
+	KeyID       string `json:"key_id,omitempty"`
+	Tenant      string `json:"tenant,omitempty"`
+	Environment string `json:"environment,omitempty"`
+	ProviderID  string `json:"provider_id"`
+	Model       string `json:"model"`
+	Tier        string `json:"tier,omitempty"`
+	// Complexity is the task complexity level: low, medium, high or very_high
+	Complexity   string    `json:"complexity,omitempty"`
 	Timestamp    time.Time `json:"timestamp"`
 	Duration     int64     `json:"duration_ms"`
 	TokensUsed   int       `json:"tokens_used"`
 	Cost         float64   `json:"cost"`
 	Success      bool      `json:"success"`
 	ErrorMessage string    `json:"error_message,omitempty"`
+	// ReasoningTokens is the part of TokensUsed a reasoning model spent
+	// thinking
+	ReasoningTokens int `json:"reasoning_tokens,omitempty"`
+	// SafetyAction and SafetyScores are the response's safety
+	// classification, empty when it was not classified
+	SafetyAction string             `json:"safety_action,omitempty"`
+	SafetyScores map[string]float64 `json:"safety_scores,omitempty"`
+	// Tags are the custom dimensions the client attached to the request
+	Tags Tags `json:"tags,omitempty"`
 }
 
 // ProviderPerformance represents performance metrics for a provider
@@ -60,12 +91,19 @@ type ProviderPerformance struct {
 
 // CostAnalysis represents cost analysis data
 type CostAnalysis struct {
-	TotalCost       float64                    `json:"total_cost"`
-	CostByProvider  map[string]float64         `json:"cost_by_provider"`
-	CostByModel     map[string]float64         `json:"cost_by_model"`
-	CostTrend       []CostDataPoint            `json:"cost_trend"`
-	Recommendations []OptimizationOpportunity  `json:"recommendations"`
-	Period          string                     `json:"period"`
+	TotalCost         float64                   `json:"total_cost"`
+	CostByProvider    map[string]float64        `json:"cost_by_provider"`
+	CostByModel       map[string]float64        `json:"cost_by_model"`
+	CostByEnvironment map[string]float64        `json:"cost_by_environment,omitempty"`
+	CostTrend         []CostDataPoint           `json:"cost_trend"`
+	Recommendations   []OptimizationOpportunity `json:"recommendations"`
+	Period            string                    `json:"period"`
+	// CostByTag is the cost of each value of each request tag
+	CostByTag map[string]map[string]float64 `json:"cost_by_tag,omitempty"`
+	// ReasoningTokens and ReasoningCost are what reasoning models spent
+	// thinking, part of the total
+	ReasoningTokens int64   `json:"reasoning_tokens"`
+	ReasoningCost   float64 `json:"reasoning_cost"`
 }
 
 // CostDataPoint represents a point in cost trend data
@@ -82,19 +120,20 @@ type OptimizationOpportunity struct {
 	Savings     float64 `json:"potential_savings"`
 }
 
-// InsightsGenerator generates analytical insights
+// InsightsGenerator derives recommendations from recorded requests
 type InsightsGenerator struct {
-	logger *logrus.Logger
-	client *pollinations.Client
+	logger    *logrus.Logger
+	assistant assistant.Model
 }
 
-// NewAnalyticsEngine creates a new analytics engine
-func NewAnalyticsEngine(logger *logrus.Logger, pollinationsClient *pollinations.Client) *AnalyticsEngine {
+// NewAnalyticsEngine creates a new analytics engine. model, which may be nil,
+// writes the optional prose summary of insights
+func NewAnalyticsEngine(logger *logrus.Logger, model assistant.Model) *AnalyticsEngine {
 	return &AnalyticsEngine{
-		logger:             logger,
-		metricsStore:       NewMetricsStore(),
-		insightsGenerator:  NewInsightsGenerator(logger),
-		pollinationsClient: pollinationsClient,
+		logger:            logger,
+		metricsStore:      NewMetricsStore(),
+		insightsGenerator: NewInsightsGenerator(logger, model),
+		retention:         defaultRetention,
 	}
 }
 
@@ -106,167 +145,354 @@ func NewMetricsStore() *MetricsStore {
 	}
 }
 
-// NewInsightsGenerator creates a new insights generator
-func NewInsightsGenerator(logger *logrus.Logger) *InsightsGenerator {
+// NewInsightsGenerator creates a new insights generator; model may be nil
+func NewInsightsGenerator(logger *logrus.Logger, model assistant.Model) *InsightsGenerator {
 	return &InsightsGenerator{
-		logger: logger,
-		client: pollinations.NewClient(),
+		logger:    logger,
+		assistant: model,
 	}
 }
 
 // RecordRequest records metrics for a request
 func (ae *AnalyticsEngine) RecordRequest(metrics RequestMetrics) {
+	if metrics.Timestamp.IsZero() {
+		metrics.Timestamp = time.Now()
+	}
+
 	ae.mutex.Lock()
 	defer ae.mutex.Unlock()
 
-	// Store the metrics (in a real implementation, this would go to a database)
-	cacheKey := fmt.Sprintf("request_%s", metrics.RequestID)
-	ae.metricsStore.Cache(cacheKey, metrics, 24*time.Hour)
+	ae.records = append(ae.records, metrics)
+
+	// Drop requests past retention, and the oldest beyond the cap
+	drop := ae.expired()
+	if excess := len(ae.records) - maxRecords; excess > drop {
+		drop = excess
+	}
+	if drop > 0 {
+		ae.records = append([]RequestMetrics(nil), ae.records[drop:]...)
+	}
 
 	ae.logger.Debugf("Recorded metrics for request %s", metrics.RequestID)
 }
 
-// GetSystemMetrics returns overall system metrics
-func (ae *AnalyticsEngine) GetSystemMetrics() map[string]interface{} {
+// SetRetention sets how long recorded requests are kept; zero or less keeps
+// them until the record cap is reached
+func (ae *AnalyticsEngine) SetRetention(retention time.Duration) {
+	ae.mutex.Lock()
+	defer ae.mutex.Unlock()
+	ae.retention = retention
+}
+
+// Prune drops requests past retention and returns how many were dropped
+func (ae *AnalyticsEngine) Prune() int {
+	ae.mutex.Lock()
+	defer ae.mutex.Unlock()
+
+	drop := ae.expired()
+	if drop > 0 {
+		ae.records = append([]RequestMetrics(nil), ae.records[drop:]...)
+	}
+	return drop
+}
+
+// expired returns how many of the oldest requests are past retention; the
+// caller holds the mutex
+func (ae *AnalyticsEngine) expired() int {
+	if ae.retention <= 0 {
+		return 0
+	}
+	cutoff := time.Now().Add(-ae.retention)
+	drop := 0
+	for drop < len(ae.records) && ae.records[drop].Timestamp.Before(cutoff) {
+		drop++
+	}
+	return drop
+}
+
+// EraseRequests drops every recorded request matching match and returns how
+// many were dropped
+func (ae *AnalyticsEngine) EraseRequests(match func(RequestMetrics) bool) int {
+	ae.mutex.Lock()
+	defer ae.mutex.Unlock()
+
+	kept := make([]RequestMetrics, 0, len(ae.records))
+	for _, record := range ae.records {
+		if !match(record) {
+			kept = append(kept, record)
+		}
+	}
+	erased := len(ae.records) - len(kept)
+	ae.records = kept
+	return erased
+}
+
+// SetProviders replaces the provider catalogue, which lets insights cover
+// providers that received little or no traffic
+func (ae *AnalyticsEngine) SetProviders(providers []ProviderInfo) {
+	ae.mutex.Lock()
+	defer ae.mutex.Unlock()
+
+	ae.providers = append([]ProviderInfo(nil), providers...)
+}
+
+// recordsSince returns a copy of the requests recorded at or after since
+func (ae *AnalyticsEngine) recordsSince(since time.Time) []RequestMetrics {
+	return ae.Records(since, time.Time{})
+}
+
+// Records returns a copy of the requests that started in [from, to); a zero
+// to means no upper bound
+func (ae *AnalyticsEngine) Records(from, to time.Time) []RequestMetrics {
 	ae.mutex.RLock()
 	defer ae.mutex.RUnlock()
 
-	// In a real implementation, this would aggregate data from the database
-	return map[string]interface{}{
-		"total_requests":    1000, // placeholder
-		"successful_requests": 950,
-		"failed_requests":   50,
-		"success_rate":      0.95,
-		"avg_response_time": 1250.5,
-		"total_cost":        125.75,
-		"active_providers":  5,
-		"timestamp":         time.Now().Unix(),
+	// Requests are recorded as they finish, so timestamps are only roughly ordered
+	var records []RequestMetrics
+	for _, record := range ae.records {
+		if !record.Timestamp.Before(from) && (to.IsZero() || record.Timestamp.Before(to)) {
+			records = append(records, record)
+		}
 	}
+	return records
+}
+
+// summarize aggregates records into the fields shared by system and provider metrics
+func summarize(records []RequestMetrics) map[string]interface{} {
+	var successful int
+	var duration int64
+	var cost float64
+	for _, record := range records {
+		if record.Success {
+			successful++
+		}
+		duration += record.Duration
+		cost += record.Cost
+	}
+
+	summary := map[string]interface{}{
+		"total_requests":      len(records),
+		"successful_requests": successful,
+		"failed_requests":     len(records) - successful,
+		"success_rate":        0.0,
+		"avg_response_time":   0.0,
+		"total_cost":          cost,
+	}
+	if len(records) > 0 {
+		summary["success_rate"] = float64(successful) / float64(len(records))
+		summary["avg_response_time"] = float64(duration) / float64(len(records))
+	}
+	return summary
+}
+
+// GetSystemMetrics returns overall system metrics over the retained requests
+func (ae *AnalyticsEngine) GetSystemMetrics() map[string]interface{} {
+	records := ae.recordsSince(time.Time{})
+
+	providers := make(map[string]bool)
+	for _, record := range records {
+		providers[record.ProviderID] = true
+	}
+
+	metrics := summarize(records)
+	metrics["active_providers"] = len(providers)
+	metrics["timestamp"] = time.Now().Unix()
+	return metrics
 }
 
 // GetProviderMetrics returns metrics for a specific provider
 func (ae *AnalyticsEngine) GetProviderMetrics(providerID string) map[string]interface{} {
-	ae.mutex.RLock()
-	defer ae.mutex.RUnlock()
+	var records []RequestMetrics
+	for _, record := range ae.recordsSince(time.Time{}) {
+		if record.ProviderID == providerID {
+			records = append(records, record)
+		}
+	}
 
-	// In a real implementation, this would query the database
-	return map[string]interface{}{
-		"provider_id":       providerID,
-		"total_requests":    200,
-		"successful_requests": 190,
-		"failed_requests":   10,
-		"success_rate":      0.95,
-		"avg_response_time": 1100.0,
-		"total_cost":        25.50,
-		"last_request":      time.Now().Add(-5 * time.Minute).Unix(),
+	metrics := summarize(records)
+	metrics["provider_id"] = providerID
+	if len(records) > 0 {
+		metrics["last_request"] = records[len(records)-1].Timestamp.Unix()
 	}
+	return metrics
 }
 
-// GetProviderPerformance returns performance analysis for all providers
-func (ae *AnalyticsEngine) GetProviderPerformance() []ProviderPerformance {
-	ae.mutex.RLock()
-	defer ae.mutex.RUnlock()
+// GetTenantMetrics returns the metrics of one tenant's requests since the
+// given time, with per-provider performance
+func (ae *AnalyticsEngine) GetTenantMetrics(tenant string, since time.Time) map[string]interface{} {
+	var records []RequestMetrics
+	for _, record := range ae.recordsSince(since) {
+		if record.Tenant == tenant {
+			records = append(records, record)
+		}
+	}
 
-	// In a real implementation, this would aggregate from the database
-	return []ProviderPerformance{
-		{
-			ProviderID:      "pollinations",
-			TotalRequests:   500,
-			SuccessfulReqs:  475,
-			FailedReqs:      25,
-			SuccessRate:     0.95,
-			AvgResponseTime: 1200.0,
-			TotalCost:       50.25,
-			LastUpdated:     time.Now(),
-		},
-		{
-			ProviderID:      "openai",
-			TotalRequests:   300,
-			SuccessfulReqs:  285,
-			FailedReqs:      15,
-			SuccessRate:     0.95,
-			AvgResponseTime: 800.0,
-			TotalCost:       75.50,
-			LastUpdated:     time.Now(),
-		},
+	metrics := summarize(records)
+	metrics["tenant"] = tenant
+	metrics["providers"] = Performance(records)
+	return metrics
+}
+
+// GetEnvironmentMetrics returns the metrics of one key environment's
+// requests since the given time, with per-provider performance
+func (ae *AnalyticsEngine) GetEnvironmentMetrics(environment string, since time.Time) map[string]interface{} {
+	var records []RequestMetrics
+	for _, record := range ae.recordsSince(since) {
+		if record.Environment == environment {
+			records = append(records, record)
+		}
 	}
+
+	metrics := summarize(records)
+	metrics["environment"] = environment
+	metrics["providers"] = Performance(records)
+	return metrics
 }
 
-// GetCostAnalysis returns cost analysis for the specified time period
-func (ae *AnalyticsEngine) GetCostAnalysis(since time.Time) CostAnalysis {
-	ae.mutex.RLock()
-	defer ae.mutex.RUnlock()
+// GetProviderPerformance returns performance analysis for all providers, busiest first
+func (ae *AnalyticsEngine) GetProviderPerformance() []ProviderPerformance {
+	return Performance(ae.recordsSince(time.Time{}))
+}
 
-	// In a real implementation, this would aggregate from the database
-	return CostAnalysis{
-		TotalCost: 125.75,
-		CostByProvider: map[string]float64{
-			"pollinations": 50.25,
-			"openai":       75.50,
-		},
-		CostByModel: map[string]float64{
-			"gpt-4":     75.50,
-			"pollinations": 50.25,
-		},
-		CostTrend: []CostDataPoint{
-			{Timestamp: time.Now().Add(-24 * time.Hour), Cost: 100.00},
-			{Timestamp: time.Now().Add(-12 * time.Hour), Cost: 112.50},
-			{Timestamp: time.Now(), Cost: 125.75},
-		},
-		Recommendations: []OptimizationOpportunity{
-			{
-				Type:        "model_optimization",
-				Description: "Consider using more cost-effective models for simple tasks",
-				Impact:      "medium",
-				Savings:     15.25,
-			},
-		},
-		Period: fmt.Sprintf("Since %s", since.Format("2006-01-02 15:04:05")),
+// Performance aggregates records per provider, busiest provider first
+func Performance(records []RequestMetrics) []ProviderPerformance {
+	byProvider := make(map[string]*ProviderPerformance)
+	var order []string
+	for _, record := range records {
+		performance, ok := byProvider[record.ProviderID]
+		if !ok {
+			performance = &ProviderPerformance{ProviderID: record.ProviderID}
+			byProvider[record.ProviderID] = performance
+			order = append(order, record.ProviderID)
+		}
+		performance.TotalRequests++
+		if record.Success {
+			performance.SuccessfulReqs++
+		} else {
+			performance.FailedReqs++
+		}
+		// Accumulate durations; averaged below
+		performance.AvgResponseTime += float64(record.Duration)
+		performance.TotalCost += record.Cost
+		performance.LastUpdated = record.Timestamp
 	}
+
+	result := make([]ProviderPerformance, 0, len(order))
+	for _, id := range order {
+		performance := byProvider[id]
+		performance.SuccessRate = float64(performance.SuccessfulReqs) / float64(performance.TotalRequests)
+		performance.AvgResponseTime /= float64(performance.TotalRequests)
+		result = append(result, *performance)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].TotalRequests > result[j].TotalRequests
+	})
+	return result
 }
 
-// GenerateInsights generates analytical insights using AI
-func (ae *AnalyticsEngine) GenerateInsights() ([]string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+// GetCostAnalysis returns cost analysis for the specified time period, of
+// the requests whose tags match filter. The recommendations are the insights
+// rules that carry a savings estimate
+func (ae *AnalyticsEngine) GetCostAnalysis(since time.Time, filter Tags) CostAnalysis {
+	now := time.Now()
+	var records []RequestMetrics
+	for _, record := range ae.recordsSince(since) {
+		if record.Tags.Matches(filter) {
+			records = append(records, record)
+		}
+	}
+
+	analysis := CostAnalysis{
+		CostByProvider:    make(map[string]float64),
+		CostByModel:       make(map[string]float64),
+		CostByEnvironment: make(map[string]float64),
+		CostByTag:         make(map[string]map[string]float64),
+		CostTrend:         []CostDataPoint{},
+		Recommendations:   []OptimizationOpportunity{},
+		Period:            fmt.Sprintf("Since %s", since.Format("2006-01-02 15:04:05")),
+	}
 
-	prompt := `Analyze the following system metrics and provide 3-5 key insights and recommendations:
+	// Cumulative cost, one point per hour with traffic
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Timestamp.Before(records[j].Timestamp)
+	})
+	var hour time.Time
+	for _, record := range records {
+		analysis.TotalCost += record.Cost
+		analysis.CostByProvider[record.ProviderID] += record.Cost
+		if record.ReasoningTokens > 0 && record.TokensUsed > 0 {
+			analysis.ReasoningTokens += int64(record.ReasoningTokens)
+			analysis.ReasoningCost += record.Cost * float64(record.ReasoningTokens) / float64(record.TokensUsed)
+		}
+		if record.Model != "" {
+			analysis.CostByModel[record.Model] += record.Cost
+		}
+		if record.Environment != "" {
+			analysis.CostByEnvironment[record.Environment] += record.Cost
+		}
+		for key, value := range record.Tags {
+			if analysis.CostByTag[key] == nil {
+				analysis.CostByTag[key] = make(map[string]float64)
+			}
+			analysis.CostByTag[key][value] += record.Cost
+		}
+		bucket := record.Timestamp.Truncate(time.Hour)
+		if len(analysis.CostTrend) == 0 || !bucket.Equal(hour) {
+			analysis.CostTrend = append(analysis.CostTrend, CostDataPoint{Timestamp: bucket})
+			hour = bucket
+		}
+		analysis.CostTrend[len(analysis.CostTrend)-1].Cost = analysis.TotalCost
+	}
+
+	for _, recommendation := range ae.Analyze(records, since, now).Recommendations {
+		if recommendation.EstimatedMonthlySavings <= 0 {
+			continue
+		}
+		analysis.Recommendations = append(analysis.Recommendations, OptimizationOpportunity{
+			Type:        string(recommendation.Type),
+			Description: recommendation.Title,
+			Impact:      recommendation.Severity,
+			Savings:     recommendation.EstimatedMonthlySavings,
+		})
+	}
+	return analysis
+}
 
-System Performance:
-- Total requests: 1000
-- Success rate: 95%
-- Average response time: 1250ms
-- Total cost: $125.75
+// Providers returns a copy of the provider catalogue
+func (ae *AnalyticsEngine) Providers() []ProviderInfo {
+	ae.mutex.RLock()
+	defer ae.mutex.RUnlock()
 
-Provider Performance:
-- Pollinations: 500 requests, 95% success, $50.25 cost
-- OpenAI: 300 requests, 95% success, $75.50 cost
+	return append([]ProviderInfo(nil), ae.providers...)
+}
 
-Provide actionable insights for system optimization.`
+// Analyze derives insights from records, the requests seen between since and
+// now, without the assistant summary
+func (ae *AnalyticsEngine) Analyze(records []RequestMetrics, since, now time.Time) *Insights {
+	return ae.insightsGenerator.Generate(records, ae.Providers(), since, now)
+}
 
-	if ae.pollinationsClient == nil {
-		// Fallback to static insights if no client available
-		return []string{
-			"System is performing well with 95% success rate",
-			"Consider load balancing between providers for better cost efficiency",
-			"Monitor response times to identify potential bottlenecks",
-		}, nil
+// GenerateInsights analyzes the requests recorded since since. With summarize
+// set the assistant model, if any, adds a prose summary; the structured
+// findings never depend on it
+func (ae *AnalyticsEngine) GenerateInsights(ctx context.Context, since time.Time, summarize bool) *Insights {
+	cacheKey := fmt.Sprintf("insights_%d_%t", since.Unix()/60, summarize)
+	if cached, ok := ae.metricsStore.GetCached(cacheKey); ok {
+		return cached.(*Insights)
 	}
 
-	response, err := ae.pollinationsClient.GenerateText(ctx, prompt)
-	if err != nil {
-		ae.logger.Errorf("Failed to generate insights: %v", err)
-		// Return fallback insights
-		return []string{
-			"System metrics indicate stable performance",
-			"Cost optimization opportunities may exist",
-			"Regular monitoring recommended",
-		}, nil
+	insights := ae.Analyze(ae.recordsSince(since), since, time.Now())
+	if summarize && ae.insightsGenerator.assistant != nil {
+		summary, err := ae.insightsGenerator.Summarize(ctx, insights)
+		if err != nil {
+			ae.logger.Warnf("Failed to summarize insights: %v", err)
+		} else {
+			insights.Summary = summary
+		}
 	}
 
-	// Parse response into insights (simplified)
-	insights := []string{response}
-	return insights, nil
+	ae.metricsStore.Cache(cacheKey, insights, time.Minute)
+	return insights
 }
 
 // Cache stores data in the metrics cache
@@ -283,8 +509,8 @@ func (ms *MetricsStore) Cache(key string, data interface{}, ttl time.Duration) {
 
 // GetCached retrieves data from the cache
 func (ms *MetricsStore) GetCached(key string) (interface{}, bool) {
-	ms.cacheMutex.RLock()
-	defer ms.cacheMutex.RUnlock()
+	ms.cacheMutex.Lock()
+	defer ms.cacheMutex.Unlock()
 
 	cached, exists := ms.cache[key]
 	if !exists {
@@ -299,16 +525,3 @@ func (ms *MetricsStore) GetCached(key string) (interface{}, bool) {
 
 	return cached.Data, true
 }
-
-// GenerateInsights generates insights using the insights generator
-func (ig *InsightsGenerator) GenerateInsights(ctx context.Context, data map[string]interface{}) ([]string, error) {
-	prompt := fmt.Sprintf("Analyze this system data and provide insights: %v", data)
-	
-	response, err := ig.client.GenerateText(ctx, prompt)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate insights: %w", err)
-	}
-
-	// Simple parsing - in reality, you'd want more sophisticated parsing
-	return []string{response}, nil
-}
\ No newline at end of file
@@ -0,0 +1,610 @@
+package analytics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
+)
+
+// Thresholds of the recommendation rules
+const (
+	// minInsightRequests is the least traffic a rule looks at before reporting
+	minInsightRequests = 10
+	// rerouteShareThreshold flags low-complexity traffic sent to the official tier
+	rerouteShareThreshold = 0.2
+	// minClusterFailures and minClusterShare make a group of errors a cluster
+	minClusterFailures = 3
+	minClusterShare    = 0.05
+	// underutilizedShare is the traffic share below which a provider is under-utilized
+	underutilizedShare = 0.05
+	// cheapCostRatio makes a provider cheap when it costs at most this fraction
+	// of the traffic-weighted average per token
+	cheapCostRatio = 0.5
+	// risingCostThreshold flags cost per token rising this much across the window
+	risingCostThreshold = 0.25
+	// minReliableSuccessRate is required of a provider before suggesting more traffic for it
+	minReliableSuccessRate = 0.9
+	// costCurveBuckets is the number of points in each cost curve
+	costCurveBuckets = 12
+	// minObservedSpan keeps monthly projections from very short windows sane
+	minObservedSpan = time.Hour
+)
+
+// month is the period savings and costs are projected over
+const month = 30 * 24 * time.Hour
+
+// RecommendationType identifies the rule that produced a recommendation
+type RecommendationType string
+
+const (
+	// RecommendationReroute suggests moving low-complexity traffic off the official tier
+	RecommendationReroute RecommendationType = "reroute_low_complexity"
+	// RecommendationFailureCluster reports a recurring error on one provider
+	RecommendationFailureCluster RecommendationType = "failure_cluster"
+	// RecommendationUnderutilized points at a cheap, reliable provider that gets little traffic
+	RecommendationUnderutilized RecommendationType = "underutilized_provider"
+	// RecommendationRisingCost reports a provider whose cost per token went up
+	RecommendationRisingCost RecommendationType = "rising_cost"
+)
+
+// Recommendation is one actionable finding, with the figures behind it
+type Recommendation struct {
+	Type     RecommendationType `json:"type"`
+	Severity string             `json:"severity"`
+	// Title states the finding and its impact in one sentence
+	Title string `json:"title"`
+	// Action is what an operator should do about it
+	Action    string   `json:"action"`
+	Providers []string `json:"providers,omitempty"`
+	// TrafficShare is the share of the traffic the finding is about, from 0 to 1
+	TrafficShare float64 `json:"traffic_share,omitempty"`
+	// EstimatedMonthlySavings extrapolates the savings over the observed window to 30 days
+	EstimatedMonthlySavings float64                `json:"estimated_monthly_savings,omitempty"`
+	Evidence                map[string]interface{} `json:"evidence,omitempty"`
+}
+
+// CostCurve is a provider's spend over the analysis window
+type CostCurve struct {
+	ProviderID string  `json:"provider_id"`
+	Tier       string  `json:"tier,omitempty"`
+	Requests   int     `json:"requests"`
+	TotalCost  float64 `json:"total_cost"`
+	CostPer1K  float64 `json:"cost_per_1k_tokens"`
+	// Change is the relative change in cost per 1K tokens from the first to
+	// the second half of the window
+	Change float64          `json:"change"`
+	Points []CostCurvePoint `json:"points"`
+}
+
+// CostCurvePoint is a provider's spend in one time bucket
+type CostCurvePoint struct {
+	Start     time.Time `json:"start"`
+	Requests  int       `json:"requests"`
+	Tokens    int64     `json:"tokens"`
+	Cost      float64   `json:"cost"`
+	CostPer1K float64   `json:"cost_per_1k_tokens"`
+}
+
+// FailureCluster is a group of failures on one provider with the same error
+type FailureCluster struct {
+	ProviderID string `json:"provider_id"`
+	// Error is the normalized error message, with numbers and IDs masked
+	Error string `json:"error"`
+	Count int    `json:"count"`
+	// Share is the fraction of the provider's requests that failed this way
+	Share      float64   `json:"share"`
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+	RequestIDs []string  `json:"request_ids,omitempty"`
+}
+
+// Insights is the analysis of the requests recorded in a window
+type Insights struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	Since       time.Time `json:"since"`
+	// ObservedHours is the span of the recorded traffic that projections extrapolate from
+	ObservedHours        float64          `json:"observed_hours"`
+	Requests             int              `json:"requests"`
+	SuccessRate          float64          `json:"success_rate"`
+	TotalCost            float64          `json:"total_cost"`
+	ProjectedMonthlyCost float64          `json:"projected_monthly_cost"`
+	CostCurves           []CostCurve      `json:"cost_curves"`
+	FailureClusters      []FailureCluster `json:"failure_clusters"`
+	Recommendations      []Recommendation `json:"recommendations"`
+	// Summary is the assistant model's prose summary, when requested
+	Summary string `json:"summary,omitempty"`
+}
+
+// providerStats aggregates one provider's traffic
+type providerStats struct {
+	id        string
+	tier      string
+	requests  int
+	failures  int
+	tokens    int64
+	cost      float64
+	catalogue float64 // catalogue cost per token, used when no tokens were recorded
+}
+
+// costPer1K is the observed cost per 1K tokens, or the catalogue price without traffic
+func (s *providerStats) costPer1K() float64 {
+	if s.tokens > 0 {
+		return s.cost / float64(s.tokens) * 1000
+	}
+	return s.catalogue * 1000
+}
+
+// reliable reports whether the provider can take more traffic; providers
+// without traffic are given the benefit of the doubt
+func (s *providerStats) reliable() bool {
+	return s.requests == 0 || float64(s.requests-s.failures)/float64(s.requests) >= minReliableSuccessRate
+}
+
+// Generate analyzes records, the requests seen since since, against the
+// provider catalogue and derives recommendations from fixed rules
+func (ig *InsightsGenerator) Generate(records []RequestMetrics, providers []ProviderInfo, since, now time.Time) *Insights {
+	insights := &Insights{
+		GeneratedAt:     now,
+		Since:           since,
+		Requests:        len(records),
+		CostCurves:      []CostCurve{},
+		FailureClusters: []FailureCluster{},
+		Recommendations: []Recommendation{},
+	}
+
+	stats := make(map[string]*providerStats)
+	for _, provider := range providers {
+		stats[provider.ProviderID] = &providerStats{id: provider.ProviderID, tier: provider.Tier, catalogue: provider.CostPerToken}
+	}
+	if len(records) == 0 {
+		return insights
+	}
+
+	start := records[0].Timestamp
+	successes := 0
+	for _, record := range records {
+		if record.Timestamp.Before(start) {
+			start = record.Timestamp
+		}
+		s, ok := stats[record.ProviderID]
+		if !ok {
+			s = &providerStats{id: record.ProviderID}
+			stats[record.ProviderID] = s
+		}
+		if s.tier == "" {
+			s.tier = record.Tier
+		}
+		s.requests++
+		if record.Success {
+			successes++
+		} else {
+			s.failures++
+		}
+		s.tokens += int64(record.TokensUsed)
+		s.cost += record.Cost
+		insights.TotalCost += record.Cost
+	}
+
+	span := now.Sub(start)
+	if span < minObservedSpan {
+		span = minObservedSpan
+	}
+	monthly := float64(month) / float64(span)
+	insights.ObservedHours = span.Hours()
+	insights.SuccessRate = float64(successes) / float64(len(records))
+	insights.ProjectedMonthlyCost = insights.TotalCost * monthly
+
+	insights.CostCurves = costCurves(records, stats, start, now)
+	insights.FailureClusters = failureClusters(records, stats)
+
+	recommendations := rerouteRecommendations(records, stats, monthly)
+	rerouted := make(map[string]bool)
+	for _, recommendation := range recommendations {
+		for _, provider := range recommendation.Providers {
+			rerouted[provider] = true
+		}
+	}
+	recommendations = append(recommendations, underutilizedRecommendations(records, stats, monthly, rerouted)...)
+	recommendations = append(recommendations, failureRecommendations(insights.FailureClusters)...)
+	recommendations = append(recommendations, risingCostRecommendations(insights.CostCurves)...)
+
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		if recommendations[i].EstimatedMonthlySavings != recommendations[j].EstimatedMonthlySavings {
+			return recommendations[i].EstimatedMonthlySavings > recommendations[j].EstimatedMonthlySavings
+		}
+		return severityRank(recommendations[i].Severity) > severityRank(recommendations[j].Severity)
+	})
+	insights.Recommendations = recommendations
+	return insights
+}
+
+// Summarize asks the assistant model for a short prose summary of insights
+func (ig *InsightsGenerator) Summarize(ctx context.Context, insights *Insights) (string, error) {
+	if ig.assistant == nil {
+		return "", fmt.Errorf("no assistant model configured")
+	}
+
+	figures := *insights
+	figures.Summary = ""
+	data, err := json.Marshal(figures)
+	if err != nil {
+		return "", err
+	}
+
+	prompt := fmt.Sprintf(`You are reviewing the traffic analysis of an AI gateway that routes requests across providers.
+Summarize the most important findings and recommendations below in 3-5 sentences for an operator.
+Use only the figures given; do not invent numbers.
+
+%s`, data)
+
+	response, err := ig.assistant.GenerateText(ctx, prompt)
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize insights: %w", err)
+	}
+	return strings.TrimSpace(response), nil
+}
+
+// costCurves buckets each provider's spend over the window, most expensive provider first
+func costCurves(records []RequestMetrics, stats map[string]*providerStats, start, now time.Time) []CostCurve {
+	bucket := now.Sub(start) / costCurveBuckets
+	if bucket <= 0 {
+		bucket = time.Minute
+	}
+
+	type halves struct {
+		cost   [2]float64
+		tokens [2]int64
+	}
+	curves := make(map[string]*CostCurve)
+	split := make(map[string]*halves)
+	for _, record := range records {
+		curve, ok := curves[record.ProviderID]
+		if !ok {
+			curve = &CostCurve{ProviderID: record.ProviderID, Tier: stats[record.ProviderID].tier, Points: make([]CostCurvePoint, costCurveBuckets)}
+			for i := range curve.Points {
+				curve.Points[i].Start = start.Add(time.Duration(i) * bucket)
+			}
+			curves[record.ProviderID] = curve
+			split[record.ProviderID] = &halves{}
+		}
+
+		i := int(record.Timestamp.Sub(start) / bucket)
+		if i >= costCurveBuckets {
+			i = costCurveBuckets - 1
+		}
+		point := &curve.Points[i]
+		point.Requests++
+		point.Tokens += int64(record.TokensUsed)
+		point.Cost += record.Cost
+
+		half := 0
+		if i >= costCurveBuckets/2 {
+			half = 1
+		}
+		split[record.ProviderID].cost[half] += record.Cost
+		split[record.ProviderID].tokens[half] += int64(record.TokensUsed)
+	}
+
+	result := make([]CostCurve, 0, len(curves))
+	for id, curve := range curves {
+		for i := range curve.Points {
+			point := &curve.Points[i]
+			if point.Tokens > 0 {
+				point.CostPer1K = point.Cost / float64(point.Tokens) * 1000
+			}
+		}
+		curve.Requests = stats[id].requests
+		curve.TotalCost = stats[id].cost
+		curve.CostPer1K = stats[id].costPer1K()
+
+		h := split[id]
+		if h.tokens[0] > 0 && h.tokens[1] > 0 && h.cost[0] > 0 {
+			first := h.cost[0] / float64(h.tokens[0])
+			second := h.cost[1] / float64(h.tokens[1])
+			curve.Change = (second - first) / first
+		}
+		result = append(result, *curve)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].TotalCost != result[j].TotalCost {
+			return result[i].TotalCost > result[j].TotalCost
+		}
+		return result[i].ProviderID < result[j].ProviderID
+	})
+	return result
+}
+
+// Patterns masked when grouping error messages
+var (
+	hexIDPattern  = regexp.MustCompile(`\b[0-9a-f]{8,}(-[0-9a-f]{4,})*\b`)
+	numberPattern = regexp.MustCompile(`\d+(\.\d+)?`)
+)
+
+// normalizeError reduces an error message to its shape so that failures
+// differing only in IDs, counts or durations group together
+func normalizeError(message string) string {
+	message = strings.ToLower(strings.TrimSpace(message))
+	if message == "" {
+		return "unknown error"
+	}
+	message = hexIDPattern.ReplaceAllString(message, "<id>")
+	message = numberPattern.ReplaceAllString(message, "#")
+	message = strings.Join(strings.Fields(message), " ")
+	if len(message) > 160 {
+		message = message[:160]
+	}
+	return message
+}
+
+// failureClusters groups failures by provider and normalized error, largest first
+func failureClusters(records []RequestMetrics, stats map[string]*providerStats) []FailureCluster {
+	type key struct{ provider, message string }
+	clusters := make(map[key]*FailureCluster)
+	for _, record := range records {
+		if record.Success {
+			continue
+		}
+		k := key{record.ProviderID, normalizeError(record.ErrorMessage)}
+		cluster, ok := clusters[k]
+		if !ok {
+			cluster = &FailureCluster{ProviderID: k.provider, Error: k.message, FirstSeen: record.Timestamp, LastSeen: record.Timestamp}
+			clusters[k] = cluster
+		}
+		cluster.Count++
+		if record.Timestamp.Before(cluster.FirstSeen) {
+			cluster.FirstSeen = record.Timestamp
+		}
+		if record.Timestamp.After(cluster.LastSeen) {
+			cluster.LastSeen = record.Timestamp
+		}
+		if len(cluster.RequestIDs) < 3 && record.RequestID != "" {
+			cluster.RequestIDs = append(cluster.RequestIDs, record.RequestID)
+		}
+	}
+
+	result := make([]FailureCluster, 0, len(clusters))
+	for _, cluster := range clusters {
+		cluster.Share = float64(cluster.Count) / float64(stats[cluster.ProviderID].requests)
+		result = append(result, *cluster)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		if result[i].ProviderID != result[j].ProviderID {
+			return result[i].ProviderID < result[j].ProviderID
+		}
+		return result[i].Error < result[j].Error
+	})
+	return result
+}
+
+// cheapestAlternative returns the cheapest reliable provider outside excluded
+// tier, preferring providers that have served traffic over untested ones
+func cheapestAlternative(stats map[string]*providerStats, excluded string) *providerStats {
+	var best *providerStats
+	better := func(s *providerStats) bool {
+		if best == nil {
+			return true
+		}
+		if tested, bestTested := s.requests > 0, best.requests > 0; tested != bestTested {
+			return tested
+		}
+		if s.costPer1K() != best.costPer1K() {
+			return s.costPer1K() < best.costPer1K()
+		}
+		return s.id < best.id
+	}
+	for _, s := range stats {
+		if s.tier == excluded || s.tier == "" || !s.reliable() {
+			continue
+		}
+		if better(s) {
+			best = s
+		}
+	}
+	return best
+}
+
+// rerouteRecommendations flags low-complexity traffic that went to the
+// official tier when a cheaper tier could have served it
+func rerouteRecommendations(records []RequestMetrics, stats map[string]*providerStats, monthly float64) []Recommendation {
+	official := string(tier.Official)
+	var low, onOfficial int
+	var officialCost float64
+	var officialTokens int64
+	for _, record := range records {
+		if record.Complexity != "low" {
+			continue
+		}
+		low++
+		if stats[record.ProviderID].tier == official {
+			onOfficial++
+			officialCost += record.Cost
+			officialTokens += int64(record.TokensUsed)
+		}
+	}
+	if low < minInsightRequests {
+		return nil
+	}
+	share := float64(onOfficial) / float64(low)
+	if share < rerouteShareThreshold {
+		return nil
+	}
+
+	alternative := cheapestAlternative(stats, official)
+	if alternative == nil {
+		return nil
+	}
+	savings := (officialCost - float64(officialTokens)/1000*alternative.costPer1K()) * monthly
+	if savings <= 0 {
+		return nil
+	}
+
+	return []Recommendation{{
+		Type:     RecommendationReroute,
+		Severity: savingsSeverity(savings),
+		Title: fmt.Sprintf("%.0f%% of low-complexity traffic went to the official tier — estimated $%.2f/month savings by rerouting to %s",
+			share*100, savings, alternative.id),
+		Action:                  fmt.Sprintf("Add a routing policy that prefers the %s tier for low-complexity requests", alternative.tier),
+		Providers:               []string{alternative.id},
+		TrafficShare:            share,
+		EstimatedMonthlySavings: savings,
+		Evidence: map[string]interface{}{
+			"low_complexity_requests":    low,
+			"official_tier_requests":     onOfficial,
+			"official_tier_cost":         officialCost,
+			"alternative_tier":           alternative.tier,
+			"alternative_cost_per_1k":    alternative.costPer1K(),
+			"alternative_has_no_traffic": alternative.requests == 0,
+		},
+	}}
+}
+
+// underutilizedRecommendations points at cheap, reliable providers that get
+// little traffic while low-complexity requests pay more elsewhere. Providers
+// already suggested by a reroute recommendation are skipped
+func underutilizedRecommendations(records []RequestMetrics, stats map[string]*providerStats, monthly float64, skip map[string]bool) []Recommendation {
+	var totalCost float64
+	var totalTokens int64
+	for _, s := range stats {
+		totalCost += s.cost
+		totalTokens += s.tokens
+	}
+	if len(records) < minInsightRequests || totalTokens == 0 {
+		return nil
+	}
+	average := totalCost / float64(totalTokens) * 1000
+
+	ids := make([]string, 0, len(stats))
+	for id := range stats {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var recommendations []Recommendation
+	for _, id := range ids {
+		candidate := stats[id]
+		share := float64(candidate.requests) / float64(len(records))
+		if skip[id] || share >= underutilizedShare || !candidate.reliable() || candidate.costPer1K() > average*cheapCostRatio {
+			continue
+		}
+
+		var movable int
+		var savings float64
+		for _, record := range records {
+			current := stats[record.ProviderID]
+			if record.Complexity != "low" || record.ProviderID == id || current.costPer1K() <= candidate.costPer1K() {
+				continue
+			}
+			movable++
+			savings += record.Cost - float64(record.TokensUsed)/1000*candidate.costPer1K()
+		}
+		savings *= monthly
+		if movable == 0 || savings <= 0 {
+			continue
+		}
+
+		recommendations = append(recommendations, Recommendation{
+			Type:     RecommendationUnderutilized,
+			Severity: savingsSeverity(savings),
+			Title: fmt.Sprintf("%s costs $%.4f per 1K tokens against a $%.4f average but served %.0f%% of traffic — estimated $%.2f/month savings by sending it low-complexity requests",
+				id, candidate.costPer1K(), average, share*100, savings),
+			Action:                  fmt.Sprintf("Raise the priority of %s for low-complexity requests", id),
+			Providers:               []string{id},
+			TrafficShare:            share,
+			EstimatedMonthlySavings: savings,
+			Evidence: map[string]interface{}{
+				"cost_per_1k":         candidate.costPer1K(),
+				"average_cost_per_1k": average,
+				"requests":            candidate.requests,
+				"failures":            candidate.failures,
+				"movable_requests":    movable,
+			},
+		})
+	}
+	return recommendations
+}
+
+// failureRecommendations turns significant failure clusters into recommendations
+func failureRecommendations(clusters []FailureCluster) []Recommendation {
+	var recommendations []Recommendation
+	for _, cluster := range clusters {
+		if cluster.Count < minClusterFailures || cluster.Share < minClusterShare {
+			continue
+		}
+		severity := "medium"
+		if cluster.Share >= 0.2 {
+			severity = "high"
+		}
+		recommendations = append(recommendations, Recommendation{
+			Type:     RecommendationFailureCluster,
+			Severity: severity,
+			Title: fmt.Sprintf("%d requests to %s (%.0f%% of its traffic) failed with %q",
+				cluster.Count, cluster.ProviderID, cluster.Share*100, cluster.Error),
+			Action:       fmt.Sprintf("Investigate the error and lower the priority of %s until it is resolved", cluster.ProviderID),
+			Providers:    []string{cluster.ProviderID},
+			TrafficShare: cluster.Share,
+			Evidence: map[string]interface{}{
+				"first_seen":  cluster.FirstSeen,
+				"last_seen":   cluster.LastSeen,
+				"request_ids": cluster.RequestIDs,
+			},
+		})
+	}
+	return recommendations
+}
+
+// risingCostRecommendations flags providers whose cost per token went up within the window
+func risingCostRecommendations(curves []CostCurve) []Recommendation {
+	var recommendations []Recommendation
+	for _, curve := range curves {
+		if curve.Requests < minInsightRequests || curve.Change < risingCostThreshold {
+			continue
+		}
+		recommendations = append(recommendations, Recommendation{
+			Type:     RecommendationRisingCost,
+			Severity: "medium",
+			Title: fmt.Sprintf("Cost per token on %s rose %.0f%% between the first and second half of the window",
+				curve.ProviderID, curve.Change*100),
+			Action:    fmt.Sprintf("Check %s for price changes or a shift to more expensive models", curve.ProviderID),
+			Providers: []string{curve.ProviderID},
+			Evidence: map[string]interface{}{
+				"change":      curve.Change,
+				"cost_per_1k": curve.CostPer1K,
+				"total_cost":  curve.TotalCost,
+			},
+		})
+	}
+	return recommendations
+}
+
+// savingsSeverity grades a recommendation by its estimated monthly savings in USD
+func savingsSeverity(savings float64) string {
+	switch {
+	case savings >= 100:
+		return "high"
+	case savings >= 10:
+		return "medium"
+	default:
+		return "low"
+	}
+}
+
+// severityRank orders severities for sorting
+func severityRank(severity string) int {
+	switch severity {
+	case "high":
+		return 2
+	case "medium":
+		return 1
+	default:
+		return 0
+	}
+}
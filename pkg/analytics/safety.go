@@ -0,0 +1,84 @@
+package analytics
+
+import (
+	"sort"
+	"time"
+)
+
+// SafetyReport summarizes the safety classification of responses for
+// compliance reporting
+type SafetyReport struct {
+	Since  time.Time `json:"since"`
+	Tenant string    `json:"tenant,omitempty"`
+	// Classified counts the responses that were classified
+	Classified int `json:"classified"`
+	// Actions counts responses by the action taken on them
+	Actions    map[string]int            `json:"actions"`
+	Categories []CategorySafety          `json:"categories"`
+	ByTenant   map[string]map[string]int `json:"by_tenant,omitempty"`
+}
+
+// CategorySafety is how often a category of unsafe content was detected
+type CategorySafety struct {
+	Category string `json:"category"`
+	// Detected counts the responses scoring above zero in the category
+	Detected     int     `json:"detected"`
+	MaxScore     float64 `json:"max_score"`
+	AverageScore float64 `json:"average_score"`
+}
+
+// GetSafetyReport summarizes the classified responses since the given time,
+// of one tenant or of all when tenant is empty. Categories are ordered by
+// detections, most first
+func (ae *AnalyticsEngine) GetSafetyReport(since time.Time, tenant string) SafetyReport {
+	report := SafetyReport{
+		Since:      since,
+		Tenant:     tenant,
+		Actions:    make(map[string]int),
+		Categories: []CategorySafety{},
+	}
+	if tenant == "" {
+		report.ByTenant = make(map[string]map[string]int)
+	}
+
+	categories := make(map[string]*CategorySafety)
+	totals := make(map[string]float64)
+	for _, record := range ae.recordsSince(since) {
+		if record.SafetyAction == "" || tenant != "" && record.Tenant != tenant {
+			continue
+		}
+		report.Classified++
+		report.Actions[record.SafetyAction]++
+		if report.ByTenant != nil && record.Tenant != "" {
+			if report.ByTenant[record.Tenant] == nil {
+				report.ByTenant[record.Tenant] = make(map[string]int)
+			}
+			report.ByTenant[record.Tenant][record.SafetyAction]++
+		}
+		for category, score := range record.SafetyScores {
+			if score <= 0 {
+				continue
+			}
+			summary := categories[category]
+			if summary == nil {
+				summary = &CategorySafety{Category: category}
+				categories[category] = summary
+			}
+			summary.Detected++
+			summary.MaxScore = max(summary.MaxScore, score)
+			totals[category] += score
+		}
+	}
+
+	for category, summary := range categories {
+		summary.AverageScore = totals[category] / float64(summary.Detected)
+		report.Categories = append(report.Categories, *summary)
+	}
+	sort.Slice(report.Categories, func(i, j int) bool {
+		if report.Categories[i].Detected != report.Categories[j].Detected {
+			return report.Categories[i].Detected > report.Categories[j].Detected
+		}
+		return report.Categories[i].Category < report.Categories[j].Category
+	})
+	return report
+}
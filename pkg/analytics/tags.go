@@ -0,0 +1,196 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Limits on the tags of one request
+const (
+	MaxTags           = 16
+	MaxTagKeyLength   = 64
+	MaxTagValueLength = 128
+)
+
+// Tags are the custom dimensions a client attaches to a request, such as
+// feature=search or team=growth
+type Tags map[string]string
+
+// ParseTags reads tags as they arrive in request metadata: an object of
+// string values. Keys are lower-cased letters, digits, '_', '-' and '.'
+func ParseTags(value interface{}) (Tags, error) {
+	var tags Tags
+	switch value := value.(type) {
+	case nil:
+		return nil, nil
+	case map[string]string:
+		tags = make(Tags, len(value))
+		for key, tagValue := range value {
+			tags[key] = tagValue
+		}
+	case map[string]interface{}:
+		tags = make(Tags, len(value))
+		for key, tagValue := range value {
+			s, ok := tagValue.(string)
+			if !ok {
+				return nil, fmt.Errorf("tag %q must be a string", key)
+			}
+			tags[key] = s
+		}
+	default:
+		return nil, fmt.Errorf("must be an object of string values")
+	}
+
+	if len(tags) > MaxTags {
+		return nil, fmt.Errorf("must have at most %d tags", MaxTags)
+	}
+	normalized := make(Tags, len(tags))
+	for key, value := range tags {
+		key = strings.ToLower(strings.TrimSpace(key))
+		if err := validTagKey(key); err != nil {
+			return nil, err
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case value == "":
+			return nil, fmt.Errorf("tag %q has no value", key)
+		case len(value) > MaxTagValueLength:
+			return nil, fmt.Errorf("tag %q must be at most %d characters", key, MaxTagValueLength)
+		case strings.IndexFunc(value, unicode.IsControl) >= 0:
+			return nil, fmt.Errorf("tag %q contains control characters", key)
+		}
+		normalized[key] = value
+	}
+	return normalized, nil
+}
+
+// ParseTagFilter reads key:value filters, such as the ?tag= query
+// parameters of the analytics API. Every filter must match
+func ParseTagFilter(filters []string) (Tags, error) {
+	if len(filters) == 0 {
+		return nil, nil
+	}
+	tags := make(Tags, len(filters))
+	for _, filter := range filters {
+		key, value, ok := strings.Cut(filter, ":")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !ok || strings.TrimSpace(value) == "" {
+			return nil, fmt.Errorf("tag filter %q must be key:value", filter)
+		}
+		if err := validTagKey(key); err != nil {
+			return nil, err
+		}
+		tags[key] = strings.TrimSpace(value)
+	}
+	return tags, nil
+}
+
+// Matches reports whether t has every tag of filter
+func (t Tags) Matches(filter Tags) bool {
+	for key, value := range filter {
+		if t[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// validTagKey checks a lower-cased tag key
+func validTagKey(key string) error {
+	switch {
+	case key == "":
+		return fmt.Errorf("tag keys must not be empty")
+	case len(key) > MaxTagKeyLength:
+		return fmt.Errorf("tag key %q must be at most %d characters", key, MaxTagKeyLength)
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '-' || r == '.') {
+			return fmt.Errorf("tag key %q may only contain letters, digits, '_', '-' and '.'", key)
+		}
+	}
+	return nil
+}
+
+// tagsKey is the context key of a request's tags
+type tagsKey struct{}
+
+// WithTags returns a context carrying the tags of its request
+func WithTags(ctx context.Context, tags Tags) context.Context {
+	if len(tags) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, tagsKey{}, tags)
+}
+
+// TagsFromContext returns the tags carried by ctx, nil without any
+func TagsFromContext(ctx context.Context) Tags {
+	tags, _ := ctx.Value(tagsKey{}).(Tags)
+	return tags
+}
+
+// TagValueUsage is the traffic of one value of a tag. An empty value covers
+// the requests without the tag
+type TagValueUsage struct {
+	Value            string  `json:"value"`
+	Requests         int     `json:"requests"`
+	FailedRequests   int     `json:"failed_requests"`
+	TokensUsed       int     `json:"tokens_used"`
+	Cost             float64 `json:"cost"`
+	AverageLatencyMs float64 `json:"average_latency_ms"`
+	ProviderCount    int     `json:"provider_count"`
+
+	latencyMs int64
+	providers map[string]bool
+}
+
+// TagBreakdown slices the requests of a period by the values of one tag
+type TagBreakdown struct {
+	Tag    string          `json:"tag"`
+	Since  time.Time       `json:"since"`
+	Filter Tags            `json:"filter,omitempty"`
+	Values []TagValueUsage `json:"values"`
+}
+
+// GetTagBreakdown returns the usage of each value of tag since the given
+// time, costliest first, over the requests matching filter
+func (ae *AnalyticsEngine) GetTagBreakdown(tag string, since time.Time, filter Tags) TagBreakdown {
+	tag = strings.ToLower(tag)
+	byValue := make(map[string]*TagValueUsage)
+	for _, record := range ae.recordsSince(since) {
+		if !record.Tags.Matches(filter) {
+			continue
+		}
+		value := record.Tags[tag]
+		usage := byValue[value]
+		if usage == nil {
+			usage = &TagValueUsage{Value: value, providers: make(map[string]bool)}
+			byValue[value] = usage
+		}
+		usage.Requests++
+		if !record.Success {
+			usage.FailedRequests++
+		}
+		usage.TokensUsed += record.TokensUsed
+		usage.Cost += record.Cost
+		usage.latencyMs += record.Duration
+		usage.providers[record.ProviderID] = true
+	}
+
+	breakdown := TagBreakdown{Tag: tag, Since: since, Filter: filter, Values: make([]TagValueUsage, 0, len(byValue))}
+	for _, usage := range byValue {
+		usage.AverageLatencyMs = float64(usage.latencyMs) / float64(usage.Requests)
+		usage.ProviderCount = len(usage.providers)
+		breakdown.Values = append(breakdown.Values, *usage)
+	}
+	sort.Slice(breakdown.Values, func(i, j int) bool {
+		if breakdown.Values[i].Cost != breakdown.Values[j].Cost {
+			return breakdown.Values[i].Cost > breakdown.Values[j].Cost
+		}
+		return breakdown.Values[i].Value < breakdown.Values[j].Value
+	})
+	return breakdown
+}
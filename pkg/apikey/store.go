@@ -0,0 +1,264 @@
+// Package apikey verifies the gateway's static API keys. Only salted hashes
+// of the keys are stored; a short prefix of each key stays in clear to tell
+// keys apart and narrow the lookup, and hashes are compared in constant time
+package apikey
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// KeyPrefix starts every generated key
+const KeyPrefix = "pal_"
+
+const (
+	// generatedPrefixLength is the clear prefix of generated keys: KeyPrefix
+	// and 8 of their 48 random hex characters
+	generatedPrefixLength = 12
+	// legacyPrefixLength is the clear prefix of other keys, kept short since
+	// their format is unknown
+	legacyPrefixLength = 6
+	saltBytes          = 16
+	hashScheme         = "sha256"
+)
+
+// Entry is one API key of the keys file
+type Entry struct {
+	Name string `yaml:"name,omitempty"`
+	// Prefix is the start of the key, enough to recognise it but not to use it
+	Prefix string `yaml:"prefix,omitempty"`
+	// Hash is "sha256:<salt>:<digest>", both hex encoded
+	Hash string `yaml:"hash,omitempty"`
+	// Key is a plaintext key from before keys were hashed. It still works but
+	// should be replaced by Prefix and Hash with `api-keys migrate`
+	Key string `yaml:"key,omitempty"`
+	// Environment is the deployment environment the key belongs to, e.g.
+	// dev or prod, which picks its routing profile
+	Environment string `yaml:"environment,omitempty"`
+}
+
+// keysFile is the layout of the keys file
+type keysFile struct {
+	Keys []Entry `yaml:"keys"`
+}
+
+// hashedKey is an entry ready for verification
+type hashedKey struct {
+	salt        []byte
+	digest      []byte
+	environment string
+}
+
+// Store holds the hashed API keys, indexed by prefix
+type Store struct {
+	byPrefix     map[string][]hashedKey
+	count        int
+	plaintext    int
+	environments []string
+}
+
+// LoadStore reads the keys file at path. Plaintext entries are hashed in
+// memory; Plaintext reports how many there were
+func LoadStore(path string) (*Store, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API keys: %w", err)
+	}
+	var file keysFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse API keys %s: %w", path, err)
+	}
+
+	store := &Store{byPrefix: make(map[string][]hashedKey)}
+	for i, entry := range file.Keys {
+		if entry.Key != "" {
+			hashed, err := Hash(entry.Name, entry.Key)
+			if err != nil {
+				return nil, err
+			}
+			hashed.Environment = entry.Environment
+			entry = hashed
+			store.plaintext++
+		}
+		key, err := parseEntry(entry)
+		if err != nil {
+			return nil, fmt.Errorf("API keys %s: key %d: %w", path, i+1, err)
+		}
+		store.byPrefix[entry.Prefix] = append(store.byPrefix[entry.Prefix], key)
+		store.count++
+		if entry.Environment != "" && !slices.Contains(store.environments, entry.Environment) {
+			store.environments = append(store.environments, entry.Environment)
+		}
+	}
+	return store, nil
+}
+
+// parseEntry decodes the hash of a hashed entry
+func parseEntry(entry Entry) (hashedKey, error) {
+	if entry.Prefix == "" || entry.Hash == "" {
+		return hashedKey{}, fmt.Errorf("needs prefix and hash, or key")
+	}
+	parts := strings.Split(entry.Hash, ":")
+	if len(parts) != 3 || parts[0] != hashScheme {
+		return hashedKey{}, fmt.Errorf("hash must look like %s:<salt>:<digest>", hashScheme)
+	}
+	salt, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return hashedKey{}, fmt.Errorf("invalid hash salt: %w", err)
+	}
+	digest, err := hex.DecodeString(parts[2])
+	if err != nil || len(digest) != sha256.Size {
+		return hashedKey{}, fmt.Errorf("invalid hash digest")
+	}
+	return hashedKey{salt: salt, digest: digest, environment: entry.Environment}, nil
+}
+
+// Len returns the number of keys
+func (s *Store) Len() int {
+	return s.count
+}
+
+// Plaintext returns the number of keys the file holds in plaintext
+func (s *Store) Plaintext() int {
+	return s.plaintext
+}
+
+// Environments returns the environments keys belong to, in file order
+func (s *Store) Environments() []string {
+	return s.environments
+}
+
+// Verify reports whether key is one of the stored keys, and returns the
+// environment it belongs to. Every key sharing its prefix is checked in
+// full, so the time taken does not depend on which one matches or how much
+// of a key is right
+func (s *Store) Verify(key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	found := 0
+	environment := ""
+	for _, candidate := range s.byPrefix[prefixOf(key)] {
+		match := subtle.ConstantTimeCompare(digest(candidate.salt, key), candidate.digest)
+		if match == 1 {
+			environment = candidate.environment
+		}
+		found |= match
+	}
+	return environment, found == 1
+}
+
+// Hash returns the entry storing key under name
+func Hash(name, key string) (Entry, error) {
+	if key == "" {
+		return Entry{}, fmt.Errorf("API key %q is empty", name)
+	}
+	salt := make([]byte, saltBytes)
+	if _, err := rand.Read(salt); err != nil {
+		return Entry{}, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return Entry{
+		Name:   name,
+		Prefix: prefixOf(key),
+		Hash:   fmt.Sprintf("%s:%s:%s", hashScheme, hex.EncodeToString(salt), hex.EncodeToString(digest(salt, key))),
+	}, nil
+}
+
+// Generate creates a random key and the entry storing it. The key cannot be
+// recovered from the entry, so it must be handed out now
+func Generate(name string) (string, Entry, error) {
+	random := make([]byte, 24)
+	if _, err := rand.Read(random); err != nil {
+		return "", Entry{}, fmt.Errorf("failed to generate key: %w", err)
+	}
+	key := KeyPrefix + hex.EncodeToString(random)
+	entry, err := Hash(name, key)
+	return key, entry, err
+}
+
+// Append adds entry to the keys file at path, creating the file if needed
+func Append(path string, entry Entry) error {
+	file, err := readFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	file.Keys = append(file.Keys, entry)
+	return writeFile(path, file)
+}
+
+// Migrate replaces the plaintext entries of the keys file at path with
+// hashed ones and returns how many it replaced. Comments in the file are
+// not preserved
+func Migrate(path string) (int, error) {
+	file, err := readFile(path)
+	if err != nil {
+		return 0, err
+	}
+	migrated := 0
+	for i, entry := range file.Keys {
+		if entry.Key == "" {
+			continue
+		}
+		if file.Keys[i], err = Hash(entry.Name, entry.Key); err != nil {
+			return 0, err
+		}
+		file.Keys[i].Environment = entry.Environment
+		migrated++
+	}
+	if migrated == 0 {
+		return 0, nil
+	}
+	return migrated, writeFile(path, file)
+}
+
+// readFile parses the keys file at path
+func readFile(path string) (keysFile, error) {
+	var file keysFile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return file, err
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return file, fmt.Errorf("failed to parse API keys %s: %w", path, err)
+	}
+	return file, nil
+}
+
+// writeFile replaces the keys file at path, readable by its owner only
+func writeFile(path string, file keysFile) error {
+	var data bytes.Buffer
+	encoder := yaml.NewEncoder(&data)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(file); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write API keys: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// prefixOf returns the clear prefix of key
+func prefixOf(key string) string {
+	length := legacyPrefixLength
+	if strings.HasPrefix(key, KeyPrefix) {
+		length = generatedPrefixLength
+	}
+	return key[:min(length, len(key))]
+}
+
+// digest hashes key with salt
+func digest(salt []byte, key string) []byte {
+	sum := sha256.Sum256(append(append([]byte(nil), salt...), key...))
+	return sum[:]
+}
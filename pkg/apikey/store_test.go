@@ -0,0 +1,163 @@
+package apikey
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeKeys writes entries as a keys file in a temporary directory and
+// returns its path
+func writeKeys(t *testing.T, entries ...Entry) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	for _, entry := range entries {
+		if err := Append(path, entry); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	return path
+}
+
+// loadKeys loads the keys file at path
+func loadKeys(t *testing.T, path string) *Store {
+	t.Helper()
+	store, err := LoadStore(path)
+	if err != nil {
+		t.Fatalf("LoadStore: %v", err)
+	}
+	return store
+}
+
+func TestGenerateAndVerify(t *testing.T) {
+	key, entry, err := Generate("ci")
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if !strings.HasPrefix(key, KeyPrefix) {
+		t.Errorf("key %q does not start with %q", key, KeyPrefix)
+	}
+	if strings.Contains(entry.Hash, key) || entry.Key != "" {
+		t.Error("entry stores the key in clear")
+	}
+	entry.Environment = "prod"
+
+	store := loadKeys(t, writeKeys(t, entry))
+	if store.Len() != 1 || store.Plaintext() != 0 {
+		t.Errorf("Len, Plaintext = %d, %d; want 1, 0", store.Len(), store.Plaintext())
+	}
+	environment, ok := store.Verify(key)
+	if !ok || environment != "prod" {
+		t.Errorf("Verify = %q, %t; want prod, true", environment, ok)
+	}
+}
+
+func TestVerifyRejectsWrongKeys(t *testing.T) {
+	key, entry, err := Generate("ci")
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	store := loadKeys(t, writeKeys(t, entry))
+
+	// The same prefix with a different tail reaches the hash comparison
+	wrong := key[:len(key)-1] + "x"
+	if key[len(key)-1] == 'x' {
+		wrong = key[:len(key)-1] + "y"
+	}
+	for name, candidate := range map[string]string{
+		"empty":      "",
+		"other key":  "pal_000000000000000000000000000000000000000000000000",
+		"wrong tail": wrong,
+		"truncated":  key[:generatedPrefixLength],
+	} {
+		if _, ok := store.Verify(candidate); ok {
+			t.Errorf("%s key verified", name)
+		}
+	}
+}
+
+// TestVerifyRevokedKey checks that a key removed from the keys file no
+// longer verifies once the file is loaded again, while the others still do
+func TestVerifyRevokedKey(t *testing.T) {
+	kept, keptEntry, err := Generate("kept")
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	revoked, revokedEntry, err := Generate("revoked")
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	path := writeKeys(t, keptEntry, revokedEntry)
+	if _, ok := loadKeys(t, path).Verify(revoked); !ok {
+		t.Fatal("key does not verify before it is revoked")
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := Append(path, keptEntry); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	store := loadKeys(t, path)
+	if _, ok := store.Verify(revoked); ok {
+		t.Error("revoked key still verifies")
+	}
+	if _, ok := store.Verify(kept); !ok {
+		t.Error("remaining key no longer verifies")
+	}
+}
+
+// TestVerifyPrefixCollision checks that keys sharing their clear prefix are
+// told apart by their hashes
+func TestVerifyPrefixCollision(t *testing.T) {
+	keys := map[string]string{"first": "sk-abc-first", "second": "sk-abc-second"}
+	var entries []Entry
+	for name, key := range keys {
+		entry, err := Hash(name, key)
+		if err != nil {
+			t.Fatalf("Hash: %v", err)
+		}
+		entry.Environment = name
+		entries = append(entries, entry)
+	}
+	if entries[0].Prefix != entries[1].Prefix {
+		t.Fatalf("prefixes %q and %q differ", entries[0].Prefix, entries[1].Prefix)
+	}
+
+	store := loadKeys(t, writeKeys(t, entries...))
+	for name, key := range keys {
+		if environment, ok := store.Verify(key); !ok || environment != name {
+			t.Errorf("Verify(%s) = %q, %t; want %q, true", key, environment, ok, name)
+		}
+	}
+	if _, ok := store.Verify("sk-abc-third"); ok {
+		t.Error("key sharing only the prefix verified")
+	}
+}
+
+func TestLoadStorePlaintextKeys(t *testing.T) {
+	path := writeKeys(t, Entry{Name: "legacy", Key: "sk-legacy-key"})
+	store := loadKeys(t, path)
+	if store.Plaintext() != 1 {
+		t.Errorf("Plaintext = %d, want 1", store.Plaintext())
+	}
+	if _, ok := store.Verify("sk-legacy-key"); !ok {
+		t.Error("plaintext key does not verify")
+	}
+
+	if migrated, err := Migrate(path); err != nil || migrated != 1 {
+		t.Fatalf("Migrate = %d, %v; want 1, nil", migrated, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "sk-legacy-key") {
+		t.Error("migrated file still holds the key in clear")
+	}
+	store = loadKeys(t, path)
+	if _, ok := store.Verify("sk-legacy-key"); !ok || store.Plaintext() != 0 {
+		t.Errorf("migrated key verifies %t with %d plaintext keys; want true with 0", ok, store.Plaintext())
+	}
+}
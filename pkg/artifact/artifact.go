@@ -0,0 +1,145 @@
+// Package artifact keeps the images and audio providers generate in a
+// content-addressed store, so clients get stable gateway URLs instead of
+// provider links that expire
+package artifact
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Artifact defaults
+const (
+	// DefaultTTL is how long artifacts are kept and their URLs stay valid
+	DefaultTTL = 24 * time.Hour
+	// DefaultMaxSize bounds the artifacts fetched from providers
+	DefaultMaxSize = 20 << 20
+)
+
+// RoutePrefix is the path the gateway serves artifacts under
+const RoutePrefix = "/api/v1/artifacts/"
+
+// Errors opening artifacts
+var (
+	ErrNotFound         = errors.New("artifact not found")
+	ErrExpired          = errors.New("artifact URL expired")
+	ErrInvalidSignature = errors.New("invalid artifact URL signature")
+)
+
+// Info describes a stored artifact
+type Info struct {
+	Key         string    `json:"key"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	Modified    time.Time `json:"modified"`
+}
+
+// Store keeps artifacts under their keys. Storing a key again refreshes it
+type Store interface {
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	// Get returns ErrNotFound for unknown keys
+	Get(ctx context.Context, key string) (io.ReadCloser, Info, error)
+	Delete(ctx context.Context, key string) error
+	List(ctx context.Context) ([]Info, error)
+}
+
+// keyPattern matches keys: a SHA-256 and an optional extension
+var keyPattern = regexp.MustCompile(`^[0-9a-f]{64}(\.[a-z0-9]+)?$`)
+
+// ValidKey reports whether key could name an artifact, which keeps paths out
+// of stores
+func ValidKey(key string) bool {
+	return keyPattern.MatchString(key)
+}
+
+// Key returns the key of content of contentType: its SHA-256 with the
+// extension of its type
+func Key(contentType string, data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]) + extension(contentType)
+}
+
+// preferredExtensions are the extensions of common media types, which mime
+// lists ambiguously or not at all
+var preferredExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+	"audio/mpeg": ".mp3",
+	"audio/wav":  ".wav",
+	"audio/ogg":  ".ogg",
+	"audio/flac": ".flac",
+	"audio/mp4":  ".m4a",
+}
+
+// extension returns the file extension of contentType, "" when unknown
+func extension(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if ext, ok := preferredExtensions[mediaType]; ok {
+		return ext
+	}
+	if extensions, _ := mime.ExtensionsByType(mediaType); len(extensions) > 0 {
+		return extensions[0]
+	}
+	return ""
+}
+
+// contentTypeOf returns the content type of a key by its extension
+func contentTypeOf(key string) string {
+	if i := strings.LastIndexByte(key, '.'); i >= 0 {
+		for mediaType, ext := range preferredExtensions {
+			if ext == key[i:] {
+				return mediaType
+			}
+		}
+		if contentType := mime.TypeByExtension(key[i:]); contentType != "" {
+			return contentType
+		}
+	}
+	return "application/octet-stream"
+}
+
+// Signer signs artifact URLs so they can be fetched without an API key
+// until they expire
+type Signer struct {
+	secret []byte
+}
+
+// NewSigner creates a signer with secret
+func NewSigner(secret []byte) *Signer {
+	return &Signer{secret: secret}
+}
+
+// Sign returns the signature of key's URL expiring at expires
+func (s *Signer) Sign(key string, expires time.Time) string {
+	mac := hmac.New(sha256.New, s.secret)
+	fmt.Fprintf(mac, "%s:%d", key, expires.Unix())
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature of key against expires, a Unix time, at now
+func (s *Signer) Verify(key, expires, signature string, now time.Time) error {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	expiry := time.Unix(unix, 0)
+	if !hmac.Equal([]byte(s.Sign(key, expiry)), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+	if !now.Before(expiry) {
+		return ErrExpired
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package artifact
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DiskStore keeps artifacts as files in a directory, named by their keys
+type DiskStore struct {
+	dir string
+}
+
+// NewDiskStore creates a store in dir, creating the directory if needed
+func NewDiskStore(dir string) (*DiskStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create artifact directory: %w", err)
+	}
+	return &DiskStore{dir: dir}, nil
+}
+
+// Put implements Store. Content with a key already stored is not written
+// again, only refreshed
+func (d *DiskStore) Put(ctx context.Context, key, contentType string, data []byte) error {
+	path := filepath.Join(d.dir, key)
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err == nil {
+		return nil
+	}
+
+	// Write to a temporary file first so readers never see a partial artifact
+	file, err := os.CreateTemp(d.dir, ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to store artifact: %w", err)
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return fmt.Errorf("failed to store artifact: %w", err)
+	}
+	return nil
+}
+
+// Get implements Store
+func (d *DiskStore) Get(ctx context.Context, key string) (io.ReadCloser, Info, error) {
+	file, err := os.Open(filepath.Join(d.dir, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, Info{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, Info{}, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, Info{}, err
+	}
+	return file, Info{Key: key, ContentType: contentTypeOf(key), Size: stat.Size(), Modified: stat.ModTime()}, nil
+}
+
+// Delete implements Store
+func (d *DiskStore) Delete(ctx context.Context, key string) error {
+	if err := os.Remove(filepath.Join(d.dir, key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// List implements Store
+func (d *DiskStore) List(ctx context.Context) ([]Info, error) {
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]Info, 0, len(entries))
+	for _, entry := range entries {
+		if !ValidKey(entry.Name()) {
+			continue
+		}
+		stat, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		infos = append(infos, Info{Key: entry.Name(), ContentType: contentTypeOf(entry.Name()), Size: stat.Size(), Modified: stat.ModTime()})
+	}
+	return infos, nil
+}
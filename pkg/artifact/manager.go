@@ -0,0 +1,268 @@
+package artifact
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Config sets how a Manager keeps and links artifacts
+type Config struct {
+	// Secret signs artifact URLs
+	Secret []byte
+	// TTL is how long artifacts are kept and their URLs stay valid
+	TTL time.Duration
+	// BaseURL is prepended to artifact URLs, e.g. https://gateway.example.com;
+	// empty leaves them relative
+	BaseURL string
+	// MaxSize bounds the artifacts fetched or decoded
+	MaxSize int64
+	// AllowPrivate lets provider links point at private and loopback
+	// addresses, which are refused so responses cannot make the gateway fetch
+	// from its own network
+	AllowPrivate bool
+}
+
+// Artifact is an artifact a response links to through the gateway
+type Artifact struct {
+	Key         string    `json:"key"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	URL         string    `json:"url"`
+	Expires     time.Time `json:"expires"`
+}
+
+// Manager stores the artifacts of responses and hands out signed URLs for
+// them
+type Manager struct {
+	store  Store
+	signer *Signer
+	config Config
+	client *http.Client
+}
+
+// NewManager creates a manager keeping artifacts in store. A non-positive
+// TTL or MaxSize takes the default
+func NewManager(store Store, config Config) *Manager {
+	if config.TTL <= 0 {
+		config.TTL = DefaultTTL
+	}
+	if config.MaxSize <= 0 {
+		config.MaxSize = DefaultMaxSize
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !config.AllowPrivate {
+		dialer.Control = refusePrivate
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+	return &Manager{
+		store:  store,
+		signer: NewSigner(config.Secret),
+		config: config,
+		client: &http.Client{Timeout: time.Minute, Transport: transport},
+	}
+}
+
+// refusePrivate refuses connections to addresses that are not public
+func refusePrivate(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("artifact host %s is not public", host)
+	}
+	return nil
+}
+
+// Save stores data of contentType and returns a signed URL for it
+func (m *Manager) Save(ctx context.Context, contentType string, data []byte) (Artifact, error) {
+	if int64(len(data)) > m.config.MaxSize {
+		return Artifact{}, fmt.Errorf("artifact of %d bytes exceeds the limit of %d", len(data), m.config.MaxSize)
+	}
+	key := Key(contentType, data)
+	if err := m.store.Put(ctx, key, contentType, data); err != nil {
+		return Artifact{}, err
+	}
+	expires := time.Now().Add(m.config.TTL).Truncate(time.Second)
+	return Artifact{
+		Key:         key,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		URL:         m.URL(key, expires),
+		Expires:     expires,
+	}, nil
+}
+
+// URL returns the signed URL of key valid until expires
+func (m *Manager) URL(key string, expires time.Time) string {
+	return fmt.Sprintf("%s%s%s?expires=%d&signature=%s", m.config.BaseURL, RoutePrefix, key, expires.Unix(), m.signer.Sign(key, expires))
+}
+
+// Open returns the artifact under key when its URL's expires and signature
+// are valid at now
+func (m *Manager) Open(ctx context.Context, key, expires, signature string, now time.Time) (io.ReadCloser, Info, error) {
+	if !ValidKey(key) {
+		return nil, Info{}, ErrNotFound
+	}
+	if err := m.signer.Verify(key, expires, signature, now); err != nil {
+		return nil, Info{}, err
+	}
+	return m.store.Get(ctx, key)
+}
+
+// Fetch downloads the image or audio a provider link points at and stores it
+func (m *Manager) Fetch(ctx context.Context, link string) (Artifact, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return Artifact{}, err
+	}
+	response, err := m.client.Do(request)
+	if err != nil {
+		return Artifact{}, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return Artifact{}, fmt.Errorf("fetching %s returned %d", request.URL.Host, response.StatusCode)
+	}
+	contentType := response.Header.Get("Content-Type")
+	if !isMedia(contentType) {
+		return Artifact{}, fmt.Errorf("%s returned %q, not an image or audio", request.URL.Host, contentType)
+	}
+	data, err := io.ReadAll(io.LimitReader(response.Body, m.config.MaxSize+1))
+	if err != nil {
+		return Artifact{}, err
+	}
+	return m.Save(ctx, contentType, data)
+}
+
+// isMedia reports whether contentType is an image or audio type
+func isMedia(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return strings.HasPrefix(mediaType, "image/") || strings.HasPrefix(mediaType, "audio/")
+}
+
+// Patterns of the artifacts found in responses: base64 data URIs, and links
+// to files with image or audio extensions
+var (
+	dataURIPattern   = regexp.MustCompile(`data:((?:image|audio)/[\w.+-]+);base64,([A-Za-z0-9+/]+=*)`)
+	mediaLinkPattern = regexp.MustCompile(`(?i)https?://[^\s"'<>()\[\]]+?\.(?:png|jpe?g|gif|webp|mp3|wav|ogg|flac|m4a|opus)(?:\?[^\s"'<>()\[\]]*)?`)
+)
+
+// Rewrite stores the artifacts content embeds or links to and replaces them
+// with their gateway URLs. Artifacts that cannot be stored keep their
+// original link, and the errors are joined in the returned error
+func (m *Manager) Rewrite(ctx context.Context, content string) (string, []Artifact, error) {
+	var artifacts []Artifact
+	var errs []error
+	rewritten := make(map[string]string)
+	rewrite := func(source string, save func() (Artifact, error)) string {
+		if target, ok := rewritten[source]; ok {
+			return target
+		}
+		artifact, err := save()
+		if err != nil {
+			errs = append(errs, err)
+			rewritten[source] = source
+			return source
+		}
+		artifacts = append(artifacts, artifact)
+		rewritten[source] = artifact.URL
+		return artifact.URL
+	}
+
+	content = dataURIPattern.ReplaceAllStringFunc(content, func(uri string) string {
+		return rewrite(uri, func() (Artifact, error) {
+			match := dataURIPattern.FindStringSubmatch(uri)
+			if int64(base64.StdEncoding.DecodedLen(len(match[2]))) > m.config.MaxSize {
+				return Artifact{}, fmt.Errorf("embedded %s exceeds the limit of %d bytes", match[1], m.config.MaxSize)
+			}
+			data, err := base64.StdEncoding.DecodeString(match[2])
+			if err != nil {
+				return Artifact{}, fmt.Errorf("embedded %s is not valid base64: %w", match[1], err)
+			}
+			return m.Save(ctx, match[1], data)
+		})
+	})
+	content = mediaLinkPattern.ReplaceAllStringFunc(content, func(link string) string {
+		if m.own(link) {
+			return link
+		}
+		return rewrite(link, func() (Artifact, error) { return m.Fetch(ctx, link) })
+	})
+	return content, artifacts, errors.Join(errs...)
+}
+
+// own reports whether link already points at an artifact of this gateway
+func (m *Manager) own(link string) bool {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	if m.config.BaseURL != "" && !strings.HasPrefix(link, m.config.BaseURL+RoutePrefix) {
+		return false
+	}
+	return strings.HasPrefix(parsed.Path, RoutePrefix) && parsed.Query().Has("signature")
+}
+
+// Cleanup deletes the artifacts not stored again for the TTL and returns
+// how many it deleted
+func (m *Manager) Cleanup(ctx context.Context, now time.Time) (int, error) {
+	infos, err := m.store.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	var errs []error
+	for _, info := range infos {
+		if now.Sub(info.Modified) < m.config.TTL {
+			continue
+		}
+		if err := m.store.Delete(ctx, info.Key); err != nil {
+			errs = append(errs, fmt.Errorf("artifact %s: %w", info.Key, err))
+			continue
+		}
+		deleted++
+	}
+	return deleted, errors.Join(errs...)
+}
+
+// StartJanitor deletes expired artifacts every interval until ctx is done,
+// reporting failures to onError
+func (m *Manager) StartJanitor(ctx context.Context, interval time.Duration, onError func(error)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if _, err := m.Cleanup(ctx, now); err != nil && onError != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+}
+
+// TTL returns how long artifacts are kept
+func (m *Manager) TTL() time.Duration {
+	return m.config.TTL
+}
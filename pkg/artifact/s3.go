@@ -0,0 +1,98 @@
+package artifact
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/objstore"
+)
+
+// S3Config locates a bucket of an S3-compatible object store
+type S3Config struct {
+	// Endpoint is the store's base URL, e.g. https://s3.eu-west-1.amazonaws.com
+	// or a MinIO server. Buckets are addressed path-style
+	Endpoint  string
+	Region    string
+	Bucket    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+}
+
+// S3Store keeps artifacts as objects in an S3-compatible bucket
+type S3Store struct {
+	prefix string
+	bucket *objstore.S3
+}
+
+// NewS3Store creates a store in the configured bucket
+func NewS3Store(config S3Config) (*S3Store, error) {
+	if config.AccessKey == "" || config.SecretKey == "" {
+		return nil, fmt.Errorf("artifact store needs S3 credentials")
+	}
+	bucket, err := objstore.NewS3(objstore.S3Config{
+		Endpoint: config.Endpoint,
+		Bucket:   config.Bucket,
+		Credentials: objstore.Credentials{
+			AccessKey: config.AccessKey,
+			SecretKey: config.SecretKey,
+			Region:    config.Region,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("artifact store: %w", err)
+	}
+	return &S3Store{prefix: config.Prefix, bucket: bucket}, nil
+}
+
+// Put implements Store
+func (s *S3Store) Put(ctx context.Context, key, contentType string, data []byte) error {
+	header := http.Header{"Content-Type": {contentType}}
+	response, err := s.bucket.Do(ctx, http.MethodPut, s.prefix+key, nil, header, data)
+	if err != nil {
+		return fmt.Errorf("failed to store artifact: %w", err)
+	}
+	response.Body.Close()
+	return nil
+}
+
+// Get implements Store
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, Info, error) {
+	response, err := s.bucket.Do(ctx, http.MethodGet, s.prefix+key, nil, nil, nil)
+	if err == objstore.ErrNotFound {
+		return nil, Info{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, Info{}, err
+	}
+	info := Info{Key: key, ContentType: response.Header.Get("Content-Type"), Size: response.ContentLength}
+	info.Modified, _ = http.ParseTime(response.Header.Get("Last-Modified"))
+	if info.ContentType == "" {
+		info.ContentType = contentTypeOf(key)
+	}
+	return response.Body, info, nil
+}
+
+// Delete implements Store
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	return s.bucket.Delete(ctx, s.prefix+key)
+}
+
+// List implements Store
+func (s *S3Store) List(ctx context.Context) ([]Info, error) {
+	objects, err := s.bucket.List(ctx, s.prefix)
+	if err != nil {
+		return nil, err
+	}
+	var infos []Info
+	for _, object := range objects {
+		key := strings.TrimPrefix(object.Name, s.prefix)
+		if ValidKey(key) {
+			infos = append(infos, Info{Key: key, ContentType: contentTypeOf(key), Size: object.Size, Modified: object.Modified})
+		}
+	}
+	return infos, nil
+}
@@ -0,0 +1,129 @@
+// Package assistant provides the "assistant model": the LLM the gateway asks
+// for its own meta-analysis, such as insights and provider configuration. It
+// can be Pollinations, a local Ollama or any provider in the routing table
+package assistant
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/pollinations"
+)
+
+// Model generates text for the gateway's internal analysis
+type Model interface {
+	GenerateText(ctx context.Context, prompt string) (string, error)
+}
+
+// Well-known assistant providers that need no routing table entry
+const (
+	ProviderPollinations = "pollinations"
+	ProviderOllama       = "ollama"
+)
+
+// DefaultOllamaURL is the OpenAI-compatible API of a local Ollama
+const DefaultOllamaURL = "http://localhost:11434/v1"
+
+// Config selects the assistant model
+type Config struct {
+	// Provider is "pollinations" (the default), "ollama" or the name of a
+	// provider in the routing table
+	Provider string
+	// Model defaults to the provider's first model; required for Ollama
+	Model string
+	// BaseURL overrides the provider's base URL
+	BaseURL string
+	APIKey  string
+	Timeout time.Duration
+}
+
+// Endpoint is a routing table provider the assistant may run on
+type Endpoint struct {
+	Name    string
+	BaseURL string
+	Models  []string
+}
+
+// ConfigFromEnv reads ASSISTANT_PROVIDER, ASSISTANT_MODEL, ASSISTANT_BASE_URL,
+// ASSISTANT_API_KEY and ASSISTANT_TIMEOUT
+func ConfigFromEnv() (Config, error) {
+	config := Config{
+		Provider: os.Getenv("ASSISTANT_PROVIDER"),
+		Model:    os.Getenv("ASSISTANT_MODEL"),
+		BaseURL:  os.Getenv("ASSISTANT_BASE_URL"),
+		APIKey:   os.Getenv("ASSISTANT_API_KEY"),
+	}
+	if raw := os.Getenv("ASSISTANT_TIMEOUT"); raw != "" {
+		timeout, err := time.ParseDuration(raw)
+		if err != nil || timeout <= 0 {
+			return Config{}, fmt.Errorf("invalid ASSISTANT_TIMEOUT %q: must be a positive duration", raw)
+		}
+		config.Timeout = timeout
+	}
+	return config, nil
+}
+
+// New builds the assistant model described by config. Providers other than
+// Pollinations are called through their OpenAI-compatible chat API, with the
+// base URL and default model taken from the matching endpoint
+func New(config Config, endpoints []Endpoint) (Model, error) {
+	name := strings.TrimSpace(config.Provider)
+
+	switch {
+	case name == "" || strings.EqualFold(name, ProviderPollinations):
+		if config.BaseURL != "" {
+			return nil, fmt.Errorf("assistant provider %s does not support a custom base URL", ProviderPollinations)
+		}
+		return pollinations.NewClient(), nil
+
+	case strings.EqualFold(name, ProviderOllama):
+		if config.Model == "" {
+			return nil, fmt.Errorf("assistant provider %s needs a model", ProviderOllama)
+		}
+		baseURL := config.BaseURL
+		if baseURL == "" {
+			baseURL = DefaultOllamaURL
+		}
+		return NewOpenAIClient(ProviderOllama, baseURL, config.Model, config.APIKey, config.Timeout), nil
+	}
+
+	baseURL, model := config.BaseURL, config.Model
+	found := false
+	for _, endpoint := range endpoints {
+		if !strings.EqualFold(endpoint.Name, name) {
+			continue
+		}
+		found = true
+		if baseURL == "" {
+			baseURL = endpoint.BaseURL
+		}
+		if model == "" && len(endpoint.Models) > 0 {
+			model = endpoint.Models[0]
+		}
+		break
+	}
+	if !found && baseURL == "" {
+		return nil, fmt.Errorf("assistant provider %q is not in the routing table", name)
+	}
+	if model == "" {
+		return nil, fmt.Errorf("assistant provider %q has no model; set one explicitly", name)
+	}
+	return NewOpenAIClient(name, baseURL, model, config.APIKey, config.Timeout), nil
+}
+
+// Describe names the model for logs, e.g. "ollama/llama3.1"
+func Describe(model Model) string {
+	switch m := model.(type) {
+	case nil:
+		return "none"
+	case *OpenAIClient:
+		return m.provider + "/" + m.model
+	case *pollinations.Client:
+		return ProviderPollinations
+	default:
+		return fmt.Sprintf("%T", model)
+	}
+}
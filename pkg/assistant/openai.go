@@ -0,0 +1,116 @@
+package assistant
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/requestid"
+)
+
+// defaultTimeout bounds a single assistant call
+const defaultTimeout = 60 * time.Second
+
+// OpenAIClient calls an OpenAI-compatible chat completions API, which covers
+// most hosted providers as well as Ollama, vLLM and LM Studio
+type OpenAIClient struct {
+	provider   string
+	baseURL    string
+	model      string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// NewOpenAIClient creates a client for model at baseURL, e.g. https://api.openai.com/v1
+func NewOpenAIClient(provider, baseURL, model, apiKey string, timeout time.Duration) *OpenAIClient {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &OpenAIClient{
+		provider:   provider,
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		model:      model,
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// chatRequest is the subset of the chat completions request the assistant uses
+type chatRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+	Stream   bool          `json:"stream"`
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// chatResponse is the subset of the chat completions response the assistant reads
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// GenerateText sends prompt as a single user message and returns the reply
+func (c *OpenAIClient) GenerateText(ctx context.Context, prompt string) (string, error) {
+	if prompt == "" {
+		return "", fmt.Errorf("prompt cannot be empty")
+	}
+
+	body, err := json.Marshal(chatRequest{
+		Model:    c.model,
+		Messages: []chatMessage{{Role: "user", Content: prompt}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Your-PaL-MoE/1.0")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	requestid.Inject(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to call %s: %w", c.provider, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var parsed chatResponse
+	decodeErr := json.Unmarshal(data, &parsed)
+	if resp.StatusCode != http.StatusOK {
+		if decodeErr == nil && parsed.Error != nil && parsed.Error.Message != "" {
+			return "", fmt.Errorf("%s returned status %d: %s", c.provider, resp.StatusCode, parsed.Error.Message)
+		}
+		return "", fmt.Errorf("%s returned status %d", c.provider, resp.StatusCode)
+	}
+	if decodeErr != nil {
+		return "", fmt.Errorf("failed to decode response: %w", decodeErr)
+	}
+	if len(parsed.Choices) == 0 {
+		return "", fmt.Errorf("%s returned no choices", c.provider)
+	}
+	return parsed.Choices[0].Message.Content, nil
+}
@@ -0,0 +1,275 @@
+// Package audit records who did what through the admin API, and whether it
+// worked, so that changes can be traced back to the key that made them
+package audit
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidCursor is returned for page cursors the log did not issue
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Page sizes for audit log queries
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+// Event is one audited admin request
+type Event struct {
+	// ID orders events and is the pagination cursor
+	ID   int64     `json:"id"`
+	Time time.Time `json:"time"`
+	// Actor is the caller's key ID, or "anonymous"
+	Actor string `json:"actor"`
+	// Action is the method and route, e.g. "PUT /admin/tenants/{id}"
+	Action string `json:"action"`
+	// Resource is the path the action applied to, e.g. "/admin/tenants/acme"
+	Resource   string `json:"resource"`
+	Success    bool   `json:"success"`
+	Status     int    `json:"status"`
+	RequestID  string `json:"request_id,omitempty"`
+	RemoteAddr string `json:"remote_addr,omitempty"`
+	// Detail explains events the server raised itself, such as a flagged
+	// prompt
+	Detail string `json:"detail,omitempty"`
+}
+
+// Query filters and pages the audit log. Empty fields match every event
+type Query struct {
+	Actor  string
+	Action string
+	// Resource matches the resource and everything below it
+	Resource string
+	Since    time.Time
+	Until    time.Time
+	Success  *bool
+	// Limit is the page size, DefaultPageSize when zero
+	Limit int
+	// Cursor is the NextCursor of the previous page
+	Cursor string
+}
+
+// Page is one page of the audit log, newest first
+type Page struct {
+	Events []Event `json:"events"`
+	// NextCursor fetches the following page; empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Log stores audit events
+type Log interface {
+	RecordAuditEvent(event Event) error
+	QueryAuditEvents(query Query) (*Page, error)
+}
+
+// Matches reports whether event passes the filters of q
+func (q Query) Matches(event *Event) bool {
+	switch {
+	case q.Actor != "" && event.Actor != q.Actor,
+		q.Action != "" && event.Action != q.Action,
+		q.Resource != "" && event.Resource != q.Resource && !strings.HasPrefix(event.Resource, strings.TrimSuffix(q.Resource, "/")+"/"),
+		!q.Since.IsZero() && event.Time.Before(q.Since),
+		!q.Until.IsZero() && !event.Time.Before(q.Until),
+		q.Success != nil && event.Success != *q.Success:
+		return false
+	}
+	return true
+}
+
+// PageBounds returns the page size and the event ID to list before, zero
+// for the first page
+func (q Query) PageBounds() (int, int64, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+	if q.Cursor == "" {
+		return limit, 0, nil
+	}
+	before, err := strconv.ParseInt(q.Cursor, 10, 64)
+	if err != nil || before <= 0 {
+		return 0, 0, fmt.Errorf("%w %q", ErrInvalidCursor, q.Cursor)
+	}
+	return limit, before, nil
+}
+
+// CSVHeader is the first row of CSV exports
+var CSVHeader = []string{"id", "time", "actor", "action", "resource", "success", "status", "request_id", "remote_addr", "detail"}
+
+// WriteCSV writes events as CSV rows below CSVHeader
+func WriteCSV(w *csv.Writer, events []Event) error {
+	for _, event := range events {
+		if err := w.Write([]string{
+			strconv.FormatInt(event.ID, 10),
+			event.Time.UTC().Format(time.RFC3339Nano),
+			event.Actor,
+			event.Action,
+			event.Resource,
+			strconv.FormatBool(event.Success),
+			strconv.Itoa(event.Status),
+			event.RequestID,
+			event.RemoteAddr,
+			event.Detail,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Export writes every event matching query, newest first, as CSV or as a
+// JSON array, fetching the log page by page so exports need little memory
+func Export(w io.Writer, log Log, query Query, format string) error {
+	query.Limit = MaxPageSize
+	query.Cursor = ""
+
+	var csvWriter *csv.Writer
+	switch format {
+	case "csv":
+		csvWriter = csv.NewWriter(w)
+		if err := csvWriter.Write(CSVHeader); err != nil {
+			return err
+		}
+	case "json":
+		if _, err := io.WriteString(w, "["); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+
+	first := true
+	for {
+		page, err := log.QueryAuditEvents(query)
+		if err != nil {
+			return err
+		}
+		if csvWriter != nil {
+			if err := WriteCSV(csvWriter, page.Events); err != nil {
+				return err
+			}
+			csvWriter.Flush()
+		} else if err := writeJSONEvents(w, page.Events, &first); err != nil {
+			return err
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	if csvWriter != nil {
+		return csvWriter.Error()
+	}
+	_, err := io.WriteString(w, "]\n")
+	return err
+}
+
+// writeJSONEvents writes events as elements of a JSON array; first tracks
+// whether a separator is needed
+func writeJSONEvents(w io.Writer, events []Event, first *bool) error {
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if !*first {
+			data = append([]byte(","), data...)
+		}
+		*first = false
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MemoryLog keeps the most recent events in memory
+type MemoryLog struct {
+	events    []Event
+	size      int
+	retention time.Duration
+	nextID    int64
+	mutex     sync.RWMutex
+}
+
+// NewMemoryLog creates a log holding up to size events
+func NewMemoryLog(size int) *MemoryLog {
+	return &MemoryLog{size: size}
+}
+
+// RecordAuditEvent adds an event, dropping the oldest when full
+func (l *MemoryLog) RecordAuditEvent(event Event) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.nextID++
+	event.ID = l.nextID
+	l.events = append(l.events, event)
+	if len(l.events) > l.size {
+		l.events = l.events[len(l.events)-l.size:]
+	}
+	return nil
+}
+
+// QueryAuditEvents returns a page of the events matching query
+func (l *MemoryLog) QueryAuditEvents(query Query) (*Page, error) {
+	limit, before, err := query.PageBounds()
+	if err != nil {
+		return nil, err
+	}
+
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	page := &Page{Events: []Event{}}
+	for i := len(l.events) - 1; i >= 0; i-- {
+		event := &l.events[i]
+		if before != 0 && event.ID >= before || !query.Matches(event) {
+			continue
+		}
+		if len(page.Events) == limit {
+			page.NextCursor = strconv.FormatInt(page.Events[limit-1].ID, 10)
+			break
+		}
+		page.Events = append(page.Events, *event)
+	}
+	return page, nil
+}
+
+// SetRetention sets how long events are kept; zero or less keeps them until
+// the log is full
+func (l *MemoryLog) SetRetention(retention time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.retention = retention
+}
+
+// Prune drops events past retention and returns how many were dropped
+func (l *MemoryLog) Prune() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.retention <= 0 {
+		return 0
+	}
+	cutoff := time.Now().Add(-l.retention)
+	drop := 0
+	for drop < len(l.events) && l.events[drop].Time.Before(cutoff) {
+		drop++
+	}
+	l.events = append([]Event(nil), l.events[drop:]...)
+	return drop
+}
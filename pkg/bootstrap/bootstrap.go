@@ -0,0 +1,87 @@
+// Package bootstrap prepares the data directory of a first run, so the
+// gateway starts in an empty container without files mounted into it
+package bootstrap
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// starter holds the files written on a first run
+//
+//go:embed starter
+var starter embed.FS
+
+// Layout is where the gateway keeps its files
+type Layout struct {
+	// ProvidersCSV receives the starter provider list when it does not exist
+	ProvidersCSV string
+	// Dirs are created when missing
+	Dirs []string
+	// Files get their parent directories created, so they can be written later
+	Files []string
+}
+
+// StarterProvidersCSV returns the provider list written on a first run: the
+// Pollinations text API, which needs no key
+func StarterProvidersCSV() []byte {
+	data, _ := starter.ReadFile("starter/providers.csv")
+	return data
+}
+
+// Prepare creates the directories of layout and writes the starter files that
+// do not exist yet, returning the paths it wrote. Existing files are never
+// touched, so the starter files can be edited freely
+func Prepare(layout Layout) ([]string, error) {
+	dirs := append([]string{}, layout.Dirs...)
+	for _, file := range append([]string{layout.ProvidersCSV}, layout.Files...) {
+		if file != "" {
+			dirs = append(dirs, filepath.Dir(file))
+		}
+	}
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+
+	var written []string
+	if layout.ProvidersCSV != "" {
+		ok, err := writeNew(layout.ProvidersCSV, StarterProvidersCSV())
+		if err != nil {
+			return written, err
+		}
+		if ok {
+			written = append(written, layout.ProvidersCSV)
+		}
+	}
+	return written, nil
+}
+
+// writeNew writes data to path unless the file exists, reporting whether it
+// wrote it
+func writeNew(path string, data []byte) (bool, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return false, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return true, nil
+}
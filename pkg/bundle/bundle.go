@@ -0,0 +1,229 @@
+// Package bundle exports configuration files as a signed tarball and verifies
+// such tarballs on import, so configuration can be promoted from staging to
+// production or carried into air-gapped environments
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ManifestVersion is the bundle format written by Export
+const ManifestVersion = 1
+
+// Entry names inside the tarball
+const (
+	manifestName  = "manifest.json"
+	signatureName = "manifest.sig"
+	filesPrefix   = "files/"
+)
+
+// tarEntry is one file written into the tarball
+type tarEntry struct {
+	name string
+	data []byte
+}
+
+// maxBundleBytes bounds the uncompressed size read from a bundle
+const maxBundleBytes = 64 << 20
+
+// ErrInvalidBundle is returned for tarballs that are malformed or whose
+// contents do not match the manifest
+var ErrInvalidBundle = errors.New("invalid config bundle")
+
+// ErrUntrustedSignature is returned when no trusted key verifies the manifest
+var ErrUntrustedSignature = errors.New("config bundle is not signed by a trusted key")
+
+// File is one configuration file in a bundle
+type File struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Size   int    `json:"size"`
+}
+
+// Manifest describes a bundle. Its signature covers the file hashes, so it
+// vouches for the contents as well
+type Manifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	// Source names the instance the bundle was exported from
+	Source string `json:"source,omitempty"`
+	// KeyID identifies the signing key, see KeyID
+	KeyID string `json:"key_id"`
+	Files []File `json:"files"`
+}
+
+// Bundle is a verified bundle: its manifest and the content of each file by path
+type Bundle struct {
+	Manifest Manifest
+	Contents map[string][]byte
+}
+
+// KeyID returns a short fingerprint of key for logs and manifests
+func KeyID(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Export writes files as a gzipped tarball signed with key. Paths must be
+// relative and inside the working directory, as they are written back to
+// the same paths on import
+func Export(w io.Writer, files []string, key ed25519.PrivateKey, source string) (*Manifest, error) {
+	manifest := &Manifest{
+		Version:   ManifestVersion,
+		CreatedAt: time.Now().UTC(),
+		Source:    source,
+		KeyID:     KeyID(key.Public().(ed25519.PublicKey)),
+	}
+
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		file = filepath.ToSlash(filepath.Clean(file))
+		if !filepath.IsLocal(file) {
+			return nil, fmt.Errorf("cannot bundle %s: path must be relative to the working directory", file)
+		}
+		paths = append(paths, file)
+	}
+	sort.Strings(paths)
+
+	var contents [][]byte
+	for i, file := range paths {
+		if i > 0 && file == paths[i-1] {
+			continue
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		sum := sha256.Sum256(content)
+		manifest.Files = append(manifest.Files, File{Path: file, SHA256: hex.EncodeToString(sum[:]), Size: len(content)})
+		contents = append(contents, content)
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	entries := []tarEntry{
+		{manifestName, manifestData},
+		{signatureName, ed25519.Sign(key, manifestData)},
+	}
+	for i, file := range manifest.Files {
+		entries = append(entries, tarEntry{filesPrefix + file.Path, contents[i]})
+	}
+	for _, entry := range entries {
+		header := &tar.Header{
+			Name:    entry.name,
+			Mode:    0644,
+			Size:    int64(len(entry.data)),
+			ModTime: manifest.CreatedAt,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(entry.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Read parses a bundle and verifies it: the manifest must be signed by one
+// of trusted and every file must match its hash in the manifest. Nothing is
+// returned unless the whole bundle verifies
+func Read(r io.Reader, trusted []ed25519.PublicKey) (*Bundle, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	defer gz.Close()
+
+	entries := make(map[string][]byte)
+	tr := tar.NewReader(io.LimitReader(gz, maxBundleBytes))
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("%w: %s is not a regular file", ErrInvalidBundle, header.Name)
+		}
+		if _, ok := entries[header.Name]; ok {
+			return nil, fmt.Errorf("%w: %s appears more than once", ErrInvalidBundle, header.Name)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+		}
+		entries[header.Name] = data
+	}
+
+	manifestData, signature := entries[manifestName], entries[signatureName]
+	if manifestData == nil || signature == nil {
+		return nil, fmt.Errorf("%w: missing %s or %s", ErrInvalidBundle, manifestName, signatureName)
+	}
+	if !verify(manifestData, signature, trusted) {
+		return nil, ErrUntrustedSignature
+	}
+
+	bundle := &Bundle{Contents: make(map[string][]byte)}
+	if err := json.Unmarshal(manifestData, &bundle.Manifest); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	if bundle.Manifest.Version != ManifestVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidBundle, bundle.Manifest.Version)
+	}
+
+	for _, file := range bundle.Manifest.Files {
+		if !filepath.IsLocal(file.Path) || path.Clean(file.Path) != file.Path {
+			return nil, fmt.Errorf("%w: unsafe path %s", ErrInvalidBundle, file.Path)
+		}
+		content, ok := entries[filesPrefix+file.Path]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s is missing", ErrInvalidBundle, file.Path)
+		}
+		sum := sha256.Sum256(content)
+		if hex.EncodeToString(sum[:]) != file.SHA256 {
+			return nil, fmt.Errorf("%w: %s does not match its hash", ErrInvalidBundle, file.Path)
+		}
+		bundle.Contents[file.Path] = content
+	}
+	if len(entries) != len(bundle.Contents)+2 {
+		return nil, fmt.Errorf("%w: it holds files the manifest does not list", ErrInvalidBundle)
+	}
+	return bundle, nil
+}
+
+// verify reports whether any trusted key made signature over data
+func verify(data, signature []byte, trusted []ed25519.PublicKey) bool {
+	for _, key := range trusted {
+		if ed25519.Verify(key, data, signature) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,228 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// bundleFiles are the configuration files exported by the tests
+var bundleFiles = map[string]string{
+	"providers.csv":       "name,tier,endpoint\nOpenAI,official,https://api.openai.com/v1\n",
+	"configs/openai.yaml": "id: openai\nname: OpenAI\n",
+}
+
+// newKey returns a fresh signing key
+func newKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	return public, private
+}
+
+// exportBundle writes bundleFiles to a temporary working directory and
+// exports them signed with key
+func exportBundle(t *testing.T, key ed25519.PrivateKey) []byte {
+	t.Helper()
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	var paths []string
+	for path, content := range bundleFiles {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	var buf bytes.Buffer
+	if _, err := Export(&buf, paths, key, "staging"); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// rewriteBundle returns data with its tarball entries passed through edit,
+// which may change them or add and remove entries
+func rewriteBundle(t *testing.T, data []byte, edit func(entries map[string][]byte)) []byte {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := make(map[string][]byte)
+	var names []string
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[header.Name] = content
+		names = append(names, header.Name)
+	}
+
+	edit(entries)
+	for name := range entries {
+		found := false
+		for _, existing := range names {
+			found = found || existing == name
+		}
+		if !found {
+			names = append(names, name)
+		}
+	}
+
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	for _, name := range names {
+		content, ok := entries[name]
+		if !ok {
+			continue
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadVerifiesSignedBundle(t *testing.T) {
+	public, private := newKey(t)
+	other, _ := newKey(t)
+
+	bundle, err := Read(bytes.NewReader(exportBundle(t, private)), []ed25519.PublicKey{other, public})
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if bundle.Manifest.KeyID != KeyID(public) || bundle.Manifest.Source != "staging" {
+		t.Errorf("manifest key %s from %q, want %s from staging", bundle.Manifest.KeyID, bundle.Manifest.Source, KeyID(public))
+	}
+	if len(bundle.Contents) != len(bundleFiles) {
+		t.Errorf("bundle holds %d files, want %d", len(bundle.Contents), len(bundleFiles))
+	}
+	for path, content := range bundleFiles {
+		if string(bundle.Contents[path]) != content {
+			t.Errorf("content of %s = %q, want %q", path, bundle.Contents[path], content)
+		}
+	}
+}
+
+func TestReadRejectsWrongKey(t *testing.T) {
+	_, private := newKey(t)
+	other, _ := newKey(t)
+
+	data := exportBundle(t, private)
+	if _, err := Read(bytes.NewReader(data), []ed25519.PublicKey{other}); !errors.Is(err, ErrUntrustedSignature) {
+		t.Errorf("Read with the wrong key = %v, want ErrUntrustedSignature", err)
+	}
+	if _, err := Read(bytes.NewReader(data), nil); !errors.Is(err, ErrUntrustedSignature) {
+		t.Errorf("Read without trusted keys = %v, want ErrUntrustedSignature", err)
+	}
+}
+
+func TestReadRejectsUnsignedBundle(t *testing.T) {
+	public, private := newKey(t)
+	data := exportBundle(t, private)
+
+	tests := []struct {
+		name string
+		edit func(entries map[string][]byte)
+		want error
+	}{
+		{"no signature", func(entries map[string][]byte) {
+			delete(entries, signatureName)
+		}, ErrInvalidBundle},
+		{"empty signature", func(entries map[string][]byte) {
+			entries[signatureName] = []byte{}
+		}, ErrUntrustedSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(bytes.NewReader(rewriteBundle(t, data, tt.edit)), []ed25519.PublicKey{public})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Read = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadRejectsTamperedBundle(t *testing.T) {
+	public, private := newKey(t)
+	data := exportBundle(t, private)
+	tampered := []byte("name,tier,endpoint\nEvil,official,https://attacker.example\n")
+
+	tests := []struct {
+		name string
+		edit func(entries map[string][]byte)
+		want error
+	}{
+		{"changed file", func(entries map[string][]byte) {
+			entries[filesPrefix+"providers.csv"] = tampered
+		}, ErrInvalidBundle},
+		{"changed file and manifest hash", func(entries map[string][]byte) {
+			entries[filesPrefix+"providers.csv"] = tampered
+			oldSum := sha256.Sum256([]byte(bundleFiles["providers.csv"]))
+			newSum := sha256.Sum256(tampered)
+			entries[manifestName] = []byte(strings.Replace(string(entries[manifestName]),
+				hex.EncodeToString(oldSum[:]), hex.EncodeToString(newSum[:]), 1))
+		}, ErrUntrustedSignature},
+		{"removed file", func(entries map[string][]byte) {
+			delete(entries, filesPrefix+"configs/openai.yaml")
+		}, ErrInvalidBundle},
+		{"added file", func(entries map[string][]byte) {
+			entries[filesPrefix+"server.yaml"] = []byte("admin:\n  insecure: true\n")
+		}, ErrInvalidBundle},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(bytes.NewReader(rewriteBundle(t, data, tt.edit)), []ed25519.PublicKey{public})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Read = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := Read(strings.NewReader("not a bundle"), []ed25519.PublicKey{public}); !errors.Is(err, ErrInvalidBundle) {
+		t.Errorf("Read of garbage = %v, want ErrInvalidBundle", err)
+	}
+}
@@ -0,0 +1,254 @@
+// Package cassette records the prompts sent to providers and their answers
+// in per-provider fixture files, and replays them so integration tests
+// recorded once against real providers run deterministically in CI.
+// Secrets are scrubbed before anything is written
+package cassette
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/scrub"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrNotRecorded is returned when replaying a request the cassette has no
+// interaction for
+var ErrNotRecorded = errors.New("interaction not recorded")
+
+// Mode decides whether providers are called, cassettes replayed or both
+type Mode string
+
+const (
+	// ModeReplay answers from the cassettes only and fails requests they do
+	// not cover
+	ModeReplay Mode = "replay"
+	// ModeRecord calls the providers and rewrites the cassettes of the
+	// providers it calls
+	ModeRecord Mode = "record"
+	// ModeAuto replays recorded requests and calls the provider for, and
+	// records, the others
+	ModeAuto Mode = "auto"
+)
+
+// ParseMode converts s to a Mode; empty is ModeReplay
+func ParseMode(s string) (Mode, error) {
+	switch mode := Mode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case "":
+		return ModeReplay, nil
+	case ModeReplay, ModeRecord, ModeAuto:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown cassette mode %q: must be replay, record or auto", s)
+}
+
+// Request is what was sent to a provider. Interactions match on all of it
+type Request struct {
+	Model        string `yaml:"model"`
+	SystemPrompt string `yaml:"system_prompt,omitempty"`
+	Prompt       string `yaml:"prompt"`
+}
+
+// Response is what the provider answered, or the error it failed with
+type Response struct {
+	Content    string  `yaml:"content,omitempty"`
+	TokensUsed int64   `yaml:"tokens_used,omitempty"`
+	Cost       float64 `yaml:"cost,omitempty"`
+	Error      string  `yaml:"error,omitempty"`
+}
+
+// Err returns the recorded error, nil when the call succeeded
+func (r Response) Err() error {
+	if r.Error == "" {
+		return nil
+	}
+	return errors.New(r.Error)
+}
+
+// Interaction is one recorded call
+type Interaction struct {
+	Request    Request   `yaml:"request"`
+	Response   Response  `yaml:"response"`
+	RecordedAt time.Time `yaml:"recorded_at"`
+}
+
+// Cassette is the file of one provider's interactions
+type Cassette struct {
+	Provider     string        `yaml:"provider"`
+	Interactions []Interaction `yaml:"interactions"`
+}
+
+// cassetteState is a loaded cassette and which interactions were replayed
+type cassetteState struct {
+	cassette Cassette
+	played   []bool
+}
+
+// unsafeFileChars are replaced in provider names to form file names
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Library is a directory of cassettes, one per provider. It is safe for
+// concurrent use
+type Library struct {
+	dir       string
+	mode      Mode
+	scrubber  *scrub.Scrubber
+	cassettes map[string]*cassetteState
+	mutex     sync.Mutex
+}
+
+// NewLibrary opens the cassettes in dir. scrubber masks secrets in what is
+// recorded and in the requests matched against it; nil uses the default
+// patterns. Recording creates dir when it does not exist
+func NewLibrary(dir string, mode Mode, scrubber *scrub.Scrubber) (*Library, error) {
+	if _, err := ParseMode(string(mode)); err != nil {
+		return nil, err
+	}
+	if mode == "" {
+		mode = ModeReplay
+	}
+	if scrubber == nil {
+		scrubber = scrub.NewDefault()
+	}
+	if mode == ModeReplay {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open cassette directory: %w", err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("cassette directory %s is not a directory", dir)
+		}
+	} else if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cassette directory: %w", err)
+	}
+	return &Library{
+		dir:       dir,
+		mode:      mode,
+		scrubber:  scrubber,
+		cassettes: make(map[string]*cassetteState),
+	}, nil
+}
+
+// Mode returns the library's mode
+func (l *Library) Mode() Mode {
+	return l.mode
+}
+
+// Path returns the file of a provider's cassette
+func (l *Library) Path(provider string) string {
+	name := strings.Trim(unsafeFileChars.ReplaceAllString(strings.ToLower(provider), "_"), "_")
+	if name == "" {
+		name = "provider"
+	}
+	return filepath.Join(l.dir, name+".yaml")
+}
+
+// Replay returns the recorded response to a request. Identical requests are
+// answered by their interactions in recorded order, the last one repeating
+// once all were replayed. It returns an error wrapping ErrNotRecorded when
+// the provider's cassette has no interaction for the request
+func (l *Library) Replay(provider string, request Request) (Response, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	state, err := l.load(provider)
+	if err != nil {
+		return Response{}, err
+	}
+	request = l.scrubRequest(request)
+	last := -1
+	for i, interaction := range state.cassette.Interactions {
+		if interaction.Request != request {
+			continue
+		}
+		if !state.played[i] {
+			state.played[i] = true
+			return interaction.Response, nil
+		}
+		last = i
+	}
+	if last >= 0 {
+		return state.cassette.Interactions[last].Response, nil
+	}
+	return Response{}, fmt.Errorf("%w: %s model %s in %s", ErrNotRecorded, provider, request.Model, l.Path(provider))
+}
+
+// Record scrubs an interaction, adds it to the provider's cassette and
+// writes the cassette. In ModeRecord the first interaction recorded for a
+// provider replaces its existing cassette
+func (l *Library) Record(provider string, request Request, response Response) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	state, err := l.load(provider)
+	if err != nil {
+		return err
+	}
+	state.cassette.Interactions = append(state.cassette.Interactions, Interaction{
+		Request: l.scrubRequest(request),
+		Response: Response{
+			Content:    l.scrubber.Scrub(response.Content),
+			TokensUsed: response.TokensUsed,
+			Cost:       response.Cost,
+			Error:      l.scrubber.Scrub(response.Error),
+		},
+		RecordedAt: time.Now().UTC(),
+	})
+	// the interaction was just made, so a replay in ModeAuto must not
+	// answer an identical request with it before earlier recordings
+	state.played = append(state.played, true)
+
+	data, err := yaml.Marshal(state.cassette)
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette: %w", err)
+	}
+	path := l.Path(provider)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return nil
+}
+
+// load returns a provider's cassette, reading it on first use. In
+// ModeRecord cassettes start empty. The caller holds the mutex
+func (l *Library) load(provider string) (*cassetteState, error) {
+	if state, ok := l.cassettes[provider]; ok {
+		return state, nil
+	}
+	state := &cassetteState{cassette: Cassette{Provider: provider}}
+	if l.mode != ModeRecord {
+		data, err := os.ReadFile(l.Path(provider))
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return nil, fmt.Errorf("failed to read cassette: %w", err)
+		default:
+			if err := yaml.Unmarshal(data, &state.cassette); err != nil {
+				return nil, fmt.Errorf("failed to parse cassette %s: %w", l.Path(provider), err)
+			}
+		}
+	}
+	state.played = make([]bool, len(state.cassette.Interactions))
+	l.cassettes[provider] = state
+	return state, nil
+}
+
+// scrubRequest masks secrets in a request, so a live request matches its
+// scrubbed recording
+func (l *Library) scrubRequest(request Request) Request {
+	return Request{
+		Model:        request.Model,
+		SystemPrompt: l.scrubber.Scrub(request.SystemPrompt),
+		Prompt:       l.scrubber.Scrub(request.Prompt),
+	}
+}
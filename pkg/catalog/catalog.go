@@ -0,0 +1,226 @@
+// Package catalog converts public model catalogs, such as OpenRouter's model
+// list and LiteLLM proxy configs, into providers.csv rows, so a large
+// provider inventory can be seeded without writing each row by hand
+package catalog
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/probe"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
+)
+
+// Model is one model of a catalog
+type Model struct {
+	// ID is the model name sent upstream
+	ID string
+	// Vendor makes or serves the model, e.g. openai
+	Vendor   string
+	Endpoint string
+	// APIKey is the auth cell of the model's row, normally a ${VAR} reference
+	APIKey string
+	// Free models cost nothing and are imported into community rows
+	Free bool
+	// Tier overrides the tier of the model's row when the catalog states it
+	Tier     tier.Tier
+	Features []probe.Feature
+	// Overrides are the modalities the catalog states; unknown ones stay nil
+	Overrides config.CapabilityOverrides
+	Limits    map[string]int
+}
+
+// Options control how models are grouped into rows
+type Options struct {
+	// Source names the catalog, e.g. OpenRouter; it prefixes row names when
+	// set and is mentioned in their descriptions
+	Source string
+	// Tier of the rows of paid models; free models are community
+	Tier tier.Tier
+	// PerModel writes one row per model instead of one per vendor
+	PerModel bool
+}
+
+// vendorNames spells the vendors whose names are not just capitalized
+var vendorNames = map[string]string{
+	"openai":       "OpenAI",
+	"deepseek":     "DeepSeek",
+	"mistralai":    "MistralAI",
+	"meta-llama":   "Meta_Llama",
+	"x-ai":         "xAI",
+	"xai":          "xAI",
+	"together_ai":  "Together",
+	"fireworks_ai": "Fireworks",
+	"openrouter":   "OpenRouter",
+	"nvidia":       "NVIDIA",
+	"ai21":         "AI21",
+}
+
+// Rows groups models into providers.csv rows: one per vendor, endpoint, key
+// and tier, with free models apart, or one per model with opts.PerModel. Like
+// capability detection from model names, a row declares every feature and
+// modality any of its models has
+func Rows(models []Model, opts Options) []config.CSVProvider {
+	type group struct {
+		name   string
+		models []Model
+	}
+	var order []string
+	groups := make(map[string]*group)
+	for _, model := range models {
+		key := strings.Join([]string{model.Vendor, model.Endpoint, model.APIKey, fmt.Sprint(model.Free), string(model.Tier)}, "\x00")
+		name := vendorName(model.Vendor)
+		if opts.PerModel {
+			key += "\x00" + model.ID
+			name += "_" + modelName(model.ID)
+		} else if model.Free {
+			name += "_Free"
+		}
+		if opts.Source != "" && !strings.EqualFold(name, opts.Source) {
+			name = opts.Source + "_" + name
+		}
+		if _, ok := groups[key]; !ok {
+			groups[key] = &group{name: name}
+			order = append(order, key)
+		}
+		groups[key].models = append(groups[key].models, model)
+	}
+
+	taken := make(map[string]bool)
+	rows := make([]config.CSVProvider, 0, len(order))
+	for _, key := range order {
+		g := groups[key]
+		name := g.name
+		for i := 2; taken[strings.ToLower(name)]; i++ {
+			name = fmt.Sprintf("%s_%d", g.name, i)
+		}
+		taken[strings.ToLower(name)] = true
+		rows = append(rows, row(name, g.models, opts))
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Name < rows[j].Name })
+	return rows
+}
+
+// row merges the models of one group into a row
+func row(name string, models []Model, opts Options) config.CSVProvider {
+	first := models[0]
+	provider := config.CSVProvider{
+		Name:     name,
+		Tier:     opts.Tier,
+		Endpoint: first.Endpoint,
+		APIKey:   first.APIKey,
+	}
+	switch {
+	case first.Tier != "":
+		provider.Tier = first.Tier
+	case first.Free:
+		provider.Tier = tier.Community
+	}
+
+	ids := make([]string, 0, len(models))
+	features := make(map[probe.Feature]bool)
+	for _, model := range models {
+		ids = append(ids, model.ID)
+		for _, feature := range model.Features {
+			features[feature] = true
+		}
+		for limit, value := range model.Limits {
+			if current, ok := provider.Limits[limit]; !ok || value < current {
+				if provider.Limits == nil {
+					provider.Limits = make(map[string]int)
+				}
+				provider.Limits[limit] = value
+			}
+		}
+	}
+	sort.Strings(ids)
+	provider.ModelsSource = strings.Join(ids, "|")
+	for _, feature := range probe.Features {
+		if features[feature] {
+			provider.Capabilities = append(provider.Capabilities, string(feature))
+		}
+	}
+
+	overrides := &config.CapabilityOverrides{
+		Text:       anyTrue(models, func(o *config.CapabilityOverrides) *bool { return o.Text }),
+		Image:      anyTrue(models, func(o *config.CapabilityOverrides) *bool { return o.Image }),
+		Audio:      anyTrue(models, func(o *config.CapabilityOverrides) *bool { return o.Audio }),
+		Video:      anyTrue(models, func(o *config.CapabilityOverrides) *bool { return o.Video }),
+		Multimodal: anyTrue(models, func(o *config.CapabilityOverrides) *bool { return o.Multimodal }),
+	}
+	if !overrides.IsEmpty() {
+		provider.CapabilityOverrides = overrides
+	}
+
+	source := "a model catalog"
+	if opts.Source != "" {
+		source = "the " + opts.Source + " catalog"
+	}
+	provider.Description = fmt.Sprintf("Imported from %s with %d models", source, len(models))
+	return provider
+}
+
+// anyTrue merges one modality of models: true when any model has it, false
+// when every model is known not to, and nil otherwise
+func anyTrue(models []Model, field func(*config.CapabilityOverrides) *bool) *bool {
+	known := 0
+	for i := range models {
+		value := field(&models[i].Overrides)
+		if value == nil {
+			continue
+		}
+		if *value {
+			return value
+		}
+		known++
+	}
+	if known < len(models) {
+		return nil
+	}
+	result := false
+	return &result
+}
+
+// vendorName turns a vendor into a row name, e.g. meta-llama into Meta_Llama
+func vendorName(vendor string) string {
+	if name, ok := vendorNames[strings.ToLower(vendor)]; ok {
+		return name
+	}
+	parts := strings.FieldsFunc(vendor, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	for i, part := range parts {
+		runes := []rune(part)
+		runes[0] = unicode.ToUpper(runes[0])
+		parts[i] = string(runes)
+	}
+	if len(parts) == 0 {
+		return "Unknown"
+	}
+	return strings.Join(parts, "_")
+}
+
+// modelName turns a model ID into a row name suffix, e.g. openai/gpt-4o into gpt_4o
+func modelName(id string) string {
+	if _, after, ok := strings.Cut(id, "/"); ok {
+		id = after
+	}
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, id)
+}
+
+// envReference is the auth cell reading the key from variable; the empty
+// default keeps the CSV loadable before it is set
+func envReference(variable string) string {
+	return "${" + variable + ":-}"
+}
+
+// boolPtr returns a pointer to value
+func boolPtr(value bool) *bool {
+	return &value
+}
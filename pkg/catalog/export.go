@@ -0,0 +1,140 @@
+package catalog
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/selection"
+)
+
+// Inventory is the configuration an exporter renders
+type Inventory struct {
+	// Providers are read with config.ReadProviderCSVTemplate, so their keys
+	// are still ${VAR} references
+	Providers []config.CSVProvider
+	// Policies only apply while their conditions hold, which no exported
+	// format can express, so they are left out except for Apply
+	Policies []selection.RoutingPolicy
+	// Apply names a policy whose effects rank the exported providers as if it
+	// applied to every request
+	Apply   string
+	Aliases []selection.ModelAlias
+}
+
+// envReferencePattern matches an auth cell that is a single ${VAR} reference,
+// with or without a default
+var envReferencePattern = regexp.MustCompile(`^\$\{([A-Za-z_][A-Za-z0-9_]*)(?::[-?][^}]*)?\}$`)
+
+// exportedProvider is a provider the other gateway can route to
+type exportedProvider struct {
+	config.CSVProvider
+	// Models is the literal model list of the row
+	Models []string
+	// Rank orders the provider like selection.RequestConstraints.Rank, 0
+	// being selected first
+	Rank int
+	// KeyVariable is the variable the key is read from, when it is one
+	KeyVariable string
+}
+
+// exportProviders returns the providers of inv that list their models, ranked
+// by the applied policy, whether that ranked them, and notes on what the
+// gateways cannot express
+func exportProviders(inv Inventory) ([]exportedProvider, bool, []string, error) {
+	var notes []string
+
+	var constraints selection.RequestConstraints
+	applied := false
+	for _, policy := range inv.Policies {
+		if inv.Apply == "" || !strings.EqualFold(policy.Name, inv.Apply) {
+			notes = append(notes, fmt.Sprintf("routing policy %s: its conditions cannot be exported; left out", policy.Name))
+			continue
+		}
+		constraints = selection.RequestConstraints{TierPreference: policy.TierPreference, PreferredProviders: policy.PreferredProviders}
+		applied = true
+	}
+	if inv.Apply != "" && !applied {
+		return nil, false, nil, fmt.Errorf("no routing policy named %s", inv.Apply)
+	}
+	ranked := len(constraints.TierPreference) > 0 || len(constraints.PreferredProviders) > 0
+
+	var providers []exportedProvider
+	for _, provider := range inv.Providers {
+		models := exportModels(provider.ModelsSource)
+		if len(models) == 0 && provider.ModelsSource == "" {
+			notes = append(notes, fmt.Sprintf("%s: no models; left out", provider.Name))
+			continue
+		}
+		if models == nil {
+			notes = append(notes, fmt.Sprintf("%s: models are discovered from %q, which cannot be exported; list them in the models column", provider.Name, provider.ModelsSource))
+			continue
+		}
+
+		exported := exportedProvider{CSVProvider: provider, Models: models}
+		if ranked {
+			if constraints.Preference(provider.Tier) < 0 {
+				// Ranked after every acceptable tier rather than dropped
+				exported.Rank = 2 * len(constraints.TierPreference)
+				notes = append(notes, fmt.Sprintf("%s: tier %s is outside the tier preference; ranked last", provider.Name, provider.Tier))
+			} else {
+				exported.Rank = constraints.Rank(provider.Tier, provider.Name)
+			}
+		}
+		if match := envReferencePattern.FindStringSubmatch(provider.APIKey); match != nil {
+			exported.KeyVariable = match[1]
+		} else if strings.Contains(provider.APIKey, "${") {
+			notes = append(notes, fmt.Sprintf("%s: auth combines ${VAR} references; left out", provider.Name))
+			exported.APIKey = ""
+		}
+		providers = append(providers, exported)
+	}
+	sort.SliceStable(providers, func(i, j int) bool { return providers[i].Rank < providers[j].Rank })
+	return providers, ranked, notes, nil
+}
+
+// exportModels returns the models of a literal models column, or nil when the
+// column names an endpoint or path the models are discovered from
+func exportModels(source string) []string {
+	if strings.Contains(source, "://") || strings.HasPrefix(source, "/") {
+		return nil
+	}
+	var models []string
+	for _, model := range strings.Split(source, "|") {
+		if model = strings.TrimSpace(model); model != "" {
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
+// resolvedAliases maps each alias onto the model it finally resolves to,
+// following chained aliases like selection.ModelAliases.Resolve
+func resolvedAliases(aliases []selection.ModelAlias) map[string]string {
+	targets := make(map[string]string, len(aliases))
+	for _, alias := range aliases {
+		targets[strings.ToLower(alias.Alias)] = alias.Target
+	}
+	resolved := make(map[string]string, len(targets))
+	for alias, target := range targets {
+		// The hop limit guards against cycles, which NewModelAliases rejects
+		for hops := 0; hops < len(targets); hops++ {
+			next, ok := targets[strings.ToLower(target)]
+			if !ok {
+				break
+			}
+			target = next
+		}
+		resolved[alias] = target
+	}
+	return resolved
+}
+
+// isAPIKey reports whether an auth cell holds a key rather than marking a
+// provider that needs none
+func isAPIKey(auth string) bool {
+	auth = strings.TrimSpace(auth)
+	return auth != "" && !strings.EqualFold(auth, "none")
+}
@@ -0,0 +1,313 @@
+package catalog
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/probe"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
+	"gopkg.in/yaml.v3"
+)
+
+// liteLLMEndpoints are the OpenAI-compatible endpoints of the LiteLLM
+// provider prefixes, used for models that set no api_base
+var liteLLMEndpoints = map[string]string{
+	"openai":       "https://api.openai.com/v1",
+	"anthropic":    "https://api.anthropic.com/v1",
+	"groq":         "https://api.groq.com/openai/v1",
+	"mistral":      "https://api.mistral.ai/v1",
+	"deepseek":     "https://api.deepseek.com/v1",
+	"together_ai":  "https://api.together.xyz/v1",
+	"fireworks_ai": "https://api.fireworks.ai/inference/v1",
+	"openrouter":   OpenRouterEndpoint,
+	"perplexity":   "https://api.perplexity.ai",
+	"xai":          "https://api.x.ai/v1",
+	"gemini":       "https://generativelanguage.googleapis.com/v1beta/openai",
+	"cerebras":     "https://api.cerebras.ai/v1",
+	"ollama":       "http://localhost:11434/v1",
+	"ollama_chat":  "http://localhost:11434/v1",
+}
+
+// liteLLMSkippedModes are model_info modes the gateway cannot route chat to
+var liteLLMSkippedModes = map[string]bool{
+	"embedding":           true,
+	"rerank":              true,
+	"moderation":          true,
+	"audio_transcription": true,
+}
+
+// liteLLMModel is one entry of a LiteLLM model_list
+type liteLLMModel struct {
+	ModelName string `yaml:"model_name"`
+	Params    struct {
+		Model   string `yaml:"model"`
+		APIBase string `yaml:"api_base"`
+		APIKey  string `yaml:"api_key"`
+		RPM     int    `yaml:"rpm"`
+		TPM     int    `yaml:"tpm"`
+		// Tags hold the tier of configs written by ExportLiteLLM
+		Tags []string `yaml:"tags"`
+	} `yaml:"litellm_params"`
+	Info struct {
+		Mode                    string `yaml:"mode"`
+		SupportsVision          *bool  `yaml:"supports_vision"`
+		SupportsFunctionCalling *bool  `yaml:"supports_function_calling"`
+		SupportsResponseSchema  *bool  `yaml:"supports_response_schema"`
+		SupportsSystemMessages  *bool  `yaml:"supports_system_messages"`
+		SupportsNativeStreaming *bool  `yaml:"supports_native_streaming"`
+		SupportsAudioInput      *bool  `yaml:"supports_audio_input"`
+		SupportsAudioOutput     *bool  `yaml:"supports_audio_output"`
+	} `yaml:"model_info"`
+}
+
+// ParseLiteLLM reads the model_list of a LiteLLM proxy config and returns
+// notes on the models it skipped or changed. A model's vendor is the provider
+// prefix of litellm_params.model. Models without api_base use the public
+// endpoint of their provider; those of other providers, and embedding,
+// rerank, moderation and transcription models, are skipped.
+// os.environ/VAR keys become ${VAR} references, and literal keys are left out
+// of the rows. A tag naming a tier sets the tier of the model's row
+func ParseLiteLLM(r io.Reader) ([]Model, []string, error) {
+	var document struct {
+		ModelList []liteLLMModel `yaml:"model_list"`
+	}
+	if err := yaml.NewDecoder(r).Decode(&document); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse LiteLLM config: %w", err)
+	}
+	if document.ModelList == nil {
+		return nil, nil, fmt.Errorf("failed to parse LiteLLM config: no model_list")
+	}
+
+	var models []Model
+	var notes []string
+	for _, entry := range document.ModelList {
+		label := entry.ModelName
+		if label == "" {
+			label = entry.Params.Model
+		}
+		vendor, id, ok := strings.Cut(entry.Params.Model, "/")
+		if !ok {
+			// Bare model names are OpenAI models in LiteLLM
+			vendor, id = "openai", entry.Params.Model
+		}
+		if id == "" {
+			notes = append(notes, fmt.Sprintf("%s: no litellm_params.model", label))
+			continue
+		}
+		if liteLLMSkippedModes[entry.Info.Mode] {
+			notes = append(notes, fmt.Sprintf("%s: %s models are not routed", label, entry.Info.Mode))
+			continue
+		}
+
+		endpoint := liteLLMValue(entry.Params.APIBase)
+		if endpoint == "" {
+			if endpoint, ok = liteLLMEndpoints[vendor]; !ok {
+				notes = append(notes, fmt.Sprintf("%s: %s has no OpenAI-compatible endpoint; set api_base", label, vendor))
+				continue
+			}
+		}
+		apiKey := liteLLMValue(entry.Params.APIKey)
+		if apiKey != "" && !strings.HasPrefix(apiKey, "${") {
+			notes = append(notes, fmt.Sprintf("%s: literal api_key left out; fill in the auth column", label))
+			apiKey = ""
+		}
+
+		model := Model{
+			ID:       id,
+			Vendor:   vendor,
+			Endpoint: strings.TrimRight(endpoint, "/"),
+			APIKey:   apiKey,
+			Overrides: config.CapabilityOverrides{
+				Image: boolPtr(entry.Info.Mode == "image_generation"),
+			},
+		}
+		for _, tag := range entry.Params.Tags {
+			if t := tier.Normalize(tag); t.Valid() {
+				model.Tier = t
+			}
+		}
+		if entry.Info.Mode == "" {
+			// Without a mode LiteLLM assumes chat, but nothing is known for sure
+			model.Overrides.Image = nil
+		}
+		for feature, supported := range map[probe.Feature]*bool{
+			probe.Vision:          entry.Info.SupportsVision,
+			probe.FunctionCalling: entry.Info.SupportsFunctionCalling,
+			probe.JSONMode:        entry.Info.SupportsResponseSchema,
+			probe.SystemPrompt:    entry.Info.SupportsSystemMessages,
+			probe.Streaming:       entry.Info.SupportsNativeStreaming,
+		} {
+			if supported != nil && *supported {
+				model.Features = append(model.Features, feature)
+			}
+		}
+		model.Overrides.Multimodal = entry.Info.SupportsVision
+		if entry.Info.SupportsAudioInput != nil || entry.Info.SupportsAudioOutput != nil {
+			model.Overrides.Audio = boolPtr(isTrue(entry.Info.SupportsAudioInput) || isTrue(entry.Info.SupportsAudioOutput))
+		}
+		if entry.Params.RPM > 0 || entry.Params.TPM > 0 {
+			model.Limits = make(map[string]int)
+			if entry.Params.RPM > 0 {
+				model.Limits["requests_per_minute"] = entry.Params.RPM
+			}
+			if entry.Params.TPM > 0 {
+				model.Limits["tokens_per_minute"] = entry.Params.TPM
+			}
+		}
+		models = append(models, model)
+	}
+	return models, notes, nil
+}
+
+// liteLLMValue converts LiteLLM's os.environ/VAR references into ${VAR}
+// references, with an empty default so the CSV loads before VAR is set
+func liteLLMValue(value string) string {
+	value = strings.TrimSpace(value)
+	if variable, ok := strings.CutPrefix(value, "os.environ/"); ok {
+		return envReference(variable)
+	}
+	return value
+}
+
+// isTrue reports whether value is set and true
+func isTrue(value *bool) bool {
+	return value != nil && *value
+}
+
+// liteLLMDeployment is one entry of an exported model_list
+type liteLLMDeployment struct {
+	ModelName string            `yaml:"model_name"`
+	Params    liteLLMParams     `yaml:"litellm_params"`
+	Info      liteLLMExportInfo `yaml:"model_info"`
+}
+
+// liteLLMParams are the exported litellm_params of a deployment
+type liteLLMParams struct {
+	Model   string   `yaml:"model"`
+	APIBase string   `yaml:"api_base,omitempty"`
+	APIKey  string   `yaml:"api_key,omitempty"`
+	RPM     int      `yaml:"rpm,omitempty"`
+	TPM     int      `yaml:"tpm,omitempty"`
+	Order   int      `yaml:"order,omitempty"`
+	Tags    []string `yaml:"tags,omitempty"`
+}
+
+// liteLLMExportInfo is the exported model_info of a deployment
+type liteLLMExportInfo struct {
+	Mode                    string `yaml:"mode"`
+	SupportsVision          bool   `yaml:"supports_vision,omitempty"`
+	SupportsFunctionCalling bool   `yaml:"supports_function_calling,omitempty"`
+	SupportsResponseSchema  bool   `yaml:"supports_response_schema,omitempty"`
+	SupportsSystemMessages  bool   `yaml:"supports_system_messages,omitempty"`
+	SupportsNativeStreaming bool   `yaml:"supports_native_streaming,omitempty"`
+}
+
+// ExportLiteLLM writes inv as a LiteLLM proxy config, the reverse of
+// ParseLiteLLM, and returns notes on what it left out. Every model of a
+// provider becomes a deployment, and deployments of the same model form one
+// load-balanced group. Providers on a known endpoint use its LiteLLM prefix;
+// others are called as openai/ with api_base. ${VAR} keys become os.environ/VAR.
+// The tier is the deployment's tag, and the applied routing policy sets each
+// deployment's order; model aliases become model_group_alias
+func ExportLiteLLM(w io.Writer, inv Inventory) ([]string, error) {
+	providers, ranked, notes, err := exportProviders(inv)
+	if err != nil {
+		return nil, err
+	}
+
+	var deployments []liteLLMDeployment
+	for _, provider := range providers {
+		prefix, apiBase := liteLLMPrefix(provider.Endpoint)
+		params := liteLLMParams{
+			APIBase: apiBase,
+			RPM:     provider.Limits["requests_per_minute"],
+			TPM:     provider.Limits["tokens_per_minute"],
+			Tags:    []string{string(provider.Tier)},
+		}
+		if ranked {
+			// LiteLLM tries order 1 first and falls back to higher orders
+			params.Order = provider.Rank + 1
+		}
+		switch {
+		case provider.KeyVariable != "":
+			params.APIKey = "os.environ/" + provider.KeyVariable
+		case isAPIKey(provider.APIKey):
+			params.APIKey = provider.APIKey
+		}
+
+		info := liteLLMExportInfo{Mode: "chat"}
+		if overrides := provider.CapabilityOverrides; overrides != nil && isTrue(overrides.Image) && !isTrue(overrides.Text) {
+			info.Mode = "image_generation"
+		}
+		for _, capability := range provider.Capabilities {
+			switch probe.Feature(capability) {
+			case probe.Vision:
+				info.SupportsVision = true
+			case probe.FunctionCalling:
+				info.SupportsFunctionCalling = true
+			case probe.JSONMode:
+				info.SupportsResponseSchema = true
+			case probe.SystemPrompt:
+				info.SupportsSystemMessages = true
+			case probe.Streaming:
+				info.SupportsNativeStreaming = true
+			}
+		}
+
+		for _, model := range provider.Models {
+			deployment := liteLLMDeployment{ModelName: model, Params: params, Info: info}
+			deployment.Params.Model = prefix + "/" + model
+			deployments = append(deployments, deployment)
+		}
+	}
+
+	document := struct {
+		ModelList      []liteLLMDeployment `yaml:"model_list"`
+		RouterSettings struct {
+			RoutingStrategy string            `yaml:"routing_strategy"`
+			ModelGroupAlias map[string]string `yaml:"model_group_alias,omitempty"`
+		} `yaml:"router_settings"`
+	}{ModelList: deployments}
+	document.RouterSettings.RoutingStrategy = "simple-shuffle"
+	served := make(map[string]bool)
+	for _, deployment := range deployments {
+		served[strings.ToLower(deployment.ModelName)] = true
+	}
+	for alias, target := range resolvedAliases(inv.Aliases) {
+		if !served[strings.ToLower(target)] {
+			continue
+		}
+		if document.RouterSettings.ModelGroupAlias == nil {
+			document.RouterSettings.ModelGroupAlias = make(map[string]string)
+		}
+		document.RouterSettings.ModelGroupAlias[alias] = target
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(document); err != nil {
+		return notes, fmt.Errorf("failed to write LiteLLM config: %w", err)
+	}
+	return notes, encoder.Close()
+}
+
+// liteLLMPrefix returns the LiteLLM provider prefix of endpoint, and the
+// api_base to send when LiteLLM does not know the endpoint
+func liteLLMPrefix(endpoint string) (string, string) {
+	endpoint = strings.TrimRight(endpoint, "/")
+	prefixes := make([]string, 0, len(liteLLMEndpoints))
+	for prefix := range liteLLMEndpoints {
+		prefixes = append(prefixes, prefix)
+	}
+	// Sorted so ollama is preferred over its ollama_chat twin
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		if liteLLMEndpoints[prefix] == endpoint {
+			return prefix, ""
+		}
+	}
+	return "openai", endpoint
+}
@@ -0,0 +1,200 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+)
+
+// oneAPICustom is the channel type of any OpenAI-compatible base URL
+const oneAPICustom = 8
+
+// oneAPIChannelTypes are the one-api channel types with a fixed endpoint.
+// one-api appends /v1/... to a channel's base URL, so these are the provider
+// endpoints without their /v1
+var oneAPIChannelTypes = map[int]string{
+	1:  "https://api.openai.com",
+	14: "https://api.anthropic.com",
+	20: "https://openrouter.ai/api",
+	28: "https://api.mistral.ai",
+	29: "https://api.groq.com/openai",
+	36: "https://api.deepseek.com",
+}
+
+// oneAPIChannel is a one-api channel, in the shape its channel API accepts
+// and lists
+type oneAPIChannel struct {
+	Type    int    `json:"type"`
+	Name    string `json:"name"`
+	Key     string `json:"key"`
+	BaseURL string `json:"base_url"`
+	// Models is a comma-separated list
+	Models string `json:"models"`
+	Group  string `json:"group"`
+	// ModelMapping is a JSON object, encoded as a string, mapping requested
+	// models onto the models sent upstream
+	ModelMapping string `json:"model_mapping,omitempty"`
+	// Priority orders channels serving the same model, highest first
+	Priority int64 `json:"priority"`
+	// Status is 1 for enabled channels
+	Status int `json:"status"`
+}
+
+// ParseOneAPI reads one-api channels, either a JSON list as written by
+// ExportOneAPI or the response of one-api's GET /api/channel/. A model's vendor
+// is the channel name. one-api does not list keys, so keys become
+// ${<NAME>_API_KEY} references, and literal keys are left out of the rows.
+// Disabled channels and channels without a known endpoint are skipped, and so
+// are the aliases of a channel's model mapping
+func ParseOneAPI(r io.Reader) ([]Model, []string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read one-api channels: %w", err)
+	}
+	var channels []oneAPIChannel
+	if err := json.Unmarshal(data, &channels); err != nil {
+		var response struct {
+			Data []oneAPIChannel `json:"data"`
+		}
+		if json.Unmarshal(data, &response) != nil || response.Data == nil {
+			return nil, nil, fmt.Errorf("failed to parse one-api channels: %w", err)
+		}
+		channels = response.Data
+	}
+
+	var models []Model
+	var notes []string
+	for i, channel := range channels {
+		label := channel.Name
+		if label == "" {
+			label = fmt.Sprintf("channel %d", i+1)
+		}
+		if channel.Status != 1 {
+			notes = append(notes, fmt.Sprintf("%s: disabled", label))
+			continue
+		}
+		baseURL := strings.TrimRight(channel.BaseURL, "/")
+		if baseURL == "" {
+			known, ok := oneAPIChannelTypes[channel.Type]
+			if !ok {
+				notes = append(notes, fmt.Sprintf("%s: channel type %d has no OpenAI-compatible endpoint; set base_url", label, channel.Type))
+				continue
+			}
+			baseURL = known
+		}
+		apiKey := envReference(config.ProviderAPIKeyVariable(label))
+		if channel.Key != "" {
+			notes = append(notes, fmt.Sprintf("%s: literal key left out; set %s", label, config.ProviderAPIKeyVariable(label)))
+		}
+		// Mapped names are aliases rather than models the endpoint serves
+		var mapping map[string]string
+		if channel.ModelMapping != "" && json.Unmarshal([]byte(channel.ModelMapping), &mapping) != nil {
+			notes = append(notes, fmt.Sprintf("%s: invalid model_mapping ignored", label))
+		}
+		var aliases []string
+		for alias, target := range mapping {
+			aliases = append(aliases, alias+" -> "+target)
+		}
+		if len(aliases) > 0 {
+			sort.Strings(aliases)
+			notes = append(notes, fmt.Sprintf("%s: model_mapping left out; add %s to MODEL_ALIASES_PATH", label, strings.Join(aliases, ", ")))
+		}
+
+		for _, id := range strings.Split(channel.Models, ",") {
+			if id = strings.TrimSpace(id); id == "" {
+				continue
+			}
+			if _, ok := mapping[id]; ok {
+				continue
+			}
+			models = append(models, Model{
+				ID:       id,
+				Vendor:   label,
+				Endpoint: baseURL + "/v1",
+				APIKey:   apiKey,
+			})
+		}
+	}
+	return models, notes, nil
+}
+
+// ExportOneAPI writes inv as a JSON list of one-api channels, the reverse of
+// ParseOneAPI, and returns notes on what it left out. Each channel can be
+// posted to one-api's /api/channel/. Providers on a known endpoint get its
+// channel type, others the custom type. ${VAR} keys are left for the operator
+// to fill in, the applied routing policy sets each channel's priority, and
+// model aliases become model mappings of the channels serving their target
+func ExportOneAPI(w io.Writer, inv Inventory) ([]string, error) {
+	providers, ranked, notes, err := exportProviders(inv)
+	if err != nil {
+		return nil, err
+	}
+	aliases := resolvedAliases(inv.Aliases)
+
+	lowest := 0
+	for _, provider := range providers {
+		lowest = max(lowest, provider.Rank)
+	}
+
+	channels := make([]oneAPIChannel, 0, len(providers))
+	for _, provider := range providers {
+		channel := oneAPIChannel{Name: provider.Name, Group: "default", Status: 1}
+		channel.Type, channel.BaseURL = oneAPIType(provider.Endpoint)
+		if channel.Type == oneAPICustom && !strings.HasSuffix(strings.TrimRight(provider.Endpoint, "/"), "/v1") {
+			notes = append(notes, fmt.Sprintf("%s: one-api appends /v1 to %s; check base_url", provider.Name, channel.BaseURL))
+		}
+		switch {
+		case provider.KeyVariable != "":
+			notes = append(notes, fmt.Sprintf("%s: set the channel key from %s", provider.Name, provider.KeyVariable))
+		case isAPIKey(provider.APIKey):
+			channel.Key = provider.APIKey
+		}
+		if ranked {
+			channel.Priority = int64(lowest - provider.Rank)
+		}
+
+		models := append([]string(nil), provider.Models...)
+		mapping := make(map[string]string)
+		for alias, target := range aliases {
+			for _, model := range provider.Models {
+				if strings.EqualFold(model, target) {
+					mapping[alias] = model
+					models = append(models, alias)
+					break
+				}
+			}
+		}
+		sort.Strings(models[len(provider.Models):])
+		channel.Models = strings.Join(models, ",")
+		if len(mapping) > 0 {
+			encoded, err := json.Marshal(mapping)
+			if err != nil {
+				return notes, err
+			}
+			channel.ModelMapping = string(encoded)
+		}
+		channels = append(channels, channel)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(channels); err != nil {
+		return notes, fmt.Errorf("failed to write one-api channels: %w", err)
+	}
+	return notes, nil
+}
+
+// oneAPIType returns the channel type and base URL of endpoint
+func oneAPIType(endpoint string) (int, string) {
+	baseURL := strings.TrimSuffix(strings.TrimRight(endpoint, "/"), "/v1")
+	for channelType, known := range oneAPIChannelTypes {
+		if known == baseURL {
+			return channelType, baseURL
+		}
+	}
+	return oneAPICustom, baseURL
+}
@@ -0,0 +1,106 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/probe"
+)
+
+// OpenRouterEndpoint is the API all OpenRouter models are served from
+const OpenRouterEndpoint = "https://openrouter.ai/api/v1"
+
+// OpenRouterCatalogURL serves OpenRouter's model list
+const OpenRouterCatalogURL = "https://openrouter.ai/api/v1/models"
+
+// openRouterModel is one entry of OpenRouter's model list
+type openRouterModel struct {
+	ID      string `json:"id"`
+	Pricing struct {
+		Prompt     string `json:"prompt"`
+		Completion string `json:"completion"`
+	} `json:"pricing"`
+	Architecture struct {
+		// Modality is the older "text+image->text" form of the lists below
+		Modality         string   `json:"modality"`
+		InputModalities  []string `json:"input_modalities"`
+		OutputModalities []string `json:"output_modalities"`
+	} `json:"architecture"`
+	SupportedParameters []string `json:"supported_parameters"`
+}
+
+// ParseOpenRouter reads OpenRouter's model list, as served at
+// OpenRouterCatalogURL. Every model is called through OpenRouterEndpoint
+// with the key in OPENROUTER_API_KEY. Entries without an ID are skipped, with
+// a note for each
+func ParseOpenRouter(r io.Reader) ([]Model, []string, error) {
+	var document struct {
+		Data []openRouterModel `json:"data"`
+	}
+	if err := json.NewDecoder(r).Decode(&document); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse OpenRouter catalog: %w", err)
+	}
+	if document.Data == nil {
+		return nil, nil, fmt.Errorf("failed to parse OpenRouter catalog: no data list")
+	}
+
+	apiKey := envReference(config.ProviderAPIKeyVariable("OpenRouter"))
+	var models []Model
+	var notes []string
+	for i, entry := range document.Data {
+		if entry.ID == "" {
+			notes = append(notes, fmt.Sprintf("entry %d: no id", i+1))
+			continue
+		}
+		vendor, _, ok := strings.Cut(entry.ID, "/")
+		if !ok {
+			vendor = "openrouter"
+		}
+
+		inputs, outputs := entry.Architecture.InputModalities, entry.Architecture.OutputModalities
+		if len(inputs) == 0 && len(outputs) == 0 {
+			in, out, _ := strings.Cut(entry.Architecture.Modality, "->")
+			inputs, outputs = strings.Split(in, "+"), strings.Split(out, "+")
+		}
+
+		// Every OpenRouter model streams
+		features := []probe.Feature{probe.Streaming}
+		if slices.Contains(entry.SupportedParameters, "tools") {
+			features = append(features, probe.FunctionCalling)
+		}
+		if slices.Contains(entry.SupportedParameters, "response_format") || slices.Contains(entry.SupportedParameters, "structured_outputs") {
+			features = append(features, probe.JSONMode)
+		}
+		if slices.Contains(inputs, "image") {
+			features = append(features, probe.Vision)
+		}
+
+		models = append(models, Model{
+			ID:       entry.ID,
+			Vendor:   vendor,
+			Endpoint: OpenRouterEndpoint,
+			APIKey:   apiKey,
+			Free:     strings.HasSuffix(entry.ID, ":free") || (isZeroPrice(entry.Pricing.Prompt) && isZeroPrice(entry.Pricing.Completion)),
+			Features: features,
+			Overrides: config.CapabilityOverrides{
+				Text:       boolPtr(slices.Contains(outputs, "text")),
+				Image:      boolPtr(slices.Contains(outputs, "image")),
+				Audio:      boolPtr(slices.Contains(inputs, "audio") || slices.Contains(outputs, "audio")),
+				Video:      boolPtr(slices.Contains(inputs, "video") || slices.Contains(outputs, "video")),
+				Multimodal: boolPtr(slices.ContainsFunc(inputs, func(m string) bool { return m != "text" && m != "" })),
+			},
+		})
+	}
+	return models, notes, nil
+}
+
+// isZeroPrice reports whether an OpenRouter price, a decimal string, is zero
+func isZeroPrice(price string) bool {
+	value, err := strconv.ParseFloat(price, 64)
+	return err == nil && value == 0
+}
@@ -0,0 +1,173 @@
+// Package classify caches request classifications, such as complexity
+// analysis, on a fingerprint of the prompt so near-identical prompts from
+// templated clients are classified once
+package classify
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Cache defaults
+const (
+	// DefaultTTL is how long a classification is reused
+	DefaultTTL = 10 * time.Minute
+	// DefaultMaxEntries is how many classifications are kept
+	DefaultMaxEntries = 10000
+)
+
+// Normalize reduces a prompt to what classification depends on: it is
+// lower-cased, runs of whitespace become one space and runs of digits one
+// zero, so prompts differing only in case, spacing or numbers filled into a
+// template normalize the same. Words and their order are kept
+func Normalize(prompt string) string {
+	var b strings.Builder
+	b.Grow(len(prompt))
+	space, digit := false, false
+	for _, r := range strings.TrimSpace(prompt) {
+		switch {
+		case unicode.IsSpace(r):
+			if !space {
+				b.WriteByte(' ')
+			}
+			space, digit = true, false
+		case unicode.IsDigit(r):
+			if !digit {
+				b.WriteByte('0')
+			}
+			space, digit = false, true
+		default:
+			b.WriteRune(unicode.ToLower(r))
+			space, digit = false, false
+		}
+	}
+	return b.String()
+}
+
+// Fingerprint returns the key a prompt's classification is cached under,
+// the SHA-256 of its normalized form
+func Fingerprint(prompt string) string {
+	sum := sha256.Sum256([]byte(Normalize(prompt)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Stats are a cache's size and how often lookups hit it
+type Stats struct {
+	TTL        string  `json:"ttl"`
+	MaxEntries int     `json:"max_entries"`
+	Entries    int     `json:"entries"`
+	Hits       int64   `json:"hits"`
+	Misses     int64   `json:"misses"`
+	HitRate    float64 `json:"hit_rate"`
+	Evictions  int64   `json:"evictions"`
+}
+
+// entry is a cached value and when it expires
+type entry[V any] struct {
+	key     string
+	value   V
+	expires time.Time
+}
+
+// Cache keeps values for ttl, evicting the least recently used beyond
+// maxEntries. It is safe for concurrent use
+type Cache[V any] struct {
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List
+	hits       int64
+	misses     int64
+	evictions  int64
+	mutex      sync.Mutex
+}
+
+// NewCache creates an empty cache. A non-positive ttl or maxEntries takes
+// the default
+func NewCache[V any](ttl time.Duration, maxEntries int) *Cache[V] {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	return &Cache[V]{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Get returns the value cached under key unless it expired by now, and
+// counts the lookup as a hit or a miss
+func (c *Cache[V]) Get(key string, now time.Time) (V, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		cached := element.Value.(*entry[V])
+		if now.Before(cached.expires) {
+			c.hits++
+			c.order.MoveToFront(element)
+			return cached.value, true
+		}
+		c.remove(element)
+	}
+	c.misses++
+	var zero V
+	return zero, false
+}
+
+// Put caches value under key from now for the cache's TTL
+func (c *Cache[V]) Put(key string, value V, now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+	c.entries[key] = c.order.PushFront(&entry[V]{key: key, value: value, expires: now.Add(c.ttl)})
+	for c.order.Len() > c.maxEntries {
+		c.remove(c.order.Back())
+		c.evictions++
+	}
+}
+
+// Purge drops every cached value and keeps the counters
+func (c *Cache[V]) Purge() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+// Stats returns the cache's size and hit rate
+func (c *Cache[V]) Stats() Stats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	stats := Stats{
+		TTL:        c.ttl.String(),
+		MaxEntries: c.maxEntries,
+		Entries:    c.order.Len(),
+		Hits:       c.hits,
+		Misses:     c.misses,
+		Evictions:  c.evictions,
+	}
+	if lookups := c.hits + c.misses; lookups > 0 {
+		stats.HitRate = float64(c.hits) / float64(lookups)
+	}
+	return stats
+}
+
+// remove drops element from the cache. The caller holds the mutex
+func (c *Cache[V]) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*entry[V]).key)
+}
@@ -0,0 +1,39 @@
+package classify
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/perfbudget"
+)
+
+// templatedPrompt is a long prompt as a templated client would send it
+var templatedPrompt = strings.Repeat("Summarize order 12345 for customer 678 and   list the 3 items shipped. ", 40)
+
+func BenchmarkFingerprint(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Fingerprint(templatedPrompt)
+	}
+}
+
+func BenchmarkCacheHit(b *testing.B) {
+	cache := NewCache[int](time.Hour, 0)
+	now := time.Now()
+	key := Fingerprint(templatedPrompt)
+	cache.Put(key, 1, now)
+	for i := 0; i < b.N; i++ {
+		if _, ok := cache.Get(Fingerprint(templatedPrompt), now); !ok {
+			b.Fatal("cached classification missing")
+		}
+	}
+}
+
+// TestPerformanceBudget covers the lookup every request makes before
+// classification. Run with PERF_BUDGET=true
+func TestPerformanceBudget(t *testing.T) {
+	perfbudget.Check(t,
+		perfbudget.Budget{Name: "Fingerprint", Benchmark: BenchmarkFingerprint, MaxNsPerOp: 100_000, MaxAllocsPerOp: 6},
+		perfbudget.Budget{Name: "CacheHit", Benchmark: BenchmarkCacheHit, MaxNsPerOp: 100_000, MaxAllocsPerOp: 6},
+	)
+}
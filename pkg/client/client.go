@@ -0,0 +1,377 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/requestid"
+)
+
+// RetryPolicy controls how failed calls are retried. Network errors and 409, 429,
+// 502, 503 and 504 responses are retried with exponential backoff, honouring
+// Retry-After when the gateway sends it
+type RetryPolicy struct {
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetryPolicy retries up to three times starting at 200ms
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries:     3,
+	InitialBackoff: 200 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+}
+
+// Client is a typed client for the Your PaL MoE gateway API
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+	retry      RetryPolicy
+	userAgent  string
+}
+
+// NewClient creates a client for the gateway at baseURL. apiKey may be empty
+func NewClient(baseURL, apiKey string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		httpClient: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+		retry:     DefaultRetryPolicy,
+		userAgent: "Your-PaL-MoE-Go-Client/1.0",
+	}
+}
+
+// SetHTTPClient replaces the underlying HTTP client
+func (c *Client) SetHTTPClient(httpClient *http.Client) {
+	c.httpClient = httpClient
+}
+
+// SetRetryPolicy replaces the retry policy; a zero MaxRetries disables retries
+func (c *Client) SetRetryPolicy(policy RetryPolicy) {
+	c.retry = policy
+}
+
+// Health checks that the gateway is up
+func (c *Client) Health(ctx context.Context) (*Health, error) {
+	var health Health
+	if err := c.do(ctx, http.MethodGet, "/health", nil, &health); err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
+
+// Process sends a single request through the gateway
+func (c *Client) Process(ctx context.Context, req ProcessRequest) (*ProcessResponse, error) {
+	var response ProcessResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/process", req, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// ProcessBatch sends several requests in one call
+func (c *Client) ProcessBatch(ctx context.Context, reqs []ProcessRequest) (*BatchResponse, error) {
+	var response BatchResponse
+	body := map[string]interface{}{"requests": reqs}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/batch", body, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// GetRequest returns a processed request with its result
+func (c *Client) GetRequest(ctx context.Context, id string) (*RequestRecord, error) {
+	var record RequestRecord
+	if err := c.do(ctx, http.MethodGet, "/api/v1/requests/"+url.PathEscape(id), nil, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// ProcessAsync starts a request in the background and returns it running;
+// poll GetRequest until its Status is no longer "running"
+func (c *Client) ProcessAsync(ctx context.Context, req ProcessRequest) (*RequestRecord, error) {
+	var record RequestRecord
+	if err := c.do(ctx, http.MethodPost, "/api/v1/process?async=true", req, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// CancelRequest cancels a running asynchronous request
+func (c *Client) CancelRequest(ctx context.Context, id string) (*RequestRecord, error) {
+	var record RequestRecord
+	if err := c.do(ctx, http.MethodDelete, "/api/v1/requests/"+url.PathEscape(id), nil, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// IssueBrowserToken exchanges the client's API key for a short-lived token a
+// front-end app served from origin can call the gateway with. A zero ttl
+// asks for the longest lifetime the gateway allows
+func (c *Client) IssueBrowserToken(ctx context.Context, origin string, ttl time.Duration) (*BrowserToken, error) {
+	body := map[string]interface{}{"origin": origin}
+	if ttl > 0 {
+		body["ttl_seconds"] = int(ttl.Seconds())
+	}
+	var token BrowserToken
+	if err := c.do(ctx, http.MethodPost, "/api/v1/browser-tokens", body, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// ListRequests returns a page of the caller's processed requests; pass the
+// page's NextCursor in filter.Cursor for the next one
+func (c *Client) ListRequests(ctx context.Context, filter RequestFilter) (*RequestPage, error) {
+	params := url.Values{}
+	for name, value := range map[string]string{"key_id": filter.KeyID, "session_id": filter.SessionID, "status": filter.Status, "provider": filter.Provider, "cursor": filter.Cursor} {
+		if value != "" {
+			params.Set(name, value)
+		}
+	}
+	if !filter.Since.IsZero() {
+		params.Set("since", filter.Since.Format(time.RFC3339))
+	}
+	if !filter.Until.IsZero() {
+		params.Set("until", filter.Until.Format(time.RFC3339))
+	}
+	if filter.Limit > 0 {
+		params.Set("limit", strconv.Itoa(filter.Limit))
+	}
+	path := "/api/v1/requests"
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+
+	var page RequestPage
+	if err := c.do(ctx, http.MethodGet, path, nil, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// ListProviders returns the providers configured on the gateway
+func (c *Client) ListProviders(ctx context.Context) ([]Provider, error) {
+	var providers []Provider
+	if err := c.do(ctx, http.MethodGet, "/api/v1/providers", nil, &providers); err != nil {
+		return nil, err
+	}
+	return providers, nil
+}
+
+// ProviderYAML returns the generated YAML configuration of one provider
+func (c *Client) ProviderYAML(ctx context.Context, provider string) (string, error) {
+	var yaml bytes.Buffer
+	if err := c.do(ctx, http.MethodGet, "/api/v1/providers/"+url.PathEscape(provider)+"/yaml", nil, &yaml); err != nil {
+		return "", err
+	}
+	return yaml.String(), nil
+}
+
+// Metrics returns the gateway metrics snapshot
+func (c *Client) Metrics(ctx context.Context) (map[string]interface{}, error) {
+	var metrics map[string]interface{}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/metrics", nil, &metrics); err != nil {
+		return nil, err
+	}
+	return metrics, nil
+}
+
+// SpeculativeStats returns how often speculative drafts needed verification
+// and what speculative routing saved
+func (c *Client) SpeculativeStats(ctx context.Context) (*SpeculativeStats, error) {
+	var stats SpeculativeStats
+	if err := c.do(ctx, http.MethodGet, "/api/v1/speculative/stats", nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// LogLevels returns the effective log level of every gateway module
+func (c *Client) LogLevels(ctx context.Context) (map[string]string, error) {
+	var levels map[string]string
+	if err := c.do(ctx, http.MethodGet, "/admin/log-level", nil, &levels); err != nil {
+		return nil, err
+	}
+	return levels, nil
+}
+
+// SetLogLevel changes the log level of a gateway module; an empty level resets it
+func (c *Client) SetLogLevel(ctx context.Context, module, level string) (map[string]string, error) {
+	var levels map[string]string
+	body := map[string]string{"module": module, "level": level}
+	if err := c.do(ctx, http.MethodPut, "/admin/log-level", body, &levels); err != nil {
+		return nil, err
+	}
+	return levels, nil
+}
+
+// OpenAPI returns the gateway's OpenAPI document
+func (c *Client) OpenAPI(ctx context.Context) (json.RawMessage, error) {
+	var doc json.RawMessage
+	if err := c.do(ctx, http.MethodGet, "/openapi.json", nil, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// do performs a call and decodes the response into out. A *bytes.Buffer receives
+// the raw body instead of being JSON-decoded
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	resp, err := c.send(ctx, method, path, body, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if buf, ok := out.(*bytes.Buffer); ok {
+		_, err := buf.ReadFrom(resp.Body)
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s %s response: %w", method, path, err)
+	}
+	return nil
+}
+
+// send performs a call with retries and returns the first successful response.
+// POST bodies carry a generated Idempotency-Key so retries never duplicate work
+func (c *Client) send(ctx context.Context, method, path string, body interface{}, header http.Header) (*http.Response, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	idempotencyKey := ""
+	if method == http.MethodPost {
+		idempotencyKey = requestid.New()
+	}
+
+	backoff := c.retry.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		resp, err := c.attempt(ctx, method, path, payload, header, idempotencyKey)
+		if err == nil {
+			return resp, nil
+		}
+
+		var apiErr *APIError
+		isAPIError := errors.As(err, &apiErr)
+		retryable := (isAPIError && apiErr.Temporary()) || (!isAPIError && ctx.Err() == nil)
+		if !retryable || attempt >= c.retry.MaxRetries {
+			return nil, err
+		}
+
+		wait := backoff
+		if apiErr != nil && apiErr.RetryAfter > wait {
+			wait = apiErr.RetryAfter
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+
+		backoff *= 2
+		if c.retry.MaxBackoff > 0 && backoff > c.retry.MaxBackoff {
+			backoff = c.retry.MaxBackoff
+		}
+	}
+}
+
+// attempt performs a single HTTP round trip, converting non-2xx responses to *APIError
+func (c *Client) attempt(ctx context.Context, method, path string, payload []byte, header http.Header, idempotencyKey string) (*http.Response, error) {
+	var bodyReader io.Reader
+	if payload != nil {
+		bodyReader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bodyReader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, values := range header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	requestid.Inject(req)
+	// The gateway stops working on the call when the caller stops waiting
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Header.Set(TimeoutHeader, formatTimeout(time.Until(deadline)))
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s failed: %w", method, path, err)
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	return nil, parseAPIError(resp)
+}
+
+// formatTimeout renders the time left before a deadline as an X-Timeout value
+func formatTimeout(remaining time.Duration) string {
+	if remaining < time.Millisecond {
+		remaining = time.Millisecond
+	}
+	return fmt.Sprintf("%dms", remaining.Milliseconds())
+}
+
+// parseAPIError builds an *APIError from a failed response
+func parseAPIError(resp *http.Response) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get(requestid.Header),
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var structured struct {
+		Error  string       `json:"error"`
+		Fields []FieldError `json:"fields"`
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") && json.Unmarshal(data, &structured) == nil {
+		apiErr.Message = structured.Error
+		apiErr.Fields = structured.Fields
+	} else {
+		apiErr.Message = strings.TrimSpace(string(data))
+	}
+	return apiErr
+}
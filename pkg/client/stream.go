@@ -0,0 +1,159 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Event is a single server-sent event received from a streaming call
+type Event struct {
+	ID   string
+	Type string
+	Data []byte
+}
+
+// Decode unmarshals the event data as JSON into v
+func (e *Event) Decode(v interface{}) error {
+	return json.Unmarshal(e.Data, v)
+}
+
+// Stream reads events from a streaming response. Callers must Close it
+type Stream struct {
+	ctx     context.Context
+	body    io.ReadCloser
+	reader  *bufio.Reader
+	pending *Event
+}
+
+// ProcessStream sends a request asking for a text/event-stream response. When the
+// gateway answers with plain JSON instead, the stream yields a single "result"
+// event carrying the full ProcessResponse, so callers can use one code path
+func (c *Client) ProcessStream(ctx context.Context, req ProcessRequest) (*Stream, error) {
+	header := http.Header{}
+	header.Set("Accept", "text/event-stream, application/json")
+
+	resp, err := c.send(ctx, http.MethodPost, "/api/v1/process", req, header)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "text/event-stream" {
+		return &Stream{ctx: ctx, body: resp.Body, reader: bufio.NewReader(resp.Body)}, nil
+	}
+
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return &Stream{
+		ctx:     ctx,
+		body:    io.NopCloser(strings.NewReader("")),
+		reader:  bufio.NewReader(strings.NewReader("")),
+		pending: &Event{Type: "result", Data: data},
+	}, nil
+}
+
+// Next returns the next event, or io.EOF once the stream has ended. When the
+// context deadline passes first it returns a *DeadlineError
+func (s *Stream) Next() (*Event, error) {
+	if s.pending != nil {
+		event := s.pending
+		s.pending = nil
+		return event, nil
+	}
+
+	event := &Event{}
+	var data []string
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil && (line == "" || err != io.EOF) {
+			if err == io.EOF && len(data) > 0 {
+				// The stream ended without a trailing blank line
+				event.Data = []byte(strings.Join(data, "\n"))
+				if event.Type == "" {
+					event.Type = "message"
+				}
+				return event, nil
+			}
+			if ctxErr := s.ctx.Err(); errors.Is(ctxErr, context.DeadlineExceeded) {
+				return nil, &DeadlineError{Err: ctxErr}
+			}
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		// A blank line dispatches the event collected so far
+		if line == "" {
+			if len(data) == 0 && event.Type == "" {
+				continue
+			}
+			event.Data = []byte(strings.Join(data, "\n"))
+			if event.Type == "" {
+				event.Type = "message"
+			}
+			if string(event.Data) == "[DONE]" {
+				return nil, io.EOF
+			}
+			return event, nil
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event.Type = value
+		case "data":
+			data = append(data, value)
+		case "id":
+			event.ID = value
+		}
+	}
+}
+
+// Collect reads the stream to the end and concatenates the content of every
+// event, decoding "result" events as a ProcessResponse. A *DeadlineError
+// carries the content collected before the deadline
+func (s *Stream) Collect() (string, error) {
+	var sb strings.Builder
+	for {
+		event, err := s.Next()
+		if err == io.EOF {
+			return sb.String(), nil
+		}
+		var deadlineErr *DeadlineError
+		if errors.As(err, &deadlineErr) {
+			deadlineErr.Partial = sb.String()
+			return sb.String(), deadlineErr
+		}
+		if err != nil {
+			return sb.String(), err
+		}
+
+		if event.Type == "result" {
+			var response ProcessResponse
+			if err := event.Decode(&response); err != nil {
+				return sb.String(), err
+			}
+			sb.WriteString(response.Content)
+			continue
+		}
+		sb.Write(event.Data)
+	}
+}
+
+// Close releases the underlying connection
+func (s *Stream) Close() error {
+	return s.body.Close()
+}
@@ -0,0 +1,191 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ProcessRequest is the payload of POST /api/v1/process
+type ProcessRequest struct {
+	Content           string                 `json:"content"`
+	PreferredProvider string                 `json:"preferred_provider,omitempty"`
+	MaxTokens         int                    `json:"max_tokens,omitempty"`
+	Temperature       float64                `json:"temperature,omitempty"`
+	Metadata          map[string]interface{} `json:"metadata,omitempty"`
+	// Strategy is StrategyDirect or StrategySpeculative; empty uses the
+	// gateway default
+	Strategy string `json:"strategy,omitempty"`
+	// Mode names the agent mode, which picks the gateway's response
+	// processors when the API key has none of its own
+	Mode string `json:"mode,omitempty"`
+}
+
+// Request strategies
+const (
+	// StrategyDirect sends the request straight to the selected provider
+	StrategyDirect = "direct"
+	// StrategySpeculative lets the cheapest capable provider draft the
+	// answer, verified by the selected provider only when the draft fails
+	// the gateway's quality check
+	StrategySpeculative = "speculative"
+)
+
+// Provider is a provider as reported by the gateway
+type Provider struct {
+	Name         string   `json:"name"`
+	BaseURL      string   `json:"base_url"`
+	Models       []string `json:"models"`
+	Tier         string   `json:"tier"`
+	MaxTokens    int      `json:"max_tokens"`
+	CostPerToken float64  `json:"cost_per_token"`
+	Capabilities []string `json:"capabilities"`
+}
+
+// ProcessResponse is the result of processing a request
+type ProcessResponse struct {
+	Content        string                 `json:"content"`
+	Provider       *Provider              `json:"provider"`
+	Model          string                 `json:"model"`
+	Complexity     json.RawMessage        `json:"complexity"`
+	ProcessingTime time.Duration          `json:"processing_time"`
+	TokensUsed     int64                  `json:"tokens_used"`
+	Cost           float64                `json:"cost"`
+	Metadata       map[string]interface{} `json:"metadata"`
+}
+
+// RequestRecord is a processed request kept in the gateway's history
+type RequestRecord struct {
+	ID         string           `json:"id"`
+	KeyID      string           `json:"key_id,omitempty"`
+	Tenant     string           `json:"tenant,omitempty"`
+	SessionID  string           `json:"session_id,omitempty"`
+	Status     string           `json:"status"`
+	Provider   string           `json:"provider,omitempty"`
+	Model      string           `json:"model,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+	DurationMs int64            `json:"duration_ms"`
+	Cost       float64          `json:"cost"`
+	Input      ProcessRequest   `json:"input"`
+	Response   *ProcessResponse `json:"response,omitempty"`
+	Error      string           `json:"error,omitempty"`
+}
+
+// RequestFilter narrows ListRequests; zero fields match every request
+type RequestFilter struct {
+	KeyID     string
+	SessionID string
+	Status    string
+	Provider  string
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+	Cursor    string
+}
+
+// RequestPage is one page of GET /api/v1/requests, newest first
+type RequestPage struct {
+	Requests   []RequestRecord `json:"requests"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// BatchResult is the outcome of one request within a batch
+type BatchResult struct {
+	Index    int              `json:"index"`
+	Response *ProcessResponse `json:"response,omitempty"`
+	Error    string           `json:"error,omitempty"`
+}
+
+// BatchResponse is the result of POST /api/v1/batch
+type BatchResponse struct {
+	Results   []BatchResult `json:"results"`
+	Timestamp int64         `json:"timestamp"`
+}
+
+// Health is the result of GET /health
+type Health struct {
+	Status    string   `json:"status"`
+	Timestamp int64    `json:"timestamp"`
+	Version   string   `json:"version"`
+	Features  []string `json:"features"`
+}
+
+// BrowserToken is the result of POST /api/v1/browser-tokens
+type BrowserToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// SpeculativeStats summarizes the gateway's speculative requests
+type SpeculativeStats struct {
+	Requests         int64   `json:"requests"`
+	Verifications    int64   `json:"verifications"`
+	VerificationRate float64 `json:"verification_rate"`
+	DraftCost        float64 `json:"draft_cost"`
+	VerifyCost       float64 `json:"verify_cost"`
+	BaselineCost     float64 `json:"baseline_cost"`
+	Savings          float64 `json:"savings"`
+}
+
+// FieldError describes one invalid request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// TimeoutHeader tells the gateway how long the client will wait; calls send
+// the time left before their context deadline
+const TimeoutHeader = "X-Timeout"
+
+// DeadlineError is returned by streams whose context deadline passes before
+// the stream ends. Partial holds the content collected until then
+type DeadlineError struct {
+	Partial string
+	Err     error
+}
+
+// Error implements the error interface
+func (e *DeadlineError) Error() string {
+	return fmt.Sprintf("stream cut short after %d bytes: %v", len(e.Partial), e.Err)
+}
+
+// Unwrap returns the context error
+func (e *DeadlineError) Unwrap() error {
+	return e.Err
+}
+
+// APIError is returned for any non-2xx response from the gateway
+type APIError struct {
+	StatusCode int
+	Message    string
+	Fields     []FieldError
+	RequestID  string
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "gateway returned %d", e.StatusCode)
+	if e.Message != "" {
+		fmt.Fprintf(&sb, ": %s", e.Message)
+	}
+	for _, field := range e.Fields {
+		fmt.Fprintf(&sb, "; %s %s", field.Field, field.Message)
+	}
+	if e.RequestID != "" {
+		fmt.Fprintf(&sb, " (request %s)", e.RequestID)
+	}
+	return sb.String()
+}
+
+// Temporary reports whether retrying the request may succeed
+func (e *APIError) Temporary() bool {
+	switch e.StatusCode {
+	case 409, 429, 502, 503, 504:
+		return true
+	default:
+		return false
+	}
+}
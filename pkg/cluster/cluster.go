@@ -0,0 +1,132 @@
+package cluster
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ProviderStats are provider outcome counters aggregated across all replicas
+type ProviderStats struct {
+	Provider       string        `json:"provider"`
+	TotalRequests  int64         `json:"total_requests"`
+	FailedRequests int64         `json:"failed_requests"`
+	AverageLatency time.Duration `json:"average_latency"`
+}
+
+// State holds request and provider state that must be shared between gateway replicas
+type State interface {
+	// AddActiveRequests adjusts this node's in-flight request count by delta
+	AddActiveRequests(ctx context.Context, delta int64) error
+	// ActiveRequests returns the in-flight request count across all live nodes
+	ActiveRequests(ctx context.Context) (int64, error)
+	// RecordProviderResult adds a single provider call outcome to the shared counters
+	RecordProviderResult(ctx context.Context, provider string, success bool, latency time.Duration) error
+	// ProviderStats returns the shared counters for a provider
+	ProviderStats(ctx context.Context, provider string) (ProviderStats, error)
+	// AllowRequest consumes one unit of a fixed-window rate limit and reports whether it was available
+	AllowRequest(ctx context.Context, key string, limit int64, window time.Duration) (bool, error)
+	// Ping checks that the shared store is reachable
+	Ping(ctx context.Context) error
+	// Close releases the underlying connections
+	Close() error
+}
+
+// LocalState is a single-process State used when clustering is disabled
+type LocalState struct {
+	active    int64
+	providers map[string]*ProviderStats
+	latency   map[string]time.Duration
+	windows   map[string]*rateWindow
+	mutex     sync.Mutex
+}
+
+// rateWindow is one fixed rate-limit window tracked by LocalState
+type rateWindow struct {
+	count   int64
+	resetAt time.Time
+}
+
+// NewLocalState creates an in-memory State
+func NewLocalState() *LocalState {
+	return &LocalState{
+		providers: make(map[string]*ProviderStats),
+		latency:   make(map[string]time.Duration),
+		windows:   make(map[string]*rateWindow),
+	}
+}
+
+// AddActiveRequests adjusts the in-flight request count by delta
+func (s *LocalState) AddActiveRequests(ctx context.Context, delta int64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.active += delta
+	return nil
+}
+
+// ActiveRequests returns the in-flight request count
+func (s *LocalState) ActiveRequests(ctx context.Context) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.active, nil
+}
+
+// RecordProviderResult adds a single provider call outcome to the counters
+func (s *LocalState) RecordProviderResult(ctx context.Context, provider string, success bool, latency time.Duration) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stats, exists := s.providers[provider]
+	if !exists {
+		stats = &ProviderStats{Provider: provider}
+		s.providers[provider] = stats
+	}
+	stats.TotalRequests++
+	if !success {
+		stats.FailedRequests++
+	}
+	s.latency[provider] += latency
+	stats.AverageLatency = s.latency[provider] / time.Duration(stats.TotalRequests)
+	return nil
+}
+
+// ProviderStats returns the counters for a provider
+func (s *LocalState) ProviderStats(ctx context.Context, provider string) (ProviderStats, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if stats, exists := s.providers[provider]; exists {
+		return *stats, nil
+	}
+	return ProviderStats{Provider: provider}, nil
+}
+
+// AllowRequest consumes one unit of a fixed-window rate limit
+func (s *LocalState) AllowRequest(ctx context.Context, key string, limit int64, window time.Duration) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	w, exists := s.windows[key]
+	if !exists || now.After(w.resetAt) {
+		w = &rateWindow{resetAt: now.Add(window)}
+		s.windows[key] = w
+	}
+	if w.count >= limit {
+		return false, nil
+	}
+	w.count++
+	return true, nil
+}
+
+// Ping always succeeds for in-memory state
+func (s *LocalState) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Close is a no-op for in-memory state
+func (s *LocalState) Close() error {
+	return nil
+}
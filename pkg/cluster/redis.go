@@ -0,0 +1,296 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/middleware"
+	"github.com/go-redis/redis/v8"
+)
+
+// DefaultKeyPrefix namespaces all keys written by the gateway
+const DefaultKeyPrefix = "palmoe"
+
+// nodeStaleAfter is how long a node may miss heartbeats before its counts are ignored
+const nodeStaleAfter = 30 * time.Second
+
+// commandTimeout bounds Redis calls made without a caller deadline, so a
+// stalled Redis fails them instead of hanging the request
+const commandTimeout = 500 * time.Millisecond
+
+// rateLimitScript increments a window counter and starts its expiry on first use
+var rateLimitScript = redis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+if count == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return count
+`)
+
+// RedisState is a State shared by every replica pointing at the same Redis
+type RedisState struct {
+	client *redis.Client
+	prefix string
+	nodeID string
+}
+
+// NewRedisState connects to the Redis instance described by url (redis://host:port/db)
+func NewRedisState(url, prefix, nodeID string) (*RedisState, error) {
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis url: %w", err)
+	}
+	if prefix == "" {
+		prefix = DefaultKeyPrefix
+	}
+
+	client := redis.NewClient(options)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	return &RedisState{
+		client: client,
+		prefix: prefix,
+		nodeID: nodeID,
+	}, nil
+}
+
+// Client exposes the underlying Redis client so other shared stores can reuse it
+func (s *RedisState) Client() *redis.Client {
+	return s.client
+}
+
+// key builds a namespaced Redis key
+func (s *RedisState) key(parts ...string) string {
+	key := s.prefix
+	for _, part := range parts {
+		key += ":" + part
+	}
+	return key
+}
+
+// Heartbeat marks this node as alive so its active request count is included in totals
+func (s *RedisState) Heartbeat(ctx context.Context) error {
+	now := time.Now()
+	pipe := s.client.TxPipeline()
+	pipe.ZAdd(ctx, s.key("nodes"), &redis.Z{Score: float64(now.Unix()), Member: s.nodeID})
+	pipe.ZRemRangeByScore(ctx, s.key("nodes"), "-inf", strconv.FormatInt(now.Add(-nodeStaleAfter).Unix(), 10))
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// StartHeartbeat sends a heartbeat every interval until ctx is cancelled
+func (s *RedisState) StartHeartbeat(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			beatCtx, cancel := context.WithTimeout(ctx, commandTimeout)
+			s.Heartbeat(beatCtx)
+			cancel()
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// AddActiveRequests adjusts this node's in-flight request count by delta
+func (s *RedisState) AddActiveRequests(ctx context.Context, delta int64) error {
+	return s.client.HIncrBy(ctx, s.key("active"), s.nodeID, delta).Err()
+}
+
+// ActiveRequests returns the in-flight request count across all live nodes
+func (s *RedisState) ActiveRequests(ctx context.Context) (int64, error) {
+	minScore := strconv.FormatInt(time.Now().Add(-nodeStaleAfter).Unix(), 10)
+	nodes, err := s.client.ZRangeByScore(ctx, s.key("nodes"), &redis.ZRangeBy{Min: minScore, Max: "+inf"}).Result()
+	if err != nil {
+		return 0, err
+	}
+	if len(nodes) == 0 {
+		return 0, nil
+	}
+
+	values, err := s.client.HMGet(ctx, s.key("active"), nodes...).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, value := range values {
+		if str, ok := value.(string); ok {
+			count, _ := strconv.ParseInt(str, 10, 64)
+			total += count
+		}
+	}
+	return total, nil
+}
+
+// RecordProviderResult adds a single provider call outcome to the shared counters
+func (s *RedisState) RecordProviderResult(ctx context.Context, provider string, success bool, latency time.Duration) error {
+	key := s.key("provider", provider)
+	pipe := s.client.TxPipeline()
+	pipe.HIncrBy(ctx, key, "total", 1)
+	if !success {
+		pipe.HIncrBy(ctx, key, "failed", 1)
+	}
+	pipe.HIncrBy(ctx, key, "latency_us", latency.Microseconds())
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ProviderStats returns the shared counters for a provider
+func (s *RedisState) ProviderStats(ctx context.Context, provider string) (ProviderStats, error) {
+	stats := ProviderStats{Provider: provider}
+
+	fields, err := s.client.HGetAll(ctx, s.key("provider", provider)).Result()
+	if err != nil {
+		return stats, err
+	}
+
+	stats.TotalRequests, _ = strconv.ParseInt(fields["total"], 10, 64)
+	stats.FailedRequests, _ = strconv.ParseInt(fields["failed"], 10, 64)
+	if stats.TotalRequests > 0 {
+		latencyMicros, _ := strconv.ParseInt(fields["latency_us"], 10, 64)
+		stats.AverageLatency = time.Duration(latencyMicros/stats.TotalRequests) * time.Microsecond
+	}
+	return stats, nil
+}
+
+// AllowRequest consumes one unit of a fixed-window rate limit shared by all replicas
+func (s *RedisState) AllowRequest(ctx context.Context, key string, limit int64, window time.Duration) (bool, error) {
+	count, err := rateLimitScript.Run(ctx, s.client, []string{s.key("ratelimit", key)}, window.Milliseconds()).Int64()
+	if err != nil {
+		return false, err
+	}
+	return count <= limit, nil
+}
+
+// Ping checks that Redis answers
+func (s *RedisState) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
+
+// Close removes this node from the cluster view and closes the connection
+func (s *RedisState) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	pipe := s.client.TxPipeline()
+	pipe.ZRem(ctx, s.key("nodes"), s.nodeID)
+	pipe.HDel(ctx, s.key("active"), s.nodeID)
+	_, err := pipe.Exec(ctx)
+
+	return errors.Join(err, s.client.Close())
+}
+
+// redisIdempotencyRecord is the JSON value stored per idempotency key
+type redisIdempotencyRecord struct {
+	Fingerprint string                     `json:"fingerprint"`
+	Response    *middleware.CachedResponse `json:"response,omitempty"`
+}
+
+// RedisIdempotencyStore shares idempotency reservations and responses between replicas
+type RedisIdempotencyStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisIdempotencyStore creates an idempotency store on top of a RedisState connection
+func NewRedisIdempotencyStore(state *RedisState) *RedisIdempotencyStore {
+	return &RedisIdempotencyStore{
+		client: state.client,
+		prefix: state.key("idempotency"),
+	}
+}
+
+var _ middleware.IdempotencyStore = (*RedisIdempotencyStore)(nil)
+
+// Begin reserves key for a new request or reports the existing entry for it
+func (s *RedisIdempotencyStore) Begin(key, fingerprint string, ttl time.Duration) (middleware.IdempotencyState, *middleware.CachedResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	storeKey := s.prefix + ":" + key
+
+	reservation, err := json.Marshal(redisIdempotencyRecord{Fingerprint: fingerprint})
+	if err != nil {
+		return middleware.IdempotencyNew, nil, err
+	}
+	reserved, err := s.client.SetNX(ctx, storeKey, reservation, ttl).Result()
+	if err != nil {
+		return middleware.IdempotencyNew, nil, err
+	}
+	if reserved {
+		return middleware.IdempotencyNew, nil, nil
+	}
+
+	data, err := s.client.Get(ctx, storeKey).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// The entry expired between SETNX and GET, so try again
+		return s.Begin(key, fingerprint, ttl)
+	}
+	if err != nil {
+		return middleware.IdempotencyNew, nil, err
+	}
+
+	var record redisIdempotencyRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return middleware.IdempotencyNew, nil, fmt.Errorf("corrupt idempotency record: %w", err)
+	}
+	if record.Response != nil {
+		return middleware.IdempotencyCompleted, record.Response, nil
+	}
+	return middleware.IdempotencyInFlight, &middleware.CachedResponse{Fingerprint: record.Fingerprint}, nil
+}
+
+// Complete records the final response for a reserved key
+func (s *RedisIdempotencyStore) Complete(key string, response *middleware.CachedResponse, ttl time.Duration) error {
+	data, err := json.Marshal(redisIdempotencyRecord{
+		Fingerprint: response.Fingerprint,
+		Response:    response,
+	})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	return s.client.Set(ctx, s.prefix+":"+key, data, ttl).Err()
+}
+
+// Abort releases a reservation so the request can be retried. A response
+// recorded for key meanwhile, after the reservation expired, is kept
+func (s *RedisIdempotencyStore) Abort(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	storeKey := s.prefix + ":" + key
+
+	return s.client.Watch(ctx, func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, storeKey).Bytes()
+		if errors.Is(err, redis.Nil) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var record redisIdempotencyRecord
+		if json.Unmarshal(data, &record) == nil && record.Response != nil {
+			return nil
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, storeKey)
+			return nil
+		})
+		return err
+	}, storeKey)
+}
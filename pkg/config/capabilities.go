@@ -0,0 +1,139 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CapabilityOverrides are operator-declared capabilities that take precedence over
+// model-name based detection. Nil fields leave the detected value untouched
+type CapabilityOverrides struct {
+	Text        *bool `yaml:"text,omitempty" json:"text,omitempty"`
+	Image       *bool `yaml:"image,omitempty" json:"image,omitempty"`
+	Code        *bool `yaml:"code,omitempty" json:"code,omitempty"`
+	Audio       *bool `yaml:"audio,omitempty" json:"audio,omitempty"`
+	Video       *bool `yaml:"video,omitempty" json:"video,omitempty"`
+	Multimodal  *bool `yaml:"multimodal,omitempty" json:"multimodal,omitempty"`
+	Reasoning   *int  `yaml:"reasoning,omitempty" json:"reasoning,omitempty"`
+	Knowledge   *int  `yaml:"knowledge,omitempty" json:"knowledge,omitempty"`
+	Computation *int  `yaml:"computation,omitempty" json:"computation,omitempty"`
+}
+
+// CapabilityOverrideColumns are the optional CSV columns holding overrides
+var CapabilityOverrideColumns = []string{
+	"text", "image", "code", "audio", "video", "multimodal",
+	"reasoning", "knowledge", "computation",
+}
+
+// IsEmpty reports whether no override is set
+func (o *CapabilityOverrides) IsEmpty() bool {
+	return o == nil || (o.Text == nil && o.Image == nil && o.Code == nil &&
+		o.Audio == nil && o.Video == nil && o.Multimodal == nil &&
+		o.Reasoning == nil && o.Knowledge == nil && o.Computation == nil)
+}
+
+// Validate checks that scores are within the 1-10 scale used by capability detection
+func (o *CapabilityOverrides) Validate() error {
+	if o == nil {
+		return nil
+	}
+	for name, score := range map[string]*int{
+		"reasoning":   o.Reasoning,
+		"knowledge":   o.Knowledge,
+		"computation": o.Computation,
+	} {
+		if score != nil && (*score < 1 || *score > 10) {
+			return fmt.Errorf("capability override %s must be between 1 and 10, got %d", name, *score)
+		}
+	}
+	return nil
+}
+
+// Set assigns a single override from its column name and textual value. Empty
+// values are ignored so blank CSV cells fall back to detection
+func (o *CapabilityOverrides) Set(name, value string) error {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+
+	switch strings.ToLower(name) {
+	case "text", "image", "code", "audio", "video", "multimodal":
+		enabled, err := parseCapabilityBool(value)
+		if err != nil {
+			return fmt.Errorf("capability override %s: %w", name, err)
+		}
+		switch strings.ToLower(name) {
+		case "text":
+			o.Text = &enabled
+		case "image":
+			o.Image = &enabled
+		case "code":
+			o.Code = &enabled
+		case "audio":
+			o.Audio = &enabled
+		case "video":
+			o.Video = &enabled
+		case "multimodal":
+			o.Multimodal = &enabled
+		}
+	case "reasoning", "knowledge", "computation":
+		score, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("capability override %s: %q is not an integer", name, value)
+		}
+		switch strings.ToLower(name) {
+		case "reasoning":
+			o.Reasoning = &score
+		case "knowledge":
+			o.Knowledge = &score
+		case "computation":
+			o.Computation = &score
+		}
+	default:
+		return fmt.Errorf("unknown capability override %q", name)
+	}
+	return nil
+}
+
+// Cells renders the overrides in CapabilityOverrideColumns order, leaving unset
+// values blank
+func (o *CapabilityOverrides) Cells() []string {
+	cells := make([]string, len(CapabilityOverrideColumns))
+	if o == nil {
+		return cells
+	}
+
+	formatBool := func(value *bool) string {
+		if value == nil {
+			return ""
+		}
+		return strconv.FormatBool(*value)
+	}
+	formatInt := func(value *int) string {
+		if value == nil {
+			return ""
+		}
+		return strconv.Itoa(*value)
+	}
+
+	copy(cells, []string{
+		formatBool(o.Text), formatBool(o.Image), formatBool(o.Code),
+		formatBool(o.Audio), formatBool(o.Video), formatBool(o.Multimodal),
+		formatInt(o.Reasoning), formatInt(o.Knowledge), formatInt(o.Computation),
+	})
+	return cells
+}
+
+// parseCapabilityBool accepts the boolean spellings commonly found in spreadsheets
+func parseCapabilityBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "true", "yes", "y", "1", "x":
+		return true, nil
+	case "false", "no", "n", "0", "-":
+		return false, nil
+	default:
+		return false, fmt.Errorf("%q is not a boolean", value)
+	}
+}
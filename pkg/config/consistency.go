@@ -0,0 +1,408 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrYAMLDirUnset is returned when provider YAML files would be written
+// without a configured directory
+var ErrYAMLDirUnset = errors.New("provider YAML directory is not configured")
+
+// orphanedYAMLDir is where reconciling moves YAML files of unknown providers
+const orphanedYAMLDir = ".orphaned"
+
+// unsafeFileChars matches characters replaced in provider YAML file names
+var unsafeFileChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// ProviderYAMLFileName turns a provider name into the file name its generated
+// YAML is written to
+func ProviderYAMLFileName(name string) string {
+	base := strings.Trim(unsafeFileChars.ReplaceAllString(strings.ToLower(name), "-"), "-.")
+	if base == "" {
+		base = "provider"
+	}
+	return base + ".yaml"
+}
+
+// DriftKind classifies a disagreement between the provider CSV, the YAML
+// files and the providers loaded at runtime
+type DriftKind string
+
+const (
+	// DriftOrphanedYAML is a YAML file naming a provider that is neither in
+	// the CSV nor loaded
+	DriftOrphanedYAML DriftKind = "orphaned_yaml"
+	// DriftInvalidYAML is a YAML file that cannot be parsed
+	DriftInvalidYAML DriftKind = "invalid_yaml"
+	// DriftDuplicateYAML is a provider described by more than one YAML file
+	DriftDuplicateYAML DriftKind = "duplicate_yaml"
+	// DriftMissingYAML is a provider without a YAML file
+	DriftMissingYAML DriftKind = "missing_yaml"
+	// DriftStaleYAML is a YAML file older than the CSV that disagrees with it
+	DriftStaleYAML DriftKind = "stale_yaml"
+	// DriftEditedYAML is a YAML file newer than the CSV that disagrees with
+	// it, usually a manual edit
+	DriftEditedYAML DriftKind = "edited_yaml"
+	// DriftMissingFromRuntime is a CSV provider that is not loaded
+	DriftMissingFromRuntime DriftKind = "missing_from_runtime"
+	// DriftMissingFromCSV is a loaded provider that is not in the CSV
+	DriftMissingFromCSV DriftKind = "missing_from_csv"
+	// DriftRuntimeMismatch is a loaded provider whose settings differ from the CSV
+	DriftRuntimeMismatch DriftKind = "runtime_mismatch"
+)
+
+// Drift is one inconsistency found by CheckConsistency
+type Drift struct {
+	Kind     DriftKind `json:"kind"`
+	Provider string    `json:"provider,omitempty"`
+	File     string    `json:"file,omitempty"`
+	Detail   string    `json:"detail"`
+	// Reconcilable drift is fixed by ReconcileYAMLDir followed by a YAML sync;
+	// the rest needs a manual edit or a restart
+	Reconcilable bool `json:"reconcilable"`
+}
+
+// RuntimeProvider is the part of a loaded provider compared with the CSV
+type RuntimeProvider struct {
+	Name     string
+	Tier     string
+	Endpoint string
+}
+
+// ConsistencyReport lists the drift between the provider CSV, the YAML
+// directory and the providers loaded at runtime
+type ConsistencyReport struct {
+	CheckedAt  time.Time `json:"checked_at"`
+	CSVPath    string    `json:"csv_path"`
+	YAMLDir    string    `json:"yaml_dir"`
+	CSVCount   int       `json:"csv_providers"`
+	YAMLCount  int       `json:"yaml_files"`
+	Runtime    int       `json:"runtime_providers"`
+	Consistent bool      `json:"consistent"`
+	Drift      []Drift   `json:"drift"`
+}
+
+// ReconcileResult is what reconciling changed, and the drift that remains
+type ReconcileResult struct {
+	Actions []string           `json:"actions"`
+	Changes []YAMLChange       `json:"changes"`
+	Report  *ConsistencyReport `json:"report"`
+}
+
+// yamlProviderFile is a provider YAML file found in the directory
+type yamlProviderFile struct {
+	file     string
+	name     string
+	tier     string
+	endpoint string
+	modTime  time.Time
+}
+
+// CheckConsistency cross-checks the CSV at csvPath, the YAML files in yamlDir
+// and the runtime providers. YAML files are matched to providers by the name
+// they contain, falling back to the file name, ignoring case. A missing CSV
+// or directory counts as empty
+func CheckConsistency(csvPath, yamlDir string, runtime []RuntimeProvider) (*ConsistencyReport, error) {
+	report := &ConsistencyReport{
+		CheckedAt: time.Now().UTC(),
+		CSVPath:   csvPath,
+		YAMLDir:   yamlDir,
+		Runtime:   len(runtime),
+		Drift:     []Drift{},
+	}
+
+	csvProviders := make(map[string]CSVProvider)
+	var csvModTime time.Time
+	if info, err := os.Stat(csvPath); err == nil {
+		csvModTime = info.ModTime()
+		parsed, err := ReadProviderCSVFile(csvPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, provider := range parsed.Providers {
+			csvProviders[strings.ToLower(provider.Name)] = provider
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read %s: %w", csvPath, err)
+	}
+	report.CSVCount = len(csvProviders)
+
+	runtimeProviders := make(map[string]RuntimeProvider, len(runtime))
+	for _, provider := range runtime {
+		runtimeProviders[strings.ToLower(provider.Name)] = provider
+	}
+
+	files, invalid, err := readProviderYAMLDir(yamlDir)
+	if err != nil {
+		return nil, err
+	}
+	report.YAMLCount = len(files) + len(invalid)
+	for file, parseErr := range invalid {
+		report.add(Drift{Kind: DriftInvalidYAML, File: file, Detail: parseErr.Error()})
+	}
+
+	yamlByProvider := make(map[string][]yamlProviderFile)
+	for _, file := range files {
+		key := strings.ToLower(file.name)
+		_, inCSV := csvProviders[key]
+		_, inRuntime := runtimeProviders[key]
+		if !inCSV && !inRuntime {
+			report.add(Drift{
+				Kind: DriftOrphanedYAML, Provider: file.name, File: file.file,
+				Detail:       "no provider with this name is in the CSV or loaded",
+				Reconcilable: true,
+			})
+			continue
+		}
+		yamlByProvider[key] = append(yamlByProvider[key], file)
+	}
+
+	// Every provider known to either the CSV or the runtime should have one YAML file
+	known := make(map[string]string)
+	for key, provider := range csvProviders {
+		known[key] = provider.Name
+	}
+	for key, provider := range runtimeProviders {
+		known[key] = provider.Name
+	}
+	for key, name := range known {
+		// Syncing generates YAML from the runtime providers only
+		_, loaded := runtimeProviders[key]
+		matches := yamlByProvider[key]
+		switch {
+		case len(matches) == 0:
+			report.add(Drift{
+				Kind: DriftMissingYAML, Provider: name, File: ProviderYAMLFileName(name),
+				Detail:       "provider has no YAML file",
+				Reconcilable: loaded,
+			})
+		case len(matches) > 1:
+			names := make([]string, len(matches))
+			for i, match := range matches {
+				names[i] = match.file
+			}
+			sort.Strings(names)
+			report.add(Drift{
+				Kind: DriftDuplicateYAML, Provider: name,
+				Detail: "provider is described by " + strings.Join(names, ", "),
+			})
+		}
+
+		csvProvider, inCSV := csvProviders[key]
+		if !inCSV {
+			continue
+		}
+		for _, file := range matches {
+			differences := compareProvider(csvProvider, file.tier, file.endpoint)
+			if len(differences) == 0 {
+				continue
+			}
+			drift := Drift{Provider: name, File: file.file, Detail: strings.Join(differences, "; ")}
+			if file.modTime.Before(csvModTime) {
+				drift.Kind = DriftStaleYAML
+				drift.Reconcilable = loaded
+			} else {
+				drift.Kind = DriftEditedYAML
+			}
+			report.add(drift)
+		}
+	}
+
+	for key, provider := range csvProviders {
+		runtimeProvider, loaded := runtimeProviders[key]
+		if !loaded {
+			report.add(Drift{
+				Kind: DriftMissingFromRuntime, Provider: provider.Name,
+				Detail: "provider is in the CSV but not loaded; providers are loaded at startup",
+			})
+			continue
+		}
+		if differences := compareProvider(provider, runtimeProvider.Tier, runtimeProvider.Endpoint); len(differences) > 0 {
+			report.add(Drift{
+				Kind: DriftRuntimeMismatch, Provider: provider.Name,
+				Detail: strings.Join(differences, "; ") + "; providers are loaded at startup",
+			})
+		}
+	}
+	for key, provider := range runtimeProviders {
+		if _, inCSV := csvProviders[key]; !inCSV {
+			report.add(Drift{
+				Kind: DriftMissingFromCSV, Provider: provider.Name,
+				Detail: "provider is loaded but not in the CSV",
+			})
+		}
+	}
+
+	sort.Slice(report.Drift, func(i, j int) bool {
+		a, b := report.Drift[i], report.Drift[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		return a.File < b.File
+	})
+	report.Consistent = len(report.Drift) == 0
+	return report, nil
+}
+
+// add appends a drift entry
+func (r *ConsistencyReport) add(drift Drift) {
+	r.Drift = append(r.Drift, drift)
+}
+
+// compareProvider describes how tier and endpoint differ from the CSV row
+func compareProvider(csvProvider CSVProvider, providerTier, endpoint string) []string {
+	var differences []string
+	if providerTier != "" && !strings.EqualFold(providerTier, csvProvider.Tier.String()) {
+		differences = append(differences, fmt.Sprintf("tier is %q, CSV has %q", providerTier, csvProvider.Tier))
+	}
+	if endpoint != "" && strings.TrimRight(endpoint, "/") != strings.TrimRight(csvProvider.Endpoint, "/") {
+		differences = append(differences, fmt.Sprintf("endpoint is %q, CSV has %q", endpoint, csvProvider.Endpoint))
+	}
+	return differences
+}
+
+// readProviderYAMLDir parses every .yaml and .yml file in dir. Both the flat
+// layout the enhanced server generates and the nested provider: layout of
+// configure-providers are understood
+func readProviderYAMLDir(dir string) ([]yamlProviderFile, map[string]error, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	var files []yamlProviderFile
+	invalid := make(map[string]error)
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", entry.Name(), err)
+		}
+
+		var doc struct {
+			Name     string `yaml:"name"`
+			Tier     string `yaml:"tier"`
+			BaseURL  string `yaml:"base_url"`
+			Provider struct {
+				Name    string `yaml:"name"`
+				Tier    string `yaml:"tier"`
+				BaseURL string `yaml:"base_url"`
+			} `yaml:"provider"`
+		}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			invalid[entry.Name()] = err
+			continue
+		}
+
+		file := yamlProviderFile{
+			file:     entry.Name(),
+			name:     firstNonEmpty(doc.Provider.Name, doc.Name, strings.TrimSuffix(entry.Name(), ext)),
+			tier:     firstNonEmpty(doc.Provider.Tier, doc.Tier),
+			endpoint: firstNonEmpty(doc.Provider.BaseURL, doc.BaseURL),
+			modTime:  info.ModTime(),
+		}
+		files = append(files, file)
+	}
+	return files, invalid, nil
+}
+
+// firstNonEmpty returns the first non-blank value
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// ReconcileYAMLDir fixes the file-level drift in report: orphaned YAML files
+// are moved into a .orphaned subdirectory, and a provider's only YAML file is
+// renamed to ProviderYAMLFileName so the next sync merges into it rather than
+// creating a second file. It returns a description of each action taken
+func ReconcileYAMLDir(report *ConsistencyReport) ([]string, error) {
+	var actions []string
+	for _, drift := range report.Drift {
+		if drift.Kind != DriftOrphanedYAML {
+			continue
+		}
+		archive := filepath.Join(report.YAMLDir, orphanedYAMLDir)
+		if err := os.MkdirAll(archive, 0755); err != nil {
+			return actions, err
+		}
+		if err := os.Rename(filepath.Join(report.YAMLDir, drift.File), filepath.Join(archive, drift.File)); err != nil {
+			return actions, fmt.Errorf("failed to archive %s: %w", drift.File, err)
+		}
+		actions = append(actions, fmt.Sprintf("moved orphaned %s to %s", drift.File, filepath.Join(orphanedYAMLDir, drift.File)))
+	}
+
+	files, _, err := readProviderYAMLDir(report.YAMLDir)
+	if err != nil {
+		return actions, err
+	}
+	byProvider := make(map[string][]yamlProviderFile)
+	for _, file := range files {
+		key := strings.ToLower(file.name)
+		byProvider[key] = append(byProvider[key], file)
+	}
+	for _, matches := range byProvider {
+		if len(matches) != 1 {
+			continue
+		}
+		file := matches[0]
+		canonical := ProviderYAMLFileName(file.name)
+		if file.file == canonical {
+			continue
+		}
+		from := filepath.Join(report.YAMLDir, file.file)
+		to := filepath.Join(report.YAMLDir, canonical)
+		if _, err := os.Stat(to); err == nil && !sameFile(from, to) {
+			continue
+		}
+		if err := renameFile(from, to); err != nil {
+			return actions, fmt.Errorf("failed to rename %s: %w", file.file, err)
+		}
+		actions = append(actions, fmt.Sprintf("renamed %s to %s", file.file, canonical))
+	}
+	return actions, nil
+}
+
+// sameFile reports whether two paths name the same file, as differently
+// cased names do on case-insensitive file systems
+func sameFile(a, b string) bool {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(infoA, infoB)
+}
+
+// renameFile renames from to to, going through a temporary name so a change
+// of case also works on case-insensitive file systems
+func renameFile(from, to string) error {
+	tmp := to + ".renaming"
+	if err := os.Rename(from, tmp); err != nil {
+		return err
+	}
+	return os.Rename(tmp, to)
+}
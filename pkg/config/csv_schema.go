@@ -0,0 +1,532 @@
+package config
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
+)
+
+// CSVSchemaVersion is the current providers.csv schema version
+const CSVSchemaVersion = 2
+
+// ErrProviderExists is returned when adding a provider whose name is taken
+var ErrProviderExists = errors.New("provider already exists")
+
+// CSVSchemaColumns are the required columns of a v2 providers.csv, in order.
+// Capability override columns and a trailing description column are optional
+var CSVSchemaColumns = []string{
+	"name", "tier", "endpoint", "auth", "models",
+	"capabilities", "limits", "region", "priority",
+}
+
+// csvDescriptionColumn holds free-form notes (the "Other" column of v1 files)
+const csvDescriptionColumn = "description"
+
+// legacyCSVColumns maps the column names used by v1 files onto v2 columns. v1
+// files came in 4, 5 and 6 column variants with differing orders, so columns
+// are matched by header name rather than position
+var legacyCSVColumns = map[string]string{
+	"name":          "name",
+	"tier":          "tier",
+	"base_url":      "endpoint",
+	"endpoint":      "endpoint",
+	"url":           "endpoint",
+	"apikey":        "auth",
+	"api_key":       "auth",
+	"model(s)":      "models",
+	"models":        "models",
+	"models_source": "models",
+	"other":         csvDescriptionColumn,
+	"description":   csvDescriptionColumn,
+	"priority":      "priority",
+}
+
+func init() {
+	// Override columns predate v2 and keep their names
+	for _, column := range CapabilityOverrideColumns {
+		legacyCSVColumns[column] = column
+	}
+}
+
+// limitAliases are the short limit names accepted in the limits column
+var limitAliases = map[string]string{
+	"rpm": "requests_per_minute",
+	"rpd": "requests_per_day",
+	"tpm": "tokens_per_minute",
+}
+
+// legacyRateLimit extracts "N requests per minute" from v1 free-form notes
+var legacyRateLimit = regexp.MustCompile(`(?i)(\d+)\s+requests?\s+per\s+minute`)
+
+// ProviderCSV is a parsed providers.csv file
+type ProviderCSV struct {
+	// Version is the schema version the file was written in; v1 files are
+	// upgraded in memory and need MigrateCSV to be rewritten on disk
+	Version   int
+	Providers []CSVProvider
+	// OverrideColumns is whether the header has the capability override columns
+	OverrideColumns bool
+}
+
+// ReadProviderCSVFile parses the providers.csv at path
+func ReadProviderCSVFile(path string) (*ProviderCSV, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CSV file: %w", err)
+	}
+	defer file.Close()
+
+	return ReadProviderCSV(file)
+}
+
+// ReadProviderCSV parses a providers.csv in either schema version. Every provider
+// parser goes through here so all of them agree on the meaning of each column.
+// ${VAR} references in any cell are resolved from the environment
+func ReadProviderCSV(r io.Reader) (*ProviderCSV, error) {
+	return readProviderCSV(r, true)
+}
+
+// ReadProviderCSVTemplate parses a providers.csv like ReadProviderCSV but keeps
+// ${VAR} references in text cells as written, for tools that convert the file
+// without resolving its secrets
+func ReadProviderCSVTemplate(r io.Reader) (*ProviderCSV, error) {
+	return readProviderCSV(r, false)
+}
+
+// readProviderCSV parses a providers.csv, optionally resolving ${VAR} references
+func readProviderCSV(r io.Reader, expandEnv bool) (*ProviderCSV, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("CSV file is empty")
+	}
+
+	header := records[0]
+	version := DetectCSVSchema(header)
+	columns, err := csvColumnIndex(header, version)
+	if err != nil {
+		return nil, err
+	}
+
+	_, overrideColumns := columns[CapabilityOverrideColumns[0]]
+	result := &ProviderCSV{Version: version, OverrideColumns: overrideColumns}
+	for i, record := range records[1:] {
+		line := i + 2
+		if isBlankRecord(record) {
+			continue
+		}
+		if err := expandCSVRecord(record, header, !expandEnv); err != nil {
+			return nil, fmt.Errorf("invalid CSV format at line %d: %w", line, err)
+		}
+
+		provider, err := parseCSVProvider(record, columns, version)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV format at line %d: %w", line, err)
+		}
+		if provider.Name == "" {
+			return nil, fmt.Errorf("invalid CSV format at line %d: name is required", line)
+		}
+		result.Providers = append(result.Providers, provider)
+	}
+
+	return result, nil
+}
+
+// DetectCSVSchema returns 2 when the header starts with CSVSchemaColumns and 1 otherwise
+func DetectCSVSchema(header []string) int {
+	if len(header) < len(CSVSchemaColumns) {
+		return 1
+	}
+	for i, column := range CSVSchemaColumns {
+		if normalizeCSVColumn(header[i]) != column {
+			return 1
+		}
+	}
+	return CSVSchemaVersion
+}
+
+// WriteProviderCSV writes providers in the current schema. Override columns are
+// only written when at least one provider sets an override
+func WriteProviderCSV(w io.Writer, providers []CSVProvider) error {
+	return WriteProviderCSVColumns(w, providers, false)
+}
+
+// WriteProviderCSVColumns writes providers like WriteProviderCSV, adding the
+// override columns even without overrides when overrideColumns is set, so a
+// file that had them keeps them
+func WriteProviderCSVColumns(w io.Writer, providers []CSVProvider, overrideColumns bool) error {
+	withOverrides := overrideColumns
+	for _, provider := range providers {
+		if !provider.CapabilityOverrides.IsEmpty() {
+			withOverrides = true
+		}
+	}
+
+	header := append([]string{}, CSVSchemaColumns...)
+	if withOverrides {
+		header = append(header, CapabilityOverrideColumns...)
+	}
+	header = append(header, csvDescriptionColumn)
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	for _, provider := range providers {
+		if err := writer.Write(providerCSVRecord(header, provider)); err != nil {
+			return fmt.Errorf("failed to write provider %s: %w", provider.Name, err)
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// AppendProviderCSV adds provider as the last row of the providers.csv in
+// content, keeping every other line as written so ${VAR} references stay
+// unresolved. Override columns are added to the header when provider sets
+// overrides and the file has none. Empty content starts a new file in the
+// current schema; files in an older schema have to be migrated first
+func AppendProviderCSV(content []byte, provider CSVProvider) ([]byte, error) {
+	if len(bytes.TrimSpace(content)) == 0 {
+		var buf bytes.Buffer
+		if err := WriteProviderCSV(&buf, []CSVProvider{provider}); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	parsed, err := readProviderCSV(bytes.NewReader(content), false)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Version < CSVSchemaVersion {
+		return nil, fmt.Errorf("providers CSV uses schema v%d; migrate it with migrate-csv first", parsed.Version)
+	}
+	for _, existing := range parsed.Providers {
+		if strings.EqualFold(existing.Name, provider.Name) {
+			return nil, fmt.Errorf("%w: %s", ErrProviderExists, existing.Name)
+		}
+	}
+
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	columns, err := csvColumnIndex(header, CSVSchemaVersion)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if _, ok := columns[CapabilityOverrideColumns[0]]; !ok && !provider.CapabilityOverrides.IsEmpty() {
+		// Columns are matched by name, so override columns can follow the
+		// description column; existing rows just leave them empty
+		end := bytes.IndexByte(content, '\n')
+		if end < 0 {
+			end = len(content)
+		}
+		buf.Write(bytes.TrimRight(content[:end], "\r"))
+		buf.WriteString("," + strings.Join(CapabilityOverrideColumns, ","))
+		content = content[end:]
+		header = append(header, CapabilityOverrideColumns...)
+	}
+	buf.Write(content)
+	if !bytes.HasSuffix(content, []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write(providerCSVRecord(header, provider)); err != nil {
+		return nil, fmt.Errorf("failed to write provider %s: %w", provider.Name, err)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// FormatProviderCSVRow renders provider as one line of a providers.csv in the
+// current schema, without override columns
+func FormatProviderCSVRow(provider CSVProvider) (string, error) {
+	header := append(append([]string{}, CSVSchemaColumns...), csvDescriptionColumn)
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write(providerCSVRecord(header, provider)); err != nil {
+		return "", err
+	}
+	writer.Flush()
+	return strings.TrimSuffix(buf.String(), "\n"), writer.Error()
+}
+
+// providerCSVRecord renders provider with the columns of header, in order
+func providerCSVRecord(header []string, provider CSVProvider) []string {
+	priority := ""
+	if provider.Priority != 0 {
+		priority = strconv.Itoa(provider.Priority)
+	}
+	cells := map[string]string{
+		"name":               provider.Name,
+		"tier":               string(provider.Tier),
+		"endpoint":           provider.Endpoint,
+		"auth":               provider.APIKey,
+		"models":             provider.ModelsSource,
+		"capabilities":       strings.Join(provider.Capabilities, "|"),
+		"limits":             FormatCSVLimits(provider.Limits),
+		"region":             provider.Region,
+		"priority":           priority,
+		csvDescriptionColumn: provider.Description,
+	}
+	for i, cell := range provider.CapabilityOverrides.Cells() {
+		cells[CapabilityOverrideColumns[i]] = cell
+	}
+
+	record := make([]string, len(header))
+	for i, column := range header {
+		record[i] = cells[normalizeCSVColumn(column)]
+	}
+	return record
+}
+
+// MigrateCSV rewrites a providers.csv of any schema version in the current
+// schema and returns the version it was read in. ${VAR} references in text
+// columns are kept as written so secrets never end up in the migrated file;
+// numeric columns have to be resolved to be parsed
+func MigrateCSV(r io.Reader, w io.Writer) (int, error) {
+	parsed, err := readProviderCSV(r, false)
+	if err != nil {
+		return 0, err
+	}
+	return parsed.Version, WriteProviderCSV(w, parsed.Providers)
+}
+
+// ProviderConfig converts a CSV row into a provider configuration without any
+// detected capability scores or cost data
+func (p CSVProvider) ProviderConfig() ProviderConfig {
+	source := ParseModelsSource(p.ModelsSource)
+	return ProviderConfig{
+		ID:                   strings.ToLower(strings.ReplaceAll(p.Name, " ", "_")),
+		Name:                 p.Name,
+		Tier:                 p.Tier,
+		Endpoint:             p.Endpoint,
+		APIKey:               p.APIKey,
+		Priority:             p.Priority,
+		Enabled:              true,
+		Description:          p.Description,
+		ModelsSource:         source,
+		Models:               source.List(),
+		Region:               p.Region,
+		Limits:               p.Limits,
+		DeclaredCapabilities: p.Capabilities,
+		CapabilityOverrides:  p.CapabilityOverrides,
+		Metadata: map[string]string{
+			"source": "csv",
+		},
+	}
+}
+
+// ParseCSVLimits parses a limits cell such as "rpm=60|tpm=90000"
+func ParseCSVLimits(value string) (map[string]int, error) {
+	limits := make(map[string]int)
+	for _, pair := range splitCSVList(value) {
+		key, raw, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("limit %q must be written as name=value", pair)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		if alias, ok := limitAliases[key]; ok {
+			key = alias
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("limit %s must be a non-negative integer, got %q", key, raw)
+		}
+		limits[key] = limit
+	}
+	return limits, nil
+}
+
+// FormatCSVLimits renders limits in the form accepted by ParseCSVLimits
+func FormatCSVLimits(limits map[string]int) string {
+	keys := make([]string, 0, len(limits))
+	for key := range limits {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%d", key, limits[key]))
+	}
+	return strings.Join(pairs, "|")
+}
+
+// csvColumnIndex maps v2 column names to their position in the header
+func csvColumnIndex(header []string, version int) (map[string]int, error) {
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = normalizeCSVColumn(name)
+		if version < CSVSchemaVersion {
+			mapped, ok := legacyCSVColumns[name]
+			if !ok {
+				continue
+			}
+			name = mapped
+		}
+		if _, seen := columns[name]; !seen {
+			columns[name] = i
+		}
+	}
+
+	for _, required := range []string{"name", "tier", "endpoint"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV header is missing the %s column", required)
+		}
+	}
+	return columns, nil
+}
+
+// parseCSVProvider converts one record using the header column index
+func parseCSVProvider(record []string, columns map[string]int, version int) (CSVProvider, error) {
+	cell := func(name string) string {
+		if idx, ok := columns[name]; ok && idx < len(record) {
+			return strings.TrimSpace(record[idx])
+		}
+		return ""
+	}
+
+	provider := CSVProvider{
+		Name:         cell("name"),
+		Tier:         tier.Normalize(cell("tier")),
+		Endpoint:     cell("endpoint"),
+		APIKey:       cell("auth"),
+		ModelsSource: cell("models"),
+		Capabilities: splitCSVList(cell("capabilities")),
+		Region:       cell("region"),
+		Description:  cell(csvDescriptionColumn),
+	}
+
+	limits, err := ParseCSVLimits(cell("limits"))
+	if err != nil {
+		return provider, err
+	}
+	if version < CSVSchemaVersion {
+		// v1 files only mention rate limits in the notes column
+		if match := legacyRateLimit.FindStringSubmatch(provider.Description); match != nil {
+			limits["requests_per_minute"], _ = strconv.Atoi(match[1])
+		}
+	}
+	if len(limits) > 0 {
+		provider.Limits = limits
+	}
+
+	if raw := cell("priority"); raw != "" {
+		priority, err := strconv.Atoi(raw)
+		if err != nil {
+			return provider, fmt.Errorf("priority must be an integer, got %q", raw)
+		}
+		provider.Priority = priority
+	}
+
+	overrides := &CapabilityOverrides{}
+	for _, column := range CapabilityOverrideColumns {
+		if err := overrides.Set(column, cell(column)); err != nil {
+			return provider, err
+		}
+	}
+	if err := overrides.Validate(); err != nil {
+		return provider, err
+	}
+	if !overrides.IsEmpty() {
+		provider.CapabilityOverrides = overrides
+	}
+
+	return provider, nil
+}
+
+// normalizeCSVColumn lower-cases a header cell and strips a UTF-8 BOM
+func normalizeCSVColumn(name string) string {
+	return strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+}
+
+// splitCSVList splits a pipe-delimited cell, dropping empty entries
+func splitCSVList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, "|") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// expandCSVRecord resolves ${VAR} references in the cells of a record, or only
+// in the numeric limits, priority and override columns when numericOnly is set
+func expandCSVRecord(record, header []string, numericOnly bool) error {
+	var problems []string
+	for i, cell := range record {
+		column := fmt.Sprintf("column %d", i+1)
+		if i < len(header) {
+			column = normalizeCSVColumn(header[i])
+		}
+		if numericOnly && !isNumericCSVColumn(column) {
+			continue
+		}
+
+		expanded, err := ExpandEnv(cell)
+		if _, missing := err.(*MissingEnvError); missing {
+			// Keep going so one error lists every missing variable on the line
+			problems = append(problems, fmt.Sprintf("%s: %v", column, err))
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", column, err)
+		}
+		record[i] = expanded
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// isNumericCSVColumn reports whether a column is parsed into a non-string value
+func isNumericCSVColumn(column string) bool {
+	if column == "limits" || column == "priority" {
+		return true
+	}
+	for _, override := range CapabilityOverrideColumns {
+		if column == override {
+			return true
+		}
+	}
+	return false
+}
+
+// isBlankRecord reports whether every cell of a record is empty
+func isBlankRecord(record []string) bool {
+	for _, cell := range record {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,277 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// MissingEnvError reports required environment variables that are not set
+type MissingEnvError struct {
+	Vars     []string
+	Messages map[string]string
+}
+
+// Error implements the error interface
+func (e *MissingEnvError) Error() string {
+	parts := make([]string, 0, len(e.Vars))
+	for _, name := range e.Vars {
+		if message := e.Messages[name]; message != "" {
+			parts = append(parts, fmt.Sprintf("%s (%s)", name, message))
+		} else {
+			parts = append(parts, name)
+		}
+	}
+	return "required environment variable not set: " + strings.Join(parts, ", ")
+}
+
+// ExpandEnv resolves ${VAR} references in s from the process environment.
+// See ExpandEnvWith for the syntax
+func ExpandEnv(s string) (string, error) {
+	return ExpandEnvWith(s, os.LookupEnv)
+}
+
+// ExpandEnvWith resolves references in s using lookup:
+//
+//	${VAR}           value of VAR; an error when unset or empty
+//	${VAR:-default}  value of VAR, or default when unset or empty
+//	${VAR:?message}  like ${VAR}, with message included in the error
+//	$${              a literal "${"
+//
+// Defaults may themselves contain references. A "$" not followed by "{" is
+// kept as is, so secrets containing dollar signs need no escaping. Every
+// missing variable is reported in one *MissingEnvError
+func ExpandEnvWith(s string, lookup func(string) (string, bool)) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	missing := &MissingEnvError{Messages: map[string]string{}}
+	result, err := expandEnv(s, lookup, missing)
+	if err != nil {
+		return "", err
+	}
+	if len(missing.Vars) > 0 {
+		sort.Strings(missing.Vars)
+		return "", missing
+	}
+	return result, nil
+}
+
+// ProviderAPIKeyVariable names the variable holding a provider's API key,
+// <NAME>_API_KEY, where NAME is the provider name upper-cased with other
+// characters replaced by _
+func ProviderAPIKeyVariable(provider string) string {
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, provider)
+	return name + "_API_KEY"
+}
+
+// ExpandEnvAll expands every value in place from the process environment. All
+// missing variables across the values are reported in one *MissingEnvError
+func ExpandEnvAll(values ...*string) error {
+	missing := &MissingEnvError{Messages: map[string]string{}}
+	for _, value := range values {
+		expanded, err := ExpandEnv(*value)
+		if missingErr, ok := err.(*MissingEnvError); ok {
+			for _, name := range missingErr.Vars {
+				message := missingErr.Messages[name]
+				if _, seen := missing.Messages[name]; !seen {
+					missing.Vars = append(missing.Vars, name)
+					missing.Messages[name] = message
+				} else if message != "" {
+					missing.Messages[name] = message
+				}
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		*value = expanded
+	}
+
+	if len(missing.Vars) > 0 {
+		sort.Strings(missing.Vars)
+		return missing
+	}
+	return nil
+}
+
+// ExpandEnv resolves ${VAR} references in the endpoint, URL, credentials,
+// headers, region, models, models source, declared capabilities and metadata
+// of a provider
+func (p *ProviderConfig) ExpandEnv() error {
+	values := []*string{&p.Endpoint, &p.URL, &p.APIKey, &p.Region}
+	for i := range p.DeclaredCapabilities {
+		values = append(values, &p.DeclaredCapabilities[i])
+	}
+	for i := range p.Models {
+		values = append(values, &p.Models[i])
+	}
+
+	// Map values and models source values are copied out and written back
+	headers, headerValues := mapValues(p.Headers)
+	metadata, metadataValues := mapValues(p.Metadata)
+	for _, mapped := range [][]string{headerValues, metadataValues} {
+		for i := range mapped {
+			values = append(values, &mapped[i])
+		}
+	}
+	var source []string
+	switch value := p.ModelsSource.Value.(type) {
+	case string:
+		source = []string{value}
+	case []string:
+		source = append([]string{}, value...)
+	case []interface{}:
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				source = append(source, s)
+			}
+		}
+	}
+	for i := range source {
+		values = append(values, &source[i])
+	}
+
+	if err := ExpandEnvAll(values...); err != nil {
+		return err
+	}
+	for i, key := range headers {
+		p.Headers[key] = headerValues[i]
+	}
+	for i, key := range metadata {
+		p.Metadata[key] = metadataValues[i]
+	}
+	switch value := p.ModelsSource.Value.(type) {
+	case string:
+		p.ModelsSource.Value = source[0]
+	case []string:
+		p.ModelsSource.Value = source
+	case []interface{}:
+		expanded := make([]interface{}, len(value))
+		next := 0
+		for i, item := range value {
+			expanded[i] = item
+			if _, ok := item.(string); ok {
+				expanded[i] = source[next]
+				next++
+			}
+		}
+		p.ModelsSource.Value = expanded
+	}
+	return nil
+}
+
+// mapValues returns the keys of m and their values in the same order
+func mapValues(m map[string]string) ([]string, []string) {
+	keys := make([]string, 0, len(m))
+	values := make([]string, 0, len(m))
+	for key, value := range m {
+		keys = append(keys, key)
+		values = append(values, value)
+	}
+	return keys, values
+}
+
+// expandEnv does the work of ExpandEnvWith, collecting missing variables
+func expandEnv(s string, lookup func(string) (string, bool), missing *MissingEnvError) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		if strings.HasPrefix(s[i:], "$${") {
+			sb.WriteString("${")
+			i += 3
+			continue
+		}
+		if !strings.HasPrefix(s[i:], "${") {
+			sb.WriteByte(s[i])
+			i++
+			continue
+		}
+
+		end := matchingBrace(s, i+2)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated variable reference in %q", s)
+		}
+		expr := s[i+2 : end]
+		i = end + 1
+
+		name, operator, operand := expr, "", ""
+		if idx := strings.IndexByte(expr, ':'); idx >= 0 {
+			name = expr[:idx]
+			if rest := expr[idx:]; len(rest) >= 2 {
+				operator, operand = rest[:2], rest[2:]
+			} else {
+				operator = rest
+			}
+		}
+		if !validEnvName(name) {
+			return "", fmt.Errorf("invalid variable reference ${%s}", expr)
+		}
+
+		value, ok := lookup(name)
+		if ok && value != "" {
+			sb.WriteString(value)
+			continue
+		}
+
+		switch operator {
+		case ":-":
+			expanded, err := expandEnv(operand, lookup, missing)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(expanded)
+		case "", ":?":
+			if _, seen := missing.Messages[name]; !seen {
+				missing.Vars = append(missing.Vars, name)
+				missing.Messages[name] = operand
+			} else if operand != "" {
+				missing.Messages[name] = operand
+			}
+		default:
+			return "", fmt.Errorf("invalid variable reference ${%s}: use ${%s:-default} or ${%s:?message}", expr, name, name)
+		}
+	}
+	return sb.String(), nil
+}
+
+// matchingBrace returns the index of the "}" closing a reference whose body
+// starts at start, allowing nested references in defaults
+func matchingBrace(s string, start int) int {
+	depth := 1
+	for i := start; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "${"):
+			depth++
+			i++
+		case s[i] == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// validEnvName reports whether name is a POSIX-style variable name
+func validEnvName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r == '_' || (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z') || (i > 0 && r >= '0' && r <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
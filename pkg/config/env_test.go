@@ -0,0 +1,69 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestProviderConfigExpandEnv(t *testing.T) {
+	t.Setenv("PAL_TEST_VALUE", "resolved")
+
+	tests := []struct {
+		name   string
+		config ProviderConfig
+		get    func(p ProviderConfig) interface{}
+		want   interface{}
+	}{
+		{"endpoint", ProviderConfig{Endpoint: "https://${PAL_TEST_VALUE}.example.com"},
+			func(p ProviderConfig) interface{} { return p.Endpoint }, "https://resolved.example.com"},
+		{"url", ProviderConfig{URL: "https://${PAL_TEST_VALUE}.example.com/v1"},
+			func(p ProviderConfig) interface{} { return p.URL }, "https://resolved.example.com/v1"},
+		{"api key", ProviderConfig{APIKey: "${PAL_TEST_VALUE}"},
+			func(p ProviderConfig) interface{} { return p.APIKey }, "resolved"},
+		{"region", ProviderConfig{Region: "${PAL_TEST_VALUE}"},
+			func(p ProviderConfig) interface{} { return p.Region }, "resolved"},
+		{"models", ProviderConfig{Models: []string{"gpt-4o", "${PAL_TEST_VALUE}"}},
+			func(p ProviderConfig) interface{} { return p.Models }, []string{"gpt-4o", "resolved"}},
+		{"headers", ProviderConfig{Headers: map[string]string{"Authorization": "Bearer ${PAL_TEST_VALUE}", "X-Plain": "plain"}},
+			func(p ProviderConfig) interface{} { return p.Headers }, map[string]string{"Authorization": "Bearer resolved", "X-Plain": "plain"}},
+		{"metadata", ProviderConfig{Metadata: map[string]string{"team": "${PAL_TEST_VALUE}"}},
+			func(p ProviderConfig) interface{} { return p.Metadata }, map[string]string{"team": "resolved"}},
+		{"declared capabilities", ProviderConfig{DeclaredCapabilities: []string{"${PAL_TEST_VALUE}"}},
+			func(p ProviderConfig) interface{} { return p.DeclaredCapabilities }, []string{"resolved"}},
+		{"models source endpoint", ProviderConfig{ModelsSource: ModelsSource{Type: "endpoint", Value: "https://${PAL_TEST_VALUE}.example.com/models"}},
+			func(p ProviderConfig) interface{} { return p.ModelsSource.Value }, "https://resolved.example.com/models"},
+		{"models source list", ProviderConfig{ModelsSource: ModelsSource{Type: "list", Value: []string{"${PAL_TEST_VALUE}"}}},
+			func(p ProviderConfig) interface{} { return p.ModelsSource.Value }, []string{"resolved"}},
+		{"models source from YAML", ProviderConfig{ModelsSource: ModelsSource{Type: "list", Value: []interface{}{"${PAL_TEST_VALUE}", 3}}},
+			func(p ProviderConfig) interface{} { return p.ModelsSource.Value }, []interface{}{"resolved", 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			if err := config.ExpandEnv(); err != nil {
+				t.Fatalf("ExpandEnv: %v", err)
+			}
+			if got := tt.get(config); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProviderConfigExpandEnvReportsEveryMissingVariable(t *testing.T) {
+	config := ProviderConfig{
+		Models:       []string{"${PAL_TEST_MISSING_MODEL}"},
+		Headers:      map[string]string{"Authorization": "${PAL_TEST_MISSING_HEADER}"},
+		URL:          "${PAL_TEST_MISSING_URL}",
+		ModelsSource: ModelsSource{Type: "endpoint", Value: "${PAL_TEST_MISSING_SOURCE}"},
+	}
+	var missing *MissingEnvError
+	if err := config.ExpandEnv(); !errors.As(err, &missing) {
+		t.Fatalf("ExpandEnv = %v, want a *MissingEnvError", err)
+	}
+	want := []string{"PAL_TEST_MISSING_HEADER", "PAL_TEST_MISSING_MODEL", "PAL_TEST_MISSING_SOURCE", "PAL_TEST_MISSING_URL"}
+	if !reflect.DeepEqual(missing.Vars, want) {
+		t.Errorf("missing = %v, want %v", missing.Vars, want)
+	}
+}
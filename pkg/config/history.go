@@ -0,0 +1,381 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// historyIndexFile lists every revision in the history directory
+const historyIndexFile = "revisions.json"
+
+// ErrRevisionNotFound is returned for a revision ID that was never recorded
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// ErrUntrackedFile is returned when writing a file the history does not track
+var ErrUntrackedFile = errors.New("file is not tracked")
+
+// Revision is one recorded version of a tracked configuration file
+type Revision struct {
+	ID   int    `json:"id"`
+	File string `json:"file"`
+	// Hash is the SHA-256 of the content, which is stored under objects/
+	Hash string `json:"hash,omitempty"`
+	// Deleted revisions record that the file was removed
+	Deleted   bool      `json:"deleted,omitempty"`
+	Author    string    `json:"author"`
+	Message   string    `json:"message,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ConfigHistory keeps every version of a set of configuration files, such as
+// providers.csv and the provider YAML directory, so changes can be listed,
+// compared and rolled back. Contents are stored once per hash under
+// objects/ in the history directory, next to an index of revisions
+type ConfigHistory struct {
+	dir       string
+	paths     []string
+	revisions []Revision
+	mutex     sync.Mutex
+}
+
+// NewConfigHistory opens the history kept in dir for paths. A path that is a
+// directory tracks every regular file directly inside it
+func NewConfigHistory(dir string, paths ...string) (*ConfigHistory, error) {
+	history := &ConfigHistory{dir: dir}
+	for _, path := range paths {
+		history.paths = append(history.paths, filepath.Clean(path))
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, historyIndexFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return history, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config history: %w", err)
+	}
+	if err := json.Unmarshal(data, &history.revisions); err != nil {
+		return nil, fmt.Errorf("failed to parse config history: %w", err)
+	}
+	return history, nil
+}
+
+// Snapshot records a revision for every tracked file whose content differs
+// from its latest revision, including files that were removed. It captures
+// edits made outside the gateway and is called after the gateway writes files
+func (h *ConfigHistory) Snapshot(author, message string) ([]Revision, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	files, err := h.trackedFiles()
+	if err != nil {
+		return nil, err
+	}
+	present := make(map[string]bool, len(files))
+
+	var recorded []Revision
+	for _, file := range files {
+		present[file] = true
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return recorded, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		revision, changed, err := h.record(file, content, false, author, message)
+		if err != nil {
+			return recorded, err
+		}
+		if changed {
+			recorded = append(recorded, revision)
+		}
+	}
+
+	latest := h.latest()
+	removed := make([]string, 0, len(latest))
+	for file, revision := range latest {
+		if !present[file] && !revision.Deleted && h.tracks(file) {
+			removed = append(removed, file)
+		}
+	}
+	sort.Strings(removed)
+	for _, file := range removed {
+		revision, _, err := h.record(file, nil, true, author, message)
+		if err != nil {
+			return recorded, err
+		}
+		recorded = append(recorded, revision)
+	}
+	return recorded, nil
+}
+
+// Write replaces a tracked file with content and records the new revision
+func (h *ConfigHistory) Write(file string, content []byte, author, message string) (*Revision, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	file = filepath.Clean(file)
+	if !h.tracks(file) {
+		return nil, fmt.Errorf("%w: %s", ErrUntrackedFile, file)
+	}
+	if err := writeFileAtomic(file, content); err != nil {
+		return nil, err
+	}
+	revision, _, err := h.record(file, content, false, author, message)
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// Files lists the tracked files that exist, sorted
+func (h *ConfigHistory) Files() ([]string, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return h.trackedFiles()
+}
+
+// Tracks reports whether file is tracked, and so may be written with Write
+func (h *ConfigHistory) Tracks(file string) bool {
+	return h.tracks(filepath.Clean(file))
+}
+
+// List returns the revisions of file, newest first. An empty file lists the
+// revisions of every file
+func (h *ConfigHistory) List(file string) []Revision {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if file != "" {
+		file = filepath.Clean(file)
+	}
+	var revisions []Revision
+	for i := len(h.revisions) - 1; i >= 0; i-- {
+		if file == "" || h.revisions[i].File == file {
+			revisions = append(revisions, h.revisions[i])
+		}
+	}
+	return revisions
+}
+
+// Get returns a revision and the content it recorded
+func (h *ConfigHistory) Get(id int) (*Revision, []byte, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	revision, err := h.revision(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := h.content(revision)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &revision, content, nil
+}
+
+// Diff returns a unified diff from revision against to revision id. With
+// against zero the diff is from the previous revision of the same file
+func (h *ConfigHistory) Diff(id, against int) (string, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	revision, err := h.revision(id)
+	if err != nil {
+		return "", err
+	}
+	after, err := h.content(revision)
+	if err != nil {
+		return "", err
+	}
+
+	var before []byte
+	if against != 0 {
+		base, err := h.revision(against)
+		if err != nil {
+			return "", err
+		}
+		if before, err = h.content(base); err != nil {
+			return "", err
+		}
+	} else if previous, ok := h.previous(revision); ok {
+		if before, err = h.content(previous); err != nil {
+			return "", err
+		}
+	}
+	return unifiedDiff(revision.File, before, after), nil
+}
+
+// Rollback restores the content of revision id, removing the file if the
+// revision recorded a deletion, and records the restore as a new revision
+func (h *ConfigHistory) Rollback(id int, author string) (*Revision, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	target, err := h.revision(id)
+	if err != nil {
+		return nil, err
+	}
+	if !h.tracks(target.File) {
+		return nil, fmt.Errorf("%w: %s", ErrUntrackedFile, target.File)
+	}
+	content, err := h.content(target)
+	if err != nil {
+		return nil, err
+	}
+
+	if target.Deleted {
+		if err := os.Remove(target.File); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to remove %s: %w", target.File, err)
+		}
+	} else if err := writeFileAtomic(target.File, content); err != nil {
+		return nil, err
+	}
+
+	revision, _, err := h.record(target.File, content, target.Deleted, author, fmt.Sprintf("rollback to revision %d", id))
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// record appends a revision of file unless it matches the latest one, in
+// which case the latest is returned with changed false
+func (h *ConfigHistory) record(file string, content []byte, deleted bool, author, message string) (Revision, bool, error) {
+	revision := Revision{
+		ID:        len(h.revisions) + 1,
+		File:      file,
+		Deleted:   deleted,
+		Author:    author,
+		Message:   message,
+		CreatedAt: time.Now(),
+	}
+	if !deleted {
+		sum := sha256.Sum256(content)
+		revision.Hash = hex.EncodeToString(sum[:])
+	}
+	if latest, ok := h.latest()[file]; ok && latest.Hash == revision.Hash && latest.Deleted == deleted {
+		return latest, false, nil
+	}
+
+	if !deleted {
+		object := filepath.Join(h.dir, "objects", revision.Hash)
+		if _, err := os.Stat(object); errors.Is(err, os.ErrNotExist) {
+			if err := writeFileAtomic(object, content); err != nil {
+				return Revision{}, false, err
+			}
+		}
+	}
+
+	h.revisions = append(h.revisions, revision)
+	data, err := json.MarshalIndent(h.revisions, "", "  ")
+	if err != nil {
+		return Revision{}, false, err
+	}
+	if err := writeFileAtomic(filepath.Join(h.dir, historyIndexFile), data); err != nil {
+		h.revisions = h.revisions[:len(h.revisions)-1]
+		return Revision{}, false, err
+	}
+	return revision, true, nil
+}
+
+// revision looks up a revision by ID
+func (h *ConfigHistory) revision(id int) (Revision, error) {
+	if id < 1 || id > len(h.revisions) {
+		return Revision{}, fmt.Errorf("%w: %d", ErrRevisionNotFound, id)
+	}
+	return h.revisions[id-1], nil
+}
+
+// previous returns the revision of the same file recorded before revision
+func (h *ConfigHistory) previous(revision Revision) (Revision, bool) {
+	for i := revision.ID - 2; i >= 0; i-- {
+		if h.revisions[i].File == revision.File {
+			return h.revisions[i], true
+		}
+	}
+	return Revision{}, false
+}
+
+// latest returns the newest revision of every file
+func (h *ConfigHistory) latest() map[string]Revision {
+	latest := make(map[string]Revision)
+	for _, revision := range h.revisions {
+		latest[revision.File] = revision
+	}
+	return latest
+}
+
+// content reads the stored content of revision, empty for deletions
+func (h *ConfigHistory) content(revision Revision) ([]byte, error) {
+	if revision.Deleted {
+		return nil, nil
+	}
+	content, err := os.ReadFile(filepath.Join(h.dir, "objects", revision.Hash))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read revision %d: %w", revision.ID, err)
+	}
+	return content, nil
+}
+
+// trackedFiles lists the files that exist under the tracked paths, sorted
+func (h *ConfigHistory) trackedFiles() ([]string, error) {
+	var files []string
+	for _, path := range h.paths {
+		info, err := os.Stat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.Type().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// tracks reports whether file is a tracked path or directly inside one
+func (h *ConfigHistory) tracks(file string) bool {
+	for _, path := range h.paths {
+		if file == path || (filepath.Dir(file) == path && !strings.HasPrefix(filepath.Base(file), ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeFileAtomic writes content through a temporary file so a crash never
+// leaves a partial file
+func writeFileAtomic(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".tmp", content, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
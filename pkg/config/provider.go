@@ -0,0 +1,79 @@
+package config
+
+import (
+	"strings"
+)
+
+// ModelsSource defines how models are retrieved for a provider
+type ModelsSource struct {
+	Type  string      `yaml:"type" json:"type"`   // "list", "endpoint", "script"
+	Value interface{} `yaml:"value" json:"value"` // []string, string URL, or script path
+}
+
+// ParseModelsSource parses the models column of providers.csv: a URL to fetch
+// models from, the path of a script that lists them, or a pipe-delimited list
+func ParseModelsSource(field string) ModelsSource {
+	field = strings.TrimSpace(field)
+	switch {
+	case strings.HasPrefix(field, "http://") || strings.HasPrefix(field, "https://"):
+		return ModelsSource{Type: "endpoint", Value: field}
+	case strings.HasPrefix(field, "/"):
+		return ModelsSource{Type: "script", Value: field}
+	}
+
+	models := []string{}
+	for _, model := range strings.Split(field, "|") {
+		if model = strings.TrimSpace(model); model != "" {
+			models = append(models, model)
+		}
+	}
+	return ModelsSource{Type: "list", Value: models}
+}
+
+// List returns the models of a list source, or nil for endpoints and scripts
+// whose models are discovered later
+func (s ModelsSource) List() []string {
+	if s.Type != "list" {
+		return nil
+	}
+	switch value := s.Value.(type) {
+	case []string:
+		return value
+	case []interface{}:
+		// Lists decoded from YAML or JSON
+		models := make([]string, 0, len(value))
+		for _, model := range value {
+			if name, ok := model.(string); ok {
+				models = append(models, name)
+			}
+		}
+		return models
+	}
+	return nil
+}
+
+// String writes the source back in the form of the providers.csv models column
+func (s ModelsSource) String() string {
+	if s.Type == "list" {
+		return strings.Join(s.List(), "|")
+	}
+	value, _ := s.Value.(string)
+	return value
+}
+
+// CSVProvider converts a provider configuration into a providers.csv row
+func (p ProviderConfig) CSVProvider() CSVProvider {
+	return CSVProvider{
+		Name:                p.Name,
+		Tier:                p.Tier,
+		Endpoint:            p.Endpoint,
+		APIKey:              p.APIKey,
+		ModelsSource:        p.ModelsSource.String(),
+		Capabilities:        p.DeclaredCapabilities,
+		Limits:              p.Limits,
+		Region:              p.Region,
+		Priority:            p.Priority,
+		Description:         p.Description,
+		CapabilityOverrides: p.CapabilityOverrides,
+	}
+}
@@ -0,0 +1,276 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/environment"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/middleware"
+	"gopkg.in/yaml.v3"
+)
+
+// ServerConfig is the server config file. ${VAR} references are expanded
+// from the environment, so secrets and paths can stay out of the file
+type ServerConfig struct {
+	Admin AdminConfig `yaml:"admin"`
+	// CORS lists the browser origins allowed to call the API
+	CORS          []middleware.CORSPolicy `yaml:"cors,omitempty"`
+	BrowserTokens BrowserTokenConfig      `yaml:"browser_tokens,omitempty"`
+	// Environments are the routing profiles of API keys by the environment
+	// the keys file assigns them, e.g. dev or prod
+	Environments map[string]environment.Profile `yaml:"environments,omitempty"`
+	Selection    SelectionConfig                `yaml:"selection,omitempty"`
+}
+
+// SelectionConfig tunes provider selection
+type SelectionConfig struct {
+	// Weights of the parts of provider scores, scaled to sum to 1; unset
+	// keeps the defaults
+	Weights *WeightsConfig `yaml:"weights,omitempty"`
+}
+
+// WeightsConfig weighs cost, quality, latency and reliability in provider
+// scores
+type WeightsConfig struct {
+	Cost        float64 `yaml:"cost"`
+	Quality     float64 `yaml:"quality"`
+	Latency     float64 `yaml:"latency"`
+	Reliability float64 `yaml:"reliability"`
+}
+
+// BrowserTokenConfig enables short-lived tokens that browser apps use instead
+// of API keys
+type BrowserTokenConfig struct {
+	// Secret signs the tokens and must be shared by all replicas; empty
+	// disables browser tokens
+	Secret string `yaml:"secret,omitempty"`
+	// TTL is the longest lifetime of a token, 15m by default
+	TTL time.Duration `yaml:"ttl,omitempty"`
+	// Paths are the endpoints tokens may call, /api/v1/process and
+	// /v1/chat/completions by default
+	Paths []string `yaml:"paths,omitempty"`
+}
+
+// AdminConfig controls where the /admin routes are served and who may reach
+// them
+type AdminConfig struct {
+	// Listen serves the admin routes on their own address, e.g.
+	// "127.0.0.1:9090", and removes them from the public listener; empty
+	// keeps them on the public listener
+	Listen string `yaml:"listen,omitempty"`
+	// AllowedIPs lists the addresses and CIDR ranges admin requests may come
+	// from; empty allows every address
+	AllowedIPs []string `yaml:"allowed_ips,omitempty"`
+	// TLS serves the admin listener over HTTPS; it needs Listen
+	TLS *TLSConfig `yaml:"tls,omitempty"`
+	// Keys grants roles, viewer or admin, to API keys by key ID. Admin
+	// requests need a role, so without keys or OIDC they are all refused
+	Keys map[string]string `yaml:"keys,omitempty"`
+	// OIDC signs operators in through an identity provider
+	OIDC *OIDCConfig `yaml:"oidc,omitempty"`
+	// Insecure leaves the admin routes open to every caller when neither
+	// keys nor OIDC are configured. It is for local development only
+	Insecure bool `yaml:"insecure,omitempty"`
+}
+
+// OIDCConfig is the identity provider operators sign in with and how its
+// groups map to roles
+type OIDCConfig struct {
+	Issuer       string `yaml:"issuer"`
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	// RedirectURL is the gateway's callback as registered with the
+	// provider, e.g. https://gateway.example.com/admin/sso/callback
+	RedirectURL string   `yaml:"redirect_url"`
+	Scopes      []string `yaml:"scopes,omitempty"`
+	// GroupsClaim names the ID token claim listing groups, "groups" by default
+	GroupsClaim string `yaml:"groups_claim,omitempty"`
+	// SessionSecret signs session cookies and must be shared by all replicas
+	SessionSecret string `yaml:"session_secret"`
+	// SessionTTL is how long a sign-in lasts, 8h by default
+	SessionTTL time.Duration `yaml:"session_ttl,omitempty"`
+	// Roles maps each role to the groups and email addresses granted it
+	Roles map[string][]string `yaml:"roles"`
+}
+
+// TLSConfig is the certificate of a listener and, for mutual TLS, the CAs
+// its clients' certificates must be signed by
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// ClientCAFile enables mutual TLS: clients without a certificate signed
+	// by one of these CAs are refused during the handshake
+	ClientCAFile string `yaml:"client_ca_file,omitempty"`
+}
+
+// LoadServerConfig reads and validates a server config file
+func LoadServerConfig(path string) (*ServerConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read server config: %w", err)
+	}
+	return ParseServerConfig(data, path)
+}
+
+// ParseServerConfig parses and validates server config YAML; source names it
+// in errors
+func ParseServerConfig(data []byte, source string) (*ServerConfig, error) {
+	expanded, err := ExpandEnv(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("server config %s: %w", source, err)
+	}
+
+	var cfg ServerConfig
+	if err := yaml.Unmarshal([]byte(expanded), &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse server config %s: %w", source, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("server config %s: %w", source, err)
+	}
+	return &cfg, nil
+}
+
+// Validate checks the admin settings, that browser tokens have origins and
+// that selection weights are usable
+func (c *ServerConfig) Validate() error {
+	if err := c.Admin.Validate(); err != nil {
+		return err
+	}
+	if c.BrowserTokens.Secret != "" && len(c.CORS) == 0 {
+		return fmt.Errorf("browser_tokens needs cors origins to issue tokens for")
+	}
+	if w := c.Selection.Weights; w != nil {
+		if w.Cost < 0 || w.Quality < 0 || w.Latency < 0 || w.Reliability < 0 {
+			return fmt.Errorf("selection.weights must not be negative")
+		}
+		if w.Cost+w.Quality+w.Latency+w.Reliability == 0 {
+			return fmt.Errorf("selection.weights needs a positive weight")
+		}
+	}
+	return nil
+}
+
+// ApplyEnv overrides settings with the environment variables lookup finds, so
+// deployments such as Helm charts can set them without a file:
+// ADMIN_LISTEN_ADDR, ADMIN_ALLOWED_IPS, ADMIN_TLS_CERT_FILE,
+// ADMIN_TLS_KEY_FILE, ADMIN_TLS_CLIENT_CA_FILE, ADMIN_KEYS (keyID=role pairs),
+// ADMIN_INSECURE, CORS_ORIGINS, BROWSER_TOKEN_SECRET and BROWSER_TOKEN_TTL.
+// Lists are comma separated. Callers validate the result
+func (c *ServerConfig) ApplyEnv(lookup func(string) (string, bool)) error {
+	if value, ok := lookup("ADMIN_LISTEN_ADDR"); ok {
+		c.Admin.Listen = value
+	}
+	if value, ok := lookup("ADMIN_ALLOWED_IPS"); ok {
+		c.Admin.AllowedIPs = splitList(value)
+	}
+	for name, field := range map[string]func(*TLSConfig) *string{
+		"ADMIN_TLS_CERT_FILE":      func(t *TLSConfig) *string { return &t.CertFile },
+		"ADMIN_TLS_KEY_FILE":       func(t *TLSConfig) *string { return &t.KeyFile },
+		"ADMIN_TLS_CLIENT_CA_FILE": func(t *TLSConfig) *string { return &t.ClientCAFile },
+	} {
+		if value, ok := lookup(name); ok && value != "" {
+			if c.Admin.TLS == nil {
+				c.Admin.TLS = &TLSConfig{}
+			}
+			*field(c.Admin.TLS) = value
+		}
+	}
+	if value, ok := lookup("ADMIN_KEYS"); ok {
+		keys := make(map[string]string)
+		for _, pair := range splitList(value) {
+			keyID, role, found := strings.Cut(pair, "=")
+			if !found || keyID == "" || role == "" {
+				return fmt.Errorf("ADMIN_KEYS entry %q must be keyID=role", pair)
+			}
+			keys[strings.TrimSpace(keyID)] = strings.TrimSpace(role)
+		}
+		c.Admin.Keys = keys
+	}
+	if value, ok := lookup("ADMIN_INSECURE"); ok {
+		c.Admin.Insecure = value == "true"
+	}
+	if value, ok := lookup("CORS_ORIGINS"); ok {
+		c.CORS = nil
+		if origins := splitList(value); len(origins) > 0 {
+			c.CORS = []middleware.CORSPolicy{{Origins: origins}}
+		}
+	}
+	if value, ok := lookup("BROWSER_TOKEN_SECRET"); ok {
+		c.BrowserTokens.Secret = value
+	}
+	if value, ok := lookup("BROWSER_TOKEN_TTL"); ok && value != "" {
+		ttl, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid BROWSER_TOKEN_TTL: %w", err)
+		}
+		c.BrowserTokens.TTL = ttl
+	}
+	return nil
+}
+
+// splitList splits a comma separated list, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Validate checks that the TLS and OIDC settings are complete and usable
+func (c *AdminConfig) Validate() error {
+	if c.Insecure && (len(c.Keys) > 0 || c.OIDC != nil) {
+		return fmt.Errorf("admin.insecure cannot be combined with admin.keys or admin.oidc")
+	}
+	if c.OIDC != nil {
+		if c.OIDC.Issuer == "" || c.OIDC.ClientID == "" || c.OIDC.RedirectURL == "" || c.OIDC.SessionSecret == "" {
+			return fmt.Errorf("admin.oidc needs issuer, client_id, redirect_url and session_secret")
+		}
+		if len(c.OIDC.Roles) == 0 {
+			return fmt.Errorf("admin.oidc needs roles mapping groups to viewer or admin")
+		}
+	}
+	if c.TLS == nil {
+		return nil
+	}
+	if c.Listen == "" {
+		return fmt.Errorf("admin.tls needs admin.listen; the public listener does not serve TLS")
+	}
+	if c.TLS.CertFile == "" || c.TLS.KeyFile == "" {
+		return fmt.Errorf("admin.tls needs cert_file and key_file")
+	}
+	return nil
+}
+
+// ServerTLS loads the certificate and client CAs into a TLS configuration
+func (t *TLSConfig) ServerTLS() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if t.ClientCAFile == "" {
+		return tlsConfig, nil
+	}
+
+	pem, err := os.ReadFile(t.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CAs: %w", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", t.ClientCAFile)
+	}
+	tlsConfig.ClientCAs = clientCAs
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	return tlsConfig, nil
+}
@@ -1,27 +1,50 @@
 package config
 
-import "time"
+import (
+	"time"
 
-// CSVProvider represents a provider loaded from CSV
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
+)
+
+// CSVProvider represents one row of providers.csv in the canonical schema
 type CSVProvider struct {
-	Name         string `csv:"name"`
-	Tier         string `csv:"tier"`
-	Endpoint     string `csv:"endpoint"`
-	ModelsSource string `csv:"models_source"`
-	APIKey       string `csv:"api_key"`
+	Name                string               `csv:"name"`
+	Tier                tier.Tier            `csv:"tier"`
+	Endpoint            string               `csv:"endpoint"`
+	APIKey              string               `csv:"auth"`
+	ModelsSource        string               `csv:"models"`
+	Capabilities        []string             `csv:"capabilities"`
+	Limits              map[string]int       `csv:"limits"`
+	Region              string               `csv:"region"`
+	Priority            int                  `csv:"priority"`
+	Description         string               `csv:"description"`
+	CapabilityOverrides *CapabilityOverrides `csv:"-"`
 }
 
-// ProviderConfig represents enhanced provider configuration
+// ProviderConfig is the canonical provider model. CSV rows, YAML and JSON
+// provider documents and the runtime providers of each module convert to and
+// from it rather than defining their own
 type ProviderConfig struct {
 	ID           string       `yaml:"id"`
 	Name         string       `yaml:"name"`
-	Tier         string       `yaml:"tier"`
+	Tier         tier.Tier    `yaml:"tier"`
 	Endpoint     string       `yaml:"endpoint"`
+	URL          string       `yaml:"url,omitempty"`
 	APIKey       string       `yaml:"api_key"`
 	Priority     int          `yaml:"priority"`
+	Type         string       `yaml:"type,omitempty"`
+	Enabled      bool         `yaml:"enabled,omitempty"`
+	Description  string       `yaml:"description,omitempty"`
+	ModelsSource ModelsSource `yaml:"models_source,omitempty"`
+	Models       []string     `yaml:"models,omitempty"`
+	Headers      map[string]string `yaml:"headers,omitempty"`
 	Capabilities Capabilities `yaml:"capabilities"`
 	CostTracking CostTracking `yaml:"cost_tracking"`
 	Metadata     map[string]string `yaml:"metadata"`
+	CapabilityOverrides *CapabilityOverrides `yaml:"capability_overrides,omitempty"`
+	Region       string         `yaml:"region,omitempty"`
+	Limits       map[string]int `yaml:"limits,omitempty"`
+	DeclaredCapabilities []string `yaml:"declared_capabilities,omitempty"`
 }
 
 // Capabilities represents provider capabilities
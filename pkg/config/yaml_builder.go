@@ -1,12 +1,12 @@
 package config
 
 import (
-	"encoding/csv"
 	"fmt"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
 	"gopkg.in/yaml.v3"
 )
 
@@ -86,45 +86,17 @@ func (y *YAMLBuilder) AddMetadata(metadata map[string]string) *YAMLBuilder {
 	return y
 }
 
-// ReadCSV reads CSV providers from file
+// ReadCSV reads CSV providers from file in either schema version
 func (y *YAMLBuilder) ReadCSV() ([]CSVProvider, error) {
 	if y.csvPath == "" {
 		return nil, fmt.Errorf("CSV path not set")
 	}
 
-	file, err := os.Open(y.csvPath)
+	parsed, err := ReadProviderCSVFile(y.csvPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open CSV file: %w", err)
+		return nil, err
 	}
-	defer file.Close()
-
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
-	if err != nil {
-		return nil, fmt.Errorf("failed to read CSV: %w", err)
-	}
-
-	if len(records) == 0 {
-		return nil, fmt.Errorf("empty CSV file")
-	}
-
-	// Skip header row
-	var providers []CSVProvider
-	for i := 1; i < len(records); i++ {
-		record := records[i]
-		if len(record) >= 5 {
-			provider := CSVProvider{
-				Name:         record[0],
-				Tier:         record[1],
-				Endpoint:     record[2],
-				ModelsSource: record[3],
-				APIKey:       record[4],
-			}
-			providers = append(providers, provider)
-		}
-	}
-
-	return providers, nil
+	return parsed.Providers, nil
 }
 
 // BuildFromCSV builds YAML configurations from CSV data
@@ -136,48 +108,40 @@ func (y *YAMLBuilder) BuildFromCSV() error {
 
 	// Create basic configurations from CSV data
 	for _, provider := range providers {
-		config := ProviderConfig{
-			ID:       strings.ToLower(strings.ReplaceAll(provider.Name, " ", "_")),
-			Name:     provider.Name,
-			Tier:     provider.Tier,
-			Endpoint: provider.Endpoint,
-			Capabilities: Capabilities{
-				Reasoning:    7, // Default values
-				Knowledge:    7,
-				Computation:  6,
-				Coordination: 5,
-			},
-			CostTracking: CostTracking{
-				CostPerToken:   0.00001, // Default cost
-				CostPerRequest: 0.001,
-				LastUpdated:    time.Now(),
-			},
-			Metadata: map[string]string{
-				"source": "csv",
-				"tier":   provider.Tier,
-			},
+		if _, err := tier.Parse(string(provider.Tier)); err != nil {
+			return fmt.Errorf("provider %s: %w", provider.Name, err)
+		}
+
+		config := provider.ProviderConfig()
+		// Credentials stay in the CSV rather than in generated files
+		config.APIKey = ""
+		config.Capabilities = Capabilities{
+			Reasoning:    7, // Default values
+			Knowledge:    7,
+			Computation:  6,
+			Coordination: 5,
 		}
+		config.CostTracking = DefaultCostTracking(provider.Tier)
+		config.CostTracking.LastUpdated = time.Now()
+		config.Metadata["tier"] = provider.Tier.String()
 
 		// Adjust capabilities based on tier
 		switch provider.Tier {
-		case "official":
+		case tier.Official:
 			config.Capabilities.Reasoning = 9
 			config.Capabilities.Knowledge = 9
 			config.Capabilities.Computation = 8
 			config.Capabilities.Coordination = 7
-			config.CostTracking.CostPerToken = 0.00005
-		case "community":
+		case tier.Community:
 			config.Capabilities.Reasoning = 7
 			config.Capabilities.Knowledge = 7
 			config.Capabilities.Computation = 6
 			config.Capabilities.Coordination = 5
-			config.CostTracking.CostPerToken = 0.00002
-		case "unofficial":
+		case tier.Unofficial:
 			config.Capabilities.Reasoning = 5
 			config.Capabilities.Knowledge = 6
 			config.Capabilities.Computation = 5
 			config.Capabilities.Coordination = 4
-			config.CostTracking.CostPerToken = 0.00001
 		}
 
 		// Save to YAML file if configDir is set
@@ -188,6 +152,17 @@ func (y *YAMLBuilder) BuildFromCSV() error {
 			}
 
 			filename := fmt.Sprintf("%s/%s.yaml", y.configDir, config.ID)
+
+			// Hand-written capability overrides survive regeneration unless the CSV sets its own
+			if existing, err := os.ReadFile(filename); err == nil {
+				var previous ProviderConfig
+				if yaml.Unmarshal(existing, &previous) == nil && config.CapabilityOverrides.IsEmpty() && !previous.CapabilityOverrides.IsEmpty() {
+					config.CapabilityOverrides = previous.CapabilityOverrides
+					if yamlData, err = yaml.Marshal(config); err != nil {
+						continue
+					}
+				}
+			}
 			os.MkdirAll(y.configDir, 0755)
 			os.WriteFile(filename, yamlData, 0644)
 		}
@@ -196,6 +171,22 @@ func (y *YAMLBuilder) BuildFromCSV() error {
 	return nil
 }
 
+// DefaultCostTracking returns the cost assumed for a provider of the given tier
+// until real pricing is known
+func DefaultCostTracking(providerTier tier.Tier) CostTracking {
+	costs := CostTracking{
+		CostPerToken:   0.00001,
+		CostPerRequest: 0.001,
+	}
+	switch providerTier {
+	case tier.Official:
+		costs.CostPerToken = 0.00005
+	case tier.Community:
+		costs.CostPerToken = 0.00002
+	}
+	return costs
+}
+
 // SetCSVPath sets the CSV file path
 func (y *YAMLBuilder) SetCSVPath(path string) {
 	y.csvPath = path
@@ -0,0 +1,458 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlSyncStateFile records what was last generated for each file in the directory
+const yamlSyncStateFile = ".generated.json"
+
+// YAMLChangeStatus says what syncing would do to a file
+type YAMLChangeStatus string
+
+const (
+	// YAMLUnchanged files are left alone: the generator output is the same as last time
+	YAMLUnchanged YAMLChangeStatus = "unchanged"
+	// YAMLCreated files do not exist yet
+	YAMLCreated YAMLChangeStatus = "created"
+	// YAMLUpdated files receive new generated values merged with manual edits
+	YAMLUpdated YAMLChangeStatus = "updated"
+)
+
+// YAMLChange describes the effect of syncing one generated file
+type YAMLChange struct {
+	File   string           `json:"file"`
+	Status YAMLChangeStatus `json:"status"`
+	// Hash is the SHA-256 of the generated content
+	Hash string `json:"hash"`
+	// Diff is a unified diff from the file on disk to what would be written
+	Diff string `json:"diff,omitempty"`
+	// Preserved lists fields whose manual edits were kept
+	Preserved []string `json:"preserved,omitempty"`
+	// Overwritten lists manually edited fields the generator changed as well;
+	// the generated value wins
+	Overwritten []string `json:"overwritten,omitempty"`
+
+	content   []byte
+	generated []byte
+}
+
+// yamlSyncEntry is the state kept for one file
+type yamlSyncEntry struct {
+	Hash      string `json:"hash"`
+	Generated string `json:"generated"`
+}
+
+// YAMLSync writes generated YAML files into a directory without clobbering
+// manual edits. Fields the generator emits are managed: they are merged three
+// ways between the last generated content, the file on disk and the new
+// content, so a field edited by hand keeps its value until the generator
+// changes it. Fields the generator never emits are left alone
+type YAMLSync struct {
+	dir   string
+	mutex sync.Mutex
+}
+
+// NewYAMLSync creates a syncer for dir
+func NewYAMLSync(dir string) *YAMLSync {
+	return &YAMLSync{dir: dir}
+}
+
+// Dir returns the directory files are written to
+func (s *YAMLSync) Dir() string {
+	return s.dir
+}
+
+// Plan reports what Apply would do with generated, keyed by file name,
+// without writing anything
+func (s *YAMLSync) Plan(generated map[string][]byte) ([]YAMLChange, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	state, err := s.loadState()
+	if err != nil {
+		return nil, err
+	}
+	return s.plan(generated, state)
+}
+
+// Apply writes every file whose generated content changed and records the new
+// content as the base for future merges
+func (s *YAMLSync) Apply(generated map[string][]byte) ([]YAMLChange, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	state, err := s.loadState()
+	if err != nil {
+		return nil, err
+	}
+	changes, err := s.plan(generated, state)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", s.dir, err)
+	}
+	for _, change := range changes {
+		if change.Status != YAMLUnchanged {
+			if err := os.WriteFile(filepath.Join(s.dir, change.File), change.content, 0644); err != nil {
+				return nil, fmt.Errorf("failed to write %s: %w", change.File, err)
+			}
+		}
+		if state[change.File].Hash != change.Hash {
+			state[change.File] = yamlSyncEntry{Hash: change.Hash, Generated: string(change.generated)}
+		}
+	}
+	return changes, s.saveState(state)
+}
+
+// plan computes the change for every generated file, sorted by file name
+func (s *YAMLSync) plan(generated map[string][]byte, state map[string]yamlSyncEntry) ([]YAMLChange, error) {
+	files := make([]string, 0, len(generated))
+	for file := range generated {
+		if file != filepath.Base(file) || file == yamlSyncStateFile {
+			return nil, fmt.Errorf("invalid generated file name %q", file)
+		}
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	changes := make([]YAMLChange, 0, len(files))
+	for _, file := range files {
+		content := generated[file]
+		sum := sha256.Sum256(content)
+		change := YAMLChange{File: file, Hash: hex.EncodeToString(sum[:]), generated: content}
+
+		existing, err := os.ReadFile(filepath.Join(s.dir, file))
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			change.Status = YAMLCreated
+			change.content = content
+			change.Diff = unifiedDiff(file, nil, content)
+			changes = append(changes, change)
+			continue
+		case err != nil:
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+
+		entry, known := state[file]
+		if known && entry.Hash == change.Hash {
+			change.Status = YAMLUnchanged
+			changes = append(changes, change)
+			continue
+		}
+
+		var base []byte
+		if known {
+			base = []byte(entry.Generated)
+		}
+		merged, err := mergeManagedYAML(base, existing, content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to merge %s: %w", file, err)
+		}
+		change.content = merged.content
+		change.Preserved = merged.preserved
+		change.Overwritten = merged.overwritten
+		change.Diff = unifiedDiff(file, existing, merged.content)
+		change.Status = YAMLUpdated
+		if change.Diff == "" {
+			// Manual edits already cover everything the generator changed
+			change.Status = YAMLUnchanged
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// loadState reads the per-file generation state, empty if none was saved
+func (s *YAMLSync) loadState() (map[string]yamlSyncEntry, error) {
+	state := make(map[string]yamlSyncEntry)
+	data, err := os.ReadFile(filepath.Join(s.dir, yamlSyncStateFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read generation state: %w", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse generation state: %w", err)
+	}
+	return state, nil
+}
+
+// saveState writes the per-file generation state
+func (s *YAMLSync) saveState(state map[string]yamlSyncEntry) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.dir, yamlSyncStateFile), data, 0644)
+}
+
+// mergeResult is a merged document and the fields that needed a decision
+type mergeResult struct {
+	content     []byte
+	preserved   []string
+	overwritten []string
+}
+
+// mergeManagedYAML merges generated into the document on disk, using base,
+// the previously generated content, to tell manual edits from stale values.
+// Without a base every managed field takes the generated value
+func mergeManagedYAML(base, disk, generated []byte) (*mergeResult, error) {
+	var baseDoc, diskDoc, genDoc yaml.Node
+	if len(base) > 0 {
+		if err := yaml.Unmarshal(base, &baseDoc); err != nil {
+			return nil, fmt.Errorf("previous content: %w", err)
+		}
+	}
+	if err := yaml.Unmarshal(disk, &diskDoc); err != nil {
+		return nil, fmt.Errorf("file on disk: %w", err)
+	}
+	if err := yaml.Unmarshal(generated, &genDoc); err != nil {
+		return nil, fmt.Errorf("generated content: %w", err)
+	}
+
+	baseRoot, diskRoot, genRoot := documentRoot(&baseDoc), documentRoot(&diskDoc), documentRoot(&genDoc)
+	if diskRoot == nil || diskRoot.Kind != yaml.MappingNode || genRoot == nil || genRoot.Kind != yaml.MappingNode {
+		// Not something fields can be merged in, so the generated file replaces it
+		return &mergeResult{content: generated, overwritten: []string{"."}}, nil
+	}
+
+	result := &mergeResult{}
+	mergeMapping(baseRoot, diskRoot, genRoot, "", result)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&diskDoc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	result.content = buf.Bytes()
+	return result, nil
+}
+
+// mergeMapping updates disk in place with the managed keys of generated
+func mergeMapping(base, disk, generated *yaml.Node, path string, result *mergeResult) {
+	if base != nil && base.Kind != yaml.MappingNode {
+		base = nil
+	}
+
+	for i := 0; i+1 < len(generated.Content); i += 2 {
+		key, genValue := generated.Content[i].Value, generated.Content[i+1]
+		field := joinYAMLPath(path, key)
+		baseValue := mappingValue(base, key)
+		diskIndex := mappingIndex(disk, key)
+
+		if diskIndex < 0 {
+			if baseValue != nil && yamlEqual(baseValue, genValue) {
+				// Removed by hand and unchanged by the generator
+				result.preserved = append(result.preserved, field)
+				continue
+			}
+			disk.Content = append(disk.Content, generated.Content[i], genValue)
+			continue
+		}
+
+		diskValue := disk.Content[diskIndex+1]
+		switch {
+		case yamlEqual(diskValue, genValue):
+		case baseValue != nil && yamlEqual(diskValue, baseValue):
+			disk.Content[diskIndex+1] = genValue
+		case baseValue != nil && yamlEqual(genValue, baseValue):
+			result.preserved = append(result.preserved, field)
+		case diskValue.Kind == yaml.MappingNode && genValue.Kind == yaml.MappingNode:
+			mergeMapping(baseValue, diskValue, genValue, field, result)
+		default:
+			disk.Content[diskIndex+1] = genValue
+			result.overwritten = append(result.overwritten, field)
+		}
+	}
+
+	// Fields the generator stopped emitting go away unless they were edited
+	if base == nil {
+		return
+	}
+	for i := 0; i+1 < len(base.Content); i += 2 {
+		key := base.Content[i].Value
+		if mappingValue(generated, key) != nil {
+			continue
+		}
+		if diskIndex := mappingIndex(disk, key); diskIndex >= 0 && yamlEqual(disk.Content[diskIndex+1], base.Content[i+1]) {
+			disk.Content = append(disk.Content[:diskIndex], disk.Content[diskIndex+2:]...)
+		}
+	}
+}
+
+// documentRoot returns the top-level node of a parsed document
+func documentRoot(doc *yaml.Node) *yaml.Node {
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		return doc.Content[0]
+	}
+	return nil
+}
+
+// mappingIndex returns the index of key's key node in mapping, or -1
+func mappingIndex(mapping *yaml.Node, key string) int {
+	if mapping == nil {
+		return -1
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// mappingValue returns the value of key in mapping, or nil
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	if i := mappingIndex(mapping, key); i >= 0 {
+		return mapping.Content[i+1]
+	}
+	return nil
+}
+
+// yamlEqual compares two nodes by value, ignoring comments and style
+func yamlEqual(a, b *yaml.Node) bool {
+	var av, bv interface{}
+	if a.Decode(&av) != nil || b.Decode(&bv) != nil {
+		return false
+	}
+	return reflect.DeepEqual(av, bv)
+}
+
+// joinYAMLPath builds a dotted field path
+func joinYAMLPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// unifiedDiff returns a unified diff with three lines of context, or "" when
+// the contents are equal
+func unifiedDiff(file string, before, after []byte) string {
+	if bytes.Equal(before, after) {
+		return ""
+	}
+	a, b := splitLines(before), splitLines(after)
+
+	// Longest common subsequence table, filled from the end
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	type diffLine struct {
+		op   byte
+		text string
+		a, b int
+	}
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i], i, j})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', a[i], i, j})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j], i, j})
+			j++
+		}
+	}
+
+	const context = 3
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- a/%s\n+++ b/%s\n", file, file)
+	for start := 0; start < len(lines); {
+		if lines[start].op == ' ' {
+			start++
+			continue
+		}
+		// Extend the hunk while changes are within two contexts of each other
+		from := start - context
+		if from < 0 {
+			from = 0
+		}
+		end := start
+		for k := start; k < len(lines); k++ {
+			if lines[k].op != ' ' {
+				end = k
+			} else if k-end > 2*context {
+				break
+			}
+		}
+		to := end + context + 1
+		if to > len(lines) {
+			to = len(lines)
+		}
+
+		var aCount, bCount int
+		for _, line := range lines[from:to] {
+			if line.op != '+' {
+				aCount++
+			}
+			if line.op != '-' {
+				bCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(lines[from].a, aCount), hunkRange(lines[from].b, bCount))
+		for _, line := range lines[from:to] {
+			out.WriteByte(line.op)
+			out.WriteString(line.text)
+			out.WriteByte('\n')
+		}
+		start = to
+	}
+	return out.String()
+}
+
+// hunkRange formats the start,count of a hunk side
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// splitLines splits content into lines without their terminators
+func splitLines(content []byte) []string {
+	text := strings.TrimSuffix(string(content), "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
@@ -0,0 +1,547 @@
+// Package contract checks the gateway's OpenAI-compatible endpoints against
+// captures of the traffic of the official OpenAI SDKs, so a change that would
+// break the SDKs at the wire level fails CI. Each capture is a request as an
+// SDK sends it and the shape of the response the SDK parses
+package contract
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Environment variables of the live check
+const (
+	// EnvURL is the base URL of a running gateway to check, such as
+	// http://localhost:8080; the live check is skipped when it is unset
+	EnvURL = "OPENAI_CONTRACT_URL"
+	// EnvAPIKey replaces the API key of the captures in the live check
+	EnvAPIKey = "OPENAI_CONTRACT_API_KEY"
+)
+
+// Shape is the kind of response body an SDK parses
+type Shape string
+
+const (
+	// ShapeChatCompletion is a chat.completion object
+	ShapeChatCompletion Shape = "chat.completion"
+	// ShapeChatCompletionStream is a server-sent stream of
+	// chat.completion.chunk objects ending with [DONE]
+	ShapeChatCompletionStream Shape = "chat.completion.stream"
+	// ShapeModelList is the list of GET /v1/models
+	ShapeModelList Shape = "model.list"
+	// ShapeModel is one model
+	ShapeModel Shape = "model"
+	// ShapeEmbeddingList is the list of POST /v1/embeddings
+	ShapeEmbeddingList Shape = "embedding.list"
+	// ShapeError is an error response, which the SDKs turn into exceptions
+	ShapeError Shape = "error"
+)
+
+// finishReasons are the finish reasons the SDKs know
+var finishReasons = map[string]bool{"stop": true, "length": true, "tool_calls": true, "content_filter": true, "function_call": true}
+
+// Request is a request as an SDK sent it
+type Request struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers"`
+	// Body is sent as is, so it may be malformed on purpose
+	Body string `json:"body,omitempty"`
+}
+
+// Expect is what the SDK needs from the response
+type Expect struct {
+	Status int   `json:"status"`
+	Shape  Shape `json:"shape"`
+	// Param and Code, when set, are the error's param and code
+	Param string `json:"param,omitempty"`
+	Code  string `json:"code,omitempty"`
+}
+
+// Reference is the response the OpenAI API itself gave to the request. It
+// keeps the shape checks honest: every reference must pass them
+type Reference struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        string `json:"body"`
+}
+
+// Capture is one SDK call
+type Capture struct {
+	Name string `json:"name"`
+	// SDK names the SDK and version whose traffic was captured
+	SDK       string    `json:"sdk"`
+	Request   Request   `json:"request"`
+	Expect    Expect    `json:"expect"`
+	Reference Reference `json:"reference"`
+	// Skip, when set, is why the gateway is not checked against the capture
+	Skip string `json:"skip,omitempty"`
+	// Note explains an expectation that differs from OpenAI's answer to the
+	// request
+	Note string `json:"note,omitempty"`
+}
+
+//go:embed captures/*.json
+var captureFiles embed.FS
+
+// Captures returns the embedded captures sorted by name
+func Captures() ([]Capture, error) {
+	entries, err := captureFiles.ReadDir("captures")
+	if err != nil {
+		return nil, err
+	}
+	captures := make([]Capture, 0, len(entries))
+	for _, entry := range entries {
+		data, err := captureFiles.ReadFile(path.Join("captures", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var capture Capture
+		if err := json.Unmarshal(data, &capture); err != nil {
+			return nil, fmt.Errorf("failed to parse capture %s: %w", entry.Name(), err)
+		}
+		captures = append(captures, capture)
+	}
+	sort.Slice(captures, func(i, j int) bool {
+		return captures[i].Name < captures[j].Name
+	})
+	return captures, nil
+}
+
+// Check replays every capture against the gateway at baseURL and fails t for
+// each response an SDK could not parse the way it parses OpenAI's. apiKey,
+// when set, replaces the key of the captures
+func Check(t *testing.T, baseURL, apiKey string) {
+	t.Helper()
+	captures, err := Captures()
+	if err != nil {
+		t.Fatalf("failed to load captures: %v", err)
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	for _, capture := range captures {
+		t.Run(capture.Name, func(t *testing.T) {
+			if capture.Skip != "" {
+				t.Skip(capture.Skip)
+			}
+			status, contentType, body, err := replay(client, baseURL, apiKey, capture.Request)
+			if err != nil {
+				t.Fatalf("%s %s: %v", capture.Request.Method, capture.Request.Path, err)
+			}
+			if status != capture.Expect.Status {
+				t.Errorf("status %d, want %d: %s", status, capture.Expect.Status, body)
+			}
+			for _, violation := range capture.Expect.Validate(contentType, body) {
+				t.Error(violation)
+			}
+		})
+	}
+}
+
+// replay sends a captured request with the SDK's headers
+func replay(client *http.Client, baseURL, apiKey string, captured Request) (int, string, []byte, error) {
+	var body io.Reader
+	if captured.Body != "" {
+		body = strings.NewReader(captured.Body)
+	}
+	req, err := http.NewRequest(captured.Method, strings.TrimRight(baseURL, "/")+captured.Path, body)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	for name, value := range captured.Headers {
+		req.Header.Set(name, value)
+	}
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	return resp.StatusCode, resp.Header.Get("Content-Type"), data, nil
+}
+
+// Validate lists how a response body differs from the expected shape, param
+// and code; none when an SDK parses it like OpenAI's
+func (e Expect) Validate(contentType string, body []byte) []string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if e.Shape == ShapeChatCompletionStream {
+		if mediaType != "text/event-stream" {
+			return []string{fmt.Sprintf("content type %q, want text/event-stream", contentType)}
+		}
+		return validateStream(body)
+	}
+	if mediaType != "application/json" {
+		return []string{fmt.Sprintf("content type %q, want application/json", contentType)}
+	}
+
+	var object map[string]any
+	if err := json.Unmarshal(body, &object); err != nil {
+		return []string{fmt.Sprintf("body is not a JSON object: %v", err)}
+	}
+	v := &validator{}
+	switch e.Shape {
+	case ShapeChatCompletion:
+		v.chatCompletion(object)
+	case ShapeModelList:
+		v.equals(object, "object", "list")
+		for i, model := range v.objects(object, "data") {
+			v.at(fmt.Sprintf("data[%d]", i)).model(model)
+		}
+	case ShapeModel:
+		v.model(object)
+	case ShapeEmbeddingList:
+		v.embeddingList(object)
+	case ShapeError:
+		v.error(object, e.Param, e.Code)
+	default:
+		v.failf("unknown shape %q", e.Shape)
+	}
+	return v.violations
+}
+
+// validateStream checks each event of a streamed completion is a chunk and
+// the stream ends with [DONE]
+func validateStream(body []byte) []string {
+	v := &validator{}
+	done := false
+	chunks := 0
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, ":") {
+			continue
+		}
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			v.failf("line %q is not a data event", line)
+			continue
+		}
+		if done {
+			v.failf("event after [DONE]: %s", data)
+			continue
+		}
+		if data == "[DONE]" {
+			done = true
+			continue
+		}
+		var chunk map[string]any
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			v.failf("event is not a JSON object: %s", data)
+			continue
+		}
+		v.at(fmt.Sprintf("chunk[%d]", chunks)).chunk(chunk)
+		chunks++
+	}
+	if chunks == 0 {
+		v.failf("stream has no chunks")
+	}
+	if !done {
+		v.failf("stream does not end with data: [DONE]")
+	}
+	return v.violations
+}
+
+// validator collects violations under a path prefix
+type validator struct {
+	prefix     string
+	violations []string
+	parent     *validator
+}
+
+// at returns a validator for a nested object, reporting to v
+func (v *validator) at(path string) *validator {
+	return &validator{prefix: v.path(path), parent: v}
+}
+
+func (v *validator) path(key string) string {
+	if v.prefix == "" {
+		return key
+	}
+	return v.prefix + "." + key
+}
+
+func (v *validator) failf(format string, args ...any) {
+	root := v
+	for root.parent != nil {
+		root = root.parent
+	}
+	message := fmt.Sprintf(format, args...)
+	if v.prefix != "" {
+		message = v.prefix + ": " + message
+	}
+	root.violations = append(root.violations, message)
+}
+
+// field returns a required field
+func (v *validator) field(object map[string]any, key string) (any, bool) {
+	value, ok := object[key]
+	if !ok {
+		v.failf("%s is missing", key)
+	}
+	return value, ok
+}
+
+func (v *validator) string(object map[string]any, key string) string {
+	value, ok := v.field(object, key)
+	if !ok {
+		return ""
+	}
+	s, isString := value.(string)
+	if !isString {
+		v.failf("%s is %s, want a string", key, kind(value))
+	}
+	return s
+}
+
+// nullableString checks a field that is a string or null, and must be present
+func (v *validator) nullableString(object map[string]any, key string) *string {
+	value, ok := v.field(object, key)
+	if !ok || value == nil {
+		return nil
+	}
+	s, isString := value.(string)
+	if !isString {
+		v.failf("%s is %s, want a string or null", key, kind(value))
+		return nil
+	}
+	return &s
+}
+
+func (v *validator) integer(object map[string]any, key string) int64 {
+	value, ok := v.field(object, key)
+	if !ok {
+		return 0
+	}
+	number, isNumber := value.(float64)
+	if !isNumber || number != float64(int64(number)) {
+		v.failf("%s is %s, want an integer", key, kind(value))
+	}
+	return int64(number)
+}
+
+func (v *validator) equals(object map[string]any, key, want string) {
+	v.string(object, key)
+	if got, ok := object[key].(string); ok && got != want {
+		v.failf("%s is %q, want %q", key, got, want)
+	}
+}
+
+func (v *validator) nonEmpty(object map[string]any, key string) {
+	v.string(object, key)
+	if got, ok := object[key].(string); ok && got == "" {
+		v.failf("%s is empty", key)
+	}
+}
+
+func (v *validator) object(object map[string]any, key string) map[string]any {
+	value, ok := v.field(object, key)
+	if !ok {
+		return nil
+	}
+	nested, isObject := value.(map[string]any)
+	if !isObject {
+		v.failf("%s is %s, want an object", key, kind(value))
+	}
+	return nested
+}
+
+// objects returns the objects of an array field
+func (v *validator) objects(object map[string]any, key string) []map[string]any {
+	value, ok := v.field(object, key)
+	if !ok {
+		return nil
+	}
+	array, isArray := value.([]any)
+	if !isArray {
+		v.failf("%s is %s, want an array", key, kind(value))
+		return nil
+	}
+	objects := make([]map[string]any, 0, len(array))
+	for i, element := range array {
+		nested, isObject := element.(map[string]any)
+		if !isObject {
+			v.failf("%s[%d] is %s, want an object", key, i, kind(element))
+			continue
+		}
+		objects = append(objects, nested)
+	}
+	return objects
+}
+
+func (v *validator) chatCompletion(object map[string]any) {
+	v.header(object, "chat.completion")
+	choices := v.objects(object, "choices")
+	if _, ok := object["choices"]; ok && len(choices) == 0 {
+		v.failf("choices is empty")
+	}
+	for i, choice := range choices {
+		c := v.at(fmt.Sprintf("choices[%d]", i))
+		c.integer(choice, "index")
+		if message := c.object(choice, "message"); message != nil {
+			m := c.at("message")
+			m.equals(message, "role", "assistant")
+			m.nullableString(message, "content")
+		}
+		c.finishReason(choice, false)
+	}
+	if usage := v.object(object, "usage"); usage != nil {
+		v.at("usage").usage(usage)
+	}
+}
+
+func (v *validator) chunk(object map[string]any) {
+	v.header(object, "chat.completion.chunk")
+	for i, choice := range v.objects(object, "choices") {
+		c := v.at(fmt.Sprintf("choices[%d]", i))
+		c.integer(choice, "index")
+		if delta := c.object(choice, "delta"); delta != nil {
+			d := c.at("delta")
+			if _, ok := delta["role"]; ok {
+				d.equals(delta, "role", "assistant")
+			}
+			if _, ok := delta["content"]; ok {
+				d.nullableString(delta, "content")
+			}
+		}
+		c.finishReason(choice, true)
+	}
+	if usage, ok := object["usage"]; ok && usage != nil {
+		if nested := v.object(object, "usage"); nested != nil {
+			v.at("usage").usage(nested)
+		}
+	}
+}
+
+// header checks the fields every completion and chunk starts with
+func (v *validator) header(object map[string]any, kind string) {
+	v.nonEmpty(object, "id")
+	v.equals(object, "object", kind)
+	v.integer(object, "created")
+	v.string(object, "model")
+}
+
+// finishReason checks a choice's finish reason, which chunks leave null
+// until the last one
+func (v *validator) finishReason(choice map[string]any, nullable bool) {
+	var reason *string
+	if nullable {
+		reason = v.nullableString(choice, "finish_reason")
+	} else if s := v.string(choice, "finish_reason"); choice["finish_reason"] != nil {
+		reason = &s
+	}
+	if reason != nil && !finishReasons[*reason] {
+		v.failf("finish_reason %q is not one the SDKs know", *reason)
+	}
+}
+
+func (v *validator) usage(usage map[string]any) {
+	prompt := v.integer(usage, "prompt_tokens")
+	completion := v.integer(usage, "completion_tokens")
+	if total := v.integer(usage, "total_tokens"); total != prompt+completion {
+		v.failf("total_tokens %d is not prompt_tokens + completion_tokens (%d)", total, prompt+completion)
+	}
+}
+
+func (v *validator) model(model map[string]any) {
+	v.nonEmpty(model, "id")
+	v.equals(model, "object", "model")
+	v.integer(model, "created")
+	v.string(model, "owned_by")
+}
+
+func (v *validator) embeddingList(object map[string]any) {
+	v.equals(object, "object", "list")
+	v.string(object, "model")
+	for i, embedding := range v.objects(object, "data") {
+		e := v.at(fmt.Sprintf("data[%d]", i))
+		e.equals(embedding, "object", "embedding")
+		e.integer(embedding, "index")
+		value, ok := e.field(embedding, "embedding")
+		if !ok {
+			continue
+		}
+		// base64 encoded embeddings are strings
+		switch vector := value.(type) {
+		case string:
+		case []any:
+			for j, component := range vector {
+				if _, isNumber := component.(float64); !isNumber {
+					e.failf("embedding[%d] is %s, want a number", j, kind(component))
+					break
+				}
+			}
+		default:
+			e.failf("embedding is %s, want an array or a base64 string", kind(value))
+		}
+	}
+	if usage := v.object(object, "usage"); usage != nil {
+		u := v.at("usage")
+		prompt := u.integer(usage, "prompt_tokens")
+		if total := u.integer(usage, "total_tokens"); total != prompt {
+			u.failf("total_tokens %d is not prompt_tokens (%d)", total, prompt)
+		}
+	}
+}
+
+// error checks an error response. The SDKs read message, type, param and
+// code, the last two of which may be null but must be present
+func (v *validator) error(object map[string]any, param, code string) {
+	body := v.object(object, "error")
+	if body == nil {
+		return
+	}
+	e := v.at("error")
+	e.nonEmpty(body, "message")
+	e.string(body, "type")
+	gotParam := e.nullableString(body, "param")
+	gotCode := e.nullableString(body, "code")
+	if param != "" && (gotParam == nil || *gotParam != param) {
+		e.failf("param is %s, want %q", describe(gotParam), param)
+	}
+	if code != "" && (gotCode == nil || *gotCode != code) {
+		e.failf("code is %s, want %q", describe(gotCode), code)
+	}
+}
+
+// kind names the JSON type of a decoded value
+func kind(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	case []any:
+		return "an array"
+	case map[string]any:
+		return "an object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func describe(s *string) string {
+	if s == nil {
+		return "null"
+	}
+	return fmt.Sprintf("%q", *s)
+}
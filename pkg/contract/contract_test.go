@@ -0,0 +1,37 @@
+package contract
+
+import (
+	"os"
+	"testing"
+)
+
+// TestReferences checks the shape checks accept what the OpenAI API itself
+// answered to each capture
+func TestReferences(t *testing.T) {
+	captures, err := Captures()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(captures) == 0 {
+		t.Fatal("no captures embedded")
+	}
+	for _, capture := range captures {
+		t.Run(capture.Name, func(t *testing.T) {
+			if capture.Reference.Status != capture.Expect.Status {
+				t.Errorf("reference status %d, expected %d", capture.Reference.Status, capture.Expect.Status)
+			}
+			for _, violation := range capture.Expect.Validate(capture.Reference.ContentType, []byte(capture.Reference.Body)) {
+				t.Error(violation)
+			}
+		})
+	}
+}
+
+// TestLiveGateway checks a running gateway. Set OPENAI_CONTRACT_URL to run it
+func TestLiveGateway(t *testing.T) {
+	baseURL := os.Getenv(EnvURL)
+	if baseURL == "" {
+		t.Skipf("set %s to check a running gateway", EnvURL)
+	}
+	Check(t, baseURL, os.Getenv(EnvAPIKey))
+}
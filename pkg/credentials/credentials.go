@@ -0,0 +1,368 @@
+// Package credentials rotates provider API keys blue/green: a new key is
+// staged next to the active one, validated with probes while traffic keeps
+// using the active key, then promoted in one switch that retires the old key
+package credentials
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/envelope"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/logging"
+)
+
+var logger = logging.Module("credentials")
+
+// Errors returned for operations a provider's credentials are not ready for
+var (
+	ErrNothingStaged = errors.New("no key is staged")
+	ErrNotValidated  = errors.New("the staged key has not passed validation")
+)
+
+// Key sources
+const (
+	// SourceEnvironment is the <NAME>_API_KEY variable or the provider CSV
+	SourceEnvironment = "environment"
+	// SourcePromoted is a key staged and promoted through the admin API
+	SourcePromoted = "promoted"
+)
+
+// Staged key statuses
+const (
+	StagedPending   = "pending"
+	StagedValidated = "validated"
+	StagedFailed    = "failed"
+)
+
+// credential is the stored state of one provider; keys are plaintext in
+// memory and sealed on disk when a sealer is set
+type credential struct {
+	Provider   string    `json:"provider"`
+	Active     string    `json:"active,omitempty"`
+	PromotedAt time.Time `json:"promoted_at"`
+
+	Staged          string    `json:"staged,omitempty"`
+	StagedAt        time.Time `json:"staged_at"`
+	StagedStatus    string    `json:"staged_status,omitempty"`
+	ValidatedAt     time.Time `json:"validated_at"`
+	ValidationError string    `json:"validation_error,omitempty"`
+
+	// Only the fingerprint of a retired key is kept
+	Retired   string    `json:"retired_fingerprint,omitempty"`
+	RetiredAt time.Time `json:"retired_at"`
+}
+
+// StagedKey describes a key awaiting promotion
+type StagedKey struct {
+	Fingerprint     string     `json:"fingerprint"`
+	StagedAt        time.Time  `json:"staged_at"`
+	Status          string     `json:"status"`
+	ValidatedAt     *time.Time `json:"validated_at,omitempty"`
+	ValidationError string     `json:"validation_error,omitempty"`
+}
+
+// Status describes the keys of a provider by fingerprint, never by value
+type Status struct {
+	Provider          string     `json:"provider"`
+	Source            string     `json:"source"`
+	ActiveFingerprint string     `json:"active_fingerprint,omitempty"`
+	PromotedAt        *time.Time `json:"promoted_at,omitempty"`
+	Staged            *StagedKey `json:"staged,omitempty"`
+	// RetiredFingerprint is the key the last promotion replaced
+	RetiredFingerprint string     `json:"retired_fingerprint,omitempty"`
+	RetiredAt          *time.Time `json:"retired_at,omitempty"`
+}
+
+// Store holds the provider keys promoted or staged through the admin API.
+// Providers without a promoted key use the fallback, normally their
+// environment variable. With a path the keys are saved as a JSON file,
+// sealed with the sealer when one is set
+type Store struct {
+	path        string
+	sealer      *envelope.Sealer
+	fallback    func(provider string) string
+	credentials map[string]*credential
+	mutex       sync.RWMutex
+}
+
+// NewStore creates a store of the keys saved at path, empty for memory
+// only. fallback returns the key of a provider without a promoted one
+func NewStore(path string, sealer *envelope.Sealer, fallback func(provider string) string) (*Store, error) {
+	store := &Store{
+		path:        path,
+		sealer:      sealer,
+		fallback:    fallback,
+		credentials: make(map[string]*credential),
+	}
+	if path == "" {
+		return store, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read provider credentials: %w", err)
+	}
+	var saved []*credential
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("failed to parse provider credentials %s: %w", path, err)
+	}
+	for _, cred := range saved {
+		for _, key := range []*string{&cred.Active, &cred.Staged} {
+			if *key, err = store.open(cred.Provider, *key); err != nil {
+				return nil, fmt.Errorf("failed to decrypt credentials of %s: %w", cred.Provider, err)
+			}
+		}
+		store.credentials[strings.ToLower(cred.Provider)] = cred
+	}
+	return store, nil
+}
+
+// Key returns the key requests to provider are sent with: the promoted key,
+// or the fallback's
+func (s *Store) Key(provider string) string {
+	s.mutex.RLock()
+	cred := s.credentials[strings.ToLower(provider)]
+	s.mutex.RUnlock()
+	if cred != nil && cred.Active != "" {
+		return cred.Active
+	}
+	if s.fallback == nil {
+		return ""
+	}
+	return s.fallback(provider)
+}
+
+// Staged returns the staged key of provider
+func (s *Store) Staged(provider string) (string, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	cred := s.credentials[strings.ToLower(provider)]
+	if cred == nil || cred.Staged == "" {
+		return "", ErrNothingStaged
+	}
+	return cred.Staged, nil
+}
+
+// Stage stages key for provider, replacing a key staged before it. Traffic
+// keeps using the active key until the staged one is promoted
+func (s *Store) Stage(provider, key string) (*Status, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return nil, errors.New("api_key is required")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	cred := s.get(provider)
+	cred.Staged = key
+	cred.StagedAt = time.Now().UTC()
+	cred.StagedStatus = StagedPending
+	cred.ValidatedAt = time.Time{}
+	cred.ValidationError = ""
+	logger.Infof("Staged key %s for %s", Fingerprint(key), provider)
+	return s.status(provider, cred), s.save()
+}
+
+// RecordValidation records the outcome of validating the staged key of
+// provider; err is why it failed, nil when it passed. A key staged while the
+// validation ran is left pending
+func (s *Store) RecordValidation(provider, key string, err error) (*Status, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	cred := s.credentials[strings.ToLower(provider)]
+	if cred == nil || cred.Staged == "" {
+		return nil, ErrNothingStaged
+	}
+	if cred.Staged == key {
+		cred.ValidatedAt = time.Now().UTC()
+		cred.StagedStatus = StagedValidated
+		cred.ValidationError = ""
+		if err != nil {
+			cred.StagedStatus = StagedFailed
+			cred.ValidationError = err.Error()
+			logger.Warnf("Staged key %s for %s failed validation: %v", Fingerprint(key), provider, err)
+		}
+	}
+	return s.status(provider, cred), s.save()
+}
+
+// Promote switches provider to its validated staged key. Requests read the
+// key per call, so the switch is atomic; the key it replaces is retired and
+// only its fingerprint kept
+func (s *Store) Promote(provider string) (*Status, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	cred := s.credentials[strings.ToLower(provider)]
+	switch {
+	case cred == nil || cred.Staged == "":
+		return nil, ErrNothingStaged
+	case cred.StagedStatus != StagedValidated:
+		return nil, ErrNotValidated
+	}
+
+	previous := cred.Active
+	if previous == "" && s.fallback != nil {
+		previous = s.fallback(provider)
+	}
+	now := time.Now().UTC()
+	cred.Active, cred.PromotedAt = cred.Staged, now
+	cred.Retired, cred.RetiredAt = "", time.Time{}
+	if previous != "" && previous != cred.Active {
+		cred.Retired, cred.RetiredAt = Fingerprint(previous), now
+	}
+	cred.Staged, cred.StagedAt, cred.StagedStatus = "", time.Time{}, ""
+	cred.ValidatedAt, cred.ValidationError = time.Time{}, ""
+	logger.Infof("Promoted key %s for %s, retiring %s", Fingerprint(cred.Active), provider, cred.Retired)
+	return s.status(provider, cred), s.save()
+}
+
+// Discard drops the staged key of provider
+func (s *Store) Discard(provider string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	cred := s.credentials[strings.ToLower(provider)]
+	if cred == nil || cred.Staged == "" {
+		return ErrNothingStaged
+	}
+	cred.Staged, cred.StagedAt, cred.StagedStatus = "", time.Time{}, ""
+	cred.ValidatedAt, cred.ValidationError = time.Time{}, ""
+	return s.save()
+}
+
+// Status returns the key status of provider
+func (s *Store) Status(provider string) *Status {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.status(provider, s.credentials[strings.ToLower(provider)])
+}
+
+// Fingerprint identifies a key without revealing it
+func Fingerprint(key string) string {
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(sum[:6])
+}
+
+// get returns the credential of provider, creating it; callers hold the mutex
+func (s *Store) get(provider string) *credential {
+	key := strings.ToLower(provider)
+	cred := s.credentials[key]
+	if cred == nil {
+		cred = &credential{Provider: provider}
+		s.credentials[key] = cred
+	}
+	return cred
+}
+
+// status describes cred, which may be nil; callers hold the mutex
+func (s *Store) status(provider string, cred *credential) *Status {
+	status := &Status{Provider: provider, Source: SourceEnvironment}
+	if cred == nil || cred.Active == "" {
+		if s.fallback != nil {
+			status.ActiveFingerprint = Fingerprint(s.fallback(provider))
+		}
+	} else {
+		status.Source = SourcePromoted
+		status.ActiveFingerprint = Fingerprint(cred.Active)
+		status.PromotedAt = timePointer(cred.PromotedAt)
+	}
+	if cred == nil {
+		return status
+	}
+	if cred.Staged != "" {
+		status.Staged = &StagedKey{
+			Fingerprint:     Fingerprint(cred.Staged),
+			StagedAt:        cred.StagedAt,
+			Status:          cred.StagedStatus,
+			ValidatedAt:     timePointer(cred.ValidatedAt),
+			ValidationError: cred.ValidationError,
+		}
+	}
+	status.RetiredFingerprint = cred.Retired
+	status.RetiredAt = timePointer(cred.RetiredAt)
+	return status
+}
+
+// save writes the credentials to the path, if any; callers hold the mutex
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+	saved := make([]credential, 0, len(s.credentials))
+	for _, cred := range s.credentials {
+		stored := *cred
+		var err error
+		for _, key := range []*string{&stored.Active, &stored.Staged} {
+			if *key, err = s.seal(cred.Provider, *key); err != nil {
+				return fmt.Errorf("failed to encrypt credentials of %s: %w", cred.Provider, err)
+			}
+		}
+		saved = append(saved, stored)
+	}
+	sort.Slice(saved, func(i, j int) bool { return saved[i].Provider < saved[j].Provider })
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	// Write to a temporary file first so a crash never leaves half a key
+	if err := os.WriteFile(s.path+".tmp", data, 0600); err != nil {
+		return fmt.Errorf("failed to write provider credentials: %w", err)
+	}
+	if err := os.Rename(s.path+".tmp", s.path); err != nil {
+		return fmt.Errorf("failed to write provider credentials: %w", err)
+	}
+	return nil
+}
+
+// seal encrypts key for storage when a sealer is set, bound to provider
+func (s *Store) seal(provider, key string) (string, error) {
+	if key == "" || s.sealer == nil {
+		return key, nil
+	}
+	sealed, err := s.sealer.Seal("", []byte(strings.ToLower(provider)), []byte(key))
+	if err != nil {
+		return "", err
+	}
+	return sealed[0], nil
+}
+
+// open decrypts a stored key; keys saved before a sealer was set are read
+// as they are
+func (s *Store) open(provider, key string) (string, error) {
+	if !envelope.IsSealed(key) {
+		return key, nil
+	}
+	if s.sealer == nil {
+		return "", envelope.ErrNotConfigured
+	}
+	plaintext, err := s.sealer.Open(key, []byte(strings.ToLower(provider)))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// timePointer returns nil for the zero time
+func timePointer(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,173 @@
+// Package envelope encrypts stored data with per-record data keys that are
+// themselves encrypted ("wrapped") by key encryption keys held in a keyring
+// or a key management service such as Vault
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// sealedPrefix marks values sealed by a Sealer
+const sealedPrefix = "enc:v1:"
+
+// dataKeySize is the AES-256 data key length
+const dataKeySize = 32
+
+// ErrUnknownKey is returned when a value was wrapped by a key the manager
+// does not hold
+var ErrUnknownKey = errors.New("unknown key encryption key")
+
+// ErrNotConfigured is returned for operations on encrypted data when no
+// Sealer is configured
+var ErrNotConfigured = errors.New("encryption is not configured")
+
+// KeyManager wraps data keys under key encryption keys it never reveals
+type KeyManager interface {
+	// WrapKey encrypts dataKey under the tenant's current key and returns
+	// the ID of that key; tenant is empty for data without one
+	WrapKey(tenant string, dataKey []byte) (keyID string, wrapped []byte, err error)
+	// UnwrapKey decrypts a data key wrapped under keyID
+	UnwrapKey(keyID string, wrapped []byte) ([]byte, error)
+	// RewrapKey re-encrypts a wrapped data key under the tenant's current
+	// key, reporting whether anything changed
+	RewrapKey(tenant, keyID string, wrapped []byte) (newKeyID string, rewrapped []byte, changed bool, err error)
+}
+
+// sealed is the stored form of a value
+type sealed struct {
+	KeyID      string `json:"k"`
+	WrappedKey []byte `json:"w"`
+	Nonce      []byte `json:"n"`
+	Ciphertext []byte `json:"c"`
+}
+
+// Sealer encrypts values with AES-GCM under fresh data keys wrapped by a
+// KeyManager
+type Sealer struct {
+	keys KeyManager
+}
+
+// NewSealer creates a sealer whose data keys are wrapped by keys
+func NewSealer(keys KeyManager) *Sealer {
+	return &Sealer{keys: keys}
+}
+
+// IsSealed reports whether value was produced by a Sealer
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix)
+}
+
+// Seal encrypts each of values under one new data key wrapped for tenant.
+// aad is authenticated but not stored; Open must be given the same, which
+// binds the values to their record
+func (s *Sealer) Seal(tenant string, aad []byte, values ...[]byte) ([]string, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	keyID, wrapped, err := s.keys.WrapKey(tenant, dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]string, len(values))
+	for i, value := range values {
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+		results[i], err = encode(sealed{
+			KeyID:      keyID,
+			WrappedKey: wrapped,
+			Nonce:      nonce,
+			Ciphertext: aead.Seal(nil, nonce, value, aad),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// Open decrypts a sealed value
+func (s *Sealer) Open(value string, aad []byte) ([]byte, error) {
+	envelope, err := decode(value)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := s.keys.UnwrapKey(envelope.KeyID, envelope.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, envelope.Nonce, envelope.Ciphertext, aad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt value: %w", err)
+	}
+	return plaintext, nil
+}
+
+// Rewrap re-wraps a sealed value's data key under the tenant's current key
+// without touching the ciphertext. It returns the value unchanged when its
+// key is already current
+func (s *Sealer) Rewrap(tenant, value string) (string, bool, error) {
+	envelope, err := decode(value)
+	if err != nil {
+		return "", false, err
+	}
+	keyID, wrapped, changed, err := s.keys.RewrapKey(tenant, envelope.KeyID, envelope.WrappedKey)
+	if err != nil || !changed {
+		return value, false, err
+	}
+	envelope.KeyID, envelope.WrappedKey = keyID, wrapped
+	rewrapped, err := encode(envelope)
+	return rewrapped, err == nil, err
+}
+
+// encode serializes an envelope with the sealed prefix
+func encode(envelope sealed) (string, error) {
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return "", err
+	}
+	return sealedPrefix + base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decode parses a value produced by encode
+func decode(value string) (sealed, error) {
+	var envelope sealed
+	if !IsSealed(value) {
+		return envelope, fmt.Errorf("value is not sealed")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(value, sealedPrefix))
+	if err != nil {
+		return envelope, fmt.Errorf("malformed sealed value: %w", err)
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return envelope, fmt.Errorf("malformed sealed value: %w", err)
+	}
+	return envelope, nil
+}
+
+// newGCM creates an AES-GCM cipher for key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,150 @@
+package envelope
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// newTestKeyring builds a keyring from keys, generating the material of
+// each key ID once so keyrings built from the same IDs agree
+func newTestKeyring(t *testing.T, material map[string]string, keys ...KeyringKey) *Keyring {
+	t.Helper()
+	for i, key := range keys {
+		if _, ok := material[key.ID]; !ok {
+			generated, err := GenerateKey()
+			if err != nil {
+				t.Fatalf("GenerateKey: %v", err)
+			}
+			material[key.ID] = generated
+		}
+		keys[i].Key = material[key.ID]
+	}
+	keyring, err := NewKeyring(keys)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	return keyring
+}
+
+// keyIDOf returns the ID of the key that wrapped a sealed value
+func keyIDOf(t *testing.T, value string) string {
+	t.Helper()
+	envelope, err := decode(value)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return envelope.KeyID
+}
+
+func TestSealOpenRoundTrip(t *testing.T) {
+	sealer := NewSealer(newTestKeyring(t, map[string]string{}, KeyringKey{ID: "k1"}))
+	aad := []byte("request-42")
+
+	values, err := sealer.Seal("", aad, []byte("prompt text"), []byte("response text"))
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	for i, want := range []string{"prompt text", "response text"} {
+		if !IsSealed(values[i]) || strings.Contains(values[i], want) {
+			t.Errorf("value %d is not sealed: %q", i, values[i])
+		}
+		opened, err := sealer.Open(values[i], aad)
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		if string(opened) != want {
+			t.Errorf("Open = %q, want %q", opened, want)
+		}
+	}
+
+	if _, err := sealer.Open(values[0], []byte("request-43")); err == nil {
+		t.Error("value opened with the aad of another record")
+	}
+	envelope, _ := decode(values[0])
+	envelope.Ciphertext[0] ^= 1
+	tampered, _ := encode(envelope)
+	if _, err := sealer.Open(tampered, aad); err == nil {
+		t.Error("tampered value opened")
+	}
+	if _, err := sealer.Open("plain text", aad); err == nil {
+		t.Error("unsealed value opened")
+	}
+}
+
+func TestOpenWithWrongKey(t *testing.T) {
+	sealer := NewSealer(newTestKeyring(t, map[string]string{}, KeyringKey{ID: "k1"}))
+	values, err := sealer.Seal("", nil, []byte("secret"))
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+
+	// Same key ID, other material
+	impostor := NewSealer(newTestKeyring(t, map[string]string{}, KeyringKey{ID: "k1"}))
+	if _, err := impostor.Open(values[0], nil); err == nil {
+		t.Error("value opened with another key of the same ID")
+	}
+
+	stranger := NewSealer(newTestKeyring(t, map[string]string{}, KeyringKey{ID: "k2"}))
+	if _, err := stranger.Open(values[0], nil); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Open without the key = %v, want ErrUnknownKey", err)
+	}
+}
+
+// TestKeyRotation checks that values sealed before a new key was added still
+// open, and that rewrapping moves them to the new key so the old one can be
+// dropped
+func TestKeyRotation(t *testing.T) {
+	material := map[string]string{}
+	before := NewSealer(newTestKeyring(t, material, KeyringKey{ID: "k1"}))
+	values, err := before.Seal("", nil, []byte("secret"))
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+
+	rotated := NewSealer(newTestKeyring(t, material, KeyringKey{ID: "k1"}, KeyringKey{ID: "k2"}))
+	if opened, err := rotated.Open(values[0], nil); err != nil || string(opened) != "secret" {
+		t.Fatalf("Open after rotation = %q, %v", opened, err)
+	}
+	fresh, err := rotated.Seal("", nil, []byte("new"))
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if id := keyIDOf(t, fresh[0]); id != "k2" {
+		t.Errorf("new value wrapped by %s, want k2", id)
+	}
+
+	rewrapped, changed, err := rotated.Rewrap("", values[0])
+	if err != nil || !changed {
+		t.Fatalf("Rewrap = %t, %v; want true, nil", changed, err)
+	}
+	if id := keyIDOf(t, rewrapped); id != "k2" {
+		t.Errorf("rewrapped value wrapped by %s, want k2", id)
+	}
+	if again, changed, err := rotated.Rewrap("", rewrapped); err != nil || changed || again != rewrapped {
+		t.Errorf("second Rewrap changed %t, err %v; want the value unchanged", changed, err)
+	}
+
+	after := NewSealer(newTestKeyring(t, material, KeyringKey{ID: "k2"}))
+	if opened, err := after.Open(rewrapped, nil); err != nil || string(opened) != "secret" {
+		t.Errorf("Open without the old key = %q, %v", opened, err)
+	}
+	if _, err := after.Open(values[0], nil); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Open of a value left on the dropped key = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestTenantKeys(t *testing.T) {
+	sealer := NewSealer(newTestKeyring(t, map[string]string{},
+		KeyringKey{ID: "shared"}, KeyringKey{ID: "acme-1", Tenant: "acme"}))
+
+	for tenant, want := range map[string]string{"acme": "acme-1", "globex": "shared", "": "shared"} {
+		values, err := sealer.Seal(tenant, nil, []byte("secret"))
+		if err != nil {
+			t.Fatalf("Seal: %v", err)
+		}
+		if id := keyIDOf(t, values[0]); id != want {
+			t.Errorf("value of tenant %q wrapped by %s, want %s", tenant, id, want)
+		}
+	}
+}
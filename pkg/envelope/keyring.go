@@ -0,0 +1,128 @@
+package envelope
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// KeyringKey is a key encryption key in a keyring file
+type KeyringKey struct {
+	ID string `yaml:"id"`
+	// Tenant restricts the key to one tenant's data; empty keys serve data
+	// without a tenant and tenants without keys of their own
+	Tenant string `yaml:"tenant,omitempty"`
+	// Key is the base64-encoded 32-byte AES key
+	Key string `yaml:"key"`
+}
+
+// Keyring is a KeyManager holding key encryption keys locally. The last key
+// listed for a tenant is its current key; earlier ones stay usable for
+// unwrapping until their data is rewrapped
+type Keyring struct {
+	keys    map[string][]byte
+	current map[string]string
+}
+
+// NewKeyring validates keys
+func NewKeyring(keys []KeyringKey) (*Keyring, error) {
+	kr := &Keyring{keys: make(map[string][]byte), current: make(map[string]string)}
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, fmt.Errorf("keyring key needs an id")
+		}
+		if _, exists := kr.keys[key.ID]; exists {
+			return nil, fmt.Errorf("keyring key %s is listed more than once", key.ID)
+		}
+		material, err := base64.StdEncoding.DecodeString(key.Key)
+		if err != nil || len(material) != dataKeySize {
+			return nil, fmt.Errorf("keyring key %s must be %d bytes of base64", key.ID, dataKeySize)
+		}
+		kr.keys[key.ID] = material
+		kr.current[key.Tenant] = key.ID
+	}
+	if _, ok := kr.current[""]; !ok {
+		return nil, fmt.Errorf("keyring needs at least one key without a tenant")
+	}
+	return kr, nil
+}
+
+// LoadKeyring reads a keyring from a YAML file with a top-level "keys" list
+func LoadKeyring(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring: %w", err)
+	}
+
+	var file struct {
+		Keys []KeyringKey `yaml:"keys"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse keyring %s: %w", path, err)
+	}
+	return NewKeyring(file.Keys)
+}
+
+// GenerateKey returns a new base64-encoded key for a keyring file
+func GenerateKey() (string, error) {
+	key := make([]byte, dataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// currentKey returns the ID of the key that wraps new data for tenant
+func (kr *Keyring) currentKey(tenant string) string {
+	if id, ok := kr.current[tenant]; ok {
+		return id
+	}
+	return kr.current[""]
+}
+
+// WrapKey implements KeyManager
+func (kr *Keyring) WrapKey(tenant string, dataKey []byte) (string, []byte, error) {
+	keyID := kr.currentKey(tenant)
+	aead, err := newGCM(kr.keys[keyID])
+	if err != nil {
+		return "", nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+	return keyID, aead.Seal(nonce, nonce, dataKey, []byte(keyID)), nil
+}
+
+// UnwrapKey implements KeyManager
+func (kr *Keyring) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	key, ok := kr.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownKey, keyID)
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, fmt.Errorf("wrapped key is too short")
+	}
+	nonce, ciphertext := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, []byte(keyID))
+}
+
+// RewrapKey implements KeyManager
+func (kr *Keyring) RewrapKey(tenant, keyID string, wrapped []byte) (string, []byte, bool, error) {
+	if keyID == kr.currentKey(tenant) {
+		return keyID, wrapped, false, nil
+	}
+	dataKey, err := kr.UnwrapKey(keyID, wrapped)
+	if err != nil {
+		return "", nil, false, err
+	}
+	newKeyID, rewrapped, err := kr.WrapKey(tenant, dataKey)
+	return newKeyID, rewrapped, err == nil, err
+}
@@ -0,0 +1,118 @@
+package envelope
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultVaultMount is where Vault's transit secrets engine is usually mounted
+const DefaultVaultMount = "transit"
+
+// VaultTransit is a KeyManager backed by Vault's transit secrets engine. Key
+// encryption keys never leave Vault; rotating a transit key makes Vault wrap
+// new data keys with the new version while older versions still unwrap
+type VaultTransit struct {
+	addr       string
+	token      string
+	mount      string
+	keyName    string
+	httpClient *http.Client
+}
+
+// NewVaultTransit creates a transit key manager. Data without a tenant is
+// wrapped by keyName, a tenant's data by "<keyName>-<tenant>"
+func NewVaultTransit(addr, token, mount, keyName string) *VaultTransit {
+	if mount == "" {
+		mount = DefaultVaultMount
+	}
+	return &VaultTransit{
+		addr:       strings.TrimRight(addr, "/"),
+		token:      token,
+		mount:      strings.Trim(mount, "/"),
+		keyName:    keyName,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// tenantKey returns the transit key name for tenant
+func (vt *VaultTransit) tenantKey(tenant string) string {
+	if tenant == "" {
+		return vt.keyName
+	}
+	return vt.keyName + "-" + tenant
+}
+
+// WrapKey implements KeyManager
+func (vt *VaultTransit) WrapKey(tenant string, dataKey []byte) (string, []byte, error) {
+	keyID := vt.tenantKey(tenant)
+	var result struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+	err := vt.call("encrypt", keyID, map[string]string{"plaintext": base64.StdEncoding.EncodeToString(dataKey)}, &result)
+	if err != nil {
+		return "", nil, err
+	}
+	return keyID, []byte(result.Ciphertext), nil
+}
+
+// UnwrapKey implements KeyManager
+func (vt *VaultTransit) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	var result struct {
+		Plaintext string `json:"plaintext"`
+	}
+	if err := vt.call("decrypt", keyID, map[string]string{"ciphertext": string(wrapped)}, &result); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(result.Plaintext)
+}
+
+// RewrapKey implements KeyManager. Vault rewraps under the latest version of
+// the same transit key, so the key ID stays the same
+func (vt *VaultTransit) RewrapKey(tenant, keyID string, wrapped []byte) (string, []byte, bool, error) {
+	var result struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+	if err := vt.call("rewrap", keyID, map[string]string{"ciphertext": string(wrapped)}, &result); err != nil {
+		return "", nil, false, err
+	}
+	return keyID, []byte(result.Ciphertext), result.Ciphertext != string(wrapped), nil
+}
+
+// call posts body to a transit endpoint and decodes the response data
+func (vt *VaultTransit) call(operation, keyName string, body, data interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	endpoint := fmt.Sprintf("%s/v1/%s/%s/%s", vt.addr, vt.mount, operation, url.PathEscape(keyName))
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", vt.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := vt.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("vault transit %s: %w", operation, err)
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []string        `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("vault transit %s: status %d: %w", operation, resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("vault transit %s with key %s: status %d: %s", operation, keyName, resp.StatusCode, strings.Join(envelope.Errors, "; "))
+	}
+	return json.Unmarshal(envelope.Data, data)
+}
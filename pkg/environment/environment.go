@@ -0,0 +1,200 @@
+// Package environment gives API keys of each deployment environment, such as
+// dev, staging and prod, a routing profile of their own: the tiers and
+// providers their requests may use and the budgets they must stay within
+package environment
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
+)
+
+var (
+	// ErrUnknown is returned for requests of an environment without a profile
+	ErrUnknown = errors.New("unknown environment")
+	// ErrNoProviders is returned when an environment's profile leaves no
+	// provider the request may use
+	ErrNoProviders = errors.New("environment allows none of the available providers")
+	// ErrBudgetExceeded is returned once an environment has spent its monthly budget
+	ErrBudgetExceeded = errors.New("environment budget exceeded")
+)
+
+// validName restricts environment names to values safe in logs and paths
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// Profile is how requests of one environment are routed
+type Profile struct {
+	// Tiers are the provider tiers the environment may use; empty allows
+	// every tier
+	Tiers []tier.Tier `yaml:"tiers,omitempty" json:"tiers,omitempty"`
+	// Providers is the environment's provider inventory; empty allows every
+	// provider
+	Providers []string `yaml:"providers,omitempty" json:"providers,omitempty"`
+	// CostLimit caps the estimated cost of each request in USD; zero is
+	// unlimited
+	CostLimit float64 `yaml:"cost_limit,omitempty" json:"cost_limit,omitempty"`
+	// MonthlyBudget caps the cost of the environment's requests per calendar
+	// month (UTC) in USD; zero is unlimited
+	MonthlyBudget float64 `yaml:"monthly_budget,omitempty" json:"monthly_budget,omitempty"`
+}
+
+// Usage is an environment's spend in the current month
+type Usage struct {
+	Environment   string  `json:"environment"`
+	Month         string  `json:"month"`
+	Requests      int64   `json:"requests"`
+	Spend         float64 `json:"spend"`
+	MonthlyBudget float64 `json:"monthly_budget,omitempty"`
+	// Remaining is the budget left this month, absent when unlimited
+	Remaining *float64 `json:"remaining,omitempty"`
+}
+
+// spend is the cost recorded for an environment in one month
+type spend struct {
+	month    string
+	requests int64
+	cost     float64
+}
+
+// Profiles holds the routing profile of each environment and its spend in
+// the current month, kept in memory. It is safe for concurrent use
+type Profiles struct {
+	profiles map[string]Profile
+	spend    map[string]*spend
+	mutex    sync.RWMutex
+}
+
+// NewProfiles validates profiles, keyed by environment name
+func NewProfiles(profiles map[string]Profile) (*Profiles, error) {
+	p := &Profiles{profiles: make(map[string]Profile), spend: make(map[string]*spend)}
+	for name, profile := range profiles {
+		if !validName.MatchString(name) {
+			return nil, fmt.Errorf("invalid environment name %q", name)
+		}
+		for i, t := range profile.Tiers {
+			parsed, err := tier.Parse(string(t))
+			if err != nil {
+				return nil, fmt.Errorf("environment %s: %w", name, err)
+			}
+			profile.Tiers[i] = parsed
+		}
+		if profile.CostLimit < 0 || profile.MonthlyBudget < 0 {
+			return nil, fmt.Errorf("environment %s: cost_limit and monthly_budget must not be negative", name)
+		}
+		p.profiles[name] = profile
+	}
+	return p, nil
+}
+
+// Names returns the environment names in order
+func (p *Profiles) Names() []string {
+	names := make([]string, 0, len(p.profiles))
+	for name := range p.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the profile of an environment
+func (p *Profiles) Get(name string) (Profile, error) {
+	profile, ok := p.profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("%w: %s", ErrUnknown, name)
+	}
+	return profile, nil
+}
+
+// Allows reports whether the profile admits the named provider of tier t
+func (profile Profile) Allows(name string, t tier.Tier) bool {
+	if len(profile.Tiers) > 0 && !slices.Contains(profile.Tiers, t) {
+		return false
+	}
+	if len(profile.Providers) == 0 {
+		return true
+	}
+	for _, provider := range profile.Providers {
+		if strings.EqualFold(provider, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckBudget returns ErrBudgetExceeded once the environment has spent its
+// monthly budget
+func (p *Profiles) CheckBudget(name string) error {
+	usage, err := p.Usage(name)
+	if err != nil {
+		return err
+	}
+	if usage.Remaining != nil && *usage.Remaining <= 0 {
+		return fmt.Errorf("%w: %s spent $%.2f of $%.2f in %s", ErrBudgetExceeded, name, usage.Spend, usage.MonthlyBudget, usage.Month)
+	}
+	return nil
+}
+
+// RecordSpend adds the cost of a request made at the given time to the
+// environment's spend, and reports whether it spent the last of the
+// environment's budget. Requests of earlier months are ignored
+func (p *Profiles) RecordSpend(name string, at time.Time, cost float64) bool {
+	month := monthOf(at)
+	if month != monthOf(time.Now()) {
+		return false
+	}
+	profile, ok := p.profiles[name]
+	if !ok {
+		return false
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	current, ok := p.spend[name]
+	if !ok || current.month != month {
+		current = &spend{month: month}
+		p.spend[name] = current
+	}
+	current.requests++
+	current.cost += cost
+	return exhausted(profile.MonthlyBudget, current.cost, cost)
+}
+
+// Usage returns the environment's spend in the current month
+func (p *Profiles) Usage(name string) (*Usage, error) {
+	profile, err := p.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	usage := &Usage{Environment: name, Month: monthOf(time.Now()), MonthlyBudget: profile.MonthlyBudget}
+	if current, ok := p.spend[name]; ok && current.month == usage.Month {
+		usage.Requests = current.requests
+		usage.Spend = current.cost
+	}
+	if profile.MonthlyBudget > 0 {
+		remaining := profile.MonthlyBudget - usage.Spend
+		usage.Remaining = &remaining
+	}
+	return usage, nil
+}
+
+// exhausted reports whether adding cost brought spent up to budget
+func exhausted(budget, spent, cost float64) bool {
+	return budget > 0 && spent >= budget && spent-cost < budget
+}
+
+// monthOf returns the calendar month (UTC) of t as "2006-01"
+func monthOf(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
@@ -0,0 +1,384 @@
+// Package events is the gateway's event bus. The gateway publishes what
+// happens to requests, providers, budgets and keys; in-process subscribers
+// and publishers to external systems such as NATS and Kafka receive the
+// events in the background, so alerting, billing and data pipelines never
+// slow requests down
+package events
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/logging"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/requestid"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
+)
+
+// logger is the events module logger, configurable via LOG_MODULES=events=<level>
+var logger = logging.Module("events")
+
+// DefaultBufferSize is how many events may wait for delivery before new ones
+// are dropped
+const DefaultBufferSize = 1024
+
+// DefaultPublishTimeout bounds the delivery of one event to one publisher
+const DefaultPublishTimeout = 5 * time.Second
+
+// Type names an event
+type Type string
+
+const (
+	// RequestCompleted is published when a request finishes, successfully or
+	// not, with RequestCompletedData
+	RequestCompleted Type = "request.completed"
+	// ProviderUnhealthy is published when a provider's error rate makes it
+	// unhealthy, with ProviderHealthData
+	ProviderUnhealthy Type = "provider.unhealthy"
+	// ProviderRecovered is published when an unhealthy provider is healthy
+	// again, with ProviderHealthData
+	ProviderRecovered Type = "provider.recovered"
+	// BudgetExceeded is published when a request spends the last of a tenant's
+	// or environment's monthly budget, with BudgetExceededData
+	BudgetExceeded Type = "budget.exceeded"
+	// KeyRotated is published when keys are bound to or removed from a
+	// tenant, or stored history is rewrapped onto new encryption keys, with
+	// KeyRotatedData
+	KeyRotated Type = "key.rotated"
+	// ProviderSLABreached is published the first time in a month a provider
+	// misses one of its SLA targets, with SLABreachData
+	ProviderSLABreached Type = "provider.sla_breached"
+)
+
+// Types lists every event type
+var Types = []Type{RequestCompleted, ProviderUnhealthy, ProviderRecovered, BudgetExceeded, KeyRotated, ProviderSLABreached}
+
+// Event is one occurrence, as delivered to subscribers and publishers
+type Event struct {
+	ID   string    `json:"id"`
+	Type Type      `json:"type"`
+	Time time.Time `json:"time"`
+	// Source is the gateway node that published the event
+	Source string      `json:"source,omitempty"`
+	Data   interface{} `json:"data"`
+}
+
+// RequestCompletedData describes a finished request. Request and response
+// content are left out
+type RequestCompletedData struct {
+	RequestID   string    `json:"request_id"`
+	KeyID       string    `json:"key_id,omitempty"`
+	Tenant      string    `json:"tenant,omitempty"`
+	Environment string    `json:"environment,omitempty"`
+	Mode        string    `json:"mode,omitempty"`
+	Status      string    `json:"status"`
+	Provider    string    `json:"provider,omitempty"`
+	Tier        tier.Tier `json:"tier,omitempty"`
+	Model       string    `json:"model,omitempty"`
+	TokensUsed  int64     `json:"tokens_used"`
+	Cost        float64   `json:"cost"`
+	DurationMs  int64     `json:"duration_ms"`
+	Error       string    `json:"error,omitempty"`
+	// Tags are the analytics tags the client sent with the request
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// ProviderHealthData describes a provider whose health changed
+type ProviderHealthData struct {
+	Provider       string  `json:"provider"`
+	Status         string  `json:"status"`
+	PreviousStatus string  `json:"previous_status,omitempty"`
+	ErrorRate      float64 `json:"error_rate"`
+	TotalRequests  int64   `json:"total_requests"`
+	AverageLatency float64 `json:"average_latency_ms"`
+}
+
+// BudgetExceededData describes a spent monthly budget
+type BudgetExceededData struct {
+	// Scope is "tenant" or "environment"
+	Scope         string  `json:"scope"`
+	Name          string  `json:"name"`
+	Month         string  `json:"month"`
+	Spend         float64 `json:"spend"`
+	MonthlyBudget float64 `json:"monthly_budget"`
+}
+
+// KeyRotatedData describes a key change
+type KeyRotatedData struct {
+	// Scope is "tenant" for tenant key bindings or "history" for the
+	// encryption keys of stored request history
+	Scope string `json:"scope"`
+	Name  string `json:"name,omitempty"`
+	// Added and Removed are key IDs, never keys
+	Added     []string `json:"added,omitempty"`
+	Removed   []string `json:"removed,omitempty"`
+	Rewrapped int      `json:"rewrapped,omitempty"`
+}
+
+// SLABreachData describes a provider missing an SLA target over the month
+// to date. Metric is availability, p95_latency or error_rate
+type SLABreachData struct {
+	Provider string  `json:"provider"`
+	Month    string  `json:"month"`
+	Metric   string  `json:"metric"`
+	Target   float64 `json:"target"`
+	Actual   float64 `json:"actual"`
+	Requests int     `json:"requests"`
+}
+
+// Publisher sends events to an external system
+type Publisher interface {
+	Name() string
+	Publish(ctx context.Context, event Event) error
+	Close() error
+}
+
+// Stats counts the events of a bus
+type Stats struct {
+	Published int64 `json:"published"`
+	Dropped   int64 `json:"dropped"`
+	// Failed counts deliveries a publisher failed
+	Failed int64 `json:"failed"`
+}
+
+// target is a subscriber or publisher and the event types it receives
+type target struct {
+	name      string
+	handle    func(ctx context.Context, event Event) error
+	types     map[Type]bool
+	publisher Publisher
+}
+
+// receives reports whether the target wants events of eventType
+func (t *target) receives(eventType Type) bool {
+	return len(t.types) == 0 || t.types[eventType]
+}
+
+// Bus delivers published events to its subscribers and publishers, in order,
+// from a background goroutine. Publishing never blocks: when the buffer is
+// full the event is dropped and counted. A nil *Bus drops every event
+type Bus struct {
+	source  string
+	timeout time.Duration
+	queue   chan Event
+	targets []*target
+	mutex   sync.RWMutex
+	closed  bool
+	done    chan struct{}
+
+	published atomic.Int64
+	dropped   atomic.Int64
+	failed    atomic.Int64
+}
+
+// NewBus starts a bus holding up to bufferSize undelivered events, whose
+// events name source as their origin
+func NewBus(source string, bufferSize int) *Bus {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+	bus := &Bus{
+		source:  source,
+		timeout: DefaultPublishTimeout,
+		queue:   make(chan Event, bufferSize),
+		done:    make(chan struct{}),
+	}
+	go bus.run()
+	return bus
+}
+
+// SetPublishTimeout bounds the delivery of one event to one publisher
+func (b *Bus) SetPublishTimeout(timeout time.Duration) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.timeout = timeout
+}
+
+// Subscribe calls handler with every later event of the given types, or of
+// every type when none is given. Handlers run on the delivery goroutine and
+// must not block
+func (b *Bus) Subscribe(handler func(Event), types ...Type) {
+	b.add(&target{
+		name: "subscriber",
+		handle: func(_ context.Context, event Event) error {
+			handler(event)
+			return nil
+		},
+		types: typeSet(types),
+	})
+}
+
+// AddPublisher sends every later event of the given types, or of every type
+// when none is given, to publisher. The bus closes it when it is closed
+func (b *Bus) AddPublisher(publisher Publisher, types ...Type) {
+	b.add(&target{
+		name:      publisher.Name(),
+		handle:    publisher.Publish,
+		types:     typeSet(types),
+		publisher: publisher,
+	})
+}
+
+// Publishers returns the names of the configured publishers
+func (b *Bus) Publishers() []string {
+	if b == nil {
+		return nil
+	}
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	var names []string
+	for _, target := range b.targets {
+		if target.publisher != nil {
+			names = append(names, target.name)
+		}
+	}
+	return names
+}
+
+// Publish queues an event of eventType carrying data. ctx only supplies the
+// request ID logged with failures
+func (b *Bus) Publish(ctx context.Context, eventType Type, data interface{}) {
+	if b == nil {
+		return
+	}
+	event := Event{
+		ID:     requestid.New(),
+		Type:   eventType,
+		Time:   time.Now().UTC(),
+		Source: b.source,
+		Data:   data,
+	}
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	if b.closed {
+		return
+	}
+	select {
+	case b.queue <- event:
+		b.published.Add(1)
+	default:
+		b.dropped.Add(1)
+		logger.WithField(requestid.Field, requestid.FromContext(ctx)).Warnf("Event buffer full, dropped %s event", eventType)
+	}
+}
+
+// Stats returns the bus's counters
+func (b *Bus) Stats() Stats {
+	if b == nil {
+		return Stats{}
+	}
+	return Stats{
+		Published: b.published.Load(),
+		Dropped:   b.dropped.Load(),
+		Failed:    b.failed.Load(),
+	}
+}
+
+// Close stops accepting events, delivers the queued ones until ctx is done
+// and closes the publishers
+func (b *Bus) Close(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		return nil
+	}
+	b.closed = true
+	close(b.queue)
+	b.mutex.Unlock()
+
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		logger.Warnf("Closed event bus with %d events undelivered", len(b.queue))
+	}
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	var firstErr error
+	for _, target := range b.targets {
+		if target.publisher == nil {
+			continue
+		}
+		if err := target.publisher.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// add registers a target
+func (b *Bus) add(target *target) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.targets = append(b.targets, target)
+}
+
+// run delivers queued events until the queue is closed
+func (b *Bus) run() {
+	defer close(b.done)
+	for event := range b.queue {
+		b.mutex.RLock()
+		targets := b.targets
+		timeout := b.timeout
+		b.mutex.RUnlock()
+
+		for _, target := range targets {
+			if target.receives(event.Type) {
+				b.deliver(target, event, timeout)
+			}
+		}
+	}
+}
+
+// deliver hands event to one target, logging failures
+func (b *Bus) deliver(target *target, event Event, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := target.handle(ctx, event); err != nil {
+		b.failed.Add(1)
+		logger.Warnf("Failed to publish %s event %s to %s: %v", event.Type, event.ID, target.name, err)
+	}
+}
+
+// typeSet indexes types, nil when empty
+func typeSet(types []Type) map[Type]bool {
+	if len(types) == 0 {
+		return nil
+	}
+	set := make(map[Type]bool, len(types))
+	for _, eventType := range types {
+		set[eventType] = true
+	}
+	return set
+}
+
+// ParseTypes reads a comma-separated list of event types. A name ending in
+// ".*" selects every type with that prefix, such as provider.*
+func ParseTypes(list string) ([]Type, error) {
+	var types []Type
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		prefix, wildcard := strings.CutSuffix(name, "*")
+		matched := false
+		for _, known := range Types {
+			if string(known) == name || (wildcard && strings.HasPrefix(string(known), prefix)) {
+				types = append(types, known)
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("unknown event type %q", name)
+		}
+	}
+	return types, nil
+}
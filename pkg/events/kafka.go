@@ -0,0 +1,67 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// DefaultKafkaTopic receives every event published to Kafka
+const DefaultKafkaTopic = "palmoe-events"
+
+// kafkaBatchTimeout is how long the writer waits to fill a batch. Events are
+// delivered one at a time, so it stays short
+const kafkaBatchTimeout = 10 * time.Millisecond
+
+// KafkaPublisher publishes events to one topic, keyed by event type so each
+// type stays in order, with the type and ID as headers
+type KafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+// NewKafkaPublisher creates a publisher writing to topic on brokers
+// (host:9092)
+func NewKafkaPublisher(brokers []string, topic string) (*KafkaPublisher, error) {
+	if len(brokers) == 0 {
+		return nil, fmt.Errorf("kafka needs at least one broker")
+	}
+	if topic == "" {
+		topic = DefaultKafkaTopic
+	}
+	return &KafkaPublisher{writer: &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireOne,
+		BatchTimeout: kafkaBatchTimeout,
+	}}, nil
+}
+
+// Name implements Publisher
+func (p *KafkaPublisher) Name() string {
+	return "kafka"
+}
+
+// Publish implements Publisher
+func (p *KafkaPublisher) Publish(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return p.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(event.Type),
+		Value: data,
+		Headers: []kafka.Header{
+			{Key: "event-type", Value: []byte(event.Type)},
+			{Key: "event-id", Value: []byte(event.ID)},
+		},
+	})
+}
+
+// Close implements Publisher, flushing pending events first
+func (p *KafkaPublisher) Close() error {
+	return p.writer.Close()
+}
@@ -0,0 +1,55 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+// DefaultNATSSubject prefixes the subject of every event published to NATS
+const DefaultNATSSubject = "palmoe.events"
+
+// NATSPublisher publishes each event to <subject>.<type>, e.g.
+// palmoe.events.request.completed, with the event ID as Nats-Msg-Id so
+// JetStream streams drop duplicates
+type NATSPublisher struct {
+	conn    *nats.Conn
+	subject string
+}
+
+// NewNATSPublisher connects to the NATS server at url (nats://host:4222),
+// reconnecting for as long as the gateway runs
+func NewNATSPublisher(url, subject string) (*NATSPublisher, error) {
+	if subject == "" {
+		subject = DefaultNATSSubject
+	}
+	conn, err := nats.Connect(url, nats.Name("Your-PaL-MoE"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	return &NATSPublisher{conn: conn, subject: subject}, nil
+}
+
+// Name implements Publisher
+func (p *NATSPublisher) Name() string {
+	return "nats"
+}
+
+// Publish implements Publisher
+func (p *NATSPublisher) Publish(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	msg := nats.NewMsg(p.subject + "." + string(event.Type))
+	msg.Data = data
+	msg.Header.Set(nats.MsgIdHdr, event.ID)
+	return p.conn.PublishMsg(msg)
+}
+
+// Close implements Publisher, flushing pending events first
+func (p *NATSPublisher) Close() error {
+	return p.conn.Drain()
+}
@@ -0,0 +1,360 @@
+// Package flags switches experimental gateway behaviors on and off globally,
+// per tenant or per API key, without a restart. Overrides are kept as JSON
+// and edited through the admin API or by hand
+package flags
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Flags of the experimental behaviors
+const (
+	// SpeculativeRouting drafts requests that name no strategy with a
+	// cheaper provider first
+	SpeculativeRouting = "speculative_routing"
+	// SafetyEscalation retries responses the safety filter blocked on an
+	// official provider
+	SafetyEscalation = "safety_escalation"
+	// PromptOptimization rewrites prompts before they are sent
+	PromptOptimization = "prompt_optimization"
+)
+
+// Definition describes a flag
+type Definition struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Definitions lists every flag
+var Definitions = []Definition{
+	{SpeculativeRouting, "Draft requests that name no strategy with the cheapest capable provider and verify drafts that fail the quality check"},
+	{SafetyEscalation, "Retry responses the safety filter blocked on an official provider, as the escalation policy allows"},
+	{PromptOptimization, "Rewrite prompts for their task before sending them"},
+}
+
+// Scopes of an override, from the broadest to the narrowest
+const (
+	ScopeGlobal = "global"
+	ScopeTenant = "tenant"
+	ScopeKey    = "key"
+	// SourceDefault is the source of an evaluation no override decided
+	SourceDefault = "default"
+)
+
+// maxTargetLength bounds tenant IDs and key IDs named by overrides
+const maxTargetLength = 128
+
+var (
+	// ErrUnknownFlag is returned for a flag name missing from Definitions
+	ErrUnknownFlag = errors.New("unknown feature flag")
+	// ErrNoOverride is returned when clearing an override that is not set
+	ErrNoOverride = errors.New("feature flag override not set")
+)
+
+// Flag is the overrides of one flag. Unset scopes fall through to the next
+// broader one, and the gateway's own configuration comes last
+type Flag struct {
+	Name    string          `json:"name"`
+	Enabled *bool           `json:"enabled,omitempty"`
+	Tenants map[string]bool `json:"tenants,omitempty"`
+	Keys    map[string]bool `json:"keys,omitempty"`
+}
+
+// Status is a flag with its overrides and the default they override
+type Status struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Default     bool            `json:"default"`
+	Enabled     *bool           `json:"enabled,omitempty"`
+	Tenants     map[string]bool `json:"tenants,omitempty"`
+	Keys        map[string]bool `json:"keys,omitempty"`
+}
+
+// Evaluation is the state of a flag for one request
+type Evaluation struct {
+	Enabled bool `json:"enabled"`
+	// Source is the scope of the override that decided it, or default
+	Source string `json:"source"`
+}
+
+// Evaluations are the states of every flag for one request, by name
+type Evaluations map[string]Evaluation
+
+// Enabled reports whether the flag is on
+func (e Evaluations) Enabled(name string) bool {
+	return e[name].Enabled
+}
+
+// Store holds the flag overrides, saved as JSON at its path. It is safe for
+// concurrent use
+type Store struct {
+	path  string
+	flags map[string]*Flag
+	mutex sync.RWMutex
+}
+
+// NewMemoryStore creates a store whose overrides are lost on restart
+func NewMemoryStore() *Store {
+	return &Store{flags: make(map[string]*Flag)}
+}
+
+// NewStore creates a store, loading the overrides saved at path. An empty
+// path keeps them in memory only
+func NewStore(path string) (*Store, error) {
+	store := NewMemoryStore()
+	if path == "" {
+		return store, nil
+	}
+	store.path = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feature flags: %w", err)
+	}
+	var flags []*Flag
+	if err := json.Unmarshal(data, &flags); err != nil {
+		return nil, fmt.Errorf("failed to parse feature flags %s: %w", path, err)
+	}
+	for _, flag := range flags {
+		if _, ok := definition(flag.Name); !ok {
+			return nil, fmt.Errorf("feature flags %s: %w %q", path, ErrUnknownFlag, flag.Name)
+		}
+		if _, ok := store.flags[flag.Name]; ok {
+			return nil, fmt.Errorf("feature flags %s: %s is declared twice", path, flag.Name)
+		}
+		for target := range flag.Tenants {
+			if err := validTarget(ScopeTenant, target); err != nil {
+				return nil, fmt.Errorf("feature flags %s: %s: %w", path, flag.Name, err)
+			}
+		}
+		for target := range flag.Keys {
+			if err := validTarget(ScopeKey, target); err != nil {
+				return nil, fmt.Errorf("feature flags %s: %s: %w", path, flag.Name, err)
+			}
+		}
+		store.flags[flag.Name] = flag
+	}
+	return store, nil
+}
+
+// Evaluate returns the state of every flag for a request of tenant with the
+// API key keyID. The key's override wins over the tenant's, which wins over
+// the global one; defaults holds the state of flags without any
+func (s *Store) Evaluate(tenant, keyID string, defaults map[string]bool) Evaluations {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	evaluations := make(Evaluations, len(Definitions))
+	for _, definition := range Definitions {
+		evaluation := Evaluation{Enabled: defaults[definition.Name], Source: SourceDefault}
+		if flag := s.flags[definition.Name]; flag != nil {
+			if enabled, ok := flag.Keys[keyID]; ok && keyID != "" {
+				evaluation = Evaluation{Enabled: enabled, Source: ScopeKey}
+			} else if enabled, ok := flag.Tenants[tenant]; ok && tenant != "" {
+				evaluation = Evaluation{Enabled: enabled, Source: ScopeTenant}
+			} else if flag.Enabled != nil {
+				evaluation = Evaluation{Enabled: *flag.Enabled, Source: ScopeGlobal}
+			}
+		}
+		evaluations[definition.Name] = evaluation
+	}
+	return evaluations
+}
+
+// List returns every flag with its overrides, in the order of Definitions
+func (s *Store) List(defaults map[string]bool) []*Status {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	statuses := make([]*Status, len(Definitions))
+	for i, definition := range Definitions {
+		statuses[i] = s.status(definition, defaults)
+	}
+	return statuses
+}
+
+// Get returns one flag with its overrides
+func (s *Store) Get(name string, defaults map[string]bool) (*Status, error) {
+	definition, ok := definition(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFlag, name)
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.status(definition, defaults), nil
+}
+
+// Set overrides a flag in scope. target is the tenant ID or key ID the
+// override applies to, and is empty for the global scope
+func (s *Store) Set(name, scope, target string, enabled bool, defaults map[string]bool) (*Status, error) {
+	definition, ok := definition(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFlag, name)
+	}
+	if err := validTarget(scope, target); err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	flag := s.flags[name]
+	if flag == nil {
+		flag = &Flag{Name: name}
+		s.flags[name] = flag
+	}
+	switch scope {
+	case ScopeGlobal:
+		flag.Enabled = &enabled
+	case ScopeTenant:
+		if flag.Tenants == nil {
+			flag.Tenants = make(map[string]bool)
+		}
+		flag.Tenants[target] = enabled
+	case ScopeKey:
+		if flag.Keys == nil {
+			flag.Keys = make(map[string]bool)
+		}
+		flag.Keys[target] = enabled
+	}
+	return s.status(definition, defaults), s.save()
+}
+
+// Clear removes the override of a flag in scope, so the next broader scope
+// decides again
+func (s *Store) Clear(name, scope, target string, defaults map[string]bool) (*Status, error) {
+	definition, ok := definition(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFlag, name)
+	}
+	if err := validTarget(scope, target); err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	flag := s.flags[name]
+	if flag == nil {
+		return nil, ErrNoOverride
+	}
+	switch scope {
+	case ScopeGlobal:
+		if flag.Enabled == nil {
+			return nil, ErrNoOverride
+		}
+		flag.Enabled = nil
+	case ScopeTenant:
+		if _, ok := flag.Tenants[target]; !ok {
+			return nil, ErrNoOverride
+		}
+		delete(flag.Tenants, target)
+	case ScopeKey:
+		if _, ok := flag.Keys[target]; !ok {
+			return nil, ErrNoOverride
+		}
+		delete(flag.Keys, target)
+	}
+	if flag.Enabled == nil && len(flag.Tenants) == 0 && len(flag.Keys) == 0 {
+		delete(s.flags, name)
+	}
+	return s.status(definition, defaults), s.save()
+}
+
+// status returns a copy of a flag's overrides; callers hold the lock
+func (s *Store) status(definition Definition, defaults map[string]bool) *Status {
+	status := &Status{Name: definition.Name, Description: definition.Description, Default: defaults[definition.Name]}
+	if flag := s.flags[definition.Name]; flag != nil {
+		if flag.Enabled != nil {
+			enabled := *flag.Enabled
+			status.Enabled = &enabled
+		}
+		status.Tenants = copyTargets(flag.Tenants)
+		status.Keys = copyTargets(flag.Keys)
+	}
+	return status
+}
+
+// save writes the overrides to the store file; callers hold the lock
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	flags := make([]*Flag, 0, len(s.flags))
+	for _, flag := range s.flags {
+		flags = append(flags, flag)
+	}
+	sort.Slice(flags, func(i, j int) bool {
+		return flags[i].Name < flags[j].Name
+	})
+	data, err := json.MarshalIndent(flags, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	// Write to a temporary file first so a crash never leaves a partial file
+	if err := os.WriteFile(s.path+".tmp", data, 0600); err != nil {
+		return fmt.Errorf("failed to write feature flags: %w", err)
+	}
+	if err := os.Rename(s.path+".tmp", s.path); err != nil {
+		return fmt.Errorf("failed to write feature flags: %w", err)
+	}
+	return nil
+}
+
+// definition returns the definition of the flag named name
+func definition(name string) (Definition, bool) {
+	for _, definition := range Definitions {
+		if definition.Name == name {
+			return definition, true
+		}
+	}
+	return Definition{}, false
+}
+
+// validTarget checks the target of an override in scope
+func validTarget(scope, target string) error {
+	switch scope {
+	case ScopeGlobal:
+		if target != "" {
+			return fmt.Errorf("global overrides take no target")
+		}
+		return nil
+	case ScopeTenant, ScopeKey:
+	default:
+		return fmt.Errorf("unknown scope %q: must be %s, %s or %s", scope, ScopeGlobal, ScopeTenant, ScopeKey)
+	}
+	switch {
+	case strings.TrimSpace(target) == "":
+		return fmt.Errorf("%s overrides need a target", scope)
+	case len(target) > maxTargetLength:
+		return fmt.Errorf("%s override target must be at most %d characters", scope, maxTargetLength)
+	case strings.IndexFunc(target, unicode.IsControl) >= 0:
+		return fmt.Errorf("%s override target contains control characters", scope)
+	}
+	return nil
+}
+
+// copyTargets copies the per-tenant or per-key overrides of a flag
+func copyTargets(targets map[string]bool) map[string]bool {
+	if len(targets) == 0 {
+		return nil
+	}
+	copied := make(map[string]bool, len(targets))
+	for target, enabled := range targets {
+		copied[target] = enabled
+	}
+	return copied
+}
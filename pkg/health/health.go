@@ -0,0 +1,70 @@
+// Package health reports whether the gateway and the dependencies it needs
+// to serve requests are working
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Report and check statuses
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+	StatusWarn     = "warn"
+	StatusFailed   = "failed"
+)
+
+// Check is the outcome of checking one dependency
+type Check struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Report collects the checks of one readiness probe. It is degraded when any
+// check failed
+type Report struct {
+	Status    string  `json:"status"`
+	Timestamp int64   `json:"timestamp"`
+	Checks    []Check `json:"checks"`
+}
+
+// NewReport creates an empty report, ok until a check fails
+func NewReport() *Report {
+	return &Report{Status: StatusOK, Timestamp: time.Now().Unix(), Checks: []Check{}}
+}
+
+// Pass records a successful check
+func (r *Report) Pass(name, detail string) {
+	r.Checks = append(r.Checks, Check{Name: name, Status: StatusOK, Detail: detail})
+}
+
+// Warn records a check that found a problem the gateway can serve through
+func (r *Report) Warn(name, detail string) {
+	r.Checks = append(r.Checks, Check{Name: name, Status: StatusWarn, Detail: detail})
+}
+
+// Fail records a failed check and marks the report degraded
+func (r *Report) Fail(name, detail string) {
+	r.Checks = append(r.Checks, Check{Name: name, Status: StatusFailed, Detail: detail})
+	r.Status = StatusDegraded
+}
+
+// Ready reports whether no check failed
+func (r *Report) Ready() bool {
+	return r.Status == StatusOK
+}
+
+// Write answers with the report, 200 when ready and 503 otherwise
+func Write(w http.ResponseWriter, r *Report) {
+	status := http.StatusOK
+	if !r.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(r)
+}
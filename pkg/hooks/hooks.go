@@ -0,0 +1,304 @@
+// Package hooks runs operator code at four points of the request lifecycle:
+// when a request arrives, when a provider is selected, when the response is
+// ready and when the request fails. Hooks are Go code registered with
+// Register, Go plugins or webhooks, configured in a YAML file
+package hooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/logging"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/requestid"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
+	"gopkg.in/yaml.v3"
+)
+
+// logger is the hooks module logger, configurable via LOG_MODULES=hooks=<level>
+var logger = logging.Module("hooks")
+
+var (
+	// ErrRejected is returned when an OnRequest or OnResponse hook refuses a
+	// request
+	ErrRejected = errors.New("request rejected by hook")
+	// ErrVetoed is returned when OnSelect hooks veto every capable provider
+	ErrVetoed = errors.New("provider vetoed by hook")
+)
+
+// Event names a point of the request lifecycle
+type Event string
+
+const (
+	// EventRequest runs before analysis; hooks may rewrite the request
+	EventRequest Event = "request"
+	// EventSelect runs for each provider selection; hooks may veto it
+	EventSelect Event = "select"
+	// EventResponse runs before the response is returned; hooks may rewrite it
+	EventResponse Event = "response"
+	// EventError runs when the request fails; hooks only observe it
+	EventError Event = "error"
+)
+
+// events lists the valid events
+var events = []Event{EventRequest, EventSelect, EventResponse, EventError}
+
+// Request is a request as hooks see it. OnRequest hooks may change Content,
+// Model and Mode; hooks of every event may add Annotations, which are
+// returned in the response metadata
+type Request struct {
+	ID          string                 `json:"id"`
+	KeyID       string                 `json:"key_id,omitempty"`
+	Tenant      string                 `json:"tenant,omitempty"`
+	Mode        string                 `json:"mode,omitempty"`
+	Model       string                 `json:"model,omitempty"`
+	Content     string                 `json:"content"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	Annotations map[string]interface{} `json:"annotations,omitempty"`
+}
+
+// Annotate records value under key in the request's annotations
+func (r *Request) Annotate(key string, value interface{}) {
+	if r.Annotations == nil {
+		r.Annotations = make(map[string]interface{})
+	}
+	r.Annotations[key] = value
+}
+
+// Selection is a provider chosen for a request
+type Selection struct {
+	Provider      string    `json:"provider"`
+	Tier          tier.Tier `json:"tier"`
+	Model         string    `json:"model"`
+	EstimatedCost float64   `json:"estimated_cost"`
+	// Draft is set when the provider only drafts a speculative answer
+	Draft bool `json:"draft,omitempty"`
+}
+
+// Response is an answer as hooks see it. OnResponse hooks may change Content
+type Response struct {
+	Provider   string  `json:"provider"`
+	Model      string  `json:"model"`
+	Content    string  `json:"content"`
+	TokensUsed int64   `json:"tokens_used"`
+	Cost       float64 `json:"cost"`
+}
+
+// Hook receives lifecycle events. An error from OnRequest or OnResponse
+// rejects the request, and one from OnSelect vetoes the provider, which is
+// excluded before selecting again. Embed Base to implement only some events
+type Hook interface {
+	OnRequest(ctx context.Context, request *Request) error
+	OnSelect(ctx context.Context, request *Request, selection Selection) error
+	OnResponse(ctx context.Context, request *Request, response *Response) error
+	OnError(ctx context.Context, request *Request, err error)
+}
+
+// Base implements every event of Hook as a no-op
+type Base struct{}
+
+// OnRequest implements Hook
+func (Base) OnRequest(context.Context, *Request) error { return nil }
+
+// OnSelect implements Hook
+func (Base) OnSelect(context.Context, *Request, Selection) error { return nil }
+
+// OnResponse implements Hook
+func (Base) OnResponse(context.Context, *Request, *Response) error { return nil }
+
+// OnError implements Hook
+func (Base) OnError(context.Context, *Request, error) {}
+
+// Spec configures one hook
+type Spec struct {
+	Name string `yaml:"name" json:"name"`
+	// Type is webhook, plugin or the name a Go hook was registered under
+	Type string `yaml:"type" json:"type"`
+	// Events limits the hook to some events; empty means all of them
+	Events []Event `yaml:"events,omitempty" json:"events,omitempty"`
+
+	// Webhooks
+	URL     string            `yaml:"url,omitempty" json:"url,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty" json:"-"`
+	Timeout time.Duration     `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	// FailOpen lets requests continue when the webhook cannot be reached or
+	// answers with an error status; by default they fail
+	FailOpen bool `yaml:"fail_open,omitempty" json:"fail_open,omitempty"`
+
+	// Plugins
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// Options are passed to plugins and registered hooks
+	Options map[string]interface{} `yaml:"options,omitempty" json:"-"`
+}
+
+// Build creates the hook described by the spec
+func (s Spec) Build() (Hook, error) {
+	switch s.Type {
+	case TypeWebhook:
+		return NewWebhook(s)
+	case TypePlugin:
+		return openPlugin(s.Path, s.Options)
+	}
+	factory, ok := registered(s.Type)
+	if !ok {
+		return nil, fmt.Errorf("unknown hook type %q: must be %s, %s or a registered hook (%s)", s.Type, TypeWebhook, TypePlugin, strings.Join(Registered(), ", "))
+	}
+	return factory(s.Options)
+}
+
+// entry is a configured hook and the events it receives
+type entry struct {
+	name   string
+	hook   Hook
+	events map[Event]bool
+}
+
+// receives reports whether the hook is configured for event
+func (e *entry) receives(event Event) bool {
+	return len(e.events) == 0 || e.events[event]
+}
+
+// Hooks runs configured hooks in order. A nil *Hooks runs none
+type Hooks struct {
+	entries []entry
+	specs   []Spec
+}
+
+// New builds the hooks described by specs
+func New(specs []Spec) (*Hooks, error) {
+	hooks := &Hooks{specs: specs}
+	names := make(map[string]bool)
+	for i, spec := range specs {
+		name := spec.Name
+		if name == "" {
+			return nil, fmt.Errorf("hook %d: name is required", i+1)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("hook %s: defined twice", name)
+		}
+		names[name] = true
+
+		entry := entry{name: name}
+		for _, event := range spec.Events {
+			if !validEvent(event) {
+				return nil, fmt.Errorf("hook %s: unknown event %q", name, event)
+			}
+			if entry.events == nil {
+				entry.events = make(map[Event]bool)
+			}
+			entry.events[event] = true
+		}
+		hook, err := spec.Build()
+		if err != nil {
+			return nil, fmt.Errorf("hook %s: %w", name, err)
+		}
+		entry.hook = hook
+		hooks.entries = append(hooks.entries, entry)
+	}
+	return hooks, nil
+}
+
+// Load reads hooks from a YAML file with a top-level "hooks" list
+func Load(path string) (*Hooks, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hooks: %w", err)
+	}
+
+	var file struct {
+		Hooks []Spec `yaml:"hooks"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse hooks %s: %w", path, err)
+	}
+	return New(file.Hooks)
+}
+
+// Specs returns the configured hooks in run order
+func (h *Hooks) Specs() []Spec {
+	if h == nil {
+		return nil
+	}
+	return append([]Spec(nil), h.specs...)
+}
+
+// Request runs the OnRequest hooks, stopping at the first that rejects the
+// request
+func (h *Hooks) Request(ctx context.Context, request *Request) error {
+	for _, entry := range h.receiving(EventRequest) {
+		if err := entry.hook.OnRequest(ctx, request); err != nil {
+			return rejection(ErrRejected, entry.name, err)
+		}
+	}
+	return nil
+}
+
+// Select runs the OnSelect hooks, returning an error wrapping ErrVetoed from
+// the first that vetoes the selection
+func (h *Hooks) Select(ctx context.Context, request *Request, selection Selection) error {
+	for _, entry := range h.receiving(EventSelect) {
+		if err := entry.hook.OnSelect(ctx, request, selection); err != nil {
+			return rejection(ErrVetoed, entry.name, err)
+		}
+	}
+	return nil
+}
+
+// Response runs the OnResponse hooks, stopping at the first that rejects the
+// response
+func (h *Hooks) Response(ctx context.Context, request *Request, response *Response) error {
+	for _, entry := range h.receiving(EventResponse) {
+		if err := entry.hook.OnResponse(ctx, request, response); err != nil {
+			return rejection(ErrRejected, entry.name, err)
+		}
+	}
+	return nil
+}
+
+// Error runs every OnError hook
+func (h *Hooks) Error(ctx context.Context, request *Request, err error) {
+	for _, entry := range h.receiving(EventError) {
+		entry.hook.OnError(ctx, request, err)
+	}
+}
+
+// receiving returns the hooks configured for event
+func (h *Hooks) receiving(event Event) []entry {
+	if h == nil {
+		return nil
+	}
+	var entries []entry
+	for _, entry := range h.entries {
+		if entry.receives(event) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// rejection wraps a hook's error in sentinel unless it already is one
+func rejection(sentinel error, name string, err error) error {
+	if errors.Is(err, sentinel) {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return fmt.Errorf("%w: %s: %v", sentinel, name, err)
+}
+
+// validEvent reports whether event is one of the lifecycle events
+func validEvent(event Event) bool {
+	for _, known := range events {
+		if event == known {
+			return true
+		}
+	}
+	return false
+}
+
+// logFailure logs a hook failure that was let through
+func logFailure(ctx context.Context, name string, event Event, err error) {
+	logger.WithField(requestid.Field, requestid.FromContext(ctx)).Warnf("Hook %s failed on %s, continuing: %v", name, event, err)
+}
@@ -0,0 +1,91 @@
+package hooks
+
+import (
+	"fmt"
+	"plugin"
+	"sort"
+	"sync"
+)
+
+// Hook types besides registered ones
+const (
+	TypeWebhook = "webhook"
+	TypePlugin  = "plugin"
+)
+
+// PluginSymbol is the Factory a Go plugin exports
+const PluginSymbol = "NewHook"
+
+// Factory creates a hook from the options of its spec
+type Factory func(options map[string]interface{}) (Hook, error)
+
+var (
+	factoriesMutex sync.RWMutex
+	factories      = make(map[string]Factory)
+)
+
+// Register makes a Go hook available as hook type name. Call it from the
+// init function of a package linked into the gateway, like a database/sql
+// driver. Registering a name twice, or a built-in type, panics
+func Register(name string, factory Factory) {
+	factoriesMutex.Lock()
+	defer factoriesMutex.Unlock()
+
+	if name == TypeWebhook || name == TypePlugin {
+		panic("hooks: cannot register built-in type " + name)
+	}
+	if _, exists := factories[name]; exists {
+		panic("hooks: Register called twice for " + name)
+	}
+	factories[name] = factory
+}
+
+// Registered returns the names of the registered hook types, sorted
+func Registered() []string {
+	factoriesMutex.RLock()
+	defer factoriesMutex.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// registered returns the factory of hook type name
+func registered(name string) (Factory, bool) {
+	factoriesMutex.RLock()
+	defer factoriesMutex.RUnlock()
+
+	factory, ok := factories[name]
+	return factory, ok
+}
+
+// openPlugin loads a Go plugin built with -buildmode=plugin and creates its
+// hook with the exported PluginSymbol. Plugins must be built with the same Go
+// toolchain and module versions as the gateway, and need cgo
+func openPlugin(path string, options map[string]interface{}) (Hook, error) {
+	if path == "" {
+		return nil, fmt.Errorf("plugin hooks need a path")
+	}
+	opened, err := plugin.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open plugin: %w", err)
+	}
+	symbol, err := opened.Lookup(PluginSymbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open plugin %s: %w", path, err)
+	}
+
+	// Plugins may export the function itself or a variable holding it
+	switch factory := symbol.(type) {
+	case func(map[string]interface{}) (Hook, error):
+		return factory(options)
+	case *func(map[string]interface{}) (Hook, error):
+		return (*factory)(options)
+	case *Factory:
+		return (*factory)(options)
+	}
+	return nil, fmt.Errorf("plugin %s: %s is a %T, not a hooks.Factory", path, PluginSymbol, symbol)
+}
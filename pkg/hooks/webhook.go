@@ -0,0 +1,190 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/requestid"
+)
+
+// DefaultWebhookTimeout bounds a webhook call when its spec sets no timeout
+const DefaultWebhookTimeout = 5 * time.Second
+
+// WebhookCall is the JSON body POSTed to a webhook
+type WebhookCall struct {
+	Event     Event      `json:"event"`
+	Hook      string     `json:"hook"`
+	Request   *Request   `json:"request"`
+	Selection *Selection `json:"selection,omitempty"`
+	Response  *Response  `json:"response,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// WebhookReply is what a webhook may answer with. An empty body, or a 204,
+// changes nothing. Content replaces the request content on request events
+// and the response content on response events; Model and Mode only apply to
+// request events. Reject rejects the request, or vetoes the provider on
+// select events
+type WebhookReply struct {
+	Content     *string                `json:"content,omitempty"`
+	Model       *string                `json:"model,omitempty"`
+	Mode        *string                `json:"mode,omitempty"`
+	Annotations map[string]interface{} `json:"annotations,omitempty"`
+	Reject      string                 `json:"reject,omitempty"`
+}
+
+// Webhook is a hook that POSTs every event to a URL
+type Webhook struct {
+	name       string
+	url        string
+	headers    map[string]string
+	failOpen   bool
+	httpClient *http.Client
+}
+
+// NewWebhook creates the webhook described by spec. ${VAR} references in
+// its URL and headers are resolved from the environment
+func NewWebhook(spec Spec) (*Webhook, error) {
+	url, err := config.ExpandEnv(spec.URL)
+	if err != nil {
+		return nil, err
+	}
+	if url == "" {
+		return nil, fmt.Errorf("webhook hooks need a url")
+	}
+	headers := make(map[string]string, len(spec.Headers))
+	for name, value := range spec.Headers {
+		if headers[name], err = config.ExpandEnv(value); err != nil {
+			return nil, fmt.Errorf("header %s: %w", name, err)
+		}
+	}
+	timeout := spec.Timeout
+	if timeout <= 0 {
+		timeout = DefaultWebhookTimeout
+	}
+	return &Webhook{
+		name:       spec.Name,
+		url:        url,
+		headers:    headers,
+		failOpen:   spec.FailOpen,
+		httpClient: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// OnRequest implements Hook
+func (wh *Webhook) OnRequest(ctx context.Context, request *Request) error {
+	reply, err := wh.call(ctx, WebhookCall{Event: EventRequest, Request: request})
+	if err != nil || reply == nil {
+		return err
+	}
+	if reply.Content != nil {
+		request.Content = *reply.Content
+	}
+	if reply.Model != nil {
+		request.Model = *reply.Model
+	}
+	if reply.Mode != nil {
+		request.Mode = *reply.Mode
+	}
+	return wh.apply(request, reply)
+}
+
+// OnSelect implements Hook
+func (wh *Webhook) OnSelect(ctx context.Context, request *Request, selection Selection) error {
+	reply, err := wh.call(ctx, WebhookCall{Event: EventSelect, Request: request, Selection: &selection})
+	if err != nil || reply == nil {
+		return err
+	}
+	return wh.apply(request, reply)
+}
+
+// OnResponse implements Hook
+func (wh *Webhook) OnResponse(ctx context.Context, request *Request, response *Response) error {
+	reply, err := wh.call(ctx, WebhookCall{Event: EventResponse, Request: request, Response: response})
+	if err != nil || reply == nil {
+		return err
+	}
+	if reply.Content != nil {
+		response.Content = *reply.Content
+	}
+	return wh.apply(request, reply)
+}
+
+// OnError implements Hook; failures are only logged
+func (wh *Webhook) OnError(ctx context.Context, request *Request, err error) {
+	if _, callErr := wh.call(ctx, WebhookCall{Event: EventError, Request: request, Error: err.Error()}); callErr != nil {
+		logFailure(ctx, wh.name, EventError, callErr)
+	}
+}
+
+// apply merges the reply's annotations and returns its rejection
+func (wh *Webhook) apply(request *Request, reply *WebhookReply) error {
+	for key, value := range reply.Annotations {
+		request.Annotate(key, value)
+	}
+	if reply.Reject != "" {
+		return fmt.Errorf("%s", reply.Reject)
+	}
+	return nil
+}
+
+// call posts an event and decodes the reply, nil when the webhook changes
+// nothing. Failures to reach the webhook are errors unless it fails open
+func (wh *Webhook) call(ctx context.Context, call WebhookCall) (*WebhookReply, error) {
+	call.Hook = wh.name
+	reply, err := wh.post(ctx, call)
+	if err != nil && wh.failOpen {
+		logFailure(ctx, wh.name, call.Event, err)
+		return nil, nil
+	}
+	return reply, err
+}
+
+// post sends call to the webhook
+func (wh *Webhook) post(ctx context.Context, call WebhookCall) (*WebhookReply, error) {
+	body, err := json.Marshal(call)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Your-PaL-MoE/1.0")
+	req.Header.Set("X-Hook-Event", string(call.Event))
+	if id := requestid.FromContext(ctx); id != "" {
+		req.Header.Set(requestid.Header, id)
+	}
+	for name, value := range wh.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := wh.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("webhook unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook reply: %w", err)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+	var reply WebhookReply
+	if err := json.Unmarshal(data, &reply); err != nil {
+		return nil, fmt.Errorf("invalid webhook reply: %w", err)
+	}
+	return &reply, nil
+}
@@ -0,0 +1,337 @@
+// Package incident records provider outages as incidents: when a provider
+// turned unhealthy and recovered, the requests it failed meanwhile, the
+// requests routed around it and what routing around it cost, so outages can
+// be reviewed after the fact
+package incident
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/logging"
+)
+
+var logger = logging.Module("incident")
+
+// DefaultLimit is how many incidents are kept before the oldest resolved
+// ones are dropped
+const DefaultLimit = 500
+
+// maxTimelineEntries bounds the timeline of one incident; its counters keep
+// counting beyond it
+const maxTimelineEntries = 200
+
+// ErrNotFound is returned for incident IDs the log does not hold
+var ErrNotFound = errors.New("incident not found")
+
+// Incident statuses
+const (
+	StatusOpen     = "open"
+	StatusResolved = "resolved"
+)
+
+// Kinds of timeline entries
+const (
+	EntryOpened   = "opened"
+	EntryFailed   = "request_failed"
+	EntryFailover = "failover"
+	EntryResolved = "resolved"
+)
+
+// Entry is one moment of an incident
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Kind      string    `json:"kind"`
+	RequestID string    `json:"request_id,omitempty"`
+	// Provider is the provider a failover went to
+	Provider string `json:"provider,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+// Incident is one outage of a provider, from the request that made it
+// unhealthy to the one it recovered with
+type Incident struct {
+	ID        int64      `json:"id"`
+	Provider  string     `json:"provider"`
+	Status    string     `json:"status"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	// ErrorRate is the provider's error rate when the incident opened
+	ErrorRate float64 `json:"error_rate"`
+	// AffectedRequests were sent to the provider during the incident, and
+	// FailedRequests of them failed
+	AffectedRequests int64 `json:"affected_requests"`
+	FailedRequests   int64 `json:"failed_requests"`
+	// Failovers are requests that had the provider as a candidate and were
+	// served by another one
+	Failovers int64 `json:"failovers"`
+	// ExtraCost is what the failovers cost above the provider's own price
+	// for the same tokens, in USD; negative when they were cheaper
+	ExtraCost         float64 `json:"extra_cost"`
+	Timeline          []Entry `json:"timeline,omitempty"`
+	TimelineTruncated bool    `json:"timeline_truncated,omitempty"`
+}
+
+// Duration is how long the incident lasted, or has lasted until now
+func (i *Incident) Duration(now time.Time) time.Duration {
+	if i.EndedAt != nil {
+		return i.EndedAt.Sub(i.StartedAt)
+	}
+	return now.Sub(i.StartedAt)
+}
+
+// add appends entry to the timeline unless it is full
+func (i *Incident) add(entry Entry) {
+	if len(i.Timeline) >= maxTimelineEntries {
+		i.TimelineTruncated = true
+		return
+	}
+	i.Timeline = append(i.Timeline, entry)
+}
+
+// copy returns a copy that shares nothing with i
+func (i *Incident) copy() Incident {
+	c := *i
+	c.Timeline = append([]Entry(nil), i.Timeline...)
+	if i.EndedAt != nil {
+		ended := *i.EndedAt
+		c.EndedAt = &ended
+	}
+	return c
+}
+
+// Query filters the incident log. Empty fields match every incident
+type Query struct {
+	Provider string
+	Status   string
+	Since    time.Time
+}
+
+// matches reports whether incident passes the filters of q
+func (q Query) matches(incident *Incident) bool {
+	switch {
+	case q.Provider != "" && !strings.EqualFold(incident.Provider, q.Provider),
+		q.Status != "" && incident.Status != q.Status,
+		!q.Since.IsZero() && incident.EndedAt != nil && incident.EndedAt.Before(q.Since):
+		return false
+	}
+	return true
+}
+
+// Log keeps the incidents of every provider, and with a path saves them as
+// a JSON file whenever one opens or resolves
+type Log struct {
+	path      string
+	limit     int
+	incidents []*Incident
+	// open holds the open incident of each provider, by lower-cased name
+	open   map[string]*Incident
+	lastID int64
+	mutex  sync.RWMutex
+}
+
+// NewMemoryLog creates a log that keeps incidents in memory only
+func NewMemoryLog() *Log {
+	return &Log{
+		limit: DefaultLimit,
+		open:  make(map[string]*Incident),
+	}
+}
+
+// NewLog creates a log saved at path, loading the incidents already there
+func NewLog(path string) (*Log, error) {
+	log := NewMemoryLog()
+	log.path = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return log, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read incidents: %w", err)
+	}
+	if err := json.Unmarshal(data, &log.incidents); err != nil {
+		return nil, fmt.Errorf("failed to parse incidents %s: %w", path, err)
+	}
+	for _, incident := range log.incidents {
+		if incident.ID > log.lastID {
+			log.lastID = incident.ID
+		}
+		if incident.Status == StatusOpen {
+			log.open[strings.ToLower(incident.Provider)] = incident
+		}
+	}
+	return log, nil
+}
+
+// Open opens an incident for provider, which turned unhealthy at with
+// errorRate. A provider has at most one open incident
+func (l *Log) Open(provider string, at time.Time, errorRate float64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	key := strings.ToLower(provider)
+	if l.open[key] != nil {
+		return
+	}
+	l.lastID++
+	incident := &Incident{
+		ID:        l.lastID,
+		Provider:  provider,
+		Status:    StatusOpen,
+		StartedAt: at,
+		ErrorRate: errorRate,
+	}
+	incident.add(Entry{Time: at, Kind: EntryOpened, Detail: fmt.Sprintf("error rate %.1f%%", errorRate*100)})
+	l.open[key] = incident
+	l.incidents = append(l.incidents, incident)
+	l.prune()
+	logger.Warnf("Opened incident %d: %s is unhealthy at a %.1f%% error rate", incident.ID, provider, errorRate*100)
+	l.save()
+}
+
+// Resolve closes the open incident of provider, which recovered at
+func (l *Log) Resolve(provider string, at time.Time) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	key := strings.ToLower(provider)
+	incident := l.open[key]
+	if incident == nil {
+		return
+	}
+	delete(l.open, key)
+	incident.Status = StatusResolved
+	incident.EndedAt = &at
+	incident.add(Entry{Time: at, Kind: EntryResolved})
+	logger.Infof("Resolved incident %d of %s after %s: %d requests affected, %d failovers, $%.4f extra",
+		incident.ID, provider, incident.Duration(at).Round(time.Second), incident.AffectedRequests, incident.Failovers, incident.ExtraCost)
+	l.save()
+}
+
+// RecordRequest counts a request sent to provider during its incident, if
+// one is open
+func (l *Log) RecordRequest(provider, requestID string, at time.Time, err error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	incident := l.open[strings.ToLower(provider)]
+	if incident == nil {
+		return
+	}
+	incident.AffectedRequests++
+	if err != nil {
+		incident.FailedRequests++
+		incident.add(Entry{Time: at, Kind: EntryFailed, RequestID: requestID, Detail: err.Error()})
+	}
+}
+
+// RecordFailover counts a request that had provider as a candidate during
+// its incident and went to another provider, at extraCost over provider's
+// price. It reports whether provider has an open incident
+func (l *Log) RecordFailover(provider, to, requestID string, at time.Time, extraCost float64) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	incident := l.open[strings.ToLower(provider)]
+	if incident == nil {
+		return false
+	}
+	incident.Failovers++
+	incident.ExtraCost += extraCost
+	incident.add(Entry{Time: at, Kind: EntryFailover, RequestID: requestID, Provider: to})
+	return true
+}
+
+// IsOpen reports whether provider has an open incident
+func (l *Log) IsOpen(provider string) bool {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return l.open[strings.ToLower(provider)] != nil
+}
+
+// List returns the incidents matching query, newest first, without their
+// timelines
+func (l *Log) List(query Query) []Incident {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	incidents := []Incident{}
+	for i := len(l.incidents) - 1; i >= 0; i-- {
+		if query.matches(l.incidents[i]) {
+			incident := *l.incidents[i]
+			incident.Timeline = nil
+			incidents = append(incidents, incident)
+		}
+	}
+	return incidents
+}
+
+// Get returns the incident with id and its timeline
+func (l *Log) Get(id int64) (*Incident, error) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	for _, incident := range l.incidents {
+		if incident.ID == id {
+			c := incident.copy()
+			return &c, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %d", ErrNotFound, id)
+}
+
+// Flush saves the counters of open incidents, which are otherwise saved
+// only when an incident opens or resolves
+func (l *Log) Flush() {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	l.save()
+}
+
+// prune drops the oldest resolved incidents beyond the limit; callers hold
+// the mutex
+func (l *Log) prune() {
+	excess := len(l.incidents) - l.limit
+	if excess <= 0 {
+		return
+	}
+	kept := l.incidents[:0]
+	for _, incident := range l.incidents {
+		if excess > 0 && incident.Status == StatusResolved {
+			excess--
+			continue
+		}
+		kept = append(kept, incident)
+	}
+	l.incidents = kept
+}
+
+// save writes the incidents to the path, if any; callers hold the mutex.
+// Failures are logged, since incidents are recorded from the request path
+func (l *Log) save() {
+	if l.path == "" {
+		return
+	}
+	incidents := append([]*Incident(nil), l.incidents...)
+	sort.Slice(incidents, func(i, j int) bool { return incidents[i].ID < incidents[j].ID })
+	data, err := json.MarshalIndent(incidents, "", "  ")
+	if err == nil {
+		if err = os.MkdirAll(filepath.Dir(l.path), 0755); err == nil {
+			// Write to a temporary file first so readers never see a partial log
+			if err = os.WriteFile(l.path+".tmp", data, 0644); err == nil {
+				err = os.Rename(l.path+".tmp", l.path)
+			}
+		}
+	}
+	if err != nil {
+		logger.Warnf("Failed to save incidents to %s: %v", l.path, err)
+	}
+}
@@ -0,0 +1,138 @@
+// Package kube is a minimal Kubernetes API client authenticated with the
+// pod's service account, enough for the gateway to read its configuration
+// from the cluster and coordinate its replicas without client-go
+package kube
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// ServiceAccountDir holds the credentials Kubernetes mounts into every pod
+const ServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// APIError is a response of the API server other than 2xx
+type APIError struct {
+	StatusCode int
+	Status     string
+	Message    string
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	return fmt.Sprintf("kubernetes API returned %s: %s", e.Status, e.Message)
+}
+
+// IsStatus reports whether err is an APIError with the status code
+func IsStatus(err error, statusCode int) bool {
+	apiErr, ok := err.(*APIError)
+	return ok && apiErr.StatusCode == statusCode
+}
+
+// Client sends requests to the API server
+type Client struct {
+	baseURL   string
+	tokenPath string
+	client    *http.Client
+}
+
+// NewClient connects to apiServer, or to the in-cluster API server when it is
+// empty, and resolves an empty namespace to the pod's namespace
+func NewClient(apiServer, namespace string) (*Client, string, error) {
+	api := &Client{
+		baseURL: strings.TrimRight(apiServer, "/"),
+		client:  &http.Client{},
+	}
+
+	if api.baseURL == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return nil, "", fmt.Errorf("not running in a Kubernetes cluster and no API server URL configured")
+		}
+		api.baseURL = "https://" + net.JoinHostPort(host, port)
+		api.tokenPath = ServiceAccountDir + "/token"
+
+		ca, err := os.ReadFile(ServiceAccountDir + "/ca.crt")
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read service account CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, "", fmt.Errorf("service account CA contains no certificates")
+		}
+		api.client.Transport = &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+		}
+	}
+
+	if namespace == "" {
+		data, err := os.ReadFile(ServiceAccountDir + "/namespace")
+		if err != nil {
+			namespace = "default"
+		} else {
+			namespace = strings.TrimSpace(string(data))
+		}
+	}
+	return api, namespace, nil
+}
+
+// Get issues an authenticated GET and fails on any non-2xx response
+func (k *Client) Get(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	return k.Do(ctx, http.MethodGet, path, query, nil)
+}
+
+// Do issues an authenticated request with object, when not nil, as its JSON
+// body. Responses other than 2xx become *APIError
+func (k *Client) Do(ctx context.Context, method, path string, query url.Values, object any) (*http.Response, error) {
+	endpoint := k.baseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	var body io.Reader
+	if object != nil {
+		data, err := json.Marshal(object)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	// Bound service account tokens rotate, so the file is read on every call
+	if k.tokenPath != "" {
+		token, err := os.ReadFile(k.tokenPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read service account token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+string(bytes.TrimSpace(token)))
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes API request failed: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return nil, &APIError{StatusCode: resp.StatusCode, Status: resp.Status, Message: strings.TrimSpace(string(message))}
+	}
+	return resp, nil
+}
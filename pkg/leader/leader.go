@@ -0,0 +1,235 @@
+// Package leader elects one replica of the gateway through a Kubernetes
+// Lease to run the jobs that must not run once per replica, such as
+// delivering scheduled reports
+package leader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/kube"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/logging"
+)
+
+var logger = logging.Module("leader")
+
+// DefaultLeaseDuration is how long a lease is held without renewal
+const DefaultLeaseDuration = 15 * time.Second
+
+// microTime is the timestamp format of Lease fields
+const microTime = "2006-01-02T15:04:05.000000Z07:00"
+
+// Config names the Lease and the candidate
+type Config struct {
+	Namespace string
+	Name      string
+	// Identity names this replica in the lease, typically the pod name
+	Identity string
+	// LeaseDuration is how long other candidates wait for a silent leader;
+	// the leader renews at a third of it
+	LeaseDuration time.Duration
+}
+
+// lease holds the parts of a coordination.k8s.io/v1 Lease the elector uses
+type lease struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name            string `json:"name"`
+		Namespace       string `json:"namespace"`
+		ResourceVersion string `json:"resourceVersion,omitempty"`
+	} `json:"metadata"`
+	Spec struct {
+		HolderIdentity       string `json:"holderIdentity,omitempty"`
+		LeaseDurationSeconds int    `json:"leaseDurationSeconds,omitempty"`
+		AcquireTime          string `json:"acquireTime,omitempty"`
+		RenewTime            string `json:"renewTime,omitempty"`
+		LeaseTransitions     int    `json:"leaseTransitions,omitempty"`
+	} `json:"spec"`
+}
+
+// Elector campaigns for a Lease and runs a function while it holds it
+type Elector struct {
+	api    *kube.Client
+	config Config
+	leader atomic.Bool
+}
+
+// NewElector creates an elector for the Lease of config. A non-positive
+// LeaseDuration takes the default
+func NewElector(api *kube.Client, config Config) *Elector {
+	if config.LeaseDuration <= 0 {
+		config.LeaseDuration = DefaultLeaseDuration
+	}
+	return &Elector{api: api, config: config}
+}
+
+// IsLeader reports whether this replica holds the lease
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Run campaigns until ctx is done. Each time this replica becomes the leader
+// lead is started with a context that is cancelled when leadership is lost,
+// so lead must return promptly once it is. The lease is released when ctx is
+// done, letting another replica take over without waiting for it to expire
+func (e *Elector) Run(ctx context.Context, lead func(ctx context.Context)) {
+	ticker := time.NewTicker(e.config.LeaseDuration / 3)
+	defer ticker.Stop()
+
+	stop := func() {}
+	defer func() { stop() }()
+	var renewed time.Time
+	for {
+		held, err := e.tryAcquireOrRenew(ctx)
+		now := time.Now()
+		switch {
+		case err != nil && ctx.Err() == nil:
+			logger.Warnf("Failed to renew lease %s/%s: %v", e.config.Namespace, e.config.Name, err)
+			// A leader that cannot reach the API server steps down before its
+			// lease expires and another replica may take over
+			held = e.IsLeader() && now.Sub(renewed) < e.config.LeaseDuration*2/3
+		case held:
+			renewed = now
+		}
+
+		if held && !e.IsLeader() {
+			logger.Infof("Became leader of %s/%s as %s", e.config.Namespace, e.config.Name, e.config.Identity)
+			e.leader.Store(true)
+			leadCtx, cancel := context.WithCancel(ctx)
+			stop = cancel
+			go lead(leadCtx)
+		} else if !held && e.IsLeader() {
+			logger.Warnf("Lost leadership of %s/%s", e.config.Namespace, e.config.Name)
+			e.leader.Store(false)
+			stop()
+		}
+
+		select {
+		case <-ctx.Done():
+			if e.IsLeader() {
+				e.leader.Store(false)
+				stop()
+				e.release()
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// tryAcquireOrRenew takes the lease when it is free or expired, or renews it
+// when this replica holds it, and reports whether this replica holds it
+func (e *Elector) tryAcquireOrRenew(ctx context.Context) (bool, error) {
+	now := time.Now()
+	current, err := e.get(ctx)
+	if kube.IsStatus(err, http.StatusNotFound) {
+		next := e.newLease()
+		next.Spec.HolderIdentity = e.config.Identity
+		next.Spec.AcquireTime = now.UTC().Format(microTime)
+		next.Spec.RenewTime = next.Spec.AcquireTime
+		response, err := e.api.Do(ctx, http.MethodPost, e.collection(), nil, next)
+		if kube.IsStatus(err, http.StatusConflict) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		response.Body.Close()
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	holder := current.Spec.HolderIdentity
+	if holder != "" && holder != e.config.Identity && !e.expired(current, now) {
+		return false, nil
+	}
+	if holder != e.config.Identity {
+		current.Spec.AcquireTime = now.UTC().Format(microTime)
+		current.Spec.LeaseTransitions++
+	}
+	current.Spec.HolderIdentity = e.config.Identity
+	current.Spec.LeaseDurationSeconds = int(e.config.LeaseDuration.Seconds())
+	current.Spec.RenewTime = now.UTC().Format(microTime)
+	// The resource version makes the update fail when another replica
+	// changed the lease since it was read
+	response, err := e.api.Do(ctx, http.MethodPut, e.path(), nil, current)
+	if kube.IsStatus(err, http.StatusConflict) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	response.Body.Close()
+	return true, nil
+}
+
+// expired reports whether the holder of lease failed to renew it in time
+func (e *Elector) expired(lease *lease, now time.Time) bool {
+	renewed, err := time.Parse(microTime, lease.Spec.RenewTime)
+	if err != nil {
+		return true
+	}
+	return now.After(renewed.Add(time.Duration(lease.Spec.LeaseDurationSeconds) * time.Second))
+}
+
+// release gives up the lease so the next leader need not wait for it to
+// expire
+func (e *Elector) release() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	current, err := e.get(ctx)
+	if err != nil || current.Spec.HolderIdentity != e.config.Identity {
+		return
+	}
+	current.Spec.HolderIdentity = ""
+	current.Spec.LeaseDurationSeconds = 1
+	response, err := e.api.Do(ctx, http.MethodPut, e.path(), nil, current)
+	if err != nil {
+		logger.Warnf("Failed to release lease %s/%s: %v", e.config.Namespace, e.config.Name, err)
+		return
+	}
+	response.Body.Close()
+	logger.Infof("Released lease %s/%s", e.config.Namespace, e.config.Name)
+}
+
+// get reads the lease
+func (e *Elector) get(ctx context.Context) (*lease, error) {
+	response, err := e.api.Get(ctx, e.path(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	var current lease
+	if err := json.NewDecoder(response.Body).Decode(&current); err != nil {
+		return nil, fmt.Errorf("failed to decode lease: %w", err)
+	}
+	return &current, nil
+}
+
+// newLease returns an empty lease of config
+func (e *Elector) newLease() *lease {
+	next := &lease{APIVersion: "coordination.k8s.io/v1", Kind: "Lease"}
+	next.Metadata.Name = e.config.Name
+	next.Metadata.Namespace = e.config.Namespace
+	next.Spec.LeaseDurationSeconds = int(e.config.LeaseDuration.Seconds())
+	return next
+}
+
+// collection is the URL of the namespace's leases
+func (e *Elector) collection() string {
+	return "/apis/coordination.k8s.io/v1/namespaces/" + url.PathEscape(e.config.Namespace) + "/leases"
+}
+
+// path is the URL of the lease
+func (e *Elector) path() string {
+	return e.collection() + "/" + url.PathEscape(e.config.Name)
+}
@@ -0,0 +1,109 @@
+// Package learned holds what the gateway learns while it runs, such as
+// provider performance and token calibration, in a form that can be saved
+// across restarts and carried between environments
+package learned
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/speculative"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/usage"
+)
+
+// Version is the state format written by Save
+const Version = 1
+
+// maxStateBytes bounds the size of state read by Decode
+const maxStateBytes = 16 << 20
+
+// ErrInvalidState is returned for state that is malformed or of an
+// unsupported version
+var ErrInvalidState = errors.New("invalid learned state")
+
+// ProviderPerformance is the request outcome totals of one provider
+type ProviderPerformance struct {
+	Provider         string    `json:"provider"`
+	TotalRequests    int64     `json:"total_requests"`
+	FailedRequests   int64     `json:"failed_requests"`
+	AverageLatencyMs float64   `json:"average_latency_ms"`
+	LastUpdated      time.Time `json:"last_updated"`
+}
+
+// OptimizerStats are the prompt optimizer's counters
+type OptimizerStats struct {
+	TotalOptimizations int64 `json:"total_optimizations"`
+	CacheHits          int64 `json:"cache_hits"`
+}
+
+// State is a snapshot of everything learned at runtime. It holds no prompts
+// or responses
+type State struct {
+	Version           int                   `json:"version"`
+	CreatedAt         time.Time             `json:"created_at"`
+	Providers         []ProviderPerformance `json:"providers"`
+	TokenCalibrations []usage.Calibration   `json:"token_calibrations"`
+	Speculative       speculative.Stats     `json:"speculative"`
+	Optimizer         *OptimizerStats       `json:"optimizer,omitempty"`
+}
+
+// ImportReport says what an import restored. Skipped lists providers and
+// calibrations of providers that are not configured here
+type ImportReport struct {
+	Providers    int      `json:"providers"`
+	Calibrations int      `json:"calibrations"`
+	Skipped      []string `json:"skipped,omitempty"`
+}
+
+// Decode reads state written by Save or exported by the admin API
+func Decode(r io.Reader) (*State, error) {
+	var state State
+	decoder := json.NewDecoder(io.LimitReader(r, maxStateBytes))
+	if err := decoder.Decode(&state); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidState, err)
+	}
+	if state.Version != Version {
+		return nil, fmt.Errorf("%w: version %d, want %d", ErrInvalidState, state.Version, Version)
+	}
+	return &state, nil
+}
+
+// Load reads state from path. It returns nil and no error when the file does
+// not exist yet
+func Load(path string) (*State, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Decode(file)
+}
+
+// Save writes state to path, replacing the file only once it is complete
+func (s *State) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
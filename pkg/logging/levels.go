@@ -0,0 +1,209 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultModule is the pseudo-module name that addresses the default level
+const DefaultModule = "default"
+
+// Registry hands out one logger per module and lets their levels change at runtime.
+// Modules without an explicit level follow the default level
+type Registry struct {
+	defaultLevel logrus.Level
+	overrides    map[string]logrus.Level
+	loggers      map[string]*logrus.Logger
+	out          io.Writer
+	formatter    logrus.Formatter
+	hooks        []logrus.Hook
+	mutex        sync.RWMutex
+}
+
+// NewRegistry creates a registry writing to out at defaultLevel
+func NewRegistry(out io.Writer, defaultLevel logrus.Level) *Registry {
+	return &Registry{
+		defaultLevel: defaultLevel,
+		overrides:    make(map[string]logrus.Level),
+		loggers:      make(map[string]*logrus.Logger),
+		out:          out,
+		formatter:    &logrus.TextFormatter{FullTimestamp: true},
+	}
+}
+
+// Default is the process-wide registry used by library packages
+var Default = NewRegistry(os.Stderr, logrus.InfoLevel)
+
+// Module returns the logger for name from the default registry
+func Module(name string) *logrus.Logger {
+	return Default.Module(name)
+}
+
+// Module returns the logger for name, creating it on first use. Every entry it
+// writes carries a "module" field
+func (r *Registry) Module(name string) *logrus.Logger {
+	r.mutex.RLock()
+	logger, exists := r.loggers[name]
+	r.mutex.RUnlock()
+	if exists {
+		return logger
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if logger, exists := r.loggers[name]; exists {
+		return logger
+	}
+	logger = logrus.New()
+	logger.SetOutput(r.out)
+	logger.SetFormatter(r.formatter)
+	logger.SetLevel(r.levelFor(name))
+	logger.AddHook(moduleHook{module: name})
+	for _, hook := range r.hooks {
+		logger.AddHook(hook)
+	}
+	r.loggers[name] = logger
+	return logger
+}
+
+// SetFormatter changes the formatter of every current and future module logger
+func (r *Registry) SetFormatter(formatter logrus.Formatter) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.formatter = formatter
+	for _, logger := range r.loggers {
+		logger.SetFormatter(formatter)
+	}
+}
+
+// AddHook adds a hook to every current and future module logger
+func (r *Registry) AddHook(hook logrus.Hook) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.hooks = append(r.hooks, hook)
+	for _, logger := range r.loggers {
+		logger.AddHook(hook)
+	}
+}
+
+// SetLevel sets the level of a module, or the default level when module is
+// DefaultModule or empty
+func (r *Registry) SetLevel(module string, level logrus.Level) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if module == "" || module == DefaultModule {
+		r.defaultLevel = level
+	} else {
+		r.overrides[module] = level
+	}
+	r.applyLevels()
+}
+
+// ResetLevel drops a module's explicit level so it follows the default again
+func (r *Registry) ResetLevel(module string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.overrides, module)
+	r.applyLevels()
+}
+
+// Levels returns the effective level of every known module plus the default
+func (r *Registry) Levels() map[string]string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	levels := map[string]string{DefaultModule: r.defaultLevel.String()}
+	for name := range r.loggers {
+		levels[name] = r.levelFor(name).String()
+	}
+	for name, level := range r.overrides {
+		levels[name] = level.String()
+	}
+	return levels
+}
+
+// Configure applies a default level and a module list such as
+// "selection=debug,analytics=warn". Empty values leave the current setting
+func (r *Registry) Configure(defaultLevel, modules string) error {
+	if defaultLevel != "" {
+		level, err := logrus.ParseLevel(defaultLevel)
+		if err != nil {
+			return err
+		}
+		r.SetLevel(DefaultModule, level)
+	}
+
+	for _, spec := range strings.Split(modules, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		name, levelName, found := strings.Cut(spec, "=")
+		if !found {
+			return fmt.Errorf("invalid module level %q, expected module=level", spec)
+		}
+		level, err := logrus.ParseLevel(strings.TrimSpace(levelName))
+		if err != nil {
+			return fmt.Errorf("module %s: %w", name, err)
+		}
+		r.SetLevel(strings.TrimSpace(name), level)
+	}
+	return nil
+}
+
+// ModuleNames returns the names of all modules that have requested a logger
+func (r *Registry) ModuleNames() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	names := make([]string, 0, len(r.loggers))
+	for name := range r.loggers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// levelFor returns the effective level of a module; callers must hold the mutex
+func (r *Registry) levelFor(module string) logrus.Level {
+	if level, exists := r.overrides[module]; exists {
+		return level
+	}
+	return r.defaultLevel
+}
+
+// applyLevels pushes effective levels to existing loggers; callers must hold the mutex
+func (r *Registry) applyLevels() {
+	for name, logger := range r.loggers {
+		logger.SetLevel(r.levelFor(name))
+	}
+}
+
+// moduleHook tags entries with the module that produced them
+type moduleHook struct {
+	module string
+}
+
+// Levels reports that the hook applies to every level
+func (h moduleHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire adds the module field unless the caller already set one
+func (h moduleHook) Fire(entry *logrus.Entry) error {
+	if _, exists := entry.Data["module"]; !exists {
+		entry.Data["module"] = h.module
+	}
+	return nil
+}
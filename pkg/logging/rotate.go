@@ -0,0 +1,149 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// RotatingFile is an io.Writer that rolls its file over once it grows past maxBytes,
+// keeping up to maxBackups old files named path.1 (newest) through path.N, and
+// none older than maxAge when set
+type RotatingFile struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+	maxAge     time.Duration
+	file       *os.File
+	size       int64
+	mutex      sync.Mutex
+}
+
+// NewRotatingFile opens path for appending, creating parent directories as needed.
+// A maxBytes of zero disables size-based rotation
+func NewRotatingFile(path string, maxBytes int64, maxBackups int) (*RotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	rf := &RotatingFile{
+		path:       path,
+		maxBytes:   maxBytes,
+		maxBackups: maxBackups,
+	}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+// Write appends p to the current file, rotating first if p would exceed the size limit
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.mutex.Lock()
+	defer rf.mutex.Unlock()
+
+	if rf.maxBytes > 0 && rf.size+int64(len(p)) > rf.maxBytes && rf.size > 0 {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// SetMaxAge removes backups last written more than maxAge ago on every
+// rotation and purge; zero or less keeps them until maxBackups pushes them out
+func (rf *RotatingFile) SetMaxAge(maxAge time.Duration) {
+	rf.mutex.Lock()
+	defer rf.mutex.Unlock()
+	rf.maxAge = maxAge
+}
+
+// Purge removes backups older than the max age and returns how many it removed
+func (rf *RotatingFile) Purge() int {
+	rf.mutex.Lock()
+	defer rf.mutex.Unlock()
+	return rf.purge()
+}
+
+// Reopen closes and reopens the file so external tools such as logrotate can move it
+func (rf *RotatingFile) Reopen() error {
+	rf.mutex.Lock()
+	defer rf.mutex.Unlock()
+
+	if err := rf.file.Close(); err != nil {
+		return err
+	}
+	return rf.open()
+}
+
+// Close closes the current file
+func (rf *RotatingFile) Close() error {
+	rf.mutex.Lock()
+	defer rf.mutex.Unlock()
+
+	return rf.file.Close()
+}
+
+// open opens the log file for appending and records its current size
+func (rf *RotatingFile) open() error {
+	file, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	rf.file = file
+	rf.size = info.Size()
+	return nil
+}
+
+// rotate shifts existing backups up by one and starts a fresh file
+func (rf *RotatingFile) rotate() error {
+	if err := rf.file.Close(); err != nil {
+		return err
+	}
+
+	if rf.maxBackups > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", rf.path, rf.maxBackups))
+		for i := rf.maxBackups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", rf.path, i), fmt.Sprintf("%s.%d", rf.path, i+1))
+		}
+		if err := os.Rename(rf.path, rf.path+".1"); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate log file: %w", err)
+		}
+	} else if err := os.Truncate(rf.path, 0); err != nil {
+		return fmt.Errorf("failed to truncate log file: %w", err)
+	}
+
+	rf.purge()
+	return rf.open()
+}
+
+// purge removes backups older than the max age; the caller holds the mutex
+func (rf *RotatingFile) purge() int {
+	if rf.maxAge <= 0 {
+		return 0
+	}
+	cutoff := time.Now().Add(-rf.maxAge)
+	removed := 0
+	for i := 1; i <= rf.maxBackups; i++ {
+		backup := fmt.Sprintf("%s.%d", rf.path, i)
+		info, err := os.Stat(backup)
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if os.Remove(backup) == nil {
+			removed++
+		}
+	}
+	return removed
+}
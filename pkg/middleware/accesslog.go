@@ -0,0 +1,243 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/requestid"
+)
+
+// PromptRedaction controls how prompts appear in access log entries
+type PromptRedaction string
+
+const (
+	// RedactOmit leaves prompts out of the access log entirely
+	RedactOmit PromptRedaction = "omit"
+	// RedactHash logs a SHA-256 prefix so identical prompts can be correlated
+	RedactHash PromptRedaction = "hash"
+	// RedactTruncate logs the first characters of the prompt
+	RedactTruncate PromptRedaction = "truncate"
+	// RedactNone logs prompts verbatim
+	RedactNone PromptRedaction = "none"
+)
+
+// ParsePromptRedaction converts a configuration string into a PromptRedaction
+func ParsePromptRedaction(value string) (PromptRedaction, error) {
+	switch redaction := PromptRedaction(strings.ToLower(value)); redaction {
+	case RedactOmit, RedactHash, RedactTruncate, RedactNone:
+		return redaction, nil
+	case "":
+		return RedactHash, nil
+	default:
+		return "", fmt.Errorf("unknown prompt redaction %q", value)
+	}
+}
+
+// AccessLogEntry is a single JSON line written to the access log
+type AccessLogEntry struct {
+	Time       time.Time `json:"time"`
+	RequestID  string    `json:"request_id,omitempty"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	KeyID      string    `json:"key_id,omitempty"`
+	Status     int       `json:"status"`
+	DurationMs float64   `json:"duration_ms"`
+	Bytes      int64     `json:"bytes"`
+	Provider   string    `json:"provider,omitempty"`
+	Prompt     string    `json:"prompt,omitempty"`
+	RemoteAddr string    `json:"remote_addr"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+}
+
+// AccessLog writes one JSON entry per request to a dedicated writer
+type AccessLog struct {
+	writer         io.Writer
+	redaction      PromptRedaction
+	truncateLength int
+	scrub          func(string) string
+	mutex          sync.Mutex
+}
+
+// NewAccessLog creates access log middleware writing to writer
+func NewAccessLog(writer io.Writer, redaction PromptRedaction) *AccessLog {
+	return &AccessLog{
+		writer:         writer,
+		redaction:      redaction,
+		truncateLength: 64,
+	}
+}
+
+// SetScrubber masks secrets in prompts logged verbatim or truncated
+func (al *AccessLog) SetScrubber(scrub func(string) string) {
+	al.scrub = scrub
+}
+
+// accessLogContextKey is the context key for per-request annotations
+type accessLogContextKey struct{}
+
+// accessLogAnnotations are filled in by handlers while the request runs
+type accessLogAnnotations struct {
+	provider string
+	prompt   string
+	mutex    sync.Mutex
+}
+
+// SetAccessLogProvider records the provider selected for the current request
+func SetAccessLogProvider(ctx context.Context, provider string) {
+	if annotations, ok := ctx.Value(accessLogContextKey{}).(*accessLogAnnotations); ok {
+		annotations.mutex.Lock()
+		defer annotations.mutex.Unlock()
+		if annotations.provider == "" {
+			annotations.provider = provider
+		} else if !strings.Contains(","+annotations.provider+",", ","+provider+",") {
+			annotations.provider += "," + provider
+		}
+	}
+}
+
+// SetAccessLogPrompt records the prompt of the current request, subject to redaction
+func SetAccessLogPrompt(ctx context.Context, prompt string) {
+	if annotations, ok := ctx.Value(accessLogContextKey{}).(*accessLogAnnotations); ok {
+		annotations.mutex.Lock()
+		defer annotations.mutex.Unlock()
+		annotations.prompt = prompt
+	}
+}
+
+// Middleware logs every request handled by next once it completes
+func (al *AccessLog) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		annotations := &accessLogAnnotations{}
+		writer := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(writer, r.WithContext(context.WithValue(r.Context(), accessLogContextKey{}, annotations)))
+
+		annotations.mutex.Lock()
+		entry := AccessLogEntry{
+			Time:       start.UTC(),
+			RequestID:  requestid.FromContext(r.Context()),
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			KeyID:      KeyID(r),
+			Status:     writer.status,
+			DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+			Bytes:      writer.bytes,
+			Provider:   annotations.provider,
+			Prompt:     al.redact(annotations.prompt),
+			RemoteAddr: r.RemoteAddr,
+			UserAgent:  r.UserAgent(),
+		}
+		annotations.mutex.Unlock()
+
+		al.write(entry)
+	})
+}
+
+// write serializes entry as a single line
+func (al *AccessLog) write(entry AccessLogEntry) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	al.mutex.Lock()
+	defer al.mutex.Unlock()
+	al.writer.Write(line)
+}
+
+// redact applies the configured prompt redaction
+func (al *AccessLog) redact(prompt string) string {
+	if prompt == "" {
+		return ""
+	}
+	if al.scrub != nil && (al.redaction == RedactNone || al.redaction == RedactTruncate) {
+		prompt = al.scrub(prompt)
+	}
+	switch al.redaction {
+	case RedactNone:
+		return prompt
+	case RedactTruncate:
+		runes := []rune(prompt)
+		if len(runes) <= al.truncateLength {
+			return prompt
+		}
+		return string(runes[:al.truncateLength]) + "…"
+	case RedactHash:
+		sum := sha256.Sum256([]byte(prompt))
+		return "sha256:" + hex.EncodeToString(sum[:8])
+	default:
+		return ""
+	}
+}
+
+// KeyID derives a stable, non-reversible identifier for the caller's API key.
+// A key ID already in the request context, such as the issuer of a browser
+// token, takes precedence
+func KeyID(r *http.Request) string {
+	if id := KeyIDFromContext(r.Context()); id != "" {
+		return id
+	}
+	credential := Credential(r)
+	if credential == "" {
+		return ""
+	}
+	return HashKey(credential)
+}
+
+// Credential returns the API key or token the caller sent, from the
+// Authorization header with any Bearer scheme removed or from X-API-Key
+func Credential(r *http.Request) string {
+	credential := r.Header.Get("Authorization")
+	if credential == "" {
+		credential = r.Header.Get("X-API-Key")
+	}
+	return strings.TrimPrefix(credential, "Bearer ")
+}
+
+// HashKey returns the key ID of an API key, the form in which keys are logged
+// and bound to tenants
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "key_" + hex.EncodeToString(sum[:6])
+}
+
+// statusWriter records the status code and number of bytes written
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+// WriteHeader records the status code before forwarding it
+func (sw *statusWriter) WriteHeader(status int) {
+	if !sw.wroteHeader {
+		sw.status = status
+		sw.wroteHeader = true
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+// Write counts body bytes before forwarding them
+func (sw *statusWriter) Write(p []byte) (int, error) {
+	sw.wroteHeader = true
+	n, err := sw.ResponseWriter.Write(p)
+	sw.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController, so streamed
+// responses can still be flushed
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// KeyVerifier checks the API keys callers present and names the environment
+// each key belongs to, "" for none
+type KeyVerifier interface {
+	Verify(key string) (environment string, ok bool)
+}
+
+// authenticatedContextKey marks requests authenticated without an API key
+type authenticatedContextKey struct{}
+
+// WithAuthenticated returns a copy of ctx marking its request as
+// authenticated by other means than an API key, such as a browser token or
+// an admin session
+func WithAuthenticated(ctx context.Context) context.Context {
+	return context.WithValue(ctx, authenticatedContextKey{}, true)
+}
+
+// Authenticated reports whether the request of ctx was marked by
+// WithAuthenticated
+func Authenticated(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	authenticated, _ := ctx.Value(authenticatedContextKey{}).(bool)
+	return authenticated
+}
+
+// APIKeyAuth rejects requests without a valid API key. Requests already
+// authenticated by a browser token or an admin session, and requests to
+// public paths such as health checks, pass without one. Public paths ending
+// in "/" cover the paths below them
+type APIKeyAuth struct {
+	verifier KeyVerifier
+	public   []string
+}
+
+// NewAPIKeyAuth creates the middleware checking keys with verifier
+func NewAPIKeyAuth(verifier KeyVerifier, public []string) *APIKeyAuth {
+	return &APIKeyAuth{verifier: verifier, public: public}
+}
+
+// Middleware answers 401 to requests without a valid key and stores the
+// environment of valid ones. It runs after the browser token and admin
+// session middleware
+func (a *APIKeyAuth) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if Authenticated(r.Context()) || a.isPublic(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		environment, ok := a.verifier.Verify(Credential(r))
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="pal-moe"`)
+			http.Error(w, "Invalid or missing API key", http.StatusUnauthorized)
+			return
+		}
+		if environment != "" {
+			r = r.WithContext(WithEnvironment(r.Context(), environment))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isPublic reports whether path is or is below a public path
+func (a *APIKeyAuth) isPublic(path string) bool {
+	return slices.ContainsFunc(a.public, func(public string) bool {
+		return public == path || strings.HasSuffix(public, "/") && strings.HasPrefix(path, public)
+	})
+}
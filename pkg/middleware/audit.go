@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/audit"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/requestid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// AuditTrail records admin requests that change something, successful or
+// not, in an audit log
+type AuditTrail struct {
+	log    audit.Log
+	logger *logrus.Logger
+}
+
+// NewAuditTrail creates the middleware recording into log
+func NewAuditTrail(log audit.Log, logger *logrus.Logger) *AuditTrail {
+	return &AuditTrail{log: log, logger: logger}
+}
+
+// Middleware records the requests to /admin routes other than reads. It
+// goes on the router serving the admin routes
+func (a *AuditTrail) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !audited(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		action := r.Method + " " + r.URL.Path
+		if route := mux.CurrentRoute(r); route != nil {
+			if template, err := route.GetPathTemplate(); err == nil {
+				action = r.Method + " " + template
+			}
+		}
+		actor := KeyID(r)
+		if actor == "" {
+			actor = "anonymous"
+		}
+		event := audit.Event{
+			Time:       time.Now(),
+			Actor:      actor,
+			Action:     action,
+			Resource:   r.URL.Path,
+			Success:    sw.status < http.StatusBadRequest,
+			Status:     sw.status,
+			RequestID:  requestid.FromContext(r.Context()),
+			RemoteAddr: r.RemoteAddr,
+		}
+		if err := a.log.RecordAuditEvent(event); err != nil {
+			requestid.Logger(r.Context(), a.logger).Warnf("Failed to record audit event %s by %s: %v", action, actor, err)
+		}
+	})
+}
+
+// audited reports whether r is an admin request that is not a plain read
+func audited(r *http.Request) bool {
+	if r.URL.Path != "/admin" && !strings.HasPrefix(r.URL.Path, "/admin/") {
+		return false
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
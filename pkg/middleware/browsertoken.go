@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// BrowserTokenPrefix tells browser tokens apart from API keys
+const BrowserTokenPrefix = "bt_"
+
+// Browser token defaults
+const (
+	DefaultBrowserTokenTTL = 15 * time.Minute
+	minBrowserTokenSecret  = 32
+)
+
+// DefaultBrowserTokenPaths are the endpoints browser tokens may call: the
+// native and OpenAI-compatible completion routes, which also serve their
+// streamed responses
+var DefaultBrowserTokenPaths = []string{"/api/v1/process", "/v1/chat/completions"}
+
+// ErrInvalidBrowserToken is returned for tokens that are malformed, forged
+// or expired
+var ErrInvalidBrowserToken = errors.New("invalid browser token")
+
+// BrowserTokenClaims is what a browser token grants
+type BrowserTokenClaims struct {
+	// KeyID is the key that issued the token; requests made with the token
+	// are attributed to it
+	KeyID string `json:"kid"`
+	// Environment is the environment of the issuing key, whose routing
+	// profile requests made with the token follow
+	Environment string `json:"env,omitempty"`
+	// Origin is the only origin the token is accepted from
+	Origin    string `json:"origin"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// browserTokenContextKey marks requests authenticated by a browser token
+type browserTokenContextKey struct{}
+
+// BrowserTokens issues and checks short-lived tokens that let browser apps
+// call a few endpoints on behalf of an API key without holding the key
+type BrowserTokens struct {
+	secret []byte
+	maxTTL time.Duration
+	paths  []string
+}
+
+// NewBrowserTokens creates an issuer signing with secret, which replicas
+// must share. Tokens live at most maxTTL, DefaultBrowserTokenTTL when zero,
+// and may call paths, DefaultBrowserTokenPaths when empty
+func NewBrowserTokens(secret string, maxTTL time.Duration, paths []string) (*BrowserTokens, error) {
+	if len(secret) < minBrowserTokenSecret {
+		return nil, fmt.Errorf("browser token secret must be at least %d characters", minBrowserTokenSecret)
+	}
+	if maxTTL <= 0 {
+		maxTTL = DefaultBrowserTokenTTL
+	}
+	if len(paths) == 0 {
+		paths = DefaultBrowserTokenPaths
+	}
+	return &BrowserTokens{secret: []byte(secret), maxTTL: maxTTL, paths: paths}, nil
+}
+
+// MaxTTL returns the longest lifetime of a token
+func (bt *BrowserTokens) MaxTTL() time.Duration {
+	return bt.maxTTL
+}
+
+// Issue creates a token acting as keyID, of environment, from origin for
+// ttl, capped at the maximum lifetime; zero uses the maximum
+func (bt *BrowserTokens) Issue(keyID, environment, origin string, ttl time.Duration) (string, time.Time, error) {
+	if keyID == "" {
+		return "", time.Time{}, fmt.Errorf("browser tokens are issued to API keys only")
+	}
+	if ttl <= 0 || ttl > bt.maxTTL {
+		ttl = bt.maxTTL
+	}
+	expiresAt := time.Now().Add(ttl).Truncate(time.Second)
+	payload, err := json.Marshal(BrowserTokenClaims{KeyID: keyID, Environment: environment, Origin: origin, ExpiresAt: expiresAt.Unix()})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return BrowserTokenPrefix + encoded + "." + bt.sign(encoded), expiresAt, nil
+}
+
+// Verify checks a token's signature and expiry and returns its claims
+func (bt *BrowserTokens) Verify(token string) (*BrowserTokenClaims, error) {
+	encoded, signature, ok := strings.Cut(strings.TrimPrefix(token, BrowserTokenPrefix), ".")
+	if !ok || !strings.HasPrefix(token, BrowserTokenPrefix) {
+		return nil, ErrInvalidBrowserToken
+	}
+	if !hmac.Equal([]byte(signature), []byte(bt.sign(encoded))) {
+		return nil, ErrInvalidBrowserToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidBrowserToken
+	}
+	var claims BrowserTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidBrowserToken
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, fmt.Errorf("%w: expired", ErrInvalidBrowserToken)
+	}
+	return &claims, nil
+}
+
+// sign returns the signature of an encoded payload
+func (bt *BrowserTokens) sign(encoded string) string {
+	mac := hmac.New(sha256.New, bt.secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Middleware authenticates requests carrying a browser token instead of an
+// API key. The token must be valid, sent from its origin and used on one of
+// the allowed paths; the request then acts as the issuing key. It runs after
+// ClientKey and before the middleware that reads the key ID
+func (bt *BrowserTokens) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		credential := Credential(r)
+		if !strings.HasPrefix(credential, BrowserTokenPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		claims, err := bt.Verify(credential)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("Origin") != claims.Origin {
+			http.Error(w, "Browser token used from another origin", http.StatusForbidden)
+			return
+		}
+		if !slices.Contains(bt.paths, r.URL.Path) {
+			http.Error(w, "Browser tokens cannot call this endpoint", http.StatusForbidden)
+			return
+		}
+
+		ctx := WithKeyID(r.Context(), claims.KeyID)
+		ctx = context.WithValue(ctx, browserTokenContextKey{}, true)
+		ctx = WithAuthenticated(ctx)
+		if claims.Environment != "" {
+			ctx = WithEnvironment(ctx, claims.Environment)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// IsBrowserToken reports whether the request of ctx was authenticated by a
+// browser token
+func IsBrowserToken(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	browser, _ := ctx.Value(browserTokenContextKey{}).(bool)
+	return browser
+}
@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testBrowserSecret = "0123456789abcdef0123456789abcdef"
+	testOrigin        = "https://app.example.com"
+)
+
+// newTestBrowserTokens returns an issuer with the default paths
+func newTestBrowserTokens(t *testing.T, secret string) *BrowserTokens {
+	t.Helper()
+	tokens, err := NewBrowserTokens(secret, time.Hour, nil)
+	if err != nil {
+		t.Fatalf("NewBrowserTokens: %v", err)
+	}
+	return tokens
+}
+
+// signedToken returns a token of claims signed by tokens, bypassing the
+// checks of Issue
+func signedToken(t *testing.T, tokens *BrowserTokens, claims BrowserTokenClaims) string {
+	t.Helper()
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return BrowserTokenPrefix + encoded + "." + tokens.sign(encoded)
+}
+
+// callWithToken sends token from origin to path through the middleware and
+// returns the response and the context the handler saw, if it was reached
+func callWithToken(tokens *BrowserTokens, token, origin, path string) (*httptest.ResponseRecorder, *http.Request) {
+	var reached *http.Request
+	handler := tokens.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = r
+	}))
+	r := httptest.NewRequest(http.MethodPost, path, nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w, reached
+}
+
+func TestBrowserTokenAccepted(t *testing.T) {
+	tokens := newTestBrowserTokens(t, testBrowserSecret)
+	token, expiresAt, err := tokens.Issue("key_abc", "prod", testOrigin, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if until := time.Until(expiresAt); until > 5*time.Minute || until < 4*time.Minute {
+		t.Errorf("token expires in %v, want about 5m", until)
+	}
+
+	w, r := callWithToken(tokens, token, testOrigin, "/api/v1/process")
+	if r == nil {
+		t.Fatalf("request refused with %d: %s", w.Code, w.Body.String())
+	}
+	if KeyIDFromContext(r.Context()) != "key_abc" || EnvironmentFromContext(r.Context()) != "prod" || !IsBrowserToken(r.Context()) {
+		t.Errorf("request acts as key %q in %q, browser token %t; want key_abc in prod, true",
+			KeyIDFromContext(r.Context()), EnvironmentFromContext(r.Context()), IsBrowserToken(r.Context()))
+	}
+}
+
+func TestBrowserTokenDefaultPaths(t *testing.T) {
+	tokens := newTestBrowserTokens(t, testBrowserSecret)
+	token, _, err := tokens.Issue("key_abc", "", testOrigin, 0)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	// Streamed responses use the same paths as whole ones
+	for _, path := range []string{"/api/v1/process", "/v1/chat/completions"} {
+		if w, r := callWithToken(tokens, token, testOrigin, path); r == nil {
+			t.Errorf("token on %s refused with %d: %s", path, w.Code, w.Body.String())
+		}
+	}
+	for _, path := range []string{"/v1/chat/completions/", "/v1/models", "/v1/embeddings"} {
+		if w, r := callWithToken(tokens, token, testOrigin, path); r != nil || w.Code != http.StatusForbidden {
+			t.Errorf("token on %s = %d, want %d", path, w.Code, http.StatusForbidden)
+		}
+	}
+}
+
+func TestBrowserTokenTTLIsCapped(t *testing.T) {
+	tokens := newTestBrowserTokens(t, testBrowserSecret)
+	_, expiresAt, err := tokens.Issue("key_abc", "", testOrigin, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if time.Until(expiresAt) > tokens.MaxTTL() {
+		t.Errorf("token outlives the maximum lifetime: expires %v", expiresAt)
+	}
+	if _, _, err := tokens.Issue("", "", testOrigin, 0); err == nil {
+		t.Error("token issued without a key")
+	}
+}
+
+func TestBrowserTokenExpired(t *testing.T) {
+	tokens := newTestBrowserTokens(t, testBrowserSecret)
+	token := signedToken(t, tokens, BrowserTokenClaims{
+		KeyID:     "key_abc",
+		Origin:    testOrigin,
+		ExpiresAt: time.Now().Add(-time.Second).Unix(),
+	})
+
+	if _, err := tokens.Verify(token); !errors.Is(err, ErrInvalidBrowserToken) {
+		t.Errorf("Verify = %v, want ErrInvalidBrowserToken", err)
+	}
+	if w, r := callWithToken(tokens, token, testOrigin, "/api/v1/process"); r != nil || w.Code != http.StatusUnauthorized {
+		t.Errorf("expired token = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestBrowserTokenWrongOrigin(t *testing.T) {
+	tokens := newTestBrowserTokens(t, testBrowserSecret)
+	token, _, err := tokens.Issue("key_abc", "", testOrigin, 0)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	for _, origin := range []string{"https://evil.example.com", "http://app.example.com", ""} {
+		if w, r := callWithToken(tokens, token, origin, "/api/v1/process"); r != nil || w.Code != http.StatusForbidden {
+			t.Errorf("token from origin %q = %d, want %d", origin, w.Code, http.StatusForbidden)
+		}
+	}
+}
+
+func TestBrowserTokenOffPath(t *testing.T) {
+	tokens := newTestBrowserTokens(t, testBrowserSecret)
+	token, _, err := tokens.Issue("key_abc", "", testOrigin, 0)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	for _, path := range []string{"/api/v1/batch", "/api/v1/browser-tokens", "/admin/stats", "/api/v1/process/"} {
+		if w, r := callWithToken(tokens, token, testOrigin, path); r != nil || w.Code != http.StatusForbidden {
+			t.Errorf("token on %s = %d, want %d", path, w.Code, http.StatusForbidden)
+		}
+	}
+}
+
+func TestBrowserTokenForged(t *testing.T) {
+	tokens := newTestBrowserTokens(t, testBrowserSecret)
+	other := newTestBrowserTokens(t, strings.Repeat("x", minBrowserTokenSecret))
+	token, _, err := tokens.Issue("key_abc", "", testOrigin, 0)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	foreign, _, err := other.Issue("key_abc", "", testOrigin, 0)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	// Another key's claims under this token's signature
+	payload, _ := json.Marshal(BrowserTokenClaims{KeyID: "key_admin", Origin: testOrigin, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	_, signature, _ := strings.Cut(token, ".")
+	swapped := BrowserTokenPrefix + base64.RawURLEncoding.EncodeToString(payload) + "." + signature
+
+	for name, forged := range map[string]string{
+		"other secret":   foreign,
+		"swapped claims": swapped,
+		"no signature":   strings.SplitN(token, ".", 2)[0],
+	} {
+		if w, r := callWithToken(tokens, forged, testOrigin, "/api/v1/process"); r != nil || w.Code != http.StatusUnauthorized {
+			t.Errorf("%s = %d, want %d", name, w.Code, http.StatusUnauthorized)
+		}
+	}
+
+	// Credentials that are not browser tokens pass through to the API key checks
+	if _, r := callWithToken(tokens, "sk-api-key", "", "/admin/stats"); r == nil || IsBrowserToken(r.Context()) {
+		t.Error("API key was not passed through untouched")
+	}
+}
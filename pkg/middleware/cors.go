@@ -0,0 +1,189 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of a CORS policy that leaves them unset
+var (
+	DefaultCORSMethods = []string{http.MethodGet, http.MethodPost}
+	DefaultCORSHeaders = []string{"Authorization", "Content-Type", "X-API-Key", "X-Request-ID", "X-Timeout", "Idempotency-Key", TenantHeader}
+	DefaultCORSExpose  = append([]string{"X-Request-ID", IdempotentReplayHeader}, RoutingHeaders...)
+)
+
+// CORSPolicy lets browser apps served from Origins call the API
+type CORSPolicy struct {
+	// Origins are exact origins such as "https://app.example.com", wildcard
+	// subdomains such as "https://*.example.com", or "*" for any origin
+	Origins []string `yaml:"origins" json:"origins"`
+	Methods []string `yaml:"methods,omitempty" json:"methods,omitempty"`
+	// Headers are the request headers browsers may send
+	Headers []string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	// ExposeHeaders are the response headers scripts may read
+	ExposeHeaders []string `yaml:"expose_headers,omitempty" json:"expose_headers,omitempty"`
+	// Credentials lets browsers send cookies and HTTP authentication
+	Credentials bool `yaml:"credentials,omitempty" json:"credentials,omitempty"`
+	// MaxAge is how long browsers may cache a preflight answer
+	MaxAge time.Duration `yaml:"max_age,omitempty" json:"max_age,omitempty"`
+}
+
+// matches reports whether the policy admits origin
+func (p *CORSPolicy) matches(origin string) bool {
+	for _, allowed := range p.Origins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		scheme, host, ok := strings.Cut(allowed, "://*.")
+		if !ok {
+			continue
+		}
+		parsed, err := url.Parse(origin)
+		if err == nil && strings.EqualFold(parsed.Scheme, scheme) &&
+			strings.HasSuffix(strings.ToLower(parsed.Host), "."+strings.ToLower(host)) {
+			return true
+		}
+	}
+	return false
+}
+
+// CORS answers preflight requests and adds CORS headers to the responses of
+// origins a policy admits. Requests without an Origin header pass untouched
+type CORS struct {
+	policies []CORSPolicy
+	mutex    sync.RWMutex
+}
+
+// NewCORS validates policies; the first policy admitting an origin applies
+func NewCORS(policies []CORSPolicy) (*CORS, error) {
+	cors := &CORS{}
+	for i, policy := range policies {
+		if len(policy.Origins) == 0 {
+			return nil, fmt.Errorf("cors policy %d: origins are required", i+1)
+		}
+		for _, origin := range policy.Origins {
+			if origin == "*" {
+				if policy.Credentials {
+					return nil, fmt.Errorf("cors policy %d: credentials cannot be allowed for every origin", i+1)
+				}
+				continue
+			}
+			parsed, err := url.Parse(strings.Replace(origin, "://*.", "://", 1))
+			if err != nil || parsed.Scheme == "" || parsed.Host == "" || parsed.Path != "" {
+				return nil, fmt.Errorf("cors policy %d: origin %q must look like https://app.example.com", i+1, origin)
+			}
+		}
+		if len(policy.Methods) == 0 {
+			policy.Methods = DefaultCORSMethods
+		}
+		if len(policy.Headers) == 0 {
+			policy.Headers = DefaultCORSHeaders
+		}
+		if len(policy.ExposeHeaders) == 0 {
+			policy.ExposeHeaders = DefaultCORSExpose
+		}
+		cors.policies = append(cors.policies, policy)
+	}
+	return cors, nil
+}
+
+// Allows reports whether a policy admits origin
+func (c *CORS) Allows(origin string) bool {
+	return c.policy(origin) != nil
+}
+
+// Replace takes over the policies of other, so a reloaded configuration
+// applies to the next request
+func (c *CORS) Replace(other *CORS) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.policies = other.policies
+}
+
+// policy returns the first policy admitting origin, nil when none does
+func (c *CORS) policy(origin string) *CORSPolicy {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	for i := range c.policies {
+		if c.policies[i].matches(origin) {
+			return &c.policies[i]
+		}
+	}
+	return nil
+}
+
+// Middleware wraps the whole router, since preflight requests use OPTIONS,
+// for which no route is registered
+func (c *CORS) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+		policy := c.policy(origin)
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if policy == nil {
+			if preflight {
+				http.Error(w, "Origin not allowed", http.StatusForbidden)
+				return
+			}
+			// Without CORS headers the browser withholds the response
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowOrigin := origin
+		if !policy.Credentials && slices.Contains(policy.Origins, "*") {
+			allowOrigin = "*"
+		}
+		w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+		if policy.Credentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(policy.ExposeHeaders, ", "))
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		method := r.Header.Get("Access-Control-Request-Method")
+		if !containsFold(policy.Methods, method) {
+			http.Error(w, fmt.Sprintf("Method %s not allowed", method), http.StatusForbidden)
+			return
+		}
+		for _, header := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+			if header = strings.TrimSpace(header); header != "" && !containsFold(policy.Headers, header) {
+				http.Error(w, fmt.Sprintf("Header %s not allowed", header), http.StatusForbidden)
+				return
+			}
+		}
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(policy.Methods, ", "))
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(policy.Headers, ", "))
+		if policy.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(policy.MaxAge.Seconds())))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// containsFold reports whether values holds value, ignoring case
+func containsFold(values []string, value string) bool {
+	for _, candidate := range values {
+		if strings.EqualFold(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
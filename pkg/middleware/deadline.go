@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// TimeoutHeader carries the time the client is willing to wait for a response
+const TimeoutHeader = "X-Timeout"
+
+// Deadlines bounds each request with a context deadline: the client's
+// X-Timeout when it sends one, never more than the server's maximum
+type Deadlines struct {
+	max time.Duration
+}
+
+// NewDeadlines creates deadline middleware. Requests without X-Timeout get
+// max; zero means they get no deadline
+func NewDeadlines(max time.Duration) *Deadlines {
+	return &Deadlines{max: max}
+}
+
+// ParseTimeout parses an X-Timeout value, a Go duration such as "1.5s" or
+// "500ms", or a number of seconds
+func ParseTimeout(value string) (time.Duration, error) {
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, parseErr := strconv.ParseFloat(value, 64)
+		if parseErr != nil {
+			return 0, fmt.Errorf("invalid %s %q: use a duration such as 30s or a number of seconds", TimeoutHeader, value)
+		}
+		timeout = time.Duration(seconds * float64(time.Second))
+	}
+	if timeout <= 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be positive", TimeoutHeader, value)
+	}
+	return timeout, nil
+}
+
+// Middleware sets the request deadline before next runs, rejecting requests
+// with an invalid X-Timeout
+func (d *Deadlines) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout := d.max
+		if value := r.Header.Get(TimeoutHeader); value != "" {
+			requested, err := ParseTimeout(value)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if timeout == 0 || requested < timeout {
+				timeout = requested
+			}
+		}
+		if timeout == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
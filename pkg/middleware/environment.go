@@ -0,0 +1,23 @@
+package middleware
+
+import "context"
+
+// environmentContextKey is the context key for the environment of the
+// caller's key
+type environmentContextKey struct{}
+
+// WithEnvironment returns a copy of ctx carrying the environment of the
+// caller's key
+func WithEnvironment(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, environmentContextKey{}, name)
+}
+
+// EnvironmentFromContext returns the environment stored by WithEnvironment,
+// or "" for keys that belong to no environment
+func EnvironmentFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	name, _ := ctx.Value(environmentContextKey{}).(string)
+	return name
+}
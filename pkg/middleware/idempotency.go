@@ -0,0 +1,347 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/requestid"
+	"github.com/sirupsen/logrus"
+)
+
+// IdempotencyHeader is the request header carrying the client-supplied idempotency key
+const IdempotencyHeader = "Idempotency-Key"
+
+// IdempotentReplayHeader is set on responses replayed from the idempotency store
+const IdempotentReplayHeader = "Idempotent-Replayed"
+
+// maxIdempotencyKeyLength bounds the size of client-supplied keys
+const maxIdempotencyKeyLength = 255
+
+// DefaultIdempotencyLockTTL is how long a key stays reserved for a request
+// that has not answered yet, unless SetLockTTL changes it
+const DefaultIdempotencyLockTTL = time.Minute
+
+// IdempotencyState describes what the store knows about a key
+type IdempotencyState int
+
+const (
+	// IdempotencyNew means the key was unknown and is now reserved for the caller
+	IdempotencyNew IdempotencyState = iota
+	// IdempotencyInFlight means another request with the same key is still running
+	IdempotencyInFlight
+	// IdempotencyCompleted means a response was recorded for the key
+	IdempotencyCompleted
+)
+
+// CachedResponse is a recorded response replayed for repeated idempotent requests
+type CachedResponse struct {
+	StatusCode  int         `json:"status_code"`
+	Header      http.Header `json:"header"`
+	Body        []byte      `json:"body"`
+	Fingerprint string      `json:"fingerprint"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
+// IdempotencyStore persists idempotency reservations and recorded responses
+type IdempotencyStore interface {
+	// Begin reserves key for ttl for a new request or reports the existing
+	// entry for it. A reservation that expires frees the key for a retry
+	Begin(key, fingerprint string, ttl time.Duration) (IdempotencyState, *CachedResponse, error)
+	// Complete records the final response for a reserved key, kept for ttl
+	Complete(key string, response *CachedResponse, ttl time.Duration) error
+	// Abort releases a reservation so the request can be retried, keeping any
+	// response recorded for key
+	Abort(key string) error
+}
+
+// idempotencyEntry is a single key tracked by MemoryIdempotencyStore
+type idempotencyEntry struct {
+	response    *CachedResponse
+	fingerprint string
+	expiresAt   time.Time
+}
+
+// MemoryIdempotencyStore keeps idempotency entries in process memory
+type MemoryIdempotencyStore struct {
+	entries map[string]*idempotencyEntry
+	mutex   sync.Mutex
+}
+
+// NewMemoryIdempotencyStore creates a new in-memory idempotency store
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		entries: make(map[string]*idempotencyEntry),
+	}
+}
+
+// Begin reserves key for a new request or reports the existing entry for it
+func (s *MemoryIdempotencyStore) Begin(key, fingerprint string, ttl time.Duration) (IdempotencyState, *CachedResponse, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	if entry, exists := s.entries[key]; exists && now.Before(entry.expiresAt) {
+		if entry.response != nil {
+			return IdempotencyCompleted, entry.response, nil
+		}
+		return IdempotencyInFlight, &CachedResponse{Fingerprint: entry.fingerprint}, nil
+	}
+
+	s.entries[key] = &idempotencyEntry{
+		fingerprint: fingerprint,
+		expiresAt:   now.Add(ttl),
+	}
+	return IdempotencyNew, nil, nil
+}
+
+// Complete records the final response for a reserved key
+func (s *MemoryIdempotencyStore) Complete(key string, response *CachedResponse, ttl time.Duration) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.entries[key] = &idempotencyEntry{
+		response:    response,
+		fingerprint: response.Fingerprint,
+		expiresAt:   time.Now().Add(ttl),
+	}
+	return nil
+}
+
+// Abort releases a reservation so the request can be retried
+func (s *MemoryIdempotencyStore) Abort(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if entry, exists := s.entries[key]; exists && entry.response == nil {
+		delete(s.entries, key)
+	}
+	return nil
+}
+
+// Prune removes expired entries and returns how many were dropped
+func (s *MemoryIdempotencyStore) Prune() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	removed := 0
+	for key, entry := range s.entries {
+		if now.After(entry.expiresAt) {
+			delete(s.entries, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// StartJanitor prunes expired entries every interval until ctx is cancelled
+func (s *MemoryIdempotencyStore) StartJanitor(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.Prune()
+			}
+		}
+	}()
+}
+
+// Idempotency deduplicates retried POST requests that carry an Idempotency-Key header
+type Idempotency struct {
+	store   IdempotencyStore
+	ttl     time.Duration
+	lockTTL time.Duration
+	logger  *logrus.Logger
+}
+
+// NewIdempotency creates idempotency middleware backed by store that replays
+// responses for ttl
+func NewIdempotency(store IdempotencyStore, ttl time.Duration, logger *logrus.Logger) *Idempotency {
+	return &Idempotency{
+		store:   store,
+		ttl:     ttl,
+		lockTTL: DefaultIdempotencyLockTTL,
+		logger:  logger,
+	}
+}
+
+// SetLockTTL sets how long a key stays reserved while its request runs. It
+// should outlast the longest request; a replica that dies mid-request only
+// blocks retries that long
+func (i *Idempotency) SetLockTTL(lockTTL time.Duration) {
+	i.lockTTL = lockTTL
+}
+
+// Middleware wraps next so repeated requests within the TTL replay the original response
+func (i *Idempotency) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyHeader)
+		if key == "" || r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			http.Error(w, "Idempotency-Key must be at most 255 characters", http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		logger := requestid.Logger(r.Context(), i.logger)
+		storeKey := idempotencyScope(r) + ":" + key
+		fingerprint := requestFingerprint(r, body)
+
+		state, cached, err := i.store.Begin(storeKey, fingerprint, i.lockTTL)
+		if err != nil {
+			// Fail open: a broken store must not take the API down
+			logger.Warnf("Idempotency store unavailable, processing request without dedupe: %v", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		switch state {
+		case IdempotencyCompleted:
+			if cached.Fingerprint != fingerprint {
+				http.Error(w, "Idempotency-Key was already used with a different request", http.StatusUnprocessableEntity)
+				return
+			}
+			logger.Debugf("Replaying idempotent response for key %s", key)
+			replayResponse(w, cached)
+			return
+		case IdempotencyInFlight:
+			if cached != nil && cached.Fingerprint != fingerprint {
+				http.Error(w, "Idempotency-Key was already used with a different request", http.StatusUnprocessableEntity)
+				return
+			}
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "A request with this Idempotency-Key is already in progress", http.StatusConflict)
+			return
+		}
+
+		// Server errors, panics and responses that could not be recorded
+		// release the key so that clients can retry them
+		recorded := false
+		defer func() {
+			if recorded {
+				return
+			}
+			if err := i.store.Abort(storeKey); err != nil {
+				logger.Warnf("Failed to release idempotency key %s: %v", key, err)
+			}
+		}()
+
+		recorder := newResponseRecorder(w)
+		next.ServeHTTP(recorder, r)
+		if recorder.status >= http.StatusInternalServerError {
+			return
+		}
+
+		response := &CachedResponse{
+			StatusCode:  recorder.status,
+			Header:      w.Header().Clone(),
+			Body:        recorder.body.Bytes(),
+			Fingerprint: fingerprint,
+			CreatedAt:   time.Now(),
+		}
+		if err := i.store.Complete(storeKey, response, i.ttl); err != nil {
+			logger.Warnf("Failed to record idempotent response for key %s: %v", key, err)
+			return
+		}
+		recorded = true
+	})
+}
+
+// idempotencyScope separates keys of different callers, and of different
+// tenants sharing a key, so they cannot collide
+func idempotencyScope(r *http.Request) string {
+	credential := r.Header.Get("Authorization")
+	if credential == "" {
+		credential = r.Header.Get("X-API-Key")
+	}
+	if tenant := TenantFromContext(r.Context()); tenant != "" {
+		credential = tenant + "\x00" + credential
+	}
+	sum := sha256.Sum256([]byte(credential))
+	return hex.EncodeToString(sum[:8])
+}
+
+// requestFingerprint hashes the parts of a request that must match on replay
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method))
+	hash.Write([]byte(r.URL.Path))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// replayResponse writes a cached response back to the client
+func replayResponse(w http.ResponseWriter, cached *CachedResponse) {
+	for name, values := range cached.Header {
+		// Headers set by outer middleware, such as the request ID, describe this request
+		if _, exists := w.Header()[name]; exists {
+			continue
+		}
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+	w.Header().Set(IdempotentReplayHeader, "true")
+	// The answer comes from the store this time, not from a provider
+	if w.Header().Get(CacheHeader) != "" {
+		w.Header().Set(CacheHeader, CacheHit)
+	}
+	w.WriteHeader(cached.StatusCode)
+	w.Write(cached.Body)
+}
+
+// responseRecorder passes writes through while keeping a copy of the response
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// newResponseRecorder wraps w with a recorder defaulting to 200 OK
+func newResponseRecorder(w http.ResponseWriter) *responseRecorder {
+	return &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+// WriteHeader records the status code before forwarding it
+func (rr *responseRecorder) WriteHeader(status int) {
+	rr.status = status
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the body bytes before forwarding them
+func (rr *responseRecorder) Write(p []byte) (int, error) {
+	rr.body.Write(p)
+	return rr.ResponseWriter.Write(p)
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController
+func (rr *responseRecorder) Unwrap() http.ResponseWriter {
+	return rr.ResponseWriter
+}
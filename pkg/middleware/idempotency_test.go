@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// idempotencyServer serves handler behind idempotency middleware replaying
+// responses for ttl and reserving keys for lockTTL
+func idempotencyServer(handler http.HandlerFunc, ttl, lockTTL time.Duration) http.Handler {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	idempotency := NewIdempotency(NewMemoryIdempotencyStore(), ttl, logger)
+	idempotency.SetLockTTL(lockTTL)
+	return idempotency.Middleware(handler)
+}
+
+// postIdempotent sends body to server with key as its Idempotency-Key
+func postIdempotent(server http.Handler, key, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/process", strings.NewReader(body))
+	r.Header.Set(IdempotencyHeader, key)
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, r)
+	return w
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	var calls atomic.Int32
+	server := idempotencyServer(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, "answer")
+	}, time.Hour, time.Minute)
+
+	first := postIdempotent(server, "key", `{"content":"hi"}`)
+	replay := postIdempotent(server, "key", `{"content":"hi"}`)
+
+	if calls.Load() != 1 {
+		t.Fatalf("handler called %d times, want 1", calls.Load())
+	}
+	if replay.Code != first.Code || replay.Body.String() != "answer" {
+		t.Errorf("replay = %d %q, want %d %q", replay.Code, replay.Body.String(), first.Code, "answer")
+	}
+	if replay.Header().Get(IdempotentReplayHeader) != "true" {
+		t.Errorf("replay is missing %s", IdempotentReplayHeader)
+	}
+}
+
+func TestIdempotencyConflictingBody(t *testing.T) {
+	server := idempotencyServer(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "answer")
+	}, time.Hour, time.Minute)
+
+	postIdempotent(server, "key", `{"content":"hi"}`)
+	if w := postIdempotent(server, "key", `{"content":"bye"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestIdempotencyInFlight(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := idempotencyServer(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "answer")
+	}, time.Hour, time.Minute)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- postIdempotent(server, "key", `{"content":"hi"}`) }()
+	<-started
+
+	w := postIdempotent(server, "key", `{"content":"hi"}`)
+	if w.Code != http.StatusConflict || w.Header().Get("Retry-After") == "" {
+		t.Errorf("concurrent retry = %d with Retry-After %q, want %d with one", w.Code, w.Header().Get("Retry-After"), http.StatusConflict)
+	}
+	if w := postIdempotent(server, "key", `{"content":"bye"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("concurrent request with another body = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+
+	close(release)
+	if first := <-done; first.Code != http.StatusOK {
+		t.Errorf("first request = %d, want %d", first.Code, http.StatusOK)
+	}
+}
+
+func TestIdempotencyExpiry(t *testing.T) {
+	var calls atomic.Int32
+	server := idempotencyServer(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		io.WriteString(w, "answer")
+	}, 20*time.Millisecond, time.Minute)
+
+	postIdempotent(server, "key", `{"content":"hi"}`)
+	time.Sleep(40 * time.Millisecond)
+	if w := postIdempotent(server, "key", `{"content":"bye"}`); w.Code != http.StatusOK || w.Header().Get(IdempotentReplayHeader) != "" {
+		t.Errorf("request after expiry = %d, replayed %q; want a fresh 200", w.Code, w.Header().Get(IdempotentReplayHeader))
+	}
+	if calls.Load() != 2 {
+		t.Errorf("handler called %d times, want 2", calls.Load())
+	}
+}
+
+// TestIdempotencyLockExpiry checks that a reservation whose request never
+// answers frees the key after the lock TTL, not the replay TTL
+func TestIdempotencyLockExpiry(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+	if state, _, _ := store.Begin("key", "fingerprint", 20*time.Millisecond); state != IdempotencyNew {
+		t.Fatalf("first Begin = %v, want IdempotencyNew", state)
+	}
+	if state, _, _ := store.Begin("key", "fingerprint", 20*time.Millisecond); state != IdempotencyInFlight {
+		t.Fatalf("second Begin = %v, want IdempotencyInFlight", state)
+	}
+	time.Sleep(40 * time.Millisecond)
+	if state, _, _ := store.Begin("key", "fingerprint", 20*time.Millisecond); state != IdempotencyNew {
+		t.Errorf("Begin after the lock expired = %v, want IdempotencyNew", state)
+	}
+}
+
+func TestIdempotencyReleasesKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"server error", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "upstream failed", http.StatusBadGateway)
+		}},
+		{"panic", func(w http.ResponseWriter, r *http.Request) {
+			panic("handler failed")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failing := true
+			server := idempotencyServer(func(w http.ResponseWriter, r *http.Request) {
+				if failing {
+					failing = false
+					tt.handler(w, r)
+					return
+				}
+				io.WriteString(w, "answer")
+			}, time.Hour, time.Minute)
+
+			func() {
+				defer func() { recover() }()
+				postIdempotent(server, "key", `{"content":"hi"}`)
+			}()
+			w := postIdempotent(server, "key", `{"content":"hi"}`)
+			if w.Code != http.StatusOK || w.Body.String() != "answer" {
+				t.Errorf("retry = %d %q, want 200 %q", w.Code, w.Body.String(), "answer")
+			}
+		})
+	}
+}
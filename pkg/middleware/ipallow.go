@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+)
+
+// IPAllowList admits requests from listed addresses and CIDR ranges only.
+// It checks the connection's address, never forwarding headers, which
+// clients can set freely
+type IPAllowList struct {
+	prefixes []netip.Prefix
+	mutex    sync.RWMutex
+}
+
+// NewIPAllowList parses entries such as "10.0.0.0/8", "192.168.1.7" or "::1"
+func NewIPAllowList(entries []string) (*IPAllowList, error) {
+	list := &IPAllowList{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR range %q: %w", entry, err)
+			}
+			list.prefixes = append(list.prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP address %q: %w", entry, err)
+		}
+		addr = addr.Unmap()
+		list.prefixes = append(list.prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return list, nil
+}
+
+// Replace takes over the entries of other
+func (l *IPAllowList) Replace(other *IPAllowList) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.prefixes = other.prefixes
+}
+
+// Allows reports whether addr is on the list
+func (l *IPAllowList) Allows(addr netip.Addr) bool {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	addr = addr.Unmap()
+	for _, prefix := range l.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware answers 403 to requests from addresses not on the list
+func (l *IPAllowList) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addrPort, err := netip.ParseAddrPort(r.RemoteAddr)
+		if err != nil || !l.Allows(addrPort.Addr()) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
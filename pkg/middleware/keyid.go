@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+)
+
+// keyIDContextKey is the context key for the caller's key ID
+type keyIDContextKey struct{}
+
+// ClientKey stores the caller's KeyID in the request context so that usage can
+// be attributed to API keys without handling the keys themselves
+func ClientKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := KeyID(r); id != "" {
+			r = r.WithContext(WithKeyID(r.Context(), id))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WithKeyID returns a copy of ctx carrying the caller's key ID
+func WithKeyID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, keyIDContextKey{}, id)
+}
+
+// KeyIDFromContext returns the key ID stored by ClientKey, or "" if there is none
+func KeyIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(keyIDContextKey{}).(string)
+	return id
+}
+
+// KeyIDIn reports whether id is one of ids, comparing every entry in
+// constant time so that timing reveals nothing about the listed IDs
+func KeyIDIn(ids []string, id string) bool {
+	if id == "" {
+		return false
+	}
+	found := 0
+	for _, candidate := range ids {
+		found |= subtle.ConstantTimeCompare([]byte(candidate), []byte(id))
+	}
+	return found == 1
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxBodyBytes is the request body limit used when none is configured
+const DefaultMaxBodyBytes int64 = 1 << 20
+
+// RequestLimits enforces body size and content type rules on incoming requests
+type RequestLimits struct {
+	maxBodyBytes int64
+	contentTypes []string
+}
+
+// NewRequestLimits creates request limit middleware accepting JSON bodies up to maxBodyBytes
+func NewRequestLimits(maxBodyBytes int64) *RequestLimits {
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = DefaultMaxBodyBytes
+	}
+	return &RequestLimits{
+		maxBodyBytes: maxBodyBytes,
+		contentTypes: []string{"application/json"},
+	}
+}
+
+// AllowContentTypes replaces the list of accepted media types
+func (rl *RequestLimits) AllowContentTypes(contentTypes ...string) *RequestLimits {
+	rl.contentTypes = contentTypes
+	return rl
+}
+
+// MaxBodyBytes returns the configured body size limit
+func (rl *RequestLimits) MaxBodyBytes() int64 {
+	return rl.maxBodyBytes
+}
+
+// Middleware rejects oversized or wrongly typed bodies before they reach next
+func (rl *RequestLimits) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !hasBody(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.ContentLength > rl.maxBodyBytes {
+			http.Error(w, fmt.Sprintf("Request body exceeds the %d byte limit", rl.maxBodyBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		if !rl.contentTypeAllowed(r.Header.Get("Content-Type")) {
+			http.Error(w, fmt.Sprintf("Unsupported Content-Type, expected one of: %s", strings.Join(rl.contentTypes, ", ")), http.StatusUnsupportedMediaType)
+			return
+		}
+
+		// Bodies without a declared length are cut off while being read
+		r.Body = http.MaxBytesReader(w, r.Body, rl.maxBodyBytes)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// contentTypeAllowed checks the media type portion of a Content-Type header
+func (rl *RequestLimits) contentTypeAllowed(header string) bool {
+	if len(rl.contentTypes) == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range rl.contentTypes {
+		if strings.EqualFold(mediaType, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasBody reports whether the request method carries a body worth checking
+func hasBody(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return r.ContentLength != 0
+	default:
+		return false
+	}
+}
@@ -0,0 +1,26 @@
+package middleware
+
+// Response headers carrying the routing outcome of a request, for clients
+// whose SDKs drop the fields of the body they do not know
+const (
+	ProviderHeader = "X-PalMoE-Provider"
+	ModelHeader    = "X-PalMoE-Model"
+	TierHeader     = "X-PalMoE-Tier"
+	ScoreHeader    = "X-PalMoE-Score"
+	// CacheHeader is "hit" on responses replayed for a repeated
+	// Idempotency-Key and "miss" otherwise
+	CacheHeader = "X-PalMoE-Cache"
+	// RetriesHeader counts the provider calls after the first, such as the
+	// selected provider repairing a speculative draft
+	RetriesHeader = "X-PalMoE-Retries"
+	CostHeader    = "X-PalMoE-Cost"
+)
+
+// Values of CacheHeader
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+// RoutingHeaders lists the routing headers, in the order servers set them
+var RoutingHeaders = []string{ProviderHeader, ModelHeader, TierHeader, ScoreHeader, CacheHeader, RetriesHeader, CostHeader}
@@ -0,0 +1,24 @@
+package middleware
+
+import "context"
+
+// TenantHeader selects the tenant of a request whose key is not bound to one
+const TenantHeader = "X-Tenant-ID"
+
+// tenantContextKey is the context key for the request's tenant ID
+type tenantContextKey struct{}
+
+// WithTenant returns a copy of ctx carrying the tenant ID
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, id)
+}
+
+// TenantFromContext returns the tenant ID stored by WithTenant, or "" for
+// requests that belong to no tenant
+func TenantFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(tenantContextKey{}).(string)
+	return id
+}
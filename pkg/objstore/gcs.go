@@ -0,0 +1,72 @@
+package objstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// GCSEndpoint is the XML API of Google Cloud Storage
+const GCSEndpoint = "https://storage.googleapis.com"
+
+// metadataTokenURL hands out access tokens of the service account a workload
+// runs as on Google Cloud
+const metadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// NewGCS creates a client of a Google Cloud Storage bucket. With HMAC keys
+// requests are signed like S3 requests; without, they carry access tokens of
+// the workload's service account from the metadata server
+func NewGCS(bucket, accessKey, secretKey string) (*S3, error) {
+	config := S3Config{Endpoint: GCSEndpoint, Bucket: bucket}
+	if accessKey != "" || secretKey != "" {
+		config.Credentials = Credentials{AccessKey: accessKey, SecretKey: secretKey, Region: "auto"}
+	} else {
+		config.Token = (&metadataToken{client: &http.Client{Timeout: 10 * time.Second}}).get
+	}
+	return NewS3(config)
+}
+
+// metadataToken caches the access token of the metadata server until shortly
+// before it expires
+type metadataToken struct {
+	client  *http.Client
+	token   string
+	expires time.Time
+	mutex   sync.Mutex
+}
+
+// get returns a valid access token
+func (m *metadataToken) get(ctx context.Context) (string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.token != "" && time.Now().Before(m.expires) {
+		return m.token, nil
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataTokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Metadata-Flavor", "Google")
+	response, err := m.client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata server returned %d", response.StatusCode)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to read access token: %w", err)
+	}
+	m.token = token.AccessToken
+	m.expires = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return m.token, nil
+}
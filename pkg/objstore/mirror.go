@@ -0,0 +1,297 @@
+package objstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/logging"
+)
+
+var logger = logging.Module("objstore")
+
+// manifestFile records in the cache directory what each local copy was
+// pulled or pushed as
+const manifestFile = ".manifest.json"
+
+// copyState is what a local file was last pulled or pushed as
+type copyState struct {
+	// ETag of the object, for conditional reads
+	ETag string `json:"etag"`
+	// Hash of the content, to tell local changes
+	Hash string `json:"sha256"`
+}
+
+// mirrored is an object or a prefix kept as a local file or directory
+type mirrored struct {
+	bucket Bucket
+	key    string
+	local  string
+	dir    bool
+}
+
+// Mirror keeps local copies of objects in a cache directory, so code reading
+// and writing files can keep them in object storage. Objects are pulled when
+// added, with conditional reads of copies cached by earlier runs, and local
+// changes are pushed by Push
+type Mirror struct {
+	dir      string
+	mirrored []mirrored
+	copies   map[string]copyState
+	mutex    sync.Mutex
+}
+
+// NewMirror creates a mirror caching objects in dir
+func NewMirror(dir string) (*Mirror, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create object cache: %w", err)
+	}
+	m := &Mirror{dir: dir, copies: make(map[string]copyState)}
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read object cache manifest: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &m.copies); err != nil {
+			logger.Warnf("Ignoring unreadable object cache manifest: %v", err)
+			m.copies = make(map[string]copyState)
+		}
+	}
+	return m, nil
+}
+
+// LocalPath returns where location is kept in the cache
+func (m *Mirror) LocalPath(location Location) string {
+	return filepath.Join(m.dir, location.Scheme, location.Bucket, filepath.FromSlash(location.Key))
+}
+
+// AddFile mirrors the object at location in bucket to a local file and pulls
+// it, returning the file's path. A missing object leaves no file. When the
+// pull fails the path is returned with the error, and a copy cached by an
+// earlier run may still be usable
+func (m *Mirror) AddFile(ctx context.Context, bucket Bucket, location Location) (string, error) {
+	entry := mirrored{bucket: bucket, key: location.Key, local: m.LocalPath(location)}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.mirrored = append(m.mirrored, entry)
+	// The directory must exist for the file to be created when the object
+	// does not exist yet
+	if err := os.MkdirAll(filepath.Dir(entry.local), 0o750); err != nil {
+		return entry.local, err
+	}
+	if err := m.pullFile(ctx, entry.bucket, entry.key, entry.local); err != nil {
+		return entry.local, fmt.Errorf("failed to pull %s: %w", location, err)
+	}
+	return entry.local, m.saveManifest()
+}
+
+// AddDir mirrors the objects under the prefix at location in bucket to a
+// local directory and pulls them, returning the directory's path. Failures
+// are reported like AddFile's
+func (m *Mirror) AddDir(ctx context.Context, bucket Bucket, location Location) (string, error) {
+	if location.Key != "" && !strings.HasSuffix(location.Key, "/") {
+		location.Key += "/"
+	}
+	entry := mirrored{bucket: bucket, key: location.Key, local: m.LocalPath(location), dir: true}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.mirrored = append(m.mirrored, entry)
+	if err := os.MkdirAll(entry.local, 0o750); err != nil {
+		return entry.local, err
+	}
+	objects, err := bucket.List(ctx, entry.key)
+	if err != nil {
+		return entry.local, fmt.Errorf("failed to list %s: %w", location, err)
+	}
+	var errs []error
+	for _, object := range objects {
+		name := strings.TrimPrefix(object.Name, entry.key)
+		if name == "" || strings.HasSuffix(name, "/") {
+			continue
+		}
+		local := filepath.Join(entry.local, filepath.FromSlash(name))
+		if !strings.HasPrefix(local, entry.local+string(filepath.Separator)) {
+			continue
+		}
+		// The listing's ETag saves reading objects whose cached copy is current
+		if state, ok := m.copies[m.relative(local)]; ok && state.ETag == object.ETag && fileHash(local) == state.Hash {
+			continue
+		}
+		if err := m.pullFile(ctx, bucket, object.Name, local); err != nil {
+			errs = append(errs, fmt.Errorf("failed to pull %s: %w", object.Name, err))
+		}
+	}
+	if err := m.saveManifest(); err != nil {
+		errs = append(errs, err)
+	}
+	return entry.local, errors.Join(errs...)
+}
+
+// pullFile reads name into local unless the cached copy is current; callers
+// hold the mutex
+func (m *Mirror) pullFile(ctx context.Context, bucket Bucket, name, local string) error {
+	relative := m.relative(local)
+	etag := ""
+	if state, ok := m.copies[relative]; ok && fileHash(local) == state.Hash {
+		etag = state.ETag
+	}
+	data, newETag, err := bucket.Get(ctx, name, etag)
+	if errors.Is(err, ErrNotModified) {
+		return nil
+	}
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := writeFile(local, data); err != nil {
+		return err
+	}
+	m.copies[relative] = copyState{ETag: newETag, Hash: sha256Hex(data)}
+	return nil
+}
+
+// Push stores the local files changed since they were pulled or last pushed,
+// and deletes the objects of local files that were removed
+func (m *Mirror) Push(ctx context.Context) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var errs []error
+	pushed := 0
+	for _, entry := range m.mirrored {
+		files := map[string]string{entry.local: entry.key}
+		prefix := entry.local + string(filepath.Separator)
+		if entry.dir {
+			files = make(map[string]string)
+			err := filepath.WalkDir(entry.local, func(path string, d fs.DirEntry, err error) error {
+				if err != nil || d.IsDir() || strings.HasSuffix(path, ".tmp") {
+					return nil
+				}
+				relative, _ := filepath.Rel(entry.local, path)
+				files[path] = entry.key + filepath.ToSlash(relative)
+				return nil
+			})
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+
+		for local, name := range files {
+			data, err := os.ReadFile(local)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			relative := m.relative(local)
+			if m.copies[relative].Hash == sha256Hex(data) {
+				continue
+			}
+			etag, err := entry.bucket.Put(ctx, name, data)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to push %s: %w", name, err))
+				continue
+			}
+			m.copies[relative] = copyState{ETag: etag, Hash: sha256Hex(data)}
+			pushed++
+		}
+
+		// Files pulled or pushed before and gone now were deleted locally
+		for relative := range m.copies {
+			local := filepath.Join(m.dir, relative)
+			if entry.dir && !strings.HasPrefix(local, prefix) || !entry.dir && local != entry.local {
+				continue
+			}
+			if _, err := os.Stat(local); !errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			name := entry.key
+			if entry.dir {
+				name += filepath.ToSlash(strings.TrimPrefix(local, prefix))
+			}
+			if err := entry.bucket.Delete(ctx, name); err != nil {
+				errs = append(errs, fmt.Errorf("failed to delete %s: %w", name, err))
+				continue
+			}
+			delete(m.copies, relative)
+			pushed++
+		}
+	}
+	if pushed > 0 {
+		logger.Debugf("Pushed %d changed files to object storage", pushed)
+		if err := m.saveManifest(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// StartSync pushes local changes every interval until ctx is done, reporting
+// failures to onError
+func (m *Mirror) StartSync(ctx context.Context, interval time.Duration, onError func(error)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := m.Push(ctx); err != nil && onError != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+}
+
+// relative returns the key of a local path in the manifest
+func (m *Mirror) relative(local string) string {
+	relative, _ := filepath.Rel(m.dir, local)
+	return relative
+}
+
+// saveManifest records the copies so later runs can read conditionally;
+// callers hold the mutex
+func (m *Mirror) saveManifest() error {
+	data, err := json.MarshalIndent(m.copies, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(m.dir, manifestFile), data)
+}
+
+// writeFile replaces path with data once it is complete
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".tmp", data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// fileHash returns the hash of the file at path, "" when it cannot be read
+func fileHash(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return sha256Hex(data)
+}
@@ -0,0 +1,79 @@
+// Package objstore reads and writes objects in S3-compatible and Google Cloud
+// Storage buckets, and mirrors them to local files so code that reads and
+// writes files can keep its state in object storage
+package objstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// Errors reading objects
+var (
+	ErrNotFound = errors.New("object not found")
+	// ErrNotModified is returned by conditional reads of unchanged objects
+	ErrNotModified = errors.New("object not modified")
+)
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Name     string    `json:"name"`
+	ETag     string    `json:"etag"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// Bucket is a flat namespace of objects
+type Bucket interface {
+	// Get returns the object's content and ETag. With a non-empty etag it
+	// returns ErrNotModified when the object still has that ETag
+	Get(ctx context.Context, name, etag string) ([]byte, string, error)
+	// Put stores data under name and returns the new ETag
+	Put(ctx context.Context, name string, data []byte) (string, error)
+	// Delete removes the object, succeeding when it does not exist
+	Delete(ctx context.Context, name string) error
+	// List returns the objects whose names start with prefix
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+}
+
+// Location is an object or prefix named by a URL such as s3://bucket/key or
+// gs://bucket/prefix/
+type Location struct {
+	Scheme string
+	Bucket string
+	Key    string
+}
+
+// IsURL reports whether s names an object location rather than a local path
+func IsURL(s string) bool {
+	return strings.HasPrefix(s, "s3://") || strings.HasPrefix(s, "gs://")
+}
+
+// ParseLocation parses an s3:// or gs:// URL
+func ParseLocation(raw string) (Location, error) {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return Location{}, err
+	}
+	if parsed.Scheme != "s3" && parsed.Scheme != "gs" {
+		return Location{}, fmt.Errorf("unsupported object store scheme %q: must be s3 or gs", parsed.Scheme)
+	}
+	if parsed.Host == "" {
+		return Location{}, fmt.Errorf("object store URL %q names no bucket", raw)
+	}
+	key := strings.TrimPrefix(parsed.Path, "/")
+	if key != "" && path.Clean(key) != strings.TrimSuffix(key, "/") {
+		return Location{}, fmt.Errorf("object store URL %q has an unclean key", raw)
+	}
+	return Location{Scheme: parsed.Scheme, Bucket: parsed.Host, Key: key}, nil
+}
+
+// String returns the location as a URL
+func (l Location) String() string {
+	return l.Scheme + "://" + l.Bucket + "/" + l.Key
+}
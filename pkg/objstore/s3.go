@@ -0,0 +1,170 @@
+package objstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3Config locates a bucket of an S3-compatible store and how to authorize
+// requests to it
+type S3Config struct {
+	// Endpoint is the store's base URL, e.g. https://s3.eu-west-1.amazonaws.com
+	// or a MinIO server. Buckets are addressed path-style
+	Endpoint string
+	Bucket   string
+	// Credentials sign requests unless Token is set
+	Credentials Credentials
+	// Token returns a bearer token for each request, as Google Cloud Storage
+	// accepts
+	Token func(ctx context.Context) (string, error)
+}
+
+// S3 is a bucket of an S3-compatible store, or of Google Cloud Storage through
+// its XML API
+type S3 struct {
+	config S3Config
+	client *http.Client
+}
+
+// NewS3 creates a client of the configured bucket
+func NewS3(config S3Config) (*S3, error) {
+	if config.Endpoint == "" || config.Bucket == "" {
+		return nil, fmt.Errorf("object store needs an endpoint and bucket")
+	}
+	if config.Token == nil && (config.Credentials.AccessKey == "" || config.Credentials.SecretKey == "") {
+		return nil, fmt.Errorf("object store needs credentials")
+	}
+	if config.Credentials.Region == "" {
+		config.Credentials.Region = "us-east-1"
+	}
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+	return &S3{config: config, client: &http.Client{Timeout: time.Minute}}, nil
+}
+
+// Get implements Bucket
+func (s *S3) Get(ctx context.Context, name, etag string) ([]byte, string, error) {
+	header := http.Header{}
+	if etag != "" {
+		header.Set("If-None-Match", etag)
+	}
+	response, err := s.Do(ctx, http.MethodGet, name, nil, header, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer response.Body.Close()
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, response.Header.Get("ETag"), nil
+}
+
+// Put implements Bucket
+func (s *S3) Put(ctx context.Context, name string, data []byte) (string, error) {
+	response, err := s.Do(ctx, http.MethodPut, name, nil, nil, data)
+	if err != nil {
+		return "", err
+	}
+	response.Body.Close()
+	return response.Header.Get("ETag"), nil
+}
+
+// Delete implements Bucket
+func (s *S3) Delete(ctx context.Context, name string) error {
+	response, err := s.Do(ctx, http.MethodDelete, name, nil, nil, nil)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	return nil
+}
+
+// listResult is a page of ListObjectsV2
+type listResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		ETag         string    `xml:"ETag"`
+		LastModified time.Time `xml:"LastModified"`
+		Size         int64     `xml:"Size"`
+	} `xml:"Contents"`
+}
+
+// List implements Bucket
+func (s *S3) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var infos []ObjectInfo
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	for {
+		response, err := s.Do(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var page listResult
+		err = xml.NewDecoder(response.Body).Decode(&page)
+		response.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read object listing: %w", err)
+		}
+		for _, object := range page.Contents {
+			infos = append(infos, ObjectInfo{Name: object.Key, ETag: object.ETag, Size: object.Size, Modified: object.LastModified})
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return infos, nil
+		}
+		query.Set("continuation-token", page.NextContinuationToken)
+	}
+}
+
+// Do sends an authorized request for an object, or the bucket when name is
+// empty, and returns the response for its caller to close. Responses other
+// than 2xx become errors: 404 ErrNotFound and 304 ErrNotModified
+func (s *S3) Do(ctx context.Context, method, name string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	path := "/" + s.config.Bucket
+	if name != "" {
+		path += "/" + name
+	}
+	request, err := http.NewRequestWithContext(ctx, method, s.config.Endpoint+EscapePath(path)+CanonicalQuery(query), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		request.Header[key] = values
+	}
+	if s.config.Token != nil {
+		token, err := s.config.Token(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to authorize object store request: %w", err)
+		}
+		request.Header.Set("Authorization", "Bearer "+token)
+	} else {
+		s.config.Credentials.Sign(request, body, time.Now())
+	}
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return response, nil
+	}
+	defer response.Body.Close()
+	switch response.StatusCode {
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	case http.StatusNotModified:
+		return nil, ErrNotModified
+	}
+	message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+	return nil, fmt.Errorf("%s %s returned %d: %s", method, path, response.StatusCode, strings.TrimSpace(string(message)))
+}
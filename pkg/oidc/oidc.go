@@ -0,0 +1,269 @@
+// Package oidc signs people in with an OpenID Connect identity provider such
+// as Google, Okta or Azure AD. It runs the authorization code flow with PKCE
+// and verifies ID tokens against the keys the provider publishes
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken is returned for ID tokens that fail verification
+var ErrInvalidToken = errors.New("invalid ID token")
+
+// DefaultScopes are requested when the configuration names none
+var DefaultScopes = []string{"openid", "email", "profile"}
+
+// DefaultGroupsClaim is the ID token claim holding the user's groups
+const DefaultGroupsClaim = "groups"
+
+// clockSkew is how far token times may be off from ours
+const clockSkew = 2 * time.Minute
+
+// Config identifies the gateway to the identity provider
+type Config struct {
+	// Issuer is the provider's issuer URL, e.g. https://accounts.google.com
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is where the provider sends users back after login
+	RedirectURL string
+	Scopes      []string
+	// GroupsClaim names the claim listing the user's groups
+	GroupsClaim string
+}
+
+// Identity is the verified user of an ID token
+type Identity struct {
+	Subject string   `json:"subject"`
+	Email   string   `json:"email,omitempty"`
+	Name    string   `json:"name,omitempty"`
+	Groups  []string `json:"groups,omitempty"`
+}
+
+// discovery is the part of the provider metadata the flow needs
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider is an identity provider the gateway trusts
+type Provider struct {
+	config    Config
+	endpoints discovery
+	keys      *keySet
+	client    *http.Client
+}
+
+// NewProvider fetches the provider's metadata from its discovery document
+func NewProvider(ctx context.Context, config Config) (*Provider, error) {
+	if len(config.Scopes) == 0 {
+		config.Scopes = DefaultScopes
+	}
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = DefaultGroupsClaim
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	var endpoints discovery
+	wellKnown := strings.TrimSuffix(config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := getJSON(ctx, client, wellKnown, &endpoints); err != nil {
+		return nil, fmt.Errorf("failed to discover %s: %w", config.Issuer, err)
+	}
+	// The issuer must be the one configured, or tokens could be accepted
+	// from a provider that merely serves the same keys
+	if endpoints.Issuer != config.Issuer {
+		return nil, fmt.Errorf("discovery document of %s names issuer %q", config.Issuer, endpoints.Issuer)
+	}
+	if endpoints.AuthorizationEndpoint == "" || endpoints.TokenEndpoint == "" || endpoints.JWKSURI == "" {
+		return nil, fmt.Errorf("discovery document of %s lacks an endpoint", config.Issuer)
+	}
+
+	return &Provider{
+		config:    config,
+		endpoints: endpoints,
+		keys:      newKeySet(client, endpoints.JWKSURI),
+		client:    client,
+	}, nil
+}
+
+// AuthURL returns the provider's login page for a login with state, nonce
+// and PKCE verifier, all from NewLoginSecrets
+func (p *Provider) AuthURL(state, nonce, verifier string) string {
+	challenge := sha256.Sum256([]byte(verifier))
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(p.endpoints.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return p.endpoints.AuthorizationEndpoint + separator + params.Encode()
+}
+
+// Exchange trades an authorization code for the user's verified identity
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoints.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var token struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("invalid token response: %w", err)
+	}
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("token response has no id_token; is the openid scope requested?")
+	}
+	return p.Verify(ctx, token.IDToken, nonce)
+}
+
+// Verify checks an ID token's signature, issuer, audience, lifetime and
+// nonce and returns the identity it asserts
+func (p *Provider) Verify(ctx context.Context, rawToken, nonce string) (*Identity, error) {
+	payload, err := p.keys.verify(ctx, rawToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if claims["iss"] != p.config.Issuer {
+		return nil, fmt.Errorf("%w: issued by %v", ErrInvalidToken, claims["iss"])
+	}
+	if !audienceContains(claims["aud"], p.config.ClientID) {
+		return nil, fmt.Errorf("%w: issued for another client", ErrInvalidToken)
+	}
+	now := time.Now()
+	expiry, ok := claims["exp"].(float64)
+	if !ok || now.After(time.Unix(int64(expiry), 0).Add(clockSkew)) {
+		return nil, fmt.Errorf("%w: expired", ErrInvalidToken)
+	}
+	if issuedAt, ok := claims["iat"].(float64); ok && time.Unix(int64(issuedAt), 0).After(now.Add(clockSkew)) {
+		return nil, fmt.Errorf("%w: issued in the future", ErrInvalidToken)
+	}
+	if claims["nonce"] != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidToken)
+	}
+
+	identity := &Identity{}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Name, _ = claims["name"].(string)
+	// Unverified addresses could be claimed by anyone, so they are dropped
+	if email, ok := claims["email"].(string); ok && claims["email_verified"] != false {
+		identity.Email = email
+	}
+	identity.Groups = stringList(claims[p.config.GroupsClaim])
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidToken)
+	}
+	return identity, nil
+}
+
+// NewLoginSecrets returns a fresh state, nonce and PKCE verifier for one login
+func NewLoginSecrets() (state, nonce, verifier string, err error) {
+	values := make([]string, 3)
+	for i := range values {
+		random := make([]byte, 32)
+		if _, err := rand.Read(random); err != nil {
+			return "", "", "", err
+		}
+		values[i] = base64.RawURLEncoding.EncodeToString(random)
+	}
+	return values[0], values[1], values[2], nil
+}
+
+// audienceContains reports whether the aud claim, a string or a list,
+// includes clientID
+func audienceContains(aud interface{}, clientID string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == clientID
+	case []interface{}:
+		for _, value := range aud {
+			if value == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stringList reads a claim holding a string or a list of strings
+func stringList(claim interface{}) []string {
+	switch claim := claim.(type) {
+	case string:
+		return []string{claim}
+	case []interface{}:
+		values := make([]string, 0, len(claim))
+		for _, value := range claim {
+			if s, ok := value.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// getJSON fetches url and decodes its JSON body into v
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
@@ -0,0 +1,213 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testClientID = "pal-moe"
+	testNonce    = "nonce-1"
+	testKeyID    = "key-1"
+)
+
+// testIdP is an identity provider serving discovery, a JWKS with one RSA key
+// and a token endpoint answering with idToken
+type testIdP struct {
+	server  *httptest.Server
+	key     *rsa.PrivateKey
+	idToken string
+}
+
+// newTestIdP starts a provider; its discovery document names issuer, or
+// its own URL when issuer is empty
+func newTestIdP(t *testing.T, issuer string) *testIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &testIdP{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		name := issuer
+		if name == "" {
+			name = idp.server.URL
+		}
+		json.NewEncoder(w).Encode(discovery{
+			Issuer:                name,
+			AuthorizationEndpoint: idp.server.URL + "/authorize",
+			TokenEndpoint:         idp.server.URL + "/token",
+			JWKSURI:               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []jsonWebKey{{
+			Kty: "RSA",
+			Kid: testKeyID,
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idp.idToken})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// claims returns valid claims for a token of this provider
+func (idp *testIdP) claims() map[string]interface{} {
+	now := time.Now()
+	return map[string]interface{}{
+		"iss":    idp.server.URL,
+		"aud":    testClientID,
+		"sub":    "user-1",
+		"email":  "ops@example.com",
+		"groups": []string{"platform"},
+		"nonce":  testNonce,
+		"iat":    now.Unix(),
+		"exp":    now.Add(time.Hour).Unix(),
+	}
+}
+
+// signToken returns a compact RS256 JWS of claims signed by key under kid
+func signToken(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": kid})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// newTestProvider discovers idp
+func newTestProvider(t *testing.T, idp *testIdP) *Provider {
+	t.Helper()
+	provider, err := NewProvider(context.Background(), Config{
+		Issuer:      idp.server.URL,
+		ClientID:    testClientID,
+		RedirectURL: "https://gateway.example.com/admin/sso/callback",
+	})
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	return provider
+}
+
+func TestVerifyValidToken(t *testing.T) {
+	idp := newTestIdP(t, "")
+	provider := newTestProvider(t, idp)
+
+	identity, err := provider.Verify(context.Background(), signToken(t, idp.key, testKeyID, idp.claims()), testNonce)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if identity.Subject != "user-1" || identity.Email != "ops@example.com" || len(identity.Groups) != 1 || identity.Groups[0] != "platform" {
+		t.Errorf("identity = %+v", *identity)
+	}
+}
+
+func TestVerifyRejectsInvalidTokens(t *testing.T) {
+	idp := newTestIdP(t, "")
+	provider := newTestProvider(t, idp)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		key    *rsa.PrivateKey
+		kid    string
+		change func(claims map[string]interface{})
+	}{
+		{"wrong issuer", idp.key, testKeyID, func(claims map[string]interface{}) {
+			claims["iss"] = "https://attacker.example.com"
+		}},
+		{"wrong audience", idp.key, testKeyID, func(claims map[string]interface{}) {
+			claims["aud"] = "another-client"
+		}},
+		{"expired", idp.key, testKeyID, func(claims map[string]interface{}) {
+			claims["exp"] = time.Now().Add(-time.Hour).Unix()
+		}},
+		{"issued in the future", idp.key, testKeyID, func(claims map[string]interface{}) {
+			claims["iat"] = time.Now().Add(time.Hour).Unix()
+		}},
+		{"wrong nonce", idp.key, testKeyID, func(claims map[string]interface{}) {
+			claims["nonce"] = "replayed"
+		}},
+		{"unknown kid", idp.key, "key-2", nil},
+		{"signed by another key", otherKey, testKeyID, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := idp.claims()
+			if tt.change != nil {
+				tt.change(claims)
+			}
+			_, err := provider.Verify(context.Background(), signToken(t, tt.key, tt.kid, claims), testNonce)
+			if !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Verify = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsUnsignedToken(t *testing.T) {
+	idp := newTestIdP(t, "")
+	provider := newTestProvider(t, idp)
+
+	header, _ := json.Marshal(map[string]string{"alg": "none", "kid": testKeyID})
+	payload, _ := json.Marshal(idp.claims())
+	token := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload) + "."
+	if _, err := provider.Verify(context.Background(), token, testNonce); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Verify of an alg none token = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestNewProviderRejectsIssuerMismatch(t *testing.T) {
+	idp := newTestIdP(t, "https://attacker.example.com")
+	_, err := NewProvider(context.Background(), Config{Issuer: idp.server.URL, ClientID: testClientID})
+	if err == nil || !strings.Contains(err.Error(), "names issuer") {
+		t.Errorf("NewProvider = %v, want an issuer mismatch", err)
+	}
+}
+
+func TestExchange(t *testing.T) {
+	idp := newTestIdP(t, "")
+	provider := newTestProvider(t, idp)
+
+	idp.idToken = signToken(t, idp.key, testKeyID, idp.claims())
+	if identity, err := provider.Exchange(context.Background(), "code", "verifier", testNonce); err != nil || identity.Subject != "user-1" {
+		t.Errorf("Exchange = %+v, %v", identity, err)
+	}
+
+	claims := idp.claims()
+	claims["aud"] = "another-client"
+	idp.idToken = signToken(t, idp.key, testKeyID, claims)
+	if _, err := provider.Exchange(context.Background(), "code", "verifier", testNonce); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Exchange of a token for another client = %v, want ErrInvalidToken", err)
+	}
+}
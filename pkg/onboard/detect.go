@@ -0,0 +1,156 @@
+package onboard
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// anthropicVersion is the API version sent when listing Anthropic models
+const anthropicVersion = "2023-06-01"
+
+// maxResponseBytes caps how much of a model listing is read
+const maxResponseBytes = 4 << 20
+
+// detection is what was learned about a provider's API
+type detection struct {
+	flavor Flavor
+	// endpoint is the base URL requests are sent to, which gains /v1 when
+	// the provider was given by its root URL
+	endpoint string
+	models   []string
+}
+
+// modelListing is a model list in the OpenAI or Anthropic format. Both put
+// the models under data; Anthropic marks each with type "model"
+type modelListing struct {
+	Data []struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+	} `json:"data"`
+}
+
+// listError is a model listing request that got an HTTP response, but not a
+// model list
+type listError struct {
+	status int
+}
+
+func (e *listError) Error() string {
+	return fmt.Sprintf("status %d", e.status)
+}
+
+// detect works out which API baseURL speaks by asking it for its models the
+// OpenAI way, then the Anthropic way. Providers given by their root URL are
+// tried again under /v1. A provider that answers neither is Custom
+func (w *Wizard) detect(ctx context.Context, baseURL, apiKey string) (*detection, error) {
+	endpoints := []string{baseURL}
+	if !strings.HasSuffix(baseURL, "/v1") {
+		endpoints = append(endpoints, baseURL+"/v1")
+	}
+
+	var reached, rejected bool
+	var lastErr error
+	for _, endpoint := range endpoints {
+		for _, flavor := range []Flavor{OpenAICompatible, Anthropic} {
+			found, models, err := w.listModels(ctx, endpoint, flavor, apiKey)
+			if err == nil {
+				return &detection{flavor: found, endpoint: endpoint, models: models}, nil
+			}
+			lastErr = err
+			if le, ok := err.(*listError); ok {
+				reached = true
+				if le.status == http.StatusUnauthorized || le.status == http.StatusForbidden {
+					rejected = true
+				}
+			}
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("%w: %v", ErrUnreachable, ctx.Err())
+			}
+		}
+	}
+
+	switch {
+	case rejected:
+		return nil, fmt.Errorf("%w: %s answered with %v", ErrRejected, baseURL, lastErr)
+	case !reached:
+		return nil, fmt.Errorf("%w: %s: %v", ErrUnreachable, baseURL, lastErr)
+	}
+	return &detection{flavor: Custom, endpoint: baseURL, models: []string{}}, nil
+}
+
+// listModels asks endpoint for its models as flavor would list them and
+// returns the flavor the listing turned out to be in
+func (w *Wizard) listModels(ctx context.Context, endpoint string, flavor Flavor, apiKey string) (Flavor, []string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"/models", nil)
+	if err != nil {
+		return "", nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "Your-PaL-MoE/1.0")
+	switch {
+	case flavor == Anthropic:
+		req.Header.Set("anthropic-version", anthropicVersion)
+		if apiKey != "" {
+			req.Header.Set("x-api-key", apiKey)
+		}
+	case apiKey != "":
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, &listError{status: resp.StatusCode}
+	}
+
+	var listing modelListing
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(&listing); err != nil || listing.Data == nil {
+		// Anything else answering 200, such as a web page, is not a model list
+		return "", nil, &listError{status: resp.StatusCode}
+	}
+
+	models := make([]string, 0, len(listing.Data))
+	for _, model := range listing.Data {
+		if model.Type == "model" {
+			flavor = Anthropic
+		}
+		if model.ID != "" {
+			models = append(models, model.ID)
+		}
+	}
+	sort.Strings(models)
+	return flavor, models, nil
+}
+
+// nonChatMarkers appear in the names of models that cannot answer a chat
+// completion, which probing would wrongly report as unsupported
+var nonChatMarkers = []string{"embed", "whisper", "tts", "dall-e", "moderation", "transcribe", "rerank"}
+
+// chatModel picks the model to probe: the first that looks like a chat model
+func chatModel(models []string) string {
+	for _, model := range models {
+		lower := strings.ToLower(model)
+		chat := true
+		for _, marker := range nonChatMarkers {
+			if strings.Contains(lower, marker) {
+				chat = false
+				break
+			}
+		}
+		if chat {
+			return model
+		}
+	}
+	if len(models) > 0 {
+		return models[0]
+	}
+	return ""
+}
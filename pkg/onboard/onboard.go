@@ -0,0 +1,407 @@
+// Package onboard drafts the configuration of a new provider from little more
+// than its base URL. It detects which API the provider speaks, discovers its
+// models and probes their capabilities, then proposes a providers.csv row and
+// YAML file that are only written once an operator confirms them
+package onboard
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/logging"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/probe"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
+	"gopkg.in/yaml.v3"
+)
+
+var logger = logging.Module("onboard")
+
+// Flavor is the API a provider speaks
+type Flavor string
+
+const (
+	// OpenAICompatible providers list models at /models and answer chat
+	// completions at /chat/completions
+	OpenAICompatible Flavor = "openai"
+	// Anthropic providers list models in Anthropic's format behind an
+	// x-api-key header; chat goes through their OpenAI-compatible endpoint
+	Anthropic Flavor = "anthropic"
+	// Custom providers answer but list no models in a known format, so their
+	// models and capabilities are filled in by hand
+	Custom Flavor = "custom"
+)
+
+var (
+	// ErrInvalidRequest is returned for onboarding requests that cannot be tried
+	ErrInvalidRequest = errors.New("invalid onboarding request")
+	// ErrUnreachable is returned when the provider could not be reached at all
+	ErrUnreachable = errors.New("provider could not be reached")
+	// ErrRejected is returned when the provider refused the API key
+	ErrRejected = errors.New("provider rejected the API key")
+	// ErrUnknownProposal is returned for proposals that were never made, were
+	// already confirmed or discarded, or have expired
+	ErrUnknownProposal = errors.New("unknown or expired proposal")
+)
+
+// ProposalTTL is how long a proposal waits for confirmation
+const ProposalTTL = 30 * time.Minute
+
+// maxPending bounds the proposals kept waiting; the oldest is dropped first
+const maxPending = 100
+
+// maxListedModels is the most models written into the models column; larger
+// catalogs are referenced by their models endpoint instead
+const maxListedModels = 25
+
+// Request is what an operator knows about a new provider
+type Request struct {
+	BaseURL string `json:"base_url"`
+	// APIKey is used to detect and probe the provider and is never stored;
+	// the gateway reads it from the variable named in the proposal
+	APIKey string `json:"api_key,omitempty"`
+	// Name defaults to one derived from the host of BaseURL
+	Name string `json:"name,omitempty"`
+	// Tier defaults to official with an API key and community without
+	Tier tier.Tier `json:"tier,omitempty"`
+	// Model is the model probed, by default the first chat model discovered
+	Model string `json:"model,omitempty"`
+}
+
+// Proposal is the drafted configuration of a provider awaiting confirmation
+type Proposal struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Flavor   Flavor    `json:"flavor"`
+	Endpoint string    `json:"endpoint"`
+	Tier     tier.Tier `json:"tier"`
+	Models   []string  `json:"models"`
+	// Probe is the capability probe of one model, absent for Custom providers
+	Probe *probe.Result `json:"probe,omitempty"`
+	// APIKeyVariable is the environment variable the gateway reads the key from
+	APIKeyVariable string    `json:"api_key_variable,omitempty"`
+	CSVRow         string    `json:"csv_row"`
+	YAMLFile       string    `json:"yaml_file"`
+	YAML           string    `json:"yaml"`
+	Warnings       []string  `json:"warnings,omitempty"`
+	ExpiresAt      time.Time `json:"expires_at"`
+
+	row config.CSVProvider
+}
+
+// Commit is what confirming a proposal wrote
+type Commit struct {
+	Proposal  *Proposal          `json:"proposal"`
+	Revisions []*config.Revision `json:"revisions"`
+}
+
+// providerYAML is the provider YAML file, in the layout of the files the
+// gateway generates itself
+type providerYAML struct {
+	Name         string    `yaml:"name"`
+	BaseURL      string    `yaml:"base_url"`
+	Flavor       Flavor    `yaml:"api_flavor"`
+	Models       []string  `yaml:"models"`
+	Tier         tier.Tier `yaml:"tier"`
+	CostPerToken float64   `yaml:"cost_per_token"`
+	Capabilities []string  `yaml:"capabilities"`
+}
+
+// Wizard drafts provider configurations and writes the confirmed ones
+type Wizard struct {
+	client  *http.Client
+	prober  *probe.Prober
+	history *config.ConfigHistory
+	csvPath string
+	yamlDir string
+	pending map[string]*Proposal
+	mutex   sync.Mutex
+}
+
+// NewWizard creates a wizard that adds confirmed providers to the CSV at
+// csvPath and the YAML directory yamlDir, both tracked by history. Each
+// request to a provider times out after timeout
+func NewWizard(history *config.ConfigHistory, csvPath, yamlDir string, timeout time.Duration) *Wizard {
+	return &Wizard{
+		client:  &http.Client{Timeout: timeout},
+		prober:  probe.NewProber(timeout),
+		history: history,
+		csvPath: csvPath,
+		yamlDir: yamlDir,
+		pending: make(map[string]*Proposal),
+	}
+}
+
+// Propose detects, discovers and probes the provider of req and drafts its
+// configuration. Nothing is written until the proposal is confirmed
+func (w *Wizard) Propose(ctx context.Context, req Request) (*Proposal, error) {
+	baseURL, host, err := parseBaseURL(req.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = nameFromHost(host)
+	}
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+	providerTier := tier.Community
+	if req.APIKey != "" {
+		providerTier = tier.Official
+	}
+	if req.Tier != "" {
+		if providerTier, err = tier.Parse(string(req.Tier)); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+		}
+	}
+	// Fail before contacting the provider when it could never be added
+	if err := w.checkAvailable(name); err != nil {
+		return nil, err
+	}
+
+	found, err := w.detect(ctx, baseURL, req.APIKey)
+	if err != nil {
+		return nil, err
+	}
+	proposal := &Proposal{
+		Name:     name,
+		Flavor:   found.flavor,
+		Endpoint: found.endpoint,
+		Tier:     providerTier,
+		Models:   found.models,
+	}
+
+	if found.flavor == Custom {
+		proposal.warn("%s lists no models in the OpenAI or Anthropic format; fill in the models column by hand", found.endpoint)
+	} else if len(found.models) == 0 {
+		proposal.warn("%s lists no models", found.endpoint)
+	} else {
+		model := req.Model
+		if model == "" {
+			model = chatModel(found.models)
+		} else if !slices.Contains(found.models, model) {
+			proposal.warn("model %s is not among the discovered models", model)
+		}
+		proposal.Probe = w.prober.Probe(ctx, probe.Target{Provider: name, BaseURL: found.endpoint, Model: model, APIKey: req.APIKey})
+		if proposal.Probe.Error != "" {
+			proposal.warn("model %s did not answer a chat completion, so no capability was verified: %s", model, proposal.Probe.Error)
+		}
+	}
+
+	if req.APIKey != "" {
+		proposal.APIKeyVariable = config.ProviderAPIKeyVariable(name)
+		proposal.warn("set %s to the API key; it is not written to the configuration", proposal.APIKeyVariable)
+	}
+	if err := proposal.draft(); err != nil {
+		return nil, err
+	}
+
+	if err := w.add(proposal); err != nil {
+		return nil, err
+	}
+	logger.Infof("Proposed provider %s: %s API at %s with %d models", name, proposal.Flavor, proposal.Endpoint, len(proposal.Models))
+	return proposal, nil
+}
+
+// Confirm writes a proposal to the provider CSV and YAML directory, recording
+// both in the configuration history under author
+func (w *Wizard) Confirm(id, author string) (*Commit, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	proposal, ok := w.pending[id]
+	if !ok || time.Now().After(proposal.ExpiresAt) {
+		delete(w.pending, id)
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProposal, id)
+	}
+	if err := w.checkAvailable(proposal.Name); err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(w.csvPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read %s: %w", w.csvPath, err)
+	}
+	content, err = config.AppendProviderCSV(content, proposal.row)
+	if err != nil {
+		return nil, err
+	}
+
+	message := fmt.Sprintf("onboard provider %s", proposal.Name)
+	csvRevision, err := w.history.Write(w.csvPath, content, author, message)
+	if err != nil {
+		return nil, err
+	}
+	yamlRevision, err := w.history.Write(filepath.Join(w.yamlDir, proposal.YAMLFile), []byte(proposal.YAML), author, message)
+	if err != nil {
+		return nil, err
+	}
+	delete(w.pending, id)
+
+	logger.Infof("Onboarded provider %s, confirmed by %s", proposal.Name, author)
+	return &Commit{Proposal: proposal, Revisions: []*config.Revision{csvRevision, yamlRevision}}, nil
+}
+
+// Discard drops a proposal without writing anything
+func (w *Wizard) Discard(id string) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if _, ok := w.pending[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownProposal, id)
+	}
+	delete(w.pending, id)
+	return nil
+}
+
+// checkAvailable returns config.ErrProviderExists when name is already in the
+// CSV or has a YAML file
+func (w *Wizard) checkAvailable(name string) error {
+	content, err := os.ReadFile(w.csvPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read %s: %w", w.csvPath, err)
+	}
+	if _, err := config.AppendProviderCSV(content, config.CSVProvider{Name: name}); err != nil {
+		return err
+	}
+	file := config.ProviderYAMLFileName(name)
+	if _, err := os.Stat(filepath.Join(w.yamlDir, file)); err == nil {
+		return fmt.Errorf("%w: %s already has the YAML file %s", config.ErrProviderExists, name, file)
+	}
+	return nil
+}
+
+// add keeps proposal until it is confirmed, discarded or expires
+func (w *Wizard) add(proposal *Proposal) error {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return err
+	}
+	proposal.ID = hex.EncodeToString(random)
+	proposal.ExpiresAt = time.Now().Add(ProposalTTL).UTC()
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	var oldest *Proposal
+	for id, pending := range w.pending {
+		if time.Now().After(pending.ExpiresAt) {
+			delete(w.pending, id)
+		} else if oldest == nil || pending.ExpiresAt.Before(oldest.ExpiresAt) {
+			oldest = pending
+		}
+	}
+	if len(w.pending) >= maxPending && oldest != nil {
+		delete(w.pending, oldest.ID)
+	}
+	w.pending[proposal.ID] = proposal
+	return nil
+}
+
+// draft fills in the CSV row and YAML file of the proposal
+func (p *Proposal) draft() error {
+	var capabilities []string
+	if p.Probe != nil {
+		for _, feature := range p.Probe.Verified() {
+			capabilities = append(capabilities, string(feature))
+		}
+	}
+
+	p.row = config.CSVProvider{
+		Name:         p.Name,
+		Tier:         p.Tier,
+		Endpoint:     p.Endpoint,
+		ModelsSource: strings.Join(p.Models, "|"),
+		Capabilities: capabilities,
+		Description:  fmt.Sprintf("%s API, onboarded %s", p.Flavor, time.Now().UTC().Format("2006-01-02")),
+	}
+	if len(p.Models) > maxListedModels && p.Flavor == OpenAICompatible {
+		p.row.ModelsSource = "/models"
+		p.warn("%d models were discovered, so the models column lists them from /models", len(p.Models))
+	}
+	if p.APIKeyVariable != "" {
+		// The empty default keeps the CSV loadable before the variable is set
+		p.row.APIKey = "${" + p.APIKeyVariable + ":-}"
+	}
+
+	row, err := config.FormatProviderCSVRow(p.row)
+	if err != nil {
+		return err
+	}
+	p.CSVRow = row
+
+	document, err := yaml.Marshal(providerYAML{
+		Name:         p.Name,
+		BaseURL:      p.Endpoint,
+		Flavor:       p.Flavor,
+		Models:       p.Models,
+		Tier:         p.Tier,
+		CostPerToken: config.DefaultCostTracking(p.Tier).CostPerToken,
+		Capabilities: capabilities,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to generate YAML: %w", err)
+	}
+	p.YAMLFile = config.ProviderYAMLFileName(p.Name)
+	p.YAML = string(document)
+	return nil
+}
+
+// warn adds a note for the operator to the proposal
+func (p *Proposal) warn(format string, args ...interface{}) {
+	p.Warnings = append(p.Warnings, fmt.Sprintf(format, args...))
+}
+
+// parseBaseURL checks that raw is an http(s) URL and returns it without a
+// trailing slash, together with its host name
+func parseBaseURL(raw string) (string, string, error) {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return "", "", fmt.Errorf("%w: base_url must be an http or https URL, got %q", ErrInvalidRequest, raw)
+	}
+	if parsed.User != nil || parsed.RawQuery != "" || parsed.Fragment != "" {
+		return "", "", fmt.Errorf("%w: base_url must not carry credentials, a query or a fragment", ErrInvalidRequest)
+	}
+	return strings.TrimRight(parsed.String(), "/"), parsed.Hostname(), nil
+}
+
+// nameFromHost derives a provider name from a host, e.g. Groq for api.groq.com
+func nameFromHost(host string) string {
+	if net.ParseIP(host) != nil {
+		return "Provider_" + strings.NewReplacer(".", "_", ":", "_").Replace(host)
+	}
+	labels := strings.Split(host, ".")
+	for len(labels) > 2 && (labels[0] == "api" || labels[0] == "www") {
+		labels = labels[1:]
+	}
+	name := []rune(labels[0])
+	name[0] = unicode.ToUpper(name[0])
+	return string(name)
+}
+
+// validateName keeps provider names to a line of printable text
+func validateName(name string) error {
+	if len(name) > 64 {
+		return fmt.Errorf("%w: name must be at most 64 characters", ErrInvalidRequest)
+	}
+	for _, r := range name {
+		if !unicode.IsPrint(r) {
+			return fmt.Errorf("%w: name must be printable", ErrInvalidRequest)
+		}
+	}
+	return nil
+}
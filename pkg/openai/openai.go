@@ -0,0 +1,281 @@
+// Package openai holds the wire types of the OpenAI-compatible chat API the
+// gateway serves, so frameworks such as LangChain and LlamaIndex can use it
+// as their base URL, and the token accounting their usage blocks follow
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Object names of the response types
+const (
+	ObjectChatCompletion      = "chat.completion"
+	ObjectChatCompletionChunk = "chat.completion.chunk"
+	ObjectModel               = "model"
+	ObjectList                = "list"
+)
+
+// OwnedBy is the owner listed for virtual models and model aliases
+const OwnedBy = "palmoe"
+
+// Finish reasons of a choice
+const (
+	// FinishStop means the model finished its answer or hit a stop sequence
+	FinishStop = "stop"
+	// FinishLength means the answer was cut off at max_tokens
+	FinishLength = "length"
+)
+
+// Roles of chat messages
+const (
+	RoleSystem    = "system"
+	RoleDeveloper = "developer"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// Content is the text of a message. Clients send either a string or a list of
+// content parts, of which the text parts are kept
+type Content string
+
+// UnmarshalJSON accepts a string, null or a list of content parts
+func (c *Content) UnmarshalJSON(data []byte) error {
+	var text *string
+	if err := json.Unmarshal(data, &text); err == nil {
+		if text != nil {
+			*c = Content(*text)
+		}
+		return nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return fmt.Errorf("content must be a string or a list of content parts")
+	}
+	var texts []string
+	for _, part := range parts {
+		if part.Type == "text" {
+			texts = append(texts, part.Text)
+		}
+	}
+	*c = Content(strings.Join(texts, "\n"))
+	return nil
+}
+
+// Message is one chat message
+type Message struct {
+	Role    string  `json:"role"`
+	Content Content `json:"content"`
+	Name    string  `json:"name,omitempty"`
+}
+
+// Stop holds the stop sequences, sent as a string or a list of strings
+type Stop []string
+
+// UnmarshalJSON accepts a string, null or a list of strings
+func (s *Stop) UnmarshalJSON(data []byte) error {
+	var single *string
+	if err := json.Unmarshal(data, &single); err == nil {
+		if single != nil {
+			*s = Stop{*single}
+		}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("stop must be a string or a list of strings")
+	}
+	*s = list
+	return nil
+}
+
+// StreamOptions tune a streamed response
+type StreamOptions struct {
+	// IncludeUsage adds a final chunk with no choices carrying the usage
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// ChatCompletionRequest is the body of POST /v1/chat/completions. Fields the
+// gateway has no use for, such as tools or logprobs, are accepted and ignored
+type ChatCompletionRequest struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+	// MaxCompletionTokens replaces MaxTokens in newer clients; either caps
+	// the answer
+	MaxTokens           int            `json:"max_tokens,omitempty"`
+	MaxCompletionTokens int            `json:"max_completion_tokens,omitempty"`
+	Temperature         *float64       `json:"temperature,omitempty"`
+	TopP                *float64       `json:"top_p,omitempty"`
+	FrequencyPenalty    *float64       `json:"frequency_penalty,omitempty"`
+	PresencePenalty     *float64       `json:"presence_penalty,omitempty"`
+	Stop                Stop           `json:"stop,omitempty"`
+	N                   int            `json:"n,omitempty"`
+	Stream              bool           `json:"stream,omitempty"`
+	StreamOptions       *StreamOptions `json:"stream_options,omitempty"`
+	User                string         `json:"user,omitempty"`
+	// LogitBias maps token IDs to a bias from -100 to 100
+	LogitBias map[string]float64 `json:"logit_bias,omitempty"`
+	// BestOf generates that many candidates and returns the best N, as the
+	// legacy completions API does
+	BestOf int `json:"best_of,omitempty"`
+	// Seed makes the gateway's routing reproducible, as seed on
+	// /api/v1/process does
+	Seed *int64 `json:"seed,omitempty"`
+	// Metadata becomes the request's analytics tags
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// ReasoningEffort is low, medium or high, and routes the request to a
+	// reasoning model
+	ReasoningEffort string `json:"reasoning_effort,omitempty"`
+}
+
+// CompletionLimit returns the most tokens the answer may have, 0 when unlimited
+func (r *ChatCompletionRequest) CompletionLimit() int {
+	if r.MaxCompletionTokens > 0 {
+		return r.MaxCompletionTokens
+	}
+	return r.MaxTokens
+}
+
+// Prompt flattens the conversation into the single prompt the gateway routes.
+// A lone user message is sent as is; longer conversations are rendered one
+// "role: content" block per message
+func (r *ChatCompletionRequest) Prompt() string {
+	if len(r.Messages) == 1 && r.Messages[0].Role == RoleUser {
+		return string(r.Messages[0].Content)
+	}
+	blocks := make([]string, 0, len(r.Messages))
+	for _, message := range r.Messages {
+		role := message.Role
+		if message.Name != "" {
+			role += " (" + message.Name + ")"
+		}
+		blocks = append(blocks, role+": "+string(message.Content))
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+// Usage is the token usage of a completion. TotalTokens is always the sum of
+// the other two
+type Usage struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+	// CompletionTokensDetails splits out the reasoning tokens of a reasoning
+	// model, which completion tokens include
+	CompletionTokensDetails *CompletionTokensDetails `json:"completion_tokens_details,omitempty"`
+}
+
+// CompletionTokensDetails breaks down the completion tokens of a usage
+type CompletionTokensDetails struct {
+	ReasoningTokens int64 `json:"reasoning_tokens"`
+}
+
+// NewUsage returns the usage of a completion from its two token counts
+func NewUsage(promptTokens, completionTokens int64) Usage {
+	return Usage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
+	}
+}
+
+// Choice is one answer of a completion
+type Choice struct {
+	Index        int     `json:"index"`
+	Message      Message `json:"message"`
+	FinishReason string  `json:"finish_reason"`
+}
+
+// ChatCompletion is the response of a non-streamed completion
+type ChatCompletion struct {
+	ID      string   `json:"id"`
+	Object  string   `json:"object"`
+	Created int64    `json:"created"`
+	Model   string   `json:"model"`
+	Choices []Choice `json:"choices"`
+	Usage   Usage    `json:"usage"`
+}
+
+// Delta is the part of a message carried by one chunk
+type Delta struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// ChunkChoice is one answer's part in a chunk. FinishReason is null until the
+// last chunk of the answer
+type ChunkChoice struct {
+	Index        int     `json:"index"`
+	Delta        Delta   `json:"delta"`
+	FinishReason *string `json:"finish_reason"`
+}
+
+// ChatCompletionChunk is one server-sent event of a streamed completion
+type ChatCompletionChunk struct {
+	ID      string        `json:"id"`
+	Object  string        `json:"object"`
+	Created int64         `json:"created"`
+	Model   string        `json:"model"`
+	Choices []ChunkChoice `json:"choices"`
+	// Usage is only set on the final chunk of streams that asked for it
+	Usage *Usage `json:"usage,omitempty"`
+}
+
+// Model is an entry of GET /v1/models
+type Model struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// ModelList is the response of GET /v1/models
+type ModelList struct {
+	Object string  `json:"object"`
+	Data   []Model `json:"data"`
+}
+
+// Error types, which clients map to exception classes
+const (
+	ErrorInvalidRequest    = "invalid_request_error"
+	ErrorAuthentication    = "authentication_error"
+	ErrorPermission        = "permission_error"
+	ErrorNotFound          = "not_found_error"
+	ErrorRateLimit         = "rate_limit_error"
+	ErrorInsufficientQuota = "insufficient_quota"
+	ErrorTimeout           = "timeout"
+	ErrorServer            = "server_error"
+)
+
+// Error is the error object of an error response
+type Error struct {
+	Message string  `json:"message"`
+	Type    string  `json:"type"`
+	Param   *string `json:"param"`
+	Code    *string `json:"code"`
+}
+
+// ErrorResponse is the body of every error the compatible API answers with
+type ErrorResponse struct {
+	Error Error `json:"error"`
+}
+
+// WriteError writes an error response. param and code are left null when empty
+func WriteError(w http.ResponseWriter, status int, errorType, message, param, code string) {
+	body := ErrorResponse{Error: Error{Message: message, Type: errorType}}
+	if param != "" {
+		body.Error.Param = &param
+	}
+	if code != "" {
+		body.Error.Code = &code
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,183 @@
+package openai
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Token overheads of the chat format, as counted by tiktoken for the
+// gpt-3.5-turbo and gpt-4 families: every message is wrapped in three tokens,
+// a name costs one more, and the reply is primed with three
+const (
+	tokensPerMessage = 3
+	tokensPerName    = 1
+	tokensReplyPrime = 3
+)
+
+// CountTokens estimates the cl100k_base tokens of text. Text is split into
+// the pieces tiktoken's pre-tokenizer produces - words with their leading
+// space, runs of up to three digits, punctuation runs and whitespace - and
+// each piece is charged like the encoder tends to: short words are a single
+// token, longer ones one per eight letters, other scripts one per character
+func CountTokens(text string) int64 {
+	var tokens int64
+	for _, piece := range pieces(text) {
+		tokens += pieceTokens(piece)
+	}
+	return tokens
+}
+
+// CountMessageTokens estimates the prompt tokens of a conversation the way
+// OpenAI bills it, including the per-message and reply overheads
+func CountMessageTokens(messages []Message) int64 {
+	tokens := int64(tokensReplyPrime)
+	for _, message := range messages {
+		tokens += tokensPerMessage + CountTokens(message.Role) + CountTokens(string(message.Content))
+		if message.Name != "" {
+			tokens += tokensPerName + CountTokens(message.Name)
+		}
+	}
+	return tokens
+}
+
+// TruncateTokens cuts text after at most limit tokens, on a piece boundary.
+// The second result reports whether anything was cut
+func TruncateTokens(text string, limit int) (string, bool) {
+	if limit <= 0 {
+		return text, false
+	}
+	var tokens int64
+	offset := 0
+	for _, piece := range pieces(text) {
+		tokens += pieceTokens(piece)
+		if tokens > int64(limit) {
+			return text[:offset], true
+		}
+		offset += len(piece)
+	}
+	return text, false
+}
+
+// CutAtStop cuts text before the earliest stop sequence. The second result
+// reports whether a stop sequence was found
+func CutAtStop(text string, stop []string) (string, bool) {
+	cut := -1
+	for _, sequence := range stop {
+		if sequence == "" {
+			continue
+		}
+		if i := strings.Index(text, sequence); i >= 0 && (cut < 0 || i < cut) {
+			cut = i
+		}
+	}
+	if cut < 0 {
+		return text, false
+	}
+	return text[:cut], true
+}
+
+// pieces splits text like the cl100k_base pre-tokenizer. The pieces cover
+// text exactly, in order
+func pieces(text string) []string {
+	var result []string
+	for len(text) > 0 {
+		n := pieceLength(text)
+		result = append(result, text[:n])
+		text = text[n:]
+	}
+	return result
+}
+
+// pieceLength returns the byte length of the piece text starts with
+func pieceLength(text string) int {
+	first, size := utf8.DecodeRuneInString(text)
+
+	// Contractions such as 's and 're
+	if first == '\'' {
+		lower := strings.ToLower(text)
+		for _, suffix := range []string{"'s", "'t", "'re", "'ve", "'m", "'ll", "'d"} {
+			if strings.HasPrefix(lower, suffix) {
+				return len(suffix)
+			}
+		}
+	}
+
+	// Digits come in groups of up to three
+	if unicode.IsDigit(first) {
+		n := size
+		for digits := 1; digits < 3 && n < len(text); digits++ {
+			r, width := utf8.DecodeRuneInString(text[n:])
+			if !unicode.IsDigit(r) {
+				break
+			}
+			n += width
+		}
+		return n
+	}
+
+	// A word may take one leading non-letter, usually a space
+	n := 0
+	if !unicode.IsLetter(first) && first != '\n' && first != '\r' && size < len(text) {
+		if next, _ := utf8.DecodeRuneInString(text[size:]); unicode.IsLetter(next) {
+			n = size
+		}
+	}
+	if unicode.IsLetter(first) || n > 0 {
+		n = spanOf(text, n, unicode.IsLetter)
+		return n
+	}
+
+	// Whitespace runs, keeping a trailing space for the word that follows
+	if unicode.IsSpace(first) {
+		n = spanOf(text, 0, unicode.IsSpace)
+		if n < len(text) && n > size {
+			last, width := utf8.DecodeLastRuneInString(text[:n])
+			if last == ' ' {
+				n -= width
+			}
+		}
+		return n
+	}
+
+	// Punctuation and symbols
+	return spanOf(text, 0, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r)
+	})
+}
+
+// spanOf returns the offset after the runes from start on that match keep
+func spanOf(text string, start int, keep func(rune) bool) int {
+	n := start
+	for n < len(text) {
+		r, width := utf8.DecodeRuneInString(text[n:])
+		if !keep(r) {
+			break
+		}
+		n += width
+	}
+	return n
+}
+
+// pieceTokens estimates the tokens of one piece
+func pieceTokens(piece string) int64 {
+	word := strings.TrimLeft(piece, " ")
+	if word == "" {
+		return 1
+	}
+	runes := utf8.RuneCountInString(word)
+	if runes < len(word) {
+		// Outside ASCII the encoder rarely merges characters
+		return int64(runes)
+	}
+	first, _ := utf8.DecodeRuneInString(word)
+	switch {
+	case unicode.IsLetter(first):
+		return int64((len(word) + 7) / 8)
+	case unicode.IsDigit(first), unicode.IsSpace(first), first == '\'' && len(word) <= 3:
+		// Digit groups, whitespace runs and contractions
+		return 1
+	default:
+		return int64((len(word) + 1) / 2)
+	}
+}
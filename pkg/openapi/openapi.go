@@ -0,0 +1,235 @@
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Version is the OpenAPI specification version emitted by this package
+const Version = "3.0.3"
+
+// Document is an OpenAPI 3 document
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Servers    []Server             `json:"servers,omitempty"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// Server is a base URL the API is served from
+type Server struct {
+	URL string `json:"url"`
+}
+
+// Components holds reusable schemas
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// PathItem holds the operations of a single path keyed by lower-case HTTP method
+type PathItem map[string]*Operation
+
+// Operation describes a single endpoint
+type Operation struct {
+	Summary     string               `json:"summary,omitempty"`
+	OperationID string               `json:"operationId,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter is a path, query or header parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes the accepted request payload
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response describes a single response status
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType binds a schema to a content type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Builder assembles a Document, deriving schemas from Go types
+type Builder struct {
+	doc   *Document
+	types map[string]string
+}
+
+// NewBuilder creates a builder for an API with the given title and version
+func NewBuilder(title, version, description string) *Builder {
+	return &Builder{
+		doc: &Document{
+			OpenAPI: Version,
+			Info: Info{
+				Title:       title,
+				Description: description,
+				Version:     version,
+			},
+			Paths:      make(map[string]*PathItem),
+			Components: Components{Schemas: make(map[string]*Schema)},
+		},
+		types: make(map[string]string),
+	}
+}
+
+// AddSchema registers a hand-written component schema and returns a reference to it
+func (b *Builder) AddSchema(name string, schema *Schema) *Schema {
+	b.doc.Components.Schemas[name] = schema
+	return Ref(name)
+}
+
+// Operation registers an operation and returns it for further configuration
+func (b *Builder) Operation(method, path, operationID, summary string, tags ...string) *OperationBuilder {
+	item, exists := b.doc.Paths[path]
+	if !exists {
+		item = &PathItem{}
+		b.doc.Paths[path] = item
+	}
+
+	op := &Operation{
+		Summary:     summary,
+		OperationID: operationID,
+		Tags:        tags,
+		Responses:   make(map[string]*Response),
+	}
+	(*item)[strings.ToLower(method)] = op
+
+	// Path templates such as /requests/{id} need matching path parameters
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:     strings.Trim(segment, "{}"),
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+		}
+	}
+
+	return &OperationBuilder{builder: b, op: op}
+}
+
+// Document returns the assembled document
+func (b *Builder) Document() *Document {
+	return b.doc
+}
+
+// OperationBuilder configures a single operation
+type OperationBuilder struct {
+	builder *Builder
+	op      *Operation
+}
+
+// Query adds a query parameter
+func (ob *OperationBuilder) Query(name, schemaType, description string) *OperationBuilder {
+	ob.op.Parameters = append(ob.op.Parameters, Parameter{
+		Name:        name,
+		In:          "query",
+		Description: description,
+		Schema:      &Schema{Type: schemaType},
+	})
+	return ob
+}
+
+// Header adds an optional request header parameter
+func (ob *OperationBuilder) Header(name, description string) *OperationBuilder {
+	ob.op.Parameters = append(ob.op.Parameters, Parameter{
+		Name:        name,
+		In:          "header",
+		Description: description,
+		Schema:      &Schema{Type: "string"},
+	})
+	return ob
+}
+
+// JSONBody sets a required JSON request body whose schema is derived from v
+func (ob *OperationBuilder) JSONBody(v interface{}) *OperationBuilder {
+	ob.op.RequestBody = &RequestBody{
+		Required: true,
+		Content: map[string]*MediaType{
+			"application/json": {Schema: ob.builder.SchemaOf(v)},
+		},
+	}
+	return ob
+}
+
+// JSON adds a JSON response whose schema is derived from v; v may also be a *Schema
+func (ob *OperationBuilder) JSON(status int, description string, v interface{}) *OperationBuilder {
+	schema, ok := v.(*Schema)
+	if !ok {
+		schema = ob.builder.SchemaOf(v)
+	}
+	ob.op.Responses[fmt.Sprint(status)] = &Response{
+		Description: description,
+		Content: map[string]*MediaType{
+			"application/json": {Schema: schema},
+		},
+	}
+	return ob
+}
+
+// Content adds a response with a non-JSON body
+func (ob *OperationBuilder) Content(status int, description, contentType string) *OperationBuilder {
+	ob.op.Responses[fmt.Sprint(status)] = &Response{
+		Description: description,
+		Content: map[string]*MediaType{
+			contentType: {Schema: &Schema{Type: "string"}},
+		},
+	}
+	return ob
+}
+
+// Status adds a response described only by its status code
+func (ob *OperationBuilder) Status(status int, description string) *OperationBuilder {
+	if description == "" {
+		description = http.StatusText(status)
+	}
+	ob.op.Responses[fmt.Sprint(status)] = &Response{Description: description}
+	return ob
+}
+
+// Route identifies a served method and path template
+type Route struct {
+	Method string
+	Path   string
+}
+
+// Undocumented returns the routes that have no operation in the document, so
+// servers can verify at startup that the spec covers everything they serve
+func (d *Document) Undocumented(routes []Route) []string {
+	var missing []string
+	for _, route := range routes {
+		item, exists := d.Paths[route.Path]
+		if !exists || (*item)[strings.ToLower(route.Method)] == nil {
+			missing = append(missing, route.Method+" "+route.Path)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
@@ -0,0 +1,157 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Schema is an OpenAPI schema object
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+}
+
+// Ref returns a schema referencing a component by name
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	durationType   = reflect.TypeOf(time.Duration(0))
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// SchemaOf derives a schema from the Go type of v. Named struct types become
+// components and are returned as references
+func (b *Builder) SchemaOf(v interface{}) *Schema {
+	return b.schemaForType(reflect.TypeOf(v))
+}
+
+// schemaForType maps a Go type to its JSON encoding
+func (b *Builder) schemaForType(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case durationType:
+		return &Schema{Type: "integer", Format: "int64", Description: "Duration in nanoseconds"}
+	case rawMessageType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: b.schemaForType(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schemaForType(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		return b.componentRef(t)
+	default:
+		// interface{} and anything else accept any JSON value
+		return &Schema{}
+	}
+}
+
+// componentRef registers a named struct as a component and returns a reference
+func (b *Builder) componentRef(t reflect.Type) *Schema {
+	name := t.Name()
+	if existing, taken := b.types[name]; taken && existing != t.PkgPath() {
+		// Disambiguate equally named types from different packages
+		name = packageName(t.PkgPath()) + name
+	}
+
+	if _, exists := b.doc.Components.Schemas[name]; !exists {
+		b.types[name] = t.PkgPath()
+		// Reserve the name first so recursive types terminate
+		b.doc.Components.Schemas[name] = &Schema{Type: "object"}
+		b.doc.Components.Schemas[name] = b.structSchema(t)
+	}
+	return Ref(name)
+}
+
+// structSchema builds an object schema from exported struct fields and their json tags
+func (b *Builder) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		// Embedded structs without a name are flattened like encoding/json does
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				inner := b.structSchema(embedded)
+				for propName, prop := range inner.Properties {
+					schema.Properties[propName] = prop
+				}
+				schema.Required = append(schema.Required, inner.Required...)
+				continue
+			}
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = b.schemaForType(field.Type)
+		if !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Ptr {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
+
+// packageName returns the last element of an import path in title case
+func packageName(pkgPath string) string {
+	name := pkgPath[strings.LastIndex(pkgPath, "/")+1:]
+	if name == "" {
+		return ""
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
@@ -4,12 +4,14 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/analysis"
 	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/modes"
 	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/optimization"
 	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/selection"
+	"golang.org/x/sync/errgroup"
 )
 
 // Task represents a unit of work in the orchestration system
@@ -37,58 +39,66 @@ const (
 	TaskFailed    TaskStatus = "failed"
 )
 
-// Orchestrator manages multi-agent task coordination
+// defaultMaxConcurrency is how many tasks run at once unless
+// SetMaxConcurrency changes it
+const defaultMaxConcurrency = 5
+
+// Orchestrator manages multi-agent task coordination. Tasks run on a pool of
+// at most maxConcurrency goroutines; a dispatcher starts each pending task
+// once its dependencies have completed, woken by task creation, completion
+// and resizing rather than by polling
 type Orchestrator struct {
 	tasks       map[string]*Task
-	taskQueue   chan *Task
 	modeManager *modes.ModeManager
-	selector    *selection.AdaptiveSelector
+	selector    selection.Selector
 	analyzer    *analysis.ComplexityAnalyzer
 	optimizer   *optimization.SPOOptimizer
-	workers     []*Worker
-	mutex       sync.RWMutex
-	ctx         context.Context
-	cancel      context.CancelFunc
-}
-
-// Worker processes tasks from the orchestrator
-type Worker struct {
-	ID           string
-	orchestrator *Orchestrator
-	running      bool
+	// execute runs one task; processTask unless replaced in tests
+	execute        func(ctx context.Context, task *Task) (map[string]interface{}, error)
+	maxConcurrency int
+	running        int
+	// pending holds the tasks not started yet, in creation order
+	pending []*Task
+	// cancels stops the tasks currently running, by task ID
+	cancels map[string]context.CancelFunc
+	// wake tells the dispatcher that there may be a task to start
+	wake   chan struct{}
+	group  *errgroup.Group
+	mutex  sync.RWMutex
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
-// NewOrchestrator creates a new task orchestrator
-func NewOrchestrator(modeManager *modes.ModeManager, selector *selection.AdaptiveSelector) *Orchestrator {
+// NewOrchestrator creates a new task orchestrator and starts its dispatcher
+func NewOrchestrator(modeManager *modes.ModeManager, selector selection.Selector) *Orchestrator {
 	ctx, cancel := context.WithCancel(context.Background())
-	
-	o := &Orchestrator{
-		tasks:       make(map[string]*Task),
-		taskQueue:   make(chan *Task, 100),
-		modeManager: modeManager,
-		selector:    selector,
-		analyzer:    analysis.NewComplexityAnalyzer(),
-		optimizer:   optimization.NewSPOOptimizer(),
-		workers:     make([]*Worker, 0),
-		ctx:         ctx,
-		cancel:      cancel,
-	}
+	group, ctx := errgroup.WithContext(ctx)
 
-	// Start workers
-	for i := 0; i < 5; i++ {
-		worker := &Worker{
-			ID:           fmt.Sprintf("worker-%d", i),
-			orchestrator: o,
-		}
-		o.workers = append(o.workers, worker)
-		go worker.run()
+	o := &Orchestrator{
+		tasks:          make(map[string]*Task),
+		modeManager:    modeManager,
+		selector:       selector,
+		analyzer:       analysis.NewComplexityAnalyzer(),
+		optimizer:      optimization.NewSPOOptimizer(),
+		maxConcurrency: defaultMaxConcurrency,
+		cancels:        make(map[string]context.CancelFunc),
+		wake:           make(chan struct{}, 1),
+		group:          group,
+		ctx:            ctx,
+		cancel:         cancel,
 	}
+	o.execute = o.processTask
+	group.Go(o.dispatch)
 
 	return o
 }
 
-// CreateTask creates a new task and adds it to the orchestration queue
+// CreateTask creates a new task and queues it; it starts once its
+// dependencies have completed and a worker is free
 func (o *Orchestrator) CreateTask(taskType, description, mode string, input map[string]interface{}, dependencies []string) (*Task, error) {
+	if o.ctx.Err() != nil {
+		return nil, fmt.Errorf("orchestrator is shut down")
+	}
 	task := &Task{
 		ID:           generateTaskID(),
 		Type:         taskType,
@@ -102,22 +112,15 @@ func (o *Orchestrator) CreateTask(taskType, description, mode string, input map[
 
 	o.mutex.Lock()
 	o.tasks[task.ID] = task
+	o.pending = append(o.pending, task)
+	created := *task
 	o.mutex.Unlock()
+	o.notify()
 
-	// Check if dependencies are met
-	if o.dependenciesMet(task) {
-		select {
-		case o.taskQueue <- task:
-			task.Status = TaskRunning
-		default:
-			// Queue is full, task remains pending
-		}
-	}
-
-	return task, nil
+	return &created, nil
 }
 
-// GetTask retrieves a task by ID
+// GetTask returns a snapshot of a task by ID
 func (o *Orchestrator) GetTask(taskID string) (*Task, error) {
 	o.mutex.RLock()
 	defer o.mutex.RUnlock()
@@ -127,10 +130,11 @@ func (o *Orchestrator) GetTask(taskID string) (*Task, error) {
 		return nil, fmt.Errorf("task not found: %s", taskID)
 	}
 
-	return task, nil
+	snapshot := *task
+	return &snapshot, nil
 }
 
-// ListTasks returns all tasks with optional status filter
+// ListTasks returns snapshots of all tasks with optional status filter
 func (o *Orchestrator) ListTasks(status TaskStatus) []*Task {
 	o.mutex.RLock()
 	defer o.mutex.RUnlock()
@@ -138,20 +142,56 @@ func (o *Orchestrator) ListTasks(status TaskStatus) []*Task {
 	var tasks []*Task
 	for _, task := range o.tasks {
 		if status == "" || task.Status == status {
-			tasks = append(tasks, task)
+			snapshot := *task
+			tasks = append(tasks, &snapshot)
 		}
 	}
 
 	return tasks
 }
 
-// dependenciesMet checks if all task dependencies are completed
+// CancelTask cancels a pending or running task. A running task's context is
+// cancelled and the task fails with context.Canceled; a pending one fails
+// without starting. It reports whether the task had not finished yet
+func (o *Orchestrator) CancelTask(taskID string) bool {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if cancel, running := o.cancels[taskID]; running {
+		cancel()
+		return true
+	}
+	for i, task := range o.pending {
+		if task.ID == taskID {
+			o.pending = append(o.pending[:i], o.pending[i+1:]...)
+			now := time.Now()
+			task.Status = TaskFailed
+			task.Error = context.Canceled.Error()
+			task.CompletedAt = &now
+			return true
+		}
+	}
+	return false
+}
+
+// SetMaxConcurrency sets how many tasks run at once. Growing the pool starts
+// waiting tasks right away; shrinking it lets running tasks finish and starts
+// no more until fewer than max are running
+func (o *Orchestrator) SetMaxConcurrency(max int) {
+	if max < 1 {
+		max = 1
+	}
+	o.mutex.Lock()
+	o.maxConcurrency = max
+	o.mutex.Unlock()
+	o.notify()
+}
+
+// dependenciesMet checks if all task dependencies are completed. The caller
+// holds the mutex
 func (o *Orchestrator) dependenciesMet(task *Task) bool {
 	for _, depID := range task.Dependencies {
-		o.mutex.RLock()
 		depTask, exists := o.tasks[depID]
-		o.mutex.RUnlock()
-
 		if !exists || depTask.Status != TaskCompleted {
 			return false
 		}
@@ -159,95 +199,127 @@ func (o *Orchestrator) dependenciesMet(task *Task) bool {
 	return true
 }
 
-// Shutdown gracefully shuts down the orchestrator
+// Shutdown cancels the running tasks and waits for them and the dispatcher
+// to return
 func (o *Orchestrator) Shutdown() {
 	o.cancel()
-	
-	// Wait for workers to finish
-	for _, worker := range o.workers {
-		worker.running = false
-	}
-	
-	close(o.taskQueue)
+	o.group.Wait()
 }
 
-// Worker implementation
+// notify wakes the dispatcher without blocking; one pending wake-up covers
+// any number of changes
+func (o *Orchestrator) notify() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
 
-// run starts the worker's main processing loop
-func (w *Worker) run() {
-	w.running = true
-	for w.running {
+// dispatch starts ready tasks each time it is woken, until shutdown
+func (o *Orchestrator) dispatch() error {
+	for {
 		select {
-		case task := <-w.orchestrator.taskQueue:
-			if task != nil {
-				w.processTask(task)
-			}
-		case <-w.orchestrator.ctx.Done():
-			w.running = false
-			return
-		case <-time.After(time.Second):
-			// Check for tasks with met dependencies
-			w.checkPendingTasks()
+		case <-o.ctx.Done():
+			return nil
+		case <-o.wake:
+			o.startReady()
 		}
 	}
 }
 
-// processTask processes a single task
-func (w *Worker) processTask(task *Task) {
+// startReady starts pending tasks whose dependencies have completed, in
+// creation order, while the pool has room
+func (o *Orchestrator) startReady() {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	waiting := o.pending[:0]
+	for _, task := range o.pending {
+		if o.running >= o.maxConcurrency || o.ctx.Err() != nil || !o.dependenciesMet(task) {
+			waiting = append(waiting, task)
+			continue
+		}
+		taskCtx, cancel := context.WithCancel(o.ctx)
+		o.cancels[task.ID] = cancel
+		o.running++
+		task.Status = TaskRunning
+		input := *task
+		o.group.Go(func() error {
+			o.runTask(taskCtx, cancel, task, &input)
+			return nil
+		})
+	}
+	o.pending = waiting
+}
+
+// runTask executes a started task, records its outcome and wakes the
+// dispatcher so dependents and waiting tasks can start. execute works on a
+// copy so readers of the task never race with it
+func (o *Orchestrator) runTask(ctx context.Context, cancel context.CancelFunc, task, input *Task) {
+	defer cancel()
+	output, err := o.executeSafely(ctx, input)
+
+	now := time.Now()
+	o.mutex.Lock()
+	delete(o.cancels, task.ID)
+	o.running--
+	if err != nil {
+		task.Status = TaskFailed
+		task.Error = err.Error()
+	} else {
+		task.Status = TaskCompleted
+		task.Output = output
+	}
+	task.CompletedAt = &now
+	o.mutex.Unlock()
+	o.notify()
+}
+
+// executeSafely runs execute, turning a panic into an error
+func (o *Orchestrator) executeSafely(ctx context.Context, task *Task) (output map[string]interface{}, err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			task.Status = TaskFailed
-			task.Error = fmt.Sprintf("Task panicked: %v", r)
+			err = fmt.Errorf("task panicked: %v", r)
 		}
 	}()
+	return o.execute(ctx, task)
+}
 
+// processTask analyzes a task, selects a provider and executes it
+func (o *Orchestrator) processTask(ctx context.Context, task *Task) (map[string]interface{}, error) {
 	// Get mode configuration
-	mode, err := w.orchestrator.modeManager.GetMode(task.Mode)
+	mode, err := o.modeManager.GetMode(task.Mode)
 	if err != nil {
-		task.Status = TaskFailed
-		task.Error = fmt.Sprintf("Mode not found: %v", err)
-		return
+		return nil, fmt.Errorf("mode not found: %w", err)
 	}
 
 	// Analyze task complexity
-	complexity := w.orchestrator.analyzer.AnalyzeTask(task.Description, task.Input)
+	complexity := o.analyzer.AnalyzeTask(task.Description, task.Input)
 
 	// Select provider
-	providerScore, err := w.orchestrator.selector.SelectProvider(complexity, task.Input)
+	providerScore, err := o.selector.SelectProvider(complexity, task.Input)
 	if err != nil {
-		task.Status = TaskFailed
-		task.Error = fmt.Sprintf("Provider selection failed: %v", err)
-		return
+		return nil, fmt.Errorf("provider selection failed: %w", err)
 	}
 
 	// Optimize prompt if needed
-	optimized := w.orchestrator.optimizer.OptimizePrompt(task.Description, task.Input)
+	optimized := o.optimizer.OptimizePrompt(task.Description, task.Input)
 
 	// Execute task with selected provider
-	result, err := w.executeWithProvider(providerScore, mode, optimized, complexity)
+	result, err := executeWithProvider(ctx, providerScore, mode, optimized, complexity)
 	if err != nil {
-		task.Status = TaskFailed
-		task.Error = fmt.Sprintf("Execution failed: %v", err)
-		return
+		return nil, fmt.Errorf("execution failed: %w", err)
 	}
-
-	// Store results
-	task.Output = result
-	task.Status = TaskCompleted
-	now := time.Now()
-	task.CompletedAt = &now
-
-	// Trigger dependent tasks
-	w.triggerDependents(task.ID)
+	return result, nil
 }
 
 // executeWithProvider executes the task using the selected provider
-func (w *Worker) executeWithProvider(provider selection.ProviderScore, mode modes.ModeConfig, prompt optimization.OptimizationResult, complexity analysis.TaskComplexity) (map[string]interface{}, error) {
+func executeWithProvider(ctx context.Context, provider selection.ProviderScore, mode modes.ModeConfig, prompt optimization.OptimizationResult, complexity analysis.TaskComplexity) (map[string]interface{}, error) {
 	// This is where the actual provider execution would happen
 	// For now, we'll simulate the execution
-	
+
 	result := map[string]interface{}{
-		"provider_id":     provider.ProviderID,
+		"provider_id":    provider.ProviderID,
 		"mode":           mode.Name,
 		"complexity":     complexity,
 		"optimization":   prompt,
@@ -257,62 +329,22 @@ func (w *Worker) executeWithProvider(provider selection.ProviderScore, mode mode
 	}
 
 	// Simulate processing time based on complexity
-	processingTime := time.Duration(complexity.Score * 1000) * time.Millisecond
-	time.Sleep(processingTime)
+	processingTime := time.Duration(complexity.Score*1000) * time.Millisecond
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(processingTime):
+	}
 
 	return result, nil
 }
 
-// checkPendingTasks checks for pending tasks whose dependencies are now met
-func (w *Worker) checkPendingTasks() {
-	w.orchestrator.mutex.RLock()
-	allTasks := make([]*Task, 0)
-	for _, task := range w.orchestrator.tasks {
-		allTasks = append(allTasks, task)
-	}
-	w.orchestrator.mutex.RUnlock()
-
-	for _, task := range allTasks {
-		if task.Status == TaskPending && w.orchestrator.dependenciesMet(task) {
-			select {
-			case w.orchestrator.taskQueue <- task:
-				task.Status = TaskRunning
-			default:
-				// Queue is full, task remains pending
-			}
-		}
-	}
-}
-
-// triggerDependents triggers tasks that depend on the completed task
-func (w *Worker) triggerDependents(taskID string) {
-	w.orchestrator.mutex.RLock()
-	allTasks := make([]*Task, 0)
-	for _, task := range w.orchestrator.tasks {
-		allTasks = append(allTasks, task)
-	}
-	w.orchestrator.mutex.RUnlock()
-
-	for _, task := range allTasks {
-		if task.Status == TaskPending {
-			for _, dep := range task.Dependencies {
-				if dep == taskID && w.orchestrator.dependenciesMet(task) {
-					select {
-					case w.orchestrator.taskQueue <- task:
-						task.Status = TaskRunning
-					default:
-						// Queue is full, task remains pending
-					}
-					break
-				}
-			}
-		}
-	}
-}
+// taskSequence tells apart tasks created in the same nanosecond
+var taskSequence atomic.Uint64
 
 // generateTaskID generates a unique task ID
 func generateTaskID() string {
-	return fmt.Sprintf("task_%d", time.Now().UnixNano())
+	return fmt.Sprintf("task_%d_%d", time.Now().UnixNano(), taskSequence.Add(1))
 }
 
 // GetStats returns orchestrator statistics
@@ -326,8 +358,9 @@ func (o *Orchestrator) GetStats() map[string]interface{} {
 		"running_tasks":   0,
 		"completed_tasks": 0,
 		"failed_tasks":    0,
-		"active_workers":  len(o.workers),
-		"queue_size":      len(o.taskQueue),
+		"active_workers":  o.running,
+		"max_concurrency": o.maxConcurrency,
+		"queue_size":      len(o.pending),
 	}
 
 	for _, task := range o.tasks {
@@ -344,4 +377,4 @@ func (o *Orchestrator) GetStats() map[string]interface{} {
 	}
 
 	return stats
-}
\ No newline at end of file
+}
@@ -0,0 +1,261 @@
+package orchestration
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testPool replaces task execution with work that runs until released or
+// cancelled, recording which tasks start and how many run at once
+type testPool struct {
+	orchestrator *Orchestrator
+	started      chan string
+	release      chan struct{}
+
+	mutex   sync.Mutex
+	running int
+	peak    int
+}
+
+// newTestPool returns an orchestrator running at most max tasks at once
+func newTestPool(t *testing.T, max int) *testPool {
+	t.Helper()
+	pool := &testPool{
+		orchestrator: NewOrchestrator(nil, nil),
+		started:      make(chan string, 100),
+		release:      make(chan struct{}),
+	}
+	pool.orchestrator.execute = pool.execute
+	pool.orchestrator.SetMaxConcurrency(max)
+	t.Cleanup(pool.orchestrator.Shutdown)
+	return pool
+}
+
+func (p *testPool) execute(ctx context.Context, task *Task) (map[string]interface{}, error) {
+	p.mutex.Lock()
+	p.running++
+	if p.running > p.peak {
+		p.peak = p.running
+	}
+	p.mutex.Unlock()
+	defer func() {
+		p.mutex.Lock()
+		p.running--
+		p.mutex.Unlock()
+	}()
+
+	p.started <- task.ID
+	select {
+	case <-p.release:
+		return map[string]interface{}{"task": task.ID}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// resetPeak forgets the most tasks seen running so far
+func (p *testPool) resetPeak() {
+	p.mutex.Lock()
+	p.peak = p.running
+	p.mutex.Unlock()
+}
+
+func (p *testPool) peakRunning() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.peak
+}
+
+// create creates a task depending on dependencies
+func (p *testPool) create(t *testing.T, dependencies ...string) string {
+	t.Helper()
+	task, err := p.orchestrator.CreateTask("test", "task", "code", nil, dependencies)
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	return task.ID
+}
+
+// waitStarted waits for n tasks to start and returns their IDs
+func (p *testPool) waitStarted(t *testing.T, n int) []string {
+	t.Helper()
+	var ids []string
+	for len(ids) < n {
+		select {
+		case id := <-p.started:
+			ids = append(ids, id)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%d of %d tasks started", len(ids), n)
+		}
+	}
+	return ids
+}
+
+// expectNoStart fails if a task starts within a short wait
+func (p *testPool) expectNoStart(t *testing.T) {
+	t.Helper()
+	select {
+	case id := <-p.started:
+		t.Fatalf("task %s started beyond the concurrency limit", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// waitStatus waits for a task to reach status and returns it
+func (p *testPool) waitStatus(t *testing.T, id string, status TaskStatus) *Task {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		task, err := p.orchestrator.GetTask(id)
+		if err != nil {
+			t.Fatalf("GetTask: %v", err)
+		}
+		if task.Status == status {
+			return task
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("task %s is %s, want %s", id, task.Status, status)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestConcurrencyCap(t *testing.T) {
+	pool := newTestPool(t, 2)
+	var ids []string
+	for i := 0; i < 5; i++ {
+		ids = append(ids, pool.create(t))
+	}
+
+	pool.waitStarted(t, 2)
+	pool.expectNoStart(t)
+	for i := 0; i < 3; i++ {
+		pool.release <- struct{}{}
+		pool.waitStarted(t, 1)
+	}
+	pool.release <- struct{}{}
+	pool.release <- struct{}{}
+
+	for _, id := range ids {
+		pool.waitStatus(t, id, TaskCompleted)
+	}
+	if peak := pool.peakRunning(); peak != 2 {
+		t.Errorf("at most %d tasks ran at once, want 2", peak)
+	}
+}
+
+func TestDependentsStartOnCompletion(t *testing.T) {
+	pool := newTestPool(t, 5)
+	first := pool.create(t)
+	second := pool.create(t, first)
+
+	if started := pool.waitStarted(t, 1); started[0] != first {
+		t.Fatalf("%s started before its dependency", started[0])
+	}
+	pool.expectNoStart(t)
+	pool.waitStatus(t, second, TaskPending)
+
+	pool.release <- struct{}{}
+	if started := pool.waitStarted(t, 1); started[0] != second {
+		t.Fatalf("started %s, want the dependent %s", started[0], second)
+	}
+	pool.release <- struct{}{}
+	pool.waitStatus(t, second, TaskCompleted)
+}
+
+func TestCancelTask(t *testing.T) {
+	pool := newTestPool(t, 1)
+	running := pool.create(t)
+	pending := pool.create(t)
+	pool.waitStarted(t, 1)
+
+	if !pool.orchestrator.CancelTask(running) {
+		t.Fatal("CancelTask of a running task = false")
+	}
+	task := pool.waitStatus(t, running, TaskFailed)
+	if task.Error != context.Canceled.Error() || task.CompletedAt == nil {
+		t.Errorf("cancelled task error %q, completed at %v", task.Error, task.CompletedAt)
+	}
+
+	// The freed worker picks up the next task, which is cancelled before
+	// it finishes
+	if started := pool.waitStarted(t, 1); started[0] != pending {
+		t.Fatalf("started %s, want %s", started[0], pending)
+	}
+	waiting := pool.create(t)
+	if !pool.orchestrator.CancelTask(waiting) {
+		t.Fatal("CancelTask of a pending task = false")
+	}
+	pool.waitStatus(t, waiting, TaskFailed)
+	pool.release <- struct{}{}
+	pool.waitStatus(t, pending, TaskCompleted)
+	pool.expectNoStart(t)
+
+	if pool.orchestrator.CancelTask(pending) {
+		t.Error("CancelTask of a completed task = true")
+	}
+	if pool.orchestrator.CancelTask("task_unknown") {
+		t.Error("CancelTask of an unknown task = true")
+	}
+}
+
+func TestSetMaxConcurrencyWhileRunning(t *testing.T) {
+	pool := newTestPool(t, 1)
+	for i := 0; i < 3; i++ {
+		pool.create(t)
+	}
+	pool.waitStarted(t, 1)
+	pool.expectNoStart(t)
+
+	// Growing the pool starts the waiting tasks beside the running one
+	pool.orchestrator.SetMaxConcurrency(3)
+	pool.waitStarted(t, 2)
+	if peak := pool.peakRunning(); peak != 3 {
+		t.Fatalf("%d tasks running after growing the pool, want 3", peak)
+	}
+
+	// Shrinking it lets the running tasks finish and starts new ones one
+	// at a time
+	pool.orchestrator.SetMaxConcurrency(1)
+	more := []string{pool.create(t), pool.create(t)}
+	pool.expectNoStart(t)
+	for i := 0; i < 3; i++ {
+		pool.release <- struct{}{}
+	}
+	pool.waitStarted(t, 1)
+	pool.resetPeak()
+	pool.expectNoStart(t)
+	pool.release <- struct{}{}
+	pool.waitStarted(t, 1)
+	pool.release <- struct{}{}
+	for _, id := range more {
+		pool.waitStatus(t, id, TaskCompleted)
+	}
+	if peak := pool.peakRunning(); peak != 1 {
+		t.Errorf("%d tasks ran at once after shrinking the pool, want 1", peak)
+	}
+}
+
+func TestShutdownCancelsRunningTasks(t *testing.T) {
+	pool := newTestPool(t, 2)
+	id := pool.create(t)
+	pool.waitStarted(t, 1)
+
+	pool.orchestrator.Shutdown()
+	task, err := pool.orchestrator.GetTask(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Status != TaskFailed || task.Error != context.Canceled.Error() {
+		t.Errorf("task after shutdown is %s with %q, want failed with context canceled", task.Status, task.Error)
+	}
+	if _, err := pool.orchestrator.CreateTask("test", "task", "code", nil, nil); err == nil {
+		t.Error("task created after shutdown")
+	}
+	if !errors.Is(pool.orchestrator.ctx.Err(), context.Canceled) {
+		t.Error("orchestrator context still live after shutdown")
+	}
+}
@@ -0,0 +1,199 @@
+// Package palmoe embeds the Your PaL MoE router in other Go programs. It
+// analyzes, optimizes and routes requests the way the enhanced server does,
+// without running the HTTP server
+package palmoe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/internal/enhanced"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/cassette"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/selection"
+)
+
+// Types shared with the enhanced system
+type (
+	Request               = enhanced.RequestInput
+	Response              = enhanced.ProcessResponse
+	Provider              = enhanced.Provider
+	ProviderAssignment    = enhanced.ProviderAssignment
+	TaskComplexity        = enhanced.TaskComplexity
+	SystemMetrics         = enhanced.SystemMetrics
+	ProviderHealthMetrics = enhanced.ProviderHealthMetrics
+
+	ComplexityAnalyzer = enhanced.ComplexityAnalyzer
+	PromptOptimizer    = enhanced.PromptOptimizer
+	ProviderSelector   = enhanced.ProviderSelector
+	Executor           = enhanced.Executor
+	ProviderCall       = enhanced.ProviderCall
+)
+
+// ErrNoProviders is returned by New when no option configured a provider
+var ErrNoProviders = errors.New("no providers configured")
+
+// Router routes requests across its providers
+type Router struct {
+	system *enhanced.EnhancedSystem
+}
+
+// Metrics is a snapshot of the router's request totals and provider health
+type Metrics struct {
+	System    *SystemMetrics
+	Providers map[string]*ProviderHealthMetrics
+}
+
+type options struct {
+	providers     []*Provider
+	systemOptions []enhanced.Option
+	paretoPolicy  *selection.ParetoPolicy
+	storagePath   string
+	cassetteDir   string
+	cassetteMode  cassette.Mode
+	err           error
+}
+
+// Option configures a Router
+type Option func(*options)
+
+// WithProviders adds providers from their canonical configuration
+func WithProviders(providers ...config.ProviderConfig) Option {
+	return func(o *options) {
+		for _, provider := range providers {
+			o.providers = append(o.providers, enhanced.NewProvider(provider))
+		}
+	}
+}
+
+// WithProvidersCSV adds the providers of a providers.csv file
+func WithProvidersCSV(path string) Option {
+	return func(o *options) {
+		providers, err := selection.LoadProvidersFromCSV(path)
+		if err != nil {
+			o.err = errors.Join(o.err, fmt.Errorf("failed to load %s: %w", path, err))
+			return
+		}
+		for _, provider := range providers {
+			o.providers = append(o.providers, enhanced.NewProvider(provider))
+		}
+	}
+}
+
+// WithComplexityAnalyzer replaces the task reasoner that analyzes requests
+func WithComplexityAnalyzer(analyzer ComplexityAnalyzer) Option {
+	return func(o *options) {
+		o.systemOptions = append(o.systemOptions, enhanced.WithComplexityAnalyzer(analyzer))
+	}
+}
+
+// WithPromptOptimizer replaces the SPO prompt optimizer
+func WithPromptOptimizer(optimizer PromptOptimizer) Option {
+	return func(o *options) {
+		o.systemOptions = append(o.systemOptions, enhanced.WithPromptOptimizer(optimizer))
+	}
+}
+
+// WithProviderSelector replaces the built-in provider selector;
+// WithParetoPolicy then has no effect
+func WithProviderSelector(selector ProviderSelector) Option {
+	return func(o *options) {
+		o.systemOptions = append(o.systemOptions, enhanced.WithProviderSelector(selector))
+	}
+}
+
+// WithExecutor replaces the executor that calls providers
+func WithExecutor(executor Executor) Option {
+	return func(o *options) {
+		o.systemOptions = append(o.systemOptions, enhanced.WithExecutor(executor))
+	}
+}
+
+// WithParetoPolicy sets the default Pareto policy used to trade off cost,
+// quality and latency when selecting providers
+func WithParetoPolicy(policy selection.ParetoPolicy) Option {
+	return func(o *options) {
+		o.paretoPolicy = &policy
+	}
+}
+
+// WithMetricsStorage keeps metrics and the request history in the SQLite
+// database at path, as METRICS_DB_PATH does for the server
+func WithMetricsStorage(path string) Option {
+	return func(o *options) {
+		o.storagePath = path
+	}
+}
+
+// WithCassettes records the executor's provider calls in per-provider
+// cassettes in dir, or replays them from there, so tests recorded once
+// against real providers run deterministically. Secrets are scrubbed from
+// the cassettes
+func WithCassettes(dir string, mode cassette.Mode) Option {
+	return func(o *options) {
+		o.cassetteDir = dir
+		o.cassetteMode = mode
+	}
+}
+
+// New creates a router. At least one provider must be configured
+func New(opts ...Option) (*Router, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.err != nil {
+		return nil, o.err
+	}
+	if len(o.providers) == 0 {
+		return nil, ErrNoProviders
+	}
+
+	system := enhanced.NewEnhancedSystem(o.providers, o.systemOptions...)
+	if o.paretoPolicy != nil {
+		system.SetParetoPolicy(*o.paretoPolicy)
+	}
+	if o.storagePath != "" {
+		storage, err := enhanced.NewMetricsStorage(o.storagePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open metrics storage: %w", err)
+		}
+		system.SetMetricsStorage(storage)
+	}
+	if o.cassetteDir != "" {
+		library, err := cassette.NewLibrary(o.cassetteDir, o.cassetteMode, nil)
+		if err != nil {
+			return nil, err
+		}
+		system.SetCassettes(library)
+	}
+	return &Router{system: system}, nil
+}
+
+// Process validates and routes a single request
+func (r *Router) Process(ctx context.Context, req Request) (*Response, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	return r.system.ProcessRequest(ctx, req)
+}
+
+// Providers returns the router's providers
+func (r *Router) Providers() []*Provider {
+	return r.system.GetProviders()
+}
+
+// Metrics returns a snapshot of the router's metrics
+func (r *Router) Metrics() Metrics {
+	return Metrics{
+		System:    r.system.GetSystemMetrics(),
+		Providers: r.system.GetProviderMetrics(),
+	}
+}
+
+// Shutdown waits for requests in flight until ctx expires, then flushes
+// metrics to storage
+func (r *Router) Shutdown(ctx context.Context) error {
+	return r.system.Shutdown(ctx)
+}
@@ -0,0 +1,82 @@
+package palmoe
+
+import (
+	"context"
+	"io"
+	"strings"
+)
+
+// Chunk is one part of a streamed answer. The last chunk carries the full
+// Response instead of content
+type Chunk struct {
+	Content  string
+	Response *Response
+}
+
+// Stream reads the chunks of a streamed answer. Callers must Close it
+type Stream struct {
+	cancel   context.CancelFunc
+	done     chan struct{}
+	response *Response
+	err      error
+	chunks   []Chunk
+}
+
+// ProcessStream validates a request and routes it in the background. Answers
+// are not yet produced incrementally, so the content arrives in one chunk
+// once the provider has answered
+func (r *Router) ProcessStream(ctx context.Context, req Request) (*Stream, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	s := &Stream{cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		s.response, s.err = r.system.ProcessRequest(ctx, req)
+		if s.err != nil {
+			return
+		}
+		if s.response.Content != "" {
+			s.chunks = append(s.chunks, Chunk{Content: s.response.Content})
+		}
+		s.chunks = append(s.chunks, Chunk{Response: s.response})
+	}()
+	return s, nil
+}
+
+// Next returns the next chunk, or io.EOF once the stream has ended
+func (s *Stream) Next() (*Chunk, error) {
+	<-s.done
+	if s.err != nil {
+		return nil, s.err
+	}
+	if len(s.chunks) == 0 {
+		return nil, io.EOF
+	}
+	chunk := s.chunks[0]
+	s.chunks = s.chunks[1:]
+	return &chunk, nil
+}
+
+// Collect reads the stream to the end and concatenates its content
+func (s *Stream) Collect() (string, error) {
+	var sb strings.Builder
+	for {
+		chunk, err := s.Next()
+		if err == io.EOF {
+			return sb.String(), nil
+		}
+		if err != nil {
+			return sb.String(), err
+		}
+		sb.WriteString(chunk.Content)
+	}
+}
+
+// Close cancels the request if it is still running
+func (s *Stream) Close() error {
+	s.cancel()
+	return nil
+}
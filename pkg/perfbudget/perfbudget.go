@@ -0,0 +1,65 @@
+// Package perfbudget checks benchmarks of hot paths against performance
+// budgets, so CI fails when a change makes them slower or allocate more
+package perfbudget
+
+import (
+	"os"
+	"strconv"
+	"testing"
+)
+
+// Environment variables controlling budget checks
+const (
+	// EnvEnable runs budget checks when set to true. Timings depend on the
+	// machine, so plain go test skips them
+	EnvEnable = "PERF_BUDGET"
+	// EnvScale multiplies time budgets, for machines slower than the one
+	// the budgets were set on
+	EnvScale = "PERF_BUDGET_SCALE"
+)
+
+// Budget is the most time and allocations one operation of a benchmark may
+// take. Allocations do not depend on the machine and are checked exactly; a
+// zero MaxNsPerOp leaves time unchecked
+type Budget struct {
+	Name           string
+	Benchmark      func(b *testing.B)
+	MaxNsPerOp     int64
+	MaxAllocsPerOp int64
+}
+
+// Check runs the benchmark of every budget and fails t for each one over
+// budget. It skips t unless PERF_BUDGET=true
+func Check(t *testing.T, budgets ...Budget) {
+	t.Helper()
+	if os.Getenv(EnvEnable) != "true" {
+		t.Skipf("set %s=true to check performance budgets", EnvEnable)
+	}
+	scale := 1.0
+	if raw := os.Getenv(EnvScale); raw != "" {
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil || parsed <= 0 {
+			t.Fatalf("invalid %s %q: must be a positive number", EnvScale, raw)
+		}
+		scale = parsed
+	}
+
+	for _, budget := range budgets {
+		result := testing.Benchmark(func(b *testing.B) {
+			b.ReportAllocs()
+			budget.Benchmark(b)
+		})
+		if result.N == 0 {
+			t.Errorf("%s: benchmark failed", budget.Name)
+			continue
+		}
+		nsPerOp, allocsPerOp := result.NsPerOp(), result.AllocsPerOp()
+		t.Logf("%s: %d ns/op, %d allocs/op", budget.Name, nsPerOp, allocsPerOp)
+		if maxNs := int64(float64(budget.MaxNsPerOp) * scale); budget.MaxNsPerOp > 0 && nsPerOp > maxNs {
+			t.Errorf("%s: %d ns/op exceeds the budget of %d ns/op", budget.Name, nsPerOp, maxNs)
+		}
+		if allocsPerOp > budget.MaxAllocsPerOp {
+			t.Errorf("%s: %d allocs/op exceeds the budget of %d allocs/op", budget.Name, allocsPerOp, budget.MaxAllocsPerOp)
+		}
+	}
+}
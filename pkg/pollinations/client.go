@@ -7,6 +7,8 @@ import (
 	"net/http"
 	"net/url"
 	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/requestid"
 )
 
 // Client represents a Pollinations API client
@@ -46,6 +48,7 @@ func (c *Client) GenerateText(ctx context.Context, prompt string) (string, error
 	// Set headers
 	req.Header.Set("User-Agent", "Your-PaL-MoE/1.0")
 	req.Header.Set("Accept", "text/plain")
+	requestid.Inject(req)
 
 	// Make the request
 	resp, err := c.httpClient.Do(req)
@@ -0,0 +1,305 @@
+// Package postprocess rewrites response content before it is returned,
+// through chains of processors configured per API key or per mode
+package postprocess
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Processor rewrites response content. Processors compose like middleware:
+// each receives the output of the one before it
+type Processor interface {
+	Name() string
+	Process(content string) string
+}
+
+// Processor types accepted in configuration
+const (
+	TypeMarkdown   = "markdown"
+	TypeCodeFences = "code_fences"
+	TypeCitations  = "citations"
+	TypeProfanity  = "profanity"
+	TypeRegex      = "regex"
+)
+
+// processorTypes lists the valid processor types
+var processorTypes = []string{TypeMarkdown, TypeCodeFences, TypeCitations, TypeProfanity, TypeRegex}
+
+// Spec configures one processor
+type Spec struct {
+	Type string `yaml:"type" json:"type"`
+	// Words replaces the default profanity list
+	Words []string `yaml:"words,omitempty" json:"words,omitempty"`
+	// Pattern and Replacement configure a regex processor; the replacement
+	// may refer to groups as $1 or ${name}
+	Pattern     string `yaml:"pattern,omitempty" json:"pattern,omitempty"`
+	Replacement string `yaml:"replacement,omitempty" json:"replacement,omitempty"`
+}
+
+// Build creates the processor described by the spec
+func (s Spec) Build() (Processor, error) {
+	switch s.Type {
+	case TypeMarkdown:
+		return MarkdownNormalizer{}, nil
+	case TypeCodeFences:
+		return CodeFenceExtractor{}, nil
+	case TypeCitations:
+		return CitationStripper{}, nil
+	case TypeProfanity:
+		return NewProfanityMask(s.Words)
+	case TypeRegex:
+		return NewRegexReplacer(s.Pattern, s.Replacement)
+	}
+	return nil, fmt.Errorf("unknown processor type %q: must be one of %s", s.Type, strings.Join(processorTypes, ", "))
+}
+
+// Chain applies processors in order
+type Chain []Processor
+
+// BuildChain creates a chain from specs
+func BuildChain(specs []Spec) (Chain, error) {
+	chain := make(Chain, 0, len(specs))
+	for i, spec := range specs {
+		processor, err := spec.Build()
+		if err != nil {
+			return nil, fmt.Errorf("processor %d: %w", i+1, err)
+		}
+		chain = append(chain, processor)
+	}
+	return chain, nil
+}
+
+// Process runs content through every processor of the chain
+func (c Chain) Process(content string) string {
+	for _, processor := range c {
+		content = processor.Process(content)
+	}
+	return content
+}
+
+// Names returns the names of the chain's processors in order
+func (c Chain) Names() []string {
+	names := make([]string, len(c))
+	for i, processor := range c {
+		names[i] = processor.Name()
+	}
+	return names
+}
+
+// Config is the file format of RESPONSE_PROCESSORS_PATH
+type Config struct {
+	// Default applies to requests no key or mode chain covers
+	Default []Spec `yaml:"default,omitempty" json:"default,omitempty"`
+	// Keys maps API key IDs to their chains
+	Keys map[string][]Spec `yaml:"keys,omitempty" json:"keys,omitempty"`
+	// Modes maps request modes to their chains
+	Modes map[string][]Spec `yaml:"modes,omitempty" json:"modes,omitempty"`
+}
+
+// Pipelines picks the processor chain for each request
+type Pipelines struct {
+	defaultChain Chain
+	keys         map[string]Chain
+	modes        map[string]Chain
+}
+
+// NewPipelines builds the chains of config
+func NewPipelines(config Config) (*Pipelines, error) {
+	defaultChain, err := BuildChain(config.Default)
+	if err != nil {
+		return nil, fmt.Errorf("default: %w", err)
+	}
+	p := &Pipelines{
+		defaultChain: defaultChain,
+		keys:         make(map[string]Chain, len(config.Keys)),
+		modes:        make(map[string]Chain, len(config.Modes)),
+	}
+	for key, specs := range config.Keys {
+		if p.keys[key], err = BuildChain(specs); err != nil {
+			return nil, fmt.Errorf("key %s: %w", key, err)
+		}
+	}
+	for mode, specs := range config.Modes {
+		if p.modes[mode], err = BuildChain(specs); err != nil {
+			return nil, fmt.Errorf("mode %s: %w", mode, err)
+		}
+	}
+	return p, nil
+}
+
+// LoadPipelines reads a Config from a YAML file
+func LoadPipelines(path string) (*Pipelines, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response processors: %w", err)
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse response processors %s: %w", path, err)
+	}
+	return NewPipelines(config)
+}
+
+// For returns the chain for a request: the key's chain when it has one,
+// otherwise the mode's, otherwise the default
+func (p *Pipelines) For(keyID, mode string) Chain {
+	if p == nil {
+		return nil
+	}
+	if chain, ok := p.keys[keyID]; ok && keyID != "" {
+		return chain
+	}
+	if chain, ok := p.modes[mode]; ok && mode != "" {
+		return chain
+	}
+	return p.defaultChain
+}
+
+// MarkdownNormalizer normalizes line endings, list markers, heading spacing
+// and blank lines outside code blocks
+type MarkdownNormalizer struct{}
+
+var (
+	markdownHeading = regexp.MustCompile(`^(#{1,6})([^#\s])`)
+	markdownBullet  = regexp.MustCompile(`^(\s*)[*+]\s+`)
+)
+
+// Name implements Processor
+func (MarkdownNormalizer) Name() string { return TypeMarkdown }
+
+// Process implements Processor
+func (MarkdownNormalizer) Process(content string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	lines := strings.Split(content, "\n")
+	out := make([]string, 0, len(lines))
+	inFence, blank := false, false
+	for _, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+		} else if !inFence {
+			line = strings.TrimRight(line, " \t")
+			if line == "" {
+				// Runs of blank lines collapse to one
+				if blank {
+					continue
+				}
+				blank = true
+				out = append(out, line)
+				continue
+			}
+			line = markdownHeading.ReplaceAllString(line, "$1 $2")
+			line = markdownBullet.ReplaceAllString(line, "$1- ")
+		}
+		blank = false
+		out = append(out, line)
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
+
+// CodeFenceExtractor replaces content with the bodies of its fenced code
+// blocks, separated by blank lines. Content without code blocks is kept
+type CodeFenceExtractor struct{}
+
+var codeFence = regexp.MustCompile("(?s)```[^\\n]*\\n(.*?)```")
+
+// Name implements Processor
+func (CodeFenceExtractor) Name() string { return TypeCodeFences }
+
+// Process implements Processor
+func (CodeFenceExtractor) Process(content string) string {
+	matches := codeFence.FindAllStringSubmatch(content, -1)
+	if len(matches) == 0 {
+		return content
+	}
+	blocks := make([]string, len(matches))
+	for i, match := range matches {
+		blocks[i] = strings.TrimRight(match[1], "\n")
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+// CitationStripper removes numeric citation markers such as [1] or [^2]
+// and footnote definitions
+type CitationStripper struct{}
+
+var (
+	citationMarker   = regexp.MustCompile(`\s?\[\^?\d+(?:[,-]\s*\d+)*\]`)
+	citationFootnote = regexp.MustCompile(`(?m)^\[\^?\d+\]:.*(?:\n|$)`)
+)
+
+// Name implements Processor
+func (CitationStripper) Name() string { return TypeCitations }
+
+// Process implements Processor
+func (CitationStripper) Process(content string) string {
+	content = citationFootnote.ReplaceAllString(content, "")
+	return strings.TrimSpace(citationMarker.ReplaceAllString(content, ""))
+}
+
+// defaultProfanity is the word list masked when none is configured
+var defaultProfanity = []string{"damn", "hell", "shit", "fuck", "bastard", "bitch", "crap"}
+
+// ProfanityMask replaces listed words, and words starting with them, with
+// asterisks, matching case-insensitively on word boundaries
+type ProfanityMask struct {
+	pattern *regexp.Regexp
+}
+
+// NewProfanityMask creates a mask for words, the default list when empty
+func NewProfanityMask(words []string) (*ProfanityMask, error) {
+	if len(words) == 0 {
+		words = defaultProfanity
+	}
+	quoted := make([]string, 0, len(words))
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			quoted = append(quoted, regexp.QuoteMeta(word))
+		}
+	}
+	if len(quoted) == 0 {
+		return nil, fmt.Errorf("profanity processor needs at least one non-empty word")
+	}
+	return &ProfanityMask{pattern: regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\w*`)}, nil
+}
+
+// Name implements Processor
+func (pm *ProfanityMask) Name() string { return TypeProfanity }
+
+// Process implements Processor
+func (pm *ProfanityMask) Process(content string) string {
+	return pm.pattern.ReplaceAllStringFunc(content, func(word string) string {
+		return strings.Repeat("*", len([]rune(word)))
+	})
+}
+
+// RegexReplacer replaces every match of a pattern
+type RegexReplacer struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// NewRegexReplacer compiles pattern
+func NewRegexReplacer(pattern, replacement string) (*RegexReplacer, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("regex processor needs a pattern")
+	}
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex pattern: %w", err)
+	}
+	return &RegexReplacer{pattern: compiled, replacement: replacement}, nil
+}
+
+// Name implements Processor
+func (rr *RegexReplacer) Name() string { return TypeRegex }
+
+// Process implements Processor
+func (rr *RegexReplacer) Process(content string) string {
+	return rr.pattern.ReplaceAllString(content, rr.replacement)
+}
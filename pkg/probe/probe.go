@@ -0,0 +1,428 @@
+// Package probe verifies which features a provider actually supports by
+// sending it tiny test requests, instead of inferring them from model names
+package probe
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/logging"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/requestid"
+)
+
+var logger = logging.Module("probe")
+
+// defaultTimeout bounds a single probe request
+const defaultTimeout = 30 * time.Second
+
+// maxResponseBytes caps how much of a probe response is read
+const maxResponseBytes = 1 << 20
+
+// Feature is a provider capability a probe can verify. Its value doubles as
+// the capability name used in routing
+type Feature string
+
+// Features the prober checks
+const (
+	Streaming       Feature = "streaming"
+	SystemPrompt    Feature = "system_prompt"
+	FunctionCalling Feature = "function_calling"
+	JSONMode        Feature = "json_mode"
+	Vision          Feature = "vision"
+)
+
+// Features lists every feature in the order they are probed
+var Features = []Feature{Streaming, SystemPrompt, FunctionCalling, JSONMode, Vision}
+
+// ParseFeature converts s to a Feature
+func ParseFeature(s string) (Feature, bool) {
+	for _, feature := range Features {
+		if strings.EqualFold(s, string(feature)) {
+			return feature, true
+		}
+	}
+	return "", false
+}
+
+// Status is the outcome of probing one feature
+type Status string
+
+const (
+	// Supported means the provider answered the feature's request as expected
+	Supported Status = "supported"
+	// Unsupported means the provider rejected the request or ignored the feature
+	Unsupported Status = "unsupported"
+	// Inconclusive means the probe could not tell, e.g. after a timeout or a
+	// server error; it does not override declared capabilities
+	Inconclusive Status = "inconclusive"
+)
+
+// Target is the provider and model a probe is sent to, over an
+// OpenAI-compatible chat completions API
+type Target struct {
+	Provider string
+	BaseURL  string
+	Model    string
+	APIKey   string
+}
+
+// Check is the outcome of probing one feature. VerifiedAt is when a probe
+// last had a conclusive outcome for the feature, which an inconclusive probe
+// keeps from the earlier result
+type Check struct {
+	Feature    Feature   `json:"feature"`
+	Status     Status    `json:"status"`
+	Detail     string    `json:"detail,omitempty"`
+	LatencyMs  int64     `json:"latency_ms"`
+	VerifiedAt time.Time `json:"verified_at,omitempty"`
+}
+
+// Change is a feature whose conclusive status differs from an earlier
+// verification, e.g. after a provider upgrade
+type Change struct {
+	Feature    Feature   `json:"feature"`
+	Previous   Status    `json:"previous"`
+	Current    Status    `json:"current"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// Result holds the verified features of a provider
+type Result struct {
+	Provider string    `json:"provider"`
+	Model    string    `json:"model"`
+	ProbedAt time.Time `json:"probed_at"`
+	// Error is set when the provider did not answer a plain request, in which
+	// case no feature was probed
+	Error  string  `json:"error,omitempty"`
+	Checks []Check `json:"checks,omitempty"`
+	// Changes lists the features whose status changed since they were
+	// verified before, until the changes are acknowledged
+	Changes []Change `json:"changes,omitempty"`
+}
+
+// Status returns the probed status of feature, Inconclusive if it was not probed
+func (r *Result) Status(feature Feature) Status {
+	for _, check := range r.Checks {
+		if check.Feature == feature {
+			return check.Status
+		}
+	}
+	return Inconclusive
+}
+
+// OldestVerification returns when the least recently verified feature was
+// verified, zero when no feature ever was
+func (r *Result) OldestVerification() time.Time {
+	var oldest time.Time
+	for _, check := range r.Checks {
+		if !check.VerifiedAt.IsZero() && (oldest.IsZero() || check.VerifiedAt.Before(oldest)) {
+			oldest = check.VerifiedAt
+		}
+	}
+	return oldest
+}
+
+// Verified returns the features the provider was shown to support
+func (r *Result) Verified() []Feature {
+	var features []Feature
+	for _, check := range r.Checks {
+		if check.Status == Supported {
+			features = append(features, check.Feature)
+		}
+	}
+	return features
+}
+
+// Prober sends probe requests
+type Prober struct {
+	httpClient *http.Client
+}
+
+// NewProber creates a prober whose requests time out after timeout
+func NewProber(timeout time.Duration) *Prober {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &Prober{httpClient: &http.Client{Timeout: timeout}}
+}
+
+// Probe checks every feature of target. A plain request is sent first; if
+// it fails the result carries the error and no checks
+func (p *Prober) Probe(ctx context.Context, target Target) *Result {
+	result := &Result{
+		Provider: target.Provider,
+		Model:    target.Model,
+		ProbedAt: time.Now().UTC(),
+	}
+
+	if _, err := p.send(ctx, target, p.request(target, userMessage("Reply with the word OK."))); err != nil {
+		result.Error = err.Error()
+		logger.Warnf("Provider %s did not answer the baseline probe: %v", target.Provider, err)
+		return result
+	}
+
+	for _, feature := range Features {
+		start := time.Now()
+		check := p.check(ctx, target, feature)
+		check.LatencyMs = time.Since(start).Milliseconds()
+		result.Checks = append(result.Checks, check)
+	}
+	logger.Infof("Probed %s (%s): verified %v", target.Provider, target.Model, result.Verified())
+	return result
+}
+
+// check probes a single feature
+func (p *Prober) check(ctx context.Context, target Target, feature Feature) Check {
+	check := Check{Feature: feature}
+
+	var body map[string]interface{}
+	var verify func(*http.Response, []byte) error
+	switch feature {
+	case Streaming:
+		body = p.request(target, userMessage("Count from 1 to 3."))
+		body["stream"] = true
+		verify = verifyStream
+	case SystemPrompt:
+		body = p.request(target,
+			map[string]interface{}{"role": "system", "content": "Whatever the user says, reply with exactly the word PINEAPPLE."},
+			userMessage("Hello, who are you?"))
+		verify = verifyReplyContains("PINEAPPLE")
+	case FunctionCalling:
+		body = p.request(target, userMessage("Call the get_probe_token function."))
+		body["tools"] = []interface{}{map[string]interface{}{
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":        "get_probe_token",
+				"description": "Returns the probe token",
+				"parameters":  map[string]interface{}{"type": "object", "properties": map[string]interface{}{}},
+			},
+		}}
+		body["tool_choice"] = map[string]interface{}{"type": "function", "function": map[string]interface{}{"name": "get_probe_token"}}
+		verify = verifyToolCall
+	case JSONMode:
+		body = p.request(target, userMessage(`Reply with a JSON object of the form {"ok": true}.`))
+		body["response_format"] = map[string]interface{}{"type": "json_object"}
+		verify = verifyJSONObject
+	case Vision:
+		body = p.request(target, map[string]interface{}{
+			"role": "user",
+			"content": []interface{}{
+				map[string]interface{}{"type": "text", "text": "What color is this image? Answer in one word."},
+				map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": probeImage}},
+			},
+		})
+		verify = verifyReplyContains("")
+	}
+
+	resp, err := p.send(ctx, target, body)
+	if err != nil {
+		check.Status = statusForError(err)
+		check.Detail = err.Error()
+		return check
+	}
+	if err := verify(resp.response, resp.body); err != nil {
+		check.Status = Unsupported
+		check.Detail = err.Error()
+		return check
+	}
+	check.Status = Supported
+	return check
+}
+
+// probeImage is a 1x1 red PNG
+const probeImage = "data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAIAAACQd1PeAAAADElEQVR4nGP4z8AAAAMBAQDJ/pLvAAAAAElFTkSuQmCC"
+
+// userMessage builds a user chat message
+func userMessage(content string) map[string]interface{} {
+	return map[string]interface{}{"role": "user", "content": content}
+}
+
+// request builds a chat completions body; probes ask for very few tokens
+func (p *Prober) request(target Target, messages ...map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"model":      target.Model,
+		"messages":   messages,
+		"max_tokens": 64,
+	}
+}
+
+// probeResponse is a successful probe response with its body read
+type probeResponse struct {
+	response *http.Response
+	body     []byte
+}
+
+// statusError is a non-200 probe response
+type statusError struct {
+	code    int
+	message string
+}
+
+func (e *statusError) Error() string {
+	if e.message != "" {
+		return fmt.Sprintf("status %d: %s", e.code, e.message)
+	}
+	return fmt.Sprintf("status %d", e.code)
+}
+
+// statusForError classifies a failed probe: a client error means the feature
+// was rejected, anything else (rate limits, server errors, timeouts) proves nothing
+func statusForError(err error) Status {
+	if se, ok := err.(*statusError); ok && se.code >= 400 && se.code < 500 &&
+		se.code != http.StatusUnauthorized && se.code != http.StatusForbidden &&
+		se.code != http.StatusRequestTimeout && se.code != http.StatusTooManyRequests {
+		return Unsupported
+	}
+	return Inconclusive
+}
+
+// send posts body to the target's chat completions endpoint
+func (p *Prober) send(ctx context.Context, target Target, body map[string]interface{}) (*probeResponse, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	url := strings.TrimRight(target.BaseURL, "/") + "/chat/completions"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Your-PaL-MoE/1.0")
+	if target.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+target.APIKey)
+	}
+	requestid.Inject(req)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	payload, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var parsed struct {
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		se := &statusError{code: resp.StatusCode}
+		if json.Unmarshal(payload, &parsed) == nil && parsed.Error != nil {
+			se.message = parsed.Error.Message
+		}
+		return nil, se
+	}
+	return &probeResponse{response: resp, body: payload}, nil
+}
+
+// completion is the subset of a chat completions response probes inspect
+type completion struct {
+	Choices []struct {
+		Message struct {
+			Content   string            `json:"content"`
+			ToolCalls []json.RawMessage `json:"tool_calls"`
+		} `json:"message"`
+	} `json:"choices"`
+}
+
+// reply decodes the first message of a completion
+func reply(body []byte) (string, []json.RawMessage, error) {
+	var parsed completion
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return "", nil, fmt.Errorf("response is not a chat completion: %v", err)
+	}
+	if len(parsed.Choices) == 0 {
+		return "", nil, fmt.Errorf("response has no choices")
+	}
+	message := parsed.Choices[0].Message
+	return message.Content, message.ToolCalls, nil
+}
+
+// verifyReplyContains accepts a non-empty reply containing want, ignoring case
+func verifyReplyContains(want string) func(*http.Response, []byte) error {
+	return func(_ *http.Response, body []byte) error {
+		content, _, err := reply(body)
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(content) == "" {
+			return fmt.Errorf("reply is empty")
+		}
+		if !strings.Contains(strings.ToUpper(content), strings.ToUpper(want)) {
+			return fmt.Errorf("reply %q ignored the instruction", truncate(content))
+		}
+		return nil
+	}
+}
+
+// verifyToolCall accepts a reply that calls a tool
+func verifyToolCall(_ *http.Response, body []byte) error {
+	_, toolCalls, err := reply(body)
+	if err != nil {
+		return err
+	}
+	if len(toolCalls) == 0 {
+		return fmt.Errorf("reply has no tool calls")
+	}
+	return nil
+}
+
+// verifyJSONObject accepts a reply that is a JSON object
+func verifyJSONObject(_ *http.Response, body []byte) error {
+	content, _, err := reply(body)
+	if err != nil {
+		return err
+	}
+	var object map[string]interface{}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(content)), &object); err != nil {
+		return fmt.Errorf("reply %q is not a JSON object", truncate(content))
+	}
+	return nil
+}
+
+// verifyStream accepts a server-sent event stream of completion chunks
+func verifyStream(resp *http.Response, body []byte) error {
+	chunks := 0
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+		var chunk struct {
+			Choices []json.RawMessage `json:"choices"`
+		}
+		if json.Unmarshal([]byte(data), &chunk) == nil && len(chunk.Choices) > 0 {
+			chunks++
+		}
+	}
+	if chunks == 0 {
+		return fmt.Errorf("response (%s) is not an event stream of chunks", resp.Header.Get("Content-Type"))
+	}
+	return nil
+}
+
+// truncate shortens a reply for check details
+func truncate(s string) string {
+	if len(s) > 80 {
+		return s[:80] + "..."
+	}
+	return s
+}
@@ -0,0 +1,268 @@
+package probe
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store keeps the latest probe result of each provider in memory and, with a
+// path, in a JSON file so verified capabilities survive restarts. It is safe
+// for concurrent use, so routing can consult it while probes run
+type Store struct {
+	path    string
+	results map[string]*Result
+	mutex   sync.RWMutex
+}
+
+// NewStore creates a store, loading the results already saved at path. An
+// empty path keeps results in memory only
+func NewStore(path string) (*Store, error) {
+	store := &Store{
+		path:    path,
+		results: make(map[string]*Result),
+	}
+	if path == "" {
+		return store, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read probe results: %w", err)
+	}
+	if err := json.Unmarshal(data, &store.results); err != nil {
+		return nil, fmt.Errorf("failed to parse probe results %s: %w", path, err)
+	}
+	return store, nil
+}
+
+// Verification is how current the probed features of a provider are
+type Verification struct {
+	Provider string    `json:"provider"`
+	Model    string    `json:"model"`
+	ProbedAt time.Time `json:"probed_at"`
+	// VerifiedAt is when the least recently verified feature was verified
+	VerifiedAt time.Time `json:"verified_at,omitempty"`
+	// Stale is set when the provider is due to be probed again
+	Stale   bool     `json:"stale"`
+	Error   string   `json:"error,omitempty"`
+	Changes []Change `json:"changes,omitempty"`
+}
+
+// Put records result as the provider's latest, replacing any earlier one.
+// Checks of result keep when their feature was last verified, and features
+// whose conclusive status differs from the earlier result are added to its
+// unacknowledged changes
+func (s *Store) Put(result *Result) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if previous, ok := s.results[result.Provider]; ok {
+		result.Changes = mergeChanges(previous, result)
+	}
+	for i := range result.Checks {
+		check := &result.Checks[i]
+		if check.Status != Inconclusive {
+			check.VerifiedAt = result.ProbedAt
+		} else if previous, ok := s.results[result.Provider]; ok {
+			check.VerifiedAt = previous.verifiedAt(check.Feature)
+		}
+	}
+	for _, change := range result.Changes {
+		if change.DetectedAt.Equal(result.ProbedAt) {
+			logger.Warnf("Provider %s feature %s changed from %s to %s since it was last verified", result.Provider, change.Feature, change.Previous, change.Current)
+		}
+	}
+	s.results[result.Provider] = result
+	return s.save()
+}
+
+// Acknowledge clears the changes flagged on provider, reporting whether it
+// has been probed
+func (s *Store) Acknowledge(provider string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	result, ok := s.results[provider]
+	if !ok {
+		return false, nil
+	}
+	if len(result.Changes) == 0 {
+		return true, nil
+	}
+	// Results handed out earlier are not modified
+	acknowledged := *result
+	acknowledged.Changes = nil
+	s.results[provider] = &acknowledged
+	return true, s.save()
+}
+
+// Verifications returns how current each probed provider's features are, by
+// provider name, against maxAge
+func (s *Store) Verifications(maxAge time.Duration) []Verification {
+	results := s.List()
+	verifications := make([]Verification, len(results))
+	for i, result := range results {
+		verifications[i] = Verification{
+			Provider:   result.Provider,
+			Model:      result.Model,
+			ProbedAt:   result.ProbedAt,
+			VerifiedAt: result.OldestVerification(),
+			Stale:      result.stale(maxAge),
+			Error:      result.Error,
+			Changes:    result.Changes,
+		}
+	}
+	return verifications
+}
+
+// save writes the results to the store's file; callers hold the mutex
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s.results, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	// Write to a temporary file first so a crash never leaves a partial file
+	if err := os.WriteFile(s.path+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("failed to write probe results: %w", err)
+	}
+	if err := os.Rename(s.path+".tmp", s.path); err != nil {
+		return fmt.Errorf("failed to write probe results: %w", err)
+	}
+	return nil
+}
+
+// Get returns the latest result of provider
+func (s *Store) Get(provider string) (*Result, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	result, ok := s.results[provider]
+	return result, ok
+}
+
+// List returns the latest result of every probed provider, by provider name
+func (s *Store) List() []*Result {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	results := make([]*Result, 0, len(s.results))
+	for _, result := range s.results {
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Provider < results[j].Provider
+	})
+	return results
+}
+
+// Stale reports whether provider has no successful probe newer than maxAge,
+// or a feature it verified before that has not been verified since maxAge
+func (s *Store) Stale(provider string, maxAge time.Duration) bool {
+	result, ok := s.Get(provider)
+	return !ok || result.stale(maxAge)
+}
+
+// stale reports whether the result is due to be probed again
+func (r *Result) stale(maxAge time.Duration) bool {
+	if r.Error != "" || time.Since(r.ProbedAt) > maxAge {
+		return true
+	}
+	oldest := r.OldestVerification()
+	return !oldest.IsZero() && time.Since(oldest) > maxAge
+}
+
+// verifiedAt returns when feature was last verified, zero if never
+func (r *Result) verifiedAt(feature Feature) time.Time {
+	for _, check := range r.Checks {
+		if check.Feature == feature {
+			return check.VerifiedAt
+		}
+	}
+	return time.Time{}
+}
+
+// lastConclusive returns the status feature was last verified with, falling
+// back to the change that flagged it when the result has no conclusive check
+func (r *Result) lastConclusive(feature Feature) (Status, bool) {
+	if status := r.Status(feature); status != Inconclusive {
+		return status, true
+	}
+	for _, change := range r.Changes {
+		if change.Feature == feature {
+			return change.Current, true
+		}
+	}
+	return Inconclusive, false
+}
+
+// mergeChanges returns the unacknowledged changes of previous updated with
+// the features current verified differently. A change whose feature
+// returns to the status it was verified with before is dropped
+func mergeChanges(previous, current *Result) []Change {
+	changes := append([]Change(nil), previous.Changes...)
+	for _, check := range current.Checks {
+		if check.Status == Inconclusive {
+			continue
+		}
+		before, known := previous.lastConclusive(check.Feature)
+		if !known || before == check.Status {
+			continue
+		}
+		found := false
+		for i := range changes {
+			if changes[i].Feature != check.Feature {
+				continue
+			}
+			found = true
+			changes[i].Current = check.Status
+			changes[i].DetectedAt = current.ProbedAt
+			if changes[i].Previous == check.Status {
+				changes = append(changes[:i], changes[i+1:]...)
+			}
+			break
+		}
+		if !found {
+			changes = append(changes, Change{Feature: check.Feature, Previous: before, Current: check.Status, DetectedAt: current.ProbedAt})
+		}
+	}
+	return changes
+}
+
+// Supports reports whether provider was probed for capability and, if so,
+// whether it is supported. Capabilities that are not probe features, and
+// inconclusive checks, are never known
+func (s *Store) Supports(provider, capability string) (supported, known bool) {
+	feature, ok := ParseFeature(strings.TrimSpace(capability))
+	if !ok {
+		return false, false
+	}
+	result, ok := s.Get(provider)
+	if !ok {
+		return false, false
+	}
+	switch result.Status(feature) {
+	case Supported:
+		return true, true
+	case Unsupported:
+		return false, true
+	default:
+		return false, false
+	}
+}
@@ -0,0 +1,148 @@
+// Package promptpolicy attaches operator-mandated system prompt prefixes and
+// suffixes to requests at dispatch time, by API key, mode and provider
+package promptpolicy
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rule attaches a system prompt prefix, suffix or both to the requests it
+// matches. Empty selectors match every request
+type Rule struct {
+	Name     string `yaml:"name" json:"name"`
+	Key      string `yaml:"key,omitempty" json:"key,omitempty"`
+	Mode     string `yaml:"mode,omitempty" json:"mode,omitempty"`
+	Provider string `yaml:"provider,omitempty" json:"provider,omitempty"`
+	Prefix   string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
+	Suffix   string `yaml:"suffix,omitempty" json:"suffix,omitempty"`
+}
+
+// Target is what a request is dispatched with
+type Target struct {
+	KeyID    string
+	Mode     string
+	Provider string
+}
+
+// matches reports whether every selector of the rule matches target.
+// Providers compare case-insensitively like elsewhere in selection
+func (r *Rule) matches(target Target) bool {
+	return (r.Key == "" || r.Key == target.KeyID) &&
+		(r.Mode == "" || r.Mode == target.Mode) &&
+		(r.Provider == "" || strings.EqualFold(r.Provider, target.Provider))
+}
+
+// specificity ranks a rule by its selectors: a provider outweighs a key,
+// which outweighs a mode, and any combination outweighs its parts
+func (r *Rule) specificity() int {
+	specificity := 0
+	if r.Provider != "" {
+		specificity += 4
+	}
+	if r.Key != "" {
+		specificity += 2
+	}
+	if r.Mode != "" {
+		specificity++
+	}
+	return specificity
+}
+
+// Resolution is the system prompt attached to a request
+type Resolution struct {
+	Prefix string `json:"-"`
+	Suffix string `json:"-"`
+	// PrefixRule and SuffixRule name the rules that supplied them
+	PrefixRule string `json:"prefix_rule,omitempty"`
+	SuffixRule string `json:"suffix_rule,omitempty"`
+}
+
+// IsZero reports whether no rule applied
+func (r Resolution) IsZero() bool {
+	return r.PrefixRule == "" && r.SuffixRule == ""
+}
+
+// Apply wraps a system prompt, which may be empty, in the prefix and suffix
+func (r Resolution) Apply(systemPrompt string) string {
+	parts := make([]string, 0, 3)
+	for _, part := range []string{r.Prefix, systemPrompt, r.Suffix} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// Policy resolves the system prompt for each request
+type Policy struct {
+	rules []Rule
+}
+
+// NewPolicy validates rules
+func NewPolicy(rules []Rule) (*Policy, error) {
+	names := make(map[string]bool, len(rules))
+	for i, rule := range rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("rule %d: name is required", i+1)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("rule %s: name is used more than once", rule.Name)
+		}
+		names[rule.Name] = true
+		if rule.Prefix == "" && rule.Suffix == "" {
+			return nil, fmt.Errorf("rule %s: needs a prefix or a suffix", rule.Name)
+		}
+	}
+	return &Policy{rules: append([]Rule(nil), rules...)}, nil
+}
+
+// LoadPolicy reads rules from a YAML file with a top-level "rules" list
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read system prompt policy: %w", err)
+	}
+
+	var file struct {
+		Rules []Rule `yaml:"rules"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse system prompt policy %s: %w", path, err)
+	}
+	return NewPolicy(file.Rules)
+}
+
+// Rules returns the configured rules in file order
+func (p *Policy) Rules() []Rule {
+	return append([]Rule(nil), p.rules...)
+}
+
+// Resolve returns the prefix and suffix for target. Each comes from the most
+// specific matching rule that sets it, the earliest in the file among equally
+// specific ones, so a provider rule's prefix can coexist with a broader
+// rule's suffix
+func (p *Policy) Resolve(target Target) Resolution {
+	var resolution Resolution
+	if p == nil {
+		return resolution
+	}
+	prefixRank, suffixRank := -1, -1
+	for i := range p.rules {
+		rule := &p.rules[i]
+		if !rule.matches(target) {
+			continue
+		}
+		rank := rule.specificity()
+		if rule.Prefix != "" && rank > prefixRank {
+			resolution.Prefix, resolution.PrefixRule, prefixRank = rule.Prefix, rule.Name, rank
+		}
+		if rule.Suffix != "" && rank > suffixRank {
+			resolution.Suffix, resolution.SuffixRule, suffixRank = rule.Suffix, rule.Name, rank
+		}
+	}
+	return resolution
+}
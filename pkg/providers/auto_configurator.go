@@ -7,6 +7,8 @@ import (
 	"path/filepath"
 	"strings"
 	"text/template"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
 )
 
 // AutoConfigurator generates provider configurations automatically
@@ -44,7 +46,7 @@ func (a *AutoConfigurator) GenerateConfigurations(ctx context.Context) error {
 // generateProviderConfig creates a YAML configuration file for a single provider
 func (a *AutoConfigurator) generateProviderConfig(ctx context.Context, provider *ProviderConfig) error {
 	// Create tier directory
-	tierDir := filepath.Join(a.outputDir, provider.Tier)
+	tierDir := filepath.Join(a.outputDir, provider.Tier.String())
 	if err := os.MkdirAll(tierDir, 0755); err != nil {
 		return fmt.Errorf("failed to create tier directory: %w", err)
 	}
@@ -146,7 +148,7 @@ cost_optimization:
 
 // GenerateScriptTemplate creates a template script for unofficial providers
 func (a *AutoConfigurator) GenerateScriptTemplate(provider *ProviderConfig, scriptPath string) error {
-	if provider.Tier != "unofficial" {
+	if provider.Tier != tier.Unofficial {
 		return nil // Only generate scripts for unofficial providers
 	}
 
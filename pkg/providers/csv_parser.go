@@ -1,44 +1,27 @@
 package providers
 
 import (
-	"encoding/csv"
 	"fmt"
 	"os"
-	"strings"
+	"sort"
 
 	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
 )
 
 // CSVParser handles loading provider configurations from CSV files
 type CSVParser struct {
 	csvPath string
+	// overrideColumns is whether the loaded file had capability override
+	// columns, which SaveProviders then keeps
+	overrideColumns bool
 }
 
-// ModelsSource defines how models are retrieved for a provider
-type ModelsSource struct {
-	Type  string      `json:"type"`  // "list", "endpoint", "script"
-	Value interface{} `json:"value"` // []string, string URL, or script path
-}
-
-// ProviderConfig represents a provider configuration aligned with canonical config
-type ProviderConfig struct {
-	ID           string              `yaml:"id"`
-	Name         string              `yaml:"name"`
-	Tier         string              `yaml:"tier"`
-	Endpoint     string              `yaml:"endpoint"`
-	URL          string              `yaml:"url"`
-	APIKey       string              `yaml:"api_key"`
-	Priority     int                 `yaml:"priority"`
-	ModelsSource ModelsSource        `yaml:"models_source"`
-	Headers      map[string]string   `yaml:"headers"`
-	Enabled      bool                `yaml:"enabled"`
-	Description  string              `yaml:"description"`
-	Models       []string            `yaml:"models"`
-	Type         string              `yaml:"type"`
-	Capabilities config.Capabilities `yaml:"capabilities"`
-	CostTracking config.CostTracking `yaml:"cost_tracking"`
-	Metadata     map[string]string   `yaml:"metadata"`
-}
+// ProviderConfig and ModelsSource are the canonical provider model in pkg/config
+type (
+	ProviderConfig = config.ProviderConfig
+	ModelsSource   = config.ModelsSource
+)
 
 // NewCSVParser creates a new CSV parser instance
 func NewCSVParser(csvPath string) *CSVParser {
@@ -49,120 +32,56 @@ func NewCSVParser(csvPath string) *CSVParser {
 
 // LoadProviders reads and parses the CSV file to load provider configurations
 func (p *CSVParser) LoadProviders() (map[string]*ProviderConfig, error) {
-	file, err := os.Open(p.csvPath)
+	parsed, err := config.ReadProviderCSVFile(p.csvPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open CSV file: %w", err)
+		return nil, err
 	}
-	defer file.Close()
-
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
-	if err != nil {
-		return nil, fmt.Errorf("failed to read CSV file: %w", err)
-	}
-
-	if len(records) == 0 {
-		return nil, fmt.Errorf("CSV file is empty")
-	}
-
-	// Skip header row
-	if len(records) < 2 {
+	if len(parsed.Providers) == 0 {
 		return nil, fmt.Errorf("CSV file must have at least one provider entry")
 	}
+	p.overrideColumns = parsed.OverrideColumns
 
 	providers := make(map[string]*ProviderConfig)
-
-	for i, record := range records[1:] { // Skip header
-		if len(record) < 4 {
-			return nil, fmt.Errorf("invalid CSV format at line %d: expected at least 4 columns", i+2)
-		}
-
-		name := strings.TrimSpace(record[0])
-		tier := strings.TrimSpace(record[1])
-		endpoint := strings.TrimSpace(record[2])
-		modelsStr := strings.TrimSpace(record[3])
-
-		if name == "" || tier == "" || endpoint == "" {
-			continue // Skip empty rows
-		}
-
-		// Create ProviderConfig based on canonical definition from pkg/config/types.go
-		provider := &ProviderConfig{
-			Name:     name,
-			Tier:     tier,
-			Endpoint: endpoint,
-			URL:      "", // optional, can be populated later if needed
-			Enabled:  true,
-			ModelsSource: ModelsSource{
-				Type:  "list",
-				Value: []string{},
-			},
-			Metadata:     map[string]string{},
-			Capabilities: config.Capabilities{}, // initialize to empty
-			CostTracking: config.CostTracking{}, // initialize to empty
+	for _, row := range parsed.Providers {
+		if row.Tier == "" || row.Endpoint == "" {
+			continue // Skip incomplete rows
 		}
-
-		// Parse models source (retaining original logic for ModelsSource)
-		provider.ModelsSource = p.parseModelsSource(modelsStr)
-
-		providers[name] = provider
+		provider := row.ProviderConfig()
+		providers[row.Name] = &provider
 	}
 
 	return providers, nil
 }
 
-// parseModelsSource parses the models string from CSV into ModelsSource
-func (p *CSVParser) parseModelsSource(modelsField string) ModelsSource {
-	// Check if it's a URL
-	if strings.HasPrefix(modelsField, "http://") || strings.HasPrefix(modelsField, "https://") {
-		return ModelsSource{
-			Type:  "endpoint",
-			Value: modelsField,
-		}
-	}
-
-	// Parse as pipe-delimited list
-	models := []string{}
-	if modelsField != "" {
-		rawModels := strings.Split(modelsField, "|")
-		for _, model := range rawModels {
-			model = strings.TrimSpace(model)
-			if model != "" {
-				models = append(models, model)
-			}
-		}
-	}
-
-	return ModelsSource{
-		Type:  "list",
-		Value: models,
-	}
-}
-
 // ValidateProvider checks if a provider configuration is valid
 func (p *CSVParser) ValidateProvider(provider *ProviderConfig) error {
+	return validateProvider(provider)
+}
+
+// validateProvider checks a provider configuration regardless of its source
+func validateProvider(provider *ProviderConfig) error {
 	if provider.Name == "" {
 		return fmt.Errorf("provider name cannot be empty")
 	}
 
-	validTiers := map[string]bool{
-		"official":   true,
-		"community":  true,
-		"unofficial": true,
-	}
-
-	if !validTiers[provider.Tier] {
-		return fmt.Errorf("invalid tier '%s': must be one of official, community, unofficial", provider.Tier)
+	if _, err := tier.Parse(provider.Tier.String()); err != nil {
+		return err
 	}
 
 	if provider.Endpoint == "" {
 		return fmt.Errorf("provider endpoint cannot be empty")
 	}
 
+	if err := provider.CapabilityOverrides.Validate(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
-// SaveProviders writes provider configurations back to CSV
+// SaveProviders writes provider configurations back to CSV in the current
+// schema. Override columns are written when the loaded file had them or a
+// provider sets an override
 func (p *CSVParser) SaveProviders(providers map[string]*ProviderConfig) error {
 	file, err := os.Create(p.csvPath)
 	if err != nil {
@@ -170,45 +89,16 @@ func (p *CSVParser) SaveProviders(providers map[string]*ProviderConfig) error {
 	}
 	defer file.Close()
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
-	// Write header
-	header := []string{"Name", "Tier", "Endpoint", "Model(s)"}
-	if err := writer.Write(header); err != nil {
-		return fmt.Errorf("failed to write CSV header: %w", err)
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
 	}
+	sort.Strings(names)
 
-	// Write provider records
-	for _, provider := range providers {
-		var modelsStr string
-
-		switch provider.ModelsSource.Type {
-		case "list":
-			if models, ok := provider.ModelsSource.Value.([]string); ok {
-				modelsStr = strings.Join(models, "|")
-			}
-		case "endpoint":
-			if endpoint, ok := provider.ModelsSource.Value.(string); ok {
-				modelsStr = endpoint
-			}
-		case "script":
-			if script, ok := provider.ModelsSource.Value.(string); ok {
-				modelsStr = script
-			}
-		}
-
-		record := []string{
-			provider.Name,
-			provider.Tier,
-			provider.Endpoint,
-			modelsStr,
-		}
-
-		if err := writer.Write(record); err != nil {
-			return fmt.Errorf("failed to write provider record: %w", err)
-		}
+	rows := make([]config.CSVProvider, 0, len(providers))
+	for _, name := range names {
+		rows = append(rows, providers[name].CSVProvider())
 	}
 
-	return nil
+	return config.WriteProviderCSVColumns(file, rows, p.overrideColumns)
 }
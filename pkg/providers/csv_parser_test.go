@@ -0,0 +1,61 @@
+package providers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+)
+
+// TestSaveProvidersOverrideColumns checks that SaveProviders writes the
+// capability override columns only when the loaded file had them or a
+// provider sets an override
+func TestSaveProvidersOverrideColumns(t *testing.T) {
+	const plain = "name,tier,endpoint,auth,models,capabilities,limits,region,priority,description\n" +
+		"OpenAI,official,https://api.openai.com/v1,,gpt-4o,,,,,\n"
+	const withColumns = "name,tier,endpoint,auth,models,capabilities,limits,region,priority," +
+		"text,image,code,audio,video,multimodal,reasoning,knowledge,computation,description\n" +
+		"OpenAI,official,https://api.openai.com/v1,,gpt-4o,,,,,,,,,,,,,,\n"
+
+	tests := []struct {
+		name        string
+		content     string
+		setOverride bool
+		want        bool
+	}{
+		{"no columns and no overrides", plain, false, false},
+		{"columns in the input", withColumns, false, true},
+		{"an override set", plain, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "providers.csv")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			parser := NewCSVParser(path)
+			providers, err := parser.LoadProviders()
+			if err != nil {
+				t.Fatalf("LoadProviders: %v", err)
+			}
+			if tt.setOverride {
+				reasoning := 8
+				providers["OpenAI"].CapabilityOverrides = &config.CapabilityOverrides{Reasoning: &reasoning}
+			}
+			if err := parser.SaveProviders(providers); err != nil {
+				t.Fatalf("SaveProviders: %v", err)
+			}
+
+			saved, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			header, _, _ := strings.Cut(string(saved), "\n")
+			if got := strings.Contains(header, ",reasoning,"); got != tt.want {
+				t.Errorf("header %q has override columns = %t, want %t", header, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+	"gopkg.in/yaml.v3"
+)
+
+// Provenance records which fragment files defined a provider
+type Provenance struct {
+	Provider string `json:"provider"`
+	// Source is the file whose definition is in use
+	Source string `json:"source"`
+	// Overridden lists the earlier files whose definitions Source replaced, in load order
+	Overridden []string `json:"overridden,omitempty"`
+}
+
+// FragmentDirSource merges the provider files in a directory such as
+// providers.d. Files are read in name order and a provider defined again in a
+// later file replaces the earlier definition as a whole, so a team file can
+// override a shared base
+type FragmentDirSource struct {
+	dir        string
+	provenance []Provenance
+	mutex      sync.RWMutex
+}
+
+// NewFragmentDirSource creates a source backed by the fragments in dir
+func NewFragmentDirSource(dir string) *FragmentDirSource {
+	return &FragmentDirSource{dir: dir}
+}
+
+// Name describes the source
+func (s *FragmentDirSource) Name() string {
+	return SourceFragmentDir + ":" + s.dir
+}
+
+// Load merges every *.csv, *.yaml, *.yml and *.json file in the directory
+func (s *FragmentDirSource) Load(ctx context.Context) ([]ProviderConfig, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", s.dir, err)
+	}
+	// ReadDir returns entries sorted by file name, which is the merge order
+
+	merged := make(map[string]ProviderConfig)
+	provenance := make(map[string]*Provenance)
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		file := filepath.Join(s.dir, entry.Name())
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		parsed, ok, err := parseProviderFile(entry.Name(), data)
+		if !ok {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+
+		// Duplicates within one fragment are mistakes, not overrides
+		seen := make(map[string]bool, len(parsed))
+		for _, provider := range parsed {
+			if seen[provider.Name] {
+				return nil, fmt.Errorf("%s: provider %q is defined more than once", file, provider.Name)
+			}
+			seen[provider.Name] = true
+
+			if previous, ok := provenance[provider.Name]; ok {
+				previous.Overridden = append(previous.Overridden, previous.Source)
+				previous.Source = entry.Name()
+			} else {
+				provenance[provider.Name] = &Provenance{Provider: provider.Name, Source: entry.Name()}
+			}
+			merged[provider.Name] = provider
+		}
+	}
+
+	configs := make([]ProviderConfig, 0, len(merged))
+	for _, provider := range merged {
+		configs = append(configs, provider)
+	}
+	configs, err = finishProviderConfigs(configs)
+	if err != nil {
+		return nil, err
+	}
+
+	report := make([]Provenance, 0, len(configs))
+	for _, provider := range configs {
+		origin := *provenance[provider.Name]
+		if len(origin.Overridden) > 0 {
+			logger.Debugf("Provider %s from %s overrides %s", origin.Provider, origin.Source, strings.Join(origin.Overridden, ", "))
+		}
+		report = append(report, origin)
+	}
+
+	s.mutex.Lock()
+	s.provenance = report
+	s.mutex.Unlock()
+	return configs, nil
+}
+
+// Provenance returns, by provider name, the files behind each provider of the
+// last successful load
+func (s *FragmentDirSource) Provenance() []Provenance {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return append([]Provenance(nil), s.provenance...)
+}
+
+// parseProviderFile parses a provider file by its extension: a providers.csv
+// for .csv, provider documents for .yaml, .yml and .json. ok is false for any
+// other file, which callers skip
+func parseProviderFile(name string, data []byte) (configs []ProviderConfig, ok bool, err error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		csvFile, err := config.ReadProviderCSV(bytes.NewReader(data))
+		if err != nil {
+			return nil, true, err
+		}
+		for _, row := range csvFile.Providers {
+			configs = append(configs, row.ProviderConfig())
+		}
+		return configs, true, nil
+	case ".json":
+		configs, err = decodeProviderDocuments(data, json.Unmarshal)
+		return configs, true, err
+	case ".yaml", ".yml":
+		configs, err = decodeProviderDocuments(data, yaml.Unmarshal)
+		return configs, true, err
+	default:
+		return nil, false, nil
+	}
+}
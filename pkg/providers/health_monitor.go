@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"sync"
 	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
 )
 
 // HealthStatus represents the health status of a provider
@@ -109,11 +111,11 @@ func (h *HealthMonitor) checkProviderHealth(provider *ProviderConfig) {
 	// Perform health check based on provider tier
 	var err error
 	switch provider.Tier {
-	case "official":
+	case tier.Official:
 		err = h.checkOfficialProvider(provider)
-	case "community":
+	case tier.Community:
 		err = h.checkCommunityProvider(provider)
-	case "unofficial":
+	case tier.Unofficial:
 		err = h.checkUnofficialProvider(provider)
 	default:
 		err = fmt.Errorf("unknown provider tier: %s", provider.Tier)
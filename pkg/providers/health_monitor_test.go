@@ -0,0 +1,75 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
+)
+
+// TestHealthMonitorConcurrentAccess registers providers, checks them in the
+// background and reads their statuses at the same time, the way the monitor
+// loop and HTTP handlers share it. It finds races only under go test -race
+func TestHealthMonitorConcurrentAccess(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer healthy.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	monitor := NewHealthMonitor(nil, 5*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	loopDone := make(chan struct{})
+	go func() {
+		defer close(loopDone)
+		monitor.Start(ctx)
+	}()
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				name := fmt.Sprintf("provider-%d", (worker+i)%6)
+				switch worker % 4 {
+				case 0:
+					endpoint := healthy.URL
+					if i%2 == 1 {
+						endpoint = failing.URL
+					}
+					monitor.RegisterProvider(&ProviderConfig{Name: name, Tier: tier.Community, Endpoint: endpoint})
+				case 1:
+					monitor.checkAllProviders()
+				case 2:
+					if status, ok := monitor.GetHealthStatus(name); ok && status.Status.Status == "" {
+						t.Errorf("%s has an empty status", name)
+					}
+					monitor.IsProviderHealthy(name)
+				case 3:
+					monitor.GetAllHealthStatuses()
+					monitor.GetHealthyProviders()
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+	cancel()
+	<-loopDone
+
+	// A final check settles every provider on its last registered endpoint
+	monitor.checkAllProviders()
+	for name, status := range monitor.GetAllHealthStatuses() {
+		if status.Status.Status != "healthy" && status.Status.Status != "unhealthy" {
+			t.Errorf("%s: status %q after a check", name, status.Status.Status)
+		}
+	}
+}
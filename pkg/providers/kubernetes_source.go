@@ -0,0 +1,302 @@
+package providers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/kube"
+)
+
+// Provider CRD coordinates, matching deploy/kubernetes/provider-crd.yaml
+const (
+	ProviderCRDGroup   = "pal-moe.io"
+	ProviderCRDVersion = "v1alpha1"
+	ProviderCRDPlural  = "providers"
+)
+
+// KubernetesSource reads providers from a ConfigMap or from Provider custom
+// resources and follows changes through the Kubernetes watch API, so GitOps
+// tools can manage the provider inventory without files on disk.
+//
+// A ConfigMap may hold providers.csv under a key ending in .csv, or provider
+// documents under keys ending in .yaml, .yml or .json. Each Provider resource
+// holds one provider document in its spec; its name defaults to the resource name
+type KubernetesSource struct {
+	api           *kube.Client
+	namespace     string
+	configMap     string
+	labelSelector string
+}
+
+// NewConfigMapSource watches the named ConfigMap. apiServer may be empty to use
+// the in-cluster API server, and namespace empty to use the pod's namespace
+func NewConfigMapSource(apiServer, namespace, name string) (*KubernetesSource, error) {
+	api, namespace, err := kube.NewClient(apiServer, namespace)
+	if err != nil {
+		return nil, err
+	}
+	return &KubernetesSource{api: api, namespace: namespace, configMap: name}, nil
+}
+
+// NewProviderCRDSource watches Provider resources matching labelSelector
+func NewProviderCRDSource(apiServer, namespace, labelSelector string) (*KubernetesSource, error) {
+	api, namespace, err := kube.NewClient(apiServer, namespace)
+	if err != nil {
+		return nil, err
+	}
+	return &KubernetesSource{api: api, namespace: namespace, labelSelector: labelSelector}, nil
+}
+
+// Name describes the source
+func (s *KubernetesSource) Name() string {
+	if s.configMap != "" {
+		return fmt.Sprintf("%s:%s/%s", SourceConfigMap, s.namespace, s.configMap)
+	}
+	return fmt.Sprintf("%s:%s", SourceProviderCRD, s.namespace)
+}
+
+// Load lists the current objects once
+func (s *KubernetesSource) Load(ctx context.Context) ([]ProviderConfig, error) {
+	objects, _, err := s.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.providers(objects)
+}
+
+// watch lists the objects, then follows the watch stream, re-listing whenever
+// the stream ends or the resource version expires
+func (s *KubernetesSource) watch(ctx context.Context, onChange func([]ProviderConfig)) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		err := s.watchOnce(ctx, onChange)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			backoff = time.Second
+			continue
+		}
+
+		logger.Warnf("Watch on %s failed, retrying in %s: %v", s.Name(), backoff, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > 30*time.Second {
+			backoff = 30 * time.Second
+		}
+	}
+}
+
+// watchOnce runs one list-then-watch cycle. It returns nil when the server
+// closes the stream normally
+func (s *KubernetesSource) watchOnce(ctx context.Context, onChange func([]ProviderConfig)) error {
+	objects, resourceVersion, err := s.list(ctx)
+	if err != nil {
+		return err
+	}
+	s.publish(objects, onChange)
+
+	query := s.query()
+	query.Set("watch", "1")
+	query.Set("resourceVersion", resourceVersion)
+	query.Set("allowWatchBookmarks", "true")
+	query.Set("timeoutSeconds", "300")
+
+	resp, err := s.api.Get(ctx, s.path(), query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64<<10), maxSourceBytes)
+	for scanner.Scan() {
+		var event struct {
+			Type   string          `json:"type"`
+			Object json.RawMessage `json:"object"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return fmt.Errorf("failed to decode watch event: %w", err)
+		}
+
+		switch event.Type {
+		case "ADDED", "MODIFIED", "DELETED":
+			var object kubeObject
+			if err := json.Unmarshal(event.Object, &object); err != nil {
+				return fmt.Errorf("failed to decode watched object: %w", err)
+			}
+			key := object.Metadata.Namespace + "/" + object.Metadata.Name
+			if event.Type == "DELETED" {
+				delete(objects, key)
+			} else {
+				objects[key] = object
+			}
+			s.publish(objects, onChange)
+		case "BOOKMARK":
+		case "ERROR":
+			var status struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			}
+			json.Unmarshal(event.Object, &status)
+			if status.Code == http.StatusGone {
+				return nil // Resource version expired; re-list
+			}
+			return fmt.Errorf("watch error %d: %s", status.Code, status.Message)
+		}
+	}
+	return scanner.Err()
+}
+
+// publish converts objects and hands the result to onChange, keeping the last
+// good inventory when they do not describe a valid one
+func (s *KubernetesSource) publish(objects map[string]kubeObject, onChange func([]ProviderConfig)) {
+	configs, err := s.providers(objects)
+	if err != nil {
+		logger.Warnf("Ignoring change to %s: %v", s.Name(), err)
+		return
+	}
+	onChange(configs)
+}
+
+// list fetches the watched objects keyed by namespace/name, with the list's resource version
+func (s *KubernetesSource) list(ctx context.Context) (map[string]kubeObject, string, error) {
+	resp, err := s.api.Get(ctx, s.path(), s.query())
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	var list struct {
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+		Items []kubeObject `json:"items"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxSourceBytes)).Decode(&list); err != nil {
+		return nil, "", fmt.Errorf("failed to decode list response: %w", err)
+	}
+
+	objects := make(map[string]kubeObject, len(list.Items))
+	for _, object := range list.Items {
+		objects[object.Metadata.Namespace+"/"+object.Metadata.Name] = object
+	}
+	return objects, list.Metadata.ResourceVersion, nil
+}
+
+// path is the collection URL of the watched resource
+func (s *KubernetesSource) path() string {
+	if s.configMap != "" {
+		return "/api/v1/namespaces/" + url.PathEscape(s.namespace) + "/configmaps"
+	}
+	return "/apis/" + ProviderCRDGroup + "/" + ProviderCRDVersion + "/namespaces/" +
+		url.PathEscape(s.namespace) + "/" + ProviderCRDPlural
+}
+
+// query narrows the collection to the watched objects
+func (s *KubernetesSource) query() url.Values {
+	query := url.Values{}
+	if s.configMap != "" {
+		query.Set("fieldSelector", "metadata.name="+s.configMap)
+	}
+	if s.labelSelector != "" {
+		query.Set("labelSelector", s.labelSelector)
+	}
+	return query
+}
+
+// providers converts the watched objects into a provider inventory
+func (s *KubernetesSource) providers(objects map[string]kubeObject) ([]ProviderConfig, error) {
+	if s.configMap != "" {
+		object, ok := objects[s.namespace+"/"+s.configMap]
+		if !ok {
+			return nil, fmt.Errorf("configmap %s/%s not found", s.namespace, s.configMap)
+		}
+		return providersFromConfigMap(object.Data)
+	}
+	return providersFromCRDs(objects), nil
+}
+
+// providersFromConfigMap parses every provider file stored in a ConfigMap
+func providersFromConfigMap(data map[string]string) ([]ProviderConfig, error) {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var configs []ProviderConfig
+	for _, key := range keys {
+		parsed, ok, err := parseProviderFile(key, []byte(data[key]))
+		if !ok {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", key, err)
+		}
+		configs = append(configs, parsed...)
+	}
+	return finishProviderConfigs(configs)
+}
+
+// providersFromCRDs converts Provider resources. An invalid resource is skipped
+// with a warning so one bad manifest cannot empty the inventory
+func providersFromCRDs(objects map[string]kubeObject) []ProviderConfig {
+	keys := make([]string, 0, len(objects))
+	for key := range objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	configs := make([]ProviderConfig, 0, len(keys))
+	seen := make(map[string]string)
+	for _, key := range keys {
+		var document providerDocument
+		if err := json.Unmarshal(objects[key].Spec, &document); err != nil {
+			logger.Warnf("Skipping Provider %s: invalid spec: %v", key, err)
+			continue
+		}
+		if document.Name == "" {
+			document.Name = objects[key].Metadata.Name
+		}
+		if err := document.expandEnv(); err != nil {
+			logger.Warnf("Skipping Provider %s: %v", key, err)
+			continue
+		}
+
+		provider := document.providerConfig()
+		if err := validateProvider(&provider); err != nil {
+			logger.Warnf("Skipping Provider %s: %v", key, err)
+			continue
+		}
+		if other, exists := seen[provider.Name]; exists {
+			logger.Warnf("Skipping Provider %s: name %q is already used by %s", key, provider.Name, other)
+			continue
+		}
+		seen[provider.Name] = key
+		configs = append(configs, provider)
+	}
+
+	sortProviderConfigs(configs)
+	return configs
+}
+
+// kubeObject holds the parts of a ConfigMap or Provider resource the source reads
+type kubeObject struct {
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+	Data map[string]string `json:"data"`
+	Spec json.RawMessage   `json:"spec"`
+}
@@ -38,30 +38,45 @@ func (m *Manager) LoadFromConfig(configs []ProviderConfig) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	m.loadLocked(configs)
+	return nil
+}
+
+// Sync replaces the provider inventory with configs. Providers missing from
+// configs are removed; those that remain keep their health state
+func (m *Manager) Sync(configs []ProviderConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	previous := m.providers
+	m.providers = make(map[string]*Provider, len(configs))
+	m.loadLocked(configs)
+
+	for name, provider := range m.providers {
+		if old, exists := previous[name]; exists {
+			provider.IsHealthy = old.IsHealthy
+			provider.LastChecked = old.LastChecked
+		}
+	}
+}
+
+// loadLocked adds or replaces providers; the caller must hold mu
+func (m *Manager) loadLocked(configs []ProviderConfig) {
 	for _, config := range configs {
-		// Extract models from ModelsSource
-		var models []string
-		switch config.ModelsSource.Type {
-		case "list":
-			if modelList, ok := config.ModelsSource.Value.([]string); ok {
-				models = modelList
-			}
-		case "endpoint":
-			// For endpoint type, we'll fetch models dynamically later
-			models = []string{} // Empty initially, will be populated by dynamic loader
-		case "script":
-			// For script type, we'll execute script later
-			models = []string{} // Empty initially, will be populated by script execution
+		// Endpoint and script sources start empty and are populated by discovery
+		models := config.ModelsSource.List()
+		if models == nil {
+			models = []string{}
 		}
 
 		provider := &Provider{
 			Name:         config.Name,
 			URL:          config.Endpoint, // Use Endpoint field from ProviderConfig
 			Models:       models,
-			Capabilities: []string{config.Tier}, // Use Tier as capability
+			Capabilities: []string{config.Tier.String()}, // Use Tier as capability
 			Priority:     1, // Default priority
 			Metadata: map[string]interface{}{
-				"tier":          config.Tier,
+				"tier":          config.Tier.String(),
 				"endpoint":      config.Endpoint,
 				"models_source": config.ModelsSource,
 				"enabled":       config.Enabled,
@@ -72,8 +87,6 @@ func (m *Manager) LoadFromConfig(configs []ProviderConfig) error {
 
 		m.providers[config.Name] = provider
 	}
-
-	return nil
 }
 
 // GetProvider returns a provider by name
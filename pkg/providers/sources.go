@@ -0,0 +1,463 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/logging"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/reload"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
+	"gopkg.in/yaml.v3"
+)
+
+var logger = logging.Module("providers")
+
+// ProviderSource supplies provider configurations from a backing store
+type ProviderSource interface {
+	// Name describes the source in logs, e.g. "csv:providers.csv"
+	Name() string
+	// Load returns the complete current provider inventory
+	Load(ctx context.Context) ([]ProviderConfig, error)
+}
+
+// liveSource is implemented by sources that push changes instead of being
+// polled. watch runs until ctx is done, calling onChange with each new inventory
+type liveSource interface {
+	watch(ctx context.Context, onChange func([]ProviderConfig))
+}
+
+// Source types accepted by SourceConfig.Type
+const (
+	SourceCSV         = "csv"
+	SourceYAMLDir     = "yaml_dir"
+	SourceFragmentDir = "fragment_dir"
+	SourceJSON        = "json"
+	SourceURL         = "url"
+	SourceConfigMap   = "kubernetes_configmap"
+	SourceProviderCRD = "kubernetes_crd"
+)
+
+// SourceConfig selects and configures a provider source
+type SourceConfig struct {
+	Type         string            `yaml:"type" json:"type"`
+	Path         string            `yaml:"path,omitempty" json:"path,omitempty"`
+	URL          string            `yaml:"url,omitempty" json:"url,omitempty"`
+	Headers      map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	PollInterval time.Duration     `yaml:"poll_interval,omitempty" json:"poll_interval,omitempty"`
+
+	// Kubernetes sources. URL overrides the in-cluster API server, e.g. for kubectl proxy
+	Namespace     string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+	ConfigMap     string `yaml:"configmap,omitempty" json:"configmap,omitempty"`
+	LabelSelector string `yaml:"label_selector,omitempty" json:"label_selector,omitempty"`
+}
+
+// NewSource creates the source described by cfg. An empty type means CSV
+func NewSource(cfg SourceConfig) (ProviderSource, error) {
+	switch strings.ToLower(cfg.Type) {
+	case "", SourceCSV:
+		path := cfg.Path
+		if path == "" {
+			path = "providers.csv"
+		}
+		return NewCSVSource(path), nil
+	case SourceYAMLDir, "yaml":
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("provider source %s requires a path", cfg.Type)
+		}
+		return NewYAMLDirSource(cfg.Path), nil
+	case SourceFragmentDir:
+		path := cfg.Path
+		if path == "" {
+			path = "providers.d"
+		}
+		return NewFragmentDirSource(path), nil
+	case SourceJSON:
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("provider source %s requires a path", cfg.Type)
+		}
+		return NewJSONFileSource(cfg.Path), nil
+	case SourceURL:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("provider source %s requires a url", cfg.Type)
+		}
+		source := NewURLSource(cfg.URL)
+		for name, value := range cfg.Headers {
+			source.SetHeader(name, value)
+		}
+		return source, nil
+	case SourceConfigMap:
+		if cfg.ConfigMap == "" {
+			return nil, fmt.Errorf("provider source %s requires a configmap name", cfg.Type)
+		}
+		return NewConfigMapSource(cfg.URL, cfg.Namespace, cfg.ConfigMap)
+	case SourceProviderCRD:
+		return NewProviderCRDSource(cfg.URL, cfg.Namespace, cfg.LabelSelector)
+	default:
+		return nil, fmt.Errorf("unknown provider source type %q", cfg.Type)
+	}
+}
+
+// SourceWatch keeps a manager in sync with a provider source
+type SourceWatch struct {
+	source  ProviderSource
+	manager *Manager
+	current []ProviderConfig
+	mutex   sync.Mutex
+}
+
+// WatchSource loads source into manager and keeps it in sync until ctx is done.
+// Sources that push changes, such as Kubernetes, are watched; others are
+// reloaded every interval, and not at all when interval is zero. The manager is
+// only synced when the inventory changes; failed reloads keep the last good one.
+// The returned watch also reloads on demand, through Prepare
+func WatchSource(ctx context.Context, source ProviderSource, manager *Manager, interval time.Duration) (*SourceWatch, error) {
+	current, err := source.Load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load providers from %s: %w", source.Name(), err)
+	}
+	manager.Sync(current)
+	logger.Infof("Loaded %d providers from %s", len(current), source.Name())
+	watch := &SourceWatch{source: source, manager: manager, current: current}
+
+	if live, ok := source.(liveSource); ok {
+		go live.watch(ctx, watch.apply)
+		return watch, nil
+	}
+	if interval <= 0 {
+		return watch, nil
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			next, err := source.Load(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.Warnf("Failed to reload providers from %s: %v", source.Name(), err)
+				}
+				continue
+			}
+			watch.apply(next)
+		}
+	}()
+	return watch, nil
+}
+
+// Prepare loads the source for a configuration reload, naming the providers
+// that were added, removed or changed, without syncing the manager
+func (w *SourceWatch) Prepare(ctx context.Context) (*reload.Change, error) {
+	next, err := w.source.Load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load providers from %s: %w", w.source.Name(), err)
+	}
+	w.mutex.Lock()
+	changed := changedProviders(w.current, next)
+	w.mutex.Unlock()
+	if len(changed) == 0 {
+		return nil, nil
+	}
+	return &reload.Change{Changed: changed, Apply: func() { w.apply(next) }}, nil
+}
+
+// apply syncs the manager with next unless the inventory is unchanged
+func (w *SourceWatch) apply(next []ProviderConfig) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if reflect.DeepEqual(next, w.current) {
+		return
+	}
+	w.manager.Sync(next)
+	w.current = next
+	logger.Infof("Provider inventory from %s changed, now %d providers", w.source.Name(), len(w.current))
+}
+
+// changedProviders names the providers that differ between two inventories
+func changedProviders(current, next []ProviderConfig) []string {
+	before := make(map[string]ProviderConfig, len(current))
+	for _, provider := range current {
+		before[provider.Name] = provider
+	}
+	var changed []string
+	for _, provider := range next {
+		previous, ok := before[provider.Name]
+		if !ok || !reflect.DeepEqual(previous, provider) {
+			changed = append(changed, provider.Name)
+		}
+		delete(before, provider.Name)
+	}
+	for name := range before {
+		changed = append(changed, name)
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// CSVSource reads providers from a providers.csv file
+type CSVSource struct {
+	parser *CSVParser
+	path   string
+}
+
+// NewCSVSource creates a source backed by the CSV file at path
+func NewCSVSource(path string) *CSVSource {
+	return &CSVSource{parser: NewCSVParser(path), path: path}
+}
+
+// Name describes the source
+func (s *CSVSource) Name() string {
+	return SourceCSV + ":" + s.path
+}
+
+// Load parses the CSV file
+func (s *CSVSource) Load(ctx context.Context) ([]ProviderConfig, error) {
+	loaded, err := s.parser.LoadProviders()
+	if err != nil {
+		return nil, err
+	}
+
+	configs := make([]ProviderConfig, 0, len(loaded))
+	for _, provider := range loaded {
+		configs = append(configs, *provider)
+	}
+	sortProviderConfigs(configs)
+	return configs, nil
+}
+
+// YAMLDirSource reads one or more providers from every *.yaml and *.yml file in a directory
+type YAMLDirSource struct {
+	dir string
+}
+
+// NewYAMLDirSource creates a source backed by the YAML files in dir
+func NewYAMLDirSource(dir string) *YAMLDirSource {
+	return &YAMLDirSource{dir: dir}
+}
+
+// Name describes the source
+func (s *YAMLDirSource) Name() string {
+	return SourceYAMLDir + ":" + s.dir
+}
+
+// Load parses every YAML file in the directory
+func (s *YAMLDirSource) Load(ctx context.Context) ([]ProviderConfig, error) {
+	var files []string
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		matches, err := filepath.Glob(filepath.Join(s.dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+
+	var configs []ProviderConfig
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		parsed, err := decodeProviderDocuments(data, yaml.Unmarshal)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		configs = append(configs, parsed...)
+	}
+	return finishProviderConfigs(configs)
+}
+
+// JSONFileSource reads providers from a single JSON file
+type JSONFileSource struct {
+	path string
+}
+
+// NewJSONFileSource creates a source backed by the JSON file at path
+func NewJSONFileSource(path string) *JSONFileSource {
+	return &JSONFileSource{path: path}
+}
+
+// Name describes the source
+func (s *JSONFileSource) Name() string {
+	return SourceJSON + ":" + s.path
+}
+
+// Load parses the JSON file
+func (s *JSONFileSource) Load(ctx context.Context) ([]ProviderConfig, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", s.path, err)
+	}
+	configs, err := decodeProviderDocuments(data, json.Unmarshal)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", s.path, err)
+	}
+	return finishProviderConfigs(configs)
+}
+
+// providerDocument is the provider shape shared by the YAML, JSON and URL sources.
+// It matches the files written by AutoConfigurator
+type providerDocument struct {
+	Name                string                      `yaml:"name" json:"name"`
+	Tier                string                      `yaml:"tier" json:"tier"`
+	Endpoint            string                      `yaml:"endpoint" json:"endpoint"`
+	APIKey              string                      `yaml:"api_key" json:"api_key"`
+	Enabled             *bool                       `yaml:"enabled" json:"enabled"`
+	Priority            int                         `yaml:"priority" json:"priority"`
+	Description         string                      `yaml:"description" json:"description"`
+	Region              string                      `yaml:"region" json:"region"`
+	Headers             map[string]string           `yaml:"headers" json:"headers"`
+	Models              []string                    `yaml:"models" json:"models"`
+	ModelsSource        *modelsSourceDocument       `yaml:"models_source" json:"models_source"`
+	Capabilities        []string                    `yaml:"capabilities" json:"capabilities"`
+	Limits              map[string]int              `yaml:"limits" json:"limits"`
+	CapabilityOverrides *config.CapabilityOverrides `yaml:"capability_overrides" json:"capability_overrides"`
+	Metadata            map[string]string           `yaml:"metadata" json:"metadata"`
+}
+
+// modelsSourceDocument describes where a provider's models come from
+type modelsSourceDocument struct {
+	Type     string   `yaml:"type" json:"type"`
+	Models   []string `yaml:"models" json:"models"`
+	Endpoint string   `yaml:"endpoint" json:"endpoint"`
+	Script   string   `yaml:"script" json:"script"`
+}
+
+// decodeProviderDocuments accepts a single provider, a list of providers, or an
+// object with a "providers" list
+func decodeProviderDocuments(data []byte, unmarshal func([]byte, interface{}) error) ([]ProviderConfig, error) {
+	var documents []providerDocument
+
+	var wrapped struct {
+		Providers *[]providerDocument `yaml:"providers" json:"providers"`
+	}
+	var list []providerDocument
+	var single providerDocument
+
+	if err := unmarshal(data, &wrapped); err == nil && wrapped.Providers != nil {
+		documents = *wrapped.Providers
+	} else if err := unmarshal(data, &list); err == nil {
+		documents = list
+	} else if err := unmarshal(data, &single); err == nil {
+		documents = []providerDocument{single}
+	} else {
+		return nil, fmt.Errorf("failed to parse providers: %w", err)
+	}
+
+	configs := make([]ProviderConfig, 0, len(documents))
+	for _, document := range documents {
+		if err := document.expandEnv(); err != nil {
+			return nil, fmt.Errorf("provider %q: %w", document.Name, err)
+		}
+		configs = append(configs, document.providerConfig())
+	}
+	return configs, nil
+}
+
+// expandEnv resolves ${VAR} references in the document's string fields
+func (d *providerDocument) expandEnv() error {
+	values := []*string{&d.Name, &d.Endpoint, &d.APIKey, &d.Description, &d.Region}
+	for i := range d.Models {
+		values = append(values, &d.Models[i])
+	}
+	for i := range d.Capabilities {
+		values = append(values, &d.Capabilities[i])
+	}
+	if source := d.ModelsSource; source != nil {
+		values = append(values, &source.Endpoint, &source.Script)
+		for i := range source.Models {
+			values = append(values, &source.Models[i])
+		}
+	}
+
+	// Map values are expanded through copies and written back on success
+	headers := make(map[string]*string, len(d.Headers))
+	for name, value := range d.Headers {
+		value := value
+		headers[name] = &value
+		values = append(values, &value)
+	}
+
+	if err := config.ExpandEnvAll(values...); err != nil {
+		return err
+	}
+	for name, value := range headers {
+		d.Headers[name] = *value
+	}
+	return nil
+}
+
+// providerConfig converts the document into the shape the Manager consumes
+func (d providerDocument) providerConfig() ProviderConfig {
+	provider := ProviderConfig{
+		Name:                 d.Name,
+		Tier:                 tier.Normalize(d.Tier),
+		Endpoint:             d.Endpoint,
+		APIKey:               d.APIKey,
+		Priority:             d.Priority,
+		Headers:              d.Headers,
+		Enabled:              d.Enabled == nil || *d.Enabled,
+		Description:          d.Description,
+		Region:               d.Region,
+		Limits:               d.Limits,
+		DeclaredCapabilities: d.Capabilities,
+		CapabilityOverrides:  d.CapabilityOverrides,
+		Metadata:             d.Metadata,
+		ModelsSource:         ModelsSource{Type: "list", Value: d.Models},
+	}
+	if provider.Metadata == nil {
+		provider.Metadata = map[string]string{}
+	}
+
+	if source := d.ModelsSource; source != nil {
+		switch source.Type {
+		case "endpoint":
+			provider.ModelsSource = ModelsSource{Type: "endpoint", Value: source.Endpoint}
+		case "script":
+			provider.ModelsSource = ModelsSource{Type: "script", Value: source.Script}
+		default:
+			provider.ModelsSource = ModelsSource{Type: "list", Value: append(source.Models, d.Models...)}
+		}
+	}
+	provider.Models = provider.ModelsSource.List()
+	return provider
+}
+
+// finishProviderConfigs validates configs, rejects duplicate names and sorts by name
+func finishProviderConfigs(configs []ProviderConfig) ([]ProviderConfig, error) {
+	seen := make(map[string]bool, len(configs))
+	for i := range configs {
+		if err := validateProvider(&configs[i]); err != nil {
+			return nil, fmt.Errorf("provider %q: %w", configs[i].Name, err)
+		}
+		if seen[configs[i].Name] {
+			return nil, fmt.Errorf("provider %q is defined more than once", configs[i].Name)
+		}
+		seen[configs[i].Name] = true
+	}
+	sortProviderConfigs(configs)
+	return configs, nil
+}
+
+// sortProviderConfigs orders configs by name so reloads compare equal
+func sortProviderConfigs(configs []ProviderConfig) {
+	sort.Slice(configs, func(i, j int) bool {
+		return configs[i].Name < configs[j].Name
+	})
+}
@@ -0,0 +1,129 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/requestid"
+	"gopkg.in/yaml.v3"
+)
+
+// maxSourceBytes bounds the size of a remote provider document
+const maxSourceBytes = 10 << 20
+
+// URLSource fetches providers as JSON or YAML from a remote URL. Conditional
+// requests with ETag / If-None-Match make frequent polling cheap
+type URLSource struct {
+	url    string
+	client *http.Client
+	header http.Header
+
+	mutex  sync.Mutex
+	etag   string
+	cached []ProviderConfig
+}
+
+// NewURLSource creates a source that fetches url
+func NewURLSource(url string) *URLSource {
+	return &URLSource{
+		url: url,
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		header: http.Header{},
+	}
+}
+
+// SetHeader adds a header, such as Authorization, sent with every fetch
+func (s *URLSource) SetHeader(name, value string) {
+	s.header.Set(name, value)
+}
+
+// SetHTTPClient replaces the underlying HTTP client
+func (s *URLSource) SetHTTPClient(client *http.Client) {
+	s.client = client
+}
+
+// Name describes the source
+func (s *URLSource) Name() string {
+	return SourceURL + ":" + s.url
+}
+
+// Load fetches the document, returning the cached inventory when the server
+// answers 304 Not Modified
+func (s *URLSource) Load(ctx context.Context) ([]ProviderConfig, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, values := range s.header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Accept", "application/json, application/yaml;q=0.9, */*;q=0.1")
+	if s.etag != "" && s.cached != nil {
+		req.Header.Set("If-None-Match", s.etag)
+	}
+	requestid.Inject(req)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", s.url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && s.cached != nil {
+		return s.cached, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s returned %s", s.url, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSourceBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", s.url, err)
+	}
+	if len(data) > maxSourceBytes {
+		return nil, fmt.Errorf("%s is larger than %d bytes", s.url, maxSourceBytes)
+	}
+
+	unmarshal := yaml.Unmarshal
+	if s.isJSON(resp.Header.Get("Content-Type")) {
+		unmarshal = json.Unmarshal
+	}
+	parsed, err := decodeProviderDocuments(data, unmarshal)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", s.url, err)
+	}
+	configs, err := finishProviderConfigs(parsed)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", s.url, err)
+	}
+
+	s.etag = resp.Header.Get("ETag")
+	s.cached = configs
+	return configs, nil
+}
+
+// isJSON decides the document format from the Content-Type, falling back to
+// the URL extension for servers that send text/plain
+func (s *URLSource) isJSON(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+		return true
+	}
+	if strings.Contains(mediaType, "yaml") {
+		return false
+	}
+	return strings.EqualFold(path.Ext(strings.SplitN(s.url, "?", 2)[0]), ".json")
+}
@@ -0,0 +1,230 @@
+// Package reasoning maps the effort a request asks of a reasoning model to
+// the knob that model takes: an effort level, a thinking token budget, or
+// nothing for models that always reason as much as they see fit
+package reasoning
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Effort is how hard a reasoning model should think before answering
+type Effort string
+
+// Effort levels a request may ask for
+const (
+	EffortLow    Effort = "low"
+	EffortMedium Effort = "medium"
+	EffortHigh   Effort = "high"
+)
+
+// Efforts lists every effort level, from the least to the most
+var Efforts = []Effort{EffortLow, EffortMedium, EffortHigh}
+
+// Capability is the provider capability of serving models that spend
+// reasoning tokens. Requests with an effort require it
+const Capability = "reasoning_tokens"
+
+// Parameters a model takes its effort in
+const (
+	// ParameterEffort sends the effort level, as OpenAI and xAI take it
+	ParameterEffort = "reasoning_effort"
+	// ParameterBudget sends a thinking budget in tokens, as Anthropic and
+	// Gemini take it
+	ParameterBudget = "thinking_budget"
+	// ParameterNone sends nothing; the model always reasons
+	ParameterNone = "none"
+)
+
+// DefaultBudgets are the thinking budgets of each effort, for budget models
+// without their own and for estimating what the other models spend
+var DefaultBudgets = map[Effort]int64{
+	EffortLow:    1024,
+	EffortMedium: 4096,
+	EffortHigh:   16384,
+}
+
+// ParseEffort reads an effort level, case-insensitively. An empty string is
+// no effort
+func ParseEffort(s string) (Effort, error) {
+	effort := Effort(strings.ToLower(strings.TrimSpace(s)))
+	if effort == "" {
+		return "", nil
+	}
+	if !known(effort) {
+		return "", fmt.Errorf("unknown reasoning effort %q: must be low, medium or high", s)
+	}
+	return effort, nil
+}
+
+// known reports whether effort is one of Efforts
+func known(effort Effort) bool {
+	for _, level := range Efforts {
+		if effort == level {
+			return true
+		}
+	}
+	return false
+}
+
+// Model is how a family of reasoning models takes its effort
+type Model struct {
+	// Match is the start of the model names the entry covers, compared
+	// case-insensitively and without an organization prefix such as openai/
+	Match     string `yaml:"match" json:"match"`
+	Parameter string `yaml:"parameter" json:"parameter"`
+	// Efforts maps efforts to the levels an effort model accepts, for
+	// models without all of low, medium and high
+	Efforts map[Effort]string `yaml:"efforts,omitempty" json:"efforts,omitempty"`
+	// Budgets replace the default thinking budgets of efforts
+	Budgets map[Effort]int64 `yaml:"budgets,omitempty" json:"budgets,omitempty"`
+}
+
+// DefaultModels are the reasoning models known without configuration
+var DefaultModels = []Model{
+	{Match: "o1", Parameter: ParameterEffort},
+	{Match: "o3", Parameter: ParameterEffort},
+	{Match: "o4-mini", Parameter: ParameterEffort},
+	{Match: "gpt-5", Parameter: ParameterEffort},
+	// Grok 3 mini knows only low and high
+	{Match: "grok-3-mini", Parameter: ParameterEffort, Efforts: map[Effort]string{EffortMedium: string(EffortLow)}},
+	{Match: "claude-3-7-sonnet", Parameter: ParameterBudget},
+	{Match: "claude-sonnet-4", Parameter: ParameterBudget},
+	{Match: "claude-opus-4", Parameter: ParameterBudget},
+	{Match: "gemini-2.5", Parameter: ParameterBudget},
+	{Match: "deepseek-r1", Parameter: ParameterNone},
+	{Match: "deepseek-reasoner", Parameter: ParameterNone},
+	{Match: "qwq", Parameter: ParameterNone},
+}
+
+// Setting is the effort one request is sent to a reasoning model with
+type Setting struct {
+	Effort    Effort `json:"effort"`
+	Parameter string `json:"parameter"`
+	// Value is the effort level sent to an effort model
+	Value string `json:"value,omitempty"`
+	// BudgetTokens is the thinking budget sent to a budget model, and what
+	// the other models are expected to spend
+	BudgetTokens int64 `json:"budget_tokens"`
+}
+
+// Table finds the reasoning models among model names
+type Table struct {
+	models []Model
+}
+
+// NewTable validates models, which take precedence over DefaultModels
+func NewTable(models []Model) (*Table, error) {
+	for i, model := range models {
+		if strings.TrimSpace(model.Match) == "" {
+			return nil, fmt.Errorf("model %d: match is required", i+1)
+		}
+		switch model.Parameter {
+		case ParameterEffort, ParameterBudget, ParameterNone:
+		default:
+			return nil, fmt.Errorf("model %s: unknown parameter %q: must be %s, %s or %s",
+				model.Match, model.Parameter, ParameterEffort, ParameterBudget, ParameterNone)
+		}
+		for effort, value := range model.Efforts {
+			if !known(effort) {
+				return nil, fmt.Errorf("model %s: unknown effort %q", model.Match, effort)
+			}
+			if strings.TrimSpace(value) == "" {
+				return nil, fmt.Errorf("model %s: effort %s maps to nothing", model.Match, effort)
+			}
+		}
+		for effort, budget := range model.Budgets {
+			if !known(effort) {
+				return nil, fmt.Errorf("model %s: unknown effort %q", model.Match, effort)
+			}
+			if budget <= 0 {
+				return nil, fmt.Errorf("model %s: budget of %s must be positive", model.Match, effort)
+			}
+		}
+	}
+	return &Table{models: append(append([]Model(nil), models...), DefaultModels...)}, nil
+}
+
+// DefaultTable returns a table of DefaultModels alone
+func DefaultTable() *Table {
+	return &Table{models: append([]Model(nil), DefaultModels...)}
+}
+
+// LoadTable reads models from a YAML file with a top-level "models" list
+func LoadTable(path string) (*Table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reasoning models: %w", err)
+	}
+
+	var file struct {
+		Models []Model `yaml:"models"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse reasoning models %s: %w", path, err)
+	}
+	return NewTable(file.Models)
+}
+
+// Models returns the configured models followed by the defaults
+func (t *Table) Models() []Model {
+	return append([]Model(nil), t.models...)
+}
+
+// Lookup returns the entry covering a model name. The longest match wins,
+// and configured entries win over defaults that match as far
+func (t *Table) Lookup(name string) (Model, bool) {
+	if t == nil {
+		return Model{}, false
+	}
+	name = strings.ToLower(strings.TrimSpace(name))
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	var (
+		found Model
+		ok    bool
+	)
+	for _, model := range t.models {
+		match := strings.ToLower(model.Match)
+		if strings.HasPrefix(name, match) && (!ok || len(match) > len(found.Match)) {
+			found, ok = model, true
+		}
+	}
+	return found, ok
+}
+
+// Supports reports whether a model spends reasoning tokens
+func (t *Table) Supports(name string) bool {
+	_, ok := t.Lookup(name)
+	return ok
+}
+
+// Setting returns how a model is sent effort, or nil when the effort is
+// empty or the model does not reason
+func (t *Table) Setting(name string, effort Effort) *Setting {
+	model, ok := t.Lookup(name)
+	if !ok || effort == "" {
+		return nil
+	}
+
+	setting := &Setting{
+		Effort:       effort,
+		Parameter:    model.Parameter,
+		BudgetTokens: DefaultBudgets[effort],
+	}
+	if budget, ok := model.Budgets[effort]; ok {
+		setting.BudgetTokens = budget
+	}
+	if model.Parameter == ParameterEffort {
+		setting.Value = string(effort)
+		if value, ok := model.Efforts[effort]; ok {
+			setting.Value = value
+		}
+	}
+	return setting
+}
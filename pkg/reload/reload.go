@@ -0,0 +1,211 @@
+// Package reload re-reads configuration while the gateway runs. Every section
+// is read and validated before any is applied, so a reload with a mistake in
+// one file changes nothing
+package reload
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/logging"
+)
+
+var logger = logging.Module("reload")
+
+// Section statuses of a report
+const (
+	StatusChanged   = "changed"
+	StatusUnchanged = "unchanged"
+	StatusInvalid   = "invalid"
+	// StatusNotApplied marks valid changes left out because another section
+	// was invalid
+	StatusNotApplied = "not_applied"
+)
+
+// Change is what reloading a section would do
+type Change struct {
+	// Changed names the settings that differ from the running configuration;
+	// empty when none does
+	Changed []string
+	// Restart names changed settings that only take effect after a restart
+	Restart []string
+	// Apply puts the changes into effect; nil when there is nothing to apply
+	Apply func()
+}
+
+// PrepareFunc reads and validates a section without applying it
+type PrepareFunc func(ctx context.Context) (*Change, error)
+
+// SectionReport is the outcome of one section
+type SectionReport struct {
+	Name            string   `json:"name"`
+	Status          string   `json:"status"`
+	Changed         []string `json:"changed,omitempty"`
+	RestartRequired []string `json:"restart_required,omitempty"`
+	Error           string   `json:"error,omitempty"`
+}
+
+// Report is the outcome of a reload
+type Report struct {
+	// Trigger is what started the reload, such as "signal" or "api"
+	Trigger string    `json:"trigger"`
+	Time    time.Time `json:"time"`
+	// Applied is false when an invalid section stopped the reload
+	Applied  bool            `json:"applied"`
+	Sections []SectionReport `json:"sections"`
+}
+
+// section is a named part of the configuration
+type section struct {
+	name    string
+	prepare PrepareFunc
+}
+
+// Reloader reloads its sections in the order they were added
+type Reloader struct {
+	sections []section
+	last     *Report
+	mutex    sync.Mutex
+}
+
+// NewReloader creates a reloader without sections
+func NewReloader() *Reloader {
+	return &Reloader{}
+}
+
+// Add adds a section
+func (r *Reloader) Add(name string, prepare PrepareFunc) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.sections = append(r.sections, section{name: name, prepare: prepare})
+}
+
+// Reload prepares every section and, when all are valid, applies the
+// changed ones. Reloads run one at a time
+func (r *Reloader) Reload(ctx context.Context, trigger string) *Report {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	report := &Report{Trigger: trigger, Time: time.Now(), Applied: true}
+	changes := make([]*Change, len(r.sections))
+	for i, section := range r.sections {
+		result := SectionReport{Name: section.name, Status: StatusUnchanged}
+		change, err := section.prepare(ctx)
+		switch {
+		case err != nil:
+			result.Status = StatusInvalid
+			result.Error = err.Error()
+			report.Applied = false
+		case change != nil && len(change.Changed) > 0:
+			result.Status = StatusChanged
+			result.Changed = change.Changed
+			result.RestartRequired = change.Restart
+			changes[i] = change
+		}
+		report.Sections = append(report.Sections, result)
+	}
+
+	for i, change := range changes {
+		if change == nil {
+			continue
+		}
+		if !report.Applied {
+			report.Sections[i].Status = StatusNotApplied
+			continue
+		}
+		if change.Apply != nil {
+			change.Apply()
+		}
+		logger.Infof("Reloaded %s: %v changed", report.Sections[i].Name, change.Changed)
+		if len(change.Restart) > 0 {
+			logger.Warnf("Changes to %v of %s take effect after a restart", change.Restart, report.Sections[i].Name)
+		}
+	}
+	if !report.Applied {
+		for _, section := range report.Sections {
+			if section.Status == StatusInvalid {
+				logger.Errorf("Reload rejected, %s is invalid: %s", section.Name, section.Error)
+			}
+		}
+	}
+	r.last = report
+	return report
+}
+
+// Last returns the report of the latest reload, nil before the first
+func (r *Reloader) Last() *Report {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.last
+}
+
+// File tracks the content of a configuration file, so a section reloads it
+// only when it changed
+type File struct {
+	path string
+	hash string
+}
+
+// NewFile tracks the file at path, as it is now
+func NewFile(path string) *File {
+	f := &File{path: path}
+	f.hash, _ = f.read()
+	return f
+}
+
+// Path returns the tracked path
+func (f *File) Path() string {
+	return f.path
+}
+
+// Changed reports whether the file differs from when it was last committed,
+// returning the hash to commit once the change is applied
+func (f *File) Changed() (bool, string, error) {
+	hash, err := f.read()
+	if err != nil {
+		return false, "", err
+	}
+	return hash != f.hash, hash, nil
+}
+
+// Commit records hash as the applied content
+func (f *File) Commit(hash string) {
+	f.hash = hash
+}
+
+// read returns the hash of the file's content
+func (f *File) read() (string, error) {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", f.path, err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// FileSection returns a section reloading file with load when it changed and
+// handing the result to apply
+func FileSection[T any](file *File, load func(path string) (T, error), apply func(value T)) PrepareFunc {
+	return func(ctx context.Context) (*Change, error) {
+		changed, hash, err := file.Changed()
+		if err != nil || !changed {
+			return nil, err
+		}
+		value, err := load(file.Path())
+		if err != nil {
+			return nil, err
+		}
+		return &Change{
+			Changed: []string{file.Path()},
+			Apply: func() {
+				apply(value)
+				file.Commit(hash)
+			},
+		}, nil
+	}
+}
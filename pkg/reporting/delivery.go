@@ -0,0 +1,161 @@
+package reporting
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Delivery sends a finished report somewhere
+type Delivery interface {
+	Name() string
+	Deliver(ctx context.Context, report *Report) error
+}
+
+// WebhookDelivery POSTs each report to a URL
+type WebhookDelivery struct {
+	url        string
+	format     Format
+	httpClient *http.Client
+}
+
+// NewWebhookDelivery creates a delivery posting reports rendered in format to url
+func NewWebhookDelivery(url string, format Format) *WebhookDelivery {
+	return &WebhookDelivery{
+		url:        url,
+		format:     format,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Name identifies the delivery in report delivery statuses
+func (d *WebhookDelivery) Name() string {
+	return "webhook"
+}
+
+// Deliver posts the rendered report with its ID and period in headers
+func (d *WebhookDelivery) Deliver(ctx context.Context, report *Report) error {
+	var body bytes.Buffer
+	if err := Render(&body, report, d.format); err != nil {
+		return fmt.Errorf("failed to render report: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", d.format.ContentType())
+	req.Header.Set("User-Agent", "Your-PaL-MoE/1.0")
+	req.Header.Set("X-Report-ID", report.ID)
+	req.Header.Set("X-Report-Period", string(report.Period))
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SMTPDelivery emails each report as HTML with a CSV attachment
+type SMTPDelivery struct {
+	addr string
+	auth smtp.Auth
+	from string
+	to   []string
+}
+
+// NewSMTPDelivery creates a delivery sending mail through the server at addr
+// (host:port). Without a username the server is used unauthenticated
+func NewSMTPDelivery(addr, username, password, from string, to []string) *SMTPDelivery {
+	delivery := &SMTPDelivery{addr: addr, from: from, to: to}
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		delivery.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return delivery
+}
+
+// Name identifies the delivery in report delivery statuses
+func (d *SMTPDelivery) Name() string {
+	return "smtp"
+}
+
+// Deliver sends the report to every recipient
+func (d *SMTPDelivery) Deliver(ctx context.Context, report *Report) error {
+	message, err := d.message(report)
+	if err != nil {
+		return err
+	}
+
+	// net/smtp has no context support, so the send runs until it finishes;
+	// ctx only stops us waiting for it
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(d.addr, d.auth, d.from, d.to, message)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// message builds a multipart email: the HTML report as the body and the CSV
+// export as an attachment
+func (d *SMTPDelivery) message(report *Report) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	fmt.Fprintf(&buf, "From: %s\r\n", d.from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(d.to, ", "))
+	fmt.Fprintf(&buf, "Subject: Your PaL MoE %s report for %s\r\n", report.Period, report.From.Format("2006-01-02"))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", writer.Boundary())
+
+	html, err := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {FormatHTML.ContentType()}})
+	if err != nil {
+		return nil, err
+	}
+	if err := Render(html, report, FormatHTML); err != nil {
+		return nil, fmt.Errorf("failed to render report: %w", err)
+	}
+
+	var csv bytes.Buffer
+	if err := Render(&csv, report, FormatCSV); err != nil {
+		return nil, fmt.Errorf("failed to render report: %w", err)
+	}
+	attachment, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {FormatCSV.ContentType()},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {fmt.Sprintf("attachment; filename=%q", FormatCSV.FileName(report))},
+	})
+	if err != nil {
+		return nil, err
+	}
+	encoded := base64.StdEncoding.EncodeToString(csv.Bytes())
+	for len(encoded) > 76 {
+		fmt.Fprintf(attachment, "%s\r\n", encoded[:76])
+		encoded = encoded[76:]
+	}
+	fmt.Fprintf(attachment, "%s\r\n", encoded)
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
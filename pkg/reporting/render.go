@@ -0,0 +1,224 @@
+package reporting
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Format is a report export format
+type Format string
+
+// Supported export formats
+const (
+	FormatJSON Format = "json"
+	FormatCSV  Format = "csv"
+	FormatHTML Format = "html"
+)
+
+// ParseFormat converts s to a Format; empty means JSON
+func ParseFormat(s string) (Format, error) {
+	switch format := Format(strings.ToLower(strings.TrimSpace(s))); format {
+	case FormatJSON, FormatCSV, FormatHTML:
+		return format, nil
+	case "":
+		return FormatJSON, nil
+	default:
+		return "", fmt.Errorf("unknown report format %q: must be json, csv or html", s)
+	}
+}
+
+// ContentType returns the MIME type of the format
+func (f Format) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatHTML:
+		return "text/html; charset=utf-8"
+	default:
+		return "application/json"
+	}
+}
+
+// FileName returns the download name of report in this format
+func (f Format) FileName(report *Report) string {
+	return fmt.Sprintf("report-%s.%s", report.ID, f)
+}
+
+// Render writes report to w in format
+func Render(w io.Writer, report *Report, format Format) error {
+	switch format {
+	case FormatCSV:
+		return renderCSV(w, report)
+	case FormatHTML:
+		return reportTemplate.Execute(w, report)
+	default:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+}
+
+// renderCSV writes the report as section,item,metric,value rows
+func renderCSV(w io.Writer, report *Report) error {
+	out := csv.NewWriter(w)
+	row := func(section, item, metric string, value interface{}) {
+		var text string
+		switch v := value.(type) {
+		case float64:
+			text = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			text = fmt.Sprint(v)
+		}
+		out.Write([]string{section, item, metric, text})
+	}
+
+	out.Write([]string{"section", "item", "metric", "value"})
+	row("report", report.ID, "period", report.Period)
+	row("report", report.ID, "from", report.From.Format("2006-01-02T15:04:05Z07:00"))
+	row("report", report.ID, "to", report.To.Format("2006-01-02T15:04:05Z07:00"))
+
+	row("traffic", "all", "requests", report.Traffic.Requests)
+	row("traffic", "all", "successful", report.Traffic.Successful)
+	row("traffic", "all", "failed", report.Traffic.Failed)
+	row("traffic", "all", "success_rate", report.Traffic.SuccessRate)
+	row("traffic", "all", "avg_latency_ms", report.Traffic.AvgLatencyMs)
+	row("traffic", "all", "tokens", report.Traffic.Tokens)
+
+	row("cost", "all", "usd", report.Cost.Total)
+	for _, entry := range sortedCosts(report.Cost.ByProvider) {
+		row("cost_by_provider", entry.Name, "usd", entry.Value)
+	}
+	for _, entry := range sortedCosts(report.Cost.ByModel) {
+		row("cost_by_model", entry.Name, "usd", entry.Value)
+	}
+	for _, entry := range sortedCosts(report.Cost.ByTier) {
+		row("cost_by_tier", entry.Name, "usd", entry.Value)
+	}
+
+	row("savings", "all", "baseline_cost", report.Savings.BaselineCost)
+	row("savings", "all", "actual_cost", report.Savings.ActualCost)
+	row("savings", "all", "realized", report.Savings.Realized)
+	row("savings", "all", "estimated_monthly_opportunity", report.Savings.EstimatedMonthlyOpportunity)
+	for _, recommendation := range report.Savings.Recommendations {
+		row("recommendation", string(recommendation.Type), "title", recommendation.Title)
+		if recommendation.EstimatedMonthlySavings > 0 {
+			row("recommendation", string(recommendation.Type), "estimated_monthly_savings", recommendation.EstimatedMonthlySavings)
+		}
+	}
+
+	for _, provider := range report.ProviderHealth {
+		row("provider_health", provider.ProviderID, "requests", provider.TotalRequests)
+		row("provider_health", provider.ProviderID, "success_rate", provider.SuccessRate)
+		row("provider_health", provider.ProviderID, "avg_response_time_ms", provider.AvgResponseTime)
+		row("provider_health", provider.ProviderID, "cost", provider.TotalCost)
+	}
+	for _, cluster := range report.FailureClusters {
+		row("failure_cluster", cluster.ProviderID, cluster.Error, cluster.Count)
+	}
+
+	for _, key := range report.TopKeys {
+		row("top_keys", key.KeyID, "requests", key.Requests)
+		row("top_keys", key.KeyID, "tokens", key.Tokens)
+		row("top_keys", key.KeyID, "cost", key.Cost)
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// namedCost is one entry of a cost breakdown
+type namedCost struct {
+	Name  string
+	Value float64
+}
+
+// sortedCosts orders a cost breakdown from most to least expensive
+func sortedCosts(costs map[string]float64) []namedCost {
+	entries := make([]namedCost, 0, len(costs))
+	for name, value := range costs {
+		entries = append(entries, namedCost{name, value})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Value != entries[j].Value {
+			return entries[i].Value > entries[j].Value
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries
+}
+
+// reportTemplate renders a self-contained HTML report, suitable for email
+// and for printing to PDF from a browser
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"usd":     func(v float64) string { return fmt.Sprintf("$%.4f", v) },
+	"percent": func(v float64) string { return fmt.Sprintf("%.1f%%", v*100) },
+	"sorted":  sortedCosts,
+	"date":    func(v interface{ Format(string) string }) string { return v.Format("2006-01-02 15:04 MST") },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Your PaL MoE {{.Period}} report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; text-align: left; }
+th { background: #f3f3f3; }
+td.num { text-align: right; }
+</style>
+</head>
+<body>
+<h1>Your PaL MoE {{.Period}} report</h1>
+<p>{{date .From}} to {{date .To}}, generated {{date .GeneratedAt}}</p>
+
+<h2>Traffic</h2>
+<table>
+<tr><th>Requests</th><th>Successful</th><th>Failed</th><th>Success rate</th><th>Avg latency</th><th>Tokens</th></tr>
+<tr><td class="num">{{.Traffic.Requests}}</td><td class="num">{{.Traffic.Successful}}</td><td class="num">{{.Traffic.Failed}}</td><td class="num">{{percent .Traffic.SuccessRate}}</td><td class="num">{{printf "%.0f" .Traffic.AvgLatencyMs}} ms</td><td class="num">{{.Traffic.Tokens}}</td></tr>
+</table>
+
+<h2>Cost</h2>
+<p>Total {{usd .Cost.Total}}</p>
+<table>
+<tr><th>Provider</th><th>Cost</th></tr>
+{{range sorted .Cost.ByProvider}}<tr><td>{{.Name}}</td><td class="num">{{usd .Value}}</td></tr>
+{{end}}</table>
+<table>
+<tr><th>Model</th><th>Cost</th></tr>
+{{range sorted .Cost.ByModel}}<tr><td>{{.Name}}</td><td class="num">{{usd .Value}}</td></tr>
+{{end}}</table>
+
+<h2>Savings</h2>
+<table>
+<tr><th>Official tier baseline</th><th>Actual</th><th>Realized</th><th>Monthly opportunity</th></tr>
+<tr><td class="num">{{usd .Savings.BaselineCost}}</td><td class="num">{{usd .Savings.ActualCost}}</td><td class="num">{{usd .Savings.Realized}}</td><td class="num">{{usd .Savings.EstimatedMonthlyOpportunity}}</td></tr>
+</table>
+{{if .Savings.Recommendations}}<table>
+<tr><th>Severity</th><th>Recommendation</th><th>Action</th></tr>
+{{range .Savings.Recommendations}}<tr><td>{{.Severity}}</td><td>{{.Title}}</td><td>{{.Action}}</td></tr>
+{{end}}</table>{{end}}
+
+<h2>Provider health</h2>
+<table>
+<tr><th>Provider</th><th>Requests</th><th>Success rate</th><th>Avg response</th><th>Cost</th></tr>
+{{range .ProviderHealth}}<tr><td>{{.ProviderID}}</td><td class="num">{{.TotalRequests}}</td><td class="num">{{percent .SuccessRate}}</td><td class="num">{{printf "%.0f" .AvgResponseTime}} ms</td><td class="num">{{usd .TotalCost}}</td></tr>
+{{end}}</table>
+{{if .FailureClusters}}<table>
+<tr><th>Provider</th><th>Error</th><th>Count</th><th>Share</th></tr>
+{{range .FailureClusters}}<tr><td>{{.ProviderID}}</td><td>{{.Error}}</td><td class="num">{{.Count}}</td><td class="num">{{percent .Share}}</td></tr>
+{{end}}</table>{{end}}
+
+<h2>Top API keys</h2>
+{{if .TopKeys}}<table>
+<tr><th>Key</th><th>Requests</th><th>Failed</th><th>Tokens</th><th>Cost</th></tr>
+{{range .TopKeys}}<tr><td>{{.KeyID}}</td><td class="num">{{.Requests}}</td><td class="num">{{.Failed}}</td><td class="num">{{.Tokens}}</td><td class="num">{{usd .Cost}}</td></tr>
+{{end}}</table>{{else}}<p>No requests carried an API key.</p>{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,278 @@
+// Package reporting builds periodic traffic, cost and provider health reports
+// from the analytics engine, stores them and delivers them by webhook or email
+package reporting
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/analytics"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/logging"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
+)
+
+var logger = logging.Module("reporting")
+
+// topKeysLimit is the number of API keys listed in a report
+const topKeysLimit = 10
+
+// Period is the span of time a report covers
+type Period string
+
+const (
+	// Daily reports cover a UTC calendar day
+	Daily Period = "daily"
+	// Weekly reports cover a UTC week starting on Monday
+	Weekly Period = "weekly"
+)
+
+// ParsePeriod converts s to a Period
+func ParsePeriod(s string) (Period, error) {
+	switch period := Period(strings.ToLower(strings.TrimSpace(s))); period {
+	case Daily, Weekly:
+		return period, nil
+	default:
+		return "", fmt.Errorf("unknown report period %q: must be daily or weekly", s)
+	}
+}
+
+// ParsePeriods parses a comma-separated list of periods, e.g. "daily,weekly"
+func ParsePeriods(s string) ([]Period, error) {
+	var periods []Period
+	for _, part := range strings.Split(s, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		period, err := ParsePeriod(part)
+		if err != nil {
+			return nil, err
+		}
+		periods = append(periods, period)
+	}
+	return periods, nil
+}
+
+// Duration returns the length of the period
+func (p Period) Duration() time.Duration {
+	if p == Weekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// Start returns the start of the period containing t: midnight UTC for daily
+// reports, Monday midnight UTC for weekly ones
+func (p Period) Start(t time.Time) time.Time {
+	day := t.UTC().Truncate(24 * time.Hour)
+	if p == Weekly {
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	}
+	return day
+}
+
+// TrafficSummary counts the requests in a report
+type TrafficSummary struct {
+	Requests     int     `json:"requests"`
+	Successful   int     `json:"successful"`
+	Failed       int     `json:"failed"`
+	SuccessRate  float64 `json:"success_rate"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	Tokens       int64   `json:"tokens"`
+}
+
+// CostSummary breaks down what the requests in a report cost, in USD
+type CostSummary struct {
+	Total      float64            `json:"total"`
+	ByProvider map[string]float64 `json:"by_provider"`
+	ByModel    map[string]float64 `json:"by_model"`
+	ByTier     map[string]float64 `json:"by_tier"`
+}
+
+// SavingsSummary compares the actual cost with sending everything to the official tier
+type SavingsSummary struct {
+	// BaselineCost prices every token at the average official tier rate; zero
+	// when no official provider has a known price
+	BaselineCost float64 `json:"baseline_cost"`
+	ActualCost   float64 `json:"actual_cost"`
+	Realized     float64 `json:"realized"`
+	// EstimatedMonthlyOpportunity sums the savings of the recommendations below
+	EstimatedMonthlyOpportunity float64                    `json:"estimated_monthly_opportunity"`
+	Recommendations             []analytics.Recommendation `json:"recommendations"`
+}
+
+// KeyUsage is the traffic of one API key, identified by its key ID
+type KeyUsage struct {
+	KeyID    string  `json:"key_id"`
+	Requests int     `json:"requests"`
+	Failed   int     `json:"failed"`
+	Tokens   int64   `json:"tokens"`
+	Cost     float64 `json:"cost"`
+}
+
+// DeliveryStatus records the outcome of sending a report through one delivery
+type DeliveryStatus struct {
+	Delivery  string    `json:"delivery"`
+	Delivered bool      `json:"delivered"`
+	Error     string    `json:"error,omitempty"`
+	At        time.Time `json:"at"`
+}
+
+// Report summarizes traffic, cost, savings, provider health and top API keys
+// over [From, To)
+type Report struct {
+	ID              string                          `json:"id"`
+	Period          Period                          `json:"period"`
+	From            time.Time                       `json:"from"`
+	To              time.Time                       `json:"to"`
+	GeneratedAt     time.Time                       `json:"generated_at"`
+	Traffic         TrafficSummary                  `json:"traffic"`
+	Cost            CostSummary                     `json:"cost"`
+	Savings         SavingsSummary                  `json:"savings"`
+	ProviderHealth  []analytics.ProviderPerformance `json:"provider_health"`
+	FailureClusters []analytics.FailureCluster      `json:"failure_clusters"`
+	TopKeys         []KeyUsage                      `json:"top_keys"`
+	Deliveries      []DeliveryStatus                `json:"deliveries,omitempty"`
+}
+
+// ReportSummary is the listing entry of a stored report
+type ReportSummary struct {
+	ID          string    `json:"id"`
+	Period      Period    `json:"period"`
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Requests    int       `json:"requests"`
+	TotalCost   float64   `json:"total_cost"`
+}
+
+// Summary returns the listing entry of the report
+func (r *Report) Summary() ReportSummary {
+	return ReportSummary{
+		ID:          r.ID,
+		Period:      r.Period,
+		From:        r.From,
+		To:          r.To,
+		GeneratedAt: r.GeneratedAt,
+		Requests:    r.Traffic.Requests,
+		TotalCost:   r.Cost.Total,
+	}
+}
+
+// reportID names a report after its period and start, e.g. daily-20240501T000000Z
+func reportID(period Period, from time.Time) string {
+	return fmt.Sprintf("%s-%s", period, from.UTC().Format("20060102T150405Z"))
+}
+
+// Build aggregates the requests engine recorded in [from, to) into a report
+func Build(engine *analytics.AnalyticsEngine, period Period, from, to time.Time) *Report {
+	records := engine.Records(from, to)
+	insights := engine.Analyze(records, from, to)
+
+	report := &Report{
+		ID:          reportID(period, from),
+		Period:      period,
+		From:        from.UTC(),
+		To:          to.UTC(),
+		GeneratedAt: time.Now().UTC(),
+		Cost: CostSummary{
+			ByProvider: make(map[string]float64),
+			ByModel:    make(map[string]float64),
+			ByTier:     make(map[string]float64),
+		},
+		ProviderHealth:  analytics.Performance(records),
+		FailureClusters: insights.FailureClusters,
+		TopKeys:         topKeys(records),
+	}
+
+	var latency int64
+	for _, record := range records {
+		report.Traffic.Requests++
+		if record.Success {
+			report.Traffic.Successful++
+		} else {
+			report.Traffic.Failed++
+		}
+		report.Traffic.Tokens += int64(record.TokensUsed)
+		latency += record.Duration
+
+		report.Cost.Total += record.Cost
+		report.Cost.ByProvider[record.ProviderID] += record.Cost
+		if record.Model != "" {
+			report.Cost.ByModel[record.Model] += record.Cost
+		}
+		if record.Tier != "" {
+			report.Cost.ByTier[record.Tier] += record.Cost
+		}
+	}
+	if report.Traffic.Requests > 0 {
+		report.Traffic.SuccessRate = float64(report.Traffic.Successful) / float64(report.Traffic.Requests)
+		report.Traffic.AvgLatencyMs = float64(latency) / float64(report.Traffic.Requests)
+	}
+
+	report.Savings = SavingsSummary{
+		ActualCost:      report.Cost.Total,
+		Recommendations: insights.Recommendations,
+	}
+	if rate := officialRate(engine.Providers()); rate > 0 {
+		report.Savings.BaselineCost = float64(report.Traffic.Tokens) * rate
+		report.Savings.Realized = report.Savings.BaselineCost - report.Cost.Total
+	}
+	for _, recommendation := range insights.Recommendations {
+		report.Savings.EstimatedMonthlyOpportunity += recommendation.EstimatedMonthlySavings
+	}
+	return report
+}
+
+// officialRate is the average per-token price of the priced official providers
+func officialRate(providers []analytics.ProviderInfo) float64 {
+	var sum float64
+	var count int
+	for _, provider := range providers {
+		if provider.Tier == string(tier.Official) && provider.CostPerToken > 0 {
+			sum += provider.CostPerToken
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return sum / float64(count)
+}
+
+// topKeys ranks API keys by request count; requests without a key are left out
+func topKeys(records []analytics.RequestMetrics) []KeyUsage {
+	byKey := make(map[string]*KeyUsage)
+	for _, record := range records {
+		if record.KeyID == "" {
+			continue
+		}
+		usage, ok := byKey[record.KeyID]
+		if !ok {
+			usage = &KeyUsage{KeyID: record.KeyID}
+			byKey[record.KeyID] = usage
+		}
+		usage.Requests++
+		if !record.Success {
+			usage.Failed++
+		}
+		usage.Tokens += int64(record.TokensUsed)
+		usage.Cost += record.Cost
+	}
+
+	keys := make([]KeyUsage, 0, len(byKey))
+	for _, usage := range byKey {
+		keys = append(keys, *usage)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Requests != keys[j].Requests {
+			return keys[i].Requests > keys[j].Requests
+		}
+		return keys[i].KeyID < keys[j].KeyID
+	})
+	if len(keys) > topKeysLimit {
+		keys = keys[:topKeysLimit]
+	}
+	return keys
+}
@@ -0,0 +1,109 @@
+package reporting
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/analytics"
+)
+
+// reportDelay lets requests that started before a period boundary finish
+// before the period's report is built
+const reportDelay = time.Minute
+
+// Scheduler builds reports at the end of every daily or weekly period, stores
+// them and hands them to the configured deliveries
+type Scheduler struct {
+	engine     *analytics.AnalyticsEngine
+	store      *Store
+	periods    []Period
+	deliveries []Delivery
+	mutex      sync.RWMutex
+}
+
+// NewScheduler creates a scheduler for periods; with none it only generates
+// reports on demand
+func NewScheduler(engine *analytics.AnalyticsEngine, store *Store, periods ...Period) *Scheduler {
+	return &Scheduler{
+		engine:  engine,
+		store:   store,
+		periods: periods,
+	}
+}
+
+// AddDelivery sends every scheduled report through delivery as well
+func (s *Scheduler) AddDelivery(delivery Delivery) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.deliveries = append(s.deliveries, delivery)
+}
+
+// Store returns where reports are kept
+func (s *Scheduler) Store() *Store {
+	return s.store
+}
+
+// Periods returns the scheduled periods
+func (s *Scheduler) Periods() []Period {
+	return append([]Period(nil), s.periods...)
+}
+
+// Generate builds and stores the report for [from, to). With deliver set it is
+// also sent through every delivery, and the outcome recorded in the report
+func (s *Scheduler) Generate(ctx context.Context, period Period, from, to time.Time, deliver bool) (*Report, error) {
+	report := Build(s.engine, period, from, to)
+	if deliver {
+		s.deliver(ctx, report)
+	}
+	if err := s.store.Save(report); err != nil {
+		return nil, err
+	}
+	logger.Infof("Generated %s report %s: %d requests, $%.4f", period, report.ID, report.Traffic.Requests, report.Cost.Total)
+	return report, nil
+}
+
+// deliver sends report through every delivery; failures are logged and
+// recorded, never fatal
+func (s *Scheduler) deliver(ctx context.Context, report *Report) {
+	s.mutex.RLock()
+	deliveries := append([]Delivery(nil), s.deliveries...)
+	s.mutex.RUnlock()
+
+	for _, delivery := range deliveries {
+		status := DeliveryStatus{Delivery: delivery.Name(), Delivered: true}
+		if err := delivery.Deliver(ctx, report); err != nil {
+			logger.Errorf("Failed to deliver report %s via %s: %v", report.ID, delivery.Name(), err)
+			status.Delivered = false
+			status.Error = err.Error()
+		}
+		status.At = time.Now().UTC()
+		report.Deliveries = append(report.Deliveries, status)
+	}
+}
+
+// Start generates a report at the end of every scheduled period until ctx is done
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, period := range s.periods {
+		go s.run(ctx, period)
+	}
+}
+
+// run waits for each boundary of period and reports on the period that just ended
+func (s *Scheduler) run(ctx context.Context, period Period) {
+	for {
+		end := period.Start(time.Now()).Add(period.Duration())
+		timer := time.NewTimer(time.Until(end.Add(reportDelay)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if _, err := s.Generate(ctx, period, end.Add(-period.Duration()), end, true); err != nil {
+			logger.Errorf("Failed to generate %s report: %v", period, err)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package reporting
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultStoreLimit is the number of reports kept before the oldest are dropped
+const DefaultStoreLimit = 400
+
+// Store keeps generated reports in memory and, with a directory, as JSON files
+// that survive restarts. Other formats are rendered on demand
+type Store struct {
+	dir     string
+	limit   int
+	reports map[string]*Report
+	mutex   sync.RWMutex
+}
+
+// NewStore creates a store, loading the reports already saved in dir. An empty
+// dir keeps reports in memory only
+func NewStore(dir string) (*Store, error) {
+	store := &Store{
+		dir:     dir,
+		limit:   DefaultStoreLimit,
+		reports: make(map[string]*Report),
+	}
+	if dir == "" {
+		return store, nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create report directory: %w", err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read report %s: %w", file, err)
+		}
+		var report Report
+		if err := json.Unmarshal(data, &report); err != nil {
+			logger.Warnf("Skipping unreadable report %s: %v", file, err)
+			continue
+		}
+		store.reports[report.ID] = &report
+	}
+	store.prune()
+	return store, nil
+}
+
+// Save stores report, replacing any report with the same ID
+func (s *Store) Save(report *Report) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.dir != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		// Write to a temporary file first so readers never see a partial report
+		path := s.path(report.ID)
+		if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+		if err := os.Rename(path+".tmp", path); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+	}
+	s.reports[report.ID] = report
+	s.prune()
+	return nil
+}
+
+// Get returns the report with id
+func (s *Store) Get(id string) (*Report, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	report, ok := s.reports[id]
+	return report, ok
+}
+
+// List returns every stored report, newest first
+func (s *Store) List() []ReportSummary {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	summaries := make([]ReportSummary, 0, len(s.reports))
+	for _, report := range s.reports {
+		summaries = append(summaries, report.Summary())
+	}
+	sortSummaries(summaries)
+	return summaries
+}
+
+// prune drops the oldest reports beyond the limit; callers hold the mutex
+func (s *Store) prune() {
+	if len(s.reports) <= s.limit {
+		return
+	}
+	summaries := make([]ReportSummary, 0, len(s.reports))
+	for _, report := range s.reports {
+		summaries = append(summaries, report.Summary())
+	}
+	sortSummaries(summaries)
+	for _, summary := range summaries[s.limit:] {
+		delete(s.reports, summary.ID)
+		if s.dir != "" {
+			if err := os.Remove(s.path(summary.ID)); err != nil && !os.IsNotExist(err) {
+				logger.Warnf("Failed to remove old report %s: %v", summary.ID, err)
+			}
+		}
+	}
+}
+
+// path returns the file of report id, which never leaves the store directory
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, strings.NewReplacer("/", "_", "\\", "_").Replace(id)+".json")
+}
+
+// sortSummaries orders reports by start time, newest first
+func sortSummaries(summaries []ReportSummary) {
+	sort.Slice(summaries, func(i, j int) bool {
+		if !summaries[i].From.Equal(summaries[j].From) {
+			return summaries[i].From.After(summaries[j].From)
+		}
+		return summaries[i].ID < summaries[j].ID
+	})
+}
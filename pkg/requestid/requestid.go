@@ -0,0 +1,77 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Header is the HTTP header carrying the request ID to and from clients and upstreams
+const Header = "X-Request-ID"
+
+// Field is the log field name used for request IDs
+const Field = "request_id"
+
+// maxLength bounds client-supplied request IDs
+const maxLength = 128
+
+// contextKey is the unexported type for the context value
+type contextKey struct{}
+
+// New generates a random request ID
+func New() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "req-unavailable"
+	}
+	return hex.EncodeToString(buf)
+}
+
+// Valid reports whether a client-supplied ID is safe to reuse in logs and headers
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// NewContext returns a copy of ctx carrying id
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID stored in ctx, or "" if there is none
+func FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Inject copies the request ID from req's context onto the outgoing request headers
+func Inject(req *http.Request) {
+	if id := FromContext(req.Context()); id != "" {
+		req.Header.Set(Header, id)
+	}
+}
+
+// Logger returns a log entry tagged with the request ID from ctx
+func Logger(ctx context.Context, logger *logrus.Logger) *logrus.Entry {
+	entry := logrus.NewEntry(logger)
+	if id := FromContext(ctx); id != "" {
+		entry = entry.WithField(Field, id)
+	}
+	return entry
+}
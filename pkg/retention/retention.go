@@ -0,0 +1,92 @@
+// Package retention describes how long stored request data is kept and how
+// it is erased on request, e.g. to honour GDPR erasure requests
+package retention
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidSelector is returned for erasure requests that do not name
+// exactly one key, session or request
+var ErrInvalidSelector = errors.New("erasure needs exactly one of key_id, session_id or request_id")
+
+// SessionMetadataKey is the request metadata field that groups requests into
+// a session
+const SessionMetadataKey = "session_id"
+
+// Policy says how long each kind of stored data is kept; zero or less keeps
+// it forever
+type Policy struct {
+	// Metrics covers provider samples, rate limits, token usage and the cost
+	// optimization log
+	Metrics time.Duration `json:"metrics"`
+	// RequestHistory covers stored prompts and responses
+	RequestHistory time.Duration `json:"request_history"`
+	// Analytics covers raw per-request analytics records
+	Analytics time.Duration `json:"analytics"`
+	// AccessLog covers rotated access log files
+	AccessLog time.Duration `json:"access_log"`
+	// Audit covers the audit log of admin actions
+	Audit time.Duration `json:"audit"`
+}
+
+// Uniform returns a policy keeping everything for retention
+func Uniform(retention time.Duration) Policy {
+	return Policy{Metrics: retention, RequestHistory: retention, Analytics: retention, AccessLog: retention, Audit: retention}
+}
+
+// Selector names the data to erase
+type Selector struct {
+	// KeyID is the hashed API key, as in the access log and request history
+	KeyID     string `json:"key_id,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Validate checks that exactly one field is set
+func (s Selector) Validate() error {
+	set := 0
+	for _, value := range []string{s.KeyID, s.SessionID, s.RequestID} {
+		if value != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return ErrInvalidSelector
+	}
+	return nil
+}
+
+// String describes the selector for logs
+func (s Selector) String() string {
+	switch {
+	case s.KeyID != "":
+		return fmt.Sprintf("key %s", s.KeyID)
+	case s.SessionID != "":
+		return fmt.Sprintf("session %s", s.SessionID)
+	default:
+		return fmt.Sprintf("request %s", s.RequestID)
+	}
+}
+
+// Receipt confirms an erasure
+type Receipt struct {
+	ID          string    `json:"id"`
+	Selector    Selector  `json:"selector"`
+	RequestedBy string    `json:"requested_by"`
+	CompletedAt time.Time `json:"completed_at"`
+	// Erased counts the erased records per store
+	Erased map[string]int `json:"erased"`
+	// Verified reports that a second pass over every store found nothing
+	// left matching the selector
+	Verified bool `json:"verified"`
+}
+
+// PurgeReport is the outcome of a retention purge
+type PurgeReport struct {
+	StartedAt time.Time `json:"started_at"`
+	// Purged counts the expired records removed per store
+	Purged map[string]int64 `json:"purged"`
+}
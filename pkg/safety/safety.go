@@ -0,0 +1,537 @@
+// Package safety classifies responses for unsafe content before they are
+// returned and, by per-tenant thresholds, passes, annotates, redacts the
+// offending segments of or blocks them
+package safety
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/logging"
+	"gopkg.in/yaml.v3"
+)
+
+var logger = logging.Module("safety")
+
+// ErrBlocked is returned for a response whose classification reached a
+// block threshold
+var ErrBlocked = errors.New("response blocked by safety filter")
+
+// Categories of unsafe content
+const (
+	CategoryViolence   = "violence"
+	CategoryWeapons    = "weapons"
+	CategorySelfHarm   = "self_harm"
+	CategoryHate       = "hate"
+	CategoryHarassment = "harassment"
+	CategorySexual     = "sexual"
+)
+
+// Categories lists the built-in categories
+var Categories = []string{CategoryViolence, CategoryWeapons, CategorySelfHarm, CategoryHate, CategoryHarassment, CategorySexual}
+
+// Action is what happens to a response, in increasing severity
+type Action string
+
+const (
+	// ActionPass returns the response unchanged
+	ActionPass Action = "pass"
+	// ActionAnnotate returns it with the classification in its metadata
+	ActionAnnotate Action = "annotate"
+	// ActionRedact replaces the offending segments
+	ActionRedact Action = "redact"
+	// ActionBlock withholds the response
+	ActionBlock Action = "block"
+)
+
+// severity orders actions
+func (a Action) severity() int {
+	switch a {
+	case ActionAnnotate:
+		return 1
+	case ActionRedact:
+		return 2
+	case ActionBlock:
+		return 3
+	}
+	return 0
+}
+
+// Thresholds are the scores from which each action applies; zero turns an
+// action off
+type Thresholds struct {
+	Annotate float64 `yaml:"annotate,omitempty" json:"annotate,omitempty"`
+	Redact   float64 `yaml:"redact,omitempty" json:"redact,omitempty"`
+	Block    float64 `yaml:"block,omitempty" json:"block,omitempty"`
+}
+
+// DefaultThresholds apply when a policy sets none
+var DefaultThresholds = Thresholds{Annotate: 0.4, Redact: 0.7, Block: 0.95}
+
+// IsZero reports whether no action is configured
+func (t Thresholds) IsZero() bool {
+	return t == Thresholds{}
+}
+
+// Action returns the most severe action whose threshold score reaches
+func (t Thresholds) Action(score float64) Action {
+	switch {
+	case t.Block > 0 && score >= t.Block:
+		return ActionBlock
+	case t.Redact > 0 && score >= t.Redact:
+		return ActionRedact
+	case t.Annotate > 0 && score >= t.Annotate:
+		return ActionAnnotate
+	}
+	return ActionPass
+}
+
+// validate checks that each threshold is between 0 and 1
+func (t Thresholds) validate() error {
+	for _, value := range []float64{t.Annotate, t.Redact, t.Block} {
+		if value < 0 || value > 1 {
+			return fmt.Errorf("thresholds must be between 0 and 1")
+		}
+	}
+	return nil
+}
+
+// Rules are a tenant's thresholds; Categories overrides them per category,
+// where unset thresholds inherit from the rules
+type Rules struct {
+	Thresholds `yaml:",inline"`
+	Categories map[string]Thresholds `yaml:"categories,omitempty" json:"categories,omitempty"`
+}
+
+// For returns the thresholds of a category
+func (r Rules) For(category string) Thresholds {
+	thresholds := r.Thresholds
+	override, ok := r.Categories[category]
+	if !ok {
+		return thresholds
+	}
+	if override.Annotate != 0 {
+		thresholds.Annotate = override.Annotate
+	}
+	if override.Redact != 0 {
+		thresholds.Redact = override.Redact
+	}
+	if override.Block != 0 {
+		thresholds.Block = override.Block
+	}
+	return thresholds
+}
+
+// validate checks the thresholds and that overridden categories are known
+func (r Rules) validate(categories map[string]bool) error {
+	if err := r.Thresholds.validate(); err != nil {
+		return err
+	}
+	for category, thresholds := range r.Categories {
+		if !categories[category] {
+			return fmt.Errorf("unknown category %q", category)
+		}
+		if err := thresholds.validate(); err != nil {
+			return fmt.Errorf("category %s: %w", category, err)
+		}
+	}
+	return nil
+}
+
+// Pattern is a heuristic; a segment matching it scores at least Score in
+// Category
+type Pattern struct {
+	Name     string  `yaml:"name" json:"name"`
+	Category string  `yaml:"category" json:"category"`
+	Pattern  string  `yaml:"pattern" json:"pattern"`
+	Score    float64 `yaml:"score" json:"score"`
+
+	regexp *regexp.Regexp
+}
+
+// DefaultPatterns are the heuristics every filter starts with. They catch
+// blatant cases only; a classifier model covers the rest
+var DefaultPatterns = []Pattern{
+	{Name: "explosives_instructions", Category: CategoryWeapons, Score: 0.9,
+		Pattern: `(?i)\b(build|make|assemble|construct)\b.{0,30}\b(bomb|explosives?|detonator|napalm|molotov)\b`},
+	{Name: "untraceable_firearm", Category: CategoryWeapons, Score: 0.7,
+		Pattern: `(?i)\b(untraceable|ghost)\s+guns?\b|\b3d[- ]print(ed)?\s+(gun|firearm)s?\b`},
+	{Name: "violent_threat", Category: CategoryViolence, Score: 0.7,
+		Pattern: `(?i)\b(i will|i'm going to|we will|going to)\s+(kill|murder|stab|shoot|torture)\b`},
+	{Name: "self_harm_method", Category: CategorySelfHarm, Score: 0.9,
+		Pattern: `(?i)\b(suicide|self[- ]harm)\s+(methods?|instructions|guide)\b|\bhow\s+to\s+(kill|hurt|harm)\s+(yourself|myself)\b`},
+	{Name: "self_harm_encouragement", Category: CategorySelfHarm, Score: 0.95,
+		Pattern: `(?i)\b(you should|just)\s+(kill|hurt|harm)\s+yourself\b`},
+	{Name: "dehumanization", Category: CategoryHate, Score: 0.8,
+		Pattern: `(?i)\b(are|is)\s+(subhuman|vermin|animals|parasites)\b.{0,40}\b(should|must|deserve)\b`},
+	{Name: "extermination", Category: CategoryHate, Score: 0.9,
+		Pattern: `(?i)\b(should|must)\s+be\s+(exterminated|eradicated|wiped\s+out)\b|\b(genocide|ethnic\s+cleansing)\s+(is|was)\s+(good|justified|necessary)\b`},
+	{Name: "insult", Category: CategoryHarassment, Score: 0.5,
+		Pattern: `(?i)\byou(\s+are|'re)\s+(a\s+|an\s+)?(worthless|pathetic|idiot|moron|loser)\b`},
+	{Name: "death_wish", Category: CategoryHarassment, Score: 0.9,
+		Pattern: `(?i)\b(go\s+die|kys)\b`},
+	{Name: "explicit_sexual", Category: CategorySexual, Score: 0.6,
+		Pattern: `(?i)\b(explicit|graphic)\s+sex(ual)?\s+(scene|content|act)s?\b|\bpornograph(y|ic)\b`},
+	{Name: "sexual_minors", Category: CategorySexual, Score: 1,
+		Pattern: `(?i)\b(minors?|child|children|underage|kids?)\b.{0,40}\b(sexual|sexually|nude|explicit)\b`},
+}
+
+// compile checks the pattern and compiles its expression
+func (p *Pattern) compile(categories map[string]bool) error {
+	if p.Name == "" {
+		return fmt.Errorf("pattern %q: name is required", p.Pattern)
+	}
+	if !categories[p.Category] {
+		return fmt.Errorf("pattern %s: unknown category %q", p.Name, p.Category)
+	}
+	if p.Score <= 0 || p.Score > 1 {
+		return fmt.Errorf("pattern %s: score must be above 0 and at most 1", p.Name)
+	}
+	compiled, err := regexp.Compile(p.Pattern)
+	if err != nil {
+		return fmt.Errorf("pattern %s: %w", p.Name, err)
+	}
+	p.regexp = compiled
+	return nil
+}
+
+// ClassifierConfig names an OpenAI-compatible model that scores responses
+type ClassifierConfig struct {
+	BaseURL string        `yaml:"base_url" json:"base_url"`
+	Model   string        `yaml:"model" json:"model"`
+	APIKey  string        `yaml:"api_key,omitempty" json:"-"`
+	Timeout time.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+// Policy configures the filter. Tenants replace the default rules for
+// their requests; Categories adds categories for patterns and classifiers.
+// Escalation, when set, retries blocked responses on an official provider
+type Policy struct {
+	Default    Rules             `yaml:"default" json:"default"`
+	Tenants    map[string]Rules  `yaml:"tenants,omitempty" json:"tenants,omitempty"`
+	Categories []string          `yaml:"categories,omitempty" json:"categories,omitempty"`
+	Patterns   []Pattern         `yaml:"patterns,omitempty" json:"patterns,omitempty"`
+	Classifier *ClassifierConfig `yaml:"classifier,omitempty" json:"classifier,omitempty"`
+	Escalation *EscalationPolicy `yaml:"escalation,omitempty" json:"escalation,omitempty"`
+}
+
+// LoadPolicy reads a policy from a YAML file
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read safety policy: %w", err)
+	}
+	var policy Policy
+	if err := yaml.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse safety policy %s: %w", path, err)
+	}
+	return &policy, nil
+}
+
+// Scores are classification scores from 0 to 1 by category
+type Scores map[string]float64
+
+// merge raises each score of s to the one in other
+func (s Scores) merge(other Scores) {
+	for category, score := range other {
+		s[category] = max(s[category], score)
+	}
+}
+
+// Classifier scores segments of a response, returning one Scores per segment
+type Classifier interface {
+	Classify(ctx context.Context, segments []string) ([]Scores, error)
+}
+
+// TextGenerator answers a single prompt, like assistant.OpenAIClient
+type TextGenerator interface {
+	GenerateText(ctx context.Context, prompt string) (string, error)
+}
+
+// classifierPrompt asks the model for one object of scores per segment
+const classifierPrompt = `You are a content safety classifier. For each numbered segment of an AI assistant's response below, rate from 0 to 1 how much it contains each of these categories of unsafe content: %s.
+Answer with a JSON array holding one object per segment, in order, mapping each category to its score, and nothing else.
+
+%s`
+
+// maxClassifiedLength caps how much of a response the classifier sees
+const maxClassifiedLength = 16000
+
+// ModelClassifier asks a chat model to score segments
+type ModelClassifier struct {
+	generator  TextGenerator
+	categories []string
+}
+
+// NewModelClassifier creates a classifier asking generator about categories
+func NewModelClassifier(generator TextGenerator, categories []string) *ModelClassifier {
+	return &ModelClassifier{generator: generator, categories: categories}
+}
+
+// Classify implements Classifier
+func (c *ModelClassifier) Classify(ctx context.Context, segments []string) ([]Scores, error) {
+	var numbered strings.Builder
+	for i, segment := range segments {
+		if numbered.Len()+len(segment) > maxClassifiedLength {
+			return nil, fmt.Errorf("response too long to classify")
+		}
+		fmt.Fprintf(&numbered, "[%d] %s\n", i+1, segment)
+	}
+	reply, err := c.generator.GenerateText(ctx, fmt.Sprintf(classifierPrompt, strings.Join(c.categories, ", "), numbered.String()))
+	if err != nil {
+		return nil, err
+	}
+	start, end := strings.Index(reply, "["), strings.LastIndex(reply, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("classifier reply is not a JSON array: %q", reply)
+	}
+	var scores []Scores
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &scores); err != nil {
+		return nil, fmt.Errorf("classifier reply is not a JSON array: %w", err)
+	}
+	if len(scores) != len(segments) {
+		return nil, fmt.Errorf("classifier scored %d segments, want %d", len(scores), len(segments))
+	}
+	known := make(map[string]bool, len(c.categories))
+	for _, category := range c.categories {
+		known[category] = true
+	}
+	for _, segment := range scores {
+		for category, score := range segment {
+			if !known[category] || score < 0 || score > 1 {
+				delete(segment, category)
+			}
+		}
+	}
+	return scores, nil
+}
+
+// Flag is a category that led to an action on a segment
+type Flag struct {
+	Category string  `json:"category"`
+	Score    float64 `json:"score"`
+	Action   Action  `json:"action"`
+	// Segment is the index of the sentence or paragraph, from 0
+	Segment int `json:"segment"`
+}
+
+// Assessment is the classification of a response. Scores holds the highest
+// score of each category over the segments, omitting zeros
+type Assessment struct {
+	Action   Action `json:"action"`
+	Scores   Scores `json:"scores,omitempty"`
+	Flags    []Flag `json:"flags,omitempty"`
+	Redacted int    `json:"redacted,omitempty"`
+}
+
+// Filter classifies responses and applies a policy
+type Filter struct {
+	rules      Rules
+	tenants    map[string]Rules
+	categories []string
+	patterns   []Pattern
+	classifier Classifier
+}
+
+// NewFilter validates policy and compiles its patterns after the default
+// ones. classifier may be nil to use the patterns alone
+func NewFilter(policy Policy, classifier Classifier) (*Filter, error) {
+	f := &Filter{
+		rules:      policy.Default,
+		tenants:    policy.Tenants,
+		categories: append(append([]string(nil), Categories...), policy.Categories...),
+		classifier: classifier,
+	}
+	if f.rules.Thresholds.IsZero() {
+		f.rules.Thresholds = DefaultThresholds
+	}
+	known := make(map[string]bool, len(f.categories))
+	for _, category := range f.categories {
+		if known[category] {
+			return nil, fmt.Errorf("category %s is listed more than once", category)
+		}
+		known[category] = true
+	}
+	if err := f.rules.validate(known); err != nil {
+		return nil, fmt.Errorf("default: %w", err)
+	}
+	for id, rules := range f.tenants {
+		if err := rules.validate(known); err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", id, err)
+		}
+	}
+
+	names := make(map[string]bool)
+	for _, pattern := range append(append([]Pattern(nil), DefaultPatterns...), policy.Patterns...) {
+		if err := pattern.compile(known); err != nil {
+			return nil, err
+		}
+		if names[pattern.Name] {
+			return nil, fmt.Errorf("pattern %s: name is used more than once", pattern.Name)
+		}
+		names[pattern.Name] = true
+		f.patterns = append(f.patterns, pattern)
+	}
+	return f, nil
+}
+
+// Categories returns the categories the filter classifies
+func (f *Filter) Categories() []string {
+	return f.categories
+}
+
+// RulesFor returns the rules for a tenant's responses
+func (f *Filter) RulesFor(tenant string) Rules {
+	if rules, ok := f.tenants[tenant]; ok {
+		return rules
+	}
+	return f.rules
+}
+
+// Apply classifies content for the tenant and returns it as it may be
+// returned, with the assessment. A blocked response returns an error
+// wrapping ErrBlocked alongside the assessment. When the classifier fails
+// the patterns decide alone
+func (f *Filter) Apply(ctx context.Context, tenant, content string) (string, *Assessment, error) {
+	assessment := &Assessment{Action: ActionPass, Scores: Scores{}}
+	spans := segment(content)
+	if len(spans) == 0 {
+		return content, assessment, nil
+	}
+
+	segments := make([]string, len(spans))
+	for i, span := range spans {
+		segments[i] = content[span.start:span.end]
+	}
+	scores := f.score(segments)
+	if f.classifier != nil {
+		classified, err := f.classifier.Classify(ctx, segments)
+		if err != nil {
+			logger.Warnf("Safety classifier failed, using patterns only: %v", err)
+		} else {
+			for i := range scores {
+				scores[i].merge(classified[i])
+			}
+		}
+	}
+
+	rules := f.RulesFor(tenant)
+	redact := make([]string, len(spans))
+	for i, segmentScores := range scores {
+		categories := make([]string, 0, len(segmentScores))
+		for category := range segmentScores {
+			categories = append(categories, category)
+		}
+		sort.Strings(categories)
+		for _, category := range categories {
+			score := segmentScores[category]
+			if score <= 0 {
+				continue
+			}
+			assessment.Scores[category] = max(assessment.Scores[category], score)
+			action := rules.For(category).Action(score)
+			if action == ActionPass {
+				continue
+			}
+			assessment.Flags = append(assessment.Flags, Flag{Category: category, Score: score, Action: action, Segment: i})
+			if action.severity() > assessment.Action.severity() {
+				assessment.Action = action
+			}
+			if action == ActionRedact && redact[i] == "" {
+				redact[i] = category
+			}
+		}
+	}
+
+	switch assessment.Action {
+	case ActionBlock:
+		return content, assessment, fmt.Errorf("%w: %s", ErrBlocked, assessment.Summary())
+	case ActionRedact:
+		var redacted strings.Builder
+		last := 0
+		for i, span := range spans {
+			if redact[i] == "" {
+				continue
+			}
+			redacted.WriteString(content[last:span.start])
+			fmt.Fprintf(&redacted, "[redacted: %s]", redact[i])
+			last = span.end
+			assessment.Redacted++
+		}
+		redacted.WriteString(content[last:])
+		return redacted.String(), assessment, nil
+	}
+	return content, assessment, nil
+}
+
+// score applies the patterns to each segment
+func (f *Filter) score(segments []string) []Scores {
+	scores := make([]Scores, len(segments))
+	for i, text := range segments {
+		scores[i] = Scores{}
+		for _, pattern := range f.patterns {
+			if pattern.regexp.MatchString(text) {
+				scores[i][pattern.Category] = max(scores[i][pattern.Category], pattern.Score)
+			}
+		}
+	}
+	return scores
+}
+
+// Summary describes the flags in one line, for logs and errors
+func (a *Assessment) Summary() string {
+	if len(a.Flags) == 0 {
+		return string(a.Action)
+	}
+	parts := make([]string, len(a.Flags))
+	for i, flag := range a.Flags {
+		parts[i] = fmt.Sprintf("%s %.2f (%s)", flag.Category, flag.Score, flag.Action)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// span is a segment's byte range in the response
+type span struct{ start, end int }
+
+// sentenceEnd matches the end of a sentence or a paragraph break
+var sentenceEnd = regexp.MustCompile(`[.!?]+["')\]]*\s+|\n\s*`)
+
+// segment splits content into sentences and lines, omitting surrounding
+// whitespace so redaction keeps the layout
+func segment(content string) []span {
+	var spans []span
+	add := func(start, end int) {
+		for start < end && isSpace(content[start]) {
+			start++
+		}
+		for end > start && isSpace(content[end-1]) {
+			end--
+		}
+		if start < end {
+			spans = append(spans, span{start, end})
+		}
+	}
+	last := 0
+	for _, match := range sentenceEnd.FindAllStringIndex(content, -1) {
+		end := match[1]
+		// Keep the punctuation with its sentence
+		for end > match[0] && isSpace(content[end-1]) {
+			end--
+		}
+		add(last, end)
+		last = match[1]
+	}
+	add(last, len(content))
+	return spans
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
@@ -0,0 +1,298 @@
+// Package sampling translates the OpenAI sampling parameters of a request to
+// the names and ranges of the API a provider speaks, and reports the ones
+// that API has no equivalent for
+package sampling
+
+import (
+	"math"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/validation"
+)
+
+// Limits of the sampling parameters as OpenAI accepts them, which requests
+// are validated against
+const (
+	MaxTemperature   = 2.0
+	MaxPenalty       = 2.0
+	MaxLogitBias     = 100.0
+	MaxStopSequences = 4
+)
+
+// OpenAI names of the sampling parameters
+const (
+	Temperature      = "temperature"
+	TopP             = "top_p"
+	FrequencyPenalty = "frequency_penalty"
+	PresencePenalty  = "presence_penalty"
+	Stop             = "stop"
+	LogitBias        = "logit_bias"
+)
+
+// Parameters are the sampling parameters of a request; nil and empty ones
+// are left to the provider's defaults
+type Parameters struct {
+	Temperature      *float64
+	TopP             *float64
+	FrequencyPenalty *float64
+	PresencePenalty  *float64
+	Stop             []string
+	LogitBias        map[string]float64
+}
+
+// Validate checks the parameters against OpenAI's ranges and returns a
+// *validation.ValidationError naming each invalid one, or nil
+func (p Parameters) Validate() error {
+	ve := &validation.ValidationError{}
+	if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > MaxTemperature) {
+		ve.Addf(Temperature, "must be between 0 and %g", MaxTemperature)
+	}
+	if p.TopP != nil && (*p.TopP < 0 || *p.TopP > 1) {
+		ve.Add(TopP, "must be between 0 and 1")
+	}
+	if p.FrequencyPenalty != nil && math.Abs(*p.FrequencyPenalty) > MaxPenalty {
+		ve.Addf(FrequencyPenalty, "must be between -%g and %g", MaxPenalty, MaxPenalty)
+	}
+	if p.PresencePenalty != nil && math.Abs(*p.PresencePenalty) > MaxPenalty {
+		ve.Addf(PresencePenalty, "must be between -%g and %g", MaxPenalty, MaxPenalty)
+	}
+	if len(p.Stop) > MaxStopSequences {
+		ve.Addf(Stop, "must have at most %d sequences", MaxStopSequences)
+	} else if slices.Contains(p.Stop, "") {
+		ve.Add(Stop, "must not contain empty sequences")
+	}
+	tokens := make([]string, 0, len(p.LogitBias))
+	for token := range p.LogitBias {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+	for _, token := range tokens {
+		if _, err := strconv.ParseUint(token, 10, 32); err != nil {
+			ve.Addf(LogitBias, "key %q is not a token ID", token)
+		} else if math.Abs(p.LogitBias[token]) > MaxLogitBias {
+			ve.Addf(LogitBias, "bias of token %s must be between -%g and %g", token, MaxLogitBias, MaxLogitBias)
+		}
+	}
+	return ve.ErrOrNil()
+}
+
+// IsZero reports whether no parameter is set
+func (p Parameters) IsZero() bool {
+	return p.Temperature == nil && p.TopP == nil && p.FrequencyPenalty == nil &&
+		p.PresencePenalty == nil && len(p.Stop) == 0 && len(p.LogitBias) == 0
+}
+
+// API is the request format a provider speaks
+type API string
+
+// APIs sampling parameters are translated to
+const (
+	// OpenAI also covers the many OpenAI compatible APIs
+	OpenAI    API = "openai"
+	Anthropic API = "anthropic"
+	// Gemini takes the parameters in generationConfig
+	Gemini API = "gemini"
+	// Ollama takes the parameters in options
+	Ollama API = "ollama"
+	Cohere API = "cohere"
+)
+
+// apiHosts maps the hosts of vendor APIs onto the API they speak
+var apiHosts = map[string]API{
+	"api.anthropic.com":                 Anthropic,
+	"generativelanguage.googleapis.com": Gemini,
+	"api.cohere.ai":                     Cohere,
+	"api.cohere.com":                    Cohere,
+}
+
+// ollamaPort is the port Ollama listens on unless told otherwise
+const ollamaPort = "11434"
+
+// DetectAPI returns the API a provider at endpoint speaks, by the vendor's
+// host or Ollama's port. Anything else is taken to be OpenAI compatible, as
+// gateways, aggregators and most local servers are whatever models they serve
+func DetectAPI(endpoint string) API {
+	parsed, err := url.Parse(strings.TrimSpace(endpoint))
+	if err != nil {
+		return OpenAI
+	}
+	host := strings.ToLower(parsed.Hostname())
+	if api, ok := apiHosts[host]; ok {
+		return api
+	}
+	if parsed.Port() == ollamaPort || strings.Contains(host, "ollama") {
+		return Ollama
+	}
+	return OpenAI
+}
+
+// Target is the API a request is sent to, and whether its model reasons
+type Target struct {
+	API API
+	// Reasoning is set when the model reasons on the request, which fixes
+	// some of its sampling
+	Reasoning bool
+	// Choices is how many completions one call generates, sent to APIs that
+	// take a completion count when above 1
+	Choices int
+}
+
+// limit is how an API takes one parameter: its name there, and the range it
+// accepts, which values are clamped into. An empty name means unsupported
+type limit struct {
+	name     string
+	min, max float64
+}
+
+// dialect describes the sampling parameters of an API
+type dialect struct {
+	temperature, topP, frequencyPenalty, presencePenalty limit
+	stop, logitBias                                      string
+	// choices names the completion count of APIs that generate several
+	// completions in one call
+	choices string
+	// fixedWhenReasoning lists the parameters the API ignores or rejects
+	// while its models reason
+	fixedWhenReasoning []string
+}
+
+// dialects are the sampling parameters of each API
+var dialects = map[API]dialect{
+	OpenAI: {
+		temperature:      limit{"temperature", 0, 2},
+		topP:             limit{"top_p", 0, 1},
+		frequencyPenalty: limit{"frequency_penalty", -2, 2},
+		presencePenalty:  limit{"presence_penalty", -2, 2},
+		stop:             "stop",
+		logitBias:        "logit_bias",
+		choices:          "n",
+		// The o-series and GPT-5 take none of these
+		fixedWhenReasoning: []string{Temperature, TopP, FrequencyPenalty, PresencePenalty, LogitBias},
+	},
+	Anthropic: {
+		temperature: limit{"temperature", 0, 1},
+		topP:        limit{"top_p", 0, 1},
+		stop:        "stop_sequences",
+		// Extended thinking requires the default temperature
+		fixedWhenReasoning: []string{Temperature, TopP},
+	},
+	Gemini: {
+		temperature:      limit{"temperature", 0, 2},
+		topP:             limit{"topP", 0, 1},
+		frequencyPenalty: limit{"frequencyPenalty", -2, 2},
+		presencePenalty:  limit{"presencePenalty", -2, 2},
+		stop:             "stopSequences",
+		choices:          "candidateCount",
+	},
+	Ollama: {
+		temperature:      limit{"temperature", 0, 2},
+		topP:             limit{"top_p", 0, 1},
+		frequencyPenalty: limit{"frequency_penalty", -2, 2},
+		presencePenalty:  limit{"presence_penalty", -2, 2},
+		stop:             "stop",
+	},
+	Cohere: {
+		temperature:      limit{"temperature", 0, 1},
+		topP:             limit{"p", 0.01, 0.99},
+		frequencyPenalty: limit{"frequency_penalty", 0, 1},
+		presencePenalty:  limit{"presence_penalty", 0, 1},
+		stop:             "stop_sequences",
+	},
+}
+
+// MultipleChoices reports whether api generates several completions in one
+// call; the others need a call per completion
+func MultipleChoices(api API) bool {
+	return dialects[api].choices != ""
+}
+
+// Adjustment is a parameter sent other than requested
+type Adjustment struct {
+	Parameter string  `json:"parameter"`
+	Requested float64 `json:"requested"`
+	Sent      float64 `json:"sent"`
+}
+
+// Translation is how a request's sampling parameters are sent to one API
+type Translation struct {
+	API API `json:"api"`
+	// Parameters are the API's names of the parameters sent with their values
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	// Adjusted lists values clamped into the range the API accepts
+	Adjusted []Adjustment `json:"adjusted,omitempty"`
+	// Unsupported lists the OpenAI names of parameters the API or model has
+	// no equivalent for, which are dropped
+	Unsupported []string `json:"unsupported,omitempty"`
+}
+
+// IsZero reports whether nothing was requested
+func (t Translation) IsZero() bool {
+	return len(t.Parameters) == 0 && len(t.Unsupported) == 0
+}
+
+// Translate maps parameters to target's API. Values outside the range the
+// API accepts are clamped into it, and parameters it lacks are dropped; both
+// are reported. The target's completion count goes to APIs that take one
+func Translate(parameters Parameters, target Target) Translation {
+	d, ok := dialects[target.API]
+	if !ok {
+		d = dialects[OpenAI]
+	}
+	translation := Translation{API: target.API, Parameters: make(map[string]interface{})}
+	fixed := make(map[string]bool)
+	if target.Reasoning {
+		for _, name := range d.fixedWhenReasoning {
+			fixed[name] = true
+		}
+	}
+
+	number := func(name string, value *float64, l limit) {
+		if value == nil {
+			return
+		}
+		if l.name == "" || fixed[name] {
+			translation.Unsupported = append(translation.Unsupported, name)
+			return
+		}
+		sent := math.Max(l.min, math.Min(*value, l.max))
+		if sent != *value {
+			translation.Adjusted = append(translation.Adjusted, Adjustment{Parameter: name, Requested: *value, Sent: sent})
+		}
+		translation.Parameters[l.name] = sent
+	}
+	number(Temperature, parameters.Temperature, d.temperature)
+	number(TopP, parameters.TopP, d.topP)
+	number(FrequencyPenalty, parameters.FrequencyPenalty, d.frequencyPenalty)
+	number(PresencePenalty, parameters.PresencePenalty, d.presencePenalty)
+
+	// Every API takes at least as many stop sequences as requests may have
+	if len(parameters.Stop) > 0 {
+		translation.Parameters[d.stop] = append([]string(nil), parameters.Stop...)
+	}
+
+	if len(parameters.LogitBias) > 0 {
+		if d.logitBias == "" || fixed[LogitBias] {
+			translation.Unsupported = append(translation.Unsupported, LogitBias)
+		} else {
+			bias := make(map[string]float64, len(parameters.LogitBias))
+			for token, value := range parameters.LogitBias {
+				bias[token] = value
+			}
+			translation.Parameters[d.logitBias] = bias
+		}
+	}
+
+	if target.Choices > 1 && d.choices != "" {
+		translation.Parameters[d.choices] = target.Choices
+	}
+
+	sort.Strings(translation.Unsupported)
+	if len(translation.Parameters) == 0 {
+		translation.Parameters = nil
+	}
+	return translation
+}
@@ -0,0 +1,190 @@
+// Package scrub masks secrets such as API keys and credentials in text
+// before it is logged, attached to metrics or persisted
+package scrub
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// secretGroup names the part of a pattern's match that is masked; patterns
+// without it are masked whole
+const secretGroup = "secret"
+
+// Pattern is a named secret pattern
+type Pattern struct {
+	Name string `yaml:"name" json:"name"`
+	// Regex matches the secret. A group named "secret" limits the mask to
+	// that group, keeping context such as the name of a key
+	Regex string `yaml:"regex" json:"regex"`
+}
+
+// DefaultPatterns covers common API key formats, bearer tokens, private keys
+// and credential assignments
+var DefaultPatterns = []Pattern{
+	{Name: "private_key", Regex: `-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`},
+	{Name: "openai_key", Regex: `\bsk-(?:proj-|ant-)?[A-Za-z0-9_-]{20,}`},
+	{Name: "aws_access_key", Regex: `\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`},
+	{Name: "github_token", Regex: `\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})`},
+	{Name: "google_api_key", Regex: `\bAIza[0-9A-Za-z_-]{35}`},
+	{Name: "slack_token", Regex: `\bxox[abposr]-[A-Za-z0-9-]{10,}`},
+	{Name: "jwt", Regex: `\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`},
+	{Name: "bearer_token", Regex: `(?i)\bbearer\s+(?P<secret>[A-Za-z0-9._~+/-]{20,}=*)`},
+	{Name: "credential", Regex: `(?i)\b(?:api[_-]?key|secret|password|passwd|access[_-]?token|auth[_-]?token)\b["']?\s*[:=]\s*["']?(?P<secret>[^\s"',;]{8,})`},
+}
+
+// compiledPattern is a Pattern ready for matching
+type compiledPattern struct {
+	name   string
+	regex  *regexp.Regexp
+	secret int
+}
+
+// Scrubber masks secrets and counts how many it masked per pattern. It is
+// safe for concurrent use
+type Scrubber struct {
+	patterns []compiledPattern
+	counts   map[string]int64
+	mutex    sync.Mutex
+}
+
+// New creates a scrubber for DefaultPatterns followed by extra
+func New(extra []Pattern) (*Scrubber, error) {
+	s := &Scrubber{counts: make(map[string]int64)}
+	for _, pattern := range append(append([]Pattern(nil), DefaultPatterns...), extra...) {
+		if pattern.Name == "" {
+			return nil, fmt.Errorf("secret pattern %q needs a name", pattern.Regex)
+		}
+		regex, err := regexp.Compile(pattern.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("secret pattern %s: %w", pattern.Name, err)
+		}
+		s.patterns = append(s.patterns, compiledPattern{
+			name:   pattern.Name,
+			regex:  regex,
+			secret: regex.SubexpIndex(secretGroup),
+		})
+	}
+	return s, nil
+}
+
+// NewDefault creates a scrubber for DefaultPatterns
+func NewDefault() *Scrubber {
+	s, err := New(nil)
+	if err != nil {
+		panic(err) // DefaultPatterns always compile
+	}
+	return s
+}
+
+// LoadPatterns reads extra patterns from a YAML file with a top-level
+// "patterns" list
+func LoadPatterns(path string) ([]Pattern, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret patterns: %w", err)
+	}
+
+	var file struct {
+		Patterns []Pattern `yaml:"patterns"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse secret patterns %s: %w", path, err)
+	}
+	return file.Patterns, nil
+}
+
+// Scrub returns text with every secret replaced by [REDACTED:<pattern>]
+func (s *Scrubber) Scrub(text string) string {
+	if s == nil || text == "" {
+		return text
+	}
+	var masked map[string]int64
+	for _, pattern := range s.patterns {
+		text = pattern.regex.ReplaceAllStringFunc(text, func(match string) string {
+			if masked == nil {
+				masked = make(map[string]int64)
+			}
+			masked[pattern.name]++
+			mask := "[REDACTED:" + pattern.name + "]"
+			if pattern.secret < 0 {
+				return mask
+			}
+			// Keep the text around the secret group
+			groups := pattern.regex.FindStringSubmatchIndex(match)
+			start, end := groups[2*pattern.secret], groups[2*pattern.secret+1]
+			if start < 0 {
+				return mask
+			}
+			return match[:start] + mask + match[end:]
+		})
+	}
+	if len(masked) > 0 {
+		s.mutex.Lock()
+		for name, count := range masked {
+			s.counts[name] += count
+		}
+		s.mutex.Unlock()
+	}
+	return text
+}
+
+// Counts returns how many secrets each pattern has masked
+func (s *Scrubber) Counts() map[string]int64 {
+	counts := make(map[string]int64)
+	if s == nil {
+		return counts
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for name, count := range s.counts {
+		counts[name] = count
+	}
+	return counts
+}
+
+// PatternNames returns the names of the patterns in match order
+func (s *Scrubber) PatternNames() []string {
+	names := make([]string, len(s.patterns))
+	for i, pattern := range s.patterns {
+		names[i] = pattern.name
+	}
+	return names
+}
+
+// LogHook scrubs the message and string fields of log entries
+type LogHook struct {
+	scrubber *Scrubber
+}
+
+// NewLogHook creates a logrus hook for scrubber
+func NewLogHook(scrubber *Scrubber) *LogHook {
+	return &LogHook{scrubber: scrubber}
+}
+
+// Levels reports that the hook applies to every level
+func (h *LogHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire scrubs the entry in place
+func (h *LogHook) Fire(entry *logrus.Entry) error {
+	entry.Message = h.scrubber.Scrub(entry.Message)
+	for key, value := range entry.Data {
+		switch value := value.(type) {
+		case string:
+			entry.Data[key] = h.scrubber.Scrub(value)
+		case error:
+			// Errors stay errors unless they held a secret
+			if scrubbed := h.scrubber.Scrub(value.Error()); scrubbed != value.Error() {
+				entry.Data[key] = scrubbed
+			}
+		}
+	}
+	return nil
+}
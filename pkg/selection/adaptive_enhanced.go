@@ -3,7 +3,8 @@ package selection
 import (
 	"fmt"
 	"math"
-	"sort"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -11,6 +12,8 @@ import (
 	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/analysis"
 	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
 	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/providers"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
+	"gopkg.in/yaml.v3"
 )
 
 // EnhancedAdaptiveSelector implements intelligent provider selection with capability filtering
@@ -24,6 +27,10 @@ type EnhancedAdaptiveSelector struct {
 	yamlBuilder        *config.YAMLBuilder
 	capabilityDetector *CapabilityDetector
 	csvParser          *providers.CSVParser
+	paretoPolicy       ParetoPolicy
+	modelAliases       *ModelAliases
+	modelDatabase      *ModelDatabase
+	metricsHalfLife    time.Duration
 }
 
 // NewEnhancedAdaptiveSelector creates a new enhanced adaptive provider selector
@@ -39,15 +46,13 @@ func NewEnhancedAdaptiveSelector(csvPath string) (*EnhancedAdaptiveSelector, err
 		enhancedConfigs:      make(map[string]*config.ProviderConfig),
 		providerCapabilities: make(map[string]ProviderCapabilities),
 		performanceData:      make(map[string]*ProviderMetrics),
+		modelAliases:         DefaultModelAliases(),
+		modelDatabase:        NewModelDatabase(),
 		yamlBuilder:          yamlBuilder,
 		csvParser:            csvParser,
 		capabilityDetector:   capabilityDetector,
-		weights: SelectionWeights{
-			Cost:        0.25,
-			Quality:     0.40,
-			Latency:     0.20,
-			Reliability: 0.15,
-		},
+		weights:              DefaultSelectionWeights,
+		metricsHalfLife:      DefaultMetricsHalfLife,
 	}
 
 	// Load CSV providers
@@ -64,7 +69,9 @@ func NewEnhancedAdaptiveSelector(csvPath string) (*EnhancedAdaptiveSelector, err
 
 	// Try to load enhanced configurations
 	if err := yamlBuilder.BuildFromCSV(); err == nil {
-		selector.loadEnhancedConfigs("./configs")
+		if err := selector.loadEnhancedConfigs("./configs"); err != nil {
+			return nil, fmt.Errorf("failed to load provider configs: %w", err)
+		}
 	}
 
 	return selector, nil
@@ -83,9 +90,7 @@ func (eas *EnhancedAdaptiveSelector) loadProviderCapabilities() error {
 		// Extract models based on source type
 		switch config.ModelsSource.Type {
 		case "list":
-			if modelList, ok := config.ModelsSource.Value.([]string); ok {
-				models = modelList
-			}
+			models = config.ModelsSource.List()
 		case "endpoint":
 			// For endpoint-based models, we'll use the provider name as a hint
 			models = []string{name}
@@ -94,9 +99,10 @@ func (eas *EnhancedAdaptiveSelector) loadProviderCapabilities() error {
 			models = []string{name}
 		}
 
-		// Detect capabilities from models
+		// Detect capabilities from models; explicit overrides from the CSV win
 		capabilities := eas.capabilityDetector.DetectCapabilities(models)
-		providerID := eas.generateProviderID(name)
+		capabilities = ApplyCapabilityOverrides(capabilities, config.CapabilityOverrides)
+		providerID := ProviderID(name)
 		eas.providerCapabilities[providerID] = capabilities
 	}
 
@@ -108,22 +114,41 @@ func (eas *EnhancedAdaptiveSelector) SelectProvider(complexity analysis.TaskComp
 	eas.mutex.RLock()
 	defer eas.mutex.RUnlock()
 
+	requestConstraints, err := ParseConstraints(constraints)
+	if err != nil {
+		return ProviderScore{}, err
+	}
+
+	// Requests for renamed or retired models are routed to their replacement
+	resolution := eas.modelAliases.Resolve(requestConstraints.Model)
+	requestConstraints.Model = resolution.Model
+	if resolution.Warning != "" {
+		logger.Warn(resolution.Warning)
+	}
+
 	// Detect task type from complexity context or constraints
 	taskType := eas.detectTaskTypeFromContext(complexity, constraints)
 
 	// Filter providers by capability first
 	compatibleProviders := eas.filterCompatibleProviders(taskType)
 	if len(compatibleProviders) == 0 {
-		return ProviderScore{}, fmt.Errorf("no providers found with capability for task type: %s", taskType)
+		return ProviderScore{}, eas.capabilityDetector.NoCapableProvider(eas.providerCapabilities, taskType)
 	}
 
-	// Try enhanced selection first if available
+	// Try enhanced selection first if available, falling back to CSV-based selection
+	var best ProviderScore
 	if len(eas.enhancedConfigs) > 0 {
-		return eas.selectFromEnhancedFiltered(complexity, constraints, compatibleProviders, taskType)
+		best, err = eas.selectFromEnhancedFiltered(complexity, constraints, requestConstraints, compatibleProviders, taskType)
+	} else {
+		best, err = eas.selectFromCSVFiltered(complexity, constraints, requestConstraints, compatibleProviders, taskType)
 	}
-
-	// Fallback to CSV-based selection with filtering
-	return eas.selectFromCSVFiltered(complexity, constraints, compatibleProviders, taskType)
+	if err != nil {
+		return ProviderScore{}, err
+	}
+	if resolution.Aliased {
+		best.ModelResolution = &resolution
+	}
+	return best, nil
 }
 
 // filterCompatibleProviders returns only providers that can handle the task type
@@ -171,14 +196,15 @@ func (eas *EnhancedAdaptiveSelector) detectTaskTypeFromContext(complexity analys
 }
 
 // selectFromEnhancedFiltered performs selection using enhanced configurations with filtering
-func (eas *EnhancedAdaptiveSelector) selectFromEnhancedFiltered(complexity analysis.TaskComplexity, constraints map[string]interface{}, compatibleProviders []string, taskType TaskType) (ProviderScore, error) {
-	var candidates []ProviderScore
+func (eas *EnhancedAdaptiveSelector) selectFromEnhancedFiltered(complexity analysis.TaskComplexity, constraints map[string]interface{}, requestConstraints RequestConstraints, compatibleProviders []string, taskType TaskType) (ProviderScore, error) {
+	var candidates []scoredCandidate
+	tokens := estimateRequestTokens(constraints)
 
 	for _, provider := range eas.enhancedConfigs {
-		providerID := eas.generateProviderID(provider.Name)
+		providerID := ProviderID(provider.Name)
 		
 		// Only consider compatible providers
-		if !eas.contains(compatibleProviders, providerID) {
+		if !contains(compatibleProviders, providerID) {
 			continue
 		}
 		
@@ -189,33 +215,42 @@ func (eas *EnhancedAdaptiveSelector) selectFromEnhancedFiltered(complexity analy
 			score = eas.adjustScoreForCapabilities(score, capabilities, taskType)
 		}
 		
-		candidates = append(candidates, score)
+		costs := provider.CostTracking
+		if costs.CostPerToken == 0 && costs.CostPerRequest == 0 {
+			costs = config.DefaultCostTracking(provider.Tier)
+		}
+		candidates = append(candidates, eas.newScoredCandidate(score, providerID, provider.Tier, costs, tokens))
 	}
 
 	if len(candidates) == 0 {
 		return ProviderScore{}, fmt.Errorf("no suitable enhanced providers found for task type: %s", taskType)
 	}
 
-	// Sort by total score (descending)
-	sort.Slice(candidates, func(i, j int) bool {
-		return candidates[i].TotalScore > candidates[j].TotalScore
-	})
-
-	best := candidates[0]
+	best, err := eas.choose(candidates, requestConstraints, ModelRequest{TaskType: taskType, Level: ModelLevel(complexity.Score), Tokens: int64(tokens), Explain: true})
+	if err != nil {
+		return ProviderScore{}, err
+	}
 	best.Reasoning = eas.generateEnhancedSelectionReasoning(best, complexity, constraints, taskType)
+	if !requestConstraints.IsZero() {
+		best.Reasoning += fmt.Sprintf(". Meets request constraints: %s", requestConstraints)
+	}
+	if best.Decision != nil {
+		best.Reasoning += ". Pareto decision: " + best.Decision.Tradeoff
+	}
 
 	return best, nil
 }
 
 // selectFromCSVFiltered performs fallback selection using only CSV data with filtering
-func (eas *EnhancedAdaptiveSelector) selectFromCSVFiltered(complexity analysis.TaskComplexity, constraints map[string]interface{}, compatibleProviders []string, taskType TaskType) (ProviderScore, error) {
-	var candidates []ProviderScore
+func (eas *EnhancedAdaptiveSelector) selectFromCSVFiltered(complexity analysis.TaskComplexity, constraints map[string]interface{}, requestConstraints RequestConstraints, compatibleProviders []string, taskType TaskType) (ProviderScore, error) {
+	var candidates []scoredCandidate
+	tokens := estimateRequestTokens(constraints)
 
 	for _, provider := range eas.csvProviders {
-		providerID := eas.generateProviderID(provider.Name)
+		providerID := ProviderID(provider.Name)
 		
 		// Only consider compatible providers
-		if !eas.contains(compatibleProviders, providerID) {
+		if !contains(compatibleProviders, providerID) {
 			continue
 		}
 		
@@ -226,23 +261,114 @@ func (eas *EnhancedAdaptiveSelector) selectFromCSVFiltered(complexity analysis.T
 			score = eas.adjustScoreForCapabilities(score, capabilities, taskType)
 		}
 		
-		candidates = append(candidates, score)
+		candidates = append(candidates, eas.newScoredCandidate(score, providerID, provider.Tier, config.DefaultCostTracking(provider.Tier), tokens))
 	}
 
 	if len(candidates) == 0 {
 		return ProviderScore{}, fmt.Errorf("no suitable CSV providers found for task type: %s", taskType)
 	}
 
-	sort.Slice(candidates, func(i, j int) bool {
-		return candidates[i].TotalScore > candidates[j].TotalScore
-	})
-
-	best := candidates[0]
+	best, err := eas.choose(candidates, requestConstraints, ModelRequest{TaskType: taskType, Level: ModelLevel(complexity.Score), Tokens: int64(tokens), Explain: true})
+	if err != nil {
+		return ProviderScore{}, err
+	}
 	best.Reasoning = eas.generateCSVSelectionReasoningWithCapabilities(best, complexity, taskType)
+	if !requestConstraints.IsZero() {
+		best.Reasoning += fmt.Sprintf(". Meets request constraints: %s", requestConstraints)
+	}
+	if best.Decision != nil {
+		best.Reasoning += ". Pareto decision: " + best.Decision.Tradeoff
+	}
+
+	return best, nil
+}
+
+// choose applies the request constraints and picks the best remaining
+// candidate by weighted score or, when a Pareto policy is in effect, from the
+// Pareto front of the most preferred rank present. The model is the requested
+// one, or else the chosen provider's best scoring model
+func (eas *EnhancedAdaptiveSelector) choose(candidates []scoredCandidate, constraints RequestConstraints, request ModelRequest) (ProviderScore, error) {
+	accepted, rejections, err := applyConstraints(candidates, constraints)
+	if err != nil {
+		return ProviderScore{}, err
+	}
+
+	best := accepted[0].score
+	policy := eas.paretoPolicy
+	if constraints.ParetoPolicy != ParetoOff {
+		policy = constraints.ParetoPolicy
+	}
+	if policy != ParetoOff {
+		preferred := constraints.Rank(accepted[0].candidate.Tier, accepted[0].candidate.ProviderID)
+		var points []ParetoPoint
+		scores := make(map[string]ProviderScore)
+		for _, c := range accepted {
+			if constraints.Rank(c.candidate.Tier, c.candidate.ProviderID) != preferred {
+				continue
+			}
+			points = append(points, ParetoPoint{
+				ProviderID:    c.candidate.ProviderID,
+				Tier:          c.candidate.Tier,
+				EstimatedCost: c.candidate.EstimatedCost,
+				Quality:       math.Min(c.score.QualityScore, 1),
+				LatencyScore:  c.score.LatencyScore,
+				TotalScore:    c.score.TotalScore,
+			})
+			scores[c.candidate.ProviderID] = c.score
+		}
+
+		decision := Decide(points, policy)
+		best = scores[decision.Chosen]
+		best.Decision = decision
+	}
 
+	for _, c := range accepted {
+		if c.candidate.ProviderID != best.ProviderID {
+			continue
+		}
+		if constraints.Model != "" {
+			best.Model, _ = MatchModel(c.candidate.Models, constraints.Model)
+		} else if best.ModelScores = ScoreModels(eas.modelDatabase, best.ProviderID, c.candidate.Models, request); len(best.ModelScores) > 0 {
+			best.Model = best.ModelScores[0].Model
+		}
+		break
+	}
+
+	best.Rejected = rejections
 	return best, nil
 }
 
+// newScoredCandidate records the estimated cost of a scored provider and the
+// facts its constraints are checked against
+func (eas *EnhancedAdaptiveSelector) newScoredCandidate(score ProviderScore, providerID string, providerTier tier.Tier, costs config.CostTracking, tokens float64) scoredCandidate {
+	score.EstimatedCost = costs.CostPerRequest + costs.CostPerToken*tokens
+
+	// Latency long unobserved no longer holds a provider to the SLO
+	var latency time.Duration
+	if metrics, exists := eas.performanceData[providerID]; exists {
+		if windowed := metrics.recent(); windowed != nil {
+			if windowed.Requests >= 1 {
+				latency = time.Duration(windowed.AverageLatencyMs * float64(time.Millisecond))
+			}
+		} else if metrics.TotalRequests > 0 {
+			latency = metrics.AverageLatency
+		}
+	}
+
+	return scoredCandidate{
+		score: score,
+		candidate: Candidate{
+			ProviderID:    providerID,
+			Tier:          providerTier,
+			EstimatedCost: score.EstimatedCost,
+			Quality:       score.QualityScore,
+			Latency:       latency,
+			Models:        eas.providerCapabilities[providerID].Models,
+			Capabilities:  ProviderFacts{Capabilities: eas.providerCapabilities[providerID], Tier: providerTier},
+		},
+	}
+}
+
 // adjustScoreForCapabilities boosts scores based on capability-task alignment
 func (eas *EnhancedAdaptiveSelector) adjustScoreForCapabilities(score ProviderScore, capabilities ProviderCapabilities, taskType TaskType) ProviderScore {
 	boost := 1.0
@@ -286,7 +412,7 @@ func (eas *EnhancedAdaptiveSelector) adjustScoreForCapabilities(score ProviderSc
 	return score
 }
 
-// Helper methods from original AdaptiveSelector (reused with modifications)
+// Scoring methods, built on the shared primitives in scoring.go
 
 func (eas *EnhancedAdaptiveSelector) calculateProviderScore(provider config.ProviderConfig, complexity analysis.TaskComplexity, constraints map[string]interface{}) ProviderScore {
 	qualityScore := eas.calculateQualityScore(provider, complexity)
@@ -311,26 +437,21 @@ func (eas *EnhancedAdaptiveSelector) calculateProviderScore(provider config.Prov
 
 func (eas *EnhancedAdaptiveSelector) calculateCSVProviderScore(provider config.CSVProvider, complexity analysis.TaskComplexity, constraints map[string]interface{}) ProviderScore {
 	// Simple tier-based quality scoring
-	qualityScore := eas.getTierQualityScore(provider.Tier)
+	qualityScore := TierQualityScore(provider.Tier)
 
 	// Adjust based on complexity requirements
-	if complexity.Score > 0.7 && provider.Tier != "official" {
+	if complexity.Score > 0.7 && provider.Tier != tier.Official {
 		qualityScore *= 0.7 // Penalty for non-official providers on complex tasks
 	}
 
 	// Simple cost scoring based on tier
-	costScore := eas.getTierCostScore(provider.Tier)
-
-	// Default latency and reliability scores
-	latencyScore := 0.7
-	reliabilityScore := 0.8
+	costScore := TierCostScore(provider.Tier)
 
 	// Apply historical data if available
-	providerID := eas.generateProviderID(provider.Name)
-	if metrics, exists := eas.performanceData[providerID]; exists {
-		latencyScore = eas.calculateLatencyScoreFromMetrics(metrics)
-		reliabilityScore = metrics.SuccessRate
-	}
+	providerID := ProviderID(provider.Name)
+	metrics := eas.performanceData[providerID]
+	latencyScore := LatencyScore(metrics)
+	reliabilityScore := ReliabilityScore(metrics)
 
 	totalScore := (qualityScore * eas.weights.Quality) +
 		(costScore * eas.weights.Cost) +
@@ -347,16 +468,15 @@ func (eas *EnhancedAdaptiveSelector) calculateCSVProviderScore(provider config.C
 	}
 }
 
-// Reuse helper methods from original AdaptiveSelector
 func (eas *EnhancedAdaptiveSelector) calculateQualityScore(provider config.ProviderConfig, complexity analysis.TaskComplexity) float64 {
 	// Use capability-based quality scoring if available
-	providerID := eas.generateProviderID(provider.Name)
+	providerID := ProviderID(provider.Name)
 	if capabilities, exists := eas.providerCapabilities[providerID]; exists {
 		return eas.calculateCapabilityBasedQuality(capabilities, complexity)
 	}
 	
 	// Fallback to default scoring
-	return 0.7
+	return DefaultQualityScore
 }
 
 func (eas *EnhancedAdaptiveSelector) calculateCapabilityBasedQuality(capabilities ProviderCapabilities, complexity analysis.TaskComplexity) float64 {
@@ -375,60 +495,11 @@ func (eas *EnhancedAdaptiveSelector) calculateCostScore(provider config.Provider
 }
 
 func (eas *EnhancedAdaptiveSelector) calculateLatencyScore(providerID string) float64 {
-	metrics, exists := eas.performanceData[providerID]
-	if !exists {
-		return 0.7 // Default score for unknown providers
-	}
-	return eas.calculateLatencyScoreFromMetrics(metrics)
-}
-
-func (eas *EnhancedAdaptiveSelector) calculateLatencyScoreFromMetrics(metrics *ProviderMetrics) float64 {
-	targetLatency := 500 * time.Millisecond
-	if metrics.AverageLatency <= targetLatency {
-		return 1.0
-	}
-
-	maxLatency := 5 * time.Second
-	if metrics.AverageLatency >= maxLatency {
-		return 0.1
-	}
-
-	ratio := float64(metrics.AverageLatency-targetLatency) / float64(maxLatency-targetLatency)
-	return 1.0 - (ratio * 0.9)
+	return LatencyScore(eas.performanceData[providerID])
 }
 
 func (eas *EnhancedAdaptiveSelector) calculateReliabilityScore(providerID string) float64 {
-	metrics, exists := eas.performanceData[providerID]
-	if !exists {
-		return 0.8 // Default high reliability for untested providers
-	}
-	return metrics.SuccessRate
-}
-
-func (eas *EnhancedAdaptiveSelector) getTierQualityScore(tier string) float64 {
-	switch tier {
-	case "official":
-		return 0.9
-	case "community":
-		return 0.7
-	case "unofficial":
-		return 0.5
-	default:
-		return 0.6
-	}
-}
-
-func (eas *EnhancedAdaptiveSelector) getTierCostScore(tier string) float64 {
-	switch tier {
-	case "official":
-		return 0.3 // Higher cost
-	case "community":
-		return 0.7 // Medium cost
-	case "unofficial":
-		return 1.0 // Lower/free cost
-	default:
-		return 0.5
-	}
+	return ReliabilityScore(eas.performanceData[providerID])
 }
 
 func (eas *EnhancedAdaptiveSelector) generateEnhancedSelectionReasoning(score ProviderScore, complexity analysis.TaskComplexity, constraints map[string]interface{}, taskType TaskType) string {
@@ -467,7 +538,7 @@ func (eas *EnhancedAdaptiveSelector) generateEnhancedSelectionReasoning(score Pr
 
 	complexityDesc := complexity.GetComplexityDescription()
 	reasoning := fmt.Sprintf("Selected for %s %s task. Key factors: %s",
-		complexityDesc, taskType, eas.joinReasons(reasons))
+		complexityDesc, taskType, joinReasons(reasons))
 
 	return reasoning
 }
@@ -485,43 +556,48 @@ func (eas *EnhancedAdaptiveSelector) UpdateProviderMetrics(providerID string, la
 
 	metrics, exists := eas.performanceData[providerID]
 	if !exists {
-		metrics = &ProviderMetrics{
-			AverageLatency:     latency,
-			SuccessRate:        1.0,
-			QualityScore:       quality,
-			TotalRequests:      0,
-			SuccessfulRequests: 0,
-			LastUpdated:        time.Now(),
+		metrics = NewProviderMetrics(latency, quality)
+		if eas.metricsHalfLife > 0 {
+			metrics.window = NewWindowedStats(eas.metricsHalfLife)
 		}
 		eas.performanceData[providerID] = metrics
 	}
-
-	// Update metrics using exponential moving average
-	alpha := 0.1 // Learning rate
-	metrics.AverageLatency = time.Duration(float64(metrics.AverageLatency)*(1-alpha) + float64(latency)*alpha)
-
-	metrics.TotalRequests++
-	if success {
-		metrics.SuccessfulRequests++
-	}
-
-	metrics.SuccessRate = float64(metrics.SuccessfulRequests) / float64(metrics.TotalRequests)
-	metrics.QualityScore = metrics.QualityScore*(1-alpha) + quality*alpha
-	metrics.LastUpdated = time.Now()
+	metrics.Record(latency, success, quality)
 }
 
-// GetProviderMetrics returns current metrics for all providers
+// GetProviderMetrics returns a copy of the current metrics for all providers
 func (eas *EnhancedAdaptiveSelector) GetProviderMetrics() map[string]*ProviderMetrics {
 	eas.mutex.RLock()
 	defer eas.mutex.RUnlock()
 
-	result := make(map[string]*ProviderMetrics)
+	result := make(map[string]*ProviderMetrics, len(eas.performanceData))
 	for k, v := range eas.performanceData {
-		result[k] = v
+		metrics := *v
+		metrics.Windowed = v.recent()
+		metrics.window = nil
+		result[k] = &metrics
 	}
 	return result
 }
 
+// SetMetricsHalfLife sets how long it takes a request's weight in the
+// windowed metrics to halve; zero scores on lifetime metrics
+func (eas *EnhancedAdaptiveSelector) SetMetricsHalfLife(halfLife time.Duration) {
+	eas.mutex.Lock()
+	defer eas.mutex.Unlock()
+
+	eas.metricsHalfLife = halfLife
+	for _, metrics := range eas.performanceData {
+		if halfLife <= 0 {
+			metrics.window = nil
+			continue
+		}
+		metrics.window = NewWindowedStats(halfLife)
+		failed := metrics.TotalRequests - metrics.SuccessfulRequests
+		metrics.window.Seed(metrics.LastUpdated, int64(metrics.TotalRequests), int64(failed), float64(metrics.AverageLatency.Milliseconds()))
+	}
+}
+
 // GetProviderCapabilities returns capabilities for all providers
 func (eas *EnhancedAdaptiveSelector) GetProviderCapabilities() map[string]ProviderCapabilities {
 	eas.mutex.RLock()
@@ -541,37 +617,64 @@ func (eas *EnhancedAdaptiveSelector) SetWeights(weights SelectionWeights) {
 	eas.weights = weights
 }
 
-// Utility methods
-func (eas *EnhancedAdaptiveSelector) contains(slice []string, item string) bool {
-	for _, s := range slice {
-		if s == item {
-			return true
-		}
-	}
-	return false
+// SetParetoPolicy sets how selection uses the Pareto front over cost, quality
+// and latency. Requests may override it with the pareto_policy constraint
+func (eas *EnhancedAdaptiveSelector) SetParetoPolicy(policy ParetoPolicy) {
+	eas.mutex.Lock()
+	defer eas.mutex.Unlock()
+	eas.paretoPolicy = policy
 }
 
-func (eas *EnhancedAdaptiveSelector) generateProviderID(name string) string {
-	return strings.ToLower(strings.ReplaceAll(name, " ", "_"))
+// SetModelAliases sets the alias table used to resolve requested models
+func (eas *EnhancedAdaptiveSelector) SetModelAliases(aliases *ModelAliases) {
+	eas.mutex.Lock()
+	defer eas.mutex.Unlock()
+
+	eas.modelAliases = aliases
 }
 
-func (eas *EnhancedAdaptiveSelector) joinReasons(reasons []string) string {
-	if len(reasons) == 0 {
-		return "general suitability"
-	}
-	if len(reasons) == 1 {
-		return reasons[0]
-	}
-	if len(reasons) == 2 {
-		return reasons[0] + " and " + reasons[1]
+// loadEnhancedConfigs loads provider YAML files from configDir. Capability
+// overrides declared there take precedence over CSV values and detection;
+// invalid ones are logged and ignored
+func (eas *EnhancedAdaptiveSelector) loadEnhancedConfigs(configDir string) error {
+	files, err := filepath.Glob(filepath.Join(configDir, "*.yaml"))
+	if err != nil {
+		return err
 	}
 
-	last := reasons[len(reasons)-1]
-	others := strings.Join(reasons[:len(reasons)-1], ", ")
-	return others + ", and " + last
-}
+	eas.mutex.Lock()
+	defer eas.mutex.Unlock()
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			logger.Warnf("Failed to read provider config %s: %v", file, err)
+			continue
+		}
+
+		var providerConfig config.ProviderConfig
+		if err := yaml.Unmarshal(data, &providerConfig); err != nil {
+			logger.Warnf("Failed to parse provider config %s: %v", file, err)
+			continue
+		}
+		if err := providerConfig.CapabilityOverrides.Validate(); err != nil {
+			logger.Warnf("Ignoring capability overrides of %s: %v", file, err)
+			providerConfig.CapabilityOverrides = nil
+		}
+		if err := providerConfig.ExpandEnv(); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+
+		providerID := providerConfig.ID
+		if providerID == "" {
+			providerID = ProviderID(providerConfig.Name)
+		}
+		eas.enhancedConfigs[providerID] = &providerConfig
+
+		if capabilities, exists := eas.providerCapabilities[providerID]; exists {
+			eas.providerCapabilities[providerID] = ApplyCapabilityOverrides(capabilities, providerConfig.CapabilityOverrides)
+		}
+	}
 
-func (eas *EnhancedAdaptiveSelector) loadEnhancedConfigs(configDir string) error {
-	// Implementation would load YAML files from configDir
 	return nil
 }
\ No newline at end of file
@@ -1,8 +1,12 @@
 package selection
 
 import (
-	"strings"
+	"fmt"
 	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
 )
 
 // ProviderCapabilities represents what a provider can handle
@@ -97,6 +101,36 @@ func (cd *CapabilityDetector) DetectCapabilities(models []string) ProviderCapabi
 	return capabilities
 }
 
+// ApplyCapabilityOverrides replaces detected capabilities with operator-declared
+// values. Only the fields set in overrides are changed
+func ApplyCapabilityOverrides(capabilities ProviderCapabilities, overrides *config.CapabilityOverrides) ProviderCapabilities {
+	if overrides == nil {
+		return capabilities
+	}
+
+	setBool := func(target *bool, value *bool) {
+		if value != nil {
+			*target = *value
+		}
+	}
+	setInt := func(target *int, value *int) {
+		if value != nil {
+			*target = *value
+		}
+	}
+
+	setBool(&capabilities.Text, overrides.Text)
+	setBool(&capabilities.Image, overrides.Image)
+	setBool(&capabilities.Code, overrides.Code)
+	setBool(&capabilities.Audio, overrides.Audio)
+	setBool(&capabilities.Video, overrides.Video)
+	setBool(&capabilities.Multimodal, overrides.Multimodal)
+	setInt(&capabilities.Reasoning, overrides.Reasoning)
+	setInt(&capabilities.Knowledge, overrides.Knowledge)
+	setInt(&capabilities.Computation, overrides.Computation)
+	return capabilities
+}
+
 // matchesAnyPattern checks if text matches any of the given patterns
 func (cd *CapabilityDetector) matchesAnyPattern(text string, patterns []*regexp.Regexp) bool {
 	for _, pattern := range patterns {
@@ -227,4 +261,100 @@ func (cd *CapabilityDetector) IsProviderCompatible(capabilities ProviderCapabili
 	default:
 		return capabilities.Text // Default to text compatibility
 	}
-}
\ No newline at end of file
+}
+
+// maxNearestMisses is how many incompatible providers a NoCapableProviderError
+// lists
+const maxNearestMisses = 3
+
+// CapabilityMiss names a provider that cannot handle a task type and the
+// capabilities it lacks
+type CapabilityMiss struct {
+	ProviderID string   `json:"provider_id"`
+	Missing    []string `json:"missing"`
+}
+
+// NoCapableProviderError is returned when no provider has the capabilities a
+// task type requires. NearestMisses lists the providers lacking the fewest
+// of them
+type NoCapableProviderError struct {
+	TaskType             TaskType         `json:"task_type"`
+	RequiredCapabilities []string         `json:"required_capabilities"`
+	NearestMisses        []CapabilityMiss `json:"nearest_misses"`
+}
+
+// Error implements the error interface
+func (e *NoCapableProviderError) Error() string {
+	message := fmt.Sprintf("no provider has the capabilities for task type %s (requires %s)", e.TaskType, strings.Join(e.RequiredCapabilities, " and "))
+	if len(e.NearestMisses) == 0 {
+		return message + ": no providers are configured"
+	}
+	misses := make([]string, len(e.NearestMisses))
+	for i, miss := range e.NearestMisses {
+		misses[i] = miss.ProviderID + " lacks " + strings.Join(miss.Missing, ", ")
+	}
+	return message + "; nearest: " + strings.Join(misses, "; ")
+}
+
+// RequiredCapabilities returns the capabilities a provider needs for a task
+// type. Code is also served by text providers and multimodal by providers
+// with both text and image
+func RequiredCapabilities(taskType TaskType) []string {
+	switch taskType {
+	case TaskTypeImage:
+		return []string{"image"}
+	case TaskTypeCode:
+		return []string{"code"}
+	case TaskTypeAudio:
+		return []string{"audio"}
+	case TaskTypeVideo:
+		return []string{"video"}
+	case TaskTypeMultimodal:
+		return []string{"text", "image"}
+	default:
+		return []string{"text"}
+	}
+}
+
+// MissingCapabilities returns the capabilities a provider lacks for a task
+// type, none when IsProviderCompatible holds
+func (cd *CapabilityDetector) MissingCapabilities(capabilities ProviderCapabilities, taskType TaskType) []string {
+	if cd.IsProviderCompatible(capabilities, taskType) {
+		return nil
+	}
+	has := map[string]bool{
+		"text":  capabilities.Text,
+		"image": capabilities.Image,
+		"code":  capabilities.Code || capabilities.Text,
+		"audio": capabilities.Audio,
+		"video": capabilities.Video,
+	}
+	var missing []string
+	for _, capability := range RequiredCapabilities(taskType) {
+		if !has[capability] {
+			missing = append(missing, capability)
+		}
+	}
+	return missing
+}
+
+// NoCapableProvider builds the error for a task type none of the providers
+// can handle, listing those lacking the fewest capabilities first
+func (cd *CapabilityDetector) NoCapableProvider(providers map[string]ProviderCapabilities, taskType TaskType) *NoCapableProviderError {
+	var misses []CapabilityMiss
+	for providerID, capabilities := range providers {
+		if missing := cd.MissingCapabilities(capabilities, taskType); len(missing) > 0 {
+			misses = append(misses, CapabilityMiss{ProviderID: providerID, Missing: missing})
+		}
+	}
+	sort.Slice(misses, func(i, j int) bool {
+		if len(misses[i].Missing) != len(misses[j].Missing) {
+			return len(misses[i].Missing) < len(misses[j].Missing)
+		}
+		return misses[i].ProviderID < misses[j].ProviderID
+	})
+	if len(misses) > maxNearestMisses {
+		misses = misses[:maxNearestMisses]
+	}
+	return &NoCapableProviderError{TaskType: taskType, RequiredCapabilities: RequiredCapabilities(taskType), NearestMisses: misses}
+}
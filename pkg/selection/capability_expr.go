@@ -0,0 +1,421 @@
+package selection
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
+)
+
+// capabilityLevels are the numeric capabilities expressions compare, each
+// on a 1-10 scale
+var capabilityLevels = []string{"reasoning", "knowledge", "computation"}
+
+// CapabilityFacts is what a capability expression is evaluated against
+type CapabilityFacts interface {
+	// HasCapability reports whether the provider has a named capability, such
+	// as "code", "vision" or its tier "official"
+	HasCapability(name string) bool
+	// CapabilityLevel returns a numeric capability such as "reasoning", false
+	// when it is unknown
+	CapabilityLevel(name string) (float64, bool)
+}
+
+// ProviderFacts evaluates expressions against detected capabilities and the
+// provider's tier
+type ProviderFacts struct {
+	Capabilities ProviderCapabilities
+	Tier         tier.Tier
+}
+
+// HasCapability implements CapabilityFacts
+func (f ProviderFacts) HasCapability(name string) bool {
+	if tier.Tier(name) == f.Tier {
+		return true
+	}
+	return f.Capabilities.Has(name)
+}
+
+// CapabilityLevel implements CapabilityFacts
+func (f ProviderFacts) CapabilityLevel(name string) (float64, bool) {
+	return f.Capabilities.Level(name)
+}
+
+// Has reports whether the capabilities include a named modality; "vision"
+// is an image capability
+func (c ProviderCapabilities) Has(name string) bool {
+	switch name {
+	case "text":
+		return c.Text
+	case "image", "vision":
+		return c.Image
+	case "code":
+		return c.Code
+	case "audio":
+		return c.Audio
+	case "video":
+		return c.Video
+	case "multimodal":
+		return c.Multimodal || c.Text && c.Image
+	}
+	return false
+}
+
+// Level returns a numeric capability, false for names that are not levels
+// and levels that were never assessed
+func (c ProviderCapabilities) Level(name string) (float64, bool) {
+	var level int
+	switch name {
+	case "reasoning":
+		level = c.Reasoning
+	case "knowledge":
+		level = c.Knowledge
+	case "computation":
+		level = c.Computation
+	default:
+		return 0, false
+	}
+	return float64(level), level > 0
+}
+
+// CapabilityExpr is a parsed capability requirement such as
+// "code AND (reasoning>=7 OR official)". Names test capabilities or the
+// tier, comparisons test capability levels, and AND binds tighter than OR.
+// A nil expression is satisfied by every provider
+type CapabilityExpr struct {
+	source string
+	root   capabilityNode
+}
+
+// ParseCapabilityExpr parses a capability requirement. Operators are AND, OR
+// and NOT in any case or &&, || and !, and levels compare with >=, >, <=,
+// <, = and !=. An empty string is no requirement and returns nil
+func ParseCapabilityExpr(s string) (*CapabilityExpr, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	tokens, err := lexCapabilityExpr(s)
+	if err != nil {
+		return nil, err
+	}
+	parser := &capabilityParser{tokens: tokens}
+	root, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if next := parser.peek(); next.kind != tokenEnd {
+		return nil, fmt.Errorf("unexpected %s at offset %d", next, next.offset)
+	}
+	return &CapabilityExpr{source: strings.TrimSpace(s), root: root}, nil
+}
+
+// MustParseCapabilityExpr is ParseCapabilityExpr for expressions known to be
+// valid; it panics otherwise
+func MustParseCapabilityExpr(s string) *CapabilityExpr {
+	expr, err := ParseCapabilityExpr(s)
+	if err != nil {
+		panic(fmt.Sprintf("invalid capability expression %q: %v", s, err))
+	}
+	return expr
+}
+
+// Eval reports whether facts satisfy the expression
+func (e *CapabilityExpr) Eval(facts CapabilityFacts) bool {
+	if e == nil {
+		return true
+	}
+	return e.root.eval(facts)
+}
+
+// And returns an expression requiring both e and other; either may be nil
+func (e *CapabilityExpr) And(other *CapabilityExpr) *CapabilityExpr {
+	if e == nil {
+		return other
+	}
+	if other == nil {
+		return e
+	}
+	return &CapabilityExpr{
+		source: "(" + e.source + ") AND (" + other.source + ")",
+		root:   capabilityAnd{left: e.root, right: other.root},
+	}
+}
+
+// String returns the expression as it was written
+func (e *CapabilityExpr) String() string {
+	if e == nil {
+		return ""
+	}
+	return e.source
+}
+
+// MarshalText encodes the expression as it was written
+func (e *CapabilityExpr) MarshalText() ([]byte, error) {
+	return []byte(e.String()), nil
+}
+
+// UnmarshalText parses an expression from JSON or YAML
+func (e *CapabilityExpr) UnmarshalText(text []byte) error {
+	parsed, err := ParseCapabilityExpr(string(text))
+	if err != nil {
+		return err
+	}
+	if parsed == nil {
+		*e = CapabilityExpr{}
+		return nil
+	}
+	*e = *parsed
+	return nil
+}
+
+// capabilityNode is a node of a parsed expression
+type capabilityNode interface {
+	eval(facts CapabilityFacts) bool
+}
+
+type capabilityAnd struct{ left, right capabilityNode }
+
+func (n capabilityAnd) eval(facts CapabilityFacts) bool {
+	return n.left.eval(facts) && n.right.eval(facts)
+}
+
+type capabilityOr struct{ left, right capabilityNode }
+
+func (n capabilityOr) eval(facts CapabilityFacts) bool {
+	return n.left.eval(facts) || n.right.eval(facts)
+}
+
+type capabilityNot struct{ operand capabilityNode }
+
+func (n capabilityNot) eval(facts CapabilityFacts) bool {
+	return !n.operand.eval(facts)
+}
+
+// capabilityName holds when the provider has the named capability
+type capabilityName struct{ name string }
+
+func (n capabilityName) eval(facts CapabilityFacts) bool {
+	return facts.HasCapability(n.name)
+}
+
+// capabilityComparison holds when a known level compares true; an unknown
+// level never satisfies a comparison
+type capabilityComparison struct {
+	name  string
+	op    string
+	value float64
+}
+
+func (n capabilityComparison) eval(facts CapabilityFacts) bool {
+	level, known := facts.CapabilityLevel(n.name)
+	if !known {
+		return false
+	}
+	switch n.op {
+	case ">=":
+		return level >= n.value
+	case ">":
+		return level > n.value
+	case "<=":
+		return level <= n.value
+	case "<":
+		return level < n.value
+	case "=":
+		return level == n.value
+	case "!=":
+		return level != n.value
+	}
+	return false
+}
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenName
+	tokenNumber
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenCompare
+	tokenOpen
+	tokenClose
+)
+
+// capabilityToken is a lexed token and where it starts in the source
+type capabilityToken struct {
+	kind   tokenKind
+	text   string
+	offset int
+}
+
+// String describes the token for parse errors
+func (t capabilityToken) String() string {
+	if t.kind == tokenEnd {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+// lexCapabilityExpr splits s into tokens ending with tokenEnd
+func lexCapabilityExpr(s string) ([]capabilityToken, error) {
+	var tokens []capabilityToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, capabilityToken{tokenOpen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, capabilityToken{tokenClose, ")", i})
+			i++
+		case strings.HasPrefix(s[i:], "&&"):
+			tokens = append(tokens, capabilityToken{tokenAnd, "&&", i})
+			i += 2
+		case strings.HasPrefix(s[i:], "||"):
+			tokens = append(tokens, capabilityToken{tokenOr, "||", i})
+			i += 2
+		case strings.HasPrefix(s[i:], ">="), strings.HasPrefix(s[i:], "<="), strings.HasPrefix(s[i:], "!="), strings.HasPrefix(s[i:], "=="):
+			op := s[i : i+2]
+			if op == "==" {
+				op = "="
+			}
+			tokens = append(tokens, capabilityToken{tokenCompare, op, i})
+			i += 2
+		case c == '>' || c == '<' || c == '=':
+			tokens = append(tokens, capabilityToken{tokenCompare, string(c), i})
+			i++
+		case c == '!':
+			tokens = append(tokens, capabilityToken{tokenNot, "!", i})
+			i++
+		case c >= '0' && c <= '9' || c == '.':
+			start := i
+			for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, capabilityToken{tokenNumber, s[start:i], start})
+		case isNameByte(c):
+			start := i
+			for i < len(s) && (isNameByte(s[i]) || s[i] >= '0' && s[i] <= '9' || s[i] == '-') {
+				i++
+			}
+			word := s[start:i]
+			kind := tokenName
+			switch strings.ToUpper(word) {
+			case "AND":
+				kind = tokenAnd
+			case "OR":
+				kind = tokenOr
+			case "NOT":
+				kind = tokenNot
+			default:
+				word = strings.ToLower(word)
+			}
+			tokens = append(tokens, capabilityToken{kind, word, start})
+		default:
+			return nil, fmt.Errorf("unexpected character %q at offset %d", c, i)
+		}
+	}
+	return append(tokens, capabilityToken{tokenEnd, "", len(s)}), nil
+}
+
+// isNameByte reports whether c may start a capability name
+func isNameByte(c byte) bool {
+	return c == '_' || c < unicode.MaxASCII && unicode.IsLetter(rune(c))
+}
+
+// capabilityParser is a recursive descent parser over lexed tokens
+type capabilityParser struct {
+	tokens []capabilityToken
+	next   int
+}
+
+func (p *capabilityParser) peek() capabilityToken {
+	return p.tokens[p.next]
+}
+
+func (p *capabilityParser) advance() capabilityToken {
+	token := p.tokens[p.next]
+	if token.kind != tokenEnd {
+		p.next++
+	}
+	return token
+}
+
+// parseOr parses "and {OR and}"
+func (p *capabilityParser) parseOr() (capabilityNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenOr {
+		p.advance()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = capabilityOr{left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseAnd parses "unary {AND unary}"
+func (p *capabilityParser) parseAnd() (capabilityNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenAnd {
+		p.advance()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = capabilityAnd{left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseUnary parses "NOT unary", "(expr)", "name" and "level op number"
+func (p *capabilityParser) parseUnary() (capabilityNode, error) {
+	token := p.advance()
+	switch token.kind {
+	case tokenNot:
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return capabilityNot{operand: operand}, nil
+	case tokenOpen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.advance(); closing.kind != tokenClose {
+			return nil, fmt.Errorf("expected \")\" at offset %d, found %s", closing.offset, closing)
+		}
+		return inner, nil
+	case tokenName:
+		if p.peek().kind != tokenCompare {
+			return capabilityName{name: token.text}, nil
+		}
+		op := p.advance()
+		if !contains(capabilityLevels, token.text) {
+			return nil, fmt.Errorf("cannot compare %q at offset %d: levels are %s", token.text, token.offset, strings.Join(capabilityLevels, ", "))
+		}
+		number := p.advance()
+		if number.kind != tokenNumber {
+			return nil, fmt.Errorf("expected a number after %s at offset %d, found %s", op.text, number.offset, number)
+		}
+		value, err := strconv.ParseFloat(number.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at offset %d", number.text, number.offset)
+		}
+		return capabilityComparison{name: token.text, op: op.text, value: value}, nil
+	}
+	return nil, fmt.Errorf("expected a capability, NOT or \"(\" at offset %d, found %s", token.offset, token)
+}
@@ -0,0 +1,146 @@
+package selection
+
+import (
+	"math"
+	"sync"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
+)
+
+// Warm-up defaults
+const (
+	// DefaultWarmupTrafficShare is the share of requests a warming provider may serve
+	DefaultWarmupTrafficShare = 0.1
+	// DefaultPriorStrength is how many requests a prior is worth
+	DefaultPriorStrength = 10
+)
+
+// WarmupPolicy decides how providers without enough real traffic are routed.
+// A provider warms up until it has served Requests requests. Meanwhile it
+// serves at most TrafficShare of the requests, only once it passed a
+// synthetic benchmark when RequireBenchmark is set, and its health is its
+// prior blended with the requests it served. A zero Requests disables warm-up
+type WarmupPolicy struct {
+	Requests         int64   `json:"requests"`
+	TrafficShare     float64 `json:"traffic_share"`
+	RequireBenchmark bool    `json:"require_benchmark"`
+	PriorStrength    float64 `json:"prior_strength"`
+}
+
+// Enabled reports whether providers warm up
+func (p WarmupPolicy) Enabled() bool {
+	return p.Requests > 0
+}
+
+// Warm reports whether a provider that served requests has warmed up
+func (p WarmupPolicy) Warm(requests int64) bool {
+	return !p.Enabled() || requests >= p.Requests
+}
+
+// Prior is the health assumed of a provider before it serves traffic.
+// Strength is how many requests it is worth
+type Prior struct {
+	SuccessRate float64 `json:"success_rate"`
+	LatencyMs   float64 `json:"latency_ms"`
+	Strength    float64 `json:"strength"`
+}
+
+// tierPriors are the success rate and latency expected of each tier
+var tierPriors = map[tier.Tier]Prior{
+	tier.Official:   {SuccessRate: 0.98, LatencyMs: 1500},
+	tier.Community:  {SuccessRate: 0.9, LatencyMs: 3000},
+	tier.Unofficial: {SuccessRate: 0.8, LatencyMs: 5000},
+}
+
+// Prior derives the prior of a provider from its tier and the model
+// database's assessment of its models. The best assessed model scales the
+// tier's success rate by up to a tenth for weak models, and the database's
+// confidence in it sets how much of PriorStrength the prior is worth, at
+// least a third
+func (p WarmupPolicy) Prior(providerTier tier.Tier, models []ModelCapabilities) Prior {
+	prior, ok := tierPriors[providerTier]
+	if !ok {
+		prior = tierPriors[tier.Unofficial]
+	}
+
+	quality, confidence := 0.0, 0.0
+	for _, model := range models {
+		if model.Confidence <= 0 {
+			continue
+		}
+		if q := float64(model.Reasoning+model.Knowledge+model.Computation) / 30; q > quality {
+			quality, confidence = q, model.Confidence
+		}
+	}
+	if confidence > 0 {
+		prior.SuccessRate *= 0.9 + 0.1*math.Min(quality, 1)
+	}
+	prior.Strength = p.PriorStrength * math.Max(math.Min(confidence, 1), 1.0/3)
+	return prior
+}
+
+// HealthEstimate is a provider's health blended from its prior and the
+// requests it served
+type HealthEstimate struct {
+	SuccessRate float64 `json:"success_rate"`
+	LatencyMs   float64 `json:"latency_ms"`
+	Requests    int64   `json:"requests"`
+	Warm        bool    `json:"warm"`
+}
+
+// Estimate blends prior with what a provider that served requests did
+// recently. The success rate is the mean of the Beta posterior whose prior
+// is worth prior.Strength requests; latency is weighted the same way. As
+// observed requests decay the prior takes over again
+func (p WarmupPolicy) Estimate(prior Prior, requests int64, observed WindowedMetrics) HealthEstimate {
+	n := observed.Requests
+	weight := prior.Strength + n
+	estimate := HealthEstimate{
+		SuccessRate: prior.SuccessRate,
+		LatencyMs:   prior.LatencyMs,
+		Requests:    requests,
+		Warm:        p.Warm(requests),
+	}
+	if weight > 0 {
+		estimate.SuccessRate = (prior.SuccessRate*prior.Strength + observed.SuccessRate*n) / weight
+		estimate.LatencyMs = (prior.LatencyMs*prior.Strength + observed.AverageLatencyMs*n) / weight
+	}
+	return estimate
+}
+
+// WarmupTracker spaces out the requests warming providers serve so each
+// stays within its traffic share. It is safe for concurrent use
+type WarmupTracker struct {
+	routed     int64
+	lastServed map[string]int64
+	mutex      sync.Mutex
+}
+
+// NewWarmupTracker creates a tracker that has routed no requests
+func NewWarmupTracker() *WarmupTracker {
+	return &WarmupTracker{lastServed: make(map[string]int64)}
+}
+
+// Admit reports whether a warming provider may serve the next request: its
+// first request always, and later ones once 1/share requests were routed
+// since its last
+func (t *WarmupTracker) Admit(provider string, share float64) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	last, served := t.lastServed[provider]
+	return !served || float64(t.routed-last) >= 1/share
+}
+
+// Record counts a request routed to provider, which is warming or not
+func (t *WarmupTracker) Record(provider string, warming bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.routed++
+	if warming {
+		t.lastServed[provider] = t.routed
+	} else {
+		delete(t.lastServed, provider)
+	}
+}
@@ -0,0 +1,178 @@
+package selection
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/analysis"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
+)
+
+// These tests hammer the selection paths from many goroutines, the way HTTP
+// handlers and background jobs use them. They find races only under
+// go test -race
+
+const (
+	concurrentWorkers    = 16
+	concurrentIterations = 200
+)
+
+// runConcurrently calls fn from concurrentWorkers goroutines, each
+// concurrentIterations times
+func runConcurrently(t *testing.T, fn func(worker, iteration int)) {
+	t.Helper()
+	var wg sync.WaitGroup
+	for worker := 0; worker < concurrentWorkers; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for iteration := 0; iteration < concurrentIterations; iteration++ {
+				fn(worker, iteration)
+			}
+		}(worker)
+	}
+	wg.Wait()
+}
+
+// newTestSelector builds a selector over two CSV providers without reading
+// files or calling providers
+func newTestSelector() *EnhancedAdaptiveSelector {
+	detector := NewCapabilityDetector()
+	selector := &EnhancedAdaptiveSelector{
+		csvProviders: []config.CSVProvider{
+			{Name: "OpenAI", Tier: tier.Official, ModelsSource: "gpt-4o|gpt-4o-mini"},
+			{Name: "Pollinations", Tier: tier.Community, ModelsSource: "openai|mistral"},
+		},
+		enhancedConfigs:      make(map[string]*config.ProviderConfig),
+		providerCapabilities: make(map[string]ProviderCapabilities),
+		performanceData:      make(map[string]*ProviderMetrics),
+		modelAliases:         DefaultModelAliases(),
+		modelDatabase:        NewModelDatabase(),
+		capabilityDetector:   detector,
+		weights:              DefaultSelectionWeights,
+	}
+	selector.providerCapabilities["openai"] = detector.DetectCapabilities([]string{"gpt-4o", "gpt-4o-mini"})
+	selector.providerCapabilities["pollinations"] = detector.DetectCapabilities([]string{"openai", "mistral"})
+	return selector
+}
+
+func TestEnhancedAdaptiveSelectorConcurrentSelection(t *testing.T) {
+	selector := newTestSelector()
+	complexity := analysis.TaskComplexity{Reasoning: 0.5, Knowledge: 0.5, Overall: 0.5, Score: 0.5}
+
+	runConcurrently(t, func(worker, iteration int) {
+		switch worker % 4 {
+		case 0:
+			if _, err := selector.SelectProvider(complexity, map[string]interface{}{"task_type": "text"}); err != nil {
+				t.Errorf("SelectProvider: %v", err)
+			}
+		case 1:
+			providerID := []string{"openai", "pollinations"}[iteration%2]
+			selector.UpdateProviderMetrics(providerID, time.Duration(iteration)*time.Millisecond, iteration%5 != 0, 0.8)
+		case 2:
+			for providerID, metrics := range selector.GetProviderMetrics() {
+				if metrics.TotalRequests < 0 {
+					t.Errorf("%s: negative request count", providerID)
+				}
+			}
+		case 3:
+			if iteration%20 == 0 {
+				selector.SetWeights(DefaultSelectionWeights)
+				selector.SetParetoPolicy([]ParetoPolicy{ParetoOff, ParetoBalanced}[iteration%2])
+			}
+			selector.GetProviderCapabilities()
+		}
+	})
+
+	metrics := selector.GetProviderMetrics()
+	var total int
+	for _, m := range metrics {
+		total += m.TotalRequests
+	}
+	if want := concurrentIterations * concurrentWorkers / 4; total != want {
+		t.Errorf("recorded %d requests, want %d", total, want)
+	}
+}
+
+func TestGetProviderMetricsReturnsCopies(t *testing.T) {
+	selector := newTestSelector()
+	selector.UpdateProviderMetrics("openai", 10*time.Millisecond, true, 0.9)
+
+	snapshot := selector.GetProviderMetrics()
+	selector.UpdateProviderMetrics("openai", 10*time.Millisecond, false, 0.9)
+
+	if got := snapshot["openai"].TotalRequests; got != 1 {
+		t.Errorf("snapshot changed after an update: %d requests, want 1", got)
+	}
+}
+
+func TestLoadTrackerConcurrentAcquire(t *testing.T) {
+	tracker := NewLoadTracker()
+	runConcurrently(t, func(worker, iteration int) {
+		providerID := fmt.Sprintf("provider-%d", worker%3)
+		release := tracker.Acquire(providerID)
+		tracker.InFlight(providerID)
+		tracker.Snapshot()
+		release()
+		release()
+	})
+	if snapshot := tracker.Snapshot(); len(snapshot) != 0 {
+		t.Errorf("requests still in flight after all releases: %v", snapshot)
+	}
+}
+
+func TestTieBreakerConcurrentChoose(t *testing.T) {
+	candidates := []TieCandidate{{ProviderID: "a", Weight: 1}, {ProviderID: "b", Weight: 1}, {ProviderID: "c", Weight: 2, Load: 1}}
+	for _, strategy := range []TieBreakStrategy{TieBreakRoundRobin, TieBreakLeastLoaded} {
+		t.Run(string(strategy), func(t *testing.T) {
+			tieBreaker := NewTieBreaker(strategy, 0.01)
+			runConcurrently(t, func(worker, iteration int) {
+				if chosen := tieBreaker.Choose(candidates); chosen < 0 || chosen >= len(candidates) {
+					t.Errorf("chose candidate %d of %d", chosen, len(candidates))
+				}
+			})
+		})
+	}
+}
+
+func TestModelAliasesConcurrentResolve(t *testing.T) {
+	aliases := DefaultModelAliases()
+	models := []string{"gpt-4", "claude-3-opus", "palmoe/auto", ""}
+	runConcurrently(t, func(worker, iteration int) {
+		aliases.Resolve(models[(worker+iteration)%len(models)])
+		if iteration%10 == 0 {
+			aliases.Usage()
+			aliases.Aliases()
+		}
+	})
+}
+
+func TestDynamicModelLoaderConcurrentFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"data": [{"id": "model-a"}, {"id": "model-b"}]}`)
+	}))
+	defer server.Close()
+
+	loader := NewDynamicModelLoader()
+	runConcurrently(t, func(worker, iteration int) {
+		switch {
+		case worker == 0 && iteration%50 == 0:
+			loader.ClearCache()
+		case worker == 1:
+			loader.GetCacheStats()
+		default:
+			models, err := loader.LoadModelsFromSource(server.URL + fmt.Sprintf("/models/%d", worker%4))
+			if err != nil {
+				t.Errorf("LoadModelsFromSource: %v", err)
+			} else if len(models) != 2 {
+				t.Errorf("loaded %v, want two models", models)
+			}
+		}
+	})
+}
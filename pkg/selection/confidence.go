@@ -0,0 +1,136 @@
+package selection
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
+)
+
+// LowConfidenceFallback decides what happens to a request whose selection is
+// less confident than the minimum
+type LowConfidenceFallback string
+
+const (
+	// FallbackEscalate selects again among the highest quality tier the
+	// request accepts
+	FallbackEscalate LowConfidenceFallback = "escalate"
+	// FallbackPin refuses the request and asks the client to pin a model
+	FallbackPin LowConfidenceFallback = "pin"
+	// FallbackReject refuses the request as ambiguous, listing the candidates
+	FallbackReject LowConfidenceFallback = "reject"
+)
+
+// lowConfidenceFallbacks lists the valid fallbacks
+var lowConfidenceFallbacks = []LowConfidenceFallback{FallbackEscalate, FallbackPin, FallbackReject}
+
+// ParseLowConfidenceFallback validates a fallback name; "" is escalate
+func ParseLowConfidenceFallback(s string) (LowConfidenceFallback, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if name == "" {
+		return FallbackEscalate, nil
+	}
+	for _, fallback := range lowConfidenceFallbacks {
+		if LowConfidenceFallback(name) == fallback {
+			return fallback, nil
+		}
+	}
+
+	names := make([]string, len(lowConfidenceFallbacks))
+	for i, fallback := range lowConfidenceFallbacks {
+		names[i] = string(fallback)
+	}
+	return FallbackEscalate, fmt.Errorf("unknown low confidence fallback %q: must be one of %s", s, strings.Join(names, ", "))
+}
+
+// ConfidencePolicy is the minimum confidence a selection needs and what to do
+// below it. A zero MinConfidence accepts every selection
+type ConfidencePolicy struct {
+	MinConfidence float64               `json:"min_confidence"`
+	Fallback      LowConfidenceFallback `json:"fallback"`
+}
+
+// Enabled reports whether the policy rejects any selection
+func (p ConfidencePolicy) Enabled() bool {
+	return p.MinConfidence > 0
+}
+
+// Accepts reports whether a selection of confidence meets the policy
+func (p ConfidencePolicy) Accepts(confidence float64) bool {
+	return !p.Enabled() || confidence >= p.MinConfidence
+}
+
+// CalibrateConfidence turns the score of the chosen provider into a
+// confidence from 0 to 1. The score relative to maxScore says how well the
+// provider fits; its margin over the best of the others says how clearly it
+// won. A provider without rivals keeps its fit, one tied with a rival half of it
+func CalibrateConfidence(chosen float64, others []float64, maxScore float64) float64 {
+	if chosen <= 0 || maxScore <= 0 {
+		return 0
+	}
+	fit := math.Min(chosen/maxScore, 1)
+
+	separation := 1.0
+	if len(others) > 0 {
+		runnerUp := math.Inf(-1)
+		for _, score := range others {
+			runnerUp = math.Max(runnerUp, score)
+		}
+		separation = math.Max(0, math.Min((chosen-runnerUp)/chosen, 1))
+	}
+	return fit * (0.5 + 0.5*separation)
+}
+
+// HighestTier returns the highest quality tier of tiers, or of all tiers when
+// tiers is empty
+func HighestTier(tiers []tier.Tier) tier.Tier {
+	if len(tiers) == 0 {
+		tiers = tier.All()
+	}
+	highest := tiers[0]
+	for _, t := range tiers[1:] {
+		if t.Rank() > highest.Rank() {
+			highest = t
+		}
+	}
+	return highest
+}
+
+// RoutingCandidate is a provider a request could have been routed to, with
+// the models a client may pin to choose it
+type RoutingCandidate struct {
+	ProviderID string    `json:"provider_id"`
+	Tier       tier.Tier `json:"tier"`
+	Models     []string  `json:"models,omitempty"`
+}
+
+// AmbiguousRoutingError is returned when selection is less confident than the
+// policy requires and the fallback refuses the request
+type AmbiguousRoutingError struct {
+	Confidence    float64               `json:"confidence"`
+	MinConfidence float64               `json:"min_confidence"`
+	Fallback      LowConfidenceFallback `json:"fallback"`
+	Candidates    []RoutingCandidate    `json:"candidates"`
+}
+
+// Error implements the error interface
+func (e *AmbiguousRoutingError) Error() string {
+	names := make([]string, len(e.Candidates))
+	for i, candidate := range e.Candidates {
+		names[i] = candidate.ProviderID
+	}
+	if e.Fallback == FallbackPin {
+		return fmt.Sprintf("routing confidence %.2f is below %.2f: pin a model served by one of %s", e.Confidence, e.MinConfidence, strings.Join(names, ", "))
+	}
+	return fmt.Sprintf("ambiguous routing: confidence %.2f is below %.2f, candidates %s", e.Confidence, e.MinConfidence, strings.Join(names, ", "))
+}
+
+// Escalation records a selection below the minimum confidence that was
+// replaced by one from a higher tier
+type Escalation struct {
+	From          string    `json:"from"`
+	Confidence    float64   `json:"confidence"`
+	MinConfidence float64   `json:"min_confidence"`
+	Tier          tier.Tier `json:"tier"`
+}
@@ -0,0 +1,564 @@
+package selection
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
+)
+
+// Keys of the constraints map passed to SelectProvider
+const (
+	// ConstraintCostLimit is the maximum estimated cost of the request in USD
+	ConstraintCostLimit = "cost_limit"
+	// ConstraintQualityMin is the minimum quality score, from 0 to 1
+	ConstraintQualityMin = "quality_min"
+	// ConstraintTierPreference lists the acceptable tiers, most preferred first
+	ConstraintTierPreference = "tier_preference"
+	// ConstraintMaxLatency is the latency SLO in milliseconds
+	ConstraintMaxLatency = "max_latency_ms"
+	// ConstraintEstimatedTokens overrides the token estimate used for costing
+	ConstraintEstimatedTokens = "estimated_tokens"
+	// ConstraintParetoPolicy overrides the selector's ParetoPolicy
+	ConstraintParetoPolicy = "pareto_policy"
+	// ConstraintPreferredProviders lists providers ranked ahead of the others
+	// in the same preferred tier
+	ConstraintPreferredProviders = "preferred_providers"
+	// ConstraintModel limits selection to providers serving the named model
+	ConstraintModel = "model"
+	// ConstraintAllowedProviders limits selection to the listed providers,
+	// such as a tenant's provider inventory
+	ConstraintAllowedProviders = "allowed_providers"
+	// ConstraintWeights replaces the selector's score weights for the request
+	ConstraintWeights = "weights"
+	// ConstraintCapabilities is a capability expression every provider must
+	// satisfy, e.g. "code AND (reasoning>=7 OR official)"
+	ConstraintCapabilities = "capabilities"
+)
+
+// defaultCompletionTokens is assumed for the response when max_tokens is unset
+const defaultCompletionTokens = 512
+
+// RequestConstraints are per-request limits every selected provider must meet.
+// Zero values mean no limit. ParetoPolicy, when set, replaces the selector's
+// policy for the request. PreferredProviders only reorders providers and never
+// excludes one; AllowedProviders, when set, excludes every provider it does not
+// list. Model is matched against provider model lists by NormalizeModelName,
+// so "gpt-4o" is served by a provider listing "gpt-4o-2024-08-06". Weights,
+// when set, replaces the selector's score weights and excludes no provider.
+// Capabilities, when set, excludes providers that do not satisfy it
+type RequestConstraints struct {
+	CostLimit          float64
+	QualityMin         float64
+	TierPreference     []tier.Tier
+	MaxLatency         time.Duration
+	ParetoPolicy       ParetoPolicy
+	PreferredProviders []string
+	AllowedProviders   []string
+	Model              string
+	Weights            *SelectionWeights
+	Capabilities       *CapabilityExpr
+}
+
+// Candidate holds the facts about a scored provider that constraints are
+// checked against. A zero Latency means no latency has been observed yet, and
+// nil Capabilities satisfy no capability expression
+type Candidate struct {
+	ProviderID    string
+	Tier          tier.Tier
+	EstimatedCost float64
+	Quality       float64
+	Latency       time.Duration
+	Models        []string
+	Capabilities  CapabilityFacts
+}
+
+// Rejection explains why a constraint excluded a provider
+type Rejection struct {
+	ProviderID string `json:"provider_id"`
+	Constraint string `json:"constraint"`
+	Reason     string `json:"reason"`
+}
+
+// ConstraintError is returned when capable providers exist but none of them
+// satisfies the request constraints
+type ConstraintError struct {
+	Constraints RequestConstraints `json:"constraints"`
+	Rejections  []Rejection        `json:"rejections"`
+}
+
+// Error implements the error interface
+func (e *ConstraintError) Error() string {
+	reasons := make([]string, len(e.Rejections))
+	for i, rejection := range e.Rejections {
+		reasons[i] = rejection.ProviderID + ": " + rejection.Reason
+	}
+	return "no provider satisfies the request constraints: " + strings.Join(reasons, "; ")
+}
+
+// ParseConstraints reads the constraint keys from a constraints map. Numbers
+// may be given as any numeric type or string, tiers as a list or a comma
+// separated string, and the latency SLO in milliseconds or as a duration
+func ParseConstraints(constraints map[string]interface{}) (RequestConstraints, error) {
+	var parsed RequestConstraints
+	var err error
+
+	if value, ok := constraints[ConstraintCostLimit]; ok {
+		if parsed.CostLimit, err = constraintFloat(value); err != nil || parsed.CostLimit < 0 {
+			return parsed, fmt.Errorf("invalid %s %v: must be a non-negative number", ConstraintCostLimit, value)
+		}
+	}
+
+	if value, ok := constraints[ConstraintQualityMin]; ok {
+		if parsed.QualityMin, err = constraintFloat(value); err != nil || parsed.QualityMin < 0 || parsed.QualityMin > 1 {
+			return parsed, fmt.Errorf("invalid %s %v: must be between 0 and 1", ConstraintQualityMin, value)
+		}
+	}
+
+	if value, ok := constraints[ConstraintTierPreference]; ok {
+		if parsed.TierPreference, err = constraintTiers(value); err != nil {
+			return parsed, fmt.Errorf("invalid %s: %w", ConstraintTierPreference, err)
+		}
+	}
+
+	if value, ok := constraints[ConstraintMaxLatency]; ok {
+		if parsed.MaxLatency, err = constraintLatency(value); err != nil || parsed.MaxLatency < 0 {
+			return parsed, fmt.Errorf("invalid %s %v: must be a non-negative number of milliseconds", ConstraintMaxLatency, value)
+		}
+	}
+
+	if value, ok := constraints[ConstraintParetoPolicy]; ok {
+		name, isString := value.(string)
+		if policy, isPolicy := value.(ParetoPolicy); isPolicy {
+			name, isString = string(policy), true
+		}
+		if !isString {
+			return parsed, fmt.Errorf("invalid %s %v: must be a string", ConstraintParetoPolicy, value)
+		}
+		if parsed.ParetoPolicy, err = ParseParetoPolicy(name); err != nil {
+			return parsed, err
+		}
+	}
+
+	if value, ok := constraints[ConstraintModel]; ok {
+		model, isString := value.(string)
+		if !isString {
+			return parsed, fmt.Errorf("invalid %s %v: must be a string", ConstraintModel, value)
+		}
+		parsed.Model = strings.TrimSpace(model)
+	}
+
+	if value, ok := constraints[ConstraintPreferredProviders]; ok {
+		if parsed.PreferredProviders, err = constraintStrings(value); err != nil {
+			return parsed, fmt.Errorf("invalid %s: %w", ConstraintPreferredProviders, err)
+		}
+	}
+
+	if value, ok := constraints[ConstraintAllowedProviders]; ok {
+		if parsed.AllowedProviders, err = constraintStrings(value); err != nil {
+			return parsed, fmt.Errorf("invalid %s: %w", ConstraintAllowedProviders, err)
+		}
+	}
+
+	if value, ok := constraints[ConstraintWeights]; ok {
+		if parsed.Weights, err = constraintWeights(value); err != nil {
+			return parsed, fmt.Errorf("invalid %s: %w", ConstraintWeights, err)
+		}
+	}
+
+	if value, ok := constraints[ConstraintCapabilities]; ok {
+		switch expr := value.(type) {
+		case *CapabilityExpr:
+			parsed.Capabilities = expr
+		case string:
+			if parsed.Capabilities, err = ParseCapabilityExpr(expr); err != nil {
+				return parsed, fmt.Errorf("invalid %s: %w", ConstraintCapabilities, err)
+			}
+		default:
+			return parsed, fmt.Errorf("invalid %s %v: must be a string", ConstraintCapabilities, value)
+		}
+	}
+
+	return parsed, nil
+}
+
+// Map returns the constraints in the form accepted by SelectProvider
+func (c RequestConstraints) Map() map[string]interface{} {
+	constraints := make(map[string]interface{})
+	if c.CostLimit > 0 {
+		constraints[ConstraintCostLimit] = c.CostLimit
+	}
+	if c.QualityMin > 0 {
+		constraints[ConstraintQualityMin] = c.QualityMin
+	}
+	if len(c.TierPreference) > 0 {
+		constraints[ConstraintTierPreference] = c.TierPreference
+	}
+	if c.MaxLatency > 0 {
+		constraints[ConstraintMaxLatency] = c.MaxLatency.Milliseconds()
+	}
+	if c.ParetoPolicy != ParetoOff {
+		constraints[ConstraintParetoPolicy] = string(c.ParetoPolicy)
+	}
+	if len(c.PreferredProviders) > 0 {
+		constraints[ConstraintPreferredProviders] = c.PreferredProviders
+	}
+	if len(c.AllowedProviders) > 0 {
+		constraints[ConstraintAllowedProviders] = c.AllowedProviders
+	}
+	if c.Model != "" {
+		constraints[ConstraintModel] = c.Model
+	}
+	if c.Weights != nil {
+		constraints[ConstraintWeights] = *c.Weights
+	}
+	if c.Capabilities != nil {
+		constraints[ConstraintCapabilities] = c.Capabilities.String()
+	}
+	return constraints
+}
+
+// MarshalJSON encodes the constraints with the same keys as the constraints map
+func (c RequestConstraints) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.Map())
+}
+
+// IsZero reports whether no limit, provider preference, provider allow list,
+// model, weights or capability expression are set
+func (c RequestConstraints) IsZero() bool {
+	return c.CostLimit == 0 && c.QualityMin == 0 && len(c.TierPreference) == 0 && c.MaxLatency == 0 &&
+		len(c.PreferredProviders) == 0 && len(c.AllowedProviders) == 0 && c.Model == "" && c.Weights == nil &&
+		c.Capabilities == nil
+}
+
+// Check returns every constraint the candidate violates
+func (c RequestConstraints) Check(candidate Candidate) []Rejection {
+	var rejections []Rejection
+	reject := func(constraint, format string, args ...interface{}) {
+		rejections = append(rejections, Rejection{
+			ProviderID: candidate.ProviderID,
+			Constraint: constraint,
+			Reason:     fmt.Sprintf(format, args...),
+		})
+	}
+
+	if len(c.AllowedProviders) > 0 && !containsFold(c.AllowedProviders, candidate.ProviderID) {
+		reject(ConstraintAllowedProviders, "provider is not in the allowed providers %s", strings.Join(c.AllowedProviders, ", "))
+	}
+	if _, served := MatchModel(candidate.Models, c.Model); c.Model != "" && !served {
+		reject(ConstraintModel, "model %s is not served", c.Model)
+	}
+	if c.Preference(candidate.Tier) < 0 {
+		reject(ConstraintTierPreference, "tier %s is not in the preferred tiers %s", candidate.Tier, joinTiers(c.TierPreference))
+	}
+	if c.CostLimit > 0 && candidate.EstimatedCost > c.CostLimit {
+		reject(ConstraintCostLimit, "estimated cost $%.6f exceeds the limit of $%.6f", candidate.EstimatedCost, c.CostLimit)
+	}
+	if quality := math.Min(candidate.Quality, 1); c.QualityMin > 0 && quality < c.QualityMin {
+		reject(ConstraintQualityMin, "quality score %.2f is below the minimum of %.2f", quality, c.QualityMin)
+	}
+	if c.MaxLatency > 0 && candidate.Latency > c.MaxLatency {
+		reject(ConstraintMaxLatency, "average latency %v exceeds the SLO of %v", candidate.Latency.Round(time.Millisecond), c.MaxLatency)
+	}
+	if c.Capabilities != nil && (candidate.Capabilities == nil || !c.Capabilities.Eval(candidate.Capabilities)) {
+		reject(ConstraintCapabilities, "capabilities do not satisfy %s", c.Capabilities)
+	}
+
+	return rejections
+}
+
+// Preference returns the position of t in the tier preference, 0 being the
+// most preferred, or -1 when t is not acceptable. Every tier is acceptable
+// when no preference is set
+func (c RequestConstraints) Preference(t tier.Tier) int {
+	if len(c.TierPreference) == 0 {
+		return 0
+	}
+	for i, preferred := range c.TierPreference {
+		if preferred == t {
+			return i
+		}
+	}
+	return -1
+}
+
+// Rank orders acceptable providers: the tier preference first, then listed
+// PreferredProviders ahead of unlisted ones. Lower ranks are selected first
+// and providers of equal rank compete on score
+func (c RequestConstraints) Rank(providerTier tier.Tier, providerID string) int {
+	rank := c.Preference(providerTier) * 2
+	if len(c.PreferredProviders) == 0 {
+		return rank
+	}
+	for _, preferred := range c.PreferredProviders {
+		if strings.EqualFold(preferred, providerID) {
+			return rank
+		}
+	}
+	return rank + 1
+}
+
+// String describes the constraints for selection reasoning
+func (c RequestConstraints) String() string {
+	var parts []string
+	if c.CostLimit > 0 {
+		parts = append(parts, fmt.Sprintf("cost <= $%.6f", c.CostLimit))
+	}
+	if c.QualityMin > 0 {
+		parts = append(parts, fmt.Sprintf("quality >= %.2f", c.QualityMin))
+	}
+	if len(c.TierPreference) > 0 {
+		parts = append(parts, "tiers "+joinTiers(c.TierPreference))
+	}
+	if c.MaxLatency > 0 {
+		parts = append(parts, fmt.Sprintf("latency <= %v", c.MaxLatency))
+	}
+	if len(c.PreferredProviders) > 0 {
+		parts = append(parts, "preferring "+strings.Join(c.PreferredProviders, ", "))
+	}
+	if c.Model != "" {
+		parts = append(parts, "model "+c.Model)
+	}
+	if c.Weights != nil {
+		parts = append(parts, "weights "+c.Weights.String())
+	}
+	if c.Capabilities != nil {
+		parts = append(parts, "capabilities "+c.Capabilities.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// scoredCandidate pairs a provider score with its constraint facts
+type scoredCandidate struct {
+	score     ProviderScore
+	candidate Candidate
+}
+
+// applyConstraints drops candidates that violate the constraints, rescores the
+// rest when the constraints carry weights and orders them by rank, then by
+// total score. A *ConstraintError lists
+// the rejections when nothing is left
+func applyConstraints(candidates []scoredCandidate, constraints RequestConstraints) ([]scoredCandidate, []Rejection, error) {
+	var accepted []scoredCandidate
+	var rejections []Rejection
+	for _, c := range candidates {
+		if rejected := constraints.Check(c.candidate); len(rejected) > 0 {
+			rejections = append(rejections, rejected...)
+			continue
+		}
+		if constraints.Weights != nil {
+			c.score.TotalScore = constraints.Weights.Total(c.score)
+		}
+		accepted = append(accepted, c)
+	}
+
+	if len(accepted) == 0 && len(rejections) > 0 {
+		sort.SliceStable(rejections, func(i, j int) bool {
+			return rejections[i].ProviderID < rejections[j].ProviderID
+		})
+		return nil, rejections, &ConstraintError{Constraints: constraints, Rejections: rejections}
+	}
+
+	sort.SliceStable(accepted, func(i, j int) bool {
+		pi := constraints.Rank(accepted[i].candidate.Tier, accepted[i].candidate.ProviderID)
+		pj := constraints.Rank(accepted[j].candidate.Tier, accepted[j].candidate.ProviderID)
+		if pi != pj {
+			return pi < pj
+		}
+		return accepted[i].score.TotalScore > accepted[j].score.TotalScore
+	})
+	return accepted, rejections, nil
+}
+
+// estimateRequestTokens estimates prompt plus completion tokens from the
+// constraints map, using roughly four characters per token for the prompt
+func estimateRequestTokens(constraints map[string]interface{}) float64 {
+	if value, ok := constraints[ConstraintEstimatedTokens]; ok {
+		if tokens, err := constraintFloat(value); err == nil && tokens > 0 {
+			return tokens
+		}
+	}
+
+	completion := float64(defaultCompletionTokens)
+	if value, ok := constraints["max_tokens"]; ok {
+		if tokens, err := constraintFloat(value); err == nil && tokens > 0 {
+			completion = tokens
+		}
+	}
+
+	prompt := 0.0
+	if content, ok := constraints["content"].(string); ok {
+		prompt = math.Ceil(float64(len(content)) / 4)
+	}
+	return prompt + completion
+}
+
+// constraintFloat converts a numeric constraint value
+func constraintFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(v), 64)
+	default:
+		return 0, fmt.Errorf("unsupported type %T", value)
+	}
+}
+
+// constraintTiers converts a tier preference value, rejecting unknown and
+// repeated tiers
+func constraintTiers(value interface{}) ([]tier.Tier, error) {
+	var names []string
+	switch v := value.(type) {
+	case []tier.Tier:
+		for _, t := range v {
+			names = append(names, string(t))
+		}
+	case []string:
+		names = v
+	case []interface{}:
+		for _, item := range v {
+			name, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("tier %v is not a string", item)
+			}
+			names = append(names, name)
+		}
+	case string:
+		names = strings.Split(v, ",")
+	default:
+		return nil, fmt.Errorf("unsupported type %T", value)
+	}
+
+	tiers := make([]tier.Tier, 0, len(names))
+	seen := make(map[tier.Tier]bool)
+	for _, name := range names {
+		t, err := tier.Parse(name)
+		if err != nil {
+			return nil, err
+		}
+		if seen[t] {
+			return nil, fmt.Errorf("tier %s is listed more than once", t)
+		}
+		seen[t] = true
+		tiers = append(tiers, t)
+	}
+	return tiers, nil
+}
+
+// constraintStrings converts a list of names given as a list or a comma
+// separated string
+func constraintStrings(value interface{}) ([]string, error) {
+	var names []string
+	switch v := value.(type) {
+	case []string:
+		names = v
+	case []interface{}:
+		for _, item := range v {
+			name, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%v is not a string", item)
+			}
+			names = append(names, name)
+		}
+	case string:
+		names = strings.Split(v, ",")
+	default:
+		return nil, fmt.Errorf("unsupported type %T", value)
+	}
+
+	cleaned := make([]string, 0, len(names))
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			cleaned = append(cleaned, name)
+		}
+	}
+	return cleaned, nil
+}
+
+// constraintWeights converts score weights given as SelectionWeights or as a
+// map of the weight names to numbers, and normalizes them
+func constraintWeights(value interface{}) (*SelectionWeights, error) {
+	var weights SelectionWeights
+	switch v := value.(type) {
+	case SelectionWeights:
+		weights = v
+	case *SelectionWeights:
+		if v == nil {
+			return nil, nil
+		}
+		weights = *v
+	case map[string]interface{}:
+		fields := map[string]*float64{
+			"cost":        &weights.Cost,
+			"quality":     &weights.Quality,
+			"latency":     &weights.Latency,
+			"reliability": &weights.Reliability,
+		}
+		for name, raw := range v {
+			field, known := fields[name]
+			if !known {
+				return nil, fmt.Errorf("unknown weight %q: must be cost, quality, latency or reliability", name)
+			}
+			number, err := constraintFloat(raw)
+			if err != nil {
+				return nil, fmt.Errorf("weight %s: %w", name, err)
+			}
+			*field = number
+		}
+	default:
+		return nil, fmt.Errorf("unsupported type %T", value)
+	}
+	normalized, err := weights.Normalized()
+	if err != nil {
+		return nil, err
+	}
+	return &normalized, nil
+}
+
+// constraintLatency converts a latency SLO given in milliseconds or as a duration
+func constraintLatency(value interface{}) (time.Duration, error) {
+	switch v := value.(type) {
+	case time.Duration:
+		return v, nil
+	case string:
+		if d, err := time.ParseDuration(strings.TrimSpace(v)); err == nil {
+			return d, nil
+		}
+	}
+	ms, err := constraintFloat(value)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(ms * float64(time.Millisecond)), nil
+}
+
+// joinTiers formats a tier list for messages
+func joinTiers(tiers []tier.Tier) string {
+	names := make([]string, len(tiers))
+	for i, t := range tiers {
+		names[i] = string(t)
+	}
+	return "[" + strings.Join(names, ", ") + "]"
+}
+
+// containsFold reports whether names holds name, ignoring case
+func containsFold(names []string, name string) bool {
+	for _, candidate := range names {
+		if strings.EqualFold(candidate, name) {
+			return true
+		}
+	}
+	return false
+}
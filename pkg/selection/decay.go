@@ -0,0 +1,89 @@
+package selection
+
+import (
+	"math"
+	"time"
+)
+
+// DefaultMetricsHalfLife is how long it takes a request's weight in windowed
+// metrics to halve
+const DefaultMetricsHalfLife = 6 * time.Hour
+
+// WindowedStats sum request outcomes with each request's weight halving
+// every half-life, so what a provider did long ago fades out. A zero
+// half-life never decays. The zero value is ready to use
+type WindowedStats struct {
+	halfLife  time.Duration
+	weight    float64
+	failures  float64
+	latencyMs float64
+	updatedAt time.Time
+}
+
+// NewWindowedStats creates empty stats that decay with halfLife
+func NewWindowedStats(halfLife time.Duration) *WindowedStats {
+	return &WindowedStats{halfLife: halfLife}
+}
+
+// Seed sets the stats to requests that ended at, failed of them, with an
+// average latency, e.g. restored totals last updated at
+func (s *WindowedStats) Seed(at time.Time, requests, failed int64, averageLatencyMs float64) {
+	s.weight = float64(requests)
+	s.failures = float64(failed)
+	s.latencyMs = averageLatencyMs * float64(requests)
+	s.updatedAt = at
+}
+
+// Record adds a request that ended at
+func (s *WindowedStats) Record(at time.Time, latency time.Duration, success bool) {
+	s.decay(at)
+	s.weight++
+	if !success {
+		s.failures++
+	}
+	s.latencyMs += float64(latency.Milliseconds())
+}
+
+// decay ages the sums to at. Time never runs backwards for the stats
+func (s *WindowedStats) decay(at time.Time) {
+	if s.halfLife > 0 && !s.updatedAt.IsZero() && at.After(s.updatedAt) {
+		factor := math.Exp2(-float64(at.Sub(s.updatedAt)) / float64(s.halfLife))
+		s.weight *= factor
+		s.failures *= factor
+		s.latencyMs *= factor
+	}
+	if at.After(s.updatedAt) {
+		s.updatedAt = at
+	}
+}
+
+// At returns the windowed metrics as of now
+func (s *WindowedStats) At(now time.Time) WindowedMetrics {
+	aged := *s
+	aged.decay(now)
+	metrics := WindowedMetrics{HalfLife: aged.halfLife.String(), Requests: aged.weight}
+	if aged.weight > 0 {
+		metrics.SuccessRate = 1 - aged.failures/aged.weight
+		metrics.AverageLatencyMs = aged.latencyMs / aged.weight
+	}
+	return metrics
+}
+
+// WindowedMetrics are a provider's recent request outcomes. Requests is the
+// decayed count: a request one half-life old counts as half of one
+type WindowedMetrics struct {
+	HalfLife         string  `json:"half_life"`
+	Requests         float64 `json:"requests"`
+	SuccessRate      float64 `json:"success_rate"`
+	AverageLatencyMs float64 `json:"average_latency_ms"`
+}
+
+// Blend moves a score measured over weight requests toward the neutral score
+// given when nothing is recorded, so scores measured on few or decayed
+// requests count for little
+func Blend(measured, neutral, weight float64) float64 {
+	if weight <= 0 {
+		return neutral
+	}
+	return neutral + (measured-neutral)*weight/(weight+1)
+}
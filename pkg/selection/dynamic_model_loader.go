@@ -6,14 +6,15 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
-	"log"
 )
 
 // DynamicModelLoader handles fetching models from provider URLs
 type DynamicModelLoader struct {
 	httpClient *http.Client
 	cache      map[string]CachedModels
+	mutex      sync.RWMutex
 }
 
 // CachedModels stores models with expiration
@@ -47,14 +48,17 @@ func (dml *DynamicModelLoader) LoadModelsFromSource(source string) ([]string, er
 // fetchModelsFromURL fetches models from a URL endpoint
 func (dml *DynamicModelLoader) fetchModelsFromURL(url string) ([]string, error) {
 	// Check cache first
-	if cached, exists := dml.cache[url]; exists {
+	dml.mutex.RLock()
+	cached, exists := dml.cache[url]
+	dml.mutex.RUnlock()
+	if exists {
 		if time.Since(cached.FetchedAt) < cached.TTL {
-			log.Printf("🔄 Using cached models for %s (%d models)", url, len(cached.Models))
+			logger.Debugf("Using cached models for %s (%d models)", url, len(cached.Models))
 			return cached.Models, nil
 		}
 	}
 	
-	log.Printf("🌐 Fetching models from URL: %s", url)
+	logger.Debugf("Fetching models from URL: %s", url)
 	
 	resp, err := dml.httpClient.Get(url)
 	if err != nil {
@@ -77,13 +81,15 @@ func (dml *DynamicModelLoader) fetchModelsFromURL(url string) ([]string, error)
 	}
 	
 	// Cache the results
+	dml.mutex.Lock()
 	dml.cache[url] = CachedModels{
 		Models:    models,
 		FetchedAt: time.Now(),
 		TTL:       5 * time.Minute, // Cache for 5 minutes
 	}
+	dml.mutex.Unlock()
 	
-	log.Printf("✅ Fetched %d models from %s", len(models), url)
+	logger.Infof("Fetched %d models from %s", len(models), url)
 	return models, nil
 }
 
@@ -198,12 +204,17 @@ func (dml *DynamicModelLoader) parseStaticModels(source string) []string {
 
 // ClearCache clears the model cache
 func (dml *DynamicModelLoader) ClearCache() {
+	dml.mutex.Lock()
+	defer dml.mutex.Unlock()
 	dml.cache = make(map[string]CachedModels)
-	log.Println("🔄 Dynamic model loader cache cleared")
+	logger.Debug("Dynamic model loader cache cleared")
 }
 
 // GetCacheStats returns cache statistics
 func (dml *DynamicModelLoader) GetCacheStats() map[string]interface{} {
+	dml.mutex.RLock()
+	defer dml.mutex.RUnlock()
+
 	stats := map[string]interface{}{
 		"cached_urls": len(dml.cache),
 		"cache_entries": make([]map[string]interface{}, 0),
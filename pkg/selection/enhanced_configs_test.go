@@ -0,0 +1,41 @@
+package selection
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestLoadEnhancedConfigsSkipsInvalidOverrides checks that overrides outside
+// the 1-10 scale are ignored without failing the other provider configs
+func TestLoadEnhancedConfigsSkipsInvalidOverrides(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"openai.yaml":       "id: openai\nname: OpenAI\ncapability_overrides:\n  reasoning: 42\n",
+		"pollinations.yaml": "id: pollinations\nname: Pollinations\ncapability_overrides:\n  reasoning: 3\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	selector := newTestSelector()
+	detected := selector.providerCapabilities["openai"].Reasoning
+	if err := selector.loadEnhancedConfigs(dir); err != nil {
+		t.Fatalf("loadEnhancedConfigs: %v", err)
+	}
+
+	if selector.enhancedConfigs["openai"] == nil {
+		t.Fatal("config with invalid overrides was not loaded")
+	}
+	if selector.enhancedConfigs["openai"].CapabilityOverrides != nil {
+		t.Error("invalid overrides were kept")
+	}
+	if got := selector.providerCapabilities["openai"].Reasoning; got != detected {
+		t.Errorf("reasoning of openai = %d, want the detected %d", got, detected)
+	}
+	if got := selector.providerCapabilities["pollinations"].Reasoning; got != 3 {
+		t.Errorf("reasoning of pollinations = %d, want the override 3", got)
+	}
+}
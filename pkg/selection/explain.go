@@ -0,0 +1,22 @@
+package selection
+
+import "context"
+
+// explainContextKey is the unexported type for the explain context value
+type explainContextKey struct{}
+
+// WithExplain returns a copy of ctx asking selection to explain its choice.
+// Scoring skips building reasoning for requests without it, which at high
+// request rates is most of what it allocates
+func WithExplain(ctx context.Context) context.Context {
+	return context.WithValue(ctx, explainContextKey{}, true)
+}
+
+// ExplainFromContext reports whether ctx asks selection to explain its choice
+func ExplainFromContext(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	explain, _ := ctx.Value(explainContextKey{}).(bool)
+	return explain
+}
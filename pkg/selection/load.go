@@ -0,0 +1,80 @@
+package selection
+
+import "sync"
+
+// Defaults for load-aware scoring
+const (
+	// DefaultLoadCapacity is how many concurrent requests a provider is
+	// assumed to handle before it counts as fully loaded
+	DefaultLoadCapacity = 10
+	// DefaultMaxLoadPenalty is the score a fully loaded provider loses
+	DefaultMaxLoadPenalty = 0.3
+)
+
+// LoadTracker counts the requests in flight on each provider. It is safe for
+// concurrent use
+type LoadTracker struct {
+	inFlight map[string]int
+	mutex    sync.Mutex
+}
+
+// NewLoadTracker creates an empty load tracker
+func NewLoadTracker() *LoadTracker {
+	return &LoadTracker{inFlight: make(map[string]int)}
+}
+
+// Acquire counts a request in flight on a provider until the returned
+// release is called; calling release more than once has no further effect
+func (lt *LoadTracker) Acquire(providerID string) (release func()) {
+	lt.mutex.Lock()
+	lt.inFlight[providerID]++
+	lt.mutex.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			lt.mutex.Lock()
+			defer lt.mutex.Unlock()
+			if lt.inFlight[providerID]--; lt.inFlight[providerID] <= 0 {
+				delete(lt.inFlight, providerID)
+			}
+		})
+	}
+}
+
+// InFlight returns the number of requests in flight on a provider
+func (lt *LoadTracker) InFlight(providerID string) int {
+	lt.mutex.Lock()
+	defer lt.mutex.Unlock()
+	return lt.inFlight[providerID]
+}
+
+// Snapshot returns the in-flight count of every provider with requests running
+func (lt *LoadTracker) Snapshot() map[string]int {
+	lt.mutex.Lock()
+	defer lt.mutex.Unlock()
+
+	snapshot := make(map[string]int, len(lt.inFlight))
+	for id, count := range lt.inFlight {
+		snapshot[id] = count
+	}
+	return snapshot
+}
+
+// LoadPenalty returns the score a provider loses for inFlight requests out of
+// capacity. The penalty grows with the square of utilization, so lightly
+// loaded providers keep their score and a burst spills over well before a
+// provider saturates; at or beyond capacity it is maxPenalty
+func LoadPenalty(inFlight, capacity int, maxPenalty float64) float64 {
+	if inFlight <= 0 || maxPenalty <= 0 {
+		return 0
+	}
+	if capacity <= 0 {
+		capacity = DefaultLoadCapacity
+	}
+	utilization := float64(inFlight) / float64(capacity)
+	if utilization > 1 {
+		utilization = 1
+	}
+	return maxPenalty * utilization * utilization
+}
@@ -0,0 +1,194 @@
+package selection
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ModelAlias maps a model name clients may still send onto the model that
+// replaced it. Deprecated aliases keep working but produce a warning
+type ModelAlias struct {
+	Alias      string `yaml:"alias" json:"alias"`
+	Target     string `yaml:"target" json:"target"`
+	Deprecated bool   `yaml:"deprecated,omitempty" json:"deprecated,omitempty"`
+	// Sunset is when the provider retired or will retire the alias, e.g. 2024-12-06
+	Sunset string `yaml:"sunset,omitempty" json:"sunset,omitempty"`
+	Note   string `yaml:"note,omitempty" json:"note,omitempty"`
+}
+
+// builtinModelAliases covers renames and retirements announced by providers
+var builtinModelAliases = []ModelAlias{
+	{Alias: "gpt-4-vision-preview", Target: "gpt-4o", Deprecated: true},
+	{Alias: "gpt-4-1106-vision-preview", Target: "gpt-4o", Deprecated: true},
+	{Alias: "gpt-4-1106-preview", Target: "gpt-4-turbo", Deprecated: true},
+	{Alias: "gpt-4-0125-preview", Target: "gpt-4-turbo", Deprecated: true},
+	{Alias: "gpt-4-turbo-preview", Target: "gpt-4-turbo"},
+	{Alias: "gpt-4-32k", Target: "gpt-4o", Deprecated: true},
+	{Alias: "gpt-3.5-turbo-16k", Target: "gpt-3.5-turbo", Deprecated: true},
+	{Alias: "text-davinci-003", Target: "gpt-3.5-turbo", Deprecated: true},
+	{Alias: "claude-2", Target: "claude-3-5-sonnet", Deprecated: true},
+	{Alias: "claude-2.1", Target: "claude-3-5-sonnet", Deprecated: true},
+	{Alias: "claude-instant-1.2", Target: "claude-3-haiku", Deprecated: true},
+}
+
+// ModelResolution is the outcome of resolving a requested model name
+type ModelResolution struct {
+	Requested  string `json:"requested"`
+	Model      string `json:"model"`
+	Aliased    bool   `json:"aliased"`
+	Deprecated bool   `json:"deprecated,omitempty"`
+	Warning    string `json:"warning,omitempty"`
+}
+
+// AliasUsage counts how often an alias was resolved, for admin reporting
+type AliasUsage struct {
+	Alias      string    `json:"alias"`
+	Target     string    `json:"target"`
+	Deprecated bool      `json:"deprecated"`
+	Count      int64     `json:"count"`
+	LastUsed   time.Time `json:"last_used"`
+}
+
+// ModelAliases resolves model aliases and records their use
+type ModelAliases struct {
+	aliases map[string]ModelAlias
+	usage   map[string]*AliasUsage
+	mutex   sync.Mutex
+}
+
+// DefaultModelAliases returns a table holding only the built-in aliases
+func DefaultModelAliases() *ModelAliases {
+	table, err := NewModelAliases(nil)
+	if err != nil {
+		// The built-in table is fixed, so this only fires on a bad edit to it
+		panic(err)
+	}
+	return table
+}
+
+// NewModelAliases builds a table from the built-in aliases overridden by
+// extra. Aliases may chain, but cycles and empty names are rejected
+func NewModelAliases(extra []ModelAlias) (*ModelAliases, error) {
+	aliases := make(map[string]ModelAlias, len(builtinModelAliases)+len(extra))
+	for _, alias := range append(append([]ModelAlias(nil), builtinModelAliases...), extra...) {
+		key := strings.ToLower(strings.TrimSpace(alias.Alias))
+		alias.Target = strings.TrimSpace(alias.Target)
+		if key == "" || alias.Target == "" {
+			return nil, fmt.Errorf("model alias %q: alias and target are required", alias.Alias)
+		}
+		if strings.EqualFold(key, alias.Target) {
+			return nil, fmt.Errorf("model alias %q points to itself", alias.Alias)
+		}
+		alias.Alias = key
+		aliases[key] = alias
+	}
+
+	for key := range aliases {
+		seen := map[string]bool{key: true}
+		for next, ok := aliases[key]; ok; next, ok = aliases[strings.ToLower(next.Target)] {
+			target := strings.ToLower(next.Target)
+			if seen[target] {
+				return nil, fmt.Errorf("model alias %q is part of a cycle", key)
+			}
+			seen[target] = true
+		}
+	}
+
+	return &ModelAliases{aliases: aliases, usage: make(map[string]*AliasUsage)}, nil
+}
+
+// LoadModelAliases reads extra aliases from a YAML file with a top-level
+// "aliases" list and merges them over the built-in ones
+func LoadModelAliases(path string) (*ModelAliases, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model aliases: %w", err)
+	}
+
+	var file struct {
+		Aliases []ModelAlias `yaml:"aliases"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse model aliases %s: %w", path, err)
+	}
+	return NewModelAliases(file.Aliases)
+}
+
+// Resolve follows aliases from model to the current model name and records
+// the use of each alias. Names that are not aliases resolve to themselves
+func (ma *ModelAliases) Resolve(model string) ModelResolution {
+	resolution := ModelResolution{Requested: model, Model: model}
+	if ma == nil || strings.TrimSpace(model) == "" {
+		return resolution
+	}
+
+	ma.mutex.Lock()
+	defer ma.mutex.Unlock()
+
+	var details []string
+	for alias, ok := ma.aliases[strings.ToLower(strings.TrimSpace(resolution.Model))]; ok; alias, ok = ma.aliases[strings.ToLower(resolution.Model)] {
+		resolution.Model = alias.Target
+		resolution.Aliased = true
+		if alias.Deprecated {
+			resolution.Deprecated = true
+			if alias.Sunset != "" {
+				details = append(details, alias.Alias+" sunset "+alias.Sunset)
+			}
+			if alias.Note != "" {
+				details = append(details, alias.Note)
+			}
+		}
+
+		usage, exists := ma.usage[alias.Alias]
+		if !exists {
+			usage = &AliasUsage{Alias: alias.Alias, Target: alias.Target, Deprecated: alias.Deprecated}
+			ma.usage[alias.Alias] = usage
+		}
+		usage.Count++
+		usage.LastUsed = time.Now()
+	}
+
+	if resolution.Deprecated {
+		resolution.Warning = fmt.Sprintf("model %s is deprecated; routed to %s instead", model, resolution.Model)
+		if len(details) > 0 {
+			resolution.Warning += " (" + strings.Join(details, "; ") + ")"
+		}
+	}
+	return resolution
+}
+
+// Aliases returns the alias table sorted by alias
+func (ma *ModelAliases) Aliases() []ModelAlias {
+	aliases := make([]ModelAlias, 0, len(ma.aliases))
+	for _, alias := range ma.aliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Slice(aliases, func(i, j int) bool {
+		return aliases[i].Alias < aliases[j].Alias
+	})
+	return aliases
+}
+
+// Usage returns how often each alias has been resolved, most used first
+func (ma *ModelAliases) Usage() []AliasUsage {
+	ma.mutex.Lock()
+	defer ma.mutex.Unlock()
+
+	usage := make([]AliasUsage, 0, len(ma.usage))
+	for _, u := range ma.usage {
+		usage = append(usage, *u)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Count != usage[j].Count {
+			return usage[i].Count > usage[j].Count
+		}
+		return usage[i].Alias < usage[j].Alias
+	})
+	return usage
+}
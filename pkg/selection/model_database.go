@@ -13,6 +13,7 @@ import (
 // ModelCapabilities represents detailed capabilities of a specific model
 type ModelCapabilities struct {
 	ModelName    string   `json:"model_name"`
+	CanonicalName string  `json:"canonical_name,omitempty"` // Base model the capabilities were looked up as
 	Text         bool     `json:"text"`
 	Image        bool     `json:"image"`
 	Code         bool     `json:"code"`
@@ -26,6 +27,8 @@ type ModelCapabilities struct {
 	Computation  int      `json:"computation"`
 	Confidence   float64  `json:"confidence"` // How confident we are in this assessment
 	Source       string   `json:"source"`     // "huggingface", "manual", "provider_hint"
+	ContextWindow int     `json:"context_window,omitempty"`     // Maximum prompt plus completion tokens, 0 if unknown
+	CostPer1K     float64 `json:"cost_per_1k_tokens,omitempty"` // List price per 1K input tokens in USD, 0 if unknown
 	LastUpdated  time.Time `json:"last_updated"`
 }
 
@@ -83,51 +86,61 @@ func (md *ModelDatabase) initializeKnownModels() {
 		"gpt-4": {
 			ModelName: "gpt-4", Text: true, Code: true, Multimodal: true,
 			PipelineTag: "text-generation", Reasoning: 9, Knowledge: 9, Computation: 8,
-			Confidence: 1.0, Source: "manual",
+			Confidence: 1.0, Source: "manual", ContextWindow: 8192, CostPer1K: 0.03,
 		},
 		"gpt-4-turbo": {
 			ModelName: "gpt-4-turbo", Text: true, Code: true, Multimodal: true,
 			PipelineTag: "text-generation", Reasoning: 9, Knowledge: 9, Computation: 8,
-			Confidence: 1.0, Source: "manual",
+			Confidence: 1.0, Source: "manual", ContextWindow: 128000, CostPer1K: 0.01,
+		},
+		"gpt-4o": {
+			ModelName: "gpt-4o", Text: true, Image: true, Code: true, Multimodal: true,
+			PipelineTag: "text-generation", Reasoning: 9, Knowledge: 9, Computation: 8,
+			Confidence: 1.0, Source: "manual", ContextWindow: 128000, CostPer1K: 0.0025,
+		},
+		"gpt-4o-mini": {
+			ModelName: "gpt-4o-mini", Text: true, Image: true, Code: true, Multimodal: true,
+			PipelineTag: "text-generation", Reasoning: 8, Knowledge: 8, Computation: 7,
+			Confidence: 1.0, Source: "manual", ContextWindow: 128000, CostPer1K: 0.00015,
 		},
 		"gpt-3.5-turbo": {
 			ModelName: "gpt-3.5-turbo", Text: true, Code: true,
 			PipelineTag: "text-generation", Reasoning: 7, Knowledge: 8, Computation: 7,
-			Confidence: 1.0, Source: "manual",
+			Confidence: 1.0, Source: "manual", ContextWindow: 16385, CostPer1K: 0.0005,
 		},
 		"dall-e-3": {
 			ModelName: "dall-e-3", Image: true,
 			PipelineTag: "text-to-image", Reasoning: 6, Knowledge: 7, Computation: 8,
-			Confidence: 1.0, Source: "manual",
+			Confidence: 1.0, Source: "manual", ContextWindow: 4000,
 		},
 		
 		// Anthropic Models
 		"claude-3-5-sonnet": {
 			ModelName: "claude-3-5-sonnet", Text: true, Code: true,
 			PipelineTag: "text-generation", Reasoning: 9, Knowledge: 9, Computation: 8,
-			Confidence: 1.0, Source: "manual",
+			Confidence: 1.0, Source: "manual", ContextWindow: 200000, CostPer1K: 0.003,
 		},
 		"claude-3-haiku": {
 			ModelName: "claude-3-haiku", Text: true, Code: true,
 			PipelineTag: "text-generation", Reasoning: 7, Knowledge: 8, Computation: 7,
-			Confidence: 1.0, Source: "manual",
+			Confidence: 1.0, Source: "manual", ContextWindow: 200000, CostPer1K: 0.00025,
 		},
 		"claude-3-opus": {
 			ModelName: "claude-3-opus", Text: true, Code: true,
 			PipelineTag: "text-generation", Reasoning: 9, Knowledge: 9, Computation: 8,
-			Confidence: 1.0, Source: "manual",
+			Confidence: 1.0, Source: "manual", ContextWindow: 200000, CostPer1K: 0.015,
 		},
 		
 		// Code Models
 		"qwen-coder": {
 			ModelName: "qwen-coder", Text: true, Code: true,
 			PipelineTag: "text-generation", Reasoning: 7, Knowledge: 7, Computation: 9,
-			Confidence: 1.0, Source: "manual",
+			Confidence: 1.0, Source: "manual", ContextWindow: 32768,
 		},
 		"codellama": {
 			ModelName: "codellama", Text: true, Code: true,
 			PipelineTag: "text-generation", Reasoning: 6, Knowledge: 6, Computation: 9,
-			Confidence: 1.0, Source: "manual",
+			Confidence: 1.0, Source: "manual", ContextWindow: 16384,
 		},
 		
 		// Image Models
@@ -151,12 +164,12 @@ func (md *ModelDatabase) initializeKnownModels() {
 		"gpt-4v": {
 			ModelName: "gpt-4v", Text: true, Image: true, Multimodal: true,
 			PipelineTag: "image-to-text", Reasoning: 9, Knowledge: 9, Computation: 8,
-			Confidence: 1.0, Source: "manual",
+			Confidence: 1.0, Source: "manual", ContextWindow: 128000, CostPer1K: 0.01,
 		},
 		"gemini-pro-vision": {
 			ModelName: "gemini-pro-vision", Text: true, Image: true, Multimodal: true,
 			PipelineTag: "image-to-text", Reasoning: 8, Knowledge: 8, Computation: 7,
-			Confidence: 1.0, Source: "manual",
+			Confidence: 1.0, Source: "manual", ContextWindow: 16384,
 		},
 		
 		// Audio Models
@@ -170,17 +183,17 @@ func (md *ModelDatabase) initializeKnownModels() {
 		"llama-2-70b": {
 			ModelName: "llama-2-70b", Text: true, Code: true,
 			PipelineTag: "text-generation", Reasoning: 8, Knowledge: 8, Computation: 7,
-			Confidence: 1.0, Source: "manual",
+			Confidence: 1.0, Source: "manual", ContextWindow: 4096,
 		},
 		"llama-2-13b": {
 			ModelName: "llama-2-13b", Text: true, Code: true,
 			PipelineTag: "text-generation", Reasoning: 7, Knowledge: 7, Computation: 6,
-			Confidence: 1.0, Source: "manual",
+			Confidence: 1.0, Source: "manual", ContextWindow: 4096,
 		},
 		"mistral-7b": {
 			ModelName: "mistral-7b", Text: true, Code: true,
 			PipelineTag: "text-generation", Reasoning: 6, Knowledge: 7, Computation: 6,
-			Confidence: 1.0, Source: "manual",
+			Confidence: 1.0, Source: "manual", ContextWindow: 32768,
 		},
 	}
 	
@@ -194,8 +207,22 @@ func (md *ModelDatabase) initializeKnownModels() {
 	}
 }
 
-// GetModelCapabilities retrieves capabilities for a specific model using multiple sources
+// GetModelCapabilities retrieves capabilities for a specific model using multiple sources.
+// Fine-tuned, dated and quantized names are matched by their base model; the
+// result keeps the original name in ModelName for execution
 func (md *ModelDatabase) GetModelCapabilities(modelName, providerName string) ModelCapabilities {
+	return md.lookupModelCapabilities(modelName, providerName, true)
+}
+
+// LookupModelCapabilities is GetModelCapabilities without the Hugging Face
+// query, for use while a request waits on selection
+func (md *ModelDatabase) LookupModelCapabilities(modelName, providerName string) ModelCapabilities {
+	return md.lookupModelCapabilities(modelName, providerName, false)
+}
+
+// lookupModelCapabilities checks the cache, known models, Hugging Face when
+// remote is set, name patterns and finally provider hints
+func (md *ModelDatabase) lookupModelCapabilities(modelName, providerName string, remote bool) ModelCapabilities {
 	// Normalize model name
 	normalizedName := strings.ToLower(strings.TrimSpace(modelName))
 	
@@ -210,27 +237,42 @@ func (md *ModelDatabase) GetModelCapabilities(modelName, providerName string) Mo
 	}
 	md.mutex.RUnlock()
 	
-	// 2. Check known models database
-	if known, exists := md.knownModels[normalizedName]; exists {
-		md.cacheModel(normalizedName, known)
-		return known
+	// 2. Check known models database, by exact then normalized name
+	lookupNames := modelLookupNames(modelName)
+	for _, name := range lookupNames {
+		if known, exists := md.knownModels[name]; exists {
+			known.ModelName = modelName
+			known.CanonicalName = name
+			md.cacheModel(normalizedName, known)
+			return known
+		}
 	}
 	
 	// 3. Try Hugging Face API
-	if hfCapabilities := md.queryHuggingFace(normalizedName); hfCapabilities.Confidence > 0 {
-		md.cacheModel(normalizedName, hfCapabilities)
-		return hfCapabilities
+	if remote {
+		if hfCapabilities := md.queryHuggingFace(normalizedName); hfCapabilities.Confidence > 0 {
+			md.cacheModel(normalizedName, hfCapabilities)
+			return hfCapabilities
+		}
 	}
 	
-	// 4. Use enhanced pattern matching
-	if patternCapabilities := md.detectFromPatterns(normalizedName); patternCapabilities.Confidence > 0 {
-		md.cacheModel(normalizedName, patternCapabilities)
+	// 4. Use enhanced pattern matching on the base model name
+	canonicalName := lookupNames[len(lookupNames)-1]
+	if patternCapabilities := md.detectFromPatterns(canonicalName); patternCapabilities.Confidence > 0 {
+		patternCapabilities.ModelName = modelName
+		patternCapabilities.CanonicalName = canonicalName
+		if remote {
+			md.cacheModel(normalizedName, patternCapabilities)
+		}
 		return patternCapabilities
 	}
 	
-	// 5. Fallback to provider hints
+	// 5. Fallback to provider hints. Guesses are only cached once Hugging Face
+	// has been asked, so an offline lookup never hides a better answer
 	providerCapabilities := md.inferFromProvider(normalizedName, providerName)
-	md.cacheModel(normalizedName, providerCapabilities)
+	if remote {
+		md.cacheModel(normalizedName, providerCapabilities)
+	}
 	
 	return providerCapabilities
 }
@@ -0,0 +1,96 @@
+package selection
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Model name suffixes that do not change what a model can do. They are
+// stripped repeatedly from the end of a name, so "x-20240620-q4_k_m" loses both
+var (
+	// Release dates: -20241022, -2024-08-06, @20240620, and OpenAI's -0613 / -1106
+	modelDateSuffix = regexp.MustCompile(`(?:[-@]20\d{2}-?\d{2}-?\d{2}|-(?:0[1-9]|1[0-2])(?:0[1-9]|[12]\d|3[01]))$`)
+	// Quantization and packaging: -q4_k_m, -q8_0, -awq, -gptq, -gguf, -int4, -fp16, -4bit
+	modelQuantSuffix = regexp.MustCompile(`[-._](?:q\d+(?:_[a-z0-9]+)*|iq\d+(?:_[a-z0-9]+)*|awq|gptq|gguf|ggml|exl2|mlx|int[48]|fp(?:8|16|32)|bf16|[48]bit)$`)
+	// Version and channel tags: -latest, -v1:0 (Bedrock), :latest (Ollama)
+	modelTagSuffix = regexp.MustCompile(`(?:[-:]latest|-v\d+:\d+)$`)
+)
+
+// NormalizeModelName reduces a provider's model name to the base model used to
+// look up capabilities: fine-tune prefixes, release dates, quantization and
+// channel suffixes are removed. The result is only a lookup key; requests must
+// still be sent with the original name
+func NormalizeModelName(name string) string {
+	normalized := strings.ToLower(strings.TrimSpace(name))
+
+	// OpenAI fine-tunes: ft:gpt-4o-mini-2024-07-18:org:suffix:id
+	if strings.HasPrefix(normalized, "ft:") {
+		parts := strings.Split(normalized, ":")
+		if len(parts) > 1 && parts[1] != "" {
+			normalized = parts[1]
+		}
+	}
+	// Legacy fine-tunes: davinci:ft-org-2023-01-01-00-00-00
+	if i := strings.Index(normalized, ":ft-"); i > 0 {
+		normalized = normalized[:i]
+	}
+	normalized = strings.TrimSuffix(normalized, ".gguf")
+
+	for {
+		stripped := modelTagSuffix.ReplaceAllString(normalized, "")
+		stripped = modelQuantSuffix.ReplaceAllString(stripped, "")
+		stripped = modelDateSuffix.ReplaceAllString(stripped, "")
+		if stripped == normalized || stripped == "" {
+			return normalized
+		}
+		normalized = stripped
+	}
+}
+
+// MatchModel finds the entry of models that serves requested: an exact
+// case-insensitive match first, then one with the same normalized name. The
+// returned name is the provider's own, ready for execution
+func MatchModel(models []string, requested string) (string, bool) {
+	requested = strings.TrimSpace(requested)
+	if requested == "" {
+		return "", false
+	}
+	for _, model := range models {
+		if strings.EqualFold(model, requested) {
+			return model, true
+		}
+	}
+
+	normalized := NormalizeModelName(requested)
+	for _, model := range models {
+		if NormalizeModelName(model) == normalized {
+			return model, true
+		}
+	}
+	return "", false
+}
+
+// modelLookupNames returns the keys to try, most specific first: the name as
+// given, its normalized form, and both without an organization prefix such as
+// "meta-llama/" or "openai/"
+func modelLookupNames(name string) []string {
+	exact := strings.ToLower(strings.TrimSpace(name))
+	names := []string{exact}
+	add := func(candidate string) {
+		for _, existing := range names {
+			if existing == candidate {
+				return
+			}
+		}
+		names = append(names, candidate)
+	}
+
+	normalized := NormalizeModelName(name)
+	add(normalized)
+	for _, candidate := range []string{exact, normalized} {
+		if i := strings.LastIndex(candidate, "/"); i >= 0 && i < len(candidate)-1 {
+			add(NormalizeModelName(candidate[i+1:]))
+		}
+	}
+	return names
+}
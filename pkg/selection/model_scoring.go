@@ -0,0 +1,170 @@
+package selection
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Weights of the per-model score components
+const (
+	modelCapabilityWeight = 0.5
+	modelCostWeight       = 0.3
+	modelContextWeight    = 0.2
+)
+
+// ModelRequest is what a request needs from the model chosen within a provider
+type ModelRequest struct {
+	TaskType TaskType
+	// Level is the reasoning level the task calls for, on the 1-10 capability scale
+	Level int
+	// Tokens is the prompt plus completion size the context window must hold
+	Tokens int64
+	// Explain fills in the reasoning of each score, which scoring skips
+	// otherwise to spare the allocations
+	Explain bool
+}
+
+// ModelLevel maps a task complexity ratio from 0 (trivial) to 1 (hardest)
+// onto the 1-10 reasoning scale used by ModelCapabilities
+func ModelLevel(ratio float64) int {
+	return int(math.Round(3 + 6*math.Max(0, math.Min(ratio, 1))))
+}
+
+// ModelScore explains how well one of a provider's models fits a request
+type ModelScore struct {
+	Model           string  `json:"model"`
+	Score           float64 `json:"score"`
+	CapabilityScore float64 `json:"capability_score"`
+	CostScore       float64 `json:"cost_score"`
+	ContextScore    float64 `json:"context_score"`
+	Eligible        bool    `json:"eligible"`
+	Reasoning       string  `json:"reasoning,omitempty"`
+}
+
+// ScoreModels ranks a provider's models for a request, best first. A model is
+// eligible when it handles the task type and its context window, if known,
+// holds the request; ineligible models are ranked after every eligible one.
+// Within that, the model closest to the required reasoning level wins,
+// favouring cheaper models among equally capable ones
+func ScoreModels(db *ModelDatabase, providerName string, models []string, request ModelRequest) []ModelScore {
+	if len(models) == 0 {
+		return nil
+	}
+
+	// Providers rarely serve more models than fit on the stack
+	var buffer [8]ModelCapabilities
+	capabilities := buffer[:0]
+	minCost, maxCost := math.Inf(1), 0.0
+	for _, model := range models {
+		capabilities = append(capabilities, db.LookupModelCapabilities(model, providerName))
+		if cost := capabilities[len(capabilities)-1].CostPer1K; cost > 0 {
+			minCost, maxCost = math.Min(minCost, cost), math.Max(maxCost, cost)
+		}
+	}
+
+	scores := make([]ModelScore, len(models))
+	for i, model := range models {
+		scores[i] = scoreModel(model, capabilities[i], request, minCost, maxCost)
+	}
+
+	slices.SortStableFunc(scores, compareModelScores)
+	return scores
+}
+
+// compareModelScores orders eligible models first, then by score, best first
+func compareModelScores(a, b ModelScore) int {
+	switch {
+	case a.Eligible != b.Eligible && a.Eligible:
+		return -1
+	case a.Eligible != b.Eligible:
+		return 1
+	case a.Score > b.Score:
+		return -1
+	case a.Score < b.Score:
+		return 1
+	}
+	return 0
+}
+
+// scoreModel scores a single model; minCost and maxCost span the provider's priced models
+func scoreModel(model string, capabilities ModelCapabilities, request ModelRequest, minCost, maxCost float64) ModelScore {
+	score := ModelScore{Model: model, Eligible: true}
+
+	// Capability: must handle the task, then should match the level without overkill
+	handlesTask := modelHandlesTask(capabilities, request.TaskType)
+	score.Eligible = handlesTask
+	gap := capabilities.Reasoning - request.Level
+	if gap >= 0 {
+		score.CapabilityScore = math.Max(0.5, 1-0.05*float64(gap))
+	} else {
+		score.CapabilityScore = math.Max(0, 1+0.15*float64(gap))
+	}
+	score.CapabilityScore *= math.Max(capabilities.Confidence, 0.3)
+
+	// Cost relative to the provider's other priced models; unknown prices sit in the middle
+	switch {
+	case capabilities.CostPer1K <= 0:
+		score.CostScore = 0.5
+	case maxCost > minCost:
+		score.CostScore = 1 - (capabilities.CostPer1K-minCost)/(maxCost-minCost)
+	default:
+		score.CostScore = 1
+	}
+
+	// Context window
+	fitsContext := capabilities.ContextWindow <= 0 || request.Tokens <= int64(capabilities.ContextWindow)
+	switch {
+	case capabilities.ContextWindow <= 0:
+		score.ContextScore = 0.7
+	case fitsContext:
+		score.ContextScore = 1
+	default:
+		score.Eligible = false
+	}
+
+	score.Score = modelCapabilityWeight*score.CapabilityScore + modelCostWeight*score.CostScore + modelContextWeight*score.ContextScore
+	if request.Explain {
+		score.Reasoning = explainModelScore(capabilities, request, handlesTask, fitsContext)
+	}
+	return score
+}
+
+// explainModelScore describes what scoreModel weighed for a model
+func explainModelScore(capabilities ModelCapabilities, request ModelRequest, handlesTask, fitsContext bool) string {
+	var reasons []string
+	if !handlesTask {
+		reasons = append(reasons, fmt.Sprintf("does not handle %s tasks", request.TaskType))
+	}
+	if capabilities.Reasoning >= request.Level {
+		reasons = append(reasons, fmt.Sprintf("reasoning %d meets level %d", capabilities.Reasoning, request.Level))
+	} else {
+		reasons = append(reasons, fmt.Sprintf("reasoning %d is below level %d", capabilities.Reasoning, request.Level))
+	}
+	if capabilities.CostPer1K > 0 {
+		reasons = append(reasons, fmt.Sprintf("$%.5f per 1K tokens", capabilities.CostPer1K))
+	}
+	if !fitsContext {
+		reasons = append(reasons, fmt.Sprintf("%d tokens exceed the %d token context window", request.Tokens, capabilities.ContextWindow))
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// modelHandlesTask applies the provider compatibility rules to a single model
+func modelHandlesTask(capabilities ModelCapabilities, taskType TaskType) bool {
+	switch taskType {
+	case TaskTypeImage:
+		return capabilities.Image
+	case TaskTypeCode:
+		return capabilities.Code || (capabilities.Text && capabilities.Reasoning >= 7)
+	case TaskTypeAudio:
+		return capabilities.Audio
+	case TaskTypeVideo:
+		return capabilities.Video
+	case TaskTypeMultimodal:
+		return capabilities.Multimodal || (capabilities.Text && capabilities.Image)
+	default:
+		return capabilities.Text
+	}
+}
@@ -0,0 +1,47 @@
+package selection
+
+import (
+	"testing"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/perfbudget"
+)
+
+// Models the scoring benchmarks rank: ones the model database knows, and
+// ones only its name patterns and provider hints can assess
+var (
+	knownModels   = []string{"gpt-4o", "gpt-4o-mini", "gpt-3.5-turbo", "claude-3-5-sonnet-20241022"}
+	unknownModels = []string{"acme-chat-large-v2", "acme-coder-7b-instruct-q4", "org/acme-mini:latest"}
+)
+
+func benchmarkScoreModels(b *testing.B, models []string, explain bool) {
+	db := NewModelDatabase()
+	request := ModelRequest{TaskType: TaskTypeText, Level: ModelLevel(0.5), Tokens: 2000, Explain: explain}
+	for i := 0; i < b.N; i++ {
+		if scores := ScoreModels(db, "Acme", models, request); len(scores) != len(models) {
+			b.Fatalf("scored %d of %d models", len(scores), len(models))
+		}
+	}
+}
+
+func BenchmarkScoreModelsKnown(b *testing.B) {
+	benchmarkScoreModels(b, knownModels, false)
+}
+
+func BenchmarkScoreModelsKnownExplained(b *testing.B) {
+	benchmarkScoreModels(b, knownModels, true)
+}
+
+func BenchmarkScoreModelsUnknown(b *testing.B) {
+	benchmarkScoreModels(b, unknownModels, false)
+}
+
+// TestPerformanceBudget covers model scoring, which runs for every capable
+// provider on every request and allocates only its result unless explained.
+// Run with PERF_BUDGET=true
+func TestPerformanceBudget(t *testing.T) {
+	perfbudget.Check(t,
+		perfbudget.Budget{Name: "ScoreModelsKnown", Benchmark: BenchmarkScoreModelsKnown, MaxNsPerOp: 5_000, MaxAllocsPerOp: 2},
+		perfbudget.Budget{Name: "ScoreModelsKnownExplained", Benchmark: BenchmarkScoreModelsKnownExplained, MaxNsPerOp: 25_000, MaxAllocsPerOp: 30},
+		perfbudget.Budget{Name: "ScoreModelsUnknown", Benchmark: BenchmarkScoreModelsUnknown, MaxNsPerOp: 120_000, MaxAllocsPerOp: 100},
+	)
+}
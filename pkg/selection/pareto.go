@@ -0,0 +1,204 @@
+package selection
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
+)
+
+// ParetoPolicy decides how selection uses the Pareto front over cost, quality
+// and latency instead of relying on the weighted score alone
+type ParetoPolicy string
+
+const (
+	// ParetoOff selects by weighted score and computes no front
+	ParetoOff ParetoPolicy = ""
+	// ParetoAdvisory selects by weighted score and reports the front so the
+	// caller can make its own tradeoff
+	ParetoAdvisory ParetoPolicy = "advisory"
+	// ParetoCheapest picks the cheapest provider on the front
+	ParetoCheapest ParetoPolicy = "cheapest"
+	// ParetoQuality picks the highest quality provider on the front
+	ParetoQuality ParetoPolicy = "quality"
+	// ParetoFastest picks the provider with the best latency score on the front
+	ParetoFastest ParetoPolicy = "fastest"
+	// ParetoBalanced picks the provider closest to the ideal point once each
+	// objective is normalized across the front
+	ParetoBalanced ParetoPolicy = "balanced"
+)
+
+// paretoPolicies lists the valid non-empty policies
+var paretoPolicies = []ParetoPolicy{ParetoAdvisory, ParetoCheapest, ParetoQuality, ParetoFastest, ParetoBalanced}
+
+// ParseParetoPolicy validates a policy name; "" and "off" disable the front
+func ParseParetoPolicy(s string) (ParetoPolicy, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if name == "" || name == "off" {
+		return ParetoOff, nil
+	}
+	for _, policy := range paretoPolicies {
+		if ParetoPolicy(name) == policy {
+			return policy, nil
+		}
+	}
+
+	names := make([]string, len(paretoPolicies))
+	for i, policy := range paretoPolicies {
+		names[i] = string(policy)
+	}
+	return ParetoOff, fmt.Errorf("unknown pareto policy %q: must be one of off, %s", s, strings.Join(names, ", "))
+}
+
+// ParetoPoint is a provider's position on the three objectives. Cost is
+// minimized; quality and latency score are maximized
+type ParetoPoint struct {
+	ProviderID    string    `json:"provider_id"`
+	Tier          tier.Tier `json:"tier,omitempty"`
+	EstimatedCost float64   `json:"estimated_cost"`
+	Quality       float64   `json:"quality"`
+	LatencyScore  float64   `json:"latency_score"`
+	TotalScore    float64   `json:"total_score"`
+}
+
+// Dominates reports whether p is no worse than other on every objective and
+// strictly better on at least one
+func (p ParetoPoint) Dominates(other ParetoPoint) bool {
+	if p.EstimatedCost > other.EstimatedCost || p.Quality < other.Quality || p.LatencyScore < other.LatencyScore {
+		return false
+	}
+	return p.EstimatedCost < other.EstimatedCost || p.Quality > other.Quality || p.LatencyScore > other.LatencyScore
+}
+
+// ParetoFront returns the points no other point dominates, in input order
+func ParetoFront(points []ParetoPoint) []ParetoPoint {
+	var front []ParetoPoint
+	for i, candidate := range points {
+		dominated := false
+		for j, other := range points {
+			if i != j && other.Dominates(candidate) {
+				dominated = true
+				break
+			}
+		}
+		if !dominated {
+			front = append(front, candidate)
+		}
+	}
+	return front
+}
+
+// Decision records which provider was chosen, the front it was chosen from
+// and what the choice gave up
+type Decision struct {
+	Policy   ParetoPolicy  `json:"policy"`
+	Front    []ParetoPoint `json:"front"`
+	Chosen   string        `json:"chosen"`
+	Tradeoff string        `json:"tradeoff"`
+}
+
+// Decide computes the front of points and chooses from it according to
+// policy. Advisory mode keeps the highest weighted score, which may lie off
+// the front when reliability decided it. Decide returns nil for no points or
+// ParetoOff
+func Decide(points []ParetoPoint, policy ParetoPolicy) *Decision {
+	if len(points) == 0 || policy == ParetoOff {
+		return nil
+	}
+
+	front := ParetoFront(points)
+	decision := &Decision{Policy: policy, Front: front}
+
+	var chosen ParetoPoint
+	switch policy {
+	case ParetoCheapest:
+		chosen = bestPoint(front, func(a, b ParetoPoint) bool { return a.EstimatedCost < b.EstimatedCost })
+	case ParetoQuality:
+		chosen = bestPoint(front, func(a, b ParetoPoint) bool { return a.Quality > b.Quality })
+	case ParetoFastest:
+		chosen = bestPoint(front, func(a, b ParetoPoint) bool { return a.LatencyScore > b.LatencyScore })
+	case ParetoBalanced:
+		chosen = balancedPoint(front)
+	default:
+		chosen = bestPoint(points, func(a, b ParetoPoint) bool { return false })
+	}
+
+	decision.Chosen = chosen.ProviderID
+	decision.Tradeoff = describeTradeoff(chosen, front, policy)
+	return decision
+}
+
+// bestPoint returns the point better is most true for, breaking ties by
+// total score
+func bestPoint(points []ParetoPoint, better func(a, b ParetoPoint) bool) ParetoPoint {
+	best := points[0]
+	for _, p := range points[1:] {
+		if better(p, best) || (!better(best, p) && p.TotalScore > best.TotalScore) {
+			best = p
+		}
+	}
+	return best
+}
+
+// balancedPoint returns the point nearest to the ideal point with every
+// objective scaled to 0 (worst on the front) .. 1 (best on the front)
+func balancedPoint(front []ParetoPoint) ParetoPoint {
+	minCost, maxCost := math.Inf(1), math.Inf(-1)
+	minQuality, maxQuality := math.Inf(1), math.Inf(-1)
+	minLatency, maxLatency := math.Inf(1), math.Inf(-1)
+	for _, p := range front {
+		minCost, maxCost = math.Min(minCost, p.EstimatedCost), math.Max(maxCost, p.EstimatedCost)
+		minQuality, maxQuality = math.Min(minQuality, p.Quality), math.Max(maxQuality, p.Quality)
+		minLatency, maxLatency = math.Min(minLatency, p.LatencyScore), math.Max(maxLatency, p.LatencyScore)
+	}
+
+	scale := func(value, worst, best float64) float64 {
+		if best == worst {
+			return 1
+		}
+		return (value - worst) / (best - worst)
+	}
+
+	best := front[0]
+	bestDistance := math.Inf(1)
+	for _, p := range front {
+		cost := 1 - scale(p.EstimatedCost, maxCost, minCost)
+		quality := 1 - scale(p.Quality, minQuality, maxQuality)
+		latency := 1 - scale(p.LatencyScore, minLatency, maxLatency)
+		distance := math.Sqrt(cost*cost + quality*quality + latency*latency)
+		if distance < bestDistance || (distance == bestDistance && p.TotalScore > best.TotalScore) {
+			best, bestDistance = p, distance
+		}
+	}
+	return best
+}
+
+// describeTradeoff explains, for each objective the chosen point is not best
+// at on the front, how much was given up and to whom
+func describeTradeoff(chosen ParetoPoint, front []ParetoPoint, policy ParetoPolicy) string {
+	reason := fmt.Sprintf("%s policy chose %s from %d Pareto-optimal providers", policy, chosen.ProviderID, len(front))
+	if policy == ParetoAdvisory {
+		reason = fmt.Sprintf("weighted score chose %s; %d Pareto-optimal providers returned for review", chosen.ProviderID, len(front))
+	}
+
+	cheapest := bestPoint(front, func(a, b ParetoPoint) bool { return a.EstimatedCost < b.EstimatedCost })
+	bestQuality := bestPoint(front, func(a, b ParetoPoint) bool { return a.Quality > b.Quality })
+	fastest := bestPoint(front, func(a, b ParetoPoint) bool { return a.LatencyScore > b.LatencyScore })
+
+	var costs []string
+	if chosen.EstimatedCost > cheapest.EstimatedCost {
+		costs = append(costs, fmt.Sprintf("$%.6f more than %s", chosen.EstimatedCost-cheapest.EstimatedCost, cheapest.ProviderID))
+	}
+	if chosen.Quality < bestQuality.Quality {
+		costs = append(costs, fmt.Sprintf("%.2f less quality than %s", bestQuality.Quality-chosen.Quality, bestQuality.ProviderID))
+	}
+	if chosen.LatencyScore < fastest.LatencyScore {
+		costs = append(costs, fmt.Sprintf("%.2f lower latency score than %s", fastest.LatencyScore-chosen.LatencyScore, fastest.ProviderID))
+	}
+
+	if len(costs) == 0 {
+		return reason + "; it is best on every objective"
+	}
+	return reason + "; tradeoffs: " + strings.Join(costs, ", ")
+}
@@ -1,12 +1,7 @@
 package selection
 
 import (
-	"encoding/csv"
 	"fmt"
-	"io"
-	"log"
-	"os"
-	"strconv"
 	"strings"
 	"time"
 
@@ -29,63 +24,17 @@ func NewEnhancedProviderLoader() *EnhancedProviderLoader {
 
 // LoadProvidersFromCSV loads providers from CSV file
 func LoadProvidersFromCSV(filename string) ([]config.ProviderConfig, error) {
-	file, err := os.Open(filename)
+	parsed, err := config.ReadProviderCSVFile(filename)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open CSV file: %w", err)
+		return nil, err
 	}
-	defer file.Close()
-
-	reader := csv.NewReader(file)
-	var providersList []config.ProviderConfig
-
-	// Read header
-	header, err := reader.Read()
-	if err != nil {
-		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	if parsed.Version < config.CSVSchemaVersion {
+		logger.Warnf("%s uses CSV schema v%d; run migrate-csv to upgrade it to v%d", filename, parsed.Version, config.CSVSchemaVersion)
 	}
 
-	// Create column index map
-	columnIndex := make(map[string]int)
-	for i, col := range header {
-		columnIndex[strings.ToLower(strings.TrimSpace(col))] = i
-	}
-
-	// Read data rows
-	for {
-		record, err := reader.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Printf("Error reading CSV row: %v", err)
-			continue
-		}
-
-		provider := config.ProviderConfig{}
-
-		// Map CSV columns to provider fields
-		if idx, exists := columnIndex["name"]; exists && idx < len(record) {
-			provider.Name = strings.TrimSpace(record[idx])
-		}
-		if idx, exists := columnIndex["tier"]; exists && idx < len(record) {
-			provider.Tier = strings.TrimSpace(record[idx])
-		}
-		if idx, exists := columnIndex["endpoint"]; exists && idx < len(record) {
-			provider.Endpoint = strings.TrimSpace(record[idx])
-		}
-		if idx, exists := columnIndex["api_key"]; exists && idx < len(record) {
-			provider.APIKey = strings.TrimSpace(record[idx])
-		}
-		if idx, exists := columnIndex["priority"]; exists && idx < len(record) {
-			if priority, err := strconv.Atoi(strings.TrimSpace(record[idx])); err == nil {
-				provider.Priority = priority
-			}
-		}
-
-		// Phase 1: ignore Enabled/URL; only require Name for validity
-		if provider.Name != "" {
-			providersList = append(providersList, provider)
-		}
+	providersList := make([]config.ProviderConfig, 0, len(parsed.Providers))
+	for _, provider := range parsed.Providers {
+		providersList = append(providersList, provider.ProviderConfig())
 	}
 
 	return providersList, nil
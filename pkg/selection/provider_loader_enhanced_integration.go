@@ -2,10 +2,10 @@ package selection
 
 import (
 	"fmt"
-	"log"
 	"strings"
 
 	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
 )
 
 type IntegratedProviderSystem struct {
@@ -31,10 +31,10 @@ func (ips *IntegratedProviderSystem) LoadAllProviders(csvFile, yamlDir string) e
 	if csvFile != "" {
 		csvProviders, err := ips.csvLoader.LoadProviders(csvFile)
 		if err != nil {
-			log.Printf("Warning: Failed to load CSV providers: %v", err)
+			logger.Warnf("Failed to load CSV providers: %v", err)
 		} else {
 			allProviders = append(allProviders, csvProviders...)
-			log.Printf("Loaded %d providers from CSV", len(csvProviders))
+			logger.Infof("Loaded %d providers from CSV", len(csvProviders))
 		}
 	}
 
@@ -42,10 +42,10 @@ func (ips *IntegratedProviderSystem) LoadAllProviders(csvFile, yamlDir string) e
 	if yamlDir != "" {
 		yamlProviders, err := ips.yamlLoader.LoadProvidersFromDirectory(yamlDir)
 		if err != nil {
-			log.Printf("Warning: Failed to load YAML providers: %v", err)
+			logger.Warnf("Failed to load YAML providers: %v", err)
 		} else {
 			allProviders = append(allProviders, yamlProviders...)
-			log.Printf("Loaded %d providers from YAML", len(yamlProviders))
+			logger.Infof("Loaded %d providers from YAML", len(yamlProviders))
 		}
 	}
 
@@ -108,6 +108,9 @@ func (ips *IntegratedProviderSystem) ValidateProviders() []string {
 		if provider.Endpoint == "" {
 			issues = append(issues, fmt.Sprintf("Provider %s: missing endpoint", provider.Name))
 		}
+		if _, err := tier.Parse(provider.Tier.String()); err != nil {
+			issues = append(issues, fmt.Sprintf("Provider %s: %v", provider.Name, err))
+		}
 	}
 
 	return issues
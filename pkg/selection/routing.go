@@ -0,0 +1,208 @@
+package selection
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
+	"gopkg.in/yaml.v3"
+)
+
+// TrafficClass distinguishes interactive requests from bulk work that can
+// tolerate slower or cheaper providers
+type TrafficClass string
+
+const (
+	// TrafficInteractive is a single request waiting on its response
+	TrafficInteractive TrafficClass = "interactive"
+	// TrafficBatch is one item of a batch submission
+	TrafficBatch TrafficClass = "batch"
+)
+
+// trafficContextKey is the unexported type for the traffic class context value
+type trafficContextKey struct{}
+
+// WithTrafficClass returns a copy of ctx marking its requests as class
+func WithTrafficClass(ctx context.Context, class TrafficClass) context.Context {
+	return context.WithValue(ctx, trafficContextKey{}, class)
+}
+
+// TrafficClassFromContext returns the traffic class stored in ctx, defaulting
+// to TrafficInteractive
+func TrafficClassFromContext(ctx context.Context) TrafficClass {
+	if ctx != nil {
+		if class, ok := ctx.Value(trafficContextKey{}).(TrafficClass); ok {
+			return class
+		}
+	}
+	return TrafficInteractive
+}
+
+// RoutingPolicy adjusts selection while all of its conditions hold. Unset
+// conditions always hold. When it applies, its tier preference replaces the
+// request's only if the request gave none, its preferred providers are
+// ranked first within each tier, and its capability expression must hold
+// along with the request's
+type RoutingPolicy struct {
+	Name string `yaml:"name" json:"name"`
+
+	// Conditions
+	Schedule        string       `yaml:"schedule,omitempty" json:"schedule,omitempty"`
+	Timezone        string       `yaml:"timezone,omitempty" json:"timezone,omitempty"`
+	Traffic         TrafficClass `yaml:"traffic,omitempty" json:"traffic,omitempty"`
+	QueueDepthAbove int64        `yaml:"queue_depth_above,omitempty" json:"queue_depth_above,omitempty"`
+
+	// Effects
+	TierPreference     []tier.Tier `yaml:"tier_preference,omitempty" json:"tier_preference,omitempty"`
+	PreferredProviders []string    `yaml:"preferred_providers,omitempty" json:"preferred_providers,omitempty"`
+	Capabilities       string      `yaml:"capabilities,omitempty" json:"capabilities,omitempty"`
+
+	capabilities *CapabilityExpr
+	schedule     *Schedule
+	location     *time.Location
+}
+
+// RoutingConditions is the live state routing policies are matched against
+type RoutingConditions struct {
+	Time       time.Time
+	Traffic    TrafficClass
+	QueueDepth int64
+}
+
+// RoutingPolicies is an ordered list of policies; the first match wins
+type RoutingPolicies struct {
+	policies []RoutingPolicy
+}
+
+// NewRoutingPolicies validates policies and compiles their schedules
+func NewRoutingPolicies(policies []RoutingPolicy) (*RoutingPolicies, error) {
+	compiled := make([]RoutingPolicy, len(policies))
+	for i, policy := range policies {
+		if err := policy.compile(); err != nil {
+			name := policy.Name
+			if name == "" {
+				name = fmt.Sprintf("#%d", i+1)
+			}
+			return nil, fmt.Errorf("routing policy %s: %w", name, err)
+		}
+		compiled[i] = policy
+	}
+	return &RoutingPolicies{policies: compiled}, nil
+}
+
+// LoadRoutingPolicies reads policies from a YAML file with a top-level
+// "policies" list
+func LoadRoutingPolicies(path string) (*RoutingPolicies, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read routing policies: %w", err)
+	}
+
+	var file struct {
+		Policies []RoutingPolicy `yaml:"policies"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse routing policies %s: %w", path, err)
+	}
+	return NewRoutingPolicies(file.Policies)
+}
+
+// Policies returns the configured policies in match order
+func (rp *RoutingPolicies) Policies() []RoutingPolicy {
+	return append([]RoutingPolicy(nil), rp.policies...)
+}
+
+// Match returns the first policy whose conditions hold, or nil
+func (rp *RoutingPolicies) Match(conditions RoutingConditions) *RoutingPolicy {
+	if rp == nil {
+		return nil
+	}
+	for i := range rp.policies {
+		if rp.policies[i].Matches(conditions) {
+			return &rp.policies[i]
+		}
+	}
+	return nil
+}
+
+// Matches reports whether every condition of the policy holds. Schedules are
+// evaluated in the policy's timezone, or the time's own location
+func (p *RoutingPolicy) Matches(conditions RoutingConditions) bool {
+	if p.Traffic != "" && p.Traffic != conditions.Traffic {
+		return false
+	}
+	if p.QueueDepthAbove > 0 && conditions.QueueDepth <= p.QueueDepthAbove {
+		return false
+	}
+	if p.schedule != nil {
+		now := conditions.Time
+		if p.location != nil {
+			now = now.In(p.location)
+		}
+		if !p.schedule.Matches(now) {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply returns constraints with the policy's preferences merged in
+func (p *RoutingPolicy) Apply(constraints RequestConstraints) RequestConstraints {
+	if len(constraints.TierPreference) == 0 && len(p.TierPreference) > 0 {
+		constraints.TierPreference = append([]tier.Tier(nil), p.TierPreference...)
+	}
+	if len(p.PreferredProviders) > 0 {
+		constraints.PreferredProviders = append(append([]string(nil), constraints.PreferredProviders...), p.PreferredProviders...)
+	}
+	constraints.Capabilities = constraints.Capabilities.And(p.capabilities)
+	return constraints
+}
+
+// compile validates the policy and parses its schedule and timezone
+func (p *RoutingPolicy) compile() error {
+	if p.Schedule == "" && p.Traffic == "" && p.QueueDepthAbove == 0 {
+		return fmt.Errorf("needs at least one of schedule, traffic or queue_depth_above")
+	}
+	if len(p.TierPreference) == 0 && len(p.PreferredProviders) == 0 && strings.TrimSpace(p.Capabilities) == "" {
+		return fmt.Errorf("needs tier_preference, preferred_providers or capabilities")
+	}
+
+	p.Traffic = TrafficClass(strings.ToLower(strings.TrimSpace(string(p.Traffic))))
+	switch p.Traffic {
+	case "", TrafficInteractive, TrafficBatch:
+	default:
+		return fmt.Errorf("unknown traffic class %q: must be %s or %s", p.Traffic, TrafficInteractive, TrafficBatch)
+	}
+	if p.QueueDepthAbove < 0 {
+		return fmt.Errorf("queue_depth_above must not be negative")
+	}
+	capabilities, err := ParseCapabilityExpr(p.Capabilities)
+	if err != nil {
+		return fmt.Errorf("invalid capabilities: %w", err)
+	}
+	p.capabilities = capabilities
+	for _, t := range p.TierPreference {
+		if !t.Valid() {
+			return fmt.Errorf("invalid tier %q: must be one of %s", t, strings.Join(tier.Names(), ", "))
+		}
+	}
+
+	if p.Schedule != "" {
+		schedule, err := ParseSchedule(p.Schedule)
+		if err != nil {
+			return err
+		}
+		p.schedule = schedule
+	}
+	if p.Timezone != "" {
+		location, err := time.LoadLocation(p.Timezone)
+		if err != nil {
+			return fmt.Errorf("invalid timezone %q: %w", p.Timezone, err)
+		}
+		p.location = location
+	}
+	return nil
+}
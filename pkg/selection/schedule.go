@@ -0,0 +1,144 @@
+package selection
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed five-field cron expression: minute, hour, day of
+// month, month and day of week. Fields accept "*", numbers, ranges ("1-5"),
+// lists ("1,3,5") and steps ("*/15", "0-30/10"). Day of week runs 0-6 from
+// Sunday, with 7 also meaning Sunday
+type Schedule struct {
+	expr   string
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+	anyDOM bool
+	anyDOW bool
+}
+
+// cronField describes the allowed range of one cron field
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = [5]cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// scheduleAliases expands the common @ shorthands
+var scheduleAliases = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// ParseSchedule parses a cron expression
+func ParseSchedule(expr string) (*Schedule, error) {
+	spec := strings.TrimSpace(expr)
+	if alias, ok := scheduleAliases[strings.ToLower(spec)]; ok {
+		spec = alias
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("invalid schedule %q: expected 5 fields, got %d", expr, len(fields))
+	}
+
+	var bits [5]uint64
+	for i, field := range fields {
+		parsed, err := parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", expr, err)
+		}
+		bits[i] = parsed
+	}
+
+	// 7 is an alias for Sunday
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+
+	return &Schedule{
+		expr:   expr,
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+		anyDOM: fields[2] == "*",
+		anyDOW: fields[4] == "*",
+	}, nil
+}
+
+// Matches reports whether t falls in a minute the schedule selects. As in
+// cron, a restricted day of month and day of week match when either does
+func (s *Schedule) Matches(t time.Time) bool {
+	if s.minute&(1<<uint(t.Minute())) == 0 || s.hour&(1<<uint(t.Hour())) == 0 || s.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.anyDOM || s.anyDOW {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// String returns the expression the schedule was parsed from
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+// parseCronField converts one comma separated field into a bit set
+func parseCronField(field string, spec cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("%s: invalid step in %q", spec.name, part)
+			}
+			rangePart, step = part[:i], n
+		}
+
+		low, high := spec.min, spec.max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if low, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("%s: invalid value %q", spec.name, part)
+			}
+			high = low
+			if len(bounds) == 2 {
+				if high, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("%s: invalid value %q", spec.name, part)
+				}
+			} else if step > 1 {
+				// "5/15" means every 15 starting at 5
+				high = spec.max
+			}
+		}
+		if low < spec.min || high > spec.max || low > high {
+			return 0, fmt.Errorf("%s: %q is outside %d-%d", spec.name, part, spec.min, spec.max)
+		}
+
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
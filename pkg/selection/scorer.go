@@ -0,0 +1,48 @@
+package selection
+
+import (
+	"context"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
+)
+
+// ScoreCandidate is a provider as a Scorer sees it, with its built-in score
+type ScoreCandidate struct {
+	Provider     string    `json:"provider"`
+	Tier         tier.Tier `json:"tier"`
+	Models       []string  `json:"models,omitempty"`
+	Capabilities []string  `json:"capabilities,omitempty"`
+	Score        float64   `json:"score"`
+	CostPerToken float64   `json:"cost_per_token"`
+	InFlight     int       `json:"in_flight"`
+}
+
+// ScoreRequest is the selection a Scorer is asked about
+type ScoreRequest struct {
+	// Complexity is the analysed complexity of the request
+	Complexity   interface{}      `json:"complexity"`
+	Capabilities []string         `json:"capabilities,omitempty"`
+	Model        string           `json:"model,omitempty"`
+	Candidates   []ScoreCandidate `json:"candidates"`
+}
+
+// ScoreAdjustment is a Scorer's answer. Adjustments are added to the scores of
+// the named providers, and Exclude maps providers that must not be selected
+// to the reason why
+type ScoreAdjustment struct {
+	Adjustments map[string]float64 `json:"adjustments,omitempty"`
+	Exclude     map[string]string  `json:"exclude,omitempty"`
+	Reasoning   string             `json:"reasoning,omitempty"`
+}
+
+// IsZero reports whether the adjustment changes nothing
+func (a ScoreAdjustment) IsZero() bool {
+	return len(a.Adjustments) == 0 && len(a.Exclude) == 0
+}
+
+// Scorer adjusts provider scores after the built-in scoring, before the
+// Pareto decision and tie break
+type Scorer interface {
+	Name() string
+	Score(ctx context.Context, request ScoreRequest) (ScoreAdjustment, error)
+}
@@ -0,0 +1,166 @@
+package selection
+
+import (
+	"strings"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
+)
+
+// Scores given to providers with no recorded requests
+const (
+	DefaultLatencyScore     = 0.7
+	DefaultReliabilityScore = 0.8
+	DefaultQualityScore     = 0.7
+)
+
+// Latency bounds for LatencyScore: at or under the target scores 1.0, and the
+// score falls linearly to 0.1 at the maximum
+const (
+	TargetLatency = 500 * time.Millisecond
+	MaxLatency    = 5 * time.Second
+)
+
+// metricsLearningRate weights each new request in the moving averages
+const metricsLearningRate = 0.1
+
+// ProviderID returns the identifier selectors key providers by
+func ProviderID(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, " ", "_"))
+}
+
+// TierQualityScore is the expected quality of a provider in the tier
+func TierQualityScore(providerTier tier.Tier) float64 {
+	switch providerTier {
+	case tier.Official:
+		return 0.9
+	case tier.Community:
+		return 0.7
+	case tier.Unofficial:
+		return 0.5
+	default:
+		return 0.6
+	}
+}
+
+// TierCostScore scores how cheap a provider in the tier is; higher is cheaper
+func TierCostScore(providerTier tier.Tier) float64 {
+	switch providerTier {
+	case tier.Official:
+		return 0.3 // Higher cost
+	case tier.Community:
+		return 0.7 // Medium cost
+	case tier.Unofficial:
+		return 1.0 // Lower/free cost
+	default:
+		return 0.5
+	}
+}
+
+// LatencyScore scores a provider's average latency, or returns
+// DefaultLatencyScore when nothing is recorded. Windowed latency moves
+// toward the default as the requests it was measured on age
+func LatencyScore(metrics *ProviderMetrics) float64 {
+	if windowed := metrics.recent(); windowed != nil {
+		latency := time.Duration(windowed.AverageLatencyMs * float64(time.Millisecond))
+		return Blend(latencyScore(latency), DefaultLatencyScore, windowed.Requests)
+	}
+	if metrics == nil || metrics.TotalRequests == 0 {
+		return DefaultLatencyScore
+	}
+	return latencyScore(metrics.AverageLatency)
+}
+
+// latencyScore scores an average latency
+func latencyScore(latency time.Duration) float64 {
+	if latency <= TargetLatency {
+		return 1.0
+	}
+	if latency >= MaxLatency {
+		return 0.1
+	}
+
+	ratio := float64(latency-TargetLatency) / float64(MaxLatency-TargetLatency)
+	return 1.0 - (ratio * 0.9)
+}
+
+// ReliabilityScore is a provider's success rate, or DefaultReliabilityScore
+// when nothing is recorded. A windowed success rate moves toward the
+// default as the requests it was measured on age
+func ReliabilityScore(metrics *ProviderMetrics) float64 {
+	if windowed := metrics.recent(); windowed != nil {
+		return Blend(windowed.SuccessRate, DefaultReliabilityScore, windowed.Requests)
+	}
+	if metrics == nil || metrics.TotalRequests == 0 {
+		return DefaultReliabilityScore
+	}
+	return metrics.SuccessRate
+}
+
+// recent returns the windowed metrics as of now, nil without a window
+func (m *ProviderMetrics) recent() *WindowedMetrics {
+	switch {
+	case m == nil:
+		return nil
+	case m.Windowed != nil:
+		return m.Windowed
+	case m.window != nil:
+		windowed := m.window.At(time.Now())
+		return &windowed
+	}
+	return nil
+}
+
+// NewProviderMetrics starts metrics for a provider from its first request
+func NewProviderMetrics(latency time.Duration, quality float64) *ProviderMetrics {
+	return &ProviderMetrics{
+		AverageLatency: latency,
+		SuccessRate:    1.0,
+		QualityScore:   quality,
+		LastUpdated:    time.Now(),
+	}
+}
+
+// Record folds one request into the metrics using exponential moving averages
+func (m *ProviderMetrics) Record(latency time.Duration, success bool, quality float64) {
+	alpha := metricsLearningRate
+	m.AverageLatency = time.Duration(float64(m.AverageLatency)*(1-alpha) + float64(latency)*alpha)
+
+	m.TotalRequests++
+	if success {
+		m.SuccessfulRequests++
+	}
+
+	m.SuccessRate = float64(m.SuccessfulRequests) / float64(m.TotalRequests)
+	m.QualityScore = m.QualityScore*(1-alpha) + quality*alpha
+	m.LastUpdated = time.Now()
+	if m.window != nil {
+		m.window.Record(m.LastUpdated, latency, success)
+	}
+}
+
+// joinReasons joins selection reasons into a readable list
+func joinReasons(reasons []string) string {
+	if len(reasons) == 0 {
+		return "general suitability"
+	}
+	if len(reasons) == 1 {
+		return reasons[0]
+	}
+	if len(reasons) == 2 {
+		return reasons[0] + " and " + reasons[1]
+	}
+
+	last := reasons[len(reasons)-1]
+	others := strings.Join(reasons[:len(reasons)-1], ", ")
+	return others + ", and " + last
+}
+
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
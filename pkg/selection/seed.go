@@ -0,0 +1,48 @@
+package selection
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/fnv"
+)
+
+// seedContextKey is the unexported type for the seed context value
+type seedContextKey struct{}
+
+// WithSeed returns a copy of ctx whose selection is reproducible: tie breaks
+// and the admission of warming providers are drawn from seed instead of
+// depending on the requests routed before
+func WithSeed(ctx context.Context, seed int64) context.Context {
+	return context.WithValue(ctx, seedContextKey{}, seed)
+}
+
+// SeedFromContext returns the seed ctx selects with, if any
+func SeedFromContext(ctx context.Context) (int64, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	seed, ok := ctx.Value(seedContextKey{}).(int64)
+	return seed, ok
+}
+
+// SeededDraw returns a number in [0, 1) that depends only on seed and keys,
+// so a draw for the same choice is the same every time
+func SeededDraw(seed int64, keys ...string) float64 {
+	hash := fnv.New64a()
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(seed))
+	hash.Write(buf[:])
+	for _, key := range keys {
+		hash.Write([]byte(key))
+		hash.Write([]byte{0})
+	}
+
+	// splitmix64 finalizer spreads nearby seeds over the whole range
+	x := hash.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return float64(x>>11) / (1 << 53)
+}
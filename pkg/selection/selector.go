@@ -0,0 +1,109 @@
+package selection
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/analysis"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/logging"
+)
+
+// logger is the selection module logger, configurable via LOG_MODULES=selection=<level>
+var logger = logging.Module("selection")
+
+// Selector chooses a provider for a task and learns from the outcomes of
+// the requests it routed. EnhancedAdaptiveSelector is the implementation;
+// callers outside this package should depend on the interface
+type Selector interface {
+	SelectProvider(complexity analysis.TaskComplexity, constraints map[string]interface{}) (ProviderScore, error)
+	UpdateProviderMetrics(providerID string, latency time.Duration, success bool, quality float64)
+	GetProviderMetrics() map[string]*ProviderMetrics
+	SetWeights(weights SelectionWeights)
+}
+
+var _ Selector = (*EnhancedAdaptiveSelector)(nil)
+
+// ProviderScore represents the scoring result for a provider
+type ProviderScore struct {
+	ProviderID       string  `json:"provider_id"`
+	TotalScore       float64 `json:"total_score"`
+	CostScore        float64 `json:"cost_score"`
+	QualityScore     float64 `json:"quality_score"`
+	LatencyScore     float64 `json:"latency_score"`
+	ReliabilityScore float64 `json:"reliability_score"`
+	Reasoning        string  `json:"reasoning"`
+
+	// EstimatedCost and Rejected are set when request constraints were applied
+	EstimatedCost float64     `json:"estimated_cost,omitempty"`
+	Rejected      []Rejection `json:"rejected,omitempty"`
+
+	// Decision records the Pareto tradeoff when a ParetoPolicy is in effect
+	Decision *Decision `json:"decision,omitempty"`
+
+	// Model is the model to execute with: the provider's name for a requested
+	// model, or its best scoring model, ranked in ModelScores. ModelResolution
+	// records how an aliased or deprecated request was mapped onto it
+	Model           string           `json:"model,omitempty"`
+	ModelScores     []ModelScore     `json:"model_scores,omitempty"`
+	ModelResolution *ModelResolution `json:"model_resolution,omitempty"`
+}
+
+// SelectionWeights defines the importance of different factors
+type SelectionWeights struct {
+	Cost        float64 `yaml:"cost" json:"cost"`
+	Quality     float64 `yaml:"quality" json:"quality"`
+	Latency     float64 `yaml:"latency" json:"latency"`
+	Reliability float64 `yaml:"reliability" json:"reliability"`
+}
+
+// DefaultSelectionWeights are the weights selectors start with
+var DefaultSelectionWeights = SelectionWeights{
+	Cost:        0.25,
+	Quality:     0.40,
+	Latency:     0.20,
+	Reliability: 0.15,
+}
+
+// Total combines the parts of a provider score by the weights
+func (w SelectionWeights) Total(score ProviderScore) float64 {
+	return score.QualityScore*w.Quality + score.CostScore*w.Cost +
+		score.LatencyScore*w.Latency + score.ReliabilityScore*w.Reliability
+}
+
+// Normalized returns the weights scaled to sum to 1. Weights must not be
+// negative and at least one must be positive
+func (w SelectionWeights) Normalized() (SelectionWeights, error) {
+	if w.Cost < 0 || w.Quality < 0 || w.Latency < 0 || w.Reliability < 0 {
+		return w, fmt.Errorf("weights must not be negative")
+	}
+	sum := w.Cost + w.Quality + w.Latency + w.Reliability
+	if sum == 0 {
+		return w, fmt.Errorf("at least one weight must be positive")
+	}
+	return SelectionWeights{
+		Cost:        w.Cost / sum,
+		Quality:     w.Quality / sum,
+		Latency:     w.Latency / sum,
+		Reliability: w.Reliability / sum,
+	}, nil
+}
+
+// String describes the weights for selection reasoning
+func (w SelectionWeights) String() string {
+	return fmt.Sprintf("quality %.2f, cost %.2f, latency %.2f, reliability %.2f", w.Quality, w.Cost, w.Latency, w.Reliability)
+}
+
+// ProviderMetrics tracks provider performance over time. The averages cover
+// the provider's lifetime; Windowed, when set, covers its recent requests
+// and is what scoring uses
+type ProviderMetrics struct {
+	AverageLatency     time.Duration    `json:"average_latency"`
+	SuccessRate        float64          `json:"success_rate"`
+	QualityScore       float64          `json:"quality_score"`
+	CostEfficiency     float64          `json:"cost_efficiency"`
+	TotalRequests      int              `json:"total_requests"`
+	SuccessfulRequests int              `json:"successful_requests"`
+	LastUpdated        time.Time        `json:"last_updated"`
+	Windowed           *WindowedMetrics `json:"windowed,omitempty"`
+	window             *WindowedStats
+}
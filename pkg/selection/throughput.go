@@ -0,0 +1,199 @@
+package selection
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Throughput defaults
+const (
+	// DefaultInteractiveReserve is the share of an account's tokens per
+	// minute batch traffic leaves to interactive requests
+	DefaultInteractiveReserve = 0.2
+	// DefaultMaxThroughputPenalty is the score a provider loses when its
+	// account has used up its tokens per minute
+	DefaultMaxThroughputPenalty = 0.3
+)
+
+// usageTierTokensPerMinute are the tokens per minute vendors grant accounts
+// in each usage tier, from tier 1, for their flagship models. Other models
+// may differ; a tpm limit overrides the tier
+var usageTierTokensPerMinute = map[string][]int64{
+	"openai":    {30000, 450000, 800000, 2000000, 30000000},
+	"anthropic": {30000, 450000, 800000, 2000000},
+}
+
+// usageTierVendors maps API hosts onto the vendors whose usage tiers apply
+var usageTierVendors = map[string]string{
+	"api.openai.com":    "openai",
+	"api.anthropic.com": "anthropic",
+}
+
+// UsageTierTokensPerMinute returns the tokens per minute of the given usage
+// tier at the vendor serving endpoint, and false when the vendor or the tier
+// is unknown
+func UsageTierTokensPerMinute(endpoint string, usageTier int) (int64, bool) {
+	vendor, ok := usageTierVendors[endpointHost(endpoint)]
+	if !ok || usageTier < 1 {
+		return 0, false
+	}
+	tiers := usageTierTokensPerMinute[vendor]
+	if usageTier > len(tiers) {
+		return 0, false
+	}
+	return tiers[usageTier-1], true
+}
+
+// ThroughputAccount identifies the upstream account behind a provider:
+// providers calling the same host with the same key draw on one account's
+// limits. The key only enters as a fingerprint
+func ThroughputAccount(endpoint, apiKey string) string {
+	host := endpointHost(endpoint)
+	if host == "" || apiKey == "" {
+		return host
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+	return host + "/" + hex.EncodeToString(sum[:4])
+}
+
+// endpointHost returns the lower-case host of endpoint, "" when it has none
+func endpointHost(endpoint string) string {
+	parsed, err := url.Parse(strings.TrimSpace(endpoint))
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
+
+// ThroughputPolicy decides how the tokens per minute of upstream accounts are
+// shared. Batch traffic may use all but InteractiveReserve of an account's
+// tokens, so large batch jobs cannot starve interactive requests, and a
+// provider loses up to MaxPenalty of its score as its account's tokens run out
+type ThroughputPolicy struct {
+	InteractiveReserve float64 `json:"interactive_reserve"`
+	MaxPenalty         float64 `json:"max_penalty"`
+}
+
+// Ceiling returns the tokens per minute traffic of class may use out of an
+// account's limit
+func (p ThroughputPolicy) Ceiling(class TrafficClass, limit int64) int64 {
+	if class != TrafficBatch {
+		return limit
+	}
+	return int64(float64(limit) * (1 - p.InteractiveReserve))
+}
+
+// Allows reports whether a request of class for tokens fits in an account
+// that used tokens of limit in the last minute. Accounts without a limit
+// allow everything
+func (p ThroughputPolicy) Allows(class TrafficClass, limit, used, tokens int64) bool {
+	return limit <= 0 || used+tokens <= p.Ceiling(class, limit)
+}
+
+// Penalty returns the score a provider loses when its account used tokens of
+// the ceiling its traffic class may use. Like the load penalty it grows with
+// the square of utilization
+func (p ThroughputPolicy) Penalty(used, ceiling int64) float64 {
+	if used <= 0 || ceiling <= 0 || p.MaxPenalty <= 0 {
+		return 0
+	}
+	utilization := math.Min(float64(used)/float64(ceiling), 1)
+	return p.MaxPenalty * utilization * utilization
+}
+
+// Headroom is an account's consumption against its tokens per minute
+type Headroom struct {
+	Account   string   `json:"account"`
+	Providers []string `json:"providers"`
+	Limit     int64    `json:"tokens_per_minute"`
+	Used      int64    `json:"used"`
+	// Remaining is what interactive requests may still use, BatchRemaining
+	// what batch traffic may
+	Remaining      int64 `json:"remaining"`
+	BatchRemaining int64 `json:"batch_remaining"`
+}
+
+// NewHeadroom reports an account that used tokens of limit under policy
+func (p ThroughputPolicy) NewHeadroom(account string, providers []string, limit, used int64) Headroom {
+	return Headroom{
+		Account:        account,
+		Providers:      providers,
+		Limit:          limit,
+		Used:           used,
+		Remaining:      max(limit-used, 0),
+		BatchRemaining: max(p.Ceiling(TrafficBatch, limit)-used, 0),
+	}
+}
+
+// tokenUsage is tokens an account consumed at a point in time
+type tokenUsage struct {
+	at     time.Time
+	tokens int64
+}
+
+// ThroughputTracker tracks the tokens each account consumed in the last
+// minute. It is safe for concurrent use
+type ThroughputTracker struct {
+	usage map[string][]*tokenUsage
+	mutex sync.Mutex
+}
+
+// NewThroughputTracker creates a tracker with nothing consumed
+func NewThroughputTracker() *ThroughputTracker {
+	return &ThroughputTracker{usage: make(map[string][]*tokenUsage)}
+}
+
+// Consume counts tokens an account is about to consume at, the estimate of
+// a request on its way. The returned settle replaces the estimate with what
+// the request actually consumed; calling it more than once has no further
+// effect
+func (t *ThroughputTracker) Consume(account string, at time.Time, tokens int64) (settle func(actual int64)) {
+	usage := &tokenUsage{at: at, tokens: tokens}
+	t.mutex.Lock()
+	t.usage[account] = append(t.prune(account, at), usage)
+	t.mutex.Unlock()
+
+	var once sync.Once
+	return func(actual int64) {
+		once.Do(func() {
+			t.mutex.Lock()
+			defer t.mutex.Unlock()
+			usage.tokens = actual
+		})
+	}
+}
+
+// Used returns the tokens an account consumed in the minute up to now
+func (t *ThroughputTracker) Used(account string, now time.Time) int64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	var used int64
+	for _, usage := range t.prune(account, now) {
+		used += usage.tokens
+	}
+	return used
+}
+
+// prune drops what an account consumed over a minute before now and returns
+// the rest. The caller holds the mutex
+func (t *ThroughputTracker) prune(account string, now time.Time) []*tokenUsage {
+	usages := t.usage[account]
+	cutoff := now.Add(-time.Minute)
+	i := 0
+	for i < len(usages) && !usages[i].at.After(cutoff) {
+		i++
+	}
+	if i == len(usages) {
+		delete(t.usage, account)
+		return nil
+	}
+	usages = usages[i:]
+	t.usage[account] = usages
+	return usages
+}
@@ -0,0 +1,168 @@
+package selection
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// DefaultTieEpsilon is how close two scores must be to count as a tie
+const DefaultTieEpsilon = 0.01
+
+// TieBreakStrategy decides which of several equally scored providers serves
+// a request
+type TieBreakStrategy string
+
+const (
+	// TieBreakFirst keeps the first provider in score order
+	TieBreakFirst TieBreakStrategy = "first"
+	// TieBreakRoundRobin spreads requests over tied providers in proportion
+	// to their weights
+	TieBreakRoundRobin TieBreakStrategy = "round_robin"
+	// TieBreakLeastLoaded picks the tied provider with the fewest requests in
+	// flight relative to its weight
+	TieBreakLeastLoaded TieBreakStrategy = "least_loaded"
+)
+
+// tieBreakStrategies lists the valid strategies
+var tieBreakStrategies = []TieBreakStrategy{TieBreakFirst, TieBreakRoundRobin, TieBreakLeastLoaded}
+
+// ParseTieBreakStrategy validates a strategy name; "" is round_robin
+func ParseTieBreakStrategy(s string) (TieBreakStrategy, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if name == "" {
+		return TieBreakRoundRobin, nil
+	}
+	for _, strategy := range tieBreakStrategies {
+		if TieBreakStrategy(name) == strategy {
+			return strategy, nil
+		}
+	}
+
+	names := make([]string, len(tieBreakStrategies))
+	for i, strategy := range tieBreakStrategies {
+		names[i] = string(strategy)
+	}
+	return TieBreakFirst, fmt.Errorf("unknown tie-break strategy %q: must be one of %s", s, strings.Join(names, ", "))
+}
+
+// TieCandidate is one of a group of equally scored providers. Weight is its
+// share of the group's traffic, typically its rate limit; non-positive
+// weights count as 1. Load is its number of requests in flight
+type TieCandidate struct {
+	ProviderID string  `json:"provider_id"`
+	Weight     float64 `json:"weight"`
+	Load       int     `json:"load"`
+}
+
+// TieBreak records how a tie between providers was resolved
+type TieBreak struct {
+	Strategy   TieBreakStrategy `json:"strategy"`
+	Candidates []string         `json:"candidates"`
+	Chosen     string           `json:"chosen"`
+	// Seed is the request's seed the choice was drawn from, if any
+	Seed *int64 `json:"seed,omitempty"`
+}
+
+// TieBreaker chooses among equally scored providers so load spreads across
+// them instead of concentrating on the first. It is safe for concurrent use
+type TieBreaker struct {
+	strategy TieBreakStrategy
+	epsilon  float64
+
+	// current holds the smooth weighted round-robin state per provider
+	current map[string]float64
+	mutex   sync.Mutex
+}
+
+// NewTieBreaker creates a tie breaker. Scores within epsilon of the best
+// are tied; DefaultTieEpsilon is used when epsilon is negative
+func NewTieBreaker(strategy TieBreakStrategy, epsilon float64) *TieBreaker {
+	if epsilon < 0 {
+		epsilon = DefaultTieEpsilon
+	}
+	return &TieBreaker{
+		strategy: strategy,
+		epsilon:  epsilon,
+		current:  make(map[string]float64),
+	}
+}
+
+// Strategy returns the configured strategy
+func (tb *TieBreaker) Strategy() TieBreakStrategy {
+	return tb.strategy
+}
+
+// Tied reports whether score is close enough to best to share its traffic
+func (tb *TieBreaker) Tied(best, score float64) bool {
+	return best-score <= tb.epsilon
+}
+
+// Choose returns the index of the candidate that should serve the next
+// request
+func (tb *TieBreaker) Choose(candidates []TieCandidate) int {
+	if len(candidates) < 2 || tb.strategy == TieBreakFirst {
+		return 0
+	}
+
+	if tb.strategy == TieBreakLeastLoaded {
+		chosen, lowest := 0, 0.0
+		for i, candidate := range candidates {
+			load := float64(candidate.Load) / tieWeight(candidate)
+			if i == 0 || load < lowest {
+				chosen, lowest = i, load
+			}
+		}
+		return chosen
+	}
+
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+
+	// Smooth weighted round-robin: every candidate gains its weight, the
+	// leader is chosen and pays back the group's total
+	chosen, total := 0, 0.0
+	for i, candidate := range candidates {
+		weight := tieWeight(candidate)
+		total += weight
+		tb.current[candidate.ProviderID] += weight
+		if tb.current[candidate.ProviderID] > tb.current[candidates[chosen].ProviderID] {
+			chosen = i
+		}
+	}
+	tb.current[candidates[chosen].ProviderID] -= total
+	return chosen
+}
+
+// ChooseSeeded returns the index of the candidate that serves a request
+// with a seed. Round-robin and least-loaded choices depend on the requests
+// before, so instead a candidate is drawn from seed in proportion to its
+// weight: the same candidates and seed always give the same choice, and the
+// round-robin state is left alone
+func (tb *TieBreaker) ChooseSeeded(candidates []TieCandidate, seed int64) int {
+	if len(candidates) < 2 || tb.strategy == TieBreakFirst {
+		return 0
+	}
+
+	total := 0.0
+	ids := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		total += tieWeight(candidate)
+		ids[i] = candidate.ProviderID
+	}
+	draw := SeededDraw(seed, append([]string{"tie_break"}, ids...)...) * total
+	for i, candidate := range candidates {
+		if draw -= tieWeight(candidate); draw < 0 {
+			return i
+		}
+	}
+	return len(candidates) - 1
+}
+
+// tieWeight returns a candidate's weight, 1 when unset
+func tieWeight(candidate TieCandidate) float64 {
+	if candidate.Weight <= 0 {
+		return 1
+	}
+	return candidate.Weight
+}
@@ -0,0 +1,203 @@
+package selection
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/tier"
+	"gopkg.in/yaml.v3"
+)
+
+// VirtualModelPrefix may precede a virtual model name so clients can tell it
+// from a concrete model: palmoe/fast and fast name the same virtual model
+const VirtualModelPrefix = "palmoe/"
+
+// VirtualModel is a model name clients request like any other that stands for
+// a routing policy instead of a model, so teams keep stable names while the
+// providers and models behind them change. Fields the request sets itself
+// take precedence, except that preferred providers add up and the stricter
+// quality and latency limits win
+type VirtualModel struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+
+	// Model pins a concrete model or alias; empty leaves the model to selection
+	Model                string            `yaml:"model,omitempty" json:"model,omitempty"`
+	TierPreference       []tier.Tier       `yaml:"tier_preference,omitempty" json:"tier_preference,omitempty"`
+	PreferredProviders   []string          `yaml:"preferred_providers,omitempty" json:"preferred_providers,omitempty"`
+	RequiredCapabilities []string          `yaml:"required_capabilities,omitempty" json:"required_capabilities,omitempty"`
+	ParetoPolicy         ParetoPolicy      `yaml:"pareto_policy,omitempty" json:"pareto_policy,omitempty"`
+	Weights              *SelectionWeights `yaml:"weights,omitempty" json:"weights,omitempty"`
+	QualityMin           float64           `yaml:"quality_min,omitempty" json:"quality_min,omitempty"`
+	MaxLatencyMs         int64             `yaml:"max_latency_ms,omitempty" json:"max_latency_ms,omitempty"`
+}
+
+// builtinVirtualModels are available unless a virtual models file redefines them
+var builtinVirtualModels = []VirtualModel{
+	{Name: "auto", Description: "The server's default selection"},
+	{Name: "fast", Description: "The provider with the best latency on the cost/quality/latency Pareto front", ParetoPolicy: ParetoFastest},
+	{Name: "cheap", Description: "The cheapest provider on the Pareto front", ParetoPolicy: ParetoCheapest},
+	{Name: "best", Description: "The highest quality provider on the Pareto front", ParetoPolicy: ParetoQuality},
+	{Name: "balanced", Description: "The provider closest to the ideal point of the Pareto front", ParetoPolicy: ParetoBalanced},
+	{
+		Name:                 "reasoning",
+		Description:          "Official providers with reasoning models, scored mostly on quality",
+		TierPreference:       []tier.Tier{tier.Official, tier.Community},
+		RequiredCapabilities: []string{"reasoning"},
+		Weights:              &SelectionWeights{Quality: 0.6, Reliability: 0.2, Latency: 0.1, Cost: 0.1},
+	},
+	{Name: "vision", Description: "Providers that accept images", RequiredCapabilities: []string{"vision"}},
+}
+
+// ID returns the name clients see the virtual model under
+func (vm VirtualModel) ID() string {
+	return VirtualModelPrefix + vm.Name
+}
+
+// Apply returns constraints with the virtual model's policy merged in. The
+// model constraint, which named the virtual model, becomes the pinned model
+func (vm VirtualModel) Apply(constraints RequestConstraints) RequestConstraints {
+	constraints.Model = vm.Model
+	if len(constraints.TierPreference) == 0 && len(vm.TierPreference) > 0 {
+		constraints.TierPreference = append([]tier.Tier(nil), vm.TierPreference...)
+	}
+	if len(vm.PreferredProviders) > 0 {
+		constraints.PreferredProviders = append(append([]string(nil), constraints.PreferredProviders...), vm.PreferredProviders...)
+	}
+	if constraints.ParetoPolicy == ParetoOff {
+		constraints.ParetoPolicy = vm.ParetoPolicy
+	}
+	if constraints.Weights == nil && vm.Weights != nil {
+		weights := *vm.Weights
+		constraints.Weights = &weights
+	}
+	if vm.QualityMin > constraints.QualityMin {
+		constraints.QualityMin = vm.QualityMin
+	}
+	if latency := vm.maxLatency(); latency > 0 && (constraints.MaxLatency == 0 || latency < constraints.MaxLatency) {
+		constraints.MaxLatency = latency
+	}
+	return constraints
+}
+
+// maxLatency returns the latency limit as a duration
+func (vm VirtualModel) maxLatency() time.Duration {
+	return time.Duration(vm.MaxLatencyMs) * time.Millisecond
+}
+
+// VirtualModels is the table of virtual models
+type VirtualModels struct {
+	models []VirtualModel
+	index  map[string]int
+}
+
+// DefaultVirtualModels returns a table holding only the built-in virtual models
+func DefaultVirtualModels() *VirtualModels {
+	table, err := NewVirtualModels(nil)
+	if err != nil {
+		panic(err)
+	}
+	return table
+}
+
+// NewVirtualModels builds a table from the built-in virtual models, replaced
+// by or extended with extra
+func NewVirtualModels(extra []VirtualModel) (*VirtualModels, error) {
+	table := &VirtualModels{index: make(map[string]int)}
+	for _, model := range append(append([]VirtualModel(nil), builtinVirtualModels...), extra...) {
+		if err := model.compile(); err != nil {
+			return nil, fmt.Errorf("virtual model %q: %w", model.Name, err)
+		}
+		if i, exists := table.index[model.Name]; exists {
+			table.models[i] = model
+			continue
+		}
+		table.index[model.Name] = len(table.models)
+		table.models = append(table.models, model)
+	}
+	return table, nil
+}
+
+// LoadVirtualModels reads virtual models from a YAML file with a top-level
+// "models" list and merges them over the built-in ones
+func LoadVirtualModels(path string) (*VirtualModels, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read virtual models: %w", err)
+	}
+
+	var file struct {
+		Models []VirtualModel `yaml:"models"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse virtual models %s: %w", path, err)
+	}
+	return NewVirtualModels(file.Models)
+}
+
+// Lookup returns the virtual model a requested model names, with or without
+// VirtualModelPrefix and ignoring case
+func (vms *VirtualModels) Lookup(model string) (VirtualModel, bool) {
+	if vms == nil {
+		return VirtualModel{}, false
+	}
+	name := strings.ToLower(strings.TrimSpace(model))
+	i, ok := vms.index[strings.TrimPrefix(name, VirtualModelPrefix)]
+	if !ok {
+		return VirtualModel{}, false
+	}
+	return vms.models[i], true
+}
+
+// Models returns the virtual models, built-in ones first
+func (vms *VirtualModels) Models() []VirtualModel {
+	if vms == nil {
+		return nil
+	}
+	return append([]VirtualModel(nil), vms.models...)
+}
+
+// compile validates the virtual model and normalizes its names and weights
+func (vm *VirtualModel) compile() error {
+	vm.Name = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(vm.Name)), VirtualModelPrefix)
+	if vm.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if strings.ContainsAny(vm.Name, "/ ") {
+		return fmt.Errorf("name must not contain slashes or spaces")
+	}
+	vm.Model = strings.TrimSpace(vm.Model)
+	for _, t := range vm.TierPreference {
+		if !t.Valid() {
+			return fmt.Errorf("invalid tier %q: must be one of %s", t, strings.Join(tier.Names(), ", "))
+		}
+	}
+	capabilities := make([]string, 0, len(vm.RequiredCapabilities))
+	for _, capability := range vm.RequiredCapabilities {
+		if capability = strings.ToLower(strings.TrimSpace(capability)); capability != "" {
+			capabilities = append(capabilities, capability)
+		}
+	}
+	vm.RequiredCapabilities = capabilities
+	policy, err := ParseParetoPolicy(string(vm.ParetoPolicy))
+	if err != nil {
+		return err
+	}
+	vm.ParetoPolicy = policy
+	if vm.Weights != nil {
+		weights, err := vm.Weights.Normalized()
+		if err != nil {
+			return fmt.Errorf("invalid weights: %w", err)
+		}
+		vm.Weights = &weights
+	}
+	if vm.QualityMin < 0 || vm.QualityMin > 1 {
+		return fmt.Errorf("quality_min must be between 0 and 1")
+	}
+	if vm.MaxLatencyMs < 0 {
+		return fmt.Errorf("max_latency_ms must not be negative")
+	}
+	return nil
+}
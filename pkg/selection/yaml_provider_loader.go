@@ -3,7 +3,6 @@ package selection
 import (
 	"fmt"
 	"io/ioutil"
-	"log"
 	"path/filepath"
 	"strings"
 
@@ -23,6 +22,8 @@ type YAMLProviderConfig struct {
 	Enabled     bool              `yaml:"enabled"`
 	Type        string            `yaml:"type"`
 	Headers     map[string]string `yaml:"headers"`
+
+	CapabilityOverrides *config.CapabilityOverrides `yaml:"capability_overrides"`
 }
 
 // YAMLProviderLoader handles loading providers from YAML files
@@ -58,6 +59,13 @@ func (ypl *YAMLProviderLoader) LoadProviderFromYAML(filename string) (*config.Pr
 		Metadata: map[string]string{
 			"source": yamlConfig.Source,
 		},
+		CapabilityOverrides: yamlConfig.CapabilityOverrides,
+	}
+	if err := yamlConfig.CapabilityOverrides.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid provider %s in %s: %w", yamlConfig.Name, filename, err)
+	}
+	if err := provider.ExpandEnv(); err != nil {
+		return nil, fmt.Errorf("invalid provider %s in %s: %w", yamlConfig.Name, filename, err)
 	}
 	// Do not populate optional fields like Capabilities or CostTracking here.
 	// Models are not stored on the canonical ProviderConfig in this pass.
@@ -87,7 +95,7 @@ func (ypl *YAMLProviderLoader) LoadProvidersFromDirectory(directory string) ([]c
 		filePath := filepath.Join(directory, file.Name())
 		provider, err := ypl.LoadProviderFromYAML(filePath)
 		if err != nil {
-			log.Printf("Warning: Failed to load provider from %s: %v", filePath, err)
+			logger.Warnf("Failed to load provider from %s: %v", filePath, err)
 			continue
 		}
 
@@ -106,7 +114,7 @@ func (ypl *YAMLProviderLoader) RefreshDynamicModels(provider *config.ProviderCon
 	}
 
 	// Phase 1: dynamic models loading is not implemented; skip
-	log.Printf("Dynamic model refresh skipped for provider %s in Phase 1", provider.Name)
+	logger.Debugf("Dynamic model refresh skipped for provider %s in Phase 1", provider.Name)
 	return nil
 }
 
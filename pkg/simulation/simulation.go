@@ -0,0 +1,153 @@
+// Package simulation evaluates a proposed routing configuration against the
+// traffic the gateway already served, so operators can see what a change to
+// weights, routing policies or providers would have cost before applying it
+package simulation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/config"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/selection"
+)
+
+// Limits on how many requests one simulation replays
+const (
+	DefaultLimit = 5000
+	MaxLimit     = 50000
+)
+
+// maxProposalBytes bounds the size of proposals read by Decode
+const maxProposalBytes = 4 << 20
+
+// ErrInvalidProposal is returned for proposals that cannot be simulated
+var ErrInvalidProposal = errors.New("invalid simulation proposal")
+
+// Proposal is a configuration to replay history through. Parts left unset
+// keep the running configuration; an empty routing_policies list simulates
+// running without any
+type Proposal struct {
+	Weights         *selection.SelectionWeights `json:"weights,omitempty"`
+	RoutingPolicies []selection.RoutingPolicy   `json:"routing_policies,omitempty"`
+	// ProvidersCSV is a providers.csv in either schema version
+	ProvidersCSV string `json:"providers_csv,omitempty"`
+
+	// The history to replay, the most recent Limit requests in the window
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+	Limit int       `json:"limit,omitempty"`
+}
+
+// Decode reads a proposal posted to the admin API
+func Decode(r io.Reader) (*Proposal, error) {
+	var proposal Proposal
+	if err := json.NewDecoder(io.LimitReader(r, maxProposalBytes)).Decode(&proposal); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProposal, err)
+	}
+	if proposal.Limit < 0 || proposal.Limit > MaxLimit {
+		return nil, fmt.Errorf("%w: limit must be between 0 and %d", ErrInvalidProposal, MaxLimit)
+	}
+	if proposal.Limit == 0 {
+		proposal.Limit = DefaultLimit
+	}
+	if !proposal.Since.IsZero() && !proposal.Until.IsZero() && !proposal.Until.After(proposal.Since) {
+		return nil, fmt.Errorf("%w: until must be after since", ErrInvalidProposal)
+	}
+	return &proposal, nil
+}
+
+// Policies compiles the proposed routing policies. It returns nil when the
+// proposal keeps the running ones
+func (p *Proposal) Policies() (*selection.RoutingPolicies, error) {
+	if p.RoutingPolicies == nil {
+		return nil, nil
+	}
+	policies, err := selection.NewRoutingPolicies(p.RoutingPolicies)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProposal, err)
+	}
+	return policies, nil
+}
+
+// Providers parses the proposed providers. It returns nil when the proposal
+// keeps the running ones
+func (p *Proposal) Providers() ([]config.ProviderConfig, error) {
+	if p.ProvidersCSV == "" {
+		return nil, nil
+	}
+	parsed, err := config.ReadProviderCSV(strings.NewReader(p.ProvidersCSV))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProposal, err)
+	}
+	providers := make([]config.ProviderConfig, 0, len(parsed.Providers))
+	for _, provider := range parsed.Providers {
+		providers = append(providers, provider.ProviderConfig())
+	}
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("%w: providers_csv lists no providers", ErrInvalidProposal)
+	}
+	return providers, nil
+}
+
+// Outcome sums up how a set of requests was, or would have been, served
+type Outcome struct {
+	Cost             float64        `json:"cost"`
+	AverageLatencyMs float64        `json:"average_latency_ms"`
+	Tiers            map[string]int `json:"tiers"`
+	Providers        map[string]int `json:"providers"`
+
+	requests  int
+	latencyMs float64
+}
+
+// Add counts one request served by provider of providerTier
+func (o *Outcome) Add(provider, providerTier string, cost, latencyMs float64) {
+	if o.Tiers == nil {
+		o.Tiers = make(map[string]int)
+		o.Providers = make(map[string]int)
+	}
+	o.requests++
+	o.Cost += cost
+	o.latencyMs += latencyMs
+	o.Tiers[providerTier]++
+	o.Providers[provider]++
+	o.AverageLatencyMs = o.latencyMs / float64(o.requests)
+}
+
+// Report compares the history as it was served with how the proposal would
+// have served it. Only requests both can account for are compared: Skipped
+// ones failed or kept no classification, and Unroutable ones no proposed
+// provider could serve
+type Report struct {
+	Since      time.Time `json:"since"`
+	Until      time.Time `json:"until"`
+	Requests   int       `json:"requests"`
+	Skipped    int       `json:"skipped"`
+	Unroutable int       `json:"unroutable"`
+	// Changed is how many compared requests would go to another provider
+	Changed int `json:"changed"`
+
+	Actual    Outcome `json:"actual"`
+	Projected Outcome `json:"projected"`
+	// Relative changes of the projection, e.g. -0.2 is 20% cheaper
+	CostChange    float64 `json:"cost_change"`
+	LatencyChange float64 `json:"latency_change"`
+}
+
+// Finish computes the relative changes once every request is added
+func (r *Report) Finish() {
+	r.CostChange = relativeChange(r.Actual.Cost, r.Projected.Cost)
+	r.LatencyChange = relativeChange(r.Actual.AverageLatencyMs, r.Projected.AverageLatencyMs)
+}
+
+// relativeChange is the change from actual to projected as a fraction of actual
+func relativeChange(actual, projected float64) float64 {
+	if actual == 0 {
+		return 0
+	}
+	return (projected - actual) / actual
+}
@@ -0,0 +1,285 @@
+// Package sla checks providers against the service levels operators expect
+// of them and scores each calendar month: availability, p95 latency and
+// error rate, computed from the analytics records of the month
+package sla
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/analytics"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/logging"
+	"gopkg.in/yaml.v3"
+)
+
+var logger = logging.Module("sla")
+
+// AvailabilityWindow is the granularity of availability: a window in which a
+// provider was sent requests counts as up when at least one succeeded
+const AvailabilityWindow = 5 * time.Minute
+
+// monthLayout formats months as scorecards name them, e.g. 2024-05
+const monthLayout = "2006-01"
+
+// Metrics a provider can breach
+const (
+	MetricAvailability = "availability"
+	MetricP95Latency   = "p95_latency"
+	MetricErrorRate    = "error_rate"
+)
+
+// Scorecard statuses of a provider
+const (
+	StatusCompliant        = "compliant"
+	StatusBreached         = "breached"
+	StatusInsufficientData = "insufficient_data"
+)
+
+// Target is the service level expected of a provider. Zero fields are not
+// checked. Provider "*" applies to providers without a target of their own
+type Target struct {
+	Provider        string  `yaml:"provider" json:"provider"`
+	AvailabilityPct float64 `yaml:"availability_pct,omitempty" json:"availability_pct,omitempty"`
+	P95LatencyMs    float64 `yaml:"p95_latency_ms,omitempty" json:"p95_latency_ms,omitempty"`
+	ErrorRatePct    float64 `yaml:"error_rate_pct,omitempty" json:"error_rate_pct,omitempty"`
+	// MinRequests is how many requests a month needs before it is judged
+	MinRequests int `yaml:"min_requests,omitempty" json:"min_requests,omitempty"`
+}
+
+// Targets are the SLA targets of the providers
+type Targets struct {
+	targets []Target
+}
+
+// NewTargets validates targets
+func NewTargets(targets []Target) (*Targets, error) {
+	seen := make(map[string]bool)
+	for i, target := range targets {
+		name := strings.ToLower(strings.TrimSpace(target.Provider))
+		switch {
+		case name == "":
+			return nil, fmt.Errorf("SLA target #%d names no provider", i+1)
+		case seen[name]:
+			return nil, fmt.Errorf("SLA target for %s is declared twice", target.Provider)
+		case target.AvailabilityPct < 0 || target.AvailabilityPct > 100:
+			return nil, fmt.Errorf("SLA target for %s: availability_pct must be between 0 and 100", target.Provider)
+		case target.ErrorRatePct < 0 || target.ErrorRatePct > 100:
+			return nil, fmt.Errorf("SLA target for %s: error_rate_pct must be between 0 and 100", target.Provider)
+		case target.P95LatencyMs < 0 || target.MinRequests < 0:
+			return nil, fmt.Errorf("SLA target for %s: p95_latency_ms and min_requests cannot be negative", target.Provider)
+		}
+		seen[name] = true
+	}
+	return &Targets{targets: append([]Target(nil), targets...)}, nil
+}
+
+// LoadTargets reads targets from a YAML file with a top-level "targets" list
+func LoadTargets(path string) (*Targets, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SLA targets: %w", err)
+	}
+
+	var file struct {
+		Targets []Target `yaml:"targets"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse SLA targets %s: %w", path, err)
+	}
+	return NewTargets(file.Targets)
+}
+
+// List returns the declared targets
+func (t *Targets) List() []Target {
+	return append([]Target(nil), t.targets...)
+}
+
+// For returns the target of provider, falling back to "*"
+func (t *Targets) For(provider string) (Target, bool) {
+	var fallback *Target
+	for i, target := range t.targets {
+		if strings.EqualFold(target.Provider, provider) {
+			return target, true
+		}
+		if target.Provider == "*" {
+			fallback = &t.targets[i]
+		}
+	}
+	if fallback == nil {
+		return Target{}, false
+	}
+	target := *fallback
+	target.Provider = provider
+	return target, true
+}
+
+// Breach is a metric that missed its target
+type Breach struct {
+	Metric string  `json:"metric"`
+	Target float64 `json:"target"`
+	Actual float64 `json:"actual"`
+}
+
+// ProviderScorecard is how one provider did against its target in a month
+type ProviderScorecard struct {
+	Provider        string   `json:"provider"`
+	Target          Target   `json:"target"`
+	Requests        int      `json:"requests"`
+	Failed          int      `json:"failed"`
+	AvailabilityPct float64  `json:"availability_pct"`
+	P95LatencyMs    float64  `json:"p95_latency_ms"`
+	ErrorRatePct    float64  `json:"error_rate_pct"`
+	Status          string   `json:"status"`
+	Breaches        []Breach `json:"breaches,omitempty"`
+}
+
+// Scorecard is the SLA compliance of every targeted provider over a UTC
+// calendar month. Until the month ends it covers the month to date
+type Scorecard struct {
+	Month       string              `json:"month"`
+	From        time.Time           `json:"from"`
+	To          time.Time           `json:"to"`
+	GeneratedAt time.Time           `json:"generated_at"`
+	Final       bool                `json:"final"`
+	Providers   []ProviderScorecard `json:"providers"`
+}
+
+// Breached returns the providers that missed a target
+func (s *Scorecard) Breached() []ProviderScorecard {
+	var breached []ProviderScorecard
+	for _, provider := range s.Providers {
+		if provider.Status == StatusBreached {
+			breached = append(breached, provider)
+		}
+	}
+	return breached
+}
+
+// MonthStart returns the start of the UTC calendar month containing t
+func MonthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// ParseMonth parses a month such as 2024-05 into its start
+func ParseMonth(month string) (time.Time, error) {
+	start, err := time.Parse(monthLayout, month)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid month %q: want YYYY-MM", month)
+	}
+	return start, nil
+}
+
+// Build scores the providers in records against targets for the month
+// starting at month, up to now. Providers named by a target appear even
+// without traffic; others only when "*" covers them
+func Build(targets *Targets, records []analytics.RequestMetrics, month, now time.Time) *Scorecard {
+	from := MonthStart(month)
+	to := from.AddDate(0, 1, 0)
+	scorecard := &Scorecard{
+		Month:       from.Format(monthLayout),
+		From:        from,
+		To:          to,
+		GeneratedAt: now.UTC(),
+		Final:       !now.Before(to),
+		Providers:   []ProviderScorecard{},
+	}
+
+	byProvider := make(map[string][]analytics.RequestMetrics)
+	names := make(map[string]string)
+	for _, record := range records {
+		if record.Timestamp.Before(from) || !record.Timestamp.Before(to) || record.ProviderID == "" {
+			continue
+		}
+		key := strings.ToLower(record.ProviderID)
+		byProvider[key] = append(byProvider[key], record)
+		names[key] = record.ProviderID
+	}
+	for _, target := range targets.List() {
+		if key := strings.ToLower(target.Provider); target.Provider != "*" && names[key] == "" {
+			names[key] = target.Provider
+		}
+	}
+
+	for key, name := range names {
+		target, ok := targets.For(name)
+		if !ok {
+			continue
+		}
+		scorecard.Providers = append(scorecard.Providers, score(target, byProvider[key]))
+	}
+	sort.Slice(scorecard.Providers, func(i, j int) bool {
+		return strings.ToLower(scorecard.Providers[i].Provider) < strings.ToLower(scorecard.Providers[j].Provider)
+	})
+	return scorecard
+}
+
+// score measures one provider's requests against its target
+func score(target Target, records []analytics.RequestMetrics) ProviderScorecard {
+	card := ProviderScorecard{
+		Provider: target.Provider,
+		Target:   target,
+		Requests: len(records),
+		Status:   StatusCompliant,
+	}
+	if len(records) == 0 {
+		card.Status = StatusInsufficientData
+		return card
+	}
+
+	windows := make(map[int64]bool)
+	var latencies []float64
+	for _, record := range records {
+		window := record.Timestamp.UnixNano() / int64(AvailabilityWindow)
+		windows[window] = windows[window] || record.Success
+		if record.Success {
+			latencies = append(latencies, float64(record.Duration))
+		} else {
+			card.Failed++
+		}
+	}
+	up := 0
+	for _, ok := range windows {
+		if ok {
+			up++
+		}
+	}
+	card.AvailabilityPct = 100 * float64(up) / float64(len(windows))
+	card.ErrorRatePct = 100 * float64(card.Failed) / float64(card.Requests)
+	card.P95LatencyMs = percentile(latencies, 0.95)
+	if card.Requests < target.MinRequests {
+		card.Status = StatusInsufficientData
+		return card
+	}
+
+	if target.AvailabilityPct > 0 && card.AvailabilityPct < target.AvailabilityPct {
+		card.Breaches = append(card.Breaches, Breach{Metric: MetricAvailability, Target: target.AvailabilityPct, Actual: card.AvailabilityPct})
+	}
+	if target.P95LatencyMs > 0 && card.P95LatencyMs > target.P95LatencyMs {
+		card.Breaches = append(card.Breaches, Breach{Metric: MetricP95Latency, Target: target.P95LatencyMs, Actual: card.P95LatencyMs})
+	}
+	if target.ErrorRatePct > 0 && card.ErrorRatePct > target.ErrorRatePct {
+		card.Breaches = append(card.Breaches, Breach{Metric: MetricErrorRate, Target: target.ErrorRatePct, Actual: card.ErrorRatePct})
+	}
+	if len(card.Breaches) > 0 {
+		card.Status = StatusBreached
+	}
+	return card
+}
+
+// percentile returns the nearest-rank percentile p of values, 0 without any
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+	rank := int(math.Ceil(p*float64(len(values)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return values[rank]
+}
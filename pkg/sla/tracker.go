@@ -0,0 +1,211 @@
+package sla
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/analytics"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/events"
+)
+
+// DefaultCheckInterval is how often the month to date is checked for breaches
+const DefaultCheckInterval = 15 * time.Minute
+
+// Tracker scores the providers of the current month as requests arrive,
+// alerts once per provider, month and metric when a target is breached, and
+// keeps the scorecard of every month, saved as <month>.json with a directory
+type Tracker struct {
+	targets    *Targets
+	engine     *analytics.AnalyticsEngine
+	dir        string
+	events     *events.Bus
+	scorecards map[string]*Scorecard
+	// alerted holds the month/provider/metric breaches already alerted on
+	alerted map[string]bool
+	mutex   sync.RWMutex
+}
+
+// NewTracker creates a tracker of targets over the records of engine, loading
+// the scorecards already saved in dir. An empty dir keeps them in memory only
+func NewTracker(targets *Targets, engine *analytics.AnalyticsEngine, dir string) (*Tracker, error) {
+	tracker := &Tracker{
+		targets:    targets,
+		engine:     engine,
+		dir:        dir,
+		scorecards: make(map[string]*Scorecard),
+		alerted:    make(map[string]bool),
+	}
+	if dir == "" {
+		return tracker, nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create scorecard directory: %w", err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read scorecard %s: %w", file, err)
+		}
+		var scorecard Scorecard
+		if err := json.Unmarshal(data, &scorecard); err != nil {
+			logger.Warnf("Skipping unreadable scorecard %s: %v", file, err)
+			continue
+		}
+		tracker.scorecards[scorecard.Month] = &scorecard
+		// Breaches of saved months were alerted on before the restart
+		for _, provider := range scorecard.Breached() {
+			for _, breach := range provider.Breaches {
+				tracker.alerted[alertKey(scorecard.Month, provider.Provider, breach.Metric)] = true
+			}
+		}
+	}
+	return tracker, nil
+}
+
+// SetEvents publishes a provider.sla_breached event for every new breach
+func (t *Tracker) SetEvents(bus *events.Bus) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.events = bus
+}
+
+// Targets returns the declared targets
+func (t *Tracker) Targets() []Target {
+	return t.targets.List()
+}
+
+// Months lists the months with a scorecard, newest first
+func (t *Tracker) Months() []string {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	months := make([]string, 0, len(t.scorecards))
+	for month := range t.scorecards {
+		months = append(months, month)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(months)))
+	return months
+}
+
+// Scorecard returns the scorecard of month. The current month, and months
+// not scored yet, are scored from the analytics records on the spot
+func (t *Tracker) Scorecard(month time.Time, now time.Time) *Scorecard {
+	key := MonthStart(month).Format(monthLayout)
+	t.mutex.RLock()
+	scorecard, ok := t.scorecards[key]
+	t.mutex.RUnlock()
+	if ok && scorecard.Final {
+		return scorecard
+	}
+	return t.build(month, now)
+}
+
+// Check scores the month to date, alerts on new breaches and saves the
+// scorecard. The month before is scored a last time first, if it was not
+// final yet
+func (t *Tracker) Check(ctx context.Context, now time.Time) error {
+	current := MonthStart(now)
+	previous := current.AddDate(0, -1, 0)
+	t.mutex.RLock()
+	last, ok := t.scorecards[previous.Format(monthLayout)]
+	t.mutex.RUnlock()
+	if ok && !last.Final {
+		if err := t.record(ctx, t.build(previous, now)); err != nil {
+			return err
+		}
+	}
+	return t.record(ctx, t.build(current, now))
+}
+
+// Start checks every interval until ctx is done
+func (t *Tracker) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := t.Check(ctx, time.Now()); err != nil {
+				logger.Errorf("Failed to check SLAs: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// build scores month from the analytics records
+func (t *Tracker) build(month, now time.Time) *Scorecard {
+	from := MonthStart(month)
+	return Build(t.targets, t.engine.Records(from, from.AddDate(0, 1, 0)), from, now)
+}
+
+// record alerts on the new breaches of scorecard and keeps it
+func (t *Tracker) record(ctx context.Context, scorecard *Scorecard) error {
+	t.mutex.Lock()
+	bus := t.events
+	var breaches []events.SLABreachData
+	for _, provider := range scorecard.Breached() {
+		for _, breach := range provider.Breaches {
+			key := alertKey(scorecard.Month, provider.Provider, breach.Metric)
+			if t.alerted[key] {
+				continue
+			}
+			t.alerted[key] = true
+			breaches = append(breaches, events.SLABreachData{
+				Provider: provider.Provider,
+				Month:    scorecard.Month,
+				Metric:   breach.Metric,
+				Target:   breach.Target,
+				Actual:   breach.Actual,
+				Requests: provider.Requests,
+			})
+		}
+	}
+	t.scorecards[scorecard.Month] = scorecard
+	t.mutex.Unlock()
+
+	for _, breach := range breaches {
+		logger.Warnf("Provider %s breached its %s SLA for %s: %.2f against a target of %.2f",
+			breach.Provider, breach.Metric, breach.Month, breach.Actual, breach.Target)
+		bus.Publish(ctx, events.ProviderSLABreached, breach)
+	}
+	return t.save(scorecard)
+}
+
+// save writes scorecard to the directory, if any
+func (t *Tracker) save(scorecard *Scorecard) error {
+	if t.dir == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(scorecard, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so readers never see a partial scorecard
+	path := filepath.Join(t.dir, scorecard.Month+".json")
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("failed to write scorecard: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to write scorecard: %w", err)
+	}
+	return nil
+}
+
+// alertKey identifies one breach of one month
+func alertKey(month, provider, metric string) string {
+	return month + "/" + provider + "/" + metric
+}
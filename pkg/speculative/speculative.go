@@ -0,0 +1,149 @@
+// Package speculative supports draft-then-verify routing: a cheap model
+// drafts the answer and an expensive one is only asked to verify and repair
+// it when the draft fails a quality check
+package speculative
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// DefaultMinDraftLength is the fewest characters a draft may have
+const DefaultMinDraftLength = 20
+
+// Verdict is the outcome of checking a draft
+type Verdict struct {
+	Passed bool `json:"passed"`
+	// Reason explains a failed check
+	Reason string `json:"reason,omitempty"`
+}
+
+// Check decides whether a draft answers prompt well enough to be returned
+// without verification
+type Check interface {
+	Check(prompt, draft string) Verdict
+}
+
+// HeuristicCheck fails drafts that are too short, refuse the task or stop
+// mid-sentence
+type HeuristicCheck struct {
+	// MinLength is the fewest characters a draft may have
+	MinLength int
+	// Refusals are phrases, matched case-insensitively, that mark a draft
+	// as declining the task
+	Refusals []string
+}
+
+// NewHeuristicCheck creates a check with the default length and refusal
+// phrases
+func NewHeuristicCheck() *HeuristicCheck {
+	return &HeuristicCheck{
+		MinLength: DefaultMinDraftLength,
+		Refusals: []string{
+			"i cannot",
+			"i can't",
+			"i'm unable to",
+			"i am unable to",
+			"as an ai",
+			"i don't know",
+		},
+	}
+}
+
+// Check implements Check
+func (hc *HeuristicCheck) Check(prompt, draft string) Verdict {
+	trimmed := strings.TrimSpace(draft)
+	if length := utf8.RuneCountInString(trimmed); length < hc.MinLength {
+		return Verdict{Reason: fmt.Sprintf("draft has %d characters, fewer than %d", length, hc.MinLength)}
+	}
+	lower := strings.ToLower(trimmed)
+	for _, refusal := range hc.Refusals {
+		if strings.Contains(lower, refusal) {
+			return Verdict{Reason: fmt.Sprintf("draft declines the task (%q)", refusal)}
+		}
+	}
+	if strings.HasSuffix(trimmed, "...") || strings.Count(trimmed, "```")%2 == 1 {
+		return Verdict{Reason: "draft appears truncated"}
+	}
+	return Verdict{Passed: true}
+}
+
+// Outcome is the cost of one speculative request
+type Outcome struct {
+	// Verified is true when the draft failed and was sent for verification
+	Verified bool
+	// DraftCost and VerifyCost are what the two calls cost
+	DraftCost  float64
+	VerifyCost float64
+	// BaselineCost is what sending the request straight to the verifier
+	// would have cost
+	BaselineCost float64
+}
+
+// Stats summarizes speculative requests and what they saved
+type Stats struct {
+	Requests      int64 `json:"requests"`
+	Verifications int64 `json:"verifications"`
+	// VerificationRate is the share of drafts that failed the quality check
+	VerificationRate float64 `json:"verification_rate"`
+	DraftCost        float64 `json:"draft_cost"`
+	VerifyCost       float64 `json:"verify_cost"`
+	BaselineCost     float64 `json:"baseline_cost"`
+	// Savings is BaselineCost less the draft and verification costs; it is
+	// negative when verification was needed too often to pay off
+	Savings float64 `json:"savings"`
+}
+
+// Recorder accumulates speculative outcomes. It is safe for concurrent use
+type Recorder struct {
+	stats Stats
+	mutex sync.Mutex
+}
+
+// NewRecorder creates an empty recorder
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Record adds the outcome of a speculative request
+func (r *Recorder) Record(outcome Outcome) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.stats.Requests++
+	if outcome.Verified {
+		r.stats.Verifications++
+	}
+	r.stats.DraftCost += outcome.DraftCost
+	r.stats.VerifyCost += outcome.VerifyCost
+	r.stats.BaselineCost += outcome.BaselineCost
+}
+
+// Restore replaces the totals, e.g. from a saved snapshot
+func (r *Recorder) Restore(stats Stats) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.stats = Stats{
+		Requests:      stats.Requests,
+		Verifications: stats.Verifications,
+		DraftCost:     stats.DraftCost,
+		VerifyCost:    stats.VerifyCost,
+		BaselineCost:  stats.BaselineCost,
+	}
+}
+
+// Stats returns the totals so far
+func (r *Recorder) Stats() Stats {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stats := r.stats
+	if stats.Requests > 0 {
+		stats.VerificationRate = float64(stats.Verifications) / float64(stats.Requests)
+	}
+	stats.Savings = stats.BaselineCost - stats.DraftCost - stats.VerifyCost
+	return stats
+}
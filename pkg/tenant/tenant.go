@@ -0,0 +1,421 @@
+// Package tenant separates organizations sharing one gateway. Each tenant has
+// its own provider inventory, API keys and monthly budget, and its requests
+// are attributed to it in analytics
+package tenant
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/logging"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/middleware"
+)
+
+var logger = logging.Module("tenant")
+
+var (
+	// ErrNotFound is returned for a tenant ID that is not registered
+	ErrNotFound = errors.New("tenant not found")
+	// ErrExists is returned when creating a tenant whose ID is taken
+	ErrExists = errors.New("tenant already exists")
+	// ErrForbidden is returned when a request may not act as the tenant it names
+	ErrForbidden = errors.New("request may not use this tenant")
+	// ErrDisabled is returned for requests of a disabled tenant
+	ErrDisabled = errors.New("tenant is disabled")
+	// ErrBudgetExceeded is returned once a tenant has spent its monthly budget
+	ErrBudgetExceeded = errors.New("tenant budget exceeded")
+	// ErrInvalid is returned for tenant IDs and specs that fail validation
+	ErrInvalid = errors.New("invalid tenant")
+)
+
+// validID restricts tenant IDs to values safe in headers, paths and logs
+var validID = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// Tenant is one organization using the gateway
+type Tenant struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	// Providers is the tenant's provider inventory; empty allows every provider
+	Providers []string `json:"providers,omitempty"`
+	// KeyIDs are the API keys bound to the tenant, as middleware.HashKey IDs.
+	// Requests with a bound key always belong to the tenant, and a tenant
+	// with bound keys can only be used with one of them
+	KeyIDs []string `json:"key_ids,omitempty"`
+	// MonthlyBudget caps the estimated cost of the tenant's requests per
+	// calendar month (UTC) in USD; zero is unlimited
+	MonthlyBudget float64   `json:"monthly_budget,omitempty"`
+	Disabled      bool      `json:"disabled,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Spec is the writable part of a tenant, accepted by Create and Update
+type Spec struct {
+	Name          string   `json:"name,omitempty"`
+	Providers     []string `json:"providers,omitempty"`
+	KeyIDs        []string `json:"key_ids,omitempty"`
+	MonthlyBudget float64  `json:"monthly_budget,omitempty"`
+	Disabled      bool     `json:"disabled,omitempty"`
+	// APIKeys are bound like KeyIDs; only their IDs are stored
+	APIKeys []string `json:"api_keys,omitempty"`
+}
+
+// Usage is a tenant's spend in the current month
+type Usage struct {
+	Tenant        string  `json:"tenant"`
+	Month         string  `json:"month"`
+	Requests      int64   `json:"requests"`
+	Spend         float64 `json:"spend"`
+	MonthlyBudget float64 `json:"monthly_budget,omitempty"`
+	// Remaining is the budget left this month, absent when unlimited
+	Remaining *float64 `json:"remaining,omitempty"`
+}
+
+// spend is the cost recorded for a tenant in one month
+type spend struct {
+	month    string
+	requests int64
+	cost     float64
+}
+
+// Registry holds the tenants, saved as JSON at its path, and their spend in
+// the current month, kept in memory. It is safe for concurrent use
+type Registry struct {
+	path    string
+	tenants map[string]*Tenant
+	keys    map[string]string
+	spend   map[string]*spend
+	mutex   sync.RWMutex
+}
+
+// NewRegistry creates a registry, loading the tenants saved at path. An empty
+// path keeps tenants in memory only
+func NewRegistry(path string) (*Registry, error) {
+	registry := &Registry{
+		path:    path,
+		tenants: make(map[string]*Tenant),
+		keys:    make(map[string]string),
+		spend:   make(map[string]*spend),
+	}
+	if path == "" {
+		return registry, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return registry, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenants: %w", err)
+	}
+	var tenants []*Tenant
+	if err := json.Unmarshal(data, &tenants); err != nil {
+		return nil, fmt.Errorf("failed to parse tenants %s: %w", path, err)
+	}
+	for _, tenant := range tenants {
+		registry.tenants[tenant.ID] = tenant
+	}
+	registry.indexKeys()
+	return registry, nil
+}
+
+// List returns every tenant, by ID
+func (r *Registry) List() []*Tenant {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	tenants := make([]*Tenant, 0, len(r.tenants))
+	for _, tenant := range r.tenants {
+		copied := *tenant
+		tenants = append(tenants, &copied)
+	}
+	sort.Slice(tenants, func(i, j int) bool {
+		return tenants[i].ID < tenants[j].ID
+	})
+	return tenants
+}
+
+// Get returns a copy of the tenant with id
+func (r *Registry) Get(id string) (*Tenant, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	tenant, ok := r.tenants[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	copied := *tenant
+	return &copied, nil
+}
+
+// Create registers a tenant
+func (r *Registry) Create(id string, spec Spec) (*Tenant, error) {
+	if !validID.MatchString(id) {
+		return nil, fmt.Errorf("%w: ID %q must use lower-case letters, digits, - and _", ErrInvalid, id)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.tenants[id]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrExists, id)
+	}
+	now := time.Now().UTC()
+	tenant := &Tenant{ID: id, CreatedAt: now}
+	if err := r.apply(tenant, spec, now); err != nil {
+		return nil, err
+	}
+	r.tenants[id] = tenant
+	if err := r.save(); err != nil {
+		delete(r.tenants, id)
+		r.indexKeys()
+		return nil, err
+	}
+	logger.Infof("Created tenant %s", id)
+
+	copied := *tenant
+	return &copied, nil
+}
+
+// Update replaces the writable fields of a tenant with spec
+func (r *Registry) Update(id string, spec Spec) (*Tenant, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	current, ok := r.tenants[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	updated := *current
+	if err := r.apply(&updated, spec, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	r.tenants[id] = &updated
+	if err := r.save(); err != nil {
+		r.tenants[id] = current
+		r.indexKeys()
+		return nil, err
+	}
+	logger.Infof("Updated tenant %s", id)
+
+	copied := updated
+	return &copied, nil
+}
+
+// Delete removes a tenant, unbinding its keys
+func (r *Registry) Delete(id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	tenant, ok := r.tenants[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	delete(r.tenants, id)
+	if err := r.save(); err != nil {
+		r.tenants[id] = tenant
+		r.indexKeys()
+		return err
+	}
+	delete(r.spend, id)
+	logger.Infof("Deleted tenant %s", id)
+	return nil
+}
+
+// Resolve returns the tenant a request belongs to, from the tenant its key is
+// bound to or else the tenant named by header. It returns nil for requests
+// of no tenant
+func (r *Registry) Resolve(keyID, header string) (*Tenant, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var tenant *Tenant
+	if id, ok := r.keys[keyID]; ok && keyID != "" {
+		tenant = r.tenants[id]
+		if header != "" && header != id {
+			return nil, fmt.Errorf("%w: the API key belongs to another tenant", ErrForbidden)
+		}
+	} else if header != "" {
+		found, ok := r.tenants[header]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, header)
+		}
+		if len(found.KeyIDs) > 0 {
+			return nil, fmt.Errorf("%w: tenant %s requires one of its API keys", ErrForbidden, header)
+		}
+		tenant = found
+	}
+
+	if tenant == nil {
+		return nil, nil
+	}
+	if tenant.Disabled {
+		return nil, fmt.Errorf("%w: %s", ErrDisabled, tenant.ID)
+	}
+	copied := *tenant
+	return &copied, nil
+}
+
+// CheckBudget returns ErrBudgetExceeded once the tenant has spent its
+// monthly budget
+func (r *Registry) CheckBudget(id string) error {
+	usage, err := r.Usage(id)
+	if err != nil {
+		return err
+	}
+	if usage.Remaining != nil && *usage.Remaining <= 0 {
+		return fmt.Errorf("%w: %s spent $%.2f of $%.2f in %s", ErrBudgetExceeded, id, usage.Spend, usage.MonthlyBudget, usage.Month)
+	}
+	return nil
+}
+
+// RecordSpend adds the cost of a request made at the given time to the
+// tenant's spend, and reports whether it spent the last of the tenant's
+// budget. Requests of earlier months are ignored
+func (r *Registry) RecordSpend(id string, at time.Time, cost float64) bool {
+	month := monthOf(at)
+	if month != monthOf(time.Now()) {
+		return false
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	tenant, ok := r.tenants[id]
+	if !ok {
+		return false
+	}
+	current, ok := r.spend[id]
+	if !ok || current.month != month {
+		current = &spend{month: month}
+		r.spend[id] = current
+	}
+	current.requests++
+	current.cost += cost
+	return exhausted(tenant.MonthlyBudget, current.cost, cost)
+}
+
+// Usage returns the tenant's spend in the current month
+func (r *Registry) Usage(id string) (*Usage, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	tenant, ok := r.tenants[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	usage := &Usage{Tenant: id, Month: monthOf(time.Now()), MonthlyBudget: tenant.MonthlyBudget}
+	if current, ok := r.spend[id]; ok && current.month == usage.Month {
+		usage.Requests = current.requests
+		usage.Spend = current.cost
+	}
+	if tenant.MonthlyBudget > 0 {
+		remaining := tenant.MonthlyBudget - usage.Spend
+		usage.Remaining = &remaining
+	}
+	return usage, nil
+}
+
+// apply validates spec and copies it into tenant
+func (r *Registry) apply(tenant *Tenant, spec Spec, now time.Time) error {
+	if spec.MonthlyBudget < 0 {
+		return fmt.Errorf("%w: monthly_budget must not be negative", ErrInvalid)
+	}
+
+	keyIDs := append([]string(nil), spec.KeyIDs...)
+	for _, key := range spec.APIKeys {
+		keyIDs = append(keyIDs, middleware.HashKey(key))
+	}
+	for _, keyID := range keyIDs {
+		if owner, ok := r.keys[keyID]; ok && owner != tenant.ID {
+			return fmt.Errorf("%w: key %s is already bound to tenant %s", ErrInvalid, keyID, owner)
+		}
+	}
+	slices.Sort(keyIDs)
+
+	tenant.Name = strings.TrimSpace(spec.Name)
+	tenant.Providers = spec.Providers
+	tenant.KeyIDs = slices.Compact(keyIDs)
+	tenant.MonthlyBudget = spec.MonthlyBudget
+	tenant.Disabled = spec.Disabled
+	tenant.UpdatedAt = now
+	return nil
+}
+
+// save writes the tenants to the registry file and re-indexes their keys
+func (r *Registry) save() error {
+	r.indexKeys()
+	if r.path == "" {
+		return nil
+	}
+
+	tenants := make([]*Tenant, 0, len(r.tenants))
+	for _, tenant := range r.tenants {
+		tenants = append(tenants, tenant)
+	}
+	sort.Slice(tenants, func(i, j int) bool {
+		return tenants[i].ID < tenants[j].ID
+	})
+	data, err := json.MarshalIndent(tenants, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+	// Write to a temporary file first so a crash never leaves a partial file
+	if err := os.WriteFile(r.path+".tmp", data, 0600); err != nil {
+		return fmt.Errorf("failed to write tenants: %w", err)
+	}
+	if err := os.Rename(r.path+".tmp", r.path); err != nil {
+		return fmt.Errorf("failed to write tenants: %w", err)
+	}
+	return nil
+}
+
+// indexKeys rebuilds the key ID to tenant index
+func (r *Registry) indexKeys() {
+	r.keys = make(map[string]string)
+	for id, tenant := range r.tenants {
+		for _, keyID := range tenant.KeyIDs {
+			r.keys[keyID] = id
+		}
+	}
+}
+
+// exhausted reports whether adding cost brought spent up to budget
+func exhausted(budget, spent, cost float64) bool {
+	return budget > 0 && spent >= budget && spent-cost < budget
+}
+
+// monthOf returns the UTC calendar month of t, e.g. "2024-05"
+func monthOf(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// Middleware resolves the tenant of each request from its API key or the
+// X-Tenant-ID header and stores it in the request context. Requests that may
+// not use the tenant they name are rejected with 403
+func (r *Registry) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		tenant, err := r.Resolve(middleware.KeyID(req), req.Header.Get(middleware.TenantHeader))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if tenant != nil {
+			req = req.WithContext(middleware.WithTenant(req.Context(), tenant.ID))
+		}
+		next.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,115 @@
+// Package tier defines the provider tiers shared by selection, validation and
+// config generation.
+package tier
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/logging"
+)
+
+var logger = logging.Module("tier")
+
+// Tier is the trust and quality level of a provider
+type Tier string
+
+const (
+	// Official providers are first-party APIs with the highest quality and cost
+	Official Tier = "official"
+	// Community providers are hosted by third parties, usually free or cheap
+	Community Tier = "community"
+	// Unofficial providers are wrappers and local scripts with no guarantees
+	Unofficial Tier = "unofficial"
+)
+
+// all lists the tiers from highest to lowest rank
+var all = []Tier{Official, Community, Unofficial}
+
+// legacyTiers maps the free/basic/premium/enterprise names used by earlier
+// releases onto the current tiers
+var legacyTiers = map[string]Tier{
+	"enterprise": Official,
+	"premium":    Official,
+	"basic":      Community,
+	"free":       Unofficial,
+}
+
+// warnedLegacy remembers which legacy names have already been logged
+var warnedLegacy sync.Map
+
+// All returns every tier, highest rank first
+func All() []Tier {
+	return append([]Tier(nil), all...)
+}
+
+// Parse converts s to a Tier, ignoring case and surrounding space. Legacy
+// names are accepted with a deprecation warning; anything else is an error
+func Parse(s string) (Tier, error) {
+	t := Normalize(s)
+	if !t.Valid() {
+		return t, fmt.Errorf("invalid tier %q: must be one of %s", s, strings.Join(Names(), ", "))
+	}
+	return t, nil
+}
+
+// Normalize lower-cases s and maps legacy names onto current tiers without
+// validating the result. Decoders use it so that validation can report the
+// offending provider
+func Normalize(s string) Tier {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if t, ok := legacyTiers[name]; ok {
+		if _, warned := warnedLegacy.LoadOrStore(name, true); !warned {
+			logger.Warnf("Tier %q is deprecated, treating it as %q", name, t)
+		}
+		return t
+	}
+	return Tier(name)
+}
+
+// Names returns the tier names, highest rank first
+func Names() []string {
+	names := make([]string, len(all))
+	for i, t := range all {
+		names[i] = string(t)
+	}
+	return names
+}
+
+// Valid reports whether t is one of the defined tiers
+func (t Tier) Valid() bool {
+	return t.Rank() > 0
+}
+
+// Rank orders tiers by expected quality: Official is highest, Unofficial
+// lowest and unknown tiers rank 0
+func (t Tier) Rank() int {
+	switch t {
+	case Official:
+		return 3
+	case Community:
+		return 2
+	case Unofficial:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// AtLeast reports whether t ranks the same as or higher than other
+func (t Tier) AtLeast(other Tier) bool {
+	return t.Rank() >= other.Rank()
+}
+
+// String returns the tier name
+func (t Tier) String() string {
+	return string(t)
+}
+
+// UnmarshalText normalizes tiers read from JSON and YAML, so legacy names in
+// existing files keep working
+func (t *Tier) UnmarshalText(text []byte) error {
+	*t = Normalize(string(text))
+	return nil
+}
@@ -0,0 +1,187 @@
+package usage
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Calibrator defaults
+const (
+	// DefaultSmoothing is the weight of the newest sample in the moving ratio
+	DefaultSmoothing = 0.2
+	// DefaultMinSamples is how many reconciliations a key needs before its
+	// ratio is trusted to correct estimates
+	DefaultMinSamples = 5
+
+	minRatio = 0.1
+	maxRatio = 10.0
+)
+
+// Reconciliation compares a request's estimated tokens with what the provider
+// reported
+type Reconciliation struct {
+	Provider  string  `json:"provider"`
+	Model     string  `json:"model,omitempty"`
+	Estimated int64   `json:"estimated_tokens"`
+	Actual    Usage   `json:"actual"`
+	Delta     int64   `json:"delta"`
+	Ratio     float64 `json:"ratio"`
+}
+
+// Calibration is the learned correction for one provider and model
+type Calibration struct {
+	Provider    string    `json:"provider,omitempty"`
+	Model       string    `json:"model,omitempty"`
+	Ratio       float64   `json:"ratio"`
+	Samples     int64     `json:"samples"`
+	Estimated   int64     `json:"estimated_tokens"`
+	Actual      int64     `json:"actual_tokens"`
+	LastUpdated time.Time `json:"last_updated"`
+}
+
+// calibrationKey identifies a ratio; the zero key holds the global ratio
+type calibrationKey struct {
+	provider string
+	model    string
+}
+
+// Calibrator keeps an exponentially weighted ratio of actual to estimated
+// tokens per provider and model, per provider, and overall, and uses the most
+// specific trusted ratio to correct new estimates
+type Calibrator struct {
+	smoothing  float64
+	minSamples int64
+	ratios     map[calibrationKey]*Calibration
+	mutex      sync.RWMutex
+}
+
+// NewCalibrator creates a calibrator with the default smoothing and sample threshold
+func NewCalibrator() *Calibrator {
+	return &Calibrator{
+		smoothing:  DefaultSmoothing,
+		minSamples: DefaultMinSamples,
+		ratios:     make(map[calibrationKey]*Calibration),
+	}
+}
+
+// Record reconciles an estimate with the provider's reported usage and feeds
+// the ratio into the provider/model, provider and global averages. Reasoning
+// tokens depend on the effort asked for rather than the text, so they count
+// towards the delta but not the ratio
+func (c *Calibrator) Record(provider, model string, estimated int64, actual Usage) Reconciliation {
+	reconciliation := Reconciliation{
+		Provider:  provider,
+		Model:     model,
+		Estimated: estimated,
+		Actual:    actual,
+		Delta:     actual.TotalTokens - estimated,
+	}
+	visible := actual.TotalTokens - actual.ReasoningTokens
+	if estimated <= 0 || visible <= 0 {
+		return reconciliation
+	}
+	reconciliation.Ratio = float64(visible) / float64(estimated)
+
+	ratio := math.Max(minRatio, math.Min(reconciliation.Ratio, maxRatio))
+	now := time.Now()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, key := range []calibrationKey{{provider, model}, {provider, ""}, {}} {
+		calibration, exists := c.ratios[key]
+		if !exists {
+			calibration = &Calibration{Provider: key.provider, Model: key.model, Ratio: ratio}
+			c.ratios[key] = calibration
+		} else {
+			calibration.Ratio += c.smoothing * (ratio - calibration.Ratio)
+		}
+		calibration.Samples++
+		calibration.Estimated += estimated
+		calibration.Actual += visible
+		calibration.LastUpdated = now
+
+		if key.provider == "" {
+			break
+		}
+	}
+	return reconciliation
+}
+
+// Combine sums the reconciliations of calls made for one request, such as
+// the parallel calls of a request for several completions. The provider and
+// model are the first call's
+func Combine(reconciliations []Reconciliation) Reconciliation {
+	var combined Reconciliation
+	for i, r := range reconciliations {
+		if i == 0 {
+			combined.Provider, combined.Model = r.Provider, r.Model
+		}
+		combined.Estimated += r.Estimated
+		combined.Actual = combined.Actual.Add(r.Actual)
+		combined.Delta += r.Delta
+	}
+	if visible := combined.Actual.TotalTokens - combined.Actual.ReasoningTokens; combined.Estimated > 0 && visible > 0 {
+		combined.Ratio = float64(visible) / float64(combined.Estimated)
+	}
+	return combined
+}
+
+// Correct scales an estimate by the most specific ratio with enough samples:
+// provider and model, then provider, then global. Pass an empty provider to
+// use the global ratio alone
+func (c *Calibrator) Correct(provider, model string, estimate int64) int64 {
+	if estimate <= 0 {
+		return estimate
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	for _, key := range []calibrationKey{{provider, model}, {provider, ""}, {}} {
+		if key.provider == "" && key != (calibrationKey{}) {
+			continue
+		}
+		if calibration, ok := c.ratios[key]; ok && calibration.Samples >= c.minSamples {
+			return int64(math.Round(float64(estimate) * calibration.Ratio))
+		}
+	}
+	return estimate
+}
+
+// Restore replaces the ratios of the given calibrations, e.g. from a saved
+// snapshot. Calibrations without samples are ignored
+func (c *Calibrator) Restore(calibrations []Calibration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, calibration := range calibrations {
+		if calibration.Samples <= 0 {
+			continue
+		}
+		calibration.Ratio = math.Max(minRatio, math.Min(calibration.Ratio, maxRatio))
+		restored := calibration
+		c.ratios[calibrationKey{calibration.Provider, calibration.Model}] = &restored
+	}
+}
+
+// Calibrations returns every learned ratio, the global one first and the rest
+// sorted by provider and model
+func (c *Calibrator) Calibrations() []Calibration {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	calibrations := make([]Calibration, 0, len(c.ratios))
+	for _, calibration := range c.ratios {
+		calibrations = append(calibrations, *calibration)
+	}
+	sort.Slice(calibrations, func(i, j int) bool {
+		if calibrations[i].Provider != calibrations[j].Provider {
+			return calibrations[i].Provider < calibrations[j].Provider
+		}
+		return calibrations[i].Model < calibrations[j].Model
+	})
+	return calibrations
+}
@@ -0,0 +1,233 @@
+package usage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxEventSize bounds the memory a StreamMeter holds for one event. Longer
+// events pass through to the reader uninspected
+const MaxEventSize = 64 * 1024
+
+// ErrIncompleteStream is reported for a stream that ended or was closed
+// before the provider gave a finish reason
+var ErrIncompleteStream = errors.New("stream ended without a finish reason")
+
+// StreamStats is what a StreamMeter counted in a streamed response
+type StreamStats struct {
+	Bytes int64 `json:"bytes"`
+	// Chunks are the events carrying data, not counting the [DONE] marker
+	Chunks int64 `json:"chunks"`
+	// Usage is what the provider reported, when Reported
+	Usage    Usage `json:"usage"`
+	Reported bool  `json:"reported"`
+	// CompletionTokens estimates the generated tokens from the streamed text
+	CompletionTokens int64  `json:"estimated_completion_tokens"`
+	FinishReason     string `json:"finish_reason,omitempty"`
+	// Oversized counts events over MaxEventSize, which were not inspected
+	Oversized int64 `json:"oversized,omitempty"`
+}
+
+// streamEvent holds the text and finish reason of every streaming shape the
+// supported providers use
+type streamEvent struct {
+	// OpenAI compatible APIs, chat and legacy completions
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		Text         string  `json:"text"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+
+	// Anthropic content_block_delta and message_delta
+	Delta *struct {
+		Text       string `json:"text"`
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"`
+
+	// Gemini
+	Candidates []struct {
+		Content struct {
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+		} `json:"content"`
+		FinishReason string `json:"finishReason"`
+	} `json:"candidates"`
+
+	// Ollama, which streams newline-delimited JSON rather than events
+	Message *struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	Response   string `json:"response"`
+	DoneReason string `json:"done_reason"`
+
+	// Cohere
+	Text         string `json:"text"`
+	FinishReason string `json:"finish_reason"`
+}
+
+// StreamMeter passes a streamed provider response through unchanged while
+// counting its chunks and text, and picking up the finish reason and usage
+// report as they go by. It keeps at most one event in memory, however long
+// the generation. done is called once, when the stream ends or is closed
+type StreamMeter struct {
+	body     io.ReadCloser
+	done     func(stats StreamStats, err error)
+	event    []byte
+	skipping bool
+	inWord   bool
+	words    int64
+	stats    StreamStats
+	once     sync.Once
+}
+
+// NewStreamMeter meters body, reporting to done. done gets
+// ErrIncompleteStream when the stream ended without a finish reason, or the
+// error reading it failed with
+func NewStreamMeter(body io.ReadCloser, done func(stats StreamStats, err error)) *StreamMeter {
+	return &StreamMeter{body: body, done: done}
+}
+
+// Read implements io.Reader
+func (m *StreamMeter) Read(p []byte) (int, error) {
+	n, err := m.body.Read(p)
+	m.scan(p[:n])
+	if err == io.EOF {
+		m.finish(nil)
+	} else if err != nil {
+		m.finish(err)
+	}
+	return n, err
+}
+
+// Close closes the stream, finishing the count when it was not read to the
+// end
+func (m *StreamMeter) Close() error {
+	err := m.body.Close()
+	m.finish(nil)
+	return err
+}
+
+// scan splits data into lines and inspects each complete one
+func (m *StreamMeter) scan(data []byte) {
+	m.stats.Bytes += int64(len(data))
+	for len(data) > 0 {
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 {
+			m.buffer(data)
+			return
+		}
+		m.buffer(data[:end])
+		if !m.skipping {
+			m.inspect(m.event)
+		}
+		m.event, m.skipping = m.event[:0], false
+		data = data[end+1:]
+	}
+}
+
+// buffer adds part of a line to the current event unless it grows over
+// MaxEventSize, in which case the event is skipped
+func (m *StreamMeter) buffer(part []byte) {
+	if m.skipping {
+		return
+	}
+	if len(m.event)+len(part) > MaxEventSize {
+		m.event, m.skipping = m.event[:0], true
+		m.stats.Oversized++
+		return
+	}
+	m.event = append(m.event, part...)
+}
+
+// inspect counts one line of a server-sent event or newline-delimited JSON
+// stream. Event names, ids and comments are ignored
+func (m *StreamMeter) inspect(line []byte) {
+	line = bytes.TrimSpace(line)
+	if bytes.HasPrefix(line, []byte("data:")) {
+		line = bytes.TrimSpace(line[len("data:"):])
+	} else if len(line) == 0 || line[0] != '{' {
+		return
+	}
+	if len(line) == 0 || bytes.Equal(line, []byte("[DONE]")) {
+		return
+	}
+	m.stats.Chunks++
+
+	if u, ok := parseJSON(line); ok {
+		m.stats.Usage = merge(m.stats.Usage, u)
+		m.stats.Reported = true
+	}
+	// Fields shaped differently by other providers fail to decode, and the
+	// rest of the event is still read
+	var event streamEvent
+	_ = json.Unmarshal(line, &event)
+	for _, choice := range event.Choices {
+		m.count(choice.Delta.Content)
+		m.count(choice.Text)
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			m.stats.FinishReason = *choice.FinishReason
+		}
+	}
+	if event.Delta != nil {
+		m.count(event.Delta.Text)
+		m.setFinishReason(event.Delta.StopReason)
+	}
+	for _, candidate := range event.Candidates {
+		for _, part := range candidate.Content.Parts {
+			m.count(part.Text)
+		}
+		m.setFinishReason(candidate.FinishReason)
+	}
+	if event.Message != nil {
+		m.count(event.Message.Content)
+	}
+	m.count(event.Response)
+	m.setFinishReason(event.DoneReason)
+	m.count(event.Text)
+	m.setFinishReason(event.FinishReason)
+}
+
+// setFinishReason records reason unless it is empty
+func (m *StreamMeter) setFinishReason(reason string) {
+	if reason != "" {
+		m.stats.FinishReason = reason
+	}
+}
+
+// count adds the words of a piece of generated text. Words may span pieces
+func (m *StreamMeter) count(text string) {
+	for len(text) > 0 {
+		r, size := utf8.DecodeRuneInString(text)
+		text = text[size:]
+		if unicode.IsSpace(r) {
+			m.inWord = false
+		} else if !m.inWord {
+			m.inWord = true
+			m.words++
+		}
+	}
+}
+
+// finish inspects a last line without a newline and reports the stream
+func (m *StreamMeter) finish(err error) {
+	m.once.Do(func() {
+		if !m.skipping && len(m.event) > 0 {
+			m.inspect(m.event)
+		}
+		m.event = nil
+		// Like request estimates, 1 token per 0.75 words
+		m.stats.CompletionTokens = int64(float64(m.words) * 1.33)
+		if err == nil && m.stats.FinishReason == "" {
+			err = ErrIncompleteStream
+		}
+		m.done(m.stats, err)
+	})
+}
@@ -0,0 +1,98 @@
+package usage
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// meter reads body through a StreamMeter in small reads and returns what
+// passed through and what the meter reported
+func meter(t *testing.T, body string) (string, StreamStats, error) {
+	t.Helper()
+	var stats StreamStats
+	var reportErr error
+	reports := 0
+	m := NewStreamMeter(io.NopCloser(strings.NewReader(body)), func(s StreamStats, err error) {
+		stats, reportErr = s, err
+		reports++
+	})
+
+	var out bytes.Buffer
+	buf := make([]byte, 7)
+	for {
+		n, err := m.Read(buf)
+		out.Write(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Read: %v", err)
+		}
+	}
+	m.Close()
+	if reports != 1 {
+		t.Fatalf("meter reported %d times, want once", reports)
+	}
+	return out.String(), stats, reportErr
+}
+
+func TestStreamMeterOpenAI(t *testing.T) {
+	body := "data: {\"choices\":[{\"delta\":{\"content\":\"Hello there\"},\"finish_reason\":null}]}\n\n" +
+		"data: {\"choices\":[{\"delta\":{\"content\":\" friend\"},\"finish_reason\":\"stop\"}]}\n\n" +
+		"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":3,\"total_tokens\":8}}\n\n" +
+		"data: [DONE]\n\n"
+
+	out, stats, err := meter(t, body)
+	if out != body {
+		t.Errorf("body changed in passing:\n%q\nwant\n%q", out, body)
+	}
+	if err != nil {
+		t.Errorf("complete stream reported %v", err)
+	}
+	if stats.Chunks != 3 || stats.FinishReason != "stop" || stats.Bytes != int64(len(body)) {
+		t.Errorf("stats = %+v, want 3 chunks, finish reason stop and %d bytes", stats, len(body))
+	}
+	if !stats.Reported || stats.Usage.TotalTokens != 8 {
+		t.Errorf("usage = %+v reported %t, want 8 total tokens", stats.Usage, stats.Reported)
+	}
+	// Three words at 1.33 tokens per word
+	if stats.CompletionTokens != 3 {
+		t.Errorf("estimated completion tokens = %d, want 3", stats.CompletionTokens)
+	}
+}
+
+func TestStreamMeterIncompleteStream(t *testing.T) {
+	_, stats, err := meter(t, "data: {\"choices\":[{\"delta\":{\"content\":\"cut off\"},\"finish_reason\":null}]}\n\n")
+	if !errors.Is(err, ErrIncompleteStream) {
+		t.Errorf("stream without a finish reason reported %v, want ErrIncompleteStream", err)
+	}
+	if stats.Reported {
+		t.Error("usage reported for a stream without a usage block")
+	}
+}
+
+func TestStreamMeterSkipsOversizedEvents(t *testing.T) {
+	large := "data: {\"choices\":[{\"delta\":{\"content\":\"" + strings.Repeat("x ", MaxEventSize) + "\"}}]}\n"
+	body := large + "data: {\"choices\":[{\"delta\":{\"content\":\"done\"},\"finish_reason\":\"length\"}]}\n"
+
+	out, stats, err := meter(t, body)
+	if out != body {
+		t.Error("oversized event did not pass through unchanged")
+	}
+	if err != nil || stats.Oversized != 1 || stats.Chunks != 1 || stats.FinishReason != "length" {
+		t.Errorf("stats = %+v, err %v; want one oversized event skipped and the last one counted", stats, err)
+	}
+}
+
+func TestStreamMeterNDJSON(t *testing.T) {
+	body := "{\"message\":{\"content\":\"Hi\"},\"done\":false}\n" +
+		"{\"message\":{\"content\":\"\"},\"done\":true,\"done_reason\":\"stop\",\"prompt_eval_count\":4,\"eval_count\":2}"
+
+	_, stats, err := meter(t, body)
+	if err != nil || stats.Chunks != 2 || stats.FinishReason != "stop" {
+		t.Errorf("stats = %+v, err %v; want 2 chunks ending with stop", stats, err)
+	}
+}
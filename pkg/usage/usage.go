@@ -0,0 +1,180 @@
+// Package usage reads the token usage providers report with their responses
+// and learns how far the request token estimates are from it
+package usage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+)
+
+// Usage is the token usage reported by a provider for one request
+type Usage struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+	// ReasoningTokens is the part of CompletionTokens a reasoning model spent
+	// thinking. They are billed as output but never reach the response
+	ReasoningTokens int64 `json:"reasoning_tokens,omitempty"`
+}
+
+// IsZero reports whether no tokens were reported
+func (u Usage) IsZero() bool {
+	return u.PromptTokens == 0 && u.CompletionTokens == 0 && u.TotalTokens == 0
+}
+
+// Add returns the usage of two requests together
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		TotalTokens:      u.TotalTokens + other.TotalTokens,
+		ReasoningTokens:  u.ReasoningTokens + other.ReasoningTokens,
+	}
+}
+
+// responseUsage holds every usage shape the supported providers return
+type responseUsage struct {
+	// OpenAI compatible APIs use prompt/completion, Anthropic input/output
+	Usage *struct {
+		PromptTokens     int64 `json:"prompt_tokens"`
+		CompletionTokens int64 `json:"completion_tokens"`
+		TotalTokens      int64 `json:"total_tokens"`
+		InputTokens      int64 `json:"input_tokens"`
+		OutputTokens     int64 `json:"output_tokens"`
+		// OpenAI reports reasoning tokens within completion tokens
+		CompletionTokensDetails *struct {
+			ReasoningTokens int64 `json:"reasoning_tokens"`
+		} `json:"completion_tokens_details"`
+	} `json:"usage"`
+
+	// Gemini
+	UsageMetadata *struct {
+		PromptTokenCount     int64 `json:"promptTokenCount"`
+		CandidatesTokenCount int64 `json:"candidatesTokenCount"`
+		TotalTokenCount      int64 `json:"totalTokenCount"`
+		// Thoughts are counted apart from the candidates but billed with them
+		ThoughtsTokenCount int64 `json:"thoughtsTokenCount"`
+	} `json:"usageMetadata"`
+
+	// Ollama
+	PromptEvalCount *int64 `json:"prompt_eval_count"`
+	EvalCount       *int64 `json:"eval_count"`
+
+	// Cohere
+	Meta *struct {
+		BilledUnits *struct {
+			InputTokens  int64 `json:"input_tokens"`
+			OutputTokens int64 `json:"output_tokens"`
+		} `json:"billed_units"`
+	} `json:"meta"`
+}
+
+// Parse extracts the usage block from a provider response body. It accepts a
+// JSON response or a server-sent event stream, where the last event that
+// carries usage wins. The second result is false when no usage was found
+func Parse(body []byte) (Usage, bool) {
+	body = bytes.TrimSpace(body)
+	if bytes.HasPrefix(body, []byte("data:")) {
+		return parseStream(body)
+	}
+	return parseJSON(body)
+}
+
+// parseStream scans server-sent events for the final usage report
+func parseStream(body []byte) (Usage, bool) {
+	var (
+		found bool
+		last  Usage
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		if u, ok := parseJSON(bytes.TrimSpace(line[len("data:"):])); ok {
+			last = merge(last, u)
+			found = true
+		}
+	}
+	return last, found
+}
+
+// merge combines stream events, as Anthropic reports input tokens when the
+// message starts and output tokens when it ends
+func merge(previous, next Usage) Usage {
+	if next.PromptTokens == 0 {
+		next.PromptTokens = previous.PromptTokens
+	}
+	if next.CompletionTokens == 0 {
+		next.CompletionTokens = previous.CompletionTokens
+	}
+	if next.ReasoningTokens == 0 {
+		next.ReasoningTokens = previous.ReasoningTokens
+	}
+	if next.TotalTokens < next.PromptTokens+next.CompletionTokens {
+		next.TotalTokens = next.PromptTokens + next.CompletionTokens
+	}
+	return next
+}
+
+// parseJSON reads usage from a single JSON document
+func parseJSON(data []byte) (Usage, bool) {
+	var raw responseUsage
+	if len(data) == 0 || data[0] != '{' || json.Unmarshal(data, &raw) != nil {
+		return Usage{}, false
+	}
+
+	var u Usage
+	switch {
+	case raw.Usage != nil:
+		u = Usage{
+			PromptTokens:     raw.Usage.PromptTokens + raw.Usage.InputTokens,
+			CompletionTokens: raw.Usage.CompletionTokens + raw.Usage.OutputTokens,
+			TotalTokens:      raw.Usage.TotalTokens,
+		}
+		if raw.Usage.CompletionTokensDetails != nil {
+			u.ReasoningTokens = raw.Usage.CompletionTokensDetails.ReasoningTokens
+		}
+	case raw.UsageMetadata != nil:
+		u = Usage{
+			PromptTokens:     raw.UsageMetadata.PromptTokenCount,
+			CompletionTokens: raw.UsageMetadata.CandidatesTokenCount + raw.UsageMetadata.ThoughtsTokenCount,
+			TotalTokens:      raw.UsageMetadata.TotalTokenCount,
+			ReasoningTokens:  raw.UsageMetadata.ThoughtsTokenCount,
+		}
+	case raw.PromptEvalCount != nil || raw.EvalCount != nil:
+		if raw.PromptEvalCount != nil {
+			u.PromptTokens = *raw.PromptEvalCount
+		}
+		if raw.EvalCount != nil {
+			u.CompletionTokens = *raw.EvalCount
+		}
+	case raw.Meta != nil && raw.Meta.BilledUnits != nil:
+		u = Usage{
+			PromptTokens:     raw.Meta.BilledUnits.InputTokens,
+			CompletionTokens: raw.Meta.BilledUnits.OutputTokens,
+		}
+	default:
+		return parseNestedMessage(data)
+	}
+
+	if u.TotalTokens == 0 {
+		u.TotalTokens = u.PromptTokens + u.CompletionTokens
+	}
+	return u, !u.IsZero()
+}
+
+// parseNestedMessage handles Anthropic's message_start event, which wraps the
+// usage block in a "message" object
+func parseNestedMessage(data []byte) (Usage, bool) {
+	var event struct {
+		Message json.RawMessage `json:"message"`
+	}
+	if json.Unmarshal(data, &event) != nil || len(event.Message) == 0 || event.Message[0] != '{' {
+		return Usage{}, false
+	}
+	return parseJSON(event.Message)
+}
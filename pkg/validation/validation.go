@@ -0,0 +1,147 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// FieldError describes a single invalid field in a request payload
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError collects field-level problems found in a request payload
+type ValidationError struct {
+	Status int          `json:"-"`
+	Errors []FieldError `json:"fields"`
+}
+
+// Error implements the error interface
+func (ve *ValidationError) Error() string {
+	parts := make([]string, 0, len(ve.Errors))
+	for _, fe := range ve.Errors {
+		if fe.Field == "" {
+			parts = append(parts, fe.Message)
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s: %s", fe.Field, fe.Message))
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+// Add appends a field error
+func (ve *ValidationError) Add(field, message string) {
+	ve.Errors = append(ve.Errors, FieldError{Field: field, Message: message})
+}
+
+// Addf appends a field error with a formatted message
+func (ve *ValidationError) Addf(field, format string, args ...interface{}) {
+	ve.Add(field, fmt.Sprintf(format, args...))
+}
+
+// Merge appends the errors of other, prefixing each field with prefix
+func (ve *ValidationError) Merge(prefix string, other error) {
+	var nested *ValidationError
+	if !errors.As(other, &nested) {
+		ve.Add(prefix, other.Error())
+		return
+	}
+	for _, fe := range nested.Errors {
+		field := prefix
+		if fe.Field != "" {
+			field = prefix + "." + fe.Field
+		}
+		ve.Add(field, fe.Message)
+	}
+}
+
+// HasErrors reports whether any field errors were collected
+func (ve *ValidationError) HasErrors() bool {
+	return len(ve.Errors) > 0
+}
+
+// ErrOrNil returns ve when it holds errors and nil otherwise
+func (ve *ValidationError) ErrOrNil() error {
+	if ve.HasErrors() {
+		return ve
+	}
+	return nil
+}
+
+// DecodeJSON decodes a single JSON document from r into v, translating decoder
+// failures into field-level validation errors. When strict is true unknown
+// fields are rejected.
+func DecodeJSON(r io.Reader, v interface{}, strict bool) error {
+	decoder := json.NewDecoder(r)
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+
+	if err := decoder.Decode(v); err != nil {
+		return translateDecodeError(err)
+	}
+
+	// Reject trailing data such as a second JSON document
+	if decoder.More() {
+		return &ValidationError{
+			Status: http.StatusBadRequest,
+			Errors: []FieldError{{Message: "request body must contain a single JSON object"}},
+		}
+	}
+	return nil
+}
+
+// translateDecodeError converts encoding/json errors into a ValidationError
+func translateDecodeError(err error) error {
+	ve := &ValidationError{Status: http.StatusBadRequest}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var maxBytesErr *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		ve.Status = http.StatusRequestEntityTooLarge
+		ve.Addf("", "request body exceeds the %d byte limit", maxBytesErr.Limit)
+	case errors.As(err, &syntaxErr):
+		ve.Addf("", "malformed JSON at offset %d", syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		ve.Addf(typeErr.Field, "expected %s but got %s", typeErr.Type.String(), typeErr.Value)
+	case errors.Is(err, io.EOF):
+		ve.Add("", "request body is empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		ve.Add("", "request body ended unexpectedly")
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		ve.Add(field, "unknown field")
+	default:
+		ve.Add("", err.Error())
+	}
+	return ve
+}
+
+// WriteError writes err as a JSON error response. ValidationErrors keep their
+// field details; any other error is reported as a generic bad request.
+func WriteError(w http.ResponseWriter, err error) {
+	var ve *ValidationError
+	if !errors.As(err, &ve) {
+		ve = &ValidationError{Status: http.StatusBadRequest, Errors: []FieldError{{Message: err.Error()}}}
+	}
+
+	status := ve.Status
+	if status == 0 {
+		status = http.StatusBadRequest
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  "validation_failed",
+		"fields": ve.Errors,
+	})
+}
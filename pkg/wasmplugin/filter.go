@@ -0,0 +1,126 @@
+package wasmplugin
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/hooks"
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/requestid"
+	"gopkg.in/yaml.v3"
+)
+
+// HookType is the hook type of filter modules in the hooks file
+const HookType = "wasm"
+
+func init() {
+	hooks.Register(HookType, NewFilterHook)
+}
+
+// FilterInput is what filter reads: the request on request events, and the
+// request and its response on response events
+type FilterInput struct {
+	Event    hooks.Event     `json:"event"`
+	Request  *hooks.Request  `json:"request"`
+	Response *hooks.Response `json:"response,omitempty"`
+}
+
+// FilterResult is what filter may answer with. Content replaces the request
+// content on request events and the response content on response events;
+// Reject rejects the request
+type FilterResult struct {
+	Content     *string                `json:"content,omitempty"`
+	Annotations map[string]interface{} `json:"annotations,omitempty"`
+	Reject      string                 `json:"reject,omitempty"`
+}
+
+// FilterOptions are the options of a wasm hook
+type FilterOptions struct {
+	Path   string `yaml:"path"`
+	Limits `yaml:",inline"`
+	// FailOpen lets requests through when the module fails; by default they
+	// are rejected
+	FailOpen bool `yaml:"fail_open,omitempty"`
+}
+
+// Filter is a hook backed by a module exporting filter, run on request and
+// response events
+type Filter struct {
+	hooks.Base
+	module   *Module
+	failOpen bool
+}
+
+// NewFilterHook creates a Filter from the options of a wasm hook. It is the
+// hooks.Factory of HookType
+func NewFilterHook(options map[string]interface{}) (hooks.Hook, error) {
+	// Options arrive as generic YAML values, so decode them again as a struct
+	data, err := yaml.Marshal(options)
+	if err != nil {
+		return nil, err
+	}
+	var parsed FilterOptions
+	if err := yaml.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid wasm options: %w", err)
+	}
+	if parsed.Path == "" {
+		return nil, fmt.Errorf("wasm hooks need a path option")
+	}
+	module, err := Open(context.Background(), parsed.Path, ExportFilter, parsed.Limits)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{module: module, failOpen: parsed.FailOpen}, nil
+}
+
+// OnRequest implements hooks.Hook
+func (f *Filter) OnRequest(ctx context.Context, request *hooks.Request) error {
+	result, err := f.filter(ctx, FilterInput{Event: hooks.EventRequest, Request: request})
+	if err != nil || result == nil {
+		return err
+	}
+	if result.Content != nil {
+		request.Content = *result.Content
+	}
+	return f.apply(request, result)
+}
+
+// OnResponse implements hooks.Hook
+func (f *Filter) OnResponse(ctx context.Context, request *hooks.Request, response *hooks.Response) error {
+	result, err := f.filter(ctx, FilterInput{Event: hooks.EventResponse, Request: request, Response: response})
+	if err != nil || result == nil {
+		return err
+	}
+	if result.Content != nil {
+		response.Content = *result.Content
+	}
+	return f.apply(request, result)
+}
+
+// apply merges the result's annotations and returns its rejection
+func (f *Filter) apply(request *hooks.Request, result *FilterResult) error {
+	for key, value := range result.Annotations {
+		request.Annotate(key, value)
+	}
+	if result.Reject != "" {
+		return fmt.Errorf("%s", result.Reject)
+	}
+	return nil
+}
+
+// filter runs the module on input, nil when it changes nothing. Failures are
+// errors unless the filter fails open
+func (f *Filter) filter(ctx context.Context, input FilterInput) (*FilterResult, error) {
+	var result FilterResult
+	changed, err := f.module.Call(ctx, input, &result)
+	if err != nil {
+		if f.failOpen {
+			logger.WithField(requestid.Field, requestid.FromContext(ctx)).Warnf("Filter %s failed on %s, continuing: %v", f.module.Path(), input.Event, err)
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !changed {
+		return nil, nil
+	}
+	return &result, nil
+}
@@ -0,0 +1,300 @@
+// Package wasmplugin runs WebAssembly modules that score providers or filter
+// content. Modules run in wazero, sandboxed from the gateway, with a memory
+// limit and a time limit on every call, and are recompiled when their file
+// changes.
+//
+// # ABI
+//
+// A module is a WASI reactor that exports its memory as "memory" and
+//
+//	alloc(size i32) -> i32
+//
+// returning a buffer of size bytes the gateway writes its input into. It
+// exports one or both of
+//
+//	score(ptr i32, len i32) -> i64
+//	filter(ptr i32, len i32) -> i64
+//
+// which read a JSON input of len bytes at ptr and return the location of a
+// JSON output packed as ptr<<32 | len, or 0 to change nothing. A module that
+// exports "_initialize" has it called first. Every call runs in a fresh
+// instance, so modules keep no state between calls.
+//
+// score reads a selection.ScoreRequest and answers a selection.ScoreAdjustment.
+// filter reads a FilterInput and answers a FilterResult
+package wasmplugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/logging"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// logger is the wasmplugin module logger, configurable via LOG_MODULES=wasmplugin=<level>
+var logger = logging.Module("wasmplugin")
+
+// Exports of the ABI
+const (
+	ExportMemory     = "memory"
+	ExportAlloc      = "alloc"
+	ExportScore      = "score"
+	ExportFilter     = "filter"
+	ExportInitialize = "_initialize"
+)
+
+// Defaults of Limits
+const (
+	DefaultMemoryLimitMB  = 16
+	DefaultTimeout        = 100 * time.Millisecond
+	DefaultReloadInterval = 2 * time.Second
+)
+
+// maxOutput bounds the JSON a module may answer with
+const maxOutput = 1 << 20
+
+// pagesPerMB is the number of 64 KiB WebAssembly pages in a MiB
+const pagesPerMB = 16
+
+// Limits bounds the resources of a module
+type Limits struct {
+	// MemoryLimitMB caps the linear memory of an instance
+	MemoryLimitMB int `yaml:"memory_limit_mb,omitempty" json:"memory_limit_mb,omitempty"`
+	// Timeout caps each call, after which the instance is stopped
+	Timeout time.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	// ReloadInterval is how often the module file is checked for changes; a
+	// negative interval disables hot reload
+	ReloadInterval time.Duration `yaml:"reload_interval,omitempty" json:"reload_interval,omitempty"`
+}
+
+// withDefaults fills in the unset limits
+func (l Limits) withDefaults() Limits {
+	if l.MemoryLimitMB <= 0 {
+		l.MemoryLimitMB = DefaultMemoryLimitMB
+	}
+	if l.Timeout <= 0 {
+		l.Timeout = DefaultTimeout
+	}
+	if l.ReloadInterval == 0 {
+		l.ReloadInterval = DefaultReloadInterval
+	}
+	return l
+}
+
+// generation is one compilation of a module file. Calls hold it until they
+// finish, so a reload closes the previous generation only once it is idle
+type generation struct {
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	modTime  time.Time
+	calls    sync.WaitGroup
+}
+
+// Module is a compiled WebAssembly module, recompiled when its file changes
+type Module struct {
+	path    string
+	export  string
+	limits  Limits
+	mutex   sync.Mutex
+	current *generation
+	checked time.Time
+}
+
+// Open compiles the module at path, which must export export besides the
+// memory and alloc of the ABI
+func Open(ctx context.Context, path, export string, limits Limits) (*Module, error) {
+	module := &Module{path: path, export: export, limits: limits.withDefaults()}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read module: %w", err)
+	}
+	if module.current, err = module.compile(ctx, info.ModTime()); err != nil {
+		return nil, err
+	}
+	module.checked = time.Now()
+	return module, nil
+}
+
+// Path returns the module file
+func (m *Module) Path() string {
+	return m.path
+}
+
+// Close releases the compiled module
+func (m *Module) Close(ctx context.Context) error {
+	m.mutex.Lock()
+	current := m.current
+	m.current = nil
+	m.mutex.Unlock()
+
+	if current == nil {
+		return nil
+	}
+	current.calls.Wait()
+	return current.runtime.Close(ctx)
+}
+
+// Call runs the module's export on input encoded as JSON and decodes its
+// answer into output. It reports false when the module changed nothing
+func (m *Module) Call(ctx context.Context, input, output interface{}) (bool, error) {
+	encoded, err := json.Marshal(input)
+	if err != nil {
+		return false, err
+	}
+	answer, err := m.call(ctx, encoded)
+	if err != nil || answer == nil {
+		return false, err
+	}
+	if err := json.Unmarshal(answer, output); err != nil {
+		return false, fmt.Errorf("module %s answered invalid JSON: %w", m.path, err)
+	}
+	return true, nil
+}
+
+// call writes input into a fresh instance and runs the export on it
+func (m *Module) call(ctx context.Context, input []byte) ([]byte, error) {
+	current, err := m.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer current.calls.Done()
+
+	ctx, cancel := context.WithTimeout(ctx, m.limits.Timeout)
+	defer cancel()
+	answer, err := m.run(ctx, current, input)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("module %s exceeded its %s time limit", m.path, m.limits.Timeout)
+	}
+	return answer, err
+}
+
+// run instantiates current and calls the export on input
+func (m *Module) run(ctx context.Context, current *generation, input []byte) ([]byte, error) {
+	config := wazero.NewModuleConfig().WithName("").WithStartFunctions(ExportInitialize)
+	instance, err := current.runtime.InstantiateModule(ctx, current.compiled, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to instantiate module %s: %w", m.path, err)
+	}
+	defer instance.Close(context.Background())
+
+	results, err := instance.ExportedFunction(ExportAlloc).Call(ctx, uint64(len(input)))
+	if err != nil {
+		return nil, fmt.Errorf("module %s: alloc failed: %w", m.path, err)
+	}
+	inputPtr := uint32(results[0])
+	if !instance.Memory().Write(inputPtr, input) {
+		return nil, fmt.Errorf("module %s: alloc returned %d, outside its memory", m.path, inputPtr)
+	}
+
+	results, err = instance.ExportedFunction(m.export).Call(ctx, uint64(inputPtr), uint64(len(input)))
+	if err != nil {
+		return nil, fmt.Errorf("module %s: %s failed: %w", m.path, m.export, err)
+	}
+	if results[0] == 0 {
+		return nil, nil
+	}
+	outputPtr, outputLen := uint32(results[0]>>32), uint32(results[0])
+	if outputLen > maxOutput {
+		return nil, fmt.Errorf("module %s: %s answered %d bytes, more than %d", m.path, m.export, outputLen, maxOutput)
+	}
+	output, ok := instance.Memory().Read(outputPtr, outputLen)
+	if !ok {
+		return nil, fmt.Errorf("module %s: %s answered outside its memory", m.path, m.export)
+	}
+	// Read returns a view of memory that closing the instance releases
+	return append([]byte(nil), output...), nil
+}
+
+// acquire returns the current generation, held for one call, after
+// recompiling the module if its file changed. A failed reload is logged and
+// keeps the previous generation
+func (m *Module) acquire(ctx context.Context) (*generation, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.current == nil {
+		return nil, fmt.Errorf("module %s is closed", m.path)
+	}
+	if m.limits.ReloadInterval > 0 && time.Since(m.checked) >= m.limits.ReloadInterval {
+		m.checked = time.Now()
+		m.reload(ctx)
+	}
+	m.current.calls.Add(1)
+	return m.current, nil
+}
+
+// reload recompiles the module when its file changed since the current
+// generation. Callers hold the mutex
+func (m *Module) reload(ctx context.Context) {
+	info, err := os.Stat(m.path)
+	if err != nil {
+		logger.Warnf("Failed to check module %s for changes: %v", m.path, err)
+		return
+	}
+	if info.ModTime().Equal(m.current.modTime) {
+		return
+	}
+	next, err := m.compile(ctx, info.ModTime())
+	if err != nil {
+		logger.Warnf("Failed to reload module %s, keeping the previous version: %v", m.path, err)
+		// Do not retry the broken file until it changes again
+		m.current.modTime = info.ModTime()
+		return
+	}
+
+	previous := m.current
+	m.current = next
+	logger.Infof("Reloaded module %s", m.path)
+	go func() {
+		previous.calls.Wait()
+		if err := previous.runtime.Close(context.Background()); err != nil {
+			logger.Warnf("Failed to close previous version of module %s: %v", m.path, err)
+		}
+	}()
+}
+
+// compile reads and compiles the module file into a runtime with the memory
+// limit, and checks that it implements the ABI
+func (m *Module) compile(ctx context.Context, modTime time.Time) (*generation, error) {
+	code, err := os.ReadFile(m.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read module: %w", err)
+	}
+
+	config := wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(uint32(m.limits.MemoryLimitMB * pagesPerMB)).
+		WithCloseOnContextDone(true)
+	runtime := wazero.NewRuntimeWithConfig(ctx, config)
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("failed to provide WASI: %w", err)
+	}
+	compiled, err := runtime.CompileModule(ctx, code)
+	if err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("failed to compile module %s: %w", m.path, err)
+	}
+
+	functions := compiled.ExportedFunctions()
+	var missing []string
+	if _, ok := compiled.ExportedMemories()[ExportMemory]; !ok {
+		missing = append(missing, ExportMemory)
+	}
+	for _, name := range []string{ExportAlloc, m.export} {
+		if _, ok := functions[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("module %s does not export %v", m.path, missing)
+	}
+	return &generation{runtime: runtime, compiled: compiled, modTime: modTime}, nil
+}
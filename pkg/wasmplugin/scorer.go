@@ -0,0 +1,92 @@
+package wasmplugin
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/ThatsRight-ItsTJ/Your-PaL-MoE/pkg/selection"
+	"gopkg.in/yaml.v3"
+)
+
+// ScorerSpec configures one scoring module
+type ScorerSpec struct {
+	Name   string `yaml:"name" json:"name"`
+	Path   string `yaml:"path" json:"path"`
+	Limits `yaml:",inline"`
+	// FailOpen keeps the built-in scores when the module fails; by default
+	// the selection fails
+	FailOpen bool `yaml:"fail_open,omitempty" json:"fail_open,omitempty"`
+}
+
+// Scorer is a selection.Scorer backed by a module exporting score
+type Scorer struct {
+	name     string
+	module   *Module
+	failOpen bool
+}
+
+// NewScorer compiles the scoring module described by spec
+func NewScorer(ctx context.Context, spec ScorerSpec) (*Scorer, error) {
+	if spec.Path == "" {
+		return nil, fmt.Errorf("scorers need a path")
+	}
+	module, err := Open(ctx, spec.Path, ExportScore, spec.Limits)
+	if err != nil {
+		return nil, err
+	}
+	return &Scorer{name: spec.Name, module: module, failOpen: spec.FailOpen}, nil
+}
+
+// LoadScorers reads scoring modules from a YAML file with a top-level
+// "scorers" list, in the order they run
+func LoadScorers(ctx context.Context, path string) ([]selection.Scorer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scorers: %w", err)
+	}
+
+	var file struct {
+		Scorers []ScorerSpec `yaml:"scorers"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse scorers %s: %w", path, err)
+	}
+
+	scorers := make([]selection.Scorer, 0, len(file.Scorers))
+	names := make(map[string]bool)
+	for i, spec := range file.Scorers {
+		if spec.Name == "" {
+			return nil, fmt.Errorf("scorer %d: name is required", i+1)
+		}
+		if names[spec.Name] {
+			return nil, fmt.Errorf("scorer %s: defined twice", spec.Name)
+		}
+		names[spec.Name] = true
+
+		scorer, err := NewScorer(ctx, spec)
+		if err != nil {
+			return nil, fmt.Errorf("scorer %s: %w", spec.Name, err)
+		}
+		scorers = append(scorers, scorer)
+	}
+	return scorers, nil
+}
+
+// Name implements selection.Scorer
+func (s *Scorer) Name() string {
+	return s.name
+}
+
+// Score implements selection.Scorer
+func (s *Scorer) Score(ctx context.Context, request selection.ScoreRequest) (selection.ScoreAdjustment, error) {
+	var adjustment selection.ScoreAdjustment
+	if _, err := s.module.Call(ctx, request, &adjustment); err != nil {
+		if s.failOpen {
+			logger.Warnf("Scorer %s failed, keeping the built-in scores: %v", s.name, err)
+			return selection.ScoreAdjustment{}, nil
+		}
+		return selection.ScoreAdjustment{}, err
+	}
+	return adjustment, nil
+}
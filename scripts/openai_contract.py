@@ -0,0 +1,102 @@
+#!/usr/bin/env python3
+"""
+OpenAI contract check with the official Python SDK
+Runs the calls captured in pkg/contract through the real openai package
+against a running gateway, so SDK parsing changes are caught as well
+
+Usage: OPENAI_CONTRACT_URL=http://localhost:8080 OPENAI_CONTRACT_API_KEY=pal_... \
+       python3 scripts/openai_contract.py
+"""
+
+import os
+import sys
+
+import openai
+
+failures = []
+
+
+def check(name, call):
+    """Run one check, recording a failure instead of stopping"""
+    try:
+        call()
+        print(f"ok    {name}")
+    except Exception as e:  # noqa: BLE001 - every failure is reported
+        failures.append(name)
+        print(f"FAIL  {name}: {type(e).__name__}: {e}")
+
+
+def expect_error(error_class, call, param=None, code=None):
+    """Call and require the SDK to raise error_class with param and code"""
+    try:
+        call()
+    except error_class as e:
+        if param is not None and e.param != param:
+            raise AssertionError(f"param {e.param!r}, want {param!r}")
+        if code is not None and e.code != code:
+            raise AssertionError(f"code {e.code!r}, want {code!r}")
+        return
+    raise AssertionError(f"no {error_class.__name__} raised")
+
+
+def main():
+    base_url = os.environ.get("OPENAI_CONTRACT_URL")
+    if not base_url:
+        print("set OPENAI_CONTRACT_URL to the gateway to check")
+        return 2
+    client = openai.OpenAI(
+        base_url=base_url.rstrip("/") + "/v1",
+        api_key=os.environ.get("OPENAI_CONTRACT_API_KEY", "sk-contract-test"),
+        max_retries=0,
+    )
+    messages = [
+        {"role": "system", "content": "You are a terse assistant."},
+        {"role": "user", "content": "Say hello to the contract suite."},
+    ]
+
+    def chat():
+        completion = client.chat.completions.create(model="gpt-4", messages=messages, temperature=0.2)
+        choice = completion.choices[0]
+        assert completion.object == "chat.completion"
+        assert choice.message.role == "assistant" and choice.message.content
+        assert choice.finish_reason in ("stop", "length")
+        assert completion.usage.total_tokens == completion.usage.prompt_tokens + completion.usage.completion_tokens
+
+    def stream():
+        chunks = list(client.chat.completions.create(
+            model="gpt-4", messages=messages, stream=True, stream_options={"include_usage": True}))
+        content = "".join(c.choices[0].delta.content or "" for c in chunks if c.choices)
+        assert content, "stream has no content"
+        assert any(c.choices and c.choices[0].finish_reason for c in chunks), "no finish reason"
+        assert chunks[-1].usage is not None, "last chunk has no usage"
+
+    def models():
+        listed = client.models.list().data
+        assert listed, "no models"
+        assert client.models.retrieve(listed[0].id).id == listed[0].id
+
+    check("chat completion", chat)
+    check("streamed chat completion", stream)
+    check("models", models)
+    check("unknown model", lambda: expect_error(
+        openai.NotFoundError, lambda: client.models.retrieve("no-such-model"), param="model", code="model_not_found"))
+    check("unknown role", lambda: expect_error(
+        openai.BadRequestError,
+        lambda: client.chat.completions.create(model="gpt-4", messages=[{"role": "narrator", "content": "hi"}]),
+        param="messages[0].role"))
+    check("empty messages", lambda: expect_error(
+        openai.BadRequestError, lambda: client.chat.completions.create(model="gpt-4", messages=[]), param="messages"))
+
+    # The gateway does not serve embeddings; the SDK must raise NotFoundError
+    check("embeddings", lambda: expect_error(
+        openai.NotFoundError,
+        lambda: client.embeddings.create(model="text-embedding-3-small", input=["The food was delicious."])))
+
+    if failures:
+        print(f"{len(failures)} check(s) failed")
+        return 1
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())